@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/amurg-ai/amurg/hub/permlink"
+	"github.com/amurg-ai/amurg/hub/router"
+	"github.com/go-chi/chi/v5"
+)
+
+// --- Pending permission request handlers ---
+
+// pendingPermissionResponse adds signed one-time links to a pending request so
+// callers can forward them in notifications.
+type pendingPermissionResponse struct {
+	router.PendingPermission
+	ApproveURL string `json:"approve_url"`
+	DenyURL    string `json:"deny_url"`
+}
+
+func (s *Server) handleListPendingPermissions(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+
+	// Admins see every pending request in the org; users only those of sessions
+	// they own or collaborate on.
+	pending := s.router.ListPendingPermissions(identity.OrgID, "")
+
+	result := make([]pendingPermissionResponse, 0, len(pending))
+	for _, p := range pending {
+		if identity.Role != "admin" && p.UserID != identity.UserID && !s.canCollaborateOn(r, p.SessionID) {
+			continue
+		}
+		result = append(result, pendingPermissionResponse{
+			PendingPermission: p,
+			ApproveURL:        s.permLinks.URL(p.RequestID, permlink.DecisionApprove, p.ExpiresAt),
+			DenyURL:           s.permLinks.URL(p.RequestID, permlink.DecisionDeny, p.ExpiresAt),
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handlePermissionDecision(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+	requestID := chi.URLParam(r, "requestID")
+
+	var req struct {
+		Approved    *bool `json:"approved"`
+		AlwaysAllow bool  `json:"always_allow"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Approved == nil {
+		writeError(w, http.StatusBadRequest, "approved is required")
+		return
+	}
+
+	pending, ok := s.router.GetPendingPermission(requestID)
+	if !ok || pending.OrgID != identity.OrgID {
+		writeError(w, http.StatusNotFound, "permission request not found or already answered")
+		return
+	}
+	// Owners and collaborators may answer; admins may answer any request in the org.
+	if pending.UserID != identity.UserID && identity.Role != "admin" && !s.canCollaborateOn(r, pending.SessionID) {
+		writeError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	// An always-allow rule acts for the owner in later sessions, so only the
+	// owner or an admin may create one; collaborators approve once.
+	alwaysAllow := req.AlwaysAllow && (pending.UserID == identity.UserID || identity.Role == "admin")
+	err := s.router.ResolvePermission(r.Context(), requestID, pending.SessionID, *req.Approved, alwaysAllow, identity.UserID, "api")
+	if errors.Is(err, router.ErrPermissionNotPending) {
+		writeError(w, http.StatusNotFound, "permission request not found or already answered")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to answer permission request")
+		return
+	}
+
+	status := "denied"
+	if *req.Approved {
+		status = "approved"
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": status, "request_id": requestID})
+}
+
+// canCollaborateOn reports whether the caller collaborates on a session.
+func (s *Server) canCollaborateOn(r *http.Request, sessionID string) bool {
+	sess, err := s.store.GetSession(r.Context(), sessionID)
+	if err != nil || sess == nil {
+		return false
+	}
+	return s.router.CanCollaborate(r.Context(), sess, getIdentityFromContext(r.Context()).UserID)
+}
+
+var permissionLinkPage = template.Must(template.New("permission-link").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Amurg permission request</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 32rem; margin: 2rem auto; padding: 0 1rem; color: #1f2328; }
+pre { background: #f6f8fa; padding: .75rem; border-radius: 6px; white-space: pre-wrap; word-break: break-word; }
+button { font-size: 1rem; padding: .6rem 1.2rem; border-radius: 6px; border: 0; color: #fff; background: {{if .Approve}}#1f883d{{else}}#cf222e{{end}}; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{with .Pending}}
+<p><strong>Tool:</strong> {{.Tool}}</p>
+{{if .Description}}<pre>{{.Description}}</pre>{{end}}
+{{if .Resource}}<p><strong>Resource:</strong> {{.Resource}}</p>{{end}}
+{{end}}
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+{{if .Confirm}}
+<form method="post">
+<button type="submit">{{if .Approve}}Approve{{else}}Deny{{end}}</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+type permissionLinkView struct {
+	Title   string
+	Message string
+	Pending *router.PendingPermission
+	Approve bool
+	Confirm bool
+}
+
+// handlePermissionLink serves signed approve/deny links. GET renders a
+// confirmation page so that link previewers cannot answer the request by
+// fetching the URL; the decision is applied by the page's POST.
+func (s *Server) handlePermissionLink(w http.ResponseWriter, r *http.Request) {
+	requestID := chi.URLParam(r, "requestID")
+	q := r.URL.Query()
+	decision := q.Get("decision")
+	expires, _ := strconv.ParseInt(q.Get("expires"), 10, 64)
+
+	render := func(status int, view permissionLinkView) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		_ = permissionLinkPage.Execute(w, view)
+	}
+
+	switch err := s.permLinks.Verify(requestID, decision, expires, q.Get("sig"), time.Now()); {
+	case errors.Is(err, permlink.ErrExpired):
+		render(http.StatusGone, permissionLinkView{Title: "Link expired", Message: "This permission request is no longer pending."})
+		return
+	case err != nil:
+		render(http.StatusForbidden, permissionLinkView{Title: "Invalid link", Message: "This link is not valid."})
+		return
+	}
+
+	approve := decision == permlink.DecisionApprove
+	pending, ok := s.router.GetPendingPermission(requestID)
+	if !ok {
+		render(http.StatusGone, permissionLinkView{Title: "Already answered", Message: "This permission request was already answered or has timed out."})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		title := "Deny permission request?"
+		if approve {
+			title = "Approve permission request?"
+		}
+		render(http.StatusOK, permissionLinkView{Title: title, Pending: &pending, Approve: approve, Confirm: true})
+		return
+	}
+
+	err := s.router.ResolvePermission(r.Context(), requestID, pending.SessionID, approve, false, "", "link")
+	if errors.Is(err, router.ErrPermissionNotPending) {
+		render(http.StatusGone, permissionLinkView{Title: "Already answered", Message: "This permission request was already answered or has timed out."})
+		return
+	}
+	if err != nil {
+		render(http.StatusInternalServerError, permissionLinkView{Title: "Something went wrong", Message: "The decision could not be recorded."})
+		return
+	}
+
+	title := "Denied"
+	if approve {
+		title = "Approved"
+	}
+	render(http.StatusOK, permissionLinkView{Title: title, Pending: &pending, Approve: approve})
+}
@@ -0,0 +1,595 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/amurg-ai/amurg/pkg/protocol"
+)
+
+// exportContentTypes maps the supported ?format= values of the export endpoint
+// to the Content-Type they are served with.
+var exportContentTypes = map[string]string{
+	"md":    "text/markdown; charset=utf-8",
+	"jsonl": "application/x-ndjson",
+	"html":  "text/html; charset=utf-8",
+}
+
+// exportPageSize is the number of messages read from the store per query
+// while assembling a transcript.
+const exportPageSize = 500
+
+// transcript is a session together with every entry needed to render it.
+type transcript struct {
+	Session   *store.Session
+	AgentName string
+	Entries   []transcriptEntry
+}
+
+// transcriptEntry is a single item in an exported transcript: a message, a
+// tool call paired with its result, an attached file, the files a turn
+// changed or a permission event.
+type transcriptEntry struct {
+	Kind       string             `json:"kind"` // "message", "tool", "file", "changes", "permission"
+	Time       time.Time          `json:"time"`
+	Seq        int64              `json:"seq,omitempty"`
+	Direction  string             `json:"direction,omitempty"`
+	Channel    string             `json:"channel,omitempty"`
+	Content    string             `json:"content,omitempty"`
+	Tool       *toolCall          `json:"tool,omitempty"`
+	File       *fileRef           `json:"file,omitempty"`
+	Changes    *protocol.TurnDiff `json:"changes,omitempty"`
+	Permission *permissionEvent   `json:"permission,omitempty"`
+
+	// Continued is set on a message that directly follows another message
+	// from the same direction and channel, so renderers can merge them.
+	Continued bool `json:"-"`
+}
+
+// toolCall is a tool_use block and, once seen, its matching tool_result.
+type toolCall struct {
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	Result    *string         `json:"result,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+	ResultSeq int64           `json:"result_seq,omitempty"`
+}
+
+// fileRef describes a file uploaded by the user or produced by the agent.
+type fileRef struct {
+	ID        string `json:"file_id"`
+	Name      string `json:"name"`
+	MimeType  string `json:"mime_type,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+	Direction string `json:"direction,omitempty"` // "upload" or "download"
+	URL       string `json:"url"`
+}
+
+// permissionEvent is a permission request or its outcome, taken from the
+// audit log.
+type permissionEvent struct {
+	Outcome   string `json:"outcome"` // "requested", "granted", "denied", "timeout"
+	RequestID string `json:"request_id,omitempty"`
+	Tool      string `json:"tool,omitempty"`
+	Resource  string `json:"resource,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+// handleExportSession handles GET /api/sessions/{sessionID}/export?format=md|jsonl|html
+// and renders the whole transcript as a downloadable document.
+func (s *Server) handleExportSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+	identity := getIdentityFromContext(r.Context())
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "md"
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "format must be md, jsonl, or html")
+		return
+	}
+
+	sess, err := s.store.GetSession(r.Context(), sessionID)
+	if err != nil || sess == nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if !s.router.CanViewSession(r.Context(), sess, identity.UserID, identity.Role) {
+		writeError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	t, err := s.buildTranscript(r.Context(), sess)
+	if err != nil {
+		s.logger.Warn("failed to build transcript", "session_id", sessionID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to export session")
+		return
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "md":
+		err = writeTranscriptMarkdown(&buf, t)
+	case "jsonl":
+		err = writeTranscriptJSONL(&buf, t)
+	case "html":
+		err = writeTranscriptHTML(&buf, t)
+	}
+	if err != nil {
+		s.logger.Warn("failed to render transcript", "session_id", sessionID, "format", format, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to export session")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="session-%s.%s"`, sanitizeFilename(sess.ID), format))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// buildTranscript loads every message and permission event of a session and
+// merges them into a single chronological list of entries.
+func (s *Server) buildTranscript(ctx context.Context, sess *store.Session) (*transcript, error) {
+	t := &transcript{Session: sess}
+	if agent, err := s.store.GetAgent(ctx, sess.AgentID); err == nil && agent != nil {
+		t.AgentName = agent.Name
+	}
+
+	var entries []transcriptEntry
+	toolIndex := make(map[string]int) // tool_use ID -> index in entries
+	var afterSeq int64
+	for {
+		page, err := s.store.GetMessages(ctx, sess.ID, afterSeq, exportPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("get messages: %w", err)
+		}
+		for _, msg := range page {
+			entries = s.appendMessageEntry(entries, toolIndex, sess.ID, msg)
+		}
+		if len(page) < exportPageSize {
+			break
+		}
+		afterSeq = page[len(page)-1].Seq
+	}
+
+	perms, err := s.sessionPermissionEvents(ctx, sess)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, perms...)
+
+	// Messages are already in seq order; interleave permission events by time
+	// while keeping that order for entries with equal timestamps.
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+
+	for i := range entries {
+		if i == 0 || entries[i].Kind != "message" {
+			continue
+		}
+		prev := entries[i-1]
+		entries[i].Continued = prev.Kind == "message" &&
+			prev.Direction == entries[i].Direction && prev.Channel == entries[i].Channel
+	}
+	t.Entries = entries
+	return t, nil
+}
+
+// appendMessageEntry converts a stored message into a transcript entry. Tool
+// results are attached to the tool_use entry they answer instead of being
+// appended on their own.
+func (s *Server) appendMessageEntry(entries []transcriptEntry, toolIndex map[string]int, sessionID string, msg store.Message) []transcriptEntry {
+	entry := transcriptEntry{
+		Kind:      "message",
+		Time:      msg.CreatedAt,
+		Seq:       msg.Seq,
+		Direction: msg.Direction,
+		Channel:   msg.Channel,
+		Content:   msg.Content,
+	}
+
+	switch msg.Channel {
+	case "tool", "history_tool", "question":
+		var block struct {
+			Type      string          `json:"type"`
+			ID        string          `json:"id"`
+			Name      string          `json:"name"`
+			Input     json.RawMessage `json:"input"`
+			ToolUseID string          `json:"tool_use_id"`
+			Content   string          `json:"content"`
+			IsError   bool            `json:"is_error"`
+		}
+		if err := json.Unmarshal([]byte(msg.Content), &block); err != nil {
+			break
+		}
+		switch block.Type {
+		case "tool_use":
+			entry.Kind = "tool"
+			entry.Content = ""
+			entry.Tool = &toolCall{ID: block.ID, Name: block.Name, Input: block.Input}
+			if block.ID != "" {
+				toolIndex[block.ID] = len(entries)
+			}
+		case "tool_result":
+			result := block.Content
+			if i, ok := toolIndex[block.ToolUseID]; ok && entries[i].Tool.Result == nil {
+				entries[i].Tool.Result = &result
+				entries[i].Tool.IsError = block.IsError
+				entries[i].Tool.ResultSeq = msg.Seq
+				return entries
+			}
+			entry.Kind = "tool"
+			entry.Content = ""
+			entry.Tool = &toolCall{ID: block.ToolUseID, Result: &result, IsError: block.IsError, ResultSeq: msg.Seq}
+		}
+
+	case "file":
+		var meta struct {
+			FileID    string `json:"file_id"`
+			Name      string `json:"name"`
+			MimeType  string `json:"mime_type"`
+			Size      int64  `json:"size"`
+			Direction string `json:"direction"`
+		}
+		if err := json.Unmarshal([]byte(msg.Content), &meta); err != nil || meta.FileID == "" {
+			break
+		}
+		entry.Kind = "file"
+		entry.Content = ""
+		entry.File = &fileRef{
+			ID:        meta.FileID,
+			Name:      meta.Name,
+			MimeType:  meta.MimeType,
+			Size:      meta.Size,
+			Direction: meta.Direction,
+			URL:       strings.TrimRight(s.baseURL, "/") + "/api/files/" + url.PathEscape(meta.FileID) + "?session_id=" + url.QueryEscape(sessionID),
+		}
+
+	case "diff":
+		var diff protocol.TurnDiff
+		if err := json.Unmarshal([]byte(msg.Content), &diff); err != nil {
+			break
+		}
+		entry.Kind = "changes"
+		entry.Content = ""
+		entry.Changes = &diff
+	}
+
+	return append(entries, entry)
+}
+
+// sessionPermissionEvents returns the permission requests and decisions
+// recorded in the audit log for a session, oldest first.
+func (s *Server) sessionPermissionEvents(ctx context.Context, sess *store.Session) ([]transcriptEntry, error) {
+	var events []store.AuditEvent
+	for offset := 0; ; offset += exportPageSize {
+		page, err := s.store.ListAuditEventsFiltered(ctx, sess.OrgID, store.AuditFilter{
+			Action:    "permission.",
+			SessionID: sess.ID,
+			Limit:     exportPageSize,
+			Offset:    offset,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list permission events: %w", err)
+		}
+		events = append(events, page...)
+		if len(page) < exportPageSize {
+			break
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].CreatedAt.Before(events[j].CreatedAt) })
+
+	tools := make(map[string]permissionEvent) // request ID -> requested event
+	usernames := make(map[string]string)      // user ID -> username
+	entries := make([]transcriptEntry, 0, len(events))
+	for _, e := range events {
+		var detail struct {
+			RequestID string `json:"request_id"`
+			Tool      string `json:"tool"`
+			Resource  string `json:"resource"`
+		}
+		_ = json.Unmarshal(e.Detail, &detail)
+
+		p := permissionEvent{
+			Outcome:   strings.TrimPrefix(e.Action, "permission."),
+			RequestID: detail.RequestID,
+			Tool:      detail.Tool,
+			Resource:  detail.Resource,
+			UserID:    e.UserID,
+		}
+		if p.UserID != "" {
+			name, ok := usernames[p.UserID]
+			if !ok {
+				if u, err := s.store.GetUserByID(ctx, p.UserID); err == nil && u != nil {
+					name = u.Username
+				}
+				usernames[p.UserID] = name
+			}
+			p.Username = name
+		}
+		if p.Outcome == "requested" {
+			tools[p.RequestID] = p
+		} else if req, ok := tools[p.RequestID]; ok {
+			if p.Tool == "" {
+				p.Tool = req.Tool
+			}
+			if p.Resource == "" {
+				p.Resource = req.Resource
+			}
+		}
+		entries = append(entries, transcriptEntry{Kind: "permission", Time: e.CreatedAt, Permission: &p})
+	}
+	return entries, nil
+}
+
+// --- Renderers ---
+
+func writeTranscriptJSONL(w io.Writer, t *transcript) error {
+	enc := json.NewEncoder(w)
+	header := struct {
+		Kind string `json:"kind"`
+		store.Session
+	}{Kind: "session", Session: *t.Session}
+	header.AgentName = t.AgentName
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	for _, e := range t.Entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTranscriptMarkdown(w io.Writer, t *transcript) error {
+	var b strings.Builder
+	sess := t.Session
+
+	fmt.Fprintf(&b, "# Session %s\n\n", sess.ID)
+	fmt.Fprintf(&b, "- **Agent:** %s (`%s`)\n", fallbackString(t.AgentName, sess.AgentID), sess.AgentID)
+	fmt.Fprintf(&b, "- **Profile:** %s\n", sess.Profile)
+	fmt.Fprintf(&b, "- **State:** %s\n", sess.State)
+	fmt.Fprintf(&b, "- **Created:** %s\n", sess.CreatedAt.UTC().Format(time.RFC3339))
+	if sess.ResumedFrom != "" {
+		fmt.Fprintf(&b, "- **Resumed from:** `%s`\n", sess.ResumedFrom)
+	}
+
+	for _, e := range t.Entries {
+		switch e.Kind {
+		case "message":
+			if e.Channel == "system" {
+				fmt.Fprintf(&b, "\n_%s_\n", strings.TrimSpace(e.Content))
+				continue
+			}
+			if !e.Continued {
+				fmt.Fprintf(&b, "\n### %s · %s\n", speakerLabel(e), e.Time.UTC().Format(time.RFC3339))
+			}
+			if e.Channel == "stderr" {
+				fmt.Fprintf(&b, "\n%s\n", fenced(e.Content, ""))
+			} else {
+				fmt.Fprintf(&b, "\n%s\n", strings.TrimRight(e.Content, "\n"))
+			}
+
+		case "tool":
+			name := fallbackString(e.Tool.Name, "unknown")
+			fmt.Fprintf(&b, "\n**Tool:** `%s`\n", name)
+			if len(e.Tool.Input) > 0 {
+				fmt.Fprintf(&b, "\n%s\n", fenced(indentJSON(e.Tool.Input), "json"))
+			}
+			if e.Tool.Result != nil {
+				summary := "Result"
+				if e.Tool.IsError {
+					summary = "Result (error)"
+				}
+				fmt.Fprintf(&b, "\n<details><summary>%s</summary>\n\n%s\n\n</details>\n", summary, fenced(*e.Tool.Result, ""))
+			}
+
+		case "file":
+			fmt.Fprintf(&b, "\n**File (%s):** [%s](%s)", fallbackString(e.File.Direction, "attachment"), e.File.Name, e.File.URL)
+			if e.File.MimeType != "" {
+				fmt.Fprintf(&b, " · %s", e.File.MimeType)
+			}
+			if e.File.Size > 0 {
+				fmt.Fprintf(&b, " · %d bytes", e.File.Size)
+			}
+			b.WriteString("\n")
+
+		case "changes":
+			fmt.Fprintf(&b, "\n**Changes:** %d file(s)\n\n", len(e.Changes.Files))
+			for _, f := range e.Changes.Files {
+				fmt.Fprintf(&b, "- `%s` %s\n", f.Path, changeSummary(f))
+			}
+			if e.Changes.Patch != "" {
+				summary := "Diff"
+				if e.Changes.Truncated {
+					summary = "Diff (truncated)"
+				}
+				fmt.Fprintf(&b, "\n<details><summary>%s</summary>\n\n%s\n\n</details>\n", summary, fenced(e.Changes.Patch, "diff"))
+			}
+
+		case "permission":
+			fmt.Fprintf(&b, "\n> **Permission %s**", e.Permission.Outcome)
+			if e.Permission.Tool != "" {
+				fmt.Fprintf(&b, ": `%s`", e.Permission.Tool)
+			}
+			if e.Permission.Resource != "" {
+				fmt.Fprintf(&b, " on `%s`", e.Permission.Resource)
+			}
+			if e.Permission.UserID != "" {
+				fmt.Fprintf(&b, " by %s", fallbackString(e.Permission.Username, e.Permission.UserID))
+			}
+			fmt.Fprintf(&b, " · %s\n", e.Time.UTC().Format(time.RFC3339))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var transcriptHTMLTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"speaker": speakerLabel,
+	"change":  changeSummary,
+	"json":    indentJSON,
+	"ts":      func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+	"or":      fallbackString,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Session {{.Session.ID}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 960px; margin: 2rem auto; padding: 0 1rem; color: #1f2328; }
+header dl { display: grid; grid-template-columns: max-content 1fr; gap: .25rem 1rem; }
+header dt { font-weight: 600; }
+.entry { margin: 1rem 0; }
+.speaker { font-weight: 600; margin-top: 1.5rem; }
+.speaker time, .permission time { color: #656d76; font-weight: normal; font-size: .85em; margin-left: .5rem; }
+.content { white-space: pre-wrap; }
+.user .content { background: #f6f8fa; border-radius: 6px; padding: .5rem .75rem; }
+.system { font-style: italic; color: #656d76; }
+pre { background: #f6f8fa; border-radius: 6px; padding: .75rem; overflow-x: auto; }
+.stderr pre, .error pre { background: #fff1f0; }
+.permission { border-left: 3px solid #d4a72c; padding-left: .75rem; }
+.changes ul { margin: .25rem 0; }
+</style>
+</head>
+<body>
+<header>
+<h1>Session {{.Session.ID}}</h1>
+<dl>
+<dt>Agent</dt><dd>{{or .AgentName .Session.AgentID}} (<code>{{.Session.AgentID}}</code>)</dd>
+<dt>Profile</dt><dd>{{.Session.Profile}}</dd>
+<dt>State</dt><dd>{{.Session.State}}</dd>
+<dt>Created</dt><dd>{{ts .Session.CreatedAt}}</dd>
+{{- if .Session.ResumedFrom}}
+<dt>Resumed from</dt><dd><code>{{.Session.ResumedFrom}}</code></dd>
+{{- end}}
+</dl>
+</header>
+<main>
+{{- range .Entries}}
+{{- if eq .Kind "message"}}
+{{- if eq .Channel "system"}}
+<div class="entry system">{{.Content}}</div>
+{{- else}}
+{{- if not .Continued}}
+<div class="speaker">{{speaker .}}<time>{{ts .Time}}</time></div>
+{{- end}}
+<div class="entry {{.Direction}} {{.Channel}}">{{if eq .Channel "stderr"}}<pre>{{.Content}}</pre>{{else}}<div class="content">{{.Content}}</div>{{end}}</div>
+{{- end}}
+{{- else if eq .Kind "tool"}}
+<div class="entry tool{{if .Tool.IsError}} error{{end}}">
+<strong>Tool:</strong> <code>{{or .Tool.Name "unknown"}}</code>
+{{- if .Tool.Input}}
+<pre>{{json .Tool.Input}}</pre>
+{{- end}}
+{{- if .Tool.Result}}
+<details><summary>Result{{if .Tool.IsError}} (error){{end}}</summary><pre>{{.Tool.Result}}</pre></details>
+{{- end}}
+</div>
+{{- else if eq .Kind "file"}}
+<div class="entry file"><strong>File ({{or .File.Direction "attachment"}}):</strong> <a href="{{.File.URL}}">{{.File.Name}}</a>{{if .File.MimeType}} · {{.File.MimeType}}{{end}}{{if .File.Size}} · {{.File.Size}} bytes{{end}}</div>
+{{- else if eq .Kind "changes"}}
+<div class="entry changes">
+<strong>Changes:</strong> {{len .Changes.Files}} file(s)
+<ul>
+{{- range .Changes.Files}}
+<li><code>{{.Path}}</code> {{change .}}</li>
+{{- end}}
+</ul>
+{{- if .Changes.Patch}}
+<details><summary>Diff{{if .Changes.Truncated}} (truncated){{end}}</summary><pre>{{.Changes.Patch}}</pre></details>
+{{- end}}
+</div>
+{{- else if eq .Kind "permission"}}
+<div class="entry permission"><strong>Permission {{.Permission.Outcome}}</strong>{{if .Permission.Tool}}: <code>{{.Permission.Tool}}</code>{{end}}{{if .Permission.Resource}} on <code>{{.Permission.Resource}}</code>{{end}}{{if .Permission.UserID}} by {{or .Permission.Username .Permission.UserID}}{{end}}<time>{{ts .Time}}</time></div>
+{{- end}}
+{{- end}}
+</main>
+</body>
+</html>
+`))
+
+func writeTranscriptHTML(w io.Writer, t *transcript) error {
+	return transcriptHTMLTemplate.Execute(w, t)
+}
+
+// speakerLabel returns the heading used for a message entry.
+func speakerLabel(e transcriptEntry) string {
+	label := "Agent"
+	if e.Direction == "user" {
+		label = "User"
+	}
+	switch e.Channel {
+	case "", "stdout", "stdin":
+		return label
+	case "history_user":
+		return "User (history)"
+	case "history_assistant":
+		return "Agent (history)"
+	default:
+		return label + " (" + e.Channel + ")"
+	}
+}
+
+// changeSummary describes how a file changed, e.g. "(modified, +3 -1)".
+func changeSummary(f protocol.FileChange) string {
+	status := f.Status
+	if f.OldPath != "" {
+		status += " from " + f.OldPath
+	}
+	if f.Binary {
+		return "(" + status + ", binary)"
+	}
+	return fmt.Sprintf("(%s, +%d -%d)", status, f.Additions, f.Deletions)
+}
+
+// fenced wraps s in a Markdown code fence long enough not to be closed by any
+// backtick run inside s.
+func fenced(s, lang string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + lang + "\n" + strings.TrimRight(s, "\n") + "\n" + fence
+}
+
+// indentJSON pretty-prints raw JSON, returning it unchanged if it is invalid.
+func indentJSON(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+func fallbackString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
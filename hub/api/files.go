@@ -37,13 +37,13 @@ func (s *Server) handleUploadFile(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionID")
 	identity := getIdentityFromContext(r.Context())
 
-	// Verify session ownership.
+	// Verify the caller owns or collaborates on the session.
 	sess, err := s.store.GetSession(r.Context(), sessionID)
 	if err != nil || sess == nil {
 		writeError(w, http.StatusNotFound, "session not found")
 		return
 	}
-	if sess.UserID != identity.UserID && identity.Role != "admin" {
+	if !s.router.CanCollaborate(r.Context(), sess, identity.UserID) && identity.Role != "admin" {
 		writeError(w, http.StatusForbidden, "access denied")
 		return
 	}
@@ -180,7 +180,7 @@ func (s *Server) handleListSessionFiles(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusNotFound, "session not found")
 		return
 	}
-	if sess.UserID != identity.UserID && identity.Role != "admin" {
+	if !s.router.CanViewSession(r.Context(), sess, identity.UserID, identity.Role) {
 		writeError(w, http.StatusForbidden, "access denied")
 		return
 	}
@@ -246,13 +246,13 @@ func (s *Server) handleDownloadFile(w http.ResponseWriter, r *http.Request) {
 
 	identity := getIdentityFromContext(r.Context())
 
-	// Verify session ownership.
+	// Verify session access.
 	sess, err := s.store.GetSession(r.Context(), sessionID)
 	if err != nil || sess == nil {
 		writeError(w, http.StatusNotFound, "session not found")
 		return
 	}
-	if sess.UserID != identity.UserID && identity.Role != "admin" {
+	if !s.router.CanViewSession(r.Context(), sess, identity.UserID, identity.Role) {
 		writeError(w, http.StatusForbidden, "access denied")
 		return
 	}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/amurg-ai/amurg/hub/router"
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/amurg-ai/amurg/pkg/protocol"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// --- Session fork handlers ---
+
+// handleForkSession creates a new session from a parent session's transcript
+// up to a given message. When the fork point is the end of the transcript and
+// the agent supports resume, the runtime also branches the agent's native
+// session so it continues with the same context; earlier fork points get the
+// transcript only, since the native session cannot be rewound, and the agent
+// is handed that transcript with the first prompt. The new session's
+// fork_mode says which one the caller got.
+func (s *Server) handleForkSession(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+
+	var req struct {
+		Seq int64 `json:"seq"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Seq < 1 {
+		writeError(w, http.StatusBadRequest, "seq must be a positive message sequence number")
+		return
+	}
+
+	parent, err := s.store.GetSession(r.Context(), chi.URLParam(r, "sessionID"))
+	if err != nil || parent == nil || parent.OrgID != identity.OrgID {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if !s.router.CanCollaborate(r.Context(), parent, identity.UserID) {
+		writeError(w, http.StatusForbidden, "access denied")
+		return
+	}
+	if s.defaultAgentAccess == "none" {
+		hasAccess, err := s.store.HasAgentAccess(r.Context(), identity.UserID, parent.AgentID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to check permissions")
+			return
+		}
+		if !hasAccess {
+			writeError(w, http.StatusForbidden, "no access to this agent")
+			return
+		}
+	}
+
+	// The fork point must be an existing message.
+	at, err := s.store.GetMessages(r.Context(), parent.ID, req.Seq-1, 1)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get messages")
+		return
+	}
+	if len(at) == 0 || at[0].Seq != req.Seq {
+		writeError(w, http.StatusBadRequest, "no message with that seq")
+		return
+	}
+	later, err := s.store.GetMessages(r.Context(), parent.ID, req.Seq, 1)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get messages")
+		return
+	}
+
+	opt := router.CreateSessionOption{
+		ResumeSessionID: parent.ID,
+		PromptProfile:   parent.PromptProfile,
+		ForkSeq:         req.Seq,
+	}
+	if len(later) == 0 && parent.NativeHandle != "" && s.agentResumeAttach(r, parent) {
+		opt.ResumeNativeHandle = parent.NativeHandle
+	}
+
+	if s.enforcer != nil {
+		if err := s.enforcer.CheckTrialExpiry(r.Context(), identity.OrgID); err != nil {
+			writeError(w, http.StatusPaymentRequired, err.Error())
+			return
+		}
+		if err := s.enforcer.CheckSessionLimit(r.Context(), identity.OrgID); err != nil {
+			writeError(w, http.StatusForbidden, err.Error())
+			return
+		}
+	}
+
+	sess, err := s.router.CreateSession(r.Context(), identity.UserID, parent.AgentID, opt)
+	if err != nil {
+		if strings.Contains(err.Error(), "max sessions") {
+			writeError(w, http.StatusTooManyRequests, "maximum sessions per user reached")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fork session")
+		return
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "session.fork",
+		UserID: identity.UserID, SessionID: sess.ID, AgentID: sess.AgentID,
+		Detail: json.RawMessage(fmt.Sprintf(`{"forked_from":%q,"seq":%d,"mode":%q}`,
+			parent.ID, req.Seq, sess.ForkMode)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "session.fork", "error", err)
+	}
+
+	writeJSON(w, http.StatusCreated, sess)
+}
+
+// agentResumeAttach reports whether the session's agent can attach to an
+// existing native session.
+func (s *Server) agentResumeAttach(r *http.Request, sess *store.Session) bool {
+	agent, err := s.store.GetAgent(r.Context(), sess.AgentID)
+	if err == nil && agent != nil && agent.Caps != "" {
+		var caps protocol.ProfileCaps
+		if err := json.Unmarshal([]byte(agent.Caps), &caps); err == nil && caps.ExecModel != "" {
+			return caps.ResumeAttach
+		}
+	}
+	return protocol.KnownProfiles[sess.Profile].ResumeAttach
+}
+
+// handleListSessionForks lists the sessions resumed or forked from a session
+// that the caller can see.
+func (s *Server) handleListSessionForks(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+	sess, err := s.store.GetSession(r.Context(), chi.URLParam(r, "sessionID"))
+	if err != nil || sess == nil || sess.OrgID != identity.OrgID {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if !s.router.CanViewSession(r.Context(), sess, identity.UserID, identity.Role) {
+		writeError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	children, err := s.store.ListChildSessions(r.Context(), sess.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list forks")
+		return
+	}
+	visible := []store.Session{}
+	for i := range children {
+		if s.router.CanViewSession(r.Context(), &children[i], identity.UserID, identity.Role) {
+			visible = append(visible, children[i])
+		}
+	}
+	writeJSON(w, http.StatusOK, visible)
+}
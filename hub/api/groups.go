@@ -0,0 +1,342 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// --- Group handlers (admin only) ---
+
+type groupRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+// apply copies the fields set in req onto g.
+func (req *groupRequest) apply(g *store.Group) {
+	if req.Name != nil {
+		g.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		g.Description = *req.Description
+	}
+}
+
+// getOrgGroup loads a group by URL param and writes 404 unless it belongs to the caller's org.
+func (s *Server) getOrgGroup(w http.ResponseWriter, r *http.Request) *store.Group {
+	identity := getIdentityFromContext(r.Context())
+	g, err := s.store.GetGroup(r.Context(), chi.URLParam(r, "groupID"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get group")
+		return nil
+	}
+	if g == nil || g.OrgID != identity.OrgID {
+		writeError(w, http.StatusNotFound, "group not found")
+		return nil
+	}
+	return g
+}
+
+// groupNameTaken reports whether another group in the org already uses name.
+func (s *Server) groupNameTaken(r *http.Request, g *store.Group) (bool, error) {
+	groups, err := s.store.ListGroups(r.Context(), g.OrgID)
+	if err != nil {
+		return false, err
+	}
+	for _, other := range groups {
+		if other.ID != g.ID && strings.EqualFold(other.Name, g.Name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Server) handleListGroups(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+	groups, err := s.store.ListGroups(r.Context(), identity.OrgID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list groups")
+		return
+	}
+	if groups == nil {
+		groups = []store.Group{}
+	}
+	writeJSON(w, http.StatusOK, groups)
+}
+
+func (s *Server) handleCreateGroup(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+
+	var req groupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	g := &store.Group{
+		ID:        uuid.New().String(),
+		OrgID:     identity.OrgID,
+		CreatedBy: identity.UserID,
+		CreatedAt: time.Now(),
+	}
+	req.apply(g)
+	if g.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if taken, err := s.groupNameTaken(r, g); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create group")
+		return
+	} else if taken {
+		writeError(w, http.StatusConflict, "a group with this name already exists")
+		return
+	}
+
+	if err := s.store.CreateGroup(r.Context(), g); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create group")
+		return
+	}
+	s.logGroupEvent(r, "group.created", g, map[string]string{"name": g.Name})
+	writeJSON(w, http.StatusCreated, g)
+}
+
+// handleGetGroup returns a group with its members and grants.
+func (s *Server) handleGetGroup(w http.ResponseWriter, r *http.Request) {
+	g := s.getOrgGroup(w, r)
+	if g == nil {
+		return
+	}
+	members, err := s.store.ListGroupMembers(r.Context(), g.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list group members")
+		return
+	}
+	grants, err := s.store.ListGroupGrants(r.Context(), g.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list group grants")
+		return
+	}
+	if members == nil {
+		members = []store.GroupMember{}
+	}
+	if grants == nil {
+		grants = []store.GroupGrant{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"group": g, "members": members, "grants": grants})
+}
+
+func (s *Server) handleUpdateGroup(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	g := s.getOrgGroup(w, r)
+	if g == nil {
+		return
+	}
+
+	var req groupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	req.apply(g)
+	if g.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if taken, err := s.groupNameTaken(r, g); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update group")
+		return
+	} else if taken {
+		writeError(w, http.StatusConflict, "a group with this name already exists")
+		return
+	}
+
+	if err := s.store.UpdateGroup(r.Context(), g); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update group")
+		return
+	}
+	s.logGroupEvent(r, "group.updated", g, map[string]string{"name": g.Name})
+	writeJSON(w, http.StatusOK, g)
+}
+
+// handleDeleteGroup removes a group along with its memberships and grants.
+func (s *Server) handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	g := s.getOrgGroup(w, r)
+	if g == nil {
+		return
+	}
+	if err := s.store.DeleteGroup(r.Context(), g.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete group")
+		return
+	}
+	s.logGroupEvent(r, "group.deleted", g, map[string]string{"name": g.Name})
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (s *Server) handleAddGroupMember(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+	g := s.getOrgGroup(w, r)
+	if g == nil {
+		return
+	}
+
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	user, err := s.store.GetUserByID(r.Context(), req.UserID)
+	if err != nil || user == nil || user.OrgID != g.OrgID || user.DeletedAt != nil {
+		writeError(w, http.StatusBadRequest, "user not found")
+		return
+	}
+
+	m := &store.GroupMember{
+		GroupID:   g.ID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		AddedBy:   identity.UserID,
+		CreatedAt: time.Now(),
+	}
+	if err := s.store.AddGroupMember(r.Context(), m); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to add group member")
+		return
+	}
+	s.logGroupEvent(r, "group.member_added", g, map[string]string{"target_user_id": user.ID, "username": user.Username})
+	writeJSON(w, http.StatusCreated, m)
+}
+
+func (s *Server) handleRemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+	g := s.getOrgGroup(w, r)
+	if g == nil {
+		return
+	}
+	userID := chi.URLParam(r, "userID")
+	if err := s.store.RemoveGroupMember(r.Context(), g.ID, userID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to remove group member")
+		return
+	}
+	s.logGroupEvent(r, "group.member_removed", g, map[string]string{"target_user_id": userID})
+	writeJSON(w, http.StatusOK, map[string]string{"status": "removed"})
+}
+
+// handleCreateGroupGrant grants a group one agent ({"agent_id"}) or every
+// agent whose tags include a key/value pair ({"tag_key", "tag_value"}).
+func (s *Server) handleCreateGroupGrant(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+	g := s.getOrgGroup(w, r)
+	if g == nil {
+		return
+	}
+
+	var req struct {
+		AgentID  string `json:"agent_id"`
+		TagKey   string `json:"tag_key"`
+		TagValue string `json:"tag_value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if (req.AgentID == "") == (req.TagKey == "") {
+		writeError(w, http.StatusBadRequest, "exactly one of agent_id or tag_key is required")
+		return
+	}
+	if req.AgentID != "" {
+		if req.TagValue != "" {
+			writeError(w, http.StatusBadRequest, "tag_value requires tag_key")
+			return
+		}
+		if agent, err := s.store.GetAgent(r.Context(), req.AgentID); err != nil || agent == nil || agent.OrgID != g.OrgID {
+			writeError(w, http.StatusBadRequest, "agent not found")
+			return
+		}
+	}
+
+	grants, err := s.store.ListGroupGrants(r.Context(), g.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create group grant")
+		return
+	}
+	for _, existing := range grants {
+		if existing.AgentID == req.AgentID && existing.TagKey == req.TagKey && existing.TagValue == req.TagValue {
+			writeError(w, http.StatusConflict, "the group already has this grant")
+			return
+		}
+	}
+
+	grant := &store.GroupGrant{
+		ID:        uuid.New().String(),
+		GroupID:   g.ID,
+		AgentID:   req.AgentID,
+		TagKey:    req.TagKey,
+		TagValue:  req.TagValue,
+		CreatedBy: identity.UserID,
+		CreatedAt: time.Now(),
+	}
+	if err := s.store.CreateGroupGrant(r.Context(), grant); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create group grant")
+		return
+	}
+	s.logGroupEvent(r, "group.grant_added", g, grantDetail(grant))
+	writeJSON(w, http.StatusCreated, grant)
+}
+
+func (s *Server) handleDeleteGroupGrant(w http.ResponseWriter, r *http.Request) {
+	g := s.getOrgGroup(w, r)
+	if g == nil {
+		return
+	}
+	grants, err := s.store.ListGroupGrants(r.Context(), g.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete group grant")
+		return
+	}
+	grantID := chi.URLParam(r, "grantID")
+	for _, grant := range grants {
+		if grant.ID != grantID {
+			continue
+		}
+		if err := s.store.DeleteGroupGrant(r.Context(), grant.ID); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to delete group grant")
+			return
+		}
+		s.logGroupEvent(r, "group.grant_removed", g, grantDetail(&grant))
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+		return
+	}
+	writeError(w, http.StatusNotFound, "group grant not found")
+}
+
+func grantDetail(grant *store.GroupGrant) map[string]string {
+	detail := map[string]string{"grant_id": grant.ID}
+	if grant.AgentID != "" {
+		detail["agent_id"] = grant.AgentID
+	} else {
+		detail["tag_key"] = grant.TagKey
+		detail["tag_value"] = grant.TagValue
+	}
+	return detail
+}
+
+func (s *Server) logGroupEvent(r *http.Request, action string, g *store.Group, detail map[string]string) {
+	identity := getIdentityFromContext(r.Context())
+	detail["group_id"] = g.ID
+	raw, _ := json.Marshal(detail)
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: action, UserID: identity.UserID,
+		Detail:    raw,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", action, "error", err)
+	}
+}
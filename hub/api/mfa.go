@@ -0,0 +1,304 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/amurg-ai/amurg/hub/auth"
+	"github.com/amurg-ai/amurg/hub/store"
+)
+
+// --- Two-factor authentication handlers ---
+
+// handleLoginMFA handles POST /api/auth/login/mfa, the second login step for
+// accounts with a second factor. Wrong codes count toward the same account
+// lockout as wrong passwords.
+func (s *Server) handleLoginMFA(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	var req struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	ch, err := s.mfa.ParseMFAChallenge(req.MFAToken)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "login expired; sign in again")
+		return
+	}
+	if s.loginLockout.isLocked(ch.Username) {
+		writeError(w, http.StatusTooManyRequests, "account temporarily locked due to too many failed attempts")
+		return
+	}
+
+	token, usedRecovery, err := s.mfa.CompleteMFALogin(r.Context(), ch, req.Code)
+	if err != nil {
+		s.loginLockout.recordFailure(ch.Username)
+		if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+			ID: uuid.New().String(), OrgID: "default", Action: "mfa.failed", UserID: ch.UserID,
+			Detail: json.RawMessage(fmt.Sprintf(`{"username":%q}`, ch.Username)), CreatedAt: time.Now(),
+		}); err != nil {
+			s.logger.Warn("failed to log audit event", "action", "mfa.failed", "error", err)
+		}
+		writeError(w, http.StatusUnauthorized, "invalid two-factor code")
+		return
+	}
+	s.loginLockout.recordSuccess(ch.Username)
+
+	method := "totp"
+	if usedRecovery {
+		method = "recovery_code"
+	}
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: "default", Action: "login.success", UserID: ch.UserID,
+		Detail: json.RawMessage(fmt.Sprintf(`{"mfa":%q}`, method)), CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "login.success", "error", err)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"token": token})
+}
+
+// mfaSession returns the caller's identity, refusing personal access tokens:
+// managing the second factor needs an interactive sign-in.
+func mfaSession(w http.ResponseWriter, r *http.Request) (*auth.Identity, bool) {
+	identity := getIdentityFromContext(r.Context())
+	if identity.TokenID != "" {
+		writeError(w, http.StatusForbidden, "personal access tokens cannot manage two-factor authentication")
+		return nil, false
+	}
+	return identity, true
+}
+
+// handleGetMFA handles GET /api/me/mfa.
+func (s *Server) handleGetMFA(w http.ResponseWriter, r *http.Request) {
+	identity, ok := mfaSession(w, r)
+	if !ok {
+		return
+	}
+	m, err := s.store.GetUserMFA(r.Context(), identity.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get two-factor status")
+		return
+	}
+	resp := map[string]any{
+		"enabled":                  false,
+		"recovery_codes_remaining": 0,
+		"required":                 auth.MFAEnrollmentRequired(r.Context(), s.store, identity),
+	}
+	if m != nil && m.Enabled {
+		resp["enabled"] = true
+		resp["enabled_at"] = m.EnabledAt
+		resp["recovery_codes_remaining"] = auth.RecoveryCodesRemaining(m)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleEnrollMFA handles POST /api/me/mfa/enroll. It returns a new secret
+// to add to an authenticator app; 2FA is only enabled once a code from it is
+// confirmed.
+func (s *Server) handleEnrollMFA(w http.ResponseWriter, r *http.Request) {
+	identity, ok := mfaSession(w, r)
+	if !ok {
+		return
+	}
+	secret, uri, err := s.mfa.BeginTOTPEnrollment(r.Context(), identity.UserID, identity.Username)
+	if errors.Is(err, auth.ErrMFAAlreadyEnabled) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start enrollment")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"secret": secret, "otpauth_uri": uri})
+}
+
+// handleConfirmMFA handles POST /api/me/mfa/confirm. The response carries the
+// recovery codes, shown only once, and a new session token signed in with
+// the second factor.
+func (s *Server) handleConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity, ok := mfaSession(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	codes, token, err := s.mfa.ConfirmTOTPEnrollment(r.Context(), identity.UserID, req.Code)
+	switch {
+	case errors.Is(err, auth.ErrMFAInvalidCode):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, auth.ErrMFANotEnrolled), errors.Is(err, auth.ErrMFAAlreadyEnabled):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "failed to confirm enrollment")
+		return
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "mfa.enrolled", UserID: identity.UserID,
+		Detail: json.RawMessage(`{"method":"totp"}`), CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "mfa.enrolled", "error", err)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"recovery_codes": codes, "token": token})
+}
+
+// handleDisableMFA handles POST /api/me/mfa/disable. Admins cannot turn 2FA
+// off while the org requires it.
+func (s *Server) handleDisableMFA(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity, ok := mfaSession(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if identity.Role == "admin" {
+		if org, _ := s.store.GetOrganization(r.Context(), identity.OrgID); org != nil && org.RequireAdminMFA {
+			writeError(w, http.StatusConflict, "the organization requires two-factor authentication for admins")
+			return
+		}
+	}
+
+	err := s.mfa.DisableTOTP(r.Context(), identity.UserID, req.Code)
+	switch {
+	case errors.Is(err, auth.ErrMFAInvalidCode):
+		s.loginLockout.recordFailure(identity.Username)
+		if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+			ID: uuid.New().String(), OrgID: identity.OrgID, Action: "mfa.failed", UserID: identity.UserID,
+			Detail: json.RawMessage(`{"operation":"disable"}`), CreatedAt: time.Now(),
+		}); err != nil {
+			s.logger.Warn("failed to log audit event", "action", "mfa.failed", "error", err)
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, auth.ErrMFANotEnrolled):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "failed to disable two-factor authentication")
+		return
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "mfa.disabled", UserID: identity.UserID,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "mfa.disabled", "error", err)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "disabled"})
+}
+
+// handleResetUserMFA handles DELETE /api/users/{userID}/mfa, removing the
+// second factor of a user who lost their authenticator and recovery codes.
+func (s *Server) handleResetUserMFA(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+	userID := chi.URLParam(r, "userID")
+	user, err := s.store.GetUserByID(r.Context(), userID)
+	if err != nil || user == nil || user.OrgID != identity.OrgID {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	m, err := s.store.GetUserMFA(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to reset two-factor authentication")
+		return
+	}
+	if m == nil {
+		writeError(w, http.StatusNotFound, "two-factor authentication is not enrolled")
+		return
+	}
+	if err := s.store.DeleteUserMFA(r.Context(), userID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to reset two-factor authentication")
+		return
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "mfa.reset", UserID: identity.UserID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"target_user_id":%q}`, userID)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "mfa.reset", "error", err)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "reset"})
+}
+
+// orgSettings is the API view of an organization's security settings.
+type orgSettings struct {
+	RequireAdminMFA bool `json:"require_admin_mfa"`
+}
+
+// handleGetOrgSettings handles GET /api/admin/org/settings.
+func (s *Server) handleGetOrgSettings(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+	org, err := s.store.GetOrganization(r.Context(), identity.OrgID)
+	if err != nil || org == nil {
+		writeError(w, http.StatusNotFound, "organization not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, orgSettings{RequireAdminMFA: org.RequireAdminMFA})
+}
+
+// handleUpdateOrgSettings handles PUT /api/admin/org/settings. An admin can
+// only require 2FA for admins after enrolling themselves, so the setting
+// cannot lock its author out.
+func (s *Server) handleUpdateOrgSettings(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+	var req orgSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	org, err := s.store.GetOrganization(r.Context(), identity.OrgID)
+	if err != nil || org == nil {
+		writeError(w, http.StatusNotFound, "organization not found")
+		return
+	}
+	if req.RequireAdminMFA && !identity.MFA {
+		writeError(w, http.StatusConflict, "sign in with two-factor authentication before requiring it for admins")
+		return
+	}
+
+	org.RequireAdminMFA = req.RequireAdminMFA
+	if err := s.store.UpdateOrganization(r.Context(), org); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update settings")
+		return
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "org.settings_updated", UserID: identity.UserID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"require_admin_mfa":%t}`, req.RequireAdminMFA)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "org.settings_updated", "error", err)
+	}
+
+	writeJSON(w, http.StatusOK, orgSettings{RequireAdminMFA: org.RequireAdminMFA})
+}
@@ -48,9 +48,18 @@ func getIdentityFromContext(ctx context.Context) *auth.Identity {
 	return identity
 }
 
+// externalAuth reports whether users are managed by an external identity
+// provider and must be provisioned locally on first sight.
+func (s *Server) externalAuth() bool {
+	return s.authProviderName == "clerk" || s.authProviderName == "oidc"
+}
+
 // ensureUserMiddleware auto-provisions a user and organization in the local
-// database when an externally-authenticated user is seen for the first time.
-// This is only active when the auth provider is "clerk".
+// database when an externally-authenticated user is seen for the first time,
+// and refuses users an admin has disabled or deleted locally. The stored role
+// follows the provider's on every interactive sign-in, since personal access
+// tokens take their role from it.
+// This is only active when the auth provider is "clerk" or "oidc".
 func (s *Server) ensureUserMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		identity := getIdentityFromContext(r.Context())
@@ -70,8 +79,18 @@ func (s *Server) ensureUserMiddleware(next http.Handler) http.Handler {
 
 		ctx := r.Context()
 
-		// Check if user already exists by their external ID (Clerk sub).
+		// Check if user already exists by their external ID (provider sub).
 		existing, _ := s.store.GetUserByExternalID(ctx, identity.UserID)
+		if existing != nil && (existing.Disabled || existing.DeletedAt != nil) {
+			writeError(w, http.StatusForbidden, "account is disabled")
+			return
+		}
+		if existing != nil && identity.TokenID == "" && existing.Role != identity.Role {
+			existing.Role = identity.Role
+			if err := s.store.UpdateUser(ctx, existing); err != nil {
+				s.logger.Warn("failed to sync user role", "user_id", existing.ID, "error", err)
+			}
+		}
 		if existing == nil {
 			orgID := identity.OrgID
 			org, _ := s.store.GetOrganization(ctx, orgID)
@@ -86,7 +105,7 @@ func (s *Server) ensureUserMiddleware(next http.Handler) http.Handler {
 
 			// Create the user.
 			_ = s.store.CreateUser(ctx, &store.User{
-				// Keep Clerk-backed identities stable across HTTP and WebSocket
+				// Keep externally-backed identities stable across HTTP and WebSocket
 				// code paths by using the provider subject as the local user ID.
 				ID:         identity.UserID,
 				OrgID:      orgID,
@@ -102,6 +121,7 @@ func (s *Server) ensureUserMiddleware(next http.Handler) http.Handler {
 }
 
 // adminOnlyMiddleware rejects requests from non-admin users with 403 Forbidden.
+// Personal access tokens additionally need the admin scope.
 // Must be placed after authMiddleware so the identity is available in context.
 func adminOnlyMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -110,6 +130,47 @@ func adminOnlyMiddleware(next http.Handler) http.Handler {
 			writeError(w, http.StatusForbidden, "admin access required")
 			return
 		}
+		if !identity.HasScope(auth.ScopeAdmin) {
+			writeError(w, http.StatusForbidden, "token lacks the admin scope")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tokenScopeMiddleware limits personal access tokens on the user API: reads
+// need the sessions:read scope and everything else needs messages:send.
+// Must be placed after authMiddleware so the identity is available in context.
+func tokenScopeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity := getIdentityFromContext(r.Context())
+		scope := auth.ScopeMessagesSend
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			scope = auth.ScopeSessionsRead
+		}
+		if identity != nil && !identity.HasScope(scope) {
+			writeError(w, http.StatusForbidden, "token lacks the "+scope+" scope")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminMFAMiddleware keeps admins who did not sign in with a second factor
+// out of everything but their own profile and 2FA enrollment when the org
+// requires two-factor authentication for admins.
+// Must be placed after authMiddleware so the identity is available in context.
+func (s *Server) adminMFAMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.mfa == nil || r.URL.Path == "/api/me" || strings.HasPrefix(r.URL.Path, "/api/me/mfa") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		identity := getIdentityFromContext(r.Context())
+		if identity != nil && auth.MFAEnrollmentRequired(r.Context(), s.store, identity) {
+			writeError(w, http.StatusForbidden, "two-factor authentication is required for admins; enroll at /api/me/mfa")
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -196,7 +257,7 @@ func makeCORSMiddleware(allowedOrigins []string) func(http.Handler) http.Handler
 				w.Header().Set("Vary", "Origin")
 			}
 
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 			if r.Method == "OPTIONS" {
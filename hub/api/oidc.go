@@ -0,0 +1,113 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/amurg-ai/amurg/hub/auth"
+	"github.com/amurg-ai/amurg/hub/store"
+)
+
+const (
+	// oidcStateCookie binds a login to the browser that started it. It holds
+	// a hash of the login's state, so the state itself never sits in a cookie.
+	oidcStateCookie = "amurg_oidc_state"
+	oidcStatePath   = "/api/auth/oidc"
+	oidcStateMaxAge = 10 * time.Minute
+)
+
+var errOIDCStateMismatch = errors.New("login was not started in this browser")
+
+// handleOIDCLogin handles GET /api/auth/oidc/login by sending the browser to
+// the identity provider.
+func (s *Server) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	target, state, err := s.redirectLogin.LoginURL()
+	if err != nil {
+		s.logger.Warn("start OIDC login failed", "error", err)
+		writeError(w, http.StatusServiceUnavailable, "could not start sign-in")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    oidcStateHash(state),
+		Path:     oidcStatePath,
+		MaxAge:   int(oidcStateMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(s.baseURL, "https://"),
+		// Lax: the identity provider's redirect back is a top-level GET.
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func oidcStateHash(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// handleOIDCCallback handles GET /api/auth/oidc/callback, where the identity
+// provider sends the browser back. The callback must come to the browser that
+// started the login, or a callback URL from someone else's login could sign
+// the victim into that account. The token is handed to the UI in the URL
+// fragment, which browsers never send to a server.
+func (s *Server) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		s.logger.Info("OIDC sign-in refused by identity provider", "error", e, "description", q.Get("error_description"))
+		s.redirectToLogin(w, r, url.Values{"error": {"sign-in was cancelled or refused"}})
+		return
+	}
+
+	cookie, cookieErr := r.Cookie(oidcStateCookie)
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: oidcStatePath, MaxAge: -1, HttpOnly: true})
+
+	var token string
+	var identity *auth.Identity
+	var err error
+	if cookieErr != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(oidcStateHash(q.Get("state")))) != 1 {
+		err = errOIDCStateMismatch
+	} else {
+		token, identity, err = s.redirectLogin.CompleteLogin(r.Context(), q.Get("state"), q.Get("code"))
+	}
+	if err != nil {
+		s.logger.Warn("complete OIDC login failed", "error", err)
+		if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+			ID: uuid.New().String(), OrgID: "default", Action: "login.failed",
+			Detail: json.RawMessage(fmt.Sprintf(`{"provider":"oidc","error":%q}`, err.Error())), CreatedAt: time.Now(),
+		}); err != nil {
+			s.logger.Warn("failed to log audit event", "action", "login.failed", "error", err)
+		}
+		s.redirectToLogin(w, r, url.Values{"error": {"sign-in failed"}})
+		return
+	}
+
+	orgID := identity.OrgID
+	if orgID == "" {
+		orgID = "default"
+	}
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: orgID, Action: "login.success", UserID: identity.UserID,
+		Detail: json.RawMessage(`{"provider":"oidc"}`), CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "login.success", "error", err)
+	}
+
+	s.redirectToLogin(w, r, url.Values{"token": {token}})
+}
+
+// redirectToLogin sends the browser to the UI's login page with values in
+// the fragment.
+func (s *Server) redirectToLogin(w http.ResponseWriter, r *http.Request, values url.Values) {
+	target := strings.TrimSuffix(s.baseURL, "/") + "/login#" + values.Encode()
+	http.Redirect(w, r, target, http.StatusFound)
+}
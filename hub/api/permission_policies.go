@@ -0,0 +1,171 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/amurg-ai/amurg/hub/router"
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// --- Permission policy handlers (admin only) ---
+
+type permissionPolicyRequest struct {
+	AgentID     *string `json:"agent_id"`
+	UserID      *string `json:"user_id"`
+	Tool        *string `json:"tool"`
+	Pattern     *string `json:"pattern"`
+	Exact       *bool   `json:"exact"`
+	Effect      *string `json:"effect"`
+	Description *string `json:"description"`
+}
+
+// apply copies the fields set in req onto p.
+func (req *permissionPolicyRequest) apply(p *store.PermissionPolicy) {
+	if req.AgentID != nil {
+		p.AgentID = *req.AgentID
+	}
+	if req.UserID != nil {
+		p.UserID = *req.UserID
+	}
+	if req.Tool != nil {
+		p.Tool = *req.Tool
+	}
+	if req.Pattern != nil {
+		p.Pattern = *req.Pattern
+	}
+	if req.Exact != nil {
+		p.Exact = *req.Exact
+	}
+	if req.Effect != nil {
+		p.Effect = *req.Effect
+	}
+	if req.Description != nil {
+		p.Description = *req.Description
+	}
+}
+
+func validatePermissionPolicy(p *store.PermissionPolicy) string {
+	if p.Effect != router.PolicyAllow && p.Effect != router.PolicyDeny {
+		return "effect must be allow or deny"
+	}
+	if (p.Tool == "" || p.Tool == "*") && p.Pattern == "" && p.Effect == router.PolicyAllow {
+		return "an allow policy must name a tool or a pattern"
+	}
+	return ""
+}
+
+// getOrgPermissionPolicy loads a policy by URL param and writes 404 unless it belongs to the caller's org.
+func (s *Server) getOrgPermissionPolicy(w http.ResponseWriter, r *http.Request) *store.PermissionPolicy {
+	identity := getIdentityFromContext(r.Context())
+	p, err := s.store.GetPermissionPolicy(r.Context(), chi.URLParam(r, "policyID"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get permission policy")
+		return nil
+	}
+	if p == nil || p.OrgID != identity.OrgID {
+		writeError(w, http.StatusNotFound, "permission policy not found")
+		return nil
+	}
+	return p
+}
+
+func (s *Server) handleListPermissionPolicies(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+	policies, err := s.store.ListPermissionPolicies(r.Context(), identity.OrgID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list permission policies")
+		return
+	}
+	if policies == nil {
+		policies = []store.PermissionPolicy{}
+	}
+	writeJSON(w, http.StatusOK, policies)
+}
+
+func (s *Server) handleCreatePermissionPolicy(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+
+	var req permissionPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	now := time.Now()
+	p := &store.PermissionPolicy{
+		ID:        uuid.New().String(),
+		OrgID:     identity.OrgID,
+		CreatedBy: identity.UserID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	req.apply(p)
+	if msg := validatePermissionPolicy(p); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if err := s.store.CreatePermissionPolicy(r.Context(), p); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create permission policy")
+		return
+	}
+	s.logPermissionPolicyEvent(r, "permission_policy.created", p)
+	writeJSON(w, http.StatusCreated, p)
+}
+
+func (s *Server) handleUpdatePermissionPolicy(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	p := s.getOrgPermissionPolicy(w, r)
+	if p == nil {
+		return
+	}
+
+	var req permissionPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	req.apply(p)
+	if msg := validatePermissionPolicy(p); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	p.UpdatedAt = time.Now()
+
+	if err := s.store.UpdatePermissionPolicy(r.Context(), p); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update permission policy")
+		return
+	}
+	s.logPermissionPolicyEvent(r, "permission_policy.updated", p)
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) handleDeletePermissionPolicy(w http.ResponseWriter, r *http.Request) {
+	p := s.getOrgPermissionPolicy(w, r)
+	if p == nil {
+		return
+	}
+	if err := s.store.DeletePermissionPolicy(r.Context(), p.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete permission policy")
+		return
+	}
+	s.logPermissionPolicyEvent(r, "permission_policy.deleted", p)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (s *Server) logPermissionPolicyEvent(r *http.Request, action string, p *store.PermissionPolicy) {
+	identity := getIdentityFromContext(r.Context())
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: action, UserID: identity.UserID, AgentID: p.AgentID,
+		Detail: json.RawMessage(fmt.Sprintf(`{"policy_id":%q,"tool":%q,"pattern":%q,"effect":%q}`,
+			p.ID, p.Tool, p.Pattern, p.Effect)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", action, "error", err)
+	}
+}
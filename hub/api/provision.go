@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/amurg-ai/amurg/hub/router"
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/amurg-ai/amurg/pkg/protocol"
+)
+
+// handleCreateRuntimeAgent handles POST /api/admin/runtimes/{runtimeID}/agents.
+// The body is a full agent definition as it appears in the runtime's
+// runtime-config.json. The runtime validates it, checks its profile against
+// runtime.remote_profiles and adds it to its config file.
+func (s *Server) handleCreateRuntimeAgent(w http.ResponseWriter, r *http.Request) {
+	rt, ok := s.provisionRuntime(w, r)
+	if !ok {
+		return
+	}
+	def, ok := s.decodeAgentDefinition(w, r, "")
+	if !ok {
+		return
+	}
+	if existing, _ := s.store.GetAgent(r.Context(), def.ID); existing != nil {
+		writeError(w, http.StatusConflict, "an agent with that id already exists")
+		return
+	}
+	s.provisionAgent(w, r, rt, protocol.ProvisionCreate, def.ID, def.raw)
+}
+
+// handleUpdateRuntimeAgent handles PUT /api/admin/runtimes/{runtimeID}/agents/{agentID}.
+// The body replaces the agent's whole definition; open sessions keep running
+// with the old one. Stored config overrides are re-applied on top.
+func (s *Server) handleUpdateRuntimeAgent(w http.ResponseWriter, r *http.Request) {
+	rt, ok := s.provisionRuntime(w, r)
+	if !ok {
+		return
+	}
+	agentID := chi.URLParam(r, "agentID")
+	if !s.runtimeHasAgent(r.Context(), rt, agentID) {
+		writeError(w, http.StatusNotFound, "agent not found")
+		return
+	}
+	def, ok := s.decodeAgentDefinition(w, r, agentID)
+	if !ok {
+		return
+	}
+	s.provisionAgent(w, r, rt, protocol.ProvisionUpdate, agentID, def.raw)
+}
+
+// handleDeleteRuntimeAgent handles DELETE /api/admin/runtimes/{runtimeID}/agents/{agentID}.
+// The runtime refuses while the agent has open sessions.
+func (s *Server) handleDeleteRuntimeAgent(w http.ResponseWriter, r *http.Request) {
+	rt, ok := s.provisionRuntime(w, r)
+	if !ok {
+		return
+	}
+	agentID := chi.URLParam(r, "agentID")
+	if !s.runtimeHasAgent(r.Context(), rt, agentID) {
+		writeError(w, http.StatusNotFound, "agent not found")
+		return
+	}
+	s.provisionAgent(w, r, rt, protocol.ProvisionDelete, agentID, nil)
+}
+
+// provisionRuntime loads the runtime named in the URL, which must belong to
+// the caller's org.
+func (s *Server) provisionRuntime(w http.ResponseWriter, r *http.Request) (*store.Runtime, bool) {
+	identity := getIdentityFromContext(r.Context())
+	rt, err := s.store.GetRuntime(r.Context(), chi.URLParam(r, "runtimeID"))
+	if err != nil || rt == nil || rt.OrgID != identity.OrgID {
+		writeError(w, http.StatusNotFound, "runtime not found")
+		return nil, false
+	}
+	return rt, true
+}
+
+func (s *Server) runtimeHasAgent(ctx context.Context, rt *store.Runtime, agentID string) bool {
+	agent, err := s.store.GetAgent(ctx, agentID)
+	return err == nil && agent != nil && agent.RuntimeID == rt.ID
+}
+
+type agentDefinition struct {
+	ID  string
+	raw json.RawMessage
+}
+
+// decodeAgentDefinition reads an agent definition from the request body.
+// Only the id and profile are checked here; the runtime validates the rest.
+// When agentID is set, the definition's id must be empty or match it.
+func (s *Server) decodeAgentDefinition(w http.ResponseWriter, r *http.Request, agentID string) (*agentDefinition, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return nil, false
+	}
+	var head struct {
+		ID      string `json:"id"`
+		Profile string `json:"profile"`
+	}
+	if err := json.Unmarshal(raw, &head); err != nil {
+		writeError(w, http.StatusBadRequest, "agent definition must be a JSON object")
+		return nil, false
+	}
+	if agentID != "" && head.ID != "" && head.ID != agentID {
+		writeError(w, http.StatusBadRequest, "id does not match the agent being updated")
+		return nil, false
+	}
+	if agentID == "" && head.ID == "" {
+		writeError(w, http.StatusBadRequest, "id is required")
+		return nil, false
+	}
+	if head.Profile == "" {
+		writeError(w, http.StatusBadRequest, "profile is required")
+		return nil, false
+	}
+	if head.ID == "" {
+		head.ID = agentID
+	}
+	return &agentDefinition{ID: head.ID, raw: raw}, true
+}
+
+// provisionAgent sends the change to the runtime, waits for its answer and
+// records it in the audit log.
+func (s *Server) provisionAgent(w http.ResponseWriter, r *http.Request, rt *store.Runtime, action, agentID string, cfg json.RawMessage) {
+	identity := getIdentityFromContext(r.Context())
+
+	if err := s.router.ProvisionAgent(r.Context(), rt.ID, action, agentID, cfg); err != nil {
+		var pErr *router.ProvisionError
+		switch {
+		case errors.Is(err, router.ErrRuntimeOffline):
+			writeError(w, http.StatusServiceUnavailable, "agent runtime is offline")
+		case errors.Is(err, router.ErrProvisionTimeout):
+			writeError(w, http.StatusGatewayTimeout, err.Error())
+		case errors.As(err, &pErr):
+			writeError(w, http.StatusConflict, pErr.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to provision agent")
+		}
+		return
+	}
+
+	if action == protocol.ProvisionDelete {
+		if err := s.store.DeleteAgentConfigOverride(r.Context(), agentID); err != nil {
+			s.logger.Warn("failed to delete config override of removed agent", "agent_id", agentID, "error", err)
+		}
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "agent.provision",
+		UserID: identity.UserID, AgentID: agentID, RuntimeID: rt.ID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"action":%q}`, action)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "agent.provision", "error", err)
+	}
+
+	if action == protocol.ProvisionDelete {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+		return
+	}
+	agent, err := s.store.GetAgent(r.Context(), agentID)
+	if err != nil || agent == nil {
+		writeError(w, http.StatusInternalServerError, "agent was provisioned but is not registered yet")
+		return
+	}
+	status := http.StatusOK
+	if action == protocol.ProvisionCreate {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, agent)
+}
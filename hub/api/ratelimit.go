@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/amurg-ai/amurg/hub/metrics"
 )
 
 // tokenBlocklist tracks revoked JWT IDs (jti) for logout support.
@@ -117,6 +119,8 @@ type rateLimiter struct {
 	buckets map[string]*bucket
 	rate    float64 // tokens per second
 	burst   int     // max tokens
+
+	rejections *metrics.Counter // optional; incremented when a request is rejected
 }
 
 type bucket struct {
@@ -164,6 +168,13 @@ func (rl *rateLimiter) allow(key string) bool {
 	return true
 }
 
+// reject records a rejected request.
+func (rl *rateLimiter) reject() {
+	if rl.rejections != nil {
+		rl.rejections.Inc()
+	}
+}
+
 // cleanup removes buckets that haven't been accessed for maxAge.
 func (rl *rateLimiter) cleanup(maxAge time.Duration) {
 	rl.mu.Lock()
@@ -204,6 +215,7 @@ func loginIPRateLimitMiddleware(rl *rateLimiter) func(http.Handler) http.Handler
 				ip = r.RemoteAddr // fallback if no port
 			}
 			if !rl.allow(ip) {
+				rl.reject()
 				w.Header().Set("Retry-After", "1")
 				writeError(w, http.StatusTooManyRequests, "too many login attempts")
 				return
@@ -224,6 +236,7 @@ func rateLimitMiddleware(rl *rateLimiter) func(http.Handler) http.Handler {
 			}
 
 			if !rl.allow(identity.UserID) {
+				rl.reject()
 				w.Header().Set("Retry-After", "1")
 				writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
 				return
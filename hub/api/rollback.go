@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/amurg-ai/amurg/hub/router"
+	"github.com/amurg-ai/amurg/hub/store"
+)
+
+// handleRollbackSession handles POST /api/sessions/{sessionID}/rollback. It
+// restores the session's work dir to its checkpoint from just before the
+// user message at seq, undoing that turn and every later one. The runtime
+// records the rollback in the transcript.
+func (s *Server) handleRollbackSession(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+
+	var req struct {
+		Seq int64 `json:"seq"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Seq < 1 {
+		writeError(w, http.StatusBadRequest, "seq must be a positive message sequence number")
+		return
+	}
+
+	sess, err := s.store.GetSession(r.Context(), chi.URLParam(r, "sessionID"))
+	if err != nil || sess == nil || sess.OrgID != identity.OrgID {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if !s.router.CanCollaborate(r.Context(), sess, identity.UserID) {
+		writeError(w, http.StatusForbidden, "access denied")
+		return
+	}
+	switch sess.State {
+	case "closed":
+		writeError(w, http.StatusConflict, "session is closed")
+		return
+	case "responding":
+		writeError(w, http.StatusConflict, "wait for the current turn to complete")
+		return
+	}
+
+	// Checkpoints are taken when a user message starts a turn.
+	at, err := s.store.GetMessages(r.Context(), sess.ID, req.Seq-1, 1)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get messages")
+		return
+	}
+	if len(at) == 0 || at[0].Seq != req.Seq {
+		writeError(w, http.StatusBadRequest, "no message with that seq")
+		return
+	}
+	if at[0].Direction != "user" || at[0].Channel != "stdin" {
+		writeError(w, http.StatusBadRequest, "seq must be a user message that started a turn")
+		return
+	}
+
+	if err := s.router.RollbackWorkspace(r.Context(), sess, &at[0]); err != nil {
+		var rbErr *router.RollbackError
+		switch {
+		case errors.Is(err, router.ErrRuntimeOffline):
+			writeError(w, http.StatusServiceUnavailable, "agent runtime is offline")
+		case errors.Is(err, router.ErrRollbackTimeout):
+			writeError(w, http.StatusGatewayTimeout, err.Error())
+		case errors.As(err, &rbErr):
+			writeError(w, http.StatusConflict, rbErr.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to roll back workspace")
+		}
+		return
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "session.rollback",
+		UserID: identity.UserID, SessionID: sess.ID, AgentID: sess.AgentID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"seq":%d}`, req.Seq)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "session.rollback", "error", err)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"status": "rolled_back", "seq": req.Seq})
+}
@@ -0,0 +1,354 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amurg-ai/amurg/hub/router"
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/amurg-ai/amurg/pkg/promptprofile"
+	"github.com/amurg-ai/amurg/pkg/protocol"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// --- Headless run handlers ---
+
+// Run statuses.
+const (
+	runRunning   = "running"
+	runSucceeded = "succeeded"
+	runFailed    = "failed"
+)
+
+const (
+	defaultRunWait = 5 * time.Minute
+	maxRunWait     = time.Hour
+	runTimeout     = time.Hour // a run still going after this is marked failed
+	runRetention   = time.Hour // finished runs stay pollable this long
+	maxRunOutput   = 1 << 20   // bytes of agent stdout kept per run
+	runStreamBatch = 500       // messages read per stream catch-up
+)
+
+// agentRun is a prompt sent through POST /api/agents/{agentID}/run. Runs are
+// kept in memory: they can be polled until runRetention after they finish,
+// and do not survive a hub restart (the session and transcript do). The
+// run's session is closed when it finishes unless the caller asked to keep it.
+type agentRun struct {
+	ID         string     `json:"id"`
+	SessionID  string     `json:"session_id"`
+	AgentID    string     `json:"agent_id"`
+	Status     string     `json:"status"`
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	Output     string     `json:"output"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	orgID       string
+	userID      string
+	seq         int64         // seq of the prompt message
+	keepSession bool          // leave the session open for follow-up prompts
+	done        chan struct{} // closed when the run finishes
+}
+
+type runRegistry struct {
+	mu   sync.Mutex
+	runs map[string]*agentRun
+}
+
+func newRunRegistry() *runRegistry {
+	return &runRegistry{runs: make(map[string]*agentRun)}
+}
+
+// add registers a run and drops finished runs past their retention.
+func (reg *runRegistry) add(run *agentRun) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	cutoff := time.Now().Add(-runRetention)
+	for id, r := range reg.runs {
+		if r.FinishedAt != nil && r.FinishedAt.Before(cutoff) {
+			delete(reg.runs, id)
+		}
+	}
+	reg.runs[run.ID] = run
+}
+
+// get returns a copy of the run, safe to read without the lock.
+func (reg *runRegistry) get(id string) (agentRun, bool) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	run, ok := reg.runs[id]
+	if !ok {
+		return agentRun{}, false
+	}
+	return *run, true
+}
+
+// finish records the run's outcome and wakes anyone waiting on it.
+func (reg *runRegistry) finish(run *agentRun, status, output, errMsg string, exitCode *int) {
+	now := time.Now()
+	reg.mu.Lock()
+	run.Status = status
+	run.Output = output
+	run.Error = errMsg
+	run.ExitCode = exitCode
+	run.FinishedAt = &now
+	reg.mu.Unlock()
+	close(run.done)
+}
+
+func (s *Server) handleRunAgent(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+	agentID := chi.URLParam(r, "agentID")
+
+	var req struct {
+		Prompt         string `json:"prompt"`
+		PromptProfile  string `json:"prompt_profile,omitempty"`
+		Wait           *bool  `json:"wait,omitempty"`
+		TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+		KeepSession    bool   `json:"keep_session,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeError(w, http.StatusBadRequest, "prompt is required")
+		return
+	}
+	profile := promptprofile.Normalize(req.PromptProfile)
+	if _, ok := promptprofile.Lookup(profile); !ok {
+		writeError(w, http.StatusBadRequest, "invalid prompt_profile")
+		return
+	}
+	wait := defaultRunWait
+	if req.TimeoutSeconds > 0 {
+		wait = min(time.Duration(req.TimeoutSeconds)*time.Second, maxRunWait)
+	}
+	stream := r.URL.Query().Get("stream") == "true" || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+
+	agent, err := s.store.GetAgent(r.Context(), agentID)
+	if err != nil || agent == nil || agent.OrgID != identity.OrgID {
+		writeError(w, http.StatusNotFound, "agent not found")
+		return
+	}
+	if s.defaultAgentAccess == "none" {
+		hasAccess, err := s.store.HasAgentAccess(r.Context(), identity.UserID, agentID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to check permissions")
+			return
+		}
+		if !hasAccess {
+			writeError(w, http.StatusForbidden, "no access to this agent")
+			return
+		}
+	}
+	if !s.router.RuntimeOnline(agent.RuntimeID) {
+		writeError(w, http.StatusServiceUnavailable, "agent runtime is offline")
+		return
+	}
+	if s.enforcer != nil {
+		if err := s.enforcer.CheckTrialExpiry(r.Context(), identity.OrgID); err != nil {
+			writeError(w, http.StatusPaymentRequired, err.Error())
+			return
+		}
+		if err := s.enforcer.CheckSessionLimit(r.Context(), identity.OrgID); err != nil {
+			writeError(w, http.StatusForbidden, err.Error())
+			return
+		}
+	}
+
+	sess, err := s.router.CreateSession(r.Context(), identity.UserID, agentID, router.CreateSessionOption{PromptProfile: profile})
+	if err != nil {
+		if strings.Contains(err.Error(), "max sessions") {
+			writeError(w, http.StatusTooManyRequests, "maximum sessions per user reached")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to create session")
+		return
+	}
+
+	// Watch before sending so a streamed run sees the very first chunk.
+	var outputCh <-chan struct{}
+	if stream {
+		var stop func()
+		outputCh, stop = s.router.WatchOutput(sess.ID)
+		defer stop()
+	}
+
+	seq, turnDone, err := s.router.SendPrompt(r.Context(), sess, identity.UserID, req.Prompt)
+	if err != nil {
+		if errors.Is(err, router.ErrRuntimeOffline) {
+			writeError(w, http.StatusServiceUnavailable, "agent runtime is offline")
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	run := &agentRun{
+		ID:          uuid.New().String(),
+		SessionID:   sess.ID,
+		AgentID:     agentID,
+		Status:      runRunning,
+		CreatedAt:   time.Now(),
+		orgID:       identity.OrgID,
+		userID:      identity.UserID,
+		seq:         seq,
+		keepSession: req.KeepSession,
+		done:        make(chan struct{}),
+	}
+	s.runs.add(run)
+	go s.awaitRun(run, turnDone)
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "agent.run",
+		UserID: identity.UserID, SessionID: sess.ID, AgentID: agentID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"run_id":%q}`, run.ID)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "agent.run", "error", err)
+	}
+
+	switch {
+	case stream:
+		s.streamRun(w, r, run, outputCh)
+	case req.Wait != nil && !*req.Wait:
+		writeJSON(w, http.StatusAccepted, s.runSnapshot(r.Context(), run.ID))
+	default:
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-run.done:
+			writeJSON(w, http.StatusOK, s.runSnapshot(r.Context(), run.ID))
+		case <-timer.C:
+			writeJSON(w, http.StatusAccepted, s.runSnapshot(r.Context(), run.ID))
+		case <-r.Context().Done():
+		}
+	}
+}
+
+// awaitRun waits for the run's turn to complete, closes the run's session
+// and records the outcome.
+func (s *Server) awaitRun(run *agentRun, turnDone <-chan protocol.TurnCompleted) {
+	ctx := context.Background()
+	timer := time.NewTimer(runTimeout)
+	defer timer.Stop()
+
+	finish := func(status, output, errMsg string, exitCode *int) {
+		// Close before finishing so callers never see a finished run with a
+		// session still open.
+		if !run.keepSession {
+			if err := s.router.CloseSession(ctx, run.SessionID, "run finished"); err != nil {
+				s.logger.Warn("close run session failed", "run_id", run.ID, "session_id", run.SessionID, "error", err)
+			}
+		}
+		s.runs.finish(run, status, output, errMsg, exitCode)
+	}
+
+	select {
+	case tc := <-turnDone:
+		output, err := router.CollectOutput(ctx, s.store, run.SessionID, run.seq, maxRunOutput)
+		if err != nil {
+			s.logger.Warn("collect run output failed", "run_id", run.ID, "error", err)
+		}
+		status, errMsg := runSucceeded, ""
+		if tc.ExitCode != nil && *tc.ExitCode != 0 {
+			status, errMsg = runFailed, fmt.Sprintf("agent exited with code %d", *tc.ExitCode)
+		}
+		finish(status, output, errMsg, tc.ExitCode)
+	case <-timer.C:
+		s.router.CancelTurnWait(run.SessionID, turnDone)
+		output, _ := router.CollectOutput(ctx, s.store, run.SessionID, run.seq, maxRunOutput)
+		finish(runFailed, output, fmt.Sprintf("timed out after %s waiting for the turn to complete", runTimeout), nil)
+	}
+}
+
+// runSnapshot returns the run, filling in the output so far while it is still running.
+func (s *Server) runSnapshot(ctx context.Context, runID string) agentRun {
+	run, _ := s.runs.get(runID)
+	if run.Status == runRunning {
+		run.Output, _ = router.CollectOutput(ctx, s.store, run.SessionID, run.seq, maxRunOutput)
+	}
+	return run
+}
+
+// streamRun writes the run as server-sent events: a "run" event with the run
+// and session IDs, one "agent.output" event per stored output chunk, and a
+// final "turn.completed" event carrying the finished run.
+func (s *Server) streamRun(w http.ResponseWriter, r *http.Request, run *agentRun, outputCh <-chan struct{}) {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, data any) bool {
+		payload, _ := json.Marshal(data)
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	afterSeq := run.seq
+	catchUp := func() bool {
+		for {
+			msgs, err := s.store.GetMessages(r.Context(), run.SessionID, afterSeq, runStreamBatch)
+			if err != nil {
+				return false
+			}
+			for _, m := range msgs {
+				afterSeq = m.Seq
+				if m.Direction != "agent" {
+					continue
+				}
+				if !send(protocol.TypeAgentOutput, protocol.AgentOutput{
+					SessionID: m.SessionID, MessageID: m.ID, Seq: m.Seq, Channel: m.Channel, Content: m.Content,
+				}) {
+					return false
+				}
+			}
+			if len(msgs) < runStreamBatch {
+				return true
+			}
+		}
+	}
+
+	if !send("run", s.runSnapshot(r.Context(), run.ID)) {
+		return
+	}
+	for {
+		select {
+		case <-outputCh:
+			if !catchUp() {
+				return
+			}
+		case <-run.done:
+			if catchUp() {
+				final, _ := s.runs.get(run.ID)
+				send(protocol.TypeTurnCompleted, final)
+			}
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) handleGetRun(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+	run, ok := s.runs.get(chi.URLParam(r, "runID"))
+	if !ok || run.orgID != identity.OrgID || (run.userID != identity.UserID && identity.Role != "admin") {
+		writeError(w, http.StatusNotFound, "run not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.runSnapshot(r.Context(), run.ID))
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amurg-ai/amurg/hub/scheduler"
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/amurg-ai/amurg/pkg/promptprofile"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// --- Schedule handlers ---
+
+type scheduleRequest struct {
+	UserID        *string `json:"user_id"` // admins may create schedules owned by another user
+	AgentID       *string `json:"agent_id"`
+	Name          *string `json:"name"`
+	Cron          *string `json:"cron"`
+	Prompt        *string `json:"prompt"`
+	PromptProfile *string `json:"prompt_profile"`
+	Enabled       *bool   `json:"enabled"`
+}
+
+// apply copies the fields set in req onto sc.
+func (req *scheduleRequest) apply(sc *store.Schedule) {
+	if req.AgentID != nil {
+		sc.AgentID = *req.AgentID
+	}
+	if req.Name != nil {
+		sc.Name = *req.Name
+	}
+	if req.Cron != nil {
+		sc.Cron = strings.TrimSpace(*req.Cron)
+	}
+	if req.Prompt != nil {
+		sc.Prompt = *req.Prompt
+	}
+	if req.PromptProfile != nil {
+		sc.PromptProfile = promptprofile.Normalize(*req.PromptProfile)
+	}
+	if req.Enabled != nil {
+		sc.Enabled = *req.Enabled
+	}
+}
+
+// validateSchedule checks sc and, if it is valid, computes its next run time.
+func (s *Server) validateSchedule(r *http.Request, sc *store.Schedule) string {
+	if strings.TrimSpace(sc.Prompt) == "" {
+		return "prompt is required"
+	}
+	if _, ok := promptprofile.Lookup(sc.PromptProfile); !ok {
+		return "invalid prompt_profile"
+	}
+	agent, err := s.store.GetAgent(r.Context(), sc.AgentID)
+	if err != nil || agent == nil || agent.OrgID != sc.OrgID {
+		return "invalid agent_id"
+	}
+	if s.defaultAgentAccess == "none" {
+		if ok, err := s.store.HasAgentAccess(r.Context(), sc.UserID, sc.AgentID); err != nil || !ok {
+			return "no access to this agent"
+		}
+	}
+	next, err := scheduler.NextRun(sc.Cron, time.Now())
+	if err != nil {
+		return "invalid cron: " + err.Error()
+	}
+	sc.NextRunAt = next
+	return ""
+}
+
+// getVisibleSchedule loads a schedule by URL param and writes 404 unless the
+// caller owns it or is an admin in its org.
+func (s *Server) getVisibleSchedule(w http.ResponseWriter, r *http.Request) *store.Schedule {
+	identity := getIdentityFromContext(r.Context())
+	sc, err := s.store.GetSchedule(r.Context(), chi.URLParam(r, "scheduleID"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get schedule")
+		return nil
+	}
+	if sc == nil || sc.OrgID != identity.OrgID || (sc.UserID != identity.UserID && identity.Role != "admin") {
+		writeError(w, http.StatusNotFound, "schedule not found")
+		return nil
+	}
+	return sc
+}
+
+func (s *Server) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+	schedules, err := s.store.ListSchedules(r.Context(), identity.OrgID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list schedules")
+		return
+	}
+	visible := []store.Schedule{}
+	for _, sc := range schedules {
+		if sc.UserID == identity.UserID || identity.Role == "admin" {
+			visible = append(visible, sc)
+		}
+	}
+	writeJSON(w, http.StatusOK, visible)
+}
+
+func (s *Server) handleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	if sc := s.getVisibleSchedule(w, r); sc != nil {
+		writeJSON(w, http.StatusOK, sc)
+	}
+}
+
+func (s *Server) handleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+
+	var req scheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	now := time.Now()
+	sc := &store.Schedule{
+		ID:            uuid.New().String(),
+		OrgID:         identity.OrgID,
+		UserID:        identity.UserID,
+		PromptProfile: promptprofile.Normalize(""),
+		Enabled:       true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if req.UserID != nil && *req.UserID != identity.UserID {
+		if identity.Role != "admin" {
+			writeError(w, http.StatusForbidden, "only admins may create schedules for other users")
+			return
+		}
+		owner, err := s.store.GetUserByID(r.Context(), *req.UserID)
+		if err != nil || owner == nil || owner.OrgID != identity.OrgID {
+			writeError(w, http.StatusBadRequest, "invalid user_id")
+			return
+		}
+		sc.UserID = owner.ID
+	}
+	req.apply(sc)
+	if msg := s.validateSchedule(r, sc); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if err := s.store.CreateSchedule(r.Context(), sc); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create schedule")
+		return
+	}
+	s.logScheduleEvent(r, "schedule.created", sc)
+	writeJSON(w, http.StatusCreated, sc)
+}
+
+func (s *Server) handleUpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	sc := s.getVisibleSchedule(w, r)
+	if sc == nil {
+		return
+	}
+
+	var req scheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	req.UserID = nil // ownership does not change
+	req.apply(sc)
+	if msg := s.validateSchedule(r, sc); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	sc.UpdatedAt = time.Now()
+
+	if err := s.store.UpdateSchedule(r.Context(), sc); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update schedule")
+		return
+	}
+	s.logScheduleEvent(r, "schedule.updated", sc)
+	writeJSON(w, http.StatusOK, sc)
+}
+
+func (s *Server) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	sc := s.getVisibleSchedule(w, r)
+	if sc == nil {
+		return
+	}
+	if err := s.store.DeleteSchedule(r.Context(), sc.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete schedule")
+		return
+	}
+	s.logScheduleEvent(r, "schedule.deleted", sc)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (s *Server) handleListScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	sc := s.getVisibleSchedule(w, r)
+	if sc == nil {
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 500 {
+			limit = n
+		}
+	}
+	runs, err := s.store.ListScheduleRuns(r.Context(), sc.ID, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list schedule runs")
+		return
+	}
+	if runs == nil {
+		runs = []store.ScheduleRun{}
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func (s *Server) logScheduleEvent(r *http.Request, action string, sc *store.Schedule) {
+	identity := getIdentityFromContext(r.Context())
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: action, UserID: identity.UserID, AgentID: sc.AgentID,
+		Detail: json.RawMessage(fmt.Sprintf(`{"schedule_id":%q,"cron":%q,"owner":%q,"enabled":%t}`,
+			sc.ID, sc.Cron, sc.UserID, sc.Enabled)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", action, "error", err)
+	}
+}
@@ -640,6 +640,21 @@ func TestAgentConfig_EmptyBodyRejected(t *testing.T) {
 	}
 }
 
+func TestAgentConfig_NegativeCgroupLimitRejected(t *testing.T) {
+	env := setupSecurityTest(t)
+
+	body := []byte(`{"limits":{"cpu_quota":1,"memory_max_bytes":-1}}`)
+	req := httptest.NewRequest(http.MethodPut, "/api/admin/agents/"+env.agentID+"/config", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+env.adminToken)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	env.srv.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for negative memory_max_bytes, got %d; body: %s", w.Code, w.Body.String())
+	}
+}
+
 // --- Native resume handles should be accepted directly ---
 
 func TestCreateSession_NativeResumeHandleAccepted(t *testing.T) {
@@ -5,13 +5,16 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -20,6 +23,8 @@ import (
 	"github.com/amurg-ai/amurg/hub/auth"
 	"github.com/amurg-ai/amurg/hub/billing"
 	"github.com/amurg-ai/amurg/hub/config"
+	"github.com/amurg-ai/amurg/hub/metrics"
+	"github.com/amurg-ai/amurg/hub/permlink"
 	"github.com/amurg-ai/amurg/hub/router"
 	"github.com/amurg-ai/amurg/hub/store"
 	"github.com/amurg-ai/amurg/pkg/promptprofile"
@@ -34,9 +39,11 @@ import (
 type ServerOptions struct {
 	Billing           billing.Service
 	Enforcer          billing.Enforcer
-	AuthProviderName  string // "builtin" or "clerk"
+	AuthProviderName  string // "builtin", "clerk" or "oidc"
 	StripePriceSingle string
 	StripePriceTeam   string
+	Metrics           *metrics.Hub     // nil creates a private metric set
+	PermissionLinks   *permlink.Signer // nil creates a private signer
 }
 
 // Server is the HTTP API server.
@@ -44,28 +51,35 @@ type Server struct {
 	store              store.Store
 	authProvider       auth.Provider
 	loginProvider      auth.LoginProvider
+	redirectLogin      auth.RedirectLoginProvider // nil unless the provider signs in through an external issuer
+	mfa                auth.MFAProvider           // nil unless the provider supports TOTP second factors
 	runtimeAuth        auth.RuntimeAuthProvider
 	billing            billing.Service  // nil when billing is disabled
 	enforcer           billing.Enforcer // nil when billing is disabled
 	router             *router.Router
+	metrics            *metrics.Hub
+	permLinks          *permlink.Signer // signs one-time approve/deny links
 	logger             *slog.Logger
 	mux                *chi.Mux
 	defaultAgentAccess string // "all" or "none"
 	startTime          time.Time
 	maxBodyBytes       int64
-	authProviderName   string // "builtin" or "clerk"
+	authProviderName   string // "builtin", "clerk" or "oidc"
 	baseURL            string // configured public base URL for generated links
 	fileStoragePath    string // path for uploaded files
 	maxFileBytes       int64  // max file upload size
 	whisperURL         string // upstream Whisper WebSocket URL for /asr proxy
+	metricsToken       string // optional bearer token required to scrape /metrics
 	stripePriceSingle  string // Stripe price ID for single plan
 	stripePriceTeam    string // Stripe price ID for team plan
 	loginRL            *rateLimiter
 	rl                 *rateLimiter
 	deviceCodeRL       *rateLimiter
 	deviceCodePollRL   *rateLimiter
+	permLinkRL         *rateLimiter
 	tokenBlocklist     *tokenBlocklist // revoked JWT IDs
 	loginLockout       *loginLockout   // per-account failed login tracking
+	runs               *runRegistry    // in-flight and recent headless agent runs
 }
 
 // NewServer creates a new API server.
@@ -74,6 +88,14 @@ func NewServer(s store.Store, ap auth.Provider, lp auth.LoginProvider, ra auth.R
 	if authName == "" {
 		authName = ap.Name()
 	}
+	m := opts.Metrics
+	if m == nil {
+		m = metrics.NewHub()
+	}
+	links := opts.PermissionLinks
+	if links == nil {
+		links = permlink.NewSigner(cfg.Server.BaseURL)
+	}
 	srv := &Server{
 		store:              s,
 		authProvider:       ap,
@@ -82,15 +104,19 @@ func NewServer(s store.Store, ap auth.Provider, lp auth.LoginProvider, ra auth.R
 		billing:            opts.Billing,
 		enforcer:           opts.Enforcer,
 		router:             rt,
+		metrics:            m,
+		permLinks:          links,
 		logger:             logger.With("component", "api"),
 		defaultAgentAccess: cfg.Auth.DefaultAgentAccess,
 		startTime:          time.Now(),
+		runs:               newRunRegistry(),
 		maxBodyBytes:       cfg.Server.MaxBodyBytes,
 		authProviderName:   authName,
 		baseURL:            cfg.Server.BaseURL,
 		fileStoragePath:    cfg.Server.FileStoragePath,
 		maxFileBytes:       cfg.Server.MaxFileBytes,
 		whisperURL:         cfg.Server.WhisperURL,
+		metricsToken:       cfg.Server.MetricsToken,
 		stripePriceSingle:  opts.StripePriceSingle,
 		stripePriceTeam:    opts.StripePriceTeam,
 	}
@@ -109,22 +135,48 @@ func NewServer(s store.Store, ap auth.Provider, lp auth.LoginProvider, ra auth.R
 	mux.Get("/healthz", srv.handleHealthz)
 	mux.Get("/readyz", srv.handleReadyz)
 
+	// Prometheus metrics (optionally protected by a static bearer token).
+	mux.Get("/metrics", srv.handleMetrics)
+
 	// Auth config endpoint (unauthenticated)
 	mux.Get("/api/auth/config", srv.handleAuthConfig)
 
 	// Login route only registered when using builtin auth.
 	if lp != nil {
 		srv.loginRL = newRateLimiter(5, 10)
+		srv.loginRL.rejections = m.RateLimitRejections.With("login")
 		mux.With(loginIPRateLimitMiddleware(srv.loginRL)).Post("/api/auth/login", srv.handleLogin)
 		mux.Post("/api/auth/logout", srv.handleLogout)
+		mux.With(loginIPRateLimitMiddleware(srv.loginRL)).Post("/api/auth/password-reset", srv.handleResetPassword)
+		if mp, ok := lp.(auth.MFAProvider); ok {
+			srv.mfa = mp
+			mux.With(loginIPRateLimitMiddleware(srv.loginRL)).Post("/api/auth/login/mfa", srv.handleLoginMFA)
+		}
+	}
+
+	// Sign-in through an external OpenID Connect issuer.
+	if rlp, ok := ap.(auth.RedirectLoginProvider); ok {
+		srv.redirectLogin = rlp
+		srv.loginRL = newRateLimiter(5, 10)
+		srv.loginRL.rejections = m.RateLimitRejections.With("login")
+		mux.With(loginIPRateLimitMiddleware(srv.loginRL)).Get("/api/auth/oidc/login", srv.handleOIDCLogin)
+		mux.Get("/api/auth/oidc/callback", srv.handleOIDCCallback)
 	}
 
 	// Device-code registration (unauthenticated, rate-limited by IP)
 	srv.deviceCodeRL = newRateLimiter(3, 5)
 	srv.deviceCodePollRL = newRateLimiter(6, 10)
+	srv.deviceCodeRL.rejections = m.RateLimitRejections.With("device_code")
+	srv.deviceCodePollRL.rejections = m.RateLimitRejections.With("device_code_poll")
 	mux.With(loginIPRateLimitMiddleware(srv.deviceCodeRL)).Post("/api/runtime/register", srv.handleRuntimeRegister)
 	mux.With(loginIPRateLimitMiddleware(srv.deviceCodePollRL)).Post("/api/runtime/register/poll", srv.handleRuntimeRegisterPoll)
 
+	// Signed one-time permission links (unauthenticated, rate-limited by IP)
+	srv.permLinkRL = newRateLimiter(3, 10)
+	srv.permLinkRL.rejections = m.RateLimitRejections.With("permission_link")
+	mux.With(loginIPRateLimitMiddleware(srv.permLinkRL)).Get("/api/permissions/{requestID}/link", srv.handlePermissionLink)
+	mux.With(loginIPRateLimitMiddleware(srv.permLinkRL)).Post("/api/permissions/{requestID}/link", srv.handlePermissionLink)
+
 	// WebSocket routes (auth handled inside)
 	mux.Get("/ws/runtime", rt.HandleRuntimeWS)
 	mux.Get("/ws/client", rt.HandleClientWS)
@@ -139,51 +191,115 @@ func NewServer(s store.Store, ap auth.Provider, lp auth.LoginProvider, ra auth.R
 
 	// Authenticated API routes (all users)
 	srv.rl = newRateLimiter(cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst)
+	srv.rl.rejections = m.RateLimitRejections.With("api")
 	mux.Group(func(r chi.Router) {
 		r.Use(srv.authMiddleware)
-		// Auto-provision users when using external auth (Clerk).
-		if srv.authProviderName == "clerk" {
+		// Auto-provision users when using external auth (Clerk, OIDC).
+		if srv.externalAuth() {
 			r.Use(srv.ensureUserMiddleware)
 		}
 		r.Use(rateLimitMiddleware(srv.rl))
+		r.Use(tokenScopeMiddleware)
+		r.Use(srv.adminMFAMiddleware)
 
 		r.Get("/api/agents", srv.handleListAgents)
+		r.Post("/api/agents/{agentID}/run", srv.handleRunAgent)
+		r.Get("/api/runs/{runID}", srv.handleGetRun)
 		r.Get("/api/prompt-profiles", srv.handleListPromptProfiles)
 		r.Get("/api/sessions", srv.handleListSessions)
 		r.Post("/api/sessions", srv.handleCreateSession)
 		r.Get("/api/sessions/{sessionID}", srv.handleGetSession)
 		r.Get("/api/sessions/{sessionID}/messages", srv.handleGetMessages)
+		r.Get("/api/sessions/{sessionID}/export", srv.handleExportSession)
 		r.Get("/api/sessions/{sessionID}/files", srv.handleListSessionFiles)
 		r.Post("/api/sessions/{sessionID}/files", srv.handleUploadFile)
 		r.Get("/api/files/{fileID}", srv.handleDownloadFile)
 		r.Post("/api/sessions/{sessionID}/close", srv.handleCloseSession)
+		r.Post("/api/sessions/{sessionID}/fork", srv.handleForkSession)
+		r.Post("/api/sessions/{sessionID}/rollback", srv.handleRollbackSession)
+		r.Get("/api/sessions/{sessionID}/forks", srv.handleListSessionForks)
+		r.Get("/api/sessions/{sessionID}/members", srv.handleListSessionMembers)
+		r.Post("/api/sessions/{sessionID}/members", srv.handleAddSessionMember)
+		r.Delete("/api/sessions/{sessionID}/members/{userID}", srv.handleRemoveSessionMember)
+		r.Get("/api/search", srv.handleSearch)
+		r.Get("/api/schedules", srv.handleListSchedules)
+		r.Post("/api/schedules", srv.handleCreateSchedule)
+		r.Get("/api/schedules/{scheduleID}", srv.handleGetSchedule)
+		r.Put("/api/schedules/{scheduleID}", srv.handleUpdateSchedule)
+		r.Delete("/api/schedules/{scheduleID}", srv.handleDeleteSchedule)
+		r.Get("/api/schedules/{scheduleID}/runs", srv.handleListScheduleRuns)
+		r.Get("/api/permissions/pending", srv.handleListPendingPermissions)
+		r.Post("/api/permissions/{requestID}/decision", srv.handlePermissionDecision)
 		r.Get("/api/me", srv.handleGetMe)
+		r.Get("/api/me/tokens", srv.handleListTokens)
+		r.Post("/api/me/tokens", srv.handleCreateToken)
+		r.Delete("/api/me/tokens/{tokenID}", srv.handleDeleteToken)
+		if lp != nil {
+			r.Post("/api/me/password", srv.handleChangePassword)
+		}
+		if srv.mfa != nil {
+			r.Get("/api/me/mfa", srv.handleGetMFA)
+			r.Post("/api/me/mfa/enroll", srv.handleEnrollMFA)
+			r.Post("/api/me/mfa/confirm", srv.handleConfirmMFA)
+			r.Post("/api/me/mfa/disable", srv.handleDisableMFA)
+		}
 	})
 
 	// Admin-only routes — require admin role
 	mux.Group(func(r chi.Router) {
 		r.Use(srv.authMiddleware)
-		if srv.authProviderName == "clerk" {
+		if srv.externalAuth() {
 			r.Use(srv.ensureUserMiddleware)
 		}
 		r.Use(rateLimitMiddleware(srv.rl))
 		r.Use(adminOnlyMiddleware)
+		r.Use(srv.adminMFAMiddleware)
 
 		r.Get("/api/runtimes", srv.handleListRuntimes)
 		r.Get("/api/users", srv.handleListUsers)
+		r.Patch("/api/users/{userID}", srv.handleUpdateUser)
+		r.Delete("/api/users/{userID}", srv.handleDeleteUser)
 		// User management only available with builtin auth.
 		if lp != nil {
 			r.Post("/api/users", srv.handleCreateUser)
+			r.Post("/api/users/{userID}/password-reset", srv.handleIssuePasswordReset)
+		}
+		if srv.mfa != nil {
+			r.Delete("/api/users/{userID}/mfa", srv.handleResetUserMFA)
+			r.Get("/api/admin/org/settings", srv.handleGetOrgSettings)
+			r.Put("/api/admin/org/settings", srv.handleUpdateOrgSettings)
 		}
 		r.Post("/api/permissions", srv.handleGrantPermission)
 		r.Delete("/api/permissions", srv.handleRevokePermission)
 		r.Get("/api/users/{userID}/permissions", srv.handleListUserPermissions)
+		r.Get("/api/admin/groups", srv.handleListGroups)
+		r.Post("/api/admin/groups", srv.handleCreateGroup)
+		r.Get("/api/admin/groups/{groupID}", srv.handleGetGroup)
+		r.Put("/api/admin/groups/{groupID}", srv.handleUpdateGroup)
+		r.Delete("/api/admin/groups/{groupID}", srv.handleDeleteGroup)
+		r.Post("/api/admin/groups/{groupID}/members", srv.handleAddGroupMember)
+		r.Delete("/api/admin/groups/{groupID}/members/{userID}", srv.handleRemoveGroupMember)
+		r.Post("/api/admin/groups/{groupID}/grants", srv.handleCreateGroupGrant)
+		r.Delete("/api/admin/groups/{groupID}/grants/{grantID}", srv.handleDeleteGroupGrant)
 		r.Get("/api/admin/sessions", srv.handleAdminListSessions)
 		r.Post("/api/admin/sessions/{sessionID}/close", srv.handleAdminCloseSession)
 		r.Get("/api/admin/audit", srv.handleAdminListAuditEvents)
 		r.Get("/api/admin/agents", srv.handleAdminListAgents)
 		r.Get("/api/admin/agents/{agentID}/config", srv.handleGetAgentConfig)
 		r.Put("/api/admin/agents/{agentID}/config", srv.handleUpdateAgentConfig)
+		r.Post("/api/admin/runtimes/{runtimeID}/agents", srv.handleCreateRuntimeAgent)
+		r.Put("/api/admin/runtimes/{runtimeID}/agents/{agentID}", srv.handleUpdateRuntimeAgent)
+		r.Delete("/api/admin/runtimes/{runtimeID}/agents/{agentID}", srv.handleDeleteRuntimeAgent)
+		r.Get("/api/admin/permission-policies", srv.handleListPermissionPolicies)
+		r.Post("/api/admin/permission-policies", srv.handleCreatePermissionPolicy)
+		r.Put("/api/admin/permission-policies/{policyID}", srv.handleUpdatePermissionPolicy)
+		r.Delete("/api/admin/permission-policies/{policyID}", srv.handleDeletePermissionPolicy)
+		r.Get("/api/admin/webhooks", srv.handleListWebhooks)
+		r.Post("/api/admin/webhooks", srv.handleCreateWebhook)
+		r.Get("/api/admin/webhooks/{webhookID}", srv.handleGetWebhook)
+		r.Put("/api/admin/webhooks/{webhookID}", srv.handleUpdateWebhook)
+		r.Delete("/api/admin/webhooks/{webhookID}", srv.handleDeleteWebhook)
+		r.Get("/api/admin/webhooks/{webhookID}/deliveries", srv.handleListWebhookDeliveries)
 		r.Post("/api/runtime/register/approve", srv.handleRuntimeRegisterApprove)
 	})
 
@@ -246,6 +362,9 @@ func (s *Server) StartBackgroundTasks(ctx context.Context) {
 	if s.deviceCodePollRL != nil {
 		s.deviceCodePollRL.StartCleanup(ctx, 5*time.Minute, 10*time.Minute)
 	}
+	if s.permLinkRL != nil {
+		s.permLinkRL.StartCleanup(ctx, 5*time.Minute, 10*time.Minute)
+	}
 	// Periodically clean up expired token blocklist entries and lockout entries.
 	go func() {
 		ticker := time.NewTicker(5 * time.Minute)
@@ -290,6 +409,17 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	token, err := s.loginProvider.Login(r.Context(), req.Username, req.Password)
+	var mfaErr *auth.MFARequiredError
+	if errors.As(err, &mfaErr) {
+		// Password was right; the account lockout is cleared only once the
+		// second factor is too.
+		writeJSON(w, http.StatusOK, map[string]any{"mfa_required": true, "mfa_token": mfaErr.Challenge})
+		return
+	}
+	if errors.Is(err, auth.ErrUserDisabled) {
+		writeError(w, http.StatusForbidden, "account is disabled")
+		return
+	}
 	if err != nil {
 		s.loginLockout.recordFailure(req.Username)
 		if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
@@ -408,6 +538,7 @@ func (s *Server) handleListAgents(w http.ResponseWriter, r *http.Request) {
 		Tags      json.RawMessage `json:"tags"`
 		Caps      json.RawMessage `json:"caps"`
 		Security  json.RawMessage `json:"security"`
+		Sandbox   json.RawMessage `json:"sandbox,omitempty"`
 		Online    bool            `json:"online"`
 	}
 	result := make([]agentResponse, len(agents))
@@ -421,6 +552,7 @@ func (s *Server) handleListAgents(w http.ResponseWriter, r *http.Request) {
 			Tags:      json.RawMessage(agent.Tags),
 			Caps:      json.RawMessage(agent.Caps),
 			Security:  json.RawMessage(agent.Security),
+			Sandbox:   json.RawMessage(agent.Sandbox),
 			Online:    onlineSet[agent.RuntimeID],
 		}
 	}
@@ -439,7 +571,7 @@ func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "session not found")
 		return
 	}
-	if sess.UserID != identity.UserID && identity.Role != "admin" {
+	if !s.router.CanViewSession(r.Context(), sess, identity.UserID, identity.Role) {
 		writeError(w, http.StatusForbidden, "access denied")
 		return
 	}
@@ -453,6 +585,15 @@ func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "failed to list sessions")
 		return
 	}
+	shared, err := s.store.ListSessionsSharedWithUser(r.Context(), identity.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list sessions")
+		return
+	}
+	if len(shared) > 0 {
+		sessions = append(sessions, shared...)
+		sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt) })
+	}
 	if sessions == nil {
 		sessions = []store.Session{}
 	}
@@ -613,7 +754,7 @@ func (s *Server) handleGetMessages(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "session not found")
 		return
 	}
-	if sess.UserID != identity.UserID && identity.Role != "admin" {
+	if !s.router.CanViewSession(r.Context(), sess, identity.UserID, identity.Role) {
 		writeError(w, http.StatusForbidden, "access denied")
 		return
 	}
@@ -647,6 +788,54 @@ func (s *Server) handleGetMessages(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, messages)
 }
 
+// handleSearch runs a full-text search over the transcripts the caller can
+// see: their own sessions, or every session in the org for admins.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+
+	limit := 50
+	offset := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	if limit > 200 {
+		limit = 200
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			offset = n
+		}
+	}
+
+	identity := getIdentityFromContext(r.Context())
+	filter := store.SearchFilter{
+		Query:  q,
+		OrgID:  identity.OrgID,
+		Limit:  limit,
+		Offset: offset,
+	}
+	if identity.Role != "admin" {
+		filter.UserID = identity.UserID
+	}
+
+	results, err := s.store.SearchMessages(r.Context(), filter)
+	if err != nil {
+		s.logger.Warn("search failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "search failed")
+		return
+	}
+	if results == nil {
+		results = []store.SearchResult{}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
 func (s *Server) handleCloseSession(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionID")
 	identity := getIdentityFromContext(r.Context())
@@ -774,6 +963,7 @@ func (s *Server) handleGrantPermission(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "failed to grant permission")
 		return
 	}
+	s.logAgentAccessEvent(r, "agent_access.granted", req.UserID, req.AgentID)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "granted"})
 }
 
@@ -795,9 +985,21 @@ func (s *Server) handleRevokePermission(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusInternalServerError, "failed to revoke permission")
 		return
 	}
+	s.logAgentAccessEvent(r, "agent_access.revoked", req.UserID, req.AgentID)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
 }
 
+func (s *Server) logAgentAccessEvent(r *http.Request, action, userID, agentID string) {
+	identity := getIdentityFromContext(r.Context())
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: action, UserID: identity.UserID, AgentID: agentID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"target_user_id":%q}`, userID)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", action, "error", err)
+	}
+}
+
 func (s *Server) handleListUserPermissions(w http.ResponseWriter, r *http.Request) {
 	userID := chi.URLParam(r, "userID")
 	agents, err := s.store.ListUserAgents(r.Context(), userID)
@@ -805,10 +1007,18 @@ func (s *Server) handleListUserPermissions(w http.ResponseWriter, r *http.Reques
 		writeError(w, http.StatusInternalServerError, "failed to list permissions")
 		return
 	}
+	groups, err := s.store.ListUserGroups(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list permissions")
+		return
+	}
 	if agents == nil {
 		agents = []string{}
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"user_id": userID, "agent_ids": agents})
+	if groups == nil {
+		groups = []store.Group{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"user_id": userID, "agent_ids": agents, "groups": groups})
 }
 
 // --- Admin session/audit handlers ---
@@ -923,6 +1133,18 @@ func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
 }
 
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.metricsToken != "" {
+		authHeader := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authHeader, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(authHeader[7:]), []byte(s.metricsToken)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid metrics token")
+			return
+		}
+	}
+	s.metrics.Registry.Handler().ServeHTTP(w, r)
+}
+
 // --- Admin agent config handlers ---
 
 // adminAgentInfo extends agent data with runtime info and config override.
@@ -937,6 +1159,7 @@ type adminAgentInfo struct {
 	Tags           json.RawMessage            `json:"tags"`
 	Caps           json.RawMessage            `json:"caps"`
 	Security       json.RawMessage            `json:"security"`
+	Sandbox        json.RawMessage            `json:"sandbox,omitempty"`
 	ConfigOverride *store.AgentConfigOverride `json:"config_override,omitempty"`
 }
 
@@ -980,6 +1203,7 @@ func (s *Server) handleAdminListAgents(w http.ResponseWriter, r *http.Request) {
 			Tags:      json.RawMessage(agent.Tags),
 			Caps:      json.RawMessage(agent.Caps),
 			Security:  json.RawMessage(agent.Security),
+			Sandbox:   json.RawMessage(agent.Sandbox),
 		}
 		if rt, ok := rtMap[agent.RuntimeID]; ok {
 			info.RuntimeName = rt.Name
@@ -1046,6 +1270,11 @@ func (s *Server) handleUpdateAgentConfig(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	if l := req.Limits; l != nil && (l.CPUQuota < 0 || l.MemoryMaxBytes < 0 || l.PidsMax < 0) {
+		writeError(w, http.StatusBadRequest, "cpu_quota, memory_max_bytes and pids_max must not be negative")
+		return
+	}
+
 	// Verify agent exists.
 	agent, err := s.store.GetAgent(r.Context(), agentID)
 	if err != nil || agent == nil {
@@ -1166,6 +1395,10 @@ func (s *Server) handleASRProxy(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
+	if !identity.HasScope(auth.ScopeMessagesSend) {
+		http.Error(w, "token lacks the messages:send scope", http.StatusForbidden)
+		return
+	}
 
 	// Per-user connection limit.
 	asrConnsMu.Lock()
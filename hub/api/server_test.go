@@ -5,9 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,7 +18,9 @@ import (
 	"github.com/amurg-ai/amurg/hub/config"
 	"github.com/amurg-ai/amurg/hub/router"
 	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/amurg-ai/amurg/pkg/protocol"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 )
 
 type staticAuthProvider struct {
@@ -31,6 +36,24 @@ func (p *staticAuthProvider) Bootstrap(context.Context) error { return nil }
 
 func (p *staticAuthProvider) Name() string { return p.name }
 
+// redirectAuthProvider is a staticAuthProvider that signs in through a
+// redirect, handing out token for the one login it starts.
+type redirectAuthProvider struct {
+	staticAuthProvider
+	state, token string
+}
+
+func (p *redirectAuthProvider) LoginURL() (string, string, error) {
+	return "https://idp.example.com/authorize?state=" + p.state, p.state, nil
+}
+
+func (p *redirectAuthProvider) CompleteLogin(_ context.Context, state, _ string) (string, *auth.Identity, error) {
+	if state != p.state {
+		return "", nil, auth.ErrLoginExpired
+	}
+	return p.token, p.identity, nil
+}
+
 func setupTestServer(t *testing.T) (*Server, *auth.Service, store.Store) {
 	t.Helper()
 	s, err := store.NewSQLite(":memory:")
@@ -390,6 +413,148 @@ func TestClerkProvisioningUsesStableExternalUserID(t *testing.T) {
 	}
 }
 
+func TestOIDCCallbackRequiresStateCookie(t *testing.T) {
+	s, err := store.NewSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	cfg := &config.Config{
+		Server:    config.ServerConfig{Addr: ":0", AllowedOrigins: []string{"*"}, MaxBodyBytes: 1024 * 1024, BaseURL: "https://hub.example.com"},
+		Auth:      config.AuthConfig{Provider: "oidc", DefaultAgentAccess: "all"},
+		RateLimit: config.RateLimitConfig{RequestsPerSecond: 100, Burst: 200},
+	}
+	provider := &redirectAuthProvider{
+		staticAuthProvider: staticAuthProvider{name: "oidc", identity: &auth.Identity{UserID: "user_alice", Username: "alice", Role: "user", OrgID: "default"}},
+		state:              "state-1",
+		token:              "hub-token",
+	}
+	rt := router.New(s, provider, nil, slog.Default(), router.Options{})
+	srv := NewServer(s, provider, nil, nil, rt, cfg, ServerOptions{AuthProviderName: "oidc"}, slog.Default())
+
+	w := httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login: expected 302, got %d", w.Code)
+	}
+	var stateCookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == oidcStateCookie {
+			stateCookie = c
+		}
+	}
+	if stateCookie == nil {
+		t.Fatal("login did not set the state cookie")
+	}
+	if !stateCookie.HttpOnly || !stateCookie.Secure || stateCookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("state cookie must be HttpOnly, Secure and SameSite=Lax: %+v", stateCookie)
+	}
+	if stateCookie.Value == provider.state {
+		t.Fatal("state cookie must hold a hash of the state, not the state")
+	}
+
+	callback := func(cookie *http.Cookie) string {
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?state=state-1&code=abc", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusFound {
+			t.Fatalf("callback: expected 302, got %d", w.Code)
+		}
+		return w.Header().Get("Location")
+	}
+
+	// A callback in a browser that never started the login, such as a victim
+	// lured to an attacker's callback URL, is refused.
+	if loc := callback(nil); strings.Contains(loc, "token=") || !strings.Contains(loc, "error=") {
+		t.Fatalf("callback without the state cookie must fail, redirected to %s", loc)
+	}
+	if loc := callback(&http.Cookie{Name: oidcStateCookie, Value: oidcStateHash("other")}); strings.Contains(loc, "token=") {
+		t.Fatalf("callback with another login's cookie must fail, redirected to %s", loc)
+	}
+
+	if loc := callback(stateCookie); loc != "https://hub.example.com/login#token=hub-token" {
+		t.Fatalf("callback with the state cookie: redirected to %s", loc)
+	}
+}
+
+func TestExternalAuthUserLifecycle(t *testing.T) {
+	s, err := store.NewSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{Addr: ":0", AllowedOrigins: []string{"*"}, MaxBodyBytes: 1024 * 1024},
+		Auth:   config.AuthConfig{Provider: "clerk", ClerkIssuer: "https://example.clerk.accounts.dev", DefaultAgentAccess: "all"},
+		RateLimit: config.RateLimitConfig{
+			RequestsPerSecond: 100,
+			Burst:             200,
+		},
+	}
+	ctx := context.Background()
+	for _, u := range []*store.User{
+		{ID: "user_admin", OrgID: "default", ExternalID: "user_admin", Username: "admin@example.com", Role: "admin", CreatedAt: time.Now()},
+		{ID: "user_bob", OrgID: "default", ExternalID: "user_bob", Username: "bob@example.com", Role: "user", CreatedAt: time.Now()},
+	} {
+		if err := s.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	provider := &staticAuthProvider{name: "clerk", identity: &auth.Identity{UserID: "user_admin", Username: "admin@example.com", Role: "admin", OrgID: "default"}}
+	rt := router.New(s, provider, nil, slog.Default(), router.Options{})
+	srv := NewServer(s, provider, nil, nil, rt, cfg, ServerOptions{AuthProviderName: "clerk"}, slog.Default())
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		return w
+	}
+
+	// The stored role follows the provider on interactive sign-in, which is
+	// what personal access tokens act with; token requests leave it alone.
+	provider.identity = &auth.Identity{UserID: "user_bob", Username: "bob@example.com", Role: "admin", OrgID: "default"}
+	if w := do(http.MethodGet, "/api/me", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for bob, got %d: %s", w.Code, w.Body.String())
+	}
+	provider.identity = &auth.Identity{UserID: "user_bob", Username: "bob@example.com", Role: "user", OrgID: "default", TokenID: "tok-1"}
+	do(http.MethodGet, "/api/me", "")
+	if bob, _ := s.GetUserByID(ctx, "user_bob"); bob == nil || bob.Role != "admin" {
+		t.Fatalf("expected bob's stored role to follow the provider, got %+v", bob)
+	}
+	provider.identity = &auth.Identity{UserID: "user_admin", Username: "admin@example.com", Role: "admin", OrgID: "default"}
+
+	// Roles come from the identity provider; disabling is the hub's call.
+	if w := do(http.MethodPatch, "/api/users/user_bob", `{"role":"admin"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 changing an external user's role, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPatch, "/api/users/user_bob", `{"disabled":true}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 disabling an external user, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The provider still vouches for bob, but the hub refuses him.
+	provider.identity = &auth.Identity{UserID: "user_bob", Username: "bob@example.com", Role: "user", OrgID: "default"}
+	if w := do(http.MethodGet, "/api/me", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a disabled external user, got %d: %s", w.Code, w.Body.String())
+	}
+
+	provider.identity = &auth.Identity{UserID: "user_admin", Username: "admin@example.com", Role: "admin", OrgID: "default"}
+	if w := do(http.MethodDelete, "/api/users/user_bob", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 deleting an external user, got %d: %s", w.Code, w.Body.String())
+	}
+	provider.identity = &auth.Identity{UserID: "user_bob", Username: "bob@example.com", Role: "user", OrgID: "default"}
+	if w := do(http.MethodGet, "/api/me", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a deleted external user, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
 func TestLoginUsernameValidation(t *testing.T) {
 	srv, _, _ := setupTestServer(t)
 
@@ -1070,3 +1235,1752 @@ func TestGetMessages_OtherUserDenied(t *testing.T) {
 		t.Fatalf("expected other user to get 403, got %d; body: %s", w.Code, w.Body.String())
 	}
 }
+
+func TestMetrics_Unauthenticated(t *testing.T) {
+	srv, _, _ := setupTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, name := range []string{"amurg_hub_runtimes_connected", "amurg_hub_clients_connected", "amurg_hub_turn_duration_seconds"} {
+		if !strings.Contains(body, "# TYPE "+name) {
+			t.Errorf("expected %s in metrics output", name)
+		}
+	}
+}
+
+func TestMetrics_TokenRequired(t *testing.T) {
+	srv, _, _ := setupTestServer(t)
+	srv.metricsToken = "scrape-secret"
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 without token, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape-secret")
+	w = httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 with token, got %d", w.Code)
+	}
+}
+
+func TestMetrics_CountsRateLimitRejections(t *testing.T) {
+	srv, authSvc, st := setupTestServer(t)
+	token := createTestUserAndGetToken(t, authSvc, st)
+	srv.rl.rate = 0
+	srv.rl.burst = 1
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		srv.mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if got := srv.metrics.RateLimitRejections.With("api").Value(); got != 2 {
+		t.Errorf("api rejections = %v, want 2", got)
+	}
+}
+
+func TestSearch_ScopedToCaller(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	ctx := context.Background()
+	_, agentID := seedAgentAndRuntime(t, s)
+
+	userToken := createTestUserAndGetToken(t, authSvc, s)
+	adminToken := createTestAdminAndGetToken(t, authSvc, s)
+	user, _ := s.GetUser(ctx, "default", "testuser")
+	admin, _ := s.GetUser(ctx, "default", "testadmin")
+
+	seed := func(userID, content string) string {
+		sessID := uuid.New().String()
+		if err := s.CreateSession(ctx, &store.Session{
+			ID: sessID, OrgID: "default", UserID: userID, AgentID: agentID, RuntimeID: "rt-1",
+			Profile: "default", State: "active", CreatedAt: time.Now(), UpdatedAt: time.Now(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.AppendMessage(ctx, &store.Message{
+			ID: uuid.New().String(), SessionID: sessID, Direction: "agent", Channel: "stdout",
+			Content: content, CreatedAt: time.Now(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return sessID
+	}
+	userSess := seed(user.ID, "I fixed the users migration")
+	seed(admin.ID, "rolled back the orders migration")
+
+	search := func(token string) []store.SearchResult {
+		req := httptest.NewRequest(http.MethodGet, "/api/search?q=migration", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var results []store.SearchResult
+		parseJSONResponse(t, w, &results)
+		return results
+	}
+
+	results := search(userToken)
+	if len(results) != 1 {
+		t.Fatalf("user: expected 1 result, got %d", len(results))
+	}
+	if results[0].SessionID != userSess || results[0].Seq != 1 {
+		t.Errorf("user: unexpected result %+v", results[0])
+	}
+	if !strings.Contains(results[0].Snippet, "**migration**") {
+		t.Errorf("user: expected highlighted snippet, got %q", results[0].Snippet)
+	}
+
+	if results := search(adminToken); len(results) != 2 {
+		t.Errorf("admin: expected 2 results, got %d", len(results))
+	}
+}
+
+func TestSearch_MissingQuery(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	token := createTestUserAndGetToken(t, authSvc, s)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/search?q=%20", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestExportSession(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	token := createTestUserAndGetToken(t, authSvc, s)
+	_, agentID := seedAgentAndRuntime(t, s)
+	ctx := context.Background()
+	user, _ := s.GetUser(ctx, "default", "testuser")
+
+	sessID := uuid.New().String()
+	if err := s.CreateSession(ctx, &store.Session{
+		ID: sessID, OrgID: "default", UserID: user.ID, AgentID: agentID, RuntimeID: "rt-1",
+		Profile: "claude-code", State: "active", CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Now().Add(-time.Minute)
+	for i, m := range []struct{ direction, channel, content string }{
+		{"user", "stdin", "run the tests"},
+		{"agent", "tool", `{"type":"tool_use","id":"tu-1","name":"Bash","input":{"command":"go test ./..."}}`},
+		{"agent", "tool", `{"type":"tool_result","tool_use_id":"tu-1","content":"ok  ./...","is_error":false}`},
+		{"agent", "stdout", "All tests pass."},
+		{"agent", "diff", `{"files":[{"path":"main_test.go","status":"modified","additions":2,"deletions":1}],"patch":"--- a/main_test.go\n+++ b/main_test.go\n"}`},
+		{"user", "file", `{"file_id":"f-1","name":"log.txt","mime_type":"text/plain","size":12,"direction":"upload"}`},
+	} {
+		if _, err := s.AppendMessage(ctx, &store.Message{
+			ID: uuid.New().String(), SessionID: sessID, Direction: m.direction, Channel: m.channel,
+			Content: m.content, CreatedAt: base.Add(time.Duration(i) * time.Second),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.LogAuditEvent(ctx, &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: "default", Action: "permission.requested", SessionID: sessID,
+		Detail:    json.RawMessage(`{"tool":"Bash","resource":"go test ./...","request_id":"perm-1"}`),
+		CreatedAt: base.Add(1500 * time.Millisecond),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LogAuditEvent(ctx, &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: "default", Action: "permission.granted", UserID: user.ID, SessionID: sessID,
+		Detail:    json.RawMessage(`{"request_id":"perm-1","approved":true}`),
+		CreatedAt: base.Add(1600 * time.Millisecond),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	export := func(format string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/sessions/"+sessID+"/export?format="+format, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("markdown", func(t *testing.T) {
+		w := export("md")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/markdown") {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		body := w.Body.String()
+		for _, want := range []string{
+			"# Session " + sessID,
+			"run the tests",
+			"**Tool:** `Bash`",
+			`"command": "go test ./..."`,
+			"<details><summary>Result</summary>",
+			"ok  ./...",
+			"**Permission granted**: `Bash` on `go test ./...` by testuser",
+			"[log.txt](/api/files/f-1?session_id=" + sessID + ")",
+			"**Changes:** 1 file(s)",
+			"- `main_test.go` (modified, +2 -1)",
+			"```diff\n--- a/main_test.go",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("markdown missing %q\n%s", want, body)
+			}
+		}
+		// The tool result is rendered with its tool_use, before the agent's reply.
+		if strings.Index(body, "ok  ./...") > strings.Index(body, "All tests pass.") {
+			t.Error("expected tool result to be paired with its tool_use")
+		}
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		w := export("jsonl")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		// session header + user msg + tool + 2 permission events + agent reply + changes + file
+		if len(lines) != 8 {
+			t.Fatalf("expected 8 lines, got %d:\n%s", len(lines), w.Body.String())
+		}
+		var header map[string]any
+		if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+			t.Fatal(err)
+		}
+		if header["kind"] != "session" || header["id"] != sessID {
+			t.Errorf("unexpected header %v", header)
+		}
+	})
+
+	t.Run("html", func(t *testing.T) {
+		w := export("html")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "<title>Session "+sessID+"</title>") || !strings.Contains(body, "All tests pass.") {
+			t.Errorf("unexpected html:\n%s", body)
+		}
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		if w := export("pdf"); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+}
+
+func TestExportSession_OtherUserDenied(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	token := createTestUserAndGetToken(t, authSvc, s)
+	_, agentID := seedAgentAndRuntime(t, s)
+	ctx := context.Background()
+	owner, err := authSvc.Register(ctx, "owner", "ownerpassword123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessID := uuid.New().String()
+	if err := s.CreateSession(ctx, &store.Session{
+		ID: sessID, OrgID: "default", UserID: owner.ID, AgentID: agentID, RuntimeID: "rt-1",
+		Profile: "default", State: "active", CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/sessions/"+sessID+"/export", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+}
+
+func TestWebhooksCRUD(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	adminToken := createTestAdminAndGetToken(t, authSvc, s)
+
+	do := func(method, path, token string, body any) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		return w
+	}
+
+	// Validation.
+	if w := do(http.MethodPost, "/api/admin/webhooks", adminToken, map[string]any{
+		"url": "ftp://example.com", "events": []string{"session.created"},
+	}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad scheme, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/admin/webhooks", adminToken, map[string]any{
+		"url": "https://example.com/hook", "events": []string{"session.exploded"},
+	}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown event, got %d", w.Code)
+	}
+
+	// Create returns the generated secret once.
+	w := do(http.MethodPost, "/api/admin/webhooks", adminToken, map[string]any{
+		"url": "https://example.com/hook", "events": []string{"session.created", "runtime.offline"},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var created webhookResponse
+	parseJSONResponse(t, w, &created)
+	if created.Secret == "" || !created.Enabled || len(created.Events) != 2 {
+		t.Fatalf("unexpected create response %+v", created)
+	}
+
+	// List hides the secret.
+	w = do(http.MethodGet, "/api/admin/webhooks", adminToken, nil)
+	var list []webhookResponse
+	parseJSONResponse(t, w, &list)
+	if len(list) != 1 || list[0].Secret != "" {
+		t.Fatalf("unexpected list response %+v", list)
+	}
+
+	// Partial update keeps the URL.
+	w = do(http.MethodPut, "/api/admin/webhooks/"+created.ID, adminToken, map[string]any{"enabled": false})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var updated webhookResponse
+	parseJSONResponse(t, w, &updated)
+	if updated.Enabled || updated.URL != "https://example.com/hook" {
+		t.Fatalf("unexpected update response %+v", updated)
+	}
+
+	w = do(http.MethodGet, "/api/admin/webhooks/"+created.ID+"/deliveries", adminToken, nil)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Fatalf("deliveries: got %d %s", w.Code, w.Body.String())
+	}
+
+	// Non-admins are rejected.
+	userToken := createTestUserAndGetToken(t, authSvc, s)
+	if w := do(http.MethodGet, "/api/admin/webhooks", userToken, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for non-admin, got %d", w.Code)
+	}
+
+	if w := do(http.MethodDelete, "/api/admin/webhooks/"+created.ID, adminToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/api/admin/webhooks/"+created.ID, adminToken, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", w.Code)
+	}
+
+	events, _ := s.ListAuditEventsFiltered(context.Background(), "default", store.AuditFilter{Action: "webhook."})
+	if len(events) != 3 {
+		t.Errorf("expected 3 webhook audit events, got %d", len(events))
+	}
+}
+
+// connectTestRuntime dials the runtime WebSocket as rt-1 with a single agent and
+// waits for the hello ack.
+func connectTestRuntime(t *testing.T, srv *Server, agentID string) *websocket.Conn {
+	t.Helper()
+	ts := httptest.NewServer(srv.mux)
+	t.Cleanup(ts.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws/runtime", nil)
+	if err != nil {
+		t.Fatalf("dial runtime websocket: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	if err := conn.WriteJSON(protocol.Envelope{
+		Type: protocol.TypeRuntimeHello,
+		Payload: protocol.RuntimeHello{
+			RuntimeID: "rt-1",
+			Token:     "tok-1",
+			Agents:    []protocol.AgentRegistration{{ID: agentID, Profile: "generic-cli", Name: "test-agent"}},
+		},
+	}); err != nil {
+		t.Fatalf("write hello: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var ack protocol.Envelope
+	if err := conn.ReadJSON(&ack); err != nil || ack.Type != protocol.TypeHelloAck {
+		t.Fatalf("expected hello.ack, got %+v (err %v)", ack, err)
+	}
+	return conn
+}
+
+func TestPermissionDecisionAndLinks(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	userToken := createTestUserAndGetToken(t, authSvc, s)
+	agentID := "ag-perm-" + uuid.New().String()[:8]
+	rtConn := connectTestRuntime(t, srv, agentID)
+
+	do := func(method, path, token string, body any) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/sessions", userToken, map[string]string{"agent_id": agentID})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create session: %d %s", w.Code, w.Body.String())
+	}
+	var sess store.Session
+	parseJSONResponse(t, w, &sess)
+
+	requestPermission := func(requestID string) pendingPermissionResponse {
+		t.Helper()
+		if err := rtConn.WriteJSON(protocol.Envelope{
+			Type:    protocol.TypePermissionRequest,
+			Payload: protocol.PermissionRequest{SessionID: sess.ID, RequestID: requestID, Tool: "Bash", Description: "make deploy"},
+		}); err != nil {
+			t.Fatalf("write permission request: %v", err)
+		}
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			var pending []pendingPermissionResponse
+			parseJSONResponse(t, do(http.MethodGet, "/api/permissions/pending", userToken, nil), &pending)
+			for _, p := range pending {
+				if p.RequestID == requestID {
+					return p
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("permission request %s never became pending", requestID)
+		return pendingPermissionResponse{}
+	}
+
+	// Answer through the REST API.
+	p := requestPermission("perm-rest")
+	if p.Tool != "Bash" || p.ApproveURL == "" || p.DenyURL == "" {
+		t.Fatalf("unexpected pending permission %+v", p)
+	}
+	otherToken := func() string {
+		_, _ = authSvc.Register(context.Background(), "otheruser", "testpassword123", "user")
+		tok, _ := authSvc.Login(context.Background(), "otheruser", "testpassword123")
+		return tok
+	}()
+	if w := do(http.MethodPost, "/api/permissions/perm-rest/decision", otherToken, map[string]bool{"approved": true}); w.Code != http.StatusForbidden {
+		t.Fatalf("other user decision: expected 403, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/permissions/perm-rest/decision", userToken, map[string]bool{"approved": true}); w.Code != http.StatusOK {
+		t.Fatalf("decision: expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/api/permissions/perm-rest/decision", userToken, map[string]bool{"approved": false}); w.Code != http.StatusNotFound {
+		t.Fatalf("second decision: expected 404, got %d", w.Code)
+	}
+
+	// A collaborator may answer, but always_allow only approves once.
+	other, _ := s.GetUser(context.Background(), "default", "otheruser")
+	if err := s.AddSessionMember(context.Background(), &store.SessionMember{
+		SessionID: sess.ID, UserID: other.ID, Role: store.MemberCollaborator, AddedBy: sess.UserID, CreatedAt: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	requestPermission("perm-collab")
+	if w := do(http.MethodPost, "/api/permissions/perm-collab/decision", otherToken, map[string]bool{"approved": true, "always_allow": true}); w.Code != http.StatusOK {
+		t.Fatalf("collaborator decision: expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	if policies, _ := s.ListPermissionPolicies(context.Background(), "default"); len(policies) != 0 {
+		t.Fatalf("collaborator created always-allow policies: %+v", policies)
+	}
+
+	// Answer through a signed link: GET only confirms, POST decides, reuse fails.
+	p = requestPermission("perm-link")
+	link := strings.TrimPrefix(p.DenyURL, srv.baseURL)
+	if w := do(http.MethodGet, link, "", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<form") {
+		t.Fatalf("link GET: expected confirmation page, got %d", w.Code)
+	}
+	if _, ok := srv.router.GetPendingPermission("perm-link"); !ok {
+		t.Fatal("GET on link must not answer the request")
+	}
+	if w := do(http.MethodGet, strings.Replace(link, "decision=deny", "decision=approve", 1), "", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("tampered link: expected 403, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, link, "", nil); w.Code != http.StatusOK {
+		t.Fatalf("link POST: expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, link, "", nil); w.Code != http.StatusGone {
+		t.Fatalf("reused link: expected 410, got %d", w.Code)
+	}
+
+	// The runtime received both decisions.
+	decisions := map[string]bool{}
+	_ = rtConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(decisions) < 2 {
+		var env protocol.Envelope
+		if err := rtConn.ReadJSON(&env); err != nil {
+			t.Fatalf("read runtime message: %v", err)
+		}
+		if env.Type != protocol.TypePermissionResponse {
+			continue
+		}
+		b, _ := json.Marshal(env.Payload)
+		var resp protocol.PermissionResponse
+		_ = json.Unmarshal(b, &resp)
+		decisions[resp.RequestID] = resp.Approved
+	}
+	if !decisions["perm-rest"] || decisions["perm-link"] {
+		t.Errorf("unexpected decisions %v", decisions)
+	}
+}
+
+func TestPermissionPoliciesCRUD(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	adminToken := createTestAdminAndGetToken(t, authSvc, s)
+
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodPost, "/api/admin/permission-policies", map[string]string{"tool": "Bash", "effect": "maybe"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid effect: expected 400, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/admin/permission-policies", map[string]string{"effect": "allow"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("allow-everything policy: expected 400, got %d", w.Code)
+	}
+
+	w := do(http.MethodPost, "/api/admin/permission-policies", map[string]string{"tool": "Write", "pattern": "/etc/*", "effect": "deny"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d %s", w.Code, w.Body.String())
+	}
+	var created store.PermissionPolicy
+	parseJSONResponse(t, w, &created)
+
+	w = do(http.MethodPut, "/api/admin/permission-policies/"+created.ID, map[string]string{"pattern": "/etc/**"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	var updated store.PermissionPolicy
+	parseJSONResponse(t, w, &updated)
+	if updated.Pattern != "/etc/**" || updated.Tool != "Write" || updated.Effect != "deny" {
+		t.Fatalf("partial update lost fields: %+v", updated)
+	}
+
+	var list []store.PermissionPolicy
+	parseJSONResponse(t, do(http.MethodGet, "/api/admin/permission-policies", nil), &list)
+	if len(list) != 1 {
+		t.Fatalf("list: got %d policies, want 1", len(list))
+	}
+
+	if w := do(http.MethodDelete, "/api/admin/permission-policies/"+created.ID, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/api/admin/permission-policies/"+created.ID, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("second delete: expected 404, got %d", w.Code)
+	}
+}
+
+func TestSessionMembers(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	ownerToken := createTestUserAndGetToken(t, authSvc, s)
+	ctx := context.Background()
+	guest, err := authSvc.Register(ctx, "guest", "testpassword123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	guestToken, err := authSvc.Login(ctx, "guest", "testpassword123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner, _ := s.GetUser(ctx, "default", "testuser")
+	runtimeID, agentID := seedAgentAndRuntime(t, s)
+	if err := s.CreateSession(ctx, &store.Session{
+		ID: "sess-shared", OrgID: "default", UserID: owner.ID, AgentID: agentID, RuntimeID: runtimeID,
+		Profile: "default", State: "active", CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(token, method, path string, body any) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(guestToken, http.MethodGet, "/api/sessions/sess-shared/messages", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("messages before sharing: expected 403, got %d", w.Code)
+	}
+	if w := do(guestToken, http.MethodPost, "/api/sessions/sess-shared/members", map[string]string{"username": "guest", "role": "observer"}); w.Code != http.StatusForbidden {
+		t.Fatalf("non-owner invite: expected 403, got %d", w.Code)
+	}
+	if w := do(ownerToken, http.MethodPost, "/api/sessions/sess-shared/members", map[string]string{"username": "guest", "role": "owner"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid role: expected 400, got %d", w.Code)
+	}
+	if w := do(ownerToken, http.MethodPost, "/api/sessions/sess-shared/members", map[string]string{"username": "nobody", "role": "observer"}); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown user: expected 404, got %d", w.Code)
+	}
+	if w := do(ownerToken, http.MethodPost, "/api/sessions/sess-shared/members", map[string]string{"username": "guest", "role": "observer"}); w.Code != http.StatusCreated {
+		t.Fatalf("invite: expected 201, got %d %s", w.Code, w.Body.String())
+	}
+
+	// The guest now sees the session, can read it, but cannot upload files into it.
+	w := do(guestToken, http.MethodGet, "/api/sessions", nil)
+	var sessions []store.Session
+	parseJSONResponse(t, w, &sessions)
+	if len(sessions) != 1 || sessions[0].ID != "sess-shared" || sessions[0].MemberRole != store.MemberObserver {
+		t.Fatalf("shared session list: unexpected %+v", sessions)
+	}
+	if w := do(guestToken, http.MethodGet, "/api/sessions/sess-shared/messages", nil); w.Code != http.StatusOK {
+		t.Fatalf("observer messages: expected 200, got %d", w.Code)
+	}
+	if w := do(guestToken, http.MethodPost, "/api/sessions/sess-shared/files", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("observer upload: expected 403, got %d", w.Code)
+	}
+
+	w = do(guestToken, http.MethodGet, "/api/sessions/sess-shared/members", nil)
+	var members []store.SessionMember
+	parseJSONResponse(t, w, &members)
+	if len(members) != 1 || members[0].UserID != guest.ID || members[0].Username != "guest" {
+		t.Fatalf("members: unexpected %+v", members)
+	}
+
+	// Members may leave on their own.
+	if w := do(guestToken, http.MethodDelete, "/api/sessions/sess-shared/members/"+guest.ID, nil); w.Code != http.StatusOK {
+		t.Fatalf("leave: expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	if w := do(guestToken, http.MethodGet, "/api/sessions/sess-shared", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("after leaving: expected 403, got %d", w.Code)
+	}
+}
+
+func TestForkSession(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	userToken := createTestUserAndGetToken(t, authSvc, s)
+	ctx := context.Background()
+	agentID := "ag-fork-" + uuid.New().String()[:8]
+	rtConn := connectTestRuntime(t, srv, agentID)
+
+	// Make the agent resumable.
+	agent, _ := s.GetAgent(ctx, agentID)
+	caps, _ := json.Marshal(protocol.KnownProfiles[protocol.ProfileClaudeCode])
+	agent.Profile = protocol.ProfileClaudeCode
+	agent.Caps = string(caps)
+	if err := s.UpsertAgent(ctx, agent); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		req.Header.Set("Authorization", "Bearer "+userToken)
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		return w
+	}
+	readSessionCreate := func() protocol.SessionCreate {
+		t.Helper()
+		_ = rtConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var env protocol.Envelope
+		if err := rtConn.ReadJSON(&env); err != nil || env.Type != protocol.TypeSessionCreate {
+			t.Fatalf("expected session.create, got %+v (err %v)", env, err)
+		}
+		data, _ := json.Marshal(env.Payload)
+		var create protocol.SessionCreate
+		_ = json.Unmarshal(data, &create)
+		return create
+	}
+
+	w := do(http.MethodPost, "/api/sessions", map[string]string{"agent_id": agentID})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create session: %d %s", w.Code, w.Body.String())
+	}
+	var parent store.Session
+	parseJSONResponse(t, w, &parent)
+	readSessionCreate()
+	if err := s.SetSessionNativeHandle(ctx, parent.ID, "native-1"); err != nil {
+		t.Fatal(err)
+	}
+	for i, content := range []string{"first", "reply", "second"} {
+		dir := "user"
+		if i == 1 {
+			dir = "agent"
+		}
+		if _, err := s.AppendMessage(ctx, &store.Message{
+			ID: uuid.New().String(), SessionID: parent.ID, Direction: dir, Channel: "stdout", Content: content, CreatedAt: time.Now(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if w := do(http.MethodPost, "/api/sessions/"+parent.ID+"/fork", map[string]int{"seq": 9}); w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown seq: expected 400, got %d", w.Code)
+	}
+
+	// A fork from the middle copies the transcript but cannot rewind the native session.
+	w = do(http.MethodPost, "/api/sessions/"+parent.ID+"/fork", map[string]int{"seq": 2})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("fork: expected 201, got %d %s", w.Code, w.Body.String())
+	}
+	var mid store.Session
+	parseJSONResponse(t, w, &mid)
+	if mid.ResumedFrom != parent.ID || mid.ForkSeq != 2 || mid.ForkMode != router.ForkTranscript {
+		t.Fatalf("fork lineage: unexpected %+v", mid)
+	}
+	if create := readSessionCreate(); create.SessionID != mid.ID || create.ResumeSessionID != "" {
+		t.Fatalf("mid fork: unexpected session.create %+v", create)
+	}
+	msgs, _ := s.GetMessages(ctx, mid.ID, 0, 10)
+	if len(msgs) != 2 || msgs[1].Content != "reply" || msgs[1].Seq != 2 {
+		t.Fatalf("fork transcript: unexpected %+v", msgs)
+	}
+
+	// A fork from the last message branches the native session.
+	w = do(http.MethodPost, "/api/sessions/"+parent.ID+"/fork", map[string]int{"seq": 3})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("fork at end: expected 201, got %d %s", w.Code, w.Body.String())
+	}
+	var end store.Session
+	parseJSONResponse(t, w, &end)
+	if end.ForkMode != router.ForkNative {
+		t.Fatalf("end fork: expected a native fork, got %q", end.ForkMode)
+	}
+	if create := readSessionCreate(); !create.Fork || create.ResumeSessionID != "native-1" {
+		t.Fatalf("end fork: unexpected session.create %+v", create)
+	}
+
+	var forks []store.Session
+	parseJSONResponse(t, do(http.MethodGet, "/api/sessions/"+parent.ID+"/forks", nil), &forks)
+	if len(forks) != 2 || forks[0].ID != mid.ID {
+		t.Fatalf("forks: unexpected %+v", forks)
+	}
+	if parentMsgs, _ := s.GetMessages(ctx, parent.ID, 0, 10); len(parentMsgs) != 3 {
+		t.Fatalf("parent transcript changed: got %d messages", len(parentMsgs))
+	}
+}
+
+func TestRollbackSession(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	userToken := createTestUserAndGetToken(t, authSvc, s)
+	ctx := context.Background()
+	agentID := "ag-rollback-" + uuid.New().String()[:8]
+	rtConn := connectTestRuntime(t, srv, agentID)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions", strings.NewReader(`{"agent_id":"`+agentID+`"}`))
+	req.Header.Set("Authorization", "Bearer "+userToken)
+	w := httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create session: %d %s", w.Code, w.Body.String())
+	}
+	var sess store.Session
+	parseJSONResponse(t, w, &sess)
+	_ = rtConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var env protocol.Envelope
+	if err := rtConn.ReadJSON(&env); err != nil || env.Type != protocol.TypeSessionCreate {
+		t.Fatalf("expected session.create, got %+v (err %v)", env, err)
+	}
+
+	msgID := uuid.New().String()
+	for _, m := range []store.Message{
+		{ID: msgID, Direction: "user", Channel: "stdin", Content: "refactor it"},
+		{ID: uuid.New().String(), Direction: "agent", Channel: "stdout", Content: "done"},
+	} {
+		m.SessionID, m.CreatedAt = sess.ID, time.Now()
+		if _, err := s.AppendMessage(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rollback := func(seq int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/sessions/"+sess.ID+"/rollback", strings.NewReader(fmt.Sprintf(`{"seq":%d}`, seq)))
+		req.Header.Set("Authorization", "Bearer "+userToken)
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		return w
+	}
+	// answer reads the next rollback request and acknowledges it.
+	answer := func(errMsg string) <-chan protocol.WorkspaceRollback {
+		got := make(chan protocol.WorkspaceRollback, 1)
+		go func() {
+			_ = rtConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			var env protocol.Envelope
+			if err := rtConn.ReadJSON(&env); err != nil || env.Type != protocol.TypeWorkspaceRollback {
+				close(got)
+				return
+			}
+			data, _ := json.Marshal(env.Payload)
+			var rb protocol.WorkspaceRollback
+			_ = json.Unmarshal(data, &rb)
+			got <- rb
+			_ = rtConn.WriteJSON(protocol.Envelope{
+				Type: protocol.TypeWorkspaceRollbackAck, SessionID: rb.SessionID,
+				Payload: protocol.WorkspaceRollbackAck{SessionID: rb.SessionID, RequestID: rb.RequestID, OK: errMsg == "", Error: errMsg},
+			})
+		}()
+		return got
+	}
+
+	if w := rollback(2); w.Code != http.StatusBadRequest {
+		t.Fatalf("agent message: expected 400, got %d", w.Code)
+	}
+	if w := rollback(9); w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown seq: expected 400, got %d", w.Code)
+	}
+
+	got := answer("")
+	if w := rollback(1); w.Code != http.StatusOK {
+		t.Fatalf("rollback: expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	if rb := <-got; rb.MessageID != msgID || rb.Seq != 1 {
+		t.Fatalf("unexpected rollback request %+v", rb)
+	}
+	events, _ := s.ListAuditEventsFiltered(ctx, "default", store.AuditFilter{Action: "session.rollback", SessionID: sess.ID, Limit: 10})
+	if len(events) != 1 {
+		t.Fatalf("expected 1 session.rollback audit event, got %d", len(events))
+	}
+
+	answer("no checkpoint")
+	if w := rollback(1); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "no checkpoint") {
+		t.Fatalf("refused rollback: expected 409, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	userToken := createTestUserAndGetToken(t, authSvc, s)
+	adminToken := createTestAdminAndGetToken(t, authSvc, s)
+	ctx := context.Background()
+	other, err := authSvc.Register(ctx, "other", "testpassword123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherToken, err := authSvc.Login(ctx, "other", "testpassword123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, agentID := seedAgentAndRuntime(t, s)
+
+	do := func(token, method, path string, body any) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		return w
+	}
+
+	for name, body := range map[string]map[string]any{
+		"bad cron":       {"agent_id": agentID, "cron": "61 * * * *", "prompt": "hi"},
+		"missing prompt": {"agent_id": agentID, "cron": "@daily"},
+		"unknown agent":  {"agent_id": "ag-missing", "cron": "@daily", "prompt": "hi"},
+		"bad profile":    {"agent_id": agentID, "cron": "@daily", "prompt": "hi", "prompt_profile": "nope"},
+	} {
+		if w := do(userToken, http.MethodPost, "/api/schedules", body); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d %s", name, w.Code, w.Body.String())
+		}
+	}
+	if w := do(userToken, http.MethodPost, "/api/schedules", map[string]any{
+		"user_id": other.ID, "agent_id": agentID, "cron": "@daily", "prompt": "hi",
+	}); w.Code != http.StatusForbidden {
+		t.Fatalf("user creating for another user: expected 403, got %d", w.Code)
+	}
+
+	w := do(userToken, http.MethodPost, "/api/schedules", map[string]any{
+		"name": "nightly", "agent_id": agentID, "cron": "0 2 * * *", "prompt": "triage new issues",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d %s", w.Code, w.Body.String())
+	}
+	var sc store.Schedule
+	parseJSONResponse(t, w, &sc)
+	if !sc.Enabled || sc.NextRunAt.IsZero() || sc.NextRunAt.UTC().Hour() != 2 || sc.NextRunAt.Minute() != 0 {
+		t.Fatalf("unexpected schedule %+v", sc)
+	}
+
+	// Schedules are private to their owner and admins.
+	if w := do(otherToken, http.MethodGet, "/api/schedules/"+sc.ID, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("other user get: expected 404, got %d", w.Code)
+	}
+	var list []store.Schedule
+	parseJSONResponse(t, do(otherToken, http.MethodGet, "/api/schedules", nil), &list)
+	if len(list) != 0 {
+		t.Fatalf("other user sees %d schedules", len(list))
+	}
+	parseJSONResponse(t, do(adminToken, http.MethodGet, "/api/schedules", nil), &list)
+	if len(list) != 1 || list[0].ID != sc.ID {
+		t.Fatalf("admin list: unexpected %+v", list)
+	}
+
+	w = do(userToken, http.MethodPut, "/api/schedules/"+sc.ID, map[string]any{"cron": "*/30 * * * *", "enabled": false})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	parseJSONResponse(t, w, &sc)
+	if sc.Enabled || sc.Cron != "*/30 * * * *" || sc.Prompt != "triage new issues" {
+		t.Fatalf("unexpected updated schedule %+v", sc)
+	}
+
+	if err := s.CreateScheduleRun(ctx, &store.ScheduleRun{
+		ID: "run-1", ScheduleID: sc.ID, OrgID: "default", Status: "succeeded", Result: "done",
+		ScheduledFor: time.Now(), StartedAt: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var runs []store.ScheduleRun
+	parseJSONResponse(t, do(userToken, http.MethodGet, "/api/schedules/"+sc.ID+"/runs", nil), &runs)
+	if len(runs) != 1 || runs[0].Result != "done" {
+		t.Fatalf("runs: unexpected %+v", runs)
+	}
+
+	if w := do(otherToken, http.MethodDelete, "/api/schedules/"+sc.ID, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("other user delete: expected 404, got %d", w.Code)
+	}
+	if w := do(userToken, http.MethodDelete, "/api/schedules/"+sc.ID, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d", w.Code)
+	}
+	if got, _ := s.GetSchedule(ctx, sc.ID); got != nil {
+		t.Fatal("schedule still exists after delete")
+	}
+}
+
+func TestRunAgent(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	userToken := createTestUserAndGetToken(t, authSvc, s)
+	ctx := context.Background()
+	if _, err := authSvc.Register(ctx, "other", "testpassword123", "user"); err != nil {
+		t.Fatal(err)
+	}
+	otherToken, err := authSvc.Login(ctx, "other", "testpassword123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agentID := "ag-run-" + uuid.New().String()[:8]
+	rtConn := connectTestRuntime(t, srv, agentID)
+
+	// Answer every prompt with two stdout chunks and a completed turn.
+	go func() {
+		for {
+			var env protocol.Envelope
+			_ = rtConn.SetReadDeadline(time.Now().Add(10 * time.Second))
+			if err := rtConn.ReadJSON(&env); err != nil {
+				return
+			}
+			if env.Type != protocol.TypeUserMessage {
+				continue
+			}
+			data, _ := json.Marshal(env.Payload)
+			var msg protocol.UserMessage
+			_ = json.Unmarshal(data, &msg)
+			for _, chunk := range []string{"the answer ", "is 4"} {
+				_ = rtConn.WriteJSON(protocol.Envelope{Type: protocol.TypeAgentOutput, SessionID: msg.SessionID, Payload: protocol.AgentOutput{
+					SessionID: msg.SessionID, Channel: "stdout", Content: chunk,
+				}})
+			}
+			exitCode := 0
+			_ = rtConn.WriteJSON(protocol.Envelope{Type: protocol.TypeTurnCompleted, SessionID: msg.SessionID, Payload: protocol.TurnCompleted{
+				SessionID: msg.SessionID, ExitCode: &exitCode,
+			}})
+		}
+	}()
+
+	do := func(token, method, path string, body any, accept string) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		return w
+	}
+	runPath := "/api/agents/" + agentID + "/run"
+
+	if w := do(userToken, http.MethodPost, runPath, map[string]any{"prompt": " "}, ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("empty prompt: expected 400, got %d", w.Code)
+	}
+	_, offlineAgent := seedAgentAndRuntime(t, s)
+	if w := do(userToken, http.MethodPost, "/api/agents/"+offlineAgent+"/run", map[string]any{"prompt": "hi"}, ""); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("offline runtime: expected 503, got %d", w.Code)
+	}
+
+	// Blocking run.
+	w := do(userToken, http.MethodPost, runPath, map[string]any{"prompt": "what is 2+2?"}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("run: expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	var run agentRun
+	parseJSONResponse(t, w, &run)
+	if run.Status != runSucceeded || run.Output != "the answer is 4" || run.SessionID == "" || run.ExitCode == nil {
+		t.Fatalf("unexpected run %+v", run)
+	}
+	if sess, _ := s.GetSession(ctx, run.SessionID); sess == nil || sess.State != "closed" {
+		t.Fatalf("expected the run's session to be closed, got %+v", sess)
+	}
+
+	// keep_session leaves the session open for follow-up prompts.
+	w = do(userToken, http.MethodPost, runPath, map[string]any{"prompt": "keep going", "keep_session": true}, "")
+	parseJSONResponse(t, w, &run)
+	if sess, _ := s.GetSession(ctx, run.SessionID); sess == nil || sess.State == "closed" {
+		t.Fatalf("expected a kept session to stay open, got %+v", sess)
+	}
+
+	// Fire and poll.
+	w = do(userToken, http.MethodPost, runPath, map[string]any{"prompt": "again", "wait": false}, "")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("async run: expected 202, got %d %s", w.Code, w.Body.String())
+	}
+	parseJSONResponse(t, w, &run)
+	if w := do(otherToken, http.MethodGet, "/api/runs/"+run.ID, nil, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("other user poll: expected 404, got %d", w.Code)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for run.Status == runRunning {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out polling run")
+		}
+		time.Sleep(10 * time.Millisecond)
+		parseJSONResponse(t, do(userToken, http.MethodGet, "/api/runs/"+run.ID, nil, ""), &run)
+	}
+	if run.Status != runSucceeded || run.Output != "the answer is 4" {
+		t.Fatalf("polled run: unexpected %+v", run)
+	}
+
+	// Streamed run.
+	w = do(userToken, http.MethodPost, runPath, map[string]any{"prompt": "stream it"}, "text/event-stream")
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("stream content type = %q", ct)
+	}
+	var events []string
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if ev, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, ev)
+		}
+	}
+	want := []string{"run", protocol.TypeAgentOutput, protocol.TypeAgentOutput, protocol.TypeTurnCompleted}
+	if strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Fatalf("stream events = %v, want %v\n%s", events, want, w.Body.String())
+	}
+}
+
+func TestProvisionRuntimeAgent(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	adminToken := createTestAdminAndGetToken(t, authSvc, s)
+	ctx := context.Background()
+	agentID := "ag-base-" + uuid.New().String()[:8]
+	rtConn := connectTestRuntime(t, srv, agentID)
+	base := protocol.AgentRegistration{ID: agentID, Profile: "generic-cli", Name: "test-agent"}
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		return w
+	}
+	// answer reads the next provisioning request, announces agents when it
+	// succeeds and acknowledges it.
+	answer := func(errMsg string, agents ...protocol.AgentRegistration) <-chan protocol.AgentProvision {
+		got := make(chan protocol.AgentProvision, 1)
+		go func() {
+			_ = rtConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			var env protocol.Envelope
+			if err := rtConn.ReadJSON(&env); err != nil || env.Type != protocol.TypeAgentProvision {
+				close(got)
+				return
+			}
+			data, _ := json.Marshal(env.Payload)
+			var req protocol.AgentProvision
+			_ = json.Unmarshal(data, &req)
+			got <- req
+			if errMsg == "" {
+				_ = rtConn.WriteJSON(protocol.Envelope{
+					Type:    protocol.TypeAgentsAnnounce,
+					Payload: protocol.AgentsAnnounce{Agents: agents},
+				})
+			}
+			_ = rtConn.WriteJSON(protocol.Envelope{
+				Type:    protocol.TypeAgentProvisionAck,
+				Payload: protocol.AgentProvisionAck{RequestID: req.RequestID, AgentID: req.AgentID, OK: errMsg == "", Error: errMsg},
+			})
+		}()
+		return got
+	}
+
+	if w := do(http.MethodPost, "/api/admin/runtimes/rt-missing/agents", `{"id":"x","profile":"generic-cli"}`); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown runtime: expected 404, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/admin/runtimes/rt-1/agents", `{"id":"x"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("missing profile: expected 400, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/admin/runtimes/rt-1/agents", `{"id":"`+agentID+`","profile":"generic-cli"}`); w.Code != http.StatusConflict {
+		t.Fatalf("existing id: expected 409, got %d", w.Code)
+	}
+
+	newID := "ag-new-" + uuid.New().String()[:8]
+	def := `{"id":"` + newID + `","name":"Builder","profile":"generic-cli","cli":{"command":"make"}}`
+	created := protocol.AgentRegistration{ID: newID, Profile: "generic-cli", Name: "Builder"}
+	got := answer("", base, created)
+	w := do(http.MethodPost, "/api/admin/runtimes/rt-1/agents", def)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d %s", w.Code, w.Body.String())
+	}
+	req := <-got
+	if req.Action != protocol.ProvisionCreate || req.AgentID != newID || !strings.Contains(string(req.Config), `"command":"make"`) {
+		t.Fatalf("unexpected provisioning request %+v", req)
+	}
+	var agent store.Agent
+	parseJSONResponse(t, w, &agent)
+	if agent.ID != newID || agent.RuntimeID != "rt-1" || agent.Name != "Builder" {
+		t.Fatalf("unexpected agent %+v", agent)
+	}
+
+	answer(`profile "generic-cli" may not be provisioned remotely`)
+	if w := do(http.MethodPut, "/api/admin/runtimes/rt-1/agents/"+newID, `{"profile":"generic-cli"}`); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "remotely") {
+		t.Fatalf("refused update: expected 409, got %d %s", w.Code, w.Body.String())
+	}
+
+	got = answer("", base)
+	if w := do(http.MethodDelete, "/api/admin/runtimes/rt-1/agents/"+newID, ""); w.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	if req := <-got; req.Action != protocol.ProvisionDelete || len(req.Config) != 0 {
+		t.Fatalf("unexpected delete request %+v", req)
+	}
+	if a, _ := s.GetAgent(ctx, newID); a != nil {
+		t.Fatalf("expected deleted agent to be gone, got %+v", a)
+	}
+	if w := do(http.MethodDelete, "/api/admin/runtimes/rt-1/agents/"+newID, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("delete again: expected 404, got %d", w.Code)
+	}
+
+	events, _ := s.ListAuditEventsFiltered(ctx, "default", store.AuditFilter{Action: "agent.provision", Limit: 10})
+	if len(events) != 2 {
+		t.Fatalf("expected 2 agent.provision audit events, got %d", len(events))
+	}
+}
+
+func TestPersonalAccessTokens(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	userToken := createTestUserAndGetToken(t, authSvc, s)
+
+	do := func(method, path, token string, body any) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		return w
+	}
+
+	// Validation.
+	if w := do(http.MethodPost, "/api/me/tokens", userToken, map[string]any{
+		"name": "ci", "scopes": []string{"sessions:write"},
+	}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown scope, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/me/tokens", userToken, map[string]any{
+		"name": "ci", "scopes": []string{"admin"},
+	}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for admin scope on a user, got %d", w.Code)
+	}
+
+	// Create returns the token once.
+	w := do(http.MethodPost, "/api/me/tokens", userToken, map[string]any{
+		"name": "ci", "scopes": []string{"sessions:read"}, "expires_in_days": 7,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var created tokenResponse
+	parseJSONResponse(t, w, &created)
+	if !strings.HasPrefix(created.Token, auth.PersonalTokenPrefix) || time.Until(created.ExpiresAt) > 7*24*time.Hour {
+		t.Fatalf("unexpected create response %+v", created)
+	}
+
+	// The token reads but cannot write, reach the admin API or mint tokens.
+	if w := do(http.MethodGet, "/api/sessions", created.Token, nil); w.Code != http.StatusOK {
+		t.Fatalf("read with token: expected 200, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/sessions", created.Token, map[string]string{"agent_id": "x"}); w.Code != http.StatusForbidden {
+		t.Fatalf("write with read-only token: expected 403, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/me/tokens", created.Token, map[string]any{
+		"name": "nested", "scopes": []string{"sessions:read"},
+	}); w.Code != http.StatusForbidden {
+		t.Fatalf("create with token: expected 403, got %d", w.Code)
+	}
+
+	// List hides the token and records its use.
+	w = do(http.MethodGet, "/api/me/tokens", userToken, nil)
+	var list []tokenResponse
+	parseJSONResponse(t, w, &list)
+	if len(list) != 1 || list[0].Token != "" || list[0].LastUsedAt == nil {
+		t.Fatalf("unexpected list response %+v", list)
+	}
+
+	// Revoked tokens stop working.
+	if w := do(http.MethodDelete, "/api/me/tokens/"+created.ID, userToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("revoke: expected 200, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/api/sessions", created.Token, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked token: expected 401, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/api/me/tokens/"+created.ID, userToken, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("revoke twice: expected 404, got %d", w.Code)
+	}
+}
+
+func TestPersonalAccessTokenAdminScope(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	adminToken := createTestAdminAndGetToken(t, authSvc, s)
+
+	create := func(scopes ...string) string {
+		b, _ := json.Marshal(map[string]any{"name": "ops", "scopes": scopes})
+		req := httptest.NewRequest(http.MethodPost, "/api/me/tokens", bytes.NewReader(b))
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create: expected 201, got %d; body: %s", w.Code, w.Body.String())
+		}
+		var created tokenResponse
+		parseJSONResponse(t, w, &created)
+		return created.Token
+	}
+
+	for token, want := range map[string]int{
+		create(auth.ScopeSessionsRead): http.StatusForbidden,
+		create(auth.ScopeAdmin):        http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/audit", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("admin API: expected %d, got %d", want, w.Code)
+		}
+	}
+}
+
+func TestTwoFactorAuth(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	adminToken := createTestAdminAndGetToken(t, authSvc, s)
+
+	do := func(method, path, token string, body any) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		return w
+	}
+
+	// Requiring 2FA needs a session that used it.
+	if w := do(http.MethodPut, "/api/admin/org/settings", adminToken, map[string]bool{"require_admin_mfa": true}); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 requiring 2FA without it, got %d", w.Code)
+	}
+
+	// Enroll and confirm.
+	w := do(http.MethodPost, "/api/me/mfa/enroll", adminToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("enroll: expected 200, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var enroll struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}
+	parseJSONResponse(t, w, &enroll)
+	if w := do(http.MethodPost, "/api/me/mfa/confirm", adminToken, map[string]string{"code": "000000"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a wrong code, got %d", w.Code)
+	}
+	code, _ := auth.TOTPCode(enroll.Secret, time.Now())
+	w = do(http.MethodPost, "/api/me/mfa/confirm", adminToken, map[string]string{"code": code})
+	if w.Code != http.StatusOK {
+		t.Fatalf("confirm: expected 200, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+		Token         string   `json:"token"`
+	}
+	parseJSONResponse(t, w, &confirmed)
+	mfaToken := confirmed.Token
+
+	var status map[string]any
+	parseJSONResponse(t, do(http.MethodGet, "/api/me/mfa", mfaToken, nil), &status)
+	if status["enabled"] != true || status["recovery_codes_remaining"] != float64(len(confirmed.RecoveryCodes)) {
+		t.Fatalf("unexpected 2FA status %v", status)
+	}
+
+	// Personal access tokens of an admin with and without 2FA, created before
+	// the requirement.
+	createPAT := func(sessionToken string) string {
+		w := do(http.MethodPost, "/api/me/tokens", sessionToken, map[string]any{"name": "ops", "scopes": []string{auth.ScopeAdmin}})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create token: expected 201, got %d; body: %s", w.Code, w.Body.String())
+		}
+		var created tokenResponse
+		parseJSONResponse(t, w, &created)
+		return created.Token
+	}
+	mfaPAT := createPAT(mfaToken)
+	if _, err := authSvc.Register(context.Background(), "opsadmin", "testpassword123", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	opsToken, err := authSvc.Login(context.Background(), "opsadmin", "testpassword123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opsPAT := createPAT(opsToken)
+
+	// Require 2FA for admins: the old password-only session is shut out of
+	// everything but enrollment.
+	if w := do(http.MethodPut, "/api/admin/org/settings", mfaToken, map[string]bool{"require_admin_mfa": true}); w.Code != http.StatusOK {
+		t.Fatalf("settings: expected 200, got %d; body: %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/api/users", adminToken, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a password-only admin session, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/api/sessions", adminToken, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 on the user API too, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/api/me/mfa", adminToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("expected enrollment status to stay reachable, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/api/users", mfaToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for an MFA session, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/api/users", opsPAT, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for the token of an admin without 2FA, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/api/users", mfaPAT, nil); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for the token of an admin with 2FA, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/me/mfa/disable", mfaToken, map[string]string{"code": confirmed.RecoveryCodes[0]}); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 disabling required 2FA, got %d", w.Code)
+	}
+
+	// Two-step login.
+	w = do(http.MethodPost, "/api/auth/login", "", map[string]string{"username": "testadmin", "password": "testpassword123"})
+	var challenge struct {
+		Token       string `json:"token"`
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
+	}
+	parseJSONResponse(t, w, &challenge)
+	if w.Code != http.StatusOK || !challenge.MFARequired || challenge.Token != "" || challenge.MFAToken == "" {
+		t.Fatalf("expected an MFA challenge, got %d %+v", w.Code, challenge)
+	}
+	if w := do(http.MethodGet, "/api/me", challenge.MFAToken, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the challenge to be refused as a session token, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/auth/login/mfa", "", map[string]string{"mfa_token": challenge.MFAToken, "code": "000000"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong code, got %d", w.Code)
+	}
+	w = do(http.MethodPost, "/api/auth/login/mfa", "", map[string]string{"mfa_token": challenge.MFAToken, "code": confirmed.RecoveryCodes[0]})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login with recovery code: expected 200, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var login map[string]string
+	parseJSONResponse(t, w, &login)
+	if w := do(http.MethodGet, "/api/users", login["token"], nil); w.Code != http.StatusOK {
+		t.Fatalf("expected the second-step token to pass the 2FA requirement, got %d", w.Code)
+	}
+
+	// Wrong codes count toward the account lockout.
+	for i := 0; i < 10; i++ {
+		srv.loginLockout.recordFailure("testadmin")
+	}
+	if w := do(http.MethodPost, "/api/auth/login/mfa", "", map[string]string{"mfa_token": challenge.MFAToken, "code": confirmed.RecoveryCodes[1]}); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 for a locked account, got %d", w.Code)
+	}
+
+	events, err := s.ListAuditEvents(context.Background(), "default", 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, e := range events {
+		seen[e.Action] = true
+	}
+	for _, action := range []string{"mfa.enrolled", "mfa.failed", "org.settings_updated"} {
+		if !seen[action] {
+			t.Errorf("expected a %s audit event", action)
+		}
+	}
+}
+
+func TestUserLifecycle(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	adminToken := createTestAdminAndGetToken(t, authSvc, s)
+	userToken := createTestUserAndGetToken(t, authSvc, s)
+	agentID := "ag-users-" + uuid.New().String()[:8]
+	rtConn := connectTestRuntime(t, srv, agentID)
+	ctx := context.Background()
+	admin, _ := s.GetUser(ctx, "default", "testadmin")
+	user, _ := s.GetUser(ctx, "default", "testuser")
+
+	do := func(method, path, token string, body any) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		return w
+	}
+
+	// Self-service password change.
+	if w := do(http.MethodPost, "/api/me/password", userToken, map[string]string{"current_password": "wrong", "new_password": "newpassword1"}); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a wrong current password, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/me/password", userToken, map[string]string{"current_password": "testpassword123", "new_password": "short"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a short password, got %d", w.Code)
+	}
+	w := do(http.MethodPost, "/api/me/password", userToken, map[string]string{"current_password": "testpassword123", "new_password": "newpassword1"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("change password: expected 200, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var changed map[string]string
+	parseJSONResponse(t, w, &changed)
+	userToken = changed["token"]
+	if w := do(http.MethodGet, "/api/me", userToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("expected the new token to work, got %d", w.Code)
+	}
+
+	// Admin-issued reset link, usable once.
+	if w := do(http.MethodPost, "/api/users/"+user.ID+"/password-reset", userToken, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin, got %d", w.Code)
+	}
+	w = do(http.MethodPost, "/api/users/"+user.ID+"/password-reset", adminToken, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("issue reset: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var reset struct {
+		URL   string `json:"url"`
+		Token string `json:"token"`
+	}
+	parseJSONResponse(t, w, &reset)
+	if reset.URL != srv.baseURL+"/reset-password#token="+reset.Token {
+		t.Fatalf("unexpected reset link %q", reset.URL)
+	}
+	if w := do(http.MethodPost, "/api/auth/password-reset", "", map[string]string{"token": reset.Token, "new_password": "resetpassword"}); w.Code != http.StatusOK {
+		t.Fatalf("reset: expected 200, got %d; body: %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/api/auth/password-reset", "", map[string]string{"token": reset.Token, "new_password": "resetpassword"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 reusing a reset link, got %d", w.Code)
+	}
+	userToken, err := authSvc.Login(ctx, "testuser", "resetpassword")
+	if err != nil {
+		t.Fatalf("login after reset: %v", err)
+	}
+
+	// Guard rails around admins.
+	if w := do(http.MethodPatch, "/api/users/"+admin.ID, adminToken, map[string]string{"role": "user"}); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 demoting yourself, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/api/users/"+admin.ID, adminToken, nil); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 deleting yourself, got %d", w.Code)
+	}
+	if w := do(http.MethodPatch, "/api/users/"+user.ID, adminToken, map[string]string{"role": "owner"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown role, got %d", w.Code)
+	}
+
+	// Disabling shuts the user out even with a valid token.
+	if w := do(http.MethodPatch, "/api/users/"+user.ID, adminToken, map[string]bool{"disabled": true}); w.Code != http.StatusOK {
+		t.Fatalf("disable: expected 200, got %d; body: %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/api/me", userToken, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a disabled user, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/auth/login", "", map[string]string{"username": "testuser", "password": "resetpassword"}); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 logging in while disabled, got %d", w.Code)
+	}
+	if w := do(http.MethodPatch, "/api/users/"+user.ID, adminToken, map[string]bool{"disabled": false}); w.Code != http.StatusOK {
+		t.Fatalf("enable: expected 200, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/api/me", userToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("expected the token to work again once enabled, got %d", w.Code)
+	}
+
+	// Deleting closes sessions and revokes grants.
+	sessID := uuid.New().String()
+	if err := s.CreateSession(ctx, &store.Session{
+		ID: sessID, OrgID: "default", UserID: user.ID, AgentID: agentID, RuntimeID: "rt-1",
+		Profile: "default", State: "active", CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.GrantAgentAccess(ctx, user.ID, agentID); err != nil {
+		t.Fatal(err)
+	}
+	w = do(http.MethodDelete, "/api/users/"+user.ID, adminToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var deleted map[string]any
+	parseJSONResponse(t, w, &deleted)
+	if deleted["sessions_closed"] != float64(1) {
+		t.Fatalf("expected one session closed, got %v", deleted)
+	}
+	if sess, _ := s.GetSession(ctx, sessID); sess == nil || sess.State != "closed" {
+		t.Fatalf("expected the session to be closed, got %+v", sess)
+	}
+	// The runtime is told, so the agent process does not outlive the user.
+	for {
+		_ = rtConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var env protocol.Envelope
+		if err := rtConn.ReadJSON(&env); err != nil {
+			t.Fatalf("expected session.close on the runtime: %v", err)
+		}
+		if env.Type == protocol.TypeSessionClose && env.SessionID == sessID {
+			break
+		}
+	}
+	if ok, _ := s.HasAgentAccess(ctx, user.ID, agentID); ok {
+		t.Fatal("expected agent grants to be revoked")
+	}
+	if w := do(http.MethodGet, "/api/me", userToken, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a deleted user, got %d", w.Code)
+	}
+	var users []store.User
+	parseJSONResponse(t, do(http.MethodGet, "/api/users", adminToken, nil), &users)
+	for _, u := range users {
+		if u.ID == user.ID {
+			t.Fatal("expected the deleted user to be hidden")
+		}
+	}
+	if w := do(http.MethodDelete, "/api/users/"+user.ID, adminToken, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 deleting twice, got %d", w.Code)
+	}
+	// The name is free again.
+	if _, err := authSvc.Register(ctx, "testuser", "testpassword123", "user"); err != nil {
+		t.Fatalf("re-register: %v", err)
+	}
+
+	events, err := s.ListAuditEvents(ctx, "default", 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, e := range events {
+		seen[e.Action] = true
+	}
+	for _, action := range []string{"user.password_changed", "user.password_reset_issued", "user.password_reset", "user.update", "user.delete"} {
+		if !seen[action] {
+			t.Errorf("expected a %s audit event", action)
+		}
+	}
+}
+
+func TestGroups(t *testing.T) {
+	srv, authSvc, s := setupTestServer(t)
+	srv.defaultAgentAccess = "none"
+	adminToken := createTestAdminAndGetToken(t, authSvc, s)
+	userToken := createTestUserAndGetToken(t, authSvc, s)
+	runtimeID, plainAgent := seedAgentAndRuntime(t, s)
+	ctx := context.Background()
+	user, _ := s.GetUser(ctx, "default", "testuser")
+
+	stagingAgent := "ag-staging"
+	if err := s.UpsertAgent(ctx, &store.Agent{
+		ID: stagingAgent, OrgID: "default", RuntimeID: runtimeID, Profile: "default", Name: "staging",
+		Tags: `{"env":"staging"}`, Caps: "{}", Security: "{}",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, path, token string, body any) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			r = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, r)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		return w
+	}
+	visibleAgents := func() []string {
+		var agents []struct {
+			ID string `json:"id"`
+		}
+		parseJSONResponse(t, do(http.MethodGet, "/api/agents", userToken, nil), &agents)
+		ids := make([]string, 0, len(agents))
+		for _, a := range agents {
+			ids = append(ids, a.ID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	if w := do(http.MethodPost, "/api/admin/groups", userToken, map[string]string{"name": "devs"}); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin, got %d", w.Code)
+	}
+	w := do(http.MethodPost, "/api/admin/groups", adminToken, map[string]string{"name": "devs", "description": "Developers"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create group: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var group store.Group
+	parseJSONResponse(t, w, &group)
+	if w := do(http.MethodPost, "/api/admin/groups", adminToken, map[string]string{"name": "Devs"}); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a duplicate name, got %d", w.Code)
+	}
+	base := "/api/admin/groups/" + group.ID
+
+	// Grants need exactly one target.
+	if w := do(http.MethodPost, base+"/grants", adminToken, map[string]string{"agent_id": plainAgent, "tag_key": "env"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an ambiguous grant, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, base+"/grants", adminToken, map[string]string{"tag_key": "env", "tag_value": "staging"}); w.Code != http.StatusCreated {
+		t.Fatalf("tag grant: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, base+"/grants", adminToken, map[string]string{"tag_key": "env", "tag_value": "staging"}); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a duplicate grant, got %d", w.Code)
+	}
+
+	// Access follows membership.
+	if got := visibleAgents(); len(got) != 0 {
+		t.Fatalf("expected no agents before joining, got %v", got)
+	}
+	if w := do(http.MethodPost, base+"/members", adminToken, map[string]string{"user_id": user.ID}); w.Code != http.StatusCreated {
+		t.Fatalf("add member: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	if got := visibleAgents(); len(got) != 1 || got[0] != stagingAgent {
+		t.Fatalf("expected the tagged agent, got %v", got)
+	}
+
+	w = do(http.MethodPost, base+"/grants", adminToken, map[string]string{"agent_id": plainAgent})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("agent grant: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var agentGrant store.GroupGrant
+	parseJSONResponse(t, w, &agentGrant)
+	if got := visibleAgents(); len(got) != 2 {
+		t.Fatalf("expected both agents, got %v", got)
+	}
+
+	var detail struct {
+		Group   store.Group         `json:"group"`
+		Members []store.GroupMember `json:"members"`
+		Grants  []store.GroupGrant  `json:"grants"`
+	}
+	parseJSONResponse(t, do(http.MethodGet, base, adminToken, nil), &detail)
+	if len(detail.Members) != 1 || detail.Members[0].Username != "testuser" || len(detail.Grants) != 2 {
+		t.Fatalf("unexpected group detail %+v", detail)
+	}
+	var perms struct {
+		AgentIDs []string      `json:"agent_ids"`
+		Groups   []store.Group `json:"groups"`
+	}
+	parseJSONResponse(t, do(http.MethodGet, "/api/users/"+user.ID+"/permissions", adminToken, nil), &perms)
+	if len(perms.AgentIDs) != 2 || len(perms.Groups) != 1 {
+		t.Fatalf("expected effective permissions through the group, got %+v", perms)
+	}
+
+	if w := do(http.MethodDelete, base+"/grants/"+agentGrant.ID, adminToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete grant: expected 200, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, base+"/grants/"+agentGrant.ID, adminToken, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 deleting a grant twice, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, base+"/members/"+user.ID, adminToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("remove member: expected 200, got %d", w.Code)
+	}
+	if got := visibleAgents(); len(got) != 0 {
+		t.Fatalf("expected no agents after leaving, got %v", got)
+	}
+
+	if w := do(http.MethodPut, base, adminToken, map[string]string{"name": "platform"}); w.Code != http.StatusOK {
+		t.Fatalf("update group: expected 200, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, base, adminToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete group: expected 200, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, base, adminToken, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a deleted group, got %d", w.Code)
+	}
+
+	events, err := s.ListAuditEvents(ctx, "default", 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, e := range events {
+		seen[e.Action] = true
+	}
+	for _, action := range []string{"group.created", "group.updated", "group.deleted", "group.member_added",
+		"group.member_removed", "group.grant_added", "group.grant_removed"} {
+		if !seen[action] {
+			t.Errorf("expected a %s audit event", action)
+		}
+	}
+}
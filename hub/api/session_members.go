@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// --- Session sharing handlers ---
+
+func (s *Server) handleListSessionMembers(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+	sess, err := s.store.GetSession(r.Context(), chi.URLParam(r, "sessionID"))
+	if err != nil || sess == nil || sess.OrgID != identity.OrgID {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if !s.router.CanViewSession(r.Context(), sess, identity.UserID, identity.Role) {
+		writeError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	members, err := s.store.ListSessionMembers(r.Context(), sess.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list session members")
+		return
+	}
+	if members == nil {
+		members = []store.SessionMember{}
+	}
+	writeJSON(w, http.StatusOK, members)
+}
+
+func (s *Server) handleAddSessionMember(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+
+	sess, err := s.store.GetSession(r.Context(), chi.URLParam(r, "sessionID"))
+	if err != nil || sess == nil || sess.OrgID != identity.OrgID {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	// Only the owner (or an admin) decides who the session is shared with.
+	if sess.UserID != identity.UserID && identity.Role != "admin" {
+		writeError(w, http.StatusForbidden, "not your session")
+		return
+	}
+
+	var req struct {
+		UserID   string `json:"user_id"`
+		Username string `json:"username"`
+		Role     string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Role != store.MemberObserver && req.Role != store.MemberCollaborator {
+		writeError(w, http.StatusBadRequest, "role must be observer or collaborator")
+		return
+	}
+
+	var user *store.User
+	switch {
+	case req.UserID != "":
+		user, err = s.store.GetUserByID(r.Context(), req.UserID)
+	case req.Username != "":
+		user, err = s.store.GetUser(r.Context(), identity.OrgID, req.Username)
+	default:
+		writeError(w, http.StatusBadRequest, "user_id or username is required")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to look up user")
+		return
+	}
+	if user == nil || user.OrgID != identity.OrgID {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if user.ID == sess.UserID {
+		writeError(w, http.StatusBadRequest, "the session owner cannot be added as a member")
+		return
+	}
+
+	m := &store.SessionMember{
+		SessionID: sess.ID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Role:      req.Role,
+		AddedBy:   identity.UserID,
+		CreatedAt: time.Now(),
+	}
+	if err := s.store.AddSessionMember(r.Context(), m); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to add session member")
+		return
+	}
+	s.logSessionMemberEvent(r, "session.member_added", sess, user.ID, req.Role)
+	writeJSON(w, http.StatusCreated, m)
+}
+
+func (s *Server) handleRemoveSessionMember(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+	userID := chi.URLParam(r, "userID")
+
+	sess, err := s.store.GetSession(r.Context(), chi.URLParam(r, "sessionID"))
+	if err != nil || sess == nil || sess.OrgID != identity.OrgID {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	// Owners and admins may remove anyone; members may leave on their own.
+	if sess.UserID != identity.UserID && identity.Role != "admin" && userID != identity.UserID {
+		writeError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	m, err := s.store.GetSessionMember(r.Context(), sess.ID, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get session member")
+		return
+	}
+	if m == nil {
+		writeError(w, http.StatusNotFound, "member not found")
+		return
+	}
+	if err := s.store.RemoveSessionMember(r.Context(), sess.ID, userID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to remove session member")
+		return
+	}
+	s.router.UnsubscribeUser(sess.ID, userID)
+	s.logSessionMemberEvent(r, "session.member_removed", sess, userID, m.Role)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "removed"})
+}
+
+func (s *Server) logSessionMemberEvent(r *http.Request, action string, sess *store.Session, memberID, role string) {
+	identity := getIdentityFromContext(r.Context())
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: action,
+		UserID: identity.UserID, SessionID: sess.ID, AgentID: sess.AgentID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"member_id":%q,"role":%q}`, memberID, role)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", action, "error", err)
+	}
+}
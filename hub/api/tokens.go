@@ -0,0 +1,209 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/amurg-ai/amurg/hub/auth"
+	"github.com/amurg-ai/amurg/hub/store"
+)
+
+const (
+	// maxTokensPerUser caps personal access tokens per user.
+	maxTokensPerUser = 50
+	// defaultTokenExpiryDays applies when a token is created without an expiry.
+	defaultTokenExpiryDays = 90
+	// maxTokenExpiryDays is the longest a personal access token may live.
+	maxTokenExpiryDays = 365
+)
+
+// --- Personal access token handlers ---
+
+// tokenResponse is the API view of a personal access token. The token itself
+// is only included in the response to the request that created it.
+type tokenResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	Token      string     `json:"token,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+func toTokenResponse(t *store.UserToken, token string) tokenResponse {
+	scopes := []string{}
+	_ = json.Unmarshal([]byte(t.Scopes), &scopes)
+	return tokenResponse{
+		ID:         t.ID,
+		Name:       t.Name,
+		Scopes:     scopes,
+		Token:      token,
+		ExpiresAt:  t.ExpiresAt,
+		CreatedAt:  t.CreatedAt,
+		LastUsedAt: t.LastUsedAt,
+	}
+}
+
+// validateTokenScopes checks requested scopes against the known ones and the
+// caller's role.
+func validateTokenScopes(scopes []string, role string) string {
+	if len(scopes) == 0 {
+		return "at least one scope is required"
+	}
+	seen := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		known := false
+		for _, s := range auth.Scopes {
+			known = known || s == scope
+		}
+		if !known {
+			return fmt.Sprintf("unknown scope %q: use %q, %q or %q", scope, auth.ScopeSessionsRead, auth.ScopeMessagesSend, auth.ScopeAdmin)
+		}
+		if scope == auth.ScopeAdmin && role != "admin" {
+			return "only admins can create tokens with the admin scope"
+		}
+		if seen[scope] {
+			return fmt.Sprintf("duplicate scope %q", scope)
+		}
+		seen[scope] = true
+	}
+	return ""
+}
+
+// handleListTokens handles GET /api/me/tokens.
+func (s *Server) handleListTokens(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+	tokens, err := s.store.ListUserTokens(r.Context(), identity.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list tokens")
+		return
+	}
+	resp := make([]tokenResponse, 0, len(tokens))
+	for i := range tokens {
+		resp = append(resp, toTokenResponse(&tokens[i], ""))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleCreateToken handles POST /api/me/tokens. Tokens can only be created
+// from an interactive sign-in, not with another personal access token.
+func (s *Server) handleCreateToken(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+	if identity.TokenID != "" {
+		writeError(w, http.StatusForbidden, "personal access tokens cannot manage tokens")
+		return
+	}
+
+	var req struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Name == "" || len(req.Name) > 64 {
+		writeError(w, http.StatusBadRequest, "name must be 1-64 characters")
+		return
+	}
+	if msg := validateTokenScopes(req.Scopes, identity.Role); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultTokenExpiryDays
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxTokenExpiryDays {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("expires_in_days must be between 1 and %d", maxTokenExpiryDays))
+		return
+	}
+
+	existing, err := s.store.ListUserTokens(r.Context(), identity.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create token")
+		return
+	}
+	if len(existing) >= maxTokensPerUser {
+		writeError(w, http.StatusConflict, fmt.Sprintf("token limit reached (%d); revoke an unused token first", maxTokensPerUser))
+		return
+	}
+
+	token, hash, err := auth.GeneratePersonalToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create token")
+		return
+	}
+	scopesJSON, _ := json.Marshal(req.Scopes)
+	now := time.Now()
+	t := &store.UserToken{
+		ID:        uuid.New().String(),
+		OrgID:     identity.OrgID,
+		UserID:    identity.UserID,
+		Name:      req.Name,
+		TokenHash: hash,
+		Scopes:    string(scopesJSON),
+		ExpiresAt: now.Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour),
+		CreatedAt: now,
+	}
+	if err := s.store.CreateUserToken(r.Context(), t); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create token")
+		return
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "token.created", UserID: identity.UserID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"token_id":%q,"name":%q,"scopes":%s}`, t.ID, t.Name, t.Scopes)),
+		CreatedAt: now,
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "token.created", "error", err)
+	}
+
+	writeJSON(w, http.StatusCreated, toTokenResponse(t, token))
+}
+
+// handleDeleteToken handles DELETE /api/me/tokens/{tokenID}.
+func (s *Server) handleDeleteToken(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+	if identity.TokenID != "" {
+		writeError(w, http.StatusForbidden, "personal access tokens cannot manage tokens")
+		return
+	}
+
+	tokens, err := s.store.ListUserTokens(r.Context(), identity.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to revoke token")
+		return
+	}
+	var t *store.UserToken
+	for i := range tokens {
+		if tokens[i].ID == chi.URLParam(r, "tokenID") {
+			t = &tokens[i]
+		}
+	}
+	if t == nil {
+		writeError(w, http.StatusNotFound, "token not found")
+		return
+	}
+	if err := s.store.DeleteUserToken(r.Context(), t.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to revoke token")
+		return
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "token.revoked", UserID: identity.UserID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"token_id":%q,"name":%q}`, t.ID, t.Name)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "token.revoked", "error", err)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
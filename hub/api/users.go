@@ -0,0 +1,293 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/amurg-ai/amurg/hub/auth"
+	"github.com/amurg-ai/amurg/hub/store"
+)
+
+// --- User lifecycle handlers ---
+
+// lifecycleUser loads the user named in the URL, which must belong to the
+// caller's org and not be deleted.
+func (s *Server) lifecycleUser(w http.ResponseWriter, r *http.Request) (*store.User, bool) {
+	identity := getIdentityFromContext(r.Context())
+	user, err := s.store.GetUserByID(r.Context(), chi.URLParam(r, "userID"))
+	if err != nil || user == nil || user.OrgID != identity.OrgID || user.DeletedAt != nil {
+		writeError(w, http.StatusNotFound, "user not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// isLastAdmin reports whether user is the org's only enabled admin.
+func (s *Server) isLastAdmin(ctx context.Context, user *store.User) (bool, error) {
+	if user.Role != "admin" || user.Disabled {
+		return false, nil
+	}
+	users, err := s.store.ListUsers(ctx, user.OrgID)
+	if err != nil {
+		return false, err
+	}
+	for _, u := range users {
+		if u.ID != user.ID && u.Role == "admin" && !u.Disabled {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// handleUpdateUser handles PATCH /api/users/{userID}: change a user's role or
+// disable and re-enable them. Disabled users are signed out everywhere; their
+// tokens are refused until they are enabled again.
+func (s *Server) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+	user, ok := s.lifecycleUser(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Role     *string `json:"role"`
+		Disabled *bool   `json:"disabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Role == nil && req.Disabled == nil {
+		writeError(w, http.StatusBadRequest, "role or disabled is required")
+		return
+	}
+	if req.Role != nil && s.externalAuth() {
+		writeError(w, http.StatusBadRequest, "roles come from the identity provider")
+		return
+	}
+	if req.Role != nil && *req.Role != "user" && *req.Role != "admin" {
+		writeError(w, http.StatusBadRequest, "role must be 'user' or 'admin'")
+		return
+	}
+
+	before := *user
+	if req.Role != nil {
+		user.Role = *req.Role
+	}
+	if req.Disabled != nil {
+		user.Disabled = *req.Disabled
+	}
+	losesAdmin := before.Role == "admin" && !before.Disabled && (user.Role != "admin" || user.Disabled)
+	if losesAdmin {
+		if user.ID == identity.UserID {
+			writeError(w, http.StatusConflict, "you cannot demote or disable yourself")
+			return
+		}
+		last, err := s.isLastAdmin(r.Context(), &before)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to update user")
+			return
+		}
+		if last {
+			writeError(w, http.StatusConflict, "cannot demote or disable the last admin")
+			return
+		}
+	}
+
+	if err := s.store.UpdateUser(r.Context(), user); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update user")
+		return
+	}
+	// Open connections carry the old role; drop them so they re-authenticate.
+	if user.Disabled || user.Role != before.Role {
+		s.router.DisconnectUser(user.ID)
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "user.update", UserID: identity.UserID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"target_user_id":%q,"role":%q,"disabled":%t}`, user.ID, user.Role, user.Disabled)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "user.update", "error", err)
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// handleDeleteUser handles DELETE /api/users/{userID}. The user's active
+// sessions are closed and their agent grants, tokens and memberships revoked.
+// Their transcripts stay, still attributed to them.
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+	user, ok := s.lifecycleUser(w, r)
+	if !ok {
+		return
+	}
+	if user.ID == identity.UserID {
+		writeError(w, http.StatusConflict, "you cannot delete yourself")
+		return
+	}
+	last, err := s.isLastAdmin(r.Context(), user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete user")
+		return
+	}
+	if last {
+		writeError(w, http.StatusConflict, "cannot delete the last admin")
+		return
+	}
+
+	sessions, err := s.store.ListSessionsByUser(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete user")
+		return
+	}
+	if err := s.store.DeleteUser(r.Context(), user.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete user")
+		return
+	}
+	s.router.DisconnectUser(user.ID)
+
+	closed := 0
+	for _, sess := range sessions {
+		if sess.State == "closed" {
+			continue
+		}
+		if err := s.router.CloseSession(r.Context(), sess.ID, "user deleted"); err != nil {
+			s.logger.Warn("failed to close session of deleted user", "session_id", sess.ID, "error", err)
+			continue
+		}
+		closed++
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "user.delete", UserID: identity.UserID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"target_user_id":%q,"username":%q,"sessions_closed":%d}`, user.ID, user.Username, closed)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "user.delete", "error", err)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"status": "deleted", "sessions_closed": closed})
+}
+
+// handleChangePassword handles POST /api/me/password. Wrong current passwords
+// count toward the account lockout. The response carries a new session token;
+// every other session of the user is signed out.
+func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+	if identity.TokenID != "" {
+		writeError(w, http.StatusForbidden, "personal access tokens cannot change the password")
+		return
+	}
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.NewPassword) < 8 || len(req.NewPassword) > 128 {
+		writeError(w, http.StatusBadRequest, "password must be 8-128 characters")
+		return
+	}
+	if s.loginLockout.isLocked(identity.Username) {
+		writeError(w, http.StatusTooManyRequests, "account temporarily locked due to too many failed attempts")
+		return
+	}
+
+	token, err := s.loginProvider.ChangePassword(r.Context(), identity.UserID, req.CurrentPassword, req.NewPassword, identity.MFA)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		s.loginLockout.recordFailure(identity.Username)
+		writeError(w, http.StatusForbidden, "current password is incorrect")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to change password")
+		return
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "user.password_changed", UserID: identity.UserID,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "user.password_changed", "error", err)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"token": token})
+}
+
+// handleIssuePasswordReset handles POST /api/users/{userID}/password-reset.
+// It returns a one-time link for the admin to pass on; the hub sends nothing.
+func (s *Server) handleIssuePasswordReset(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+	user, ok := s.lifecycleUser(w, r)
+	if !ok {
+		return
+	}
+	token, expiresAt, err := s.loginProvider.IssuePasswordReset(r.Context(), user.ID, identity.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create reset link")
+		return
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "user.password_reset_issued", UserID: identity.UserID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"target_user_id":%q}`, user.ID)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "user.password_reset_issued", "error", err)
+	}
+
+	// The token travels in the fragment so it never reaches server logs.
+	link := s.baseURL + "/reset-password#" + url.Values{"token": {token}}.Encode()
+	writeJSON(w, http.StatusCreated, map[string]any{"url": link, "token": token, "expires_at": expiresAt})
+}
+
+// handleResetPassword handles POST /api/auth/password-reset, redeeming a reset
+// link. It needs no session and is rate limited like login.
+func (s *Server) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	var req struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.NewPassword) < 8 || len(req.NewPassword) > 128 {
+		writeError(w, http.StatusBadRequest, "password must be 8-128 characters")
+		return
+	}
+
+	user, err := s.loginProvider.ResetPassword(r.Context(), req.Token, req.NewPassword)
+	if errors.Is(err, auth.ErrResetInvalid) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to reset password")
+		return
+	}
+	s.loginLockout.recordSuccess(user.Username)
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: user.OrgID, Action: "user.password_reset", UserID: user.ID,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "user.password_reset", "error", err)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "username": user.Username})
+}
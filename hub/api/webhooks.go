@@ -0,0 +1,270 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/amurg-ai/amurg/hub/webhook"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// --- Webhook handlers (admin only) ---
+
+// webhookResponse is the API view of a webhook. The signing secret is only
+// included in the response to the request that created or rotated it.
+type webhookResponse struct {
+	ID          string    `json:"id"`
+	OrgID       string    `json:"org_id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description"`
+	Enabled     bool      `json:"enabled"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func toWebhookResponse(wh *store.Webhook, includeSecret bool) webhookResponse {
+	events := []string{}
+	_ = json.Unmarshal([]byte(wh.Events), &events)
+	resp := webhookResponse{
+		ID:          wh.ID,
+		OrgID:       wh.OrgID,
+		URL:         wh.URL,
+		Events:      events,
+		Description: wh.Description,
+		Enabled:     wh.Enabled,
+		CreatedBy:   wh.CreatedBy,
+		CreatedAt:   wh.CreatedAt,
+		UpdatedAt:   wh.UpdatedAt,
+	}
+	if includeSecret {
+		resp.Secret = wh.Secret
+	}
+	return resp
+}
+
+// validateWebhookRequest checks the target URL and event filter.
+func validateWebhookRequest(rawURL string, events []string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "url must be an absolute http or https URL"
+	}
+	if len(events) == 0 {
+		return "events must not be empty"
+	}
+	for _, e := range events {
+		if !webhook.ValidEventType(e) {
+			return fmt.Sprintf("unknown event type %q", e)
+		}
+	}
+	return ""
+}
+
+// getOrgWebhook loads a webhook by URL param and writes 404 unless it belongs to the caller's org.
+func (s *Server) getOrgWebhook(w http.ResponseWriter, r *http.Request) *store.Webhook {
+	identity := getIdentityFromContext(r.Context())
+	wh, err := s.store.GetWebhook(r.Context(), chi.URLParam(r, "webhookID"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get webhook")
+		return nil
+	}
+	if wh == nil || wh.OrgID != identity.OrgID {
+		writeError(w, http.StatusNotFound, "webhook not found")
+		return nil
+	}
+	return wh
+}
+
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+	webhooks, err := s.store.ListWebhooks(r.Context(), identity.OrgID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list webhooks")
+		return
+	}
+	result := make([]webhookResponse, 0, len(webhooks))
+	for i := range webhooks {
+		result = append(result, toWebhookResponse(&webhooks[i], false))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+
+	var req struct {
+		URL         string   `json:"url"`
+		Events      []string `json:"events"`
+		Description string   `json:"description"`
+		Secret      string   `json:"secret"`
+		Enabled     *bool    `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if msg := validateWebhookRequest(req.URL, req.Events); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if req.Secret == "" {
+		req.Secret = generateHexToken(32)
+	}
+	eventsJSON, _ := json.Marshal(req.Events)
+
+	now := time.Now()
+	wh := &store.Webhook{
+		ID:          uuid.New().String(),
+		OrgID:       identity.OrgID,
+		URL:         req.URL,
+		Secret:      req.Secret,
+		Events:      string(eventsJSON),
+		Description: req.Description,
+		Enabled:     req.Enabled == nil || *req.Enabled,
+		CreatedBy:   identity.UserID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.store.CreateWebhook(r.Context(), wh); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create webhook")
+		return
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "webhook.created", UserID: identity.UserID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"webhook_id":%q,"url":%q}`, wh.ID, wh.URL)),
+		CreatedAt: now,
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "webhook.created", "error", err)
+	}
+
+	writeJSON(w, http.StatusCreated, toWebhookResponse(wh, true))
+}
+
+func (s *Server) handleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	wh := s.getOrgWebhook(w, r)
+	if wh == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, toWebhookResponse(wh, false))
+}
+
+func (s *Server) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	identity := getIdentityFromContext(r.Context())
+	wh := s.getOrgWebhook(w, r)
+	if wh == nil {
+		return
+	}
+
+	var req struct {
+		URL          *string   `json:"url"`
+		Events       *[]string `json:"events"`
+		Description  *string   `json:"description"`
+		Enabled      *bool     `json:"enabled"`
+		RotateSecret bool      `json:"rotate_secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	// Merge with the stored webhook so a partial PUT does not wipe unspecified fields.
+	rawURL := wh.URL
+	if req.URL != nil {
+		rawURL = *req.URL
+	}
+	var events []string
+	_ = json.Unmarshal([]byte(wh.Events), &events)
+	if req.Events != nil {
+		events = *req.Events
+	}
+	if msg := validateWebhookRequest(rawURL, events); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	eventsJSON, _ := json.Marshal(events)
+	wh.URL = rawURL
+	wh.Events = string(eventsJSON)
+	if req.Description != nil {
+		wh.Description = *req.Description
+	}
+	if req.Enabled != nil {
+		wh.Enabled = *req.Enabled
+	}
+	if req.RotateSecret {
+		wh.Secret = generateHexToken(32)
+	}
+	wh.UpdatedAt = time.Now()
+
+	if err := s.store.UpdateWebhook(r.Context(), wh); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update webhook")
+		return
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "webhook.updated", UserID: identity.UserID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"webhook_id":%q,"enabled":%t,"secret_rotated":%t}`, wh.ID, wh.Enabled, req.RotateSecret)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "webhook.updated", "error", err)
+	}
+
+	writeJSON(w, http.StatusOK, toWebhookResponse(wh, req.RotateSecret))
+}
+
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	identity := getIdentityFromContext(r.Context())
+	wh := s.getOrgWebhook(w, r)
+	if wh == nil {
+		return
+	}
+	if err := s.store.DeleteWebhook(r.Context(), wh.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete webhook")
+		return
+	}
+
+	if err := s.store.LogAuditEvent(r.Context(), &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: identity.OrgID, Action: "webhook.deleted", UserID: identity.UserID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"webhook_id":%q,"url":%q}`, wh.ID, wh.URL)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", "webhook.deleted", "error", err)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (s *Server) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	wh := s.getOrgWebhook(w, r)
+	if wh == nil {
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	deliveries, err := s.store.ListWebhookDeliveries(r.Context(), wh.ID, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list deliveries")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []store.WebhookDelivery{}
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
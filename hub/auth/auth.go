@@ -23,6 +23,7 @@ var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserExists         = errors.New("user already exists")
 	ErrUnauthorized       = errors.New("unauthorized")
+	ErrUserDisabled       = errors.New("user is disabled")
 )
 
 // Claims represents the JWT token claims.
@@ -30,6 +31,8 @@ type Claims struct {
 	UserID   string `json:"uid"`
 	Username string `json:"usr"`
 	Role     string `json:"role"`
+	MFA      bool   `json:"mfa,omitempty"` // signed in with a second factor
+	OrgID    string `json:"org,omitempty"` // set for users of an external identity provider
 	jwt.RegisteredClaims
 }
 
@@ -177,8 +180,23 @@ func (s *Service) Login(ctx context.Context, username, password string) (string,
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
 		return "", ErrInvalidCredentials
 	}
+	if user.Disabled {
+		return "", ErrUserDisabled
+	}
+
+	mfa, err := s.store.GetUserMFA(ctx, user.ID)
+	if err != nil {
+		return "", fmt.Errorf("get mfa: %w", err)
+	}
+	if mfa != nil && mfa.Enabled {
+		challenge, err := s.generateMFAChallenge(user)
+		if err != nil {
+			return "", err
+		}
+		return "", &MFARequiredError{Challenge: challenge}
+	}
 
-	return s.generateToken(user)
+	return s.generateToken(user, false)
 }
 
 // Register creates a new user account.
@@ -222,6 +240,10 @@ func (s *Service) Register(ctx context.Context, username, password, role string)
 // ValidateToken validates a bearer token and returns an Identity.
 // This implements the Provider interface.
 func (s *Service) ValidateToken(ctx context.Context, tokenStr string) (*Identity, error) {
+	if IsPersonalToken(tokenStr) {
+		return s.ValidatePersonalToken(ctx, tokenStr)
+	}
+
 	claims, err := s.validateJWT(tokenStr)
 	if err != nil {
 		return nil, err
@@ -237,7 +259,11 @@ func (s *Service) ValidateToken(ctx context.Context, tokenStr string) (*Identity
 	if err != nil || user == nil {
 		return nil, ErrUnauthorized
 	}
-	if user.Role != claims.Role {
+	if user.Role != claims.Role || user.Disabled {
+		return nil, ErrUnauthorized
+	}
+	// Tokens issued before the last password change are no longer valid.
+	if user.PasswordChangedAt != nil && (claims.IssuedAt == nil || claims.IssuedAt.Unix() < user.PasswordChangedAt.Unix()) {
 		return nil, ErrUnauthorized
 	}
 
@@ -246,6 +272,7 @@ func (s *Service) ValidateToken(ctx context.Context, tokenStr string) (*Identity
 		Username: user.Username,
 		Role:     user.Role,
 		OrgID:    "default",
+		MFA:      claims.MFA,
 	}, nil
 }
 
@@ -305,11 +332,34 @@ func (s *Service) ValidateRuntimeToken(runtimeID, token string) bool {
 	return hmac.Equal([]byte(expected), []byte(token))
 }
 
-func (s *Service) generateToken(user *store.User) (string, error) {
+func (s *Service) generateToken(user *store.User, mfa bool) (string, error) {
 	claims := &Claims{
 		UserID:   user.ID,
 		Username: user.Username,
 		Role:     user.Role,
+		MFA:      mfa,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.jwtExpiry)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        uuid.New().String(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(s.jwtSecret)
+}
+
+// generateExternalToken issues a hub session token for an identity an
+// external identity provider has just vouched for.
+func (s *Service) generateExternalToken(identity *Identity) (string, error) {
+	if len(s.jwtSecret) == 0 {
+		return "", errors.New("auth.jwt_secret is not set")
+	}
+	claims := &Claims{
+		UserID:   identity.UserID,
+		Username: identity.Username,
+		Role:     identity.Role,
+		OrgID:    identity.OrgID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.jwtExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -210,6 +211,208 @@ func TestExpiredToken(t *testing.T) {
 	}
 }
 
+func TestValidatePersonalToken(t *testing.T) {
+	svc, s := newTestAuthService(t)
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := func(expiresAt time.Time) string {
+		token, hash, err := GeneratePersonalToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CreateUserToken(ctx, &store.UserToken{
+			ID: hash[:12], OrgID: "default", UserID: user.ID, Name: "ci", TokenHash: hash,
+			Scopes: `["sessions:read"]`, ExpiresAt: expiresAt, CreatedAt: time.Now(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	identity, err := svc.ValidateToken(ctx, issue(time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if identity.UserID != user.ID || identity.TokenID == "" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+	if !identity.HasScope(ScopeSessionsRead) || identity.HasScope(ScopeMessagesSend) {
+		t.Fatalf("unexpected scopes %v", identity.Scopes)
+	}
+
+	if _, err := svc.ValidateToken(ctx, issue(time.Now().Add(-time.Minute))); err != ErrUnauthorized {
+		t.Fatalf("expired token: expected ErrUnauthorized, got %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, PersonalTokenPrefix+"unknown"); err != ErrUnauthorized {
+		t.Fatalf("unknown token: expected ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestMFALogin(t *testing.T) {
+	svc, _ := newTestAuthService(t)
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "alice", "password123", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, uri, err := svc.BeginTOTPEnrollment(ctx, user.ID, user.Username)
+	if err != nil {
+		t.Fatalf("BeginTOTPEnrollment: %v", err)
+	}
+	if !strings.HasPrefix(uri, "otpauth://totp/Amurg:alice?") || !strings.Contains(uri, "secret="+secret) {
+		t.Fatalf("unexpected otpauth URI %s", uri)
+	}
+
+	// An unconfirmed enrollment does not change the login.
+	if _, err := svc.Login(ctx, "alice", "password123"); err != nil {
+		t.Fatalf("login before confirmation: %v", err)
+	}
+
+	if _, _, err := svc.ConfirmTOTPEnrollment(ctx, user.ID, "000000"); err != ErrMFAInvalidCode {
+		t.Fatalf("expected ErrMFAInvalidCode, got %v", err)
+	}
+	code, _ := TOTPCode(secret, time.Now())
+	recovery, token, err := svc.ConfirmTOTPEnrollment(ctx, user.ID, code)
+	if err != nil {
+		t.Fatalf("ConfirmTOTPEnrollment: %v", err)
+	}
+	if len(recovery) != recoveryCodeCount {
+		t.Fatalf("expected %d recovery codes, got %d", recoveryCodeCount, len(recovery))
+	}
+	if identity, err := svc.ValidateToken(ctx, token); err != nil || !identity.MFA {
+		t.Fatalf("expected the confirmation token to carry MFA, got %+v, %v", identity, err)
+	}
+
+	// The password alone now yields a challenge, which is not a session token.
+	_, err = svc.Login(ctx, "alice", "password123")
+	var mfaErr *MFARequiredError
+	if !errors.As(err, &mfaErr) {
+		t.Fatalf("expected MFARequiredError, got %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, mfaErr.Challenge); err != ErrUnauthorized {
+		t.Fatalf("challenge accepted as a session token: %v", err)
+	}
+	ch, err := svc.ParseMFAChallenge(mfaErr.Challenge)
+	if err != nil || ch.UserID != user.ID {
+		t.Fatalf("ParseMFAChallenge = %+v, %v", ch, err)
+	}
+	if _, err := svc.ParseMFAChallenge(token); err != ErrUnauthorized {
+		t.Fatalf("session token accepted as a challenge: %v", err)
+	}
+
+	// The code used to confirm cannot be replayed.
+	if _, _, err := svc.CompleteMFALogin(ctx, ch, code); err != ErrMFAInvalidCode {
+		t.Fatalf("expected replayed code to be refused, got %v", err)
+	}
+	next, _ := TOTPCode(secret, time.Now().Add(totpPeriod*time.Second))
+	token, usedRecovery, err := svc.CompleteMFALogin(ctx, ch, next)
+	if err != nil || usedRecovery {
+		t.Fatalf("CompleteMFALogin with TOTP: %v (recovery %v)", err, usedRecovery)
+	}
+	if identity, err := svc.ValidateToken(ctx, token); err != nil || !identity.MFA {
+		t.Fatalf("expected an MFA session, got %+v, %v", identity, err)
+	}
+
+	// Recovery codes work once, with any case and spacing.
+	messy := strings.ToUpper(strings.ReplaceAll(recovery[0], "-", " "))
+	if _, usedRecovery, err := svc.CompleteMFALogin(ctx, ch, messy); err != nil || !usedRecovery {
+		t.Fatalf("CompleteMFALogin with recovery code: %v (recovery %v)", err, usedRecovery)
+	}
+	if _, _, err := svc.CompleteMFALogin(ctx, ch, recovery[0]); err != ErrMFAInvalidCode {
+		t.Fatalf("expected a used recovery code to be refused, got %v", err)
+	}
+
+	if err := svc.DisableTOTP(ctx, user.ID, "000000"); err != ErrMFAInvalidCode {
+		t.Fatalf("expected disable with a wrong code to fail, got %v", err)
+	}
+	if err := svc.DisableTOTP(ctx, user.ID, recovery[1]); err != nil {
+		t.Fatalf("DisableTOTP: %v", err)
+	}
+	if _, err := svc.Login(ctx, "alice", "password123"); err != nil {
+		t.Fatalf("login after disabling 2FA: %v", err)
+	}
+}
+
+func TestUserLifecycle(t *testing.T) {
+	svc, s := newTestAuthService(t)
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "alice", "password123", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := svc.Login(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the password needs the current one.
+	if _, err := svc.ChangePassword(ctx, user.ID, "wrong", "newpassword1", false); err != ErrInvalidCredentials {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	newToken, err := svc.ChangePassword(ctx, user.ID, "password123", "newpassword1", false)
+	if err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, newToken); err != nil {
+		t.Fatalf("expected the new token to be valid: %v", err)
+	}
+	if _, err := svc.Login(ctx, "alice", "password123"); err != ErrInvalidCredentials {
+		t.Fatalf("expected the old password to be refused, got %v", err)
+	}
+
+	// Tokens issued before a password change are refused.
+	u, _ := s.GetUserByID(ctx, user.ID)
+	later := time.Now().Add(2 * time.Second)
+	u.PasswordChangedAt = &later
+	if err := s.UpdateUser(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ValidateToken(ctx, token); err != ErrUnauthorized {
+		t.Fatalf("expected a pre-change token to be refused, got %v", err)
+	}
+
+	// Reset links work once.
+	reset, _, err := svc.IssuePasswordReset(ctx, user.ID, "admin")
+	if err != nil {
+		t.Fatalf("IssuePasswordReset: %v", err)
+	}
+	if _, err := svc.ResetPassword(ctx, "bogus", "resetpassword"); err != ErrResetInvalid {
+		t.Fatalf("expected ErrResetInvalid for an unknown token, got %v", err)
+	}
+	if got, err := svc.ResetPassword(ctx, reset, "resetpassword"); err != nil || got.ID != user.ID {
+		t.Fatalf("ResetPassword = %+v, %v", got, err)
+	}
+	if _, err := svc.ResetPassword(ctx, reset, "another-password"); err != ErrResetInvalid {
+		t.Fatalf("expected a used reset token to be refused, got %v", err)
+	}
+	newToken, err = svc.Login(ctx, "alice", "resetpassword")
+	if err != nil {
+		t.Fatalf("login after reset: %v", err)
+	}
+
+	// Disabled users cannot sign in, and their tokens stop working.
+	u, _ = s.GetUserByID(ctx, user.ID)
+	u.Disabled = true
+	if err := s.UpdateUser(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ValidateToken(ctx, newToken); err != ErrUnauthorized {
+		t.Fatalf("expected a disabled user's token to be refused, got %v", err)
+	}
+	if _, err := svc.Login(ctx, "alice", "resetpassword"); err != ErrUserDisabled {
+		t.Fatalf("expected ErrUserDisabled, got %v", err)
+	}
+	if _, err := svc.Login(ctx, "alice", "wrong"); err != ErrInvalidCredentials {
+		t.Fatalf("expected a wrong password to stay ErrInvalidCredentials, got %v", err)
+	}
+}
+
 func TestValidateRuntimeToken(t *testing.T) {
 	svc, _ := newTestAuthService(t)
 
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -17,8 +18,16 @@ func NewProvider(cfg config.AuthConfig, s store.Store) (Provider, error) {
 			return nil, err
 		}
 		// Wrap Clerk with a Service to provide runtime token validation.
-		svc := NewService(s, cfg)
-		return &clerkWithRuntime{ClerkProvider: clerk, svc: svc}, nil
+		return &clerkWithRuntime{ClerkProvider: clerk, runtimeAuth: runtimeAuth{svc: NewService(s, cfg)}}, nil
+	case "oidc":
+		if cfg.OIDC == nil {
+			return nil, fmt.Errorf("auth.oidc is required when provider is oidc")
+		}
+		oidc, err := NewOIDCProvider(*cfg.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		return &oidcWithRuntime{OIDCProvider: oidc, runtimeAuth: runtimeAuth{svc: NewService(s, cfg)}}, nil
 	case "builtin", "":
 		return NewService(s, cfg), nil
 	default:
@@ -30,25 +39,103 @@ func NewProvider(cfg config.AuthConfig, s store.Store) (Provider, error) {
 // Service (for runtime token validation).
 type clerkWithRuntime struct {
 	*ClerkProvider
+	runtimeAuth
+}
+
+// oidcWithRuntime combines OIDCProvider (for user sign-in and ID token
+// validation) with Service (for runtime token validation).
+type oidcWithRuntime struct {
+	*OIDCProvider
+	runtimeAuth
+}
+
+// ValidateToken accepts personal access tokens as well as Clerk session JWTs.
+func (c *clerkWithRuntime) ValidateToken(ctx context.Context, token string) (*Identity, error) {
+	if IsPersonalToken(token) {
+		return c.svc.ValidatePersonalToken(ctx, token)
+	}
+	identity, err := c.ClerkProvider.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return identity, c.checkLocalAccount(ctx, identity)
+}
+
+// CompleteLogin finishes a sign-in at the issuer and exchanges its ID token
+// for a hub session token. ID tokens are short-lived (Keycloak's last five
+// minutes) and the hub holds no refresh token, so they make poor sessions.
+func (o *oidcWithRuntime) CompleteLogin(ctx context.Context, state, code string) (string, *Identity, error) {
+	_, identity, err := o.OIDCProvider.CompleteLogin(ctx, state, code)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := o.checkLocalAccount(ctx, identity); err != nil {
+		return "", nil, err
+	}
+	token, err := o.svc.generateExternalToken(identity)
+	if err != nil {
+		return "", nil, fmt.Errorf("issue session token: %w", err)
+	}
+	return token, identity, nil
+}
+
+// ValidateToken accepts personal access tokens and hub session tokens as well
+// as OIDC ID tokens.
+func (o *oidcWithRuntime) ValidateToken(ctx context.Context, token string) (*Identity, error) {
+	if IsPersonalToken(token) {
+		return o.svc.ValidatePersonalToken(ctx, token)
+	}
+	if len(o.svc.jwtSecret) > 0 {
+		if claims, err := o.svc.validateJWT(token); err == nil {
+			if claims.UserID == "" || claims.Role == "" {
+				return nil, ErrUnauthorized
+			}
+			identity := &Identity{UserID: claims.UserID, Username: claims.Username, Role: claims.Role, OrgID: claims.OrgID}
+			return identity, o.checkLocalAccount(ctx, identity)
+		}
+	}
+	identity, err := o.OIDCProvider.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return identity, o.checkLocalAccount(ctx, identity)
+}
+
+// runtimeAuth provides RuntimeAuthProvider for external user providers.
+type runtimeAuth struct {
 	svc *Service
 }
 
-func (c *clerkWithRuntime) ValidateRuntimeToken(runtimeID, token string) bool {
+// checkLocalAccount refuses an externally-authenticated identity whose local
+// account an admin disabled or deleted. The identity provider keeps vouching
+// for the user, so its tokens would otherwise stay valid until they expire.
+func (c runtimeAuth) checkLocalAccount(ctx context.Context, identity *Identity) error {
+	user, err := c.svc.store.GetUserByExternalID(ctx, identity.UserID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+	if user != nil && (user.Disabled || user.DeletedAt != nil) {
+		return ErrUserDisabled
+	}
+	return nil
+}
+
+func (c runtimeAuth) ValidateRuntimeToken(runtimeID, token string) bool {
 	return c.svc.ValidateRuntimeToken(runtimeID, token)
 }
 
-func (c *clerkWithRuntime) ValidateTimeLimitedToken(token string) (string, error) {
+func (c runtimeAuth) ValidateTimeLimitedToken(token string) (string, error) {
 	return c.svc.ValidateTimeLimitedToken(token)
 }
 
-func (c *clerkWithRuntime) GenerateRuntimeToken(runtimeID string) string {
+func (c runtimeAuth) GenerateRuntimeToken(runtimeID string) string {
 	return c.svc.GenerateRuntimeToken(runtimeID)
 }
 
-func (c *clerkWithRuntime) RuntimeTokenSecret() string {
+func (c runtimeAuth) RuntimeTokenSecret() string {
 	return c.svc.RuntimeTokenSecret()
 }
 
-func (c *clerkWithRuntime) RuntimeTokenLifetime() time.Duration {
+func (c runtimeAuth) RuntimeTokenLifetime() time.Duration {
 	return c.svc.RuntimeTokenLifetime()
 }
@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/amurg-ai/amurg/hub/store"
+)
+
+const (
+	// mfaChallengeTTL bounds how long a user may take to enter their code
+	// after the password step.
+	mfaChallengeTTL = 5 * time.Minute
+	// mfaChallengeAudience tells challenge tokens apart from session tokens.
+	mfaChallengeAudience = "amurg-mfa"
+	// mfaIssuer is the account issuer shown in authenticator apps.
+	mfaIssuer = "Amurg"
+	// recoveryCodeCount is how many recovery codes an enrollment gets.
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrMFAInvalidCode    = errors.New("invalid two-factor code")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication is not enrolled")
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+)
+
+// MFARequiredError is returned by Login when the password was right but the
+// account has a second factor. Challenge is passed back with the code to
+// finish the login.
+type MFARequiredError struct {
+	Challenge string
+}
+
+func (e *MFARequiredError) Error() string { return "second factor required" }
+
+// MFAChallenge is a login that passed the password step.
+type MFAChallenge struct {
+	UserID   string
+	Username string
+}
+
+// mfaClaims are the claims of a challenge token. They carry no "uid" claim,
+// so a challenge is never accepted as a session token.
+type mfaClaims struct {
+	Username string `json:"usr"`
+	jwt.RegisteredClaims
+}
+
+func (s *Service) generateMFAChallenge(user *store.User) (string, error) {
+	now := time.Now()
+	claims := &mfaClaims{
+		Username: user.Username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID,
+			Audience:  jwt.ClaimStrings{mfaChallengeAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.jwtSecret)
+}
+
+// ParseMFAChallenge validates a challenge returned in an MFARequiredError.
+func (s *Service) ParseMFAChallenge(challenge string) (*MFAChallenge, error) {
+	token, err := jwt.ParseWithClaims(challenge, &mfaClaims{}, func(token *jwt.Token) (any, error) {
+		return s.jwtSecret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithAudience(mfaChallengeAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+	claims, ok := token.Claims.(*mfaClaims)
+	if !ok || !token.Valid || claims.Subject == "" {
+		return nil, ErrUnauthorized
+	}
+	return &MFAChallenge{UserID: claims.Subject, Username: claims.Username}, nil
+}
+
+// CompleteMFALogin finishes a login with a TOTP code or, failing that, a
+// recovery code. A recovery code works once.
+func (s *Service) CompleteMFALogin(ctx context.Context, ch *MFAChallenge, code string) (string, bool, error) {
+	user, err := s.store.GetUserByID(ctx, ch.UserID)
+	if err != nil || user == nil || user.Disabled {
+		return "", false, ErrUnauthorized
+	}
+	m, err := s.store.GetUserMFA(ctx, user.ID)
+	if err != nil {
+		return "", false, fmt.Errorf("get mfa: %w", err)
+	}
+	if m == nil || !m.Enabled {
+		return "", false, ErrMFANotEnrolled
+	}
+
+	usedRecovery := false
+	if step, ok := verifyTOTP(m.Secret, strings.TrimSpace(code), time.Now(), m.LastStep); ok {
+		m.LastStep = step
+	} else if codes, ok := useRecoveryCode(m.RecoveryCodes, code); ok {
+		m.RecoveryCodes = codes
+		usedRecovery = true
+	} else {
+		return "", false, ErrMFAInvalidCode
+	}
+	if err := s.store.UpsertUserMFA(ctx, m); err != nil {
+		return "", false, fmt.Errorf("update mfa: %w", err)
+	}
+
+	token, err := s.generateToken(user, true)
+	return token, usedRecovery, err
+}
+
+// BeginTOTPEnrollment creates a new pending secret for a user, replacing any
+// earlier unconfirmed one. It returns the secret and its otpauth:// URI.
+func (s *Service) BeginTOTPEnrollment(ctx context.Context, userID, username string) (string, string, error) {
+	existing, err := s.store.GetUserMFA(ctx, userID)
+	if err != nil {
+		return "", "", fmt.Errorf("get mfa: %w", err)
+	}
+	if existing != nil && existing.Enabled {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.store.UpsertUserMFA(ctx, &store.UserMFA{
+		UserID:        userID,
+		Secret:        secret,
+		RecoveryCodes: "[]",
+		CreatedAt:     time.Now(),
+	}); err != nil {
+		return "", "", fmt.Errorf("save mfa: %w", err)
+	}
+	return secret, TOTPURI(mfaIssuer, username, secret), nil
+}
+
+// ConfirmTOTPEnrollment enables a pending secret once code proves the user's
+// authenticator has it. It returns the recovery codes, shown to the user
+// once, and a session token that counts as signed in with a second factor.
+func (s *Service) ConfirmTOTPEnrollment(ctx context.Context, userID, code string) ([]string, string, error) {
+	m, err := s.store.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, "", fmt.Errorf("get mfa: %w", err)
+	}
+	if m == nil {
+		return nil, "", ErrMFANotEnrolled
+	}
+	if m.Enabled {
+		return nil, "", ErrMFAAlreadyEnabled
+	}
+	step, ok := verifyTOTP(m.Secret, strings.TrimSpace(code), time.Now(), m.LastStep)
+	if !ok {
+		return nil, "", ErrMFAInvalidCode
+	}
+	user, err := s.store.GetUserByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, "", ErrUnauthorized
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, "", err
+	}
+	hashesJSON, _ := json.Marshal(hashes)
+	now := time.Now()
+	m.Enabled = true
+	m.EnabledAt = &now
+	m.LastStep = step
+	m.RecoveryCodes = string(hashesJSON)
+	if err := s.store.UpsertUserMFA(ctx, m); err != nil {
+		return nil, "", fmt.Errorf("save mfa: %w", err)
+	}
+
+	token, err := s.generateToken(user, true)
+	if err != nil {
+		return nil, "", err
+	}
+	return codes, token, nil
+}
+
+// DisableTOTP removes a user's second factor. An enabled one needs a valid
+// TOTP or recovery code; an unconfirmed enrollment is simply discarded.
+func (s *Service) DisableTOTP(ctx context.Context, userID, code string) error {
+	m, err := s.store.GetUserMFA(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get mfa: %w", err)
+	}
+	if m == nil {
+		return ErrMFANotEnrolled
+	}
+	if m.Enabled {
+		if _, ok := verifyTOTP(m.Secret, strings.TrimSpace(code), time.Now(), m.LastStep); !ok {
+			if _, ok := useRecoveryCode(m.RecoveryCodes, code); !ok {
+				return ErrMFAInvalidCode
+			}
+		}
+	}
+	return s.store.DeleteUserMFA(ctx, userID)
+}
+
+// RecoveryCodesRemaining returns how many unused recovery codes m has.
+func RecoveryCodesRemaining(m *store.UserMFA) int {
+	var hashes []string
+	_ = json.Unmarshal([]byte(m.RecoveryCodes), &hashes)
+	return len(hashes)
+}
+
+// MFAEnrollmentRequired reports whether the org requires admins to use a
+// second factor and identity is an admin session that did not sign in with
+// one. A personal access token passes only while its owner has 2FA enabled:
+// tokens created before the requirement was turned on would otherwise keep
+// admin access without it.
+func MFAEnrollmentRequired(ctx context.Context, s store.Store, identity *Identity) bool {
+	if identity.Role != "admin" || identity.MFA {
+		return false
+	}
+	org, err := s.GetOrganization(ctx, identity.OrgID)
+	if err != nil || org == nil || !org.RequireAdminMFA {
+		return false
+	}
+	if identity.TokenID != "" {
+		m, err := s.GetUserMFA(ctx, identity.UserID)
+		return err != nil || m == nil || !m.Enabled
+	}
+	return true
+}
+
+// generateRecoveryCodes returns new recovery codes and their stored hashes.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("generate recovery code: %w", err)
+		}
+		h := hex.EncodeToString(b)
+		codes[i] = h[:5] + "-" + h[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code, ignoring case, spaces and dashes.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// useRecoveryCode looks code up in the stored hash list and returns the list
+// without it.
+func useRecoveryCode(stored, code string) (string, bool) {
+	var hashes []string
+	if err := json.Unmarshal([]byte(stored), &hashes); err != nil {
+		return stored, false
+	}
+	want := hashRecoveryCode(code)
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(want)) == 1 {
+			rest, _ := json.Marshal(append(hashes[:i:i], hashes[i+1:]...))
+			return string(rest), true
+		}
+	}
+	return stored, false
+}
@@ -0,0 +1,343 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MicahParks/keyfunc/v3"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/amurg-ai/amurg/hub/config"
+)
+
+const (
+	// oidcLoginTTL bounds how long a user may take at the identity provider.
+	oidcLoginTTL = 10 * time.Minute
+	// oidcMaxPendingLogins caps unfinished logins held in memory.
+	oidcMaxPendingLogins = 10000
+)
+
+// ErrLoginExpired is returned for a callback whose login is unknown or too old.
+var ErrLoginExpired = errors.New("login expired or unknown; start again")
+
+// OIDCProvider signs users in through a generic OpenID Connect issuer with
+// the authorization code flow and PKCE. The issuer's ID tokens are the bearer
+// tokens: they are validated against its JWKS on every request.
+type OIDCProvider struct {
+	cfg        config.OIDCConfig
+	client     *http.Client
+	jwks       keyfunc.Keyfunc
+	stopJWKS   context.CancelFunc
+	authURL    string
+	tokenURL   string
+	validAlgos []string
+
+	mu      sync.Mutex
+	pending map[string]oidcLogin // state -> login in progress
+}
+
+// oidcLogin is a login waiting for its callback.
+type oidcLogin struct {
+	verifier string
+	nonce    string
+	expires  time.Time
+}
+
+// oidcDiscovery is the subset of the issuer's discovery document we use.
+type oidcDiscovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	IDTokenSigningAlgs    []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// NewOIDCProvider discovers the issuer's endpoints and starts fetching its JWKS.
+func NewOIDCProvider(cfg config.OIDCConfig) (*OIDCProvider, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" {
+		return nil, fmt.Errorf("oidc issuer and client_id are required")
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	discoveryURL := strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	resp, err := client.Get(discoveryURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetch OIDC discovery document: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch OIDC discovery document: %s returned %s", discoveryURL, resp.Status)
+	}
+	var disc oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&disc); err != nil {
+		return nil, fmt.Errorf("parse OIDC discovery document: %w", err)
+	}
+	if disc.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("OIDC discovery document is for issuer %q, not %q", disc.Issuer, cfg.Issuer)
+	}
+	if disc.AuthorizationEndpoint == "" || disc.TokenEndpoint == "" || disc.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery document lacks an authorization, token or jwks endpoint")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	jwks, err := keyfunc.NewDefaultCtx(ctx, []string{disc.JWKSURI})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("fetch JWKS from %s: %w", disc.JWKSURI, err)
+	}
+
+	algs := disc.IDTokenSigningAlgs
+	if len(algs) == 0 {
+		algs = []string{"RS256"} // the only algorithm OIDC requires
+	}
+
+	return &OIDCProvider{
+		cfg:        cfg,
+		client:     client,
+		jwks:       jwks,
+		stopJWKS:   cancel,
+		authURL:    disc.AuthorizationEndpoint,
+		tokenURL:   disc.TokenEndpoint,
+		validAlgos: algs,
+		pending:    make(map[string]oidcLogin),
+	}, nil
+}
+
+// LoginURL starts a login and returns the issuer URL to send the user to
+// along with the login's state.
+func (p *OIDCProvider) LoginURL() (string, string, error) {
+	state, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	p.mu.Lock()
+	for s, l := range p.pending {
+		if now.After(l.expires) {
+			delete(p.pending, s)
+		}
+	}
+	if len(p.pending) >= oidcMaxPendingLogins {
+		p.mu.Unlock()
+		return "", "", fmt.Errorf("too many logins in progress")
+	}
+	p.pending[state] = oidcLogin{verifier: verifier, nonce: nonce, expires: now.Add(oidcLoginTTL)}
+	p.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.authURL, "?") {
+		sep = "&"
+	}
+	return p.authURL + sep + q.Encode(), state, nil
+}
+
+// CompleteLogin redeems the code from the issuer's callback and returns the
+// validated ID token and the identity it carries.
+func (p *OIDCProvider) CompleteLogin(ctx context.Context, state, code string) (string, *Identity, error) {
+	p.mu.Lock()
+	login, ok := p.pending[state]
+	delete(p.pending, state)
+	p.mu.Unlock()
+	if !ok || time.Now().After(login.expires) {
+		return "", nil, ErrLoginExpired
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {login.verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("redeem authorization code: %w", err)
+	}
+	defer resp.Body.Close()
+	var tok struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", nil, fmt.Errorf("parse token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || tok.Error != "" {
+		return "", nil, fmt.Errorf("redeem authorization code: %s %s", tok.Error, tok.ErrorDescription)
+	}
+	if tok.IDToken == "" {
+		return "", nil, fmt.Errorf("token response has no id_token")
+	}
+
+	claims, err := p.parseIDToken(ctx, tok.IDToken)
+	if err != nil {
+		return "", nil, err
+	}
+	if nonce, _ := claims["nonce"].(string); nonce != login.nonce {
+		return "", nil, ErrUnauthorized
+	}
+	return tok.IDToken, p.identity(claims), nil
+}
+
+// ValidateToken validates an ID token issued to this client and returns the
+// identity mapped from its claims.
+func (p *OIDCProvider) ValidateToken(ctx context.Context, tokenStr string) (*Identity, error) {
+	claims, err := p.parseIDToken(ctx, tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	return p.identity(claims), nil
+}
+
+func (p *OIDCProvider) parseIDToken(ctx context.Context, tokenStr string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenStr, p.jwks.KeyfuncCtx(ctx),
+		jwt.WithIssuer(p.cfg.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithValidMethods(p.validAlgos),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrUnauthorized
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, ErrUnauthorized
+	}
+	return claims, nil
+}
+
+// identity maps ID token claims to an Identity using the configured claims.
+func (p *OIDCProvider) identity(claims jwt.MapClaims) *Identity {
+	sub, _ := claims["sub"].(string)
+
+	username := ""
+	if p.cfg.UsernameClaim != "" {
+		username, _ = lookupClaim(claims, p.cfg.UsernameClaim).(string)
+	}
+	for _, key := range []string{"preferred_username", "email"} {
+		if username == "" {
+			username = claimStr(claims, key)
+		}
+	}
+	if username == "" {
+		username = sub
+	}
+
+	role := "user"
+	if p.cfg.RoleClaim != "" && claimHasAny(lookupClaim(claims, p.cfg.RoleClaim), p.cfg.AdminValues) {
+		role = "admin"
+	}
+
+	orgID := ""
+	if p.cfg.OrgClaim != "" {
+		orgID, _ = lookupClaim(claims, p.cfg.OrgClaim).(string)
+	}
+
+	return &Identity{
+		UserID:   sub,
+		Username: username,
+		Role:     role,
+		OrgID:    orgID,
+	}
+}
+
+// lookupClaim resolves a dotted claim path such as "realm_access.roles".
+func lookupClaim(claims jwt.MapClaims, path string) any {
+	var v any = map[string]any(claims)
+	for _, part := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[part]
+	}
+	return v
+}
+
+// claimHasAny reports whether a string or list claim contains one of values.
+func claimHasAny(claim any, values []string) bool {
+	var got []string
+	switch c := claim.(type) {
+	case string:
+		got = []string{c}
+	case []any:
+		for _, item := range c {
+			if s, ok := item.(string); ok {
+				got = append(got, s)
+			}
+		}
+	}
+	for _, g := range got {
+		for _, want := range values {
+			if g == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Bootstrap is a no-op for OIDC (users are provisioned on first sign-in).
+func (p *OIDCProvider) Bootstrap(ctx context.Context) error {
+	return nil
+}
+
+// Name returns the provider name.
+func (p *OIDCProvider) Name() string { return "oidc" }
+
+// Close stops the JWKS background refresh.
+func (p *OIDCProvider) Close() error {
+	p.stopJWKS()
+	return nil
+}
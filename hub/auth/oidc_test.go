@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/amurg-ai/amurg/hub/config"
+	"github.com/amurg-ai/amurg/hub/store"
+)
+
+// mockIssuer is a minimal OpenID Connect issuer: discovery, JWKS and a token
+// endpoint that redeems codes registered with authorize.
+type mockIssuer struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockGrant // code -> grant
+}
+
+type mockGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{key: key, codes: make(map[string]mockGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                m.srv.URL,
+			"authorization_endpoint":                m.srv.URL + "/authorize",
+			"token_endpoint":                        m.srv.URL + "/token",
+			"jwks_uri":                              m.srv.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "test-key", "alg": "RS256", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		m.mu.Lock()
+		grant, ok := m.codes[r.PostForm.Get("code")]
+		delete(m.codes, r.PostForm.Get("code"))
+		m.mu.Unlock()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": m.sign(t, grant.claims), "token_type": "Bearer"})
+	})
+	m.srv = httptest.NewServer(mux)
+	t.Cleanup(m.srv.Close)
+	return m
+}
+
+// authorize plays the user signing in at the issuer: it registers a code for
+// the login URL's PKCE challenge and returns the callback's state and code.
+func (m *mockIssuer) authorize(t *testing.T, loginURL string, claims jwt.MapClaims) (state, code string) {
+	t.Helper()
+	u, err := url.Parse(loginURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("login URL lacks a PKCE challenge: %s", loginURL)
+	}
+	full := jwt.MapClaims{"nonce": q.Get("nonce")}
+	for k, v := range claims {
+		full[k] = v
+	}
+	code = "code-" + q.Get("state")[:8]
+	m.mu.Lock()
+	m.codes[code] = mockGrant{challenge: q.Get("code_challenge"), claims: full}
+	m.mu.Unlock()
+	return q.Get("state"), code
+}
+
+func (m *mockIssuer) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	full := jwt.MapClaims{
+		"iss": m.srv.URL,
+		"aud": "amurg",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		full[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, full)
+	token.Header["kid"] = "test-key"
+	s, err := token.SignedString(m.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func newTestOIDCProvider(t *testing.T, m *mockIssuer) *OIDCProvider {
+	t.Helper()
+	p, err := NewOIDCProvider(config.OIDCConfig{
+		Issuer:      m.srv.URL,
+		ClientID:    "amurg",
+		RedirectURL: "https://hub.example.com/api/auth/oidc/callback",
+		Scopes:      []string{"openid", "profile"},
+		RoleClaim:   "realm_access.roles",
+		AdminValues: []string{"amurg-admin"},
+		OrgClaim:    "org",
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider: %v", err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
+
+func TestOIDCProvider_LoginWithPKCE(t *testing.T) {
+	m := newMockIssuer(t)
+	p := newTestOIDCProvider(t, m)
+	ctx := context.Background()
+
+	loginURL, _, err := p.LoginURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := m.authorize(t, loginURL, jwt.MapClaims{
+		"sub":                "user-123",
+		"preferred_username": "alice",
+		"realm_access":       map[string]any{"roles": []string{"offline_access", "amurg-admin"}},
+		"org":                "acme",
+	})
+
+	token, identity, err := p.CompleteLogin(ctx, state, code)
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	want := Identity{UserID: "user-123", Username: "alice", Role: "admin", OrgID: "acme"}
+	if !reflect.DeepEqual(*identity, want) {
+		t.Fatalf("identity = %+v, want %+v", *identity, want)
+	}
+
+	validated, err := p.ValidateToken(ctx, token)
+	if err != nil || !reflect.DeepEqual(*validated, want) {
+		t.Fatalf("ValidateToken = %+v, %v", validated, err)
+	}
+
+	// A state can only be redeemed once.
+	if _, _, err := p.CompleteLogin(ctx, state, code); !errors.Is(err, ErrLoginExpired) {
+		t.Fatalf("expected ErrLoginExpired on replay, got %v", err)
+	}
+}
+
+func TestOIDCProvider_RejectsForeignTokens(t *testing.T) {
+	m := newMockIssuer(t)
+	p := newTestOIDCProvider(t, m)
+	ctx := context.Background()
+
+	plain := m.sign(t, jwt.MapClaims{"sub": "user-1", "email": "bob@example.com"})
+	identity, err := p.ValidateToken(ctx, plain)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if identity.Username != "bob@example.com" || identity.Role != "user" || identity.OrgID != "" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+
+	for name, claims := range map[string]jwt.MapClaims{
+		"other audience": {"sub": "user-1", "aud": "someone-else"},
+		"other issuer":   {"sub": "user-1", "iss": "https://evil.example.com"},
+		"expired":        {"sub": "user-1", "exp": time.Now().Add(-time.Minute).Unix()},
+		"no subject":     {"email": "bob@example.com"},
+	} {
+		if _, err := p.ValidateToken(ctx, m.sign(t, claims)); err == nil {
+			t.Errorf("%s: expected token to be rejected", name)
+		}
+	}
+
+	// A code redeemed with the wrong verifier (a stolen code) fails.
+	loginURL, _, _ := p.LoginURL()
+	_, code := m.authorize(t, loginURL, jwt.MapClaims{"sub": "user-1"})
+	otherURL, _, _ := p.LoginURL()
+	otherState, _ := m.authorize(t, otherURL, jwt.MapClaims{"sub": "user-2"})
+	if _, _, err := p.CompleteLogin(ctx, otherState, code); err == nil {
+		t.Fatal("expected a code bound to another login's PKCE challenge to be refused")
+	}
+}
+
+func TestOIDCProvider_IssuesHubSessionTokens(t *testing.T) {
+	m := newMockIssuer(t)
+	svc, _ := newTestAuthService(t)
+	p := &oidcWithRuntime{OIDCProvider: newTestOIDCProvider(t, m), runtimeAuth: runtimeAuth{svc: svc}}
+	ctx := context.Background()
+
+	// The ID token only lasts a minute, as many issuers' do.
+	loginURL, _, err := p.LoginURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := m.authorize(t, loginURL, jwt.MapClaims{
+		"sub":                "user-123",
+		"preferred_username": "alice",
+		"org":                "acme",
+		"exp":                time.Now().Add(time.Minute).Unix(),
+	})
+	token, identity, err := p.CompleteLogin(ctx, state, code)
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+
+	claims, err := svc.validateJWT(token)
+	if err != nil {
+		t.Fatalf("expected a hub session token: %v", err)
+	}
+	if got := claims.ExpiresAt.Sub(claims.IssuedAt.Time); got != time.Hour {
+		t.Fatalf("session token lasts %v, want the hub's jwt_expiry", got)
+	}
+	validated, err := p.ValidateToken(ctx, token)
+	if err != nil || !reflect.DeepEqual(validated, identity) {
+		t.Fatalf("ValidateToken = %+v, %v; want %+v", validated, err, identity)
+	}
+
+	// A token signed with another secret is not a session token.
+	other := NewService(nil, config.AuthConfig{JWTSecret: "another-secret-at-least-32-chars!", JWTExpiry: config.Duration{Duration: time.Hour}})
+	forged, err := other.generateExternalToken(identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ValidateToken(ctx, forged); err == nil {
+		t.Fatal("expected a token signed with another secret to be refused")
+	}
+}
+
+func TestOIDCProvider_RefusesDisabledLocalAccounts(t *testing.T) {
+	m := newMockIssuer(t)
+	svc, s := newTestAuthService(t)
+	p := &oidcWithRuntime{OIDCProvider: newTestOIDCProvider(t, m), runtimeAuth: runtimeAuth{svc: svc}}
+	ctx := context.Background()
+
+	// Users the hub has not provisioned yet are let through.
+	token := m.sign(t, jwt.MapClaims{"sub": "user-1", "email": "bob@example.com"})
+	if _, err := p.ValidateToken(ctx, token); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+
+	user := &store.User{ID: "user-1", OrgID: "default", ExternalID: "user-1", Username: "bob@example.com", Role: "user", CreatedAt: time.Now()}
+	if err := s.CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	user.Disabled = true
+	if err := s.UpdateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ValidateToken(ctx, token); !errors.Is(err, ErrUserDisabled) {
+		t.Fatalf("expected a disabled user's ID token to be refused, got %v", err)
+	}
+
+	user.Disabled = false
+	if err := s.UpdateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ValidateToken(ctx, token); err != nil {
+		t.Fatalf("expected a re-enabled user to be accepted: %v", err)
+	}
+	if err := s.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ValidateToken(ctx, token); !errors.Is(err, ErrUserDisabled) {
+		t.Fatalf("expected a deleted user's ID token to be refused, got %v", err)
+	}
+}
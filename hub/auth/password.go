@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/amurg-ai/amurg/hub/store"
+)
+
+// passwordResetTTL is how long an admin-issued reset link stays valid.
+const passwordResetTTL = 24 * time.Hour
+
+// ErrResetInvalid is returned for a reset token that is unknown, used or expired.
+var ErrResetInvalid = errors.New("reset link is invalid or has expired")
+
+// ChangePassword replaces a user's password after checking the current one.
+// Session tokens issued before the change stop working; the returned token
+// replaces the caller's, keeping its second-factor state.
+func (s *Service) ChangePassword(ctx context.Context, userID, current, newPassword string, mfa bool) (string, error) {
+	user, err := s.store.GetUserByID(ctx, userID)
+	if err != nil || user == nil || user.Disabled {
+		return "", ErrUnauthorized
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(current)); err != nil {
+		return "", ErrInvalidCredentials
+	}
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return "", err
+	}
+	return s.generateToken(user, mfa)
+}
+
+// IssuePasswordReset creates a one-time reset token for a user. Only its
+// hash is stored, and issuing a new one invalidates earlier ones.
+func (s *Service) IssuePasswordReset(ctx context.Context, userID, createdBy string) (string, time.Time, error) {
+	user, err := s.store.GetUserByID(ctx, userID)
+	if err != nil || user == nil || user.DeletedAt != nil {
+		return "", time.Time{}, fmt.Errorf("user not found")
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, fmt.Errorf("generate reset token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+
+	if err := s.store.DeletePasswordResets(ctx, userID); err != nil {
+		return "", time.Time{}, fmt.Errorf("delete old resets: %w", err)
+	}
+	now := time.Now()
+	r := &store.PasswordReset{
+		ID:        uuid.New().String(),
+		OrgID:     user.OrgID,
+		UserID:    user.ID,
+		TokenHash: hashResetToken(token),
+		CreatedBy: createdBy,
+		ExpiresAt: now.Add(passwordResetTTL),
+		CreatedAt: now,
+	}
+	if err := s.store.CreatePasswordReset(ctx, r); err != nil {
+		return "", time.Time{}, fmt.Errorf("save reset: %w", err)
+	}
+	return token, r.ExpiresAt, nil
+}
+
+// ResetPassword sets a new password with a reset token. The token works
+// once; session tokens issued before the reset stop working.
+func (s *Service) ResetPassword(ctx context.Context, token, newPassword string) (*store.User, error) {
+	r, err := s.store.GetPasswordResetByHash(ctx, hashResetToken(token))
+	if err != nil || r == nil || time.Now().After(r.ExpiresAt) {
+		return nil, ErrResetInvalid
+	}
+	user, err := s.store.GetUserByID(ctx, r.UserID)
+	if err != nil || user == nil || user.DeletedAt != nil {
+		return nil, ErrResetInvalid
+	}
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// setPassword stores a new password hash and drops pending reset tokens.
+func (s *Service) setPassword(ctx context.Context, user *store.User, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+	now := time.Now()
+	user.PasswordHash = string(hash)
+	user.PasswordChangedAt = &now
+	if err := s.store.UpdateUser(ctx, user); err != nil {
+		return fmt.Errorf("update user: %w", err)
+	}
+	if err := s.store.DeletePasswordResets(ctx, user.ID); err != nil {
+		return fmt.Errorf("delete resets: %w", err)
+	}
+	return nil
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
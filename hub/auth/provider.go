@@ -13,6 +13,26 @@ type Identity struct {
 	Username string
 	Role     string // "admin" or "user"
 	OrgID    string // "default" for self-hosted, org_id for SaaS
+	MFA      bool   // signed in with a second factor (builtin auth)
+
+	// TokenID and Scopes are set when the request authenticated with a
+	// personal access token; such requests are limited to Scopes.
+	TokenID string
+	Scopes  []string
+}
+
+// HasScope reports whether the identity may act within scope. Identities
+// from an interactive sign-in are unrestricted.
+func (i *Identity) HasScope(scope string) bool {
+	if i.TokenID == "" {
+		return true
+	}
+	for _, s := range i.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
 }
 
 // Provider validates bearer tokens and returns identities.
@@ -26,6 +46,43 @@ type Provider interface {
 type LoginProvider interface {
 	Login(ctx context.Context, username, password string) (string, error)
 	Register(ctx context.Context, username, password, role string) (*store.User, error)
+	// ChangePassword replaces a user's password after checking the current
+	// one and returns a fresh session token; older session tokens stop working.
+	ChangePassword(ctx context.Context, userID, current, newPassword string, mfa bool) (string, error)
+	// IssuePasswordReset creates a one-time reset token for a user,
+	// replacing any earlier one.
+	IssuePasswordReset(ctx context.Context, userID, createdBy string) (token string, expiresAt time.Time, err error)
+	// ResetPassword sets a new password with a reset token and returns the
+	// user it belonged to.
+	ResetPassword(ctx context.Context, token, newPassword string) (*store.User, error)
+}
+
+// RedirectLoginProvider is implemented by providers that sign users in by
+// sending them to an external identity provider and back.
+type RedirectLoginProvider interface {
+	// LoginURL starts a login and returns where to send the user and the
+	// login's state, which the callback carries back.
+	LoginURL() (target, state string, err error)
+	// CompleteLogin finishes a login from the callback's state and code and
+	// returns the bearer token for the signed-in user.
+	CompleteLogin(ctx context.Context, state, code string) (string, *Identity, error)
+}
+
+// MFAProvider is implemented by providers that support TOTP second factors.
+type MFAProvider interface {
+	// ParseMFAChallenge validates the challenge Login returned in an
+	// MFARequiredError.
+	ParseMFAChallenge(challenge string) (*MFAChallenge, error)
+	// CompleteMFALogin checks a TOTP or recovery code for a challenge and
+	// returns the session token.
+	CompleteMFALogin(ctx context.Context, ch *MFAChallenge, code string) (token string, usedRecoveryCode bool, err error)
+	// BeginTOTPEnrollment creates a pending TOTP secret for a user.
+	BeginTOTPEnrollment(ctx context.Context, userID, username string) (secret, uri string, err error)
+	// ConfirmTOTPEnrollment enables the pending secret once the user proves
+	// it works, and returns recovery codes and a fresh session token.
+	ConfirmTOTPEnrollment(ctx context.Context, userID, code string) (recoveryCodes []string, token string, err error)
+	// DisableTOTP removes a user's second factor after checking a code.
+	DisableTOTP(ctx context.Context, userID, code string) error
 }
 
 // RuntimeAuthProvider handles runtime token validation and generation.
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Personal access token scopes.
+const (
+	ScopeSessionsRead = "sessions:read" // read sessions, transcripts and other resources
+	ScopeMessagesSend = "messages:send" // create sessions, send messages and run agents
+	ScopeAdmin        = "admin"         // admin API, for admin users only
+)
+
+// Scopes lists every personal access token scope.
+var Scopes = []string{ScopeSessionsRead, ScopeMessagesSend, ScopeAdmin}
+
+// PersonalTokenPrefix starts every personal access token, telling them apart
+// from JWTs.
+const PersonalTokenPrefix = "amurg_pat_"
+
+// lastUsedGranularity limits how often a token's last-used time is written.
+const lastUsedGranularity = time.Minute
+
+// IsPersonalToken reports whether token looks like a personal access token.
+func IsPersonalToken(token string) bool {
+	return strings.HasPrefix(token, PersonalTokenPrefix)
+}
+
+// GeneratePersonalToken returns a new personal access token and the hash to
+// store for it. The token itself is shown to the user once and never stored.
+func GeneratePersonalToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("generate token: %w", err)
+	}
+	token = PersonalTokenPrefix + hex.EncodeToString(b)
+	return token, HashPersonalToken(token), nil
+}
+
+// HashPersonalToken returns the stored form of a personal access token.
+func HashPersonalToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidatePersonalToken looks up a personal access token and returns the
+// identity of its owner, limited to the token's scopes.
+func (s *Service) ValidatePersonalToken(ctx context.Context, tokenStr string) (*Identity, error) {
+	t, err := s.store.GetUserTokenByHash(ctx, HashPersonalToken(tokenStr))
+	if err != nil || t == nil {
+		return nil, ErrUnauthorized
+	}
+	now := time.Now()
+	if now.After(t.ExpiresAt) {
+		return nil, ErrUnauthorized
+	}
+	user, err := s.store.GetUserByID(ctx, t.UserID)
+	if err != nil || user == nil || user.Disabled || user.DeletedAt != nil {
+		return nil, ErrUnauthorized
+	}
+	var scopes []string
+	if err := json.Unmarshal([]byte(t.Scopes), &scopes); err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= lastUsedGranularity {
+		_ = s.store.UpdateUserTokenLastUsed(ctx, t.ID)
+	}
+
+	return &Identity{
+		UserID:   user.ID,
+		Username: user.Username,
+		Role:     user.Role,
+		OrgID:    t.OrgID,
+		TokenID:  t.ID,
+		Scopes:   scopes,
+	}, nil
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode_RFC6238(t *testing.T) {
+	// RFC 6238 appendix B SHA-1 vectors, truncated to six digits.
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		got, err := TOTPCode(secret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("TOTPCode at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	code, _ := TOTPCode(secret, now.Add(-totpPeriod*time.Second))
+
+	step, ok := verifyTOTP(secret, code, now, 0)
+	if !ok || step != now.Unix()/totpPeriod-1 {
+		t.Fatalf("expected previous step to be accepted, got %d %v", step, ok)
+	}
+	if _, ok := verifyTOTP(secret, code, now, step); ok {
+		t.Fatal("expected a used code to be refused")
+	}
+	old, _ := TOTPCode(secret, now.Add(-3*totpPeriod*time.Second))
+	if _, ok := verifyTOTP(secret, old, now, 0); ok {
+		t.Fatal("expected a code outside the skew window to be refused")
+	}
+	if _, ok := verifyTOTP(secret, "12345", now, 0); ok {
+		t.Fatal("expected a short code to be refused")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -62,11 +63,12 @@ type ServerConfig struct {
 	FileStoragePath string   `json:"file_storage_path,omitempty"` // path for uploaded files; default "./amurg-files"
 	MaxFileBytes    int64    `json:"max_file_bytes,omitempty"`    // max file size; default 10MB
 	WhisperURL      string   `json:"whisper_url,omitempty"`       // upstream Whisper WebSocket URL to proxy at /asr
+	MetricsToken    string   `json:"metrics_token,omitempty"`     // bearer token required to scrape /metrics; empty = unauthenticated
 }
 
 // AuthConfig defines authentication settings.
 type AuthConfig struct {
-	Provider             string              `json:"provider,omitempty"`     // "builtin" (default) or "clerk"
+	Provider             string              `json:"provider,omitempty"`     // "builtin" (default), "clerk" or "oidc"
 	ClerkIssuer          string              `json:"clerk_issuer,omitempty"` // e.g. "https://foo.clerk.accounts.dev"
 	ClerkSecretKey       string              `json:"clerk_secret_key,omitempty"`
 	JWTSecret            string              `json:"jwt_secret"`
@@ -76,6 +78,22 @@ type AuthConfig struct {
 	RuntimeTokenLifetime Duration            `json:"runtime_token_lifetime,omitempty"` // lifetime for generated tokens (default 1h)
 	InitialAdmin         *InitialAdmin       `json:"initial_admin,omitempty"`
 	DefaultAgentAccess   string              `json:"default_agent_access,omitempty"` // "all" (default) or "none"
+	OIDC                 *OIDCConfig         `json:"oidc,omitempty"`                 // required when provider is oidc
+}
+
+// OIDCConfig configures sign-in through a generic OpenID Connect issuer
+// (Keycloak, Authentik, Okta, Google Workspace, ...). Claim names may be
+// dotted paths into nested objects, e.g. "realm_access.roles".
+type OIDCConfig struct {
+	Issuer        string   `json:"issuer"`
+	ClientID      string   `json:"client_id"`
+	ClientSecret  string   `json:"client_secret,omitempty"`  // empty for public clients; PKCE is always used
+	RedirectURL   string   `json:"redirect_url,omitempty"`   // default: server.base_url + "/api/auth/oidc/callback"
+	Scopes        []string `json:"scopes,omitempty"`         // default: openid, profile, email
+	UsernameClaim string   `json:"username_claim,omitempty"` // default: preferred_username, then email, then sub
+	RoleClaim     string   `json:"role_claim,omitempty"`     // string or list claim checked against admin_values
+	AdminValues   []string `json:"admin_values,omitempty"`   // role claim values that make a user admin; default ["admin"]
+	OrgClaim      string   `json:"org_claim,omitempty"`      // claim holding the org ID; unset puts everyone in "default"
 }
 
 // RuntimeTokenEntry maps a runtime ID to its auth token.
@@ -174,8 +192,9 @@ func (c *Config) validate() error {
 	if c.Server.Addr == "" {
 		return fmt.Errorf("server.addr is required")
 	}
-	// JWTSecret is only required for builtin auth provider.
-	if (c.Auth.Provider == "" || c.Auth.Provider == "builtin") && c.Auth.JWTSecret == "" {
+	// JWTSecret signs session tokens for builtin and OIDC sign-ins; Clerk
+	// issues its own.
+	if c.Auth.Provider != "clerk" && c.Auth.JWTSecret == "" {
 		return fmt.Errorf("auth.jwt_secret is required")
 	}
 	if c.Auth.JWTSecret != "" && len(c.Auth.JWTSecret) < 32 {
@@ -187,6 +206,15 @@ func (c *Config) validate() error {
 	if c.Auth.Provider == "clerk" && c.Auth.ClerkIssuer == "" {
 		return fmt.Errorf("auth.clerk_issuer is required when provider is clerk")
 	}
+	if c.Auth.Provider == "oidc" {
+		o := c.Auth.OIDC
+		if o == nil || o.Issuer == "" || o.ClientID == "" {
+			return fmt.Errorf("auth.oidc.issuer and auth.oidc.client_id are required when provider is oidc")
+		}
+		if o.RedirectURL == "" && c.Server.BaseURL == "" {
+			return fmt.Errorf("auth.oidc.redirect_url or server.base_url is required when provider is oidc")
+		}
+	}
 	if c.Server.BaseURL != "" {
 		baseURL, err := url.Parse(c.Server.BaseURL)
 		if err != nil || !baseURL.IsAbs() || baseURL.Host == "" {
@@ -236,6 +264,17 @@ func (c *Config) applyDefaults() {
 	if c.Auth.RuntimeTokenLifetime.Duration == 0 {
 		c.Auth.RuntimeTokenLifetime.Duration = 1 * time.Hour
 	}
+	if o := c.Auth.OIDC; o != nil {
+		if o.RedirectURL == "" {
+			o.RedirectURL = strings.TrimSuffix(c.Server.BaseURL, "/") + "/api/auth/oidc/callback"
+		}
+		if len(o.Scopes) == 0 {
+			o.Scopes = []string{"openid", "profile", "email"}
+		}
+		if len(o.AdminValues) == 0 {
+			o.AdminValues = []string{"admin"}
+		}
+	}
 	if c.RateLimit.RequestsPerSecond == 0 {
 		c.RateLimit.RequestsPerSecond = 10
 	}
@@ -204,6 +204,48 @@ func TestValidateClerkProvider(t *testing.T) {
 	}
 }
 
+func TestValidateOIDCProvider(t *testing.T) {
+	// OIDC needs an issuer, a client and somewhere to send the callback.
+	noRedirect := `{
+		"server": {"addr": ":8080"},
+		"auth": {"provider": "oidc", "jwt_secret": "test-secret-at-least-32-chars-long", "oidc": {"issuer": "https://id.example.com", "client_id": "amurg"}}
+	}`
+	path := writeTempConfig(t, noRedirect)
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected error for oidc provider without redirect_url or base_url, got nil")
+	}
+
+	// The hub signs its own session tokens after an OIDC sign-in.
+	noSecret := `{
+		"server": {"addr": ":8080", "base_url": "https://hub.example.com/"},
+		"auth": {"provider": "oidc", "oidc": {"issuer": "https://id.example.com", "client_id": "amurg"}}
+	}`
+	path = writeTempConfig(t, noSecret)
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected error for oidc provider without jwt_secret, got nil")
+	}
+
+	withBaseURL := `{
+		"server": {"addr": ":8080", "base_url": "https://hub.example.com/"},
+		"auth": {"provider": "oidc", "jwt_secret": "test-secret-at-least-32-chars-long", "oidc": {"issuer": "https://id.example.com", "client_id": "amurg"}}
+	}`
+	path = writeTempConfig(t, withBaseURL)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load with oidc provider: %v", err)
+	}
+	o := cfg.Auth.OIDC
+	if o.RedirectURL != "https://hub.example.com/api/auth/oidc/callback" {
+		t.Errorf("OIDC.RedirectURL: got %q", o.RedirectURL)
+	}
+	if len(o.Scopes) != 3 || o.Scopes[0] != "openid" {
+		t.Errorf("OIDC.Scopes: got %v", o.Scopes)
+	}
+	if len(o.AdminValues) != 1 || o.AdminValues[0] != "admin" {
+		t.Errorf("OIDC.AdminValues: got %v", o.AdminValues)
+	}
+}
+
 func TestValidateBaseURL(t *testing.T) {
 	valid := `{
 		"server": {"addr": ":8080", "base_url": "https://hub.example.com"},
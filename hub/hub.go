@@ -13,8 +13,12 @@ import (
 	"github.com/amurg-ai/amurg/hub/auth"
 	"github.com/amurg-ai/amurg/hub/billing"
 	"github.com/amurg-ai/amurg/hub/config"
+	"github.com/amurg-ai/amurg/hub/metrics"
+	"github.com/amurg-ai/amurg/hub/permlink"
 	"github.com/amurg-ai/amurg/hub/router"
+	"github.com/amurg-ai/amurg/hub/scheduler"
 	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/amurg-ai/amurg/hub/webhook"
 )
 
 // Options contains optional dependencies injected by the caller (e.g. SaaS billing).
@@ -31,17 +35,24 @@ type Hub struct {
 	authProvider auth.Provider
 	router       *router.Router
 	api          *api.Server
+	webhooks     *webhook.Dispatcher
+	scheduler    *scheduler.Scheduler
 	logger       *slog.Logger
 }
 
 // New creates a new hub from configuration.
 func New(cfg *config.Config, opts Options, logger *slog.Logger) (*Hub, error) {
 	// Initialize storage.
-	db, err := store.New(cfg.Storage)
+	raw, err := store.New(cfg.Storage)
 	if err != nil {
 		return nil, fmt.Errorf("init storage: %w", err)
 	}
 
+	// Instrument every store call so /metrics exposes per-method latency.
+	m := metrics.NewHub()
+	db := store.Instrument(raw, m.ObserveStoreCall)
+	m.RegisterSessionStates(db.CountSessionsByState)
+
 	// Create auth provider based on config.
 	authProvider, err := auth.NewProvider(cfg.Auth, db)
 	if err != nil {
@@ -67,6 +78,12 @@ func New(cfg *config.Config, opts Options, logger *slog.Logger) (*Hub, error) {
 		runtimeAuth = ra
 	}
 
+	// Outbound webhooks are delivered asynchronously from router events.
+	webhooks := webhook.NewDispatcher(db, logger)
+
+	// Signs one-time approve/deny links for permission notifications.
+	permLinks := permlink.NewSigner(cfg.Server.BaseURL)
+
 	// Initialize router.
 	rt := router.New(db, authProvider, runtimeAuth, logger, router.Options{
 		TurnBased:         cfg.Session.TurnBased,
@@ -75,6 +92,9 @@ func New(cfg *config.Config, opts Options, logger *slog.Logger) (*Hub, error) {
 		MaxClientMsgBytes: cfg.Session.MaxMessageBytes,
 		FileStoragePath:   cfg.Server.FileStoragePath,
 		MaxFileBytes:      cfg.Server.MaxFileBytes,
+		Metrics:           m,
+		Webhooks:          webhooks,
+		PermissionLinks:   permLinks,
 	})
 
 	// Initialize billing (if factory provided and billing enabled).
@@ -91,14 +111,21 @@ func New(cfg *config.Config, opts Options, logger *slog.Logger) (*Hub, error) {
 		AuthProviderName:  authProvider.Name(),
 		StripePriceSingle: cfg.Billing.StripePriceSingle,
 		StripePriceTeam:   cfg.Billing.StripePriceTeam,
+		Metrics:           m,
+		PermissionLinks:   permLinks,
 	}, logger)
 
+	sched := scheduler.New(db, rt, logger)
+	sched.DefaultAgentAccess = cfg.Auth.DefaultAgentAccess
+
 	h := &Hub{
 		cfg:          cfg,
 		store:        db,
 		authProvider: authProvider,
 		router:       rt,
 		api:          apiSrv,
+		webhooks:     webhooks,
+		scheduler:    sched,
 		logger:       logger.With("component", "hub"),
 	}
 
@@ -118,6 +145,9 @@ func New(cfg *config.Config, opts Options, logger *slog.Logger) (*Hub, error) {
 			break
 		}
 	}
+	if cfg.Server.MetricsToken == "" {
+		logger.Warn("server.metrics_token is not configured — /metrics is served without authentication")
+	}
 	if cfg.Server.BaseURL == "" {
 		logger.Warn("server.base_url is not configured — device-code registration is limited to localhost")
 	}
@@ -158,6 +188,12 @@ func (h *Hub) Run(ctx context.Context) error {
 	// Start rate limiter cleanup tasks.
 	h.api.StartBackgroundTasks(ctx)
 
+	// Start webhook delivery workers.
+	go h.webhooks.Run(ctx)
+
+	// Start scheduled agent runs.
+	go h.scheduler.Run(ctx)
+
 	// Start retention purger.
 	if h.cfg.Storage.Retention.Duration > 0 {
 		go h.runRetentionPurger(ctx, h.cfg.Storage.Retention.Duration, h.cfg.Storage.AuditRetention.Duration)
@@ -219,6 +255,11 @@ func (h *Hub) runRetentionPurger(ctx context.Context, retention, auditRetention
 			} else if n > 0 {
 				h.logger.Info("retention purge: deleted old audit events", "count", n)
 			}
+			if n, err := h.store.PurgeOldWebhookDeliveries(ctx, auditCutoff); err != nil {
+				h.logger.Warn("retention purge: webhook deliveries failed", "error", err)
+			} else if n > 0 {
+				h.logger.Info("retention purge: deleted old webhook deliveries", "count", n)
+			}
 		}
 	}
 }
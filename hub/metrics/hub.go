@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"context"
+	"time"
+)
+
+// Hub holds the metrics exported by the hub process.
+type Hub struct {
+	Registry *Registry
+
+	RuntimesConnected *Gauge
+	ClientsConnected  *Gauge
+
+	AgentOutputMessages *CounterVec // by channel
+	AgentOutputBytes    *CounterVec // by channel
+
+	TurnsCompleted *Counter
+	TurnDuration   *Histogram
+
+	PermissionRequests  *Counter
+	PermissionResponses *CounterVec // by outcome: approved, denied, timeout, runtime_disconnect
+	PermissionLatency   *Histogram
+
+	StoreCallDuration *HistogramVec // by method
+	StoreCallErrors   *CounterVec   // by method
+
+	RateLimitRejections *CounterVec // by limiter
+}
+
+// NewHub creates the hub metric set on a fresh registry.
+func NewHub() *Hub {
+	r := NewRegistry()
+	return &Hub{
+		Registry: r,
+
+		RuntimesConnected: r.NewGauge("amurg_hub_runtimes_connected",
+			"Number of runtimes with an open WebSocket connection."),
+		ClientsConnected: r.NewGauge("amurg_hub_clients_connected",
+			"Number of UI clients with an open WebSocket connection."),
+
+		AgentOutputMessages: r.NewCounterVec("amurg_hub_agent_output_messages_total",
+			"Agent output messages persisted, by channel.", "channel"),
+		AgentOutputBytes: r.NewCounterVec("amurg_hub_agent_output_bytes_total",
+			"Bytes of agent output persisted, by channel.", "channel"),
+
+		TurnsCompleted: r.NewCounter("amurg_hub_turns_completed_total",
+			"Agent turns completed."),
+		TurnDuration: r.NewHistogram("amurg_hub_turn_duration_seconds",
+			"Time from turn.started to turn.completed.",
+			[]float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}),
+
+		PermissionRequests: r.NewCounter("amurg_hub_permission_requests_total",
+			"Permission requests received from runtimes."),
+		PermissionResponses: r.NewCounterVec("amurg_hub_permission_responses_total",
+			"Permission requests resolved, by outcome.", "outcome"),
+		PermissionLatency: r.NewHistogram("amurg_hub_permission_response_seconds",
+			"Time from a permission request until it was answered by a user.",
+			[]float64{1, 2.5, 5, 10, 20, 30, 45, 60, 120, 300}),
+
+		StoreCallDuration: r.NewHistogramVec("amurg_hub_store_call_duration_seconds",
+			"Latency of store calls, by method.", DefBuckets, "method"),
+		StoreCallErrors: r.NewCounterVec("amurg_hub_store_call_errors_total",
+			"Store calls that returned an error, by method.", "method"),
+
+		RateLimitRejections: r.NewCounterVec("amurg_hub_rate_limit_rejections_total",
+			"Requests rejected by a rate limiter, by limiter.", "limiter"),
+	}
+}
+
+// ObserveStoreCall records the latency and outcome of a single store call.
+// Its signature matches the observer expected by store.Instrument.
+func (h *Hub) ObserveStoreCall(method string, d time.Duration, err error) {
+	h.StoreCallDuration.With(method).Observe(d.Seconds())
+	if err != nil {
+		h.StoreCallErrors.With(method).Inc()
+	}
+}
+
+// RegisterSessionStates exports the number of sessions in each state, as
+// reported by count at scrape time.
+func (h *Hub) RegisterSessionStates(count func(ctx context.Context) (map[string]int, error)) {
+	h.Registry.NewGaugeFunc("amurg_hub_sessions", "Number of sessions, by state.", "state",
+		func() map[string]float64 {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			counts, err := count(ctx)
+			if err != nil {
+				return nil
+			}
+			out := make(map[string]float64, len(counts))
+			for state, n := range counts {
+				out[state] = float64(n)
+			}
+			return out
+		})
+}
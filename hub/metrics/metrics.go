@@ -0,0 +1,384 @@
+// Package metrics implements a small Prometheus-compatible metrics registry
+// and the set of metrics exported by the hub at /metrics.
+//
+// Only the subset of the Prometheus data model the hub needs is supported:
+// counters, gauges and histograms, optionally partitioned by labels. Output
+// uses the text exposition format (version 0.0.4), which is also accepted by
+// OpenMetrics scrapers.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default histogram buckets, in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector is implemented by every registered metric family.
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds a set of metric families and renders them on scrape.
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.collectors[c.name()]; exists {
+		panic("metrics: duplicate metric " + c.name())
+	}
+	r.collectors[c.name()] = c
+}
+
+// WriteText writes all registered metrics in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	cs := make([]collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		cs = append(cs, c)
+	}
+	r.mu.Unlock()
+	sort.Slice(cs, func(i, j int) bool { return cs[i].name() < cs[j].name() })
+
+	bw := bufio.NewWriter(w)
+	for _, c := range cs {
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler returns an http.Handler that serves the registry.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.WriteText(w)
+	})
+}
+
+// --- Counter ---
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	mu sync.Mutex
+	v  float64
+}
+
+// Inc increments the counter by one.
+func (c *Counter) Inc() { c.Add(1) }
+
+// Add increments the counter by v. Negative values are ignored.
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.v += v
+	c.mu.Unlock()
+}
+
+// Value returns the current value.
+func (c *Counter) Value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.v
+}
+
+func (c *Counter) writeSamples(w *bufio.Writer, name, labels string) {
+	writeSample(w, name, labels, c.Value())
+}
+
+// --- Gauge ---
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	mu sync.Mutex
+	v  float64
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	g.v = v
+	g.mu.Unlock()
+}
+
+// Add adds v (which may be negative) to the gauge.
+func (g *Gauge) Add(v float64) {
+	g.mu.Lock()
+	g.v += v
+	g.mu.Unlock()
+}
+
+// Inc increments the gauge by one.
+func (g *Gauge) Inc() { g.Add(1) }
+
+// Dec decrements the gauge by one.
+func (g *Gauge) Dec() { g.Add(-1) }
+
+// Value returns the current value.
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.v
+}
+
+func (g *Gauge) writeSamples(w *bufio.Writer, name, labels string) {
+	writeSample(w, name, labels, g.Value())
+}
+
+// --- Histogram ---
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	mu      sync.Mutex
+	upper   []float64
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	upper := append([]float64(nil), buckets...)
+	sort.Float64s(upper)
+	return &Histogram{upper: upper, buckets: make([]uint64, len(upper))}
+}
+
+// Observe records a single observation.
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, ub := range h.upper {
+		if v <= ub {
+			h.buckets[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+func (h *Histogram) writeSamples(w *bufio.Writer, name, labels string) {
+	h.mu.Lock()
+	buckets := append([]uint64(nil), h.buckets...)
+	sum, count := h.sum, h.count
+	h.mu.Unlock()
+
+	var cumulative uint64
+	for i, ub := range h.upper {
+		cumulative += buckets[i]
+		writeSample(w, name+"_bucket", joinLabels(labels, `le="`+formatFloat(ub)+`"`), float64(cumulative))
+	}
+	writeSample(w, name+"_bucket", joinLabels(labels, `le="+Inf"`), float64(count))
+	writeSample(w, name+"_sum", labels, sum)
+	writeSample(w, name+"_count", labels, float64(count))
+}
+
+// --- Families ---
+
+type sampler interface {
+	writeSamples(w *bufio.Writer, name, labels string)
+}
+
+// family is a named metric with zero or more label dimensions.
+type family[M sampler] struct {
+	metricName string
+	help       string
+	typ        string
+	labelNames []string
+	newMetric  func() M
+
+	mu       sync.Mutex
+	children map[string]M
+}
+
+func (f *family[M]) name() string { return f.metricName }
+
+// With returns the child metric for the given label values, creating it on
+// first use. The number of values must match the family's label names.
+func (f *family[M]) With(values ...string) M {
+	if len(values) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.metricName, len(f.labelNames), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, ok := f.children[key]
+	if !ok {
+		m = f.newMetric()
+		f.children[key] = m
+	}
+	return m
+}
+
+func (f *family[M]) write(w *bufio.Writer) {
+	f.mu.Lock()
+	keys := make([]string, 0, len(f.children))
+	for k := range f.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	children := make([]M, len(keys))
+	for i, k := range keys {
+		children[i] = f.children[k]
+	}
+	f.mu.Unlock()
+
+	writeHeader(w, f.metricName, f.help, f.typ)
+	for i, k := range keys {
+		var labels string
+		if len(f.labelNames) > 0 {
+			labels = formatLabels(f.labelNames, strings.Split(k, "\xff"))
+		}
+		children[i].writeSamples(w, f.metricName, labels)
+	}
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec = family[*Counter]
+
+// GaugeVec is a gauge partitioned by labels.
+type GaugeVec = family[*Gauge]
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec = family[*Histogram]
+
+// NewCounter registers and returns an unlabeled counter.
+func (r *Registry) NewCounter(name, help string) *Counter {
+	return r.NewCounterVec(name, help).With()
+}
+
+// NewCounterVec registers and returns a labeled counter family.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	f := &CounterVec{metricName: name, help: help, typ: "counter", labelNames: labels,
+		newMetric: func() *Counter { return &Counter{} }, children: make(map[string]*Counter)}
+	r.register(f)
+	return f
+}
+
+// NewGauge registers and returns an unlabeled gauge.
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	return r.NewGaugeVec(name, help).With()
+}
+
+// NewGaugeVec registers and returns a labeled gauge family.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	f := &GaugeVec{metricName: name, help: help, typ: "gauge", labelNames: labels,
+		newMetric: func() *Gauge { return &Gauge{} }, children: make(map[string]*Gauge)}
+	r.register(f)
+	return f
+}
+
+// NewHistogram registers and returns an unlabeled histogram.
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	return r.NewHistogramVec(name, help, buckets).With()
+}
+
+// NewHistogramVec registers and returns a labeled histogram family.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	f := &HistogramVec{metricName: name, help: help, typ: "histogram", labelNames: labels,
+		newMetric: func() *Histogram { return newHistogram(buckets) }, children: make(map[string]*Histogram)}
+	r.register(f)
+	return f
+}
+
+// gaugeFunc is a gauge family whose values are computed on every scrape.
+type gaugeFunc struct {
+	metricName string
+	help       string
+	label      string
+	fn         func() map[string]float64
+}
+
+func (g *gaugeFunc) name() string { return g.metricName }
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	values := g.fn()
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	writeHeader(w, g.metricName, g.help, "gauge")
+	for _, k := range keys {
+		writeSample(w, g.metricName, formatLabels([]string{g.label}, []string{k}), values[k])
+	}
+}
+
+// NewGaugeFunc registers a gauge family partitioned by a single label whose
+// values are produced by fn at scrape time. fn must be safe for concurrent use.
+func (r *Registry) NewGaugeFunc(name, help, label string, fn func() map[string]float64) {
+	r.register(&gaugeFunc{metricName: name, help: help, label: label, fn: fn})
+}
+
+// --- Text format helpers ---
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, helpEscaper.Replace(help), name, typ)
+}
+
+func writeSample(w *bufio.Writer, name, labels string, v float64) {
+	_, _ = w.WriteString(name)
+	if labels != "" {
+		_, _ = w.WriteString("{" + labels + "}")
+	}
+	_, _ = w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+func formatLabels(names, values []string) string {
+	parts := make([]string, len(names))
+	for i, n := range names {
+		parts[i] = n + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return strings.Join(parts, ",")
+}
+
+func joinLabels(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "," + b
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	return buf.String()
+}
+
+func TestCounterAndGauge(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_events_total", "Events seen.")
+	g := r.NewGauge("test_connections", "Open connections.")
+
+	c.Inc()
+	c.Add(2)
+	c.Add(-5) // ignored
+	g.Inc()
+	g.Inc()
+	g.Dec()
+
+	out := render(t, r)
+	for _, want := range []string{
+		"# HELP test_events_total Events seen.\n# TYPE test_events_total counter\ntest_events_total 3\n",
+		"# TYPE test_connections gauge\ntest_connections 1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+	// Families are sorted by name.
+	if strings.Index(out, "test_connections") > strings.Index(out, "test_events_total") {
+		t.Error("expected families to be sorted by name")
+	}
+}
+
+func TestCounterVecLabels(t *testing.T) {
+	r := NewRegistry()
+	v := r.NewCounterVec("test_output_total", "Output.", "channel")
+	v.With("stdout").Add(2)
+	v.With("stderr").Inc()
+	v.With(`we"ird`).Inc()
+
+	out := render(t, r)
+	for _, want := range []string{
+		`test_output_total{channel="stdout"} 2`,
+		`test_output_total{channel="stderr"} 1`,
+		`test_output_total{channel="we\"ird"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestCounterVecWrongLabelCountPanics(t *testing.T) {
+	r := NewRegistry()
+	v := r.NewCounterVec("test_total", "x", "a", "b")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for wrong label count")
+		}
+	}()
+	v.With("only-one")
+}
+
+func TestDuplicateRegistrationPanics(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("dup_total", "x")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate registration")
+		}
+	}()
+	r.NewGauge("dup_total", "x")
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("test_seconds", "Latency.", []float64{1, 5})
+	h.Observe(0.5)
+	h.Observe(3)
+	h.Observe(10)
+
+	out := render(t, r)
+	for _, want := range []string{
+		"# TYPE test_seconds histogram\n",
+		`test_seconds_bucket{le="1"} 1`,
+		`test_seconds_bucket{le="5"} 2`,
+		`test_seconds_bucket{le="+Inf"} 3`,
+		"test_seconds_sum 13.5",
+		"test_seconds_count 3",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestHistogramVecLabelsCombineWithLe(t *testing.T) {
+	r := NewRegistry()
+	v := r.NewHistogramVec("test_call_seconds", "Calls.", []float64{1}, "method")
+	v.With("GetSession").Observe(0.1)
+
+	out := render(t, r)
+	if !strings.Contains(out, `test_call_seconds_bucket{method="GetSession",le="1"} 1`) {
+		t.Errorf("expected combined labels, got\n%s", out)
+	}
+	if !strings.Contains(out, `test_call_seconds_count{method="GetSession"} 1`) {
+		t.Errorf("expected labeled count, got\n%s", out)
+	}
+}
+
+func TestHubSessionStatesAndStoreCalls(t *testing.T) {
+	h := NewHub()
+	h.RegisterSessionStates(func(context.Context) (map[string]int, error) {
+		return map[string]int{"active": 2, "closed": 5}, nil
+	})
+	h.ObserveStoreCall("GetSession", 2*time.Millisecond, nil)
+	h.ObserveStoreCall("GetSession", 3*time.Millisecond, errors.New("boom"))
+
+	out := render(t, h.Registry)
+	for _, want := range []string{
+		`amurg_hub_sessions{state="active"} 2`,
+		`amurg_hub_sessions{state="closed"} 5`,
+		`amurg_hub_store_call_duration_seconds_count{method="GetSession"} 2`,
+		`amurg_hub_store_call_errors_total{method="GetSession"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+}
+
+func TestHandlerContentType(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("x_total", "x").Inc()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if !strings.Contains(w.Body.String(), "x_total 1") {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+}
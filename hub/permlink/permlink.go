@@ -0,0 +1,78 @@
+// Package permlink signs approve/deny links for pending permission requests.
+//
+// A link lets someone answer a permission request without an authenticated
+// session, e.g. from a phone notification. The link is bound to a single
+// request ID and decision and carries an expiry; because a request can only be
+// answered once, every link becomes unusable as soon as any decision is made.
+package permlink
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Decisions a link can carry.
+const (
+	DecisionApprove = "approve"
+	DecisionDeny    = "deny"
+)
+
+var (
+	// ErrInvalidSignature is returned when a link was not issued by this signer.
+	ErrInvalidSignature = errors.New("invalid link signature")
+	// ErrExpired is returned when a link's expiry has passed.
+	ErrExpired = errors.New("link expired")
+)
+
+// Signer issues and verifies permission links.
+type Signer struct {
+	key     []byte
+	baseURL string
+}
+
+// NewSigner creates a signer with a random per-process key. Pending permissions
+// live in memory, so links never need to outlive the process that issued them.
+// baseURL prefixes generated links; when empty, links are host-relative.
+func NewSigner(baseURL string) *Signer {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return &Signer{key: key, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// URL returns a signed link that applies decision to the given request.
+func (s *Signer) URL(requestID, decision string, expires time.Time) string {
+	exp := expires.Unix()
+	q := url.Values{}
+	q.Set("decision", decision)
+	q.Set("expires", strconv.FormatInt(exp, 10))
+	q.Set("sig", s.sign(requestID, decision, exp))
+	return s.baseURL + "/api/permissions/" + url.PathEscape(requestID) + "/link?" + q.Encode()
+}
+
+// Verify checks a link's signature and expiry.
+func (s *Signer) Verify(requestID, decision string, expires int64, sig string, now time.Time) error {
+	if decision != DecisionApprove && decision != DecisionDeny {
+		return ErrInvalidSignature
+	}
+	want := s.sign(requestID, decision, expires)
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		return ErrInvalidSignature
+	}
+	if now.Unix() > expires {
+		return ErrExpired
+	}
+	return nil
+}
+
+func (s *Signer) sign(requestID, decision string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(requestID + "\n" + decision + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
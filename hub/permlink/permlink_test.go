@@ -0,0 +1,41 @@
+package permlink
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignerRoundTrip(t *testing.T) {
+	s := NewSigner("https://hub.example.com/")
+	expires := time.Now().Add(time.Minute)
+
+	link := s.URL("req/1", DecisionApprove, expires)
+	if !strings.HasPrefix(link, "https://hub.example.com/api/permissions/req%2F1/link?") {
+		t.Fatalf("unexpected link %q", link)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	exp, _ := strconv.ParseInt(q.Get("expires"), 10, 64)
+
+	if err := s.Verify("req/1", q.Get("decision"), exp, q.Get("sig"), time.Now()); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := s.Verify("req/1", DecisionDeny, exp, q.Get("sig"), time.Now()); err != ErrInvalidSignature {
+		t.Errorf("swapped decision: err = %v, want ErrInvalidSignature", err)
+	}
+	if err := s.Verify("req/2", DecisionApprove, exp, q.Get("sig"), time.Now()); err != ErrInvalidSignature {
+		t.Errorf("other request: err = %v, want ErrInvalidSignature", err)
+	}
+	if err := s.Verify("req/1", DecisionApprove, exp, q.Get("sig"), expires.Add(2*time.Second)); err != ErrExpired {
+		t.Errorf("after expiry: err = %v, want ErrExpired", err)
+	}
+	if err := NewSigner("").Verify("req/1", DecisionApprove, exp, q.Get("sig"), time.Now()); err != ErrInvalidSignature {
+		t.Errorf("other signer: err = %v, want ErrInvalidSignature", err)
+	}
+}
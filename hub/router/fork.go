@@ -0,0 +1,113 @@
+package router
+
+import (
+	"context"
+	"strings"
+
+	"github.com/amurg-ai/amurg/hub/store"
+)
+
+// Fork modes recorded on forked sessions.
+const (
+	// ForkNative forks branch the agent's native session, which keeps its
+	// context.
+	ForkNative = "native"
+	// ForkTranscript forks start a fresh agent, which the hub hands the copied
+	// transcript along with the first prompt.
+	ForkTranscript = "transcript"
+)
+
+// forkTranscriptMaxBytes bounds the transcript that seeds a transcript-only
+// fork. The oldest turns are dropped first.
+const forkTranscriptMaxBytes = 256 * 1024
+
+// seedForkPrompt returns the content to send the runtime for the user message
+// stored at seq. The first prompt of a transcript-only fork is prefixed with
+// the copied transcript, since the agent behind it has never seen it; every
+// other prompt is sent as is.
+func (r *Router) seedForkPrompt(ctx context.Context, sess *store.Session, seq int64, content string) string {
+	if sess.ForkMode != ForkTranscript || seq <= sess.ForkSeq {
+		return content
+	}
+	since, err := r.store.GetMessages(ctx, sess.ID, sess.ForkSeq, int(seq-sess.ForkSeq))
+	if err != nil {
+		r.logger.Warn("list fork messages failed", "session_id", sess.ID, "error", err)
+		return content
+	}
+	for _, m := range since {
+		if m.Seq < seq && m.Direction == "user" {
+			return content
+		}
+	}
+
+	transcript, err := forkTranscript(ctx, r.store, sess.ID, sess.ForkSeq)
+	if err != nil {
+		r.logger.Warn("read fork transcript failed", "session_id", sess.ID, "error", err)
+		return content
+	}
+	if transcript == "" {
+		return content
+	}
+	return "This conversation continues an earlier one that you have no memory of. " +
+		"Its transcript so far:\n\n<transcript>\n" + transcript + "</transcript>\n\n" + content
+}
+
+// forkTranscript renders the user prompts and agent replies stored in a
+// session up to uptoSeq, keeping the most recent forkTranscriptMaxBytes.
+func forkTranscript(ctx context.Context, s store.Store, sessionID string, uptoSeq int64) (string, error) {
+	var turns []string
+	var turn strings.Builder
+	lastDir := ""
+	flush := func() {
+		if turn.Len() > 0 {
+			turns = append(turns, strings.TrimRight(turn.String(), "\n")+"\n\n")
+			turn.Reset()
+		}
+	}
+
+	afterSeq := int64(0)
+	for afterSeq < uptoSeq {
+		msgs, err := s.GetMessages(ctx, sessionID, afterSeq, 500)
+		if err != nil {
+			return "", err
+		}
+		for _, m := range msgs {
+			if m.Seq > uptoSeq {
+				break
+			}
+			afterSeq = m.Seq
+			var label string
+			switch {
+			case m.Direction == "user" && m.Channel == "stdin":
+				label = "User"
+			case m.Direction == "agent" && m.Channel == "stdout":
+				label = "Agent"
+			default:
+				continue
+			}
+			// Agent output arrives in chunks; consecutive ones form one reply.
+			if label != lastDir || label == "User" {
+				flush()
+				turn.WriteString(label + ": ")
+				lastDir = label
+			}
+			turn.WriteString(m.Content)
+		}
+		if len(msgs) < 500 {
+			break
+		}
+	}
+	flush()
+
+	size := 0
+	first := len(turns)
+	for first > 0 && size+len(turns[first-1]) <= forkTranscriptMaxBytes {
+		first--
+		size += len(turns[first])
+	}
+	out := strings.Join(turns[first:], "")
+	if first > 0 {
+		out = "[earlier messages omitted]\n\n" + out
+	}
+	return out, nil
+}
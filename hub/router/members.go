@@ -0,0 +1,47 @@
+package router
+
+import (
+	"context"
+
+	"github.com/amurg-ai/amurg/hub/store"
+)
+
+// CanViewSession reports whether a user may watch a session and read its
+// transcript: the owner, an admin, or any member the session is shared with.
+func (r *Router) CanViewSession(ctx context.Context, sess *store.Session, userID, role string) bool {
+	if sess.UserID == userID || role == "admin" {
+		return true
+	}
+	m, err := r.store.GetSessionMember(ctx, sess.ID, userID)
+	if err != nil {
+		r.logger.Warn("get session member failed", "session_id", sess.ID, "user_id", userID, "error", err)
+		return false
+	}
+	return m != nil
+}
+
+// CanCollaborate reports whether a user may send input to a session, stop it,
+// and answer its permission requests: the owner or a collaborator.
+func (r *Router) CanCollaborate(ctx context.Context, sess *store.Session, userID string) bool {
+	if sess.UserID == userID {
+		return true
+	}
+	m, err := r.store.GetSessionMember(ctx, sess.ID, userID)
+	if err != nil {
+		r.logger.Warn("get session member failed", "session_id", sess.ID, "user_id", userID, "error", err)
+		return false
+	}
+	return m != nil && m.Role == store.MemberCollaborator
+}
+
+// UnsubscribeUser drops a user's live subscriptions to a session, e.g. after
+// their membership is revoked.
+func (r *Router) UnsubscribeUser(sessionID, userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, cc := range r.subscribers[sessionID] {
+		if cc.userID == userID {
+			delete(r.subscribers[sessionID], id)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/amurg-ai/amurg/hub/permlink"
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/amurg-ai/amurg/pkg/protocol"
+	"github.com/google/uuid"
+)
+
+// ErrPermissionNotPending is returned when a permission request has already
+// been answered, timed out, or never existed.
+var ErrPermissionNotPending = errors.New("permission request is not pending")
+
+// PendingPermission is a permission request that is waiting for a decision.
+type PendingPermission struct {
+	RequestID   string    `json:"request_id"`
+	SessionID   string    `json:"session_id"`
+	OrgID       string    `json:"org_id"`
+	AgentID     string    `json:"agent_id"`
+	UserID      string    `json:"user_id"` // session owner
+	Tool        string    `json:"tool"`
+	Description string    `json:"description"`
+	Resource    string    `json:"resource,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func (r *Router) toPendingPermission(pp *pendingPermission) PendingPermission {
+	return PendingPermission{
+		RequestID:   pp.requestID,
+		SessionID:   pp.sessionID,
+		OrgID:       pp.orgID,
+		AgentID:     pp.agentID,
+		UserID:      pp.userID,
+		Tool:        pp.tool,
+		Description: pp.description,
+		Resource:    pp.resource,
+		CreatedAt:   pp.createdAt,
+		ExpiresAt:   pp.createdAt.Add(r.permissionTimeout),
+	}
+}
+
+// ListPendingPermissions returns the pending permission requests in an org,
+// oldest first. A non-empty userID restricts the list to that user's sessions.
+func (r *Router) ListPendingPermissions(orgID, userID string) []PendingPermission {
+	r.mu.RLock()
+	result := make([]PendingPermission, 0, len(r.pendingPerms))
+	for _, pp := range r.pendingPerms {
+		if pp.orgID != orgID || (userID != "" && pp.userID != userID) {
+			continue
+		}
+		result = append(result, r.toPendingPermission(pp))
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	return result
+}
+
+// GetPendingPermission looks up a single pending permission request.
+func (r *Router) GetPendingPermission(requestID string) (PendingPermission, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	pp, ok := r.pendingPerms[requestID]
+	if !ok {
+		return PendingPermission{}, false
+	}
+	return r.toPendingPermission(pp), true
+}
+
+// ResolvePermission answers a pending permission request on behalf of userID
+// (empty for signed links) and relays the decision to the runtime and any
+// subscribed clients. via records how the decision was made ("api", "link").
+func (r *Router) ResolvePermission(ctx context.Context, requestID, sessionID string, approved, alwaysAllow bool, userID, via string) error {
+	pp := r.takePendingPermission(requestID, sessionID)
+	if pp == nil {
+		return ErrPermissionNotPending
+	}
+	resp := protocol.PermissionResponse{
+		SessionID:   pp.sessionID,
+		RequestID:   pp.requestID,
+		Approved:    approved,
+		AlwaysAllow: alwaysAllow,
+	}
+	r.answerPermission(ctx, pp, resp, pp.orgID, userID, via)
+
+	// Clients watching the session are still showing the prompt; let them dismiss it.
+	r.broadcastToSession(pp.sessionID, protocol.TypePermissionResponse, resp)
+	return nil
+}
+
+// takePendingPermission removes a pending permission belonging to sessionID
+// and stops its timeout. It returns nil if there is no such request.
+func (r *Router) takePendingPermission(requestID, sessionID string) *pendingPermission {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pp, ok := r.pendingPerms[requestID]
+	if !ok || pp.sessionID != sessionID {
+		return nil
+	}
+	pp.timer.Stop()
+	delete(r.pendingPerms, requestID)
+	return pp
+}
+
+// answerPermission records a decision for a permission already taken from
+// pendingPerms and relays it to the runtime.
+func (r *Router) answerPermission(ctx context.Context, pp *pendingPermission, resp protocol.PermissionResponse, orgID, userID, via string) {
+	action := "permission.denied"
+	outcome := "denied"
+	if resp.Approved {
+		action = "permission.granted"
+		outcome = "approved"
+	}
+	r.metrics.PermissionResponses.With(outcome).Inc()
+	r.metrics.PermissionLatency.Observe(time.Since(pp.createdAt).Seconds())
+	if err := r.store.LogAuditEvent(ctx, &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: orgID, Action: action,
+		UserID: userID, SessionID: pp.sessionID, AgentID: pp.agentID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"request_id":%q,"approved":%t,"via":%q}`, pp.requestID, resp.Approved, via)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		r.logger.Warn("failed to log audit event", "action", action, "error", err)
+	}
+
+	r.sendToRuntime(pp.runtimeID, protocol.TypePermissionResponse, pp.sessionID, resp)
+
+	if resp.Approved && resp.AlwaysAllow {
+		r.persistAlwaysAllow(ctx, pp, userID)
+	}
+}
+
+// permissionEventData builds the webhook payload data for a permission
+// request, including signed approve/deny links when a signer is configured.
+func (r *Router) permissionEventData(pp *pendingPermission) json.RawMessage {
+	data := map[string]any{
+		"request_id":  pp.requestID,
+		"tool":        pp.tool,
+		"description": pp.description,
+		"resource":    pp.resource,
+	}
+	if r.permLinks != nil {
+		expires := pp.createdAt.Add(r.permissionTimeout)
+		data["expires_at"] = expires
+		data["approve_url"] = r.permLinks.URL(pp.requestID, permlink.DecisionApprove, expires)
+		data["deny_url"] = r.permLinks.URL(pp.requestID, permlink.DecisionDeny, expires)
+	}
+	b, _ := json.Marshal(data)
+	return b
+}
@@ -0,0 +1,158 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/amurg-ai/amurg/pkg/protocol"
+	"github.com/google/uuid"
+)
+
+// Policy effects.
+const (
+	PolicyAllow = "allow"
+	PolicyDeny  = "deny"
+)
+
+// PolicyMatches reports whether a policy applies to a permission request made
+// in a session of userID on agentID. The description is free text from the
+// agent, so allow rules match it only when the request names no resource;
+// deny rules match either.
+func PolicyMatches(p *store.PermissionPolicy, agentID, userID string, req protocol.PermissionRequest) bool {
+	if p.AgentID != "" && p.AgentID != agentID {
+		return false
+	}
+	if p.UserID != "" && p.UserID != userID {
+		return false
+	}
+	if p.Tool != "" && p.Tool != "*" && !strings.EqualFold(p.Tool, req.Tool) {
+		return false
+	}
+	if p.Pattern == "" {
+		return true
+	}
+	match := wildcardMatch
+	if p.Exact {
+		match = func(pattern, s string) bool { return pattern == s }
+	}
+	if p.Effect != PolicyDeny && req.Resource != "" {
+		return match(p.Pattern, req.Resource)
+	}
+	return (req.Resource != "" && match(p.Pattern, req.Resource)) || match(p.Pattern, req.Description)
+}
+
+// wildcardMatch matches s against pattern, where '*' matches any run of
+// characters (including '/') and everything else is literal.
+func wildcardMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, last)
+}
+
+// evaluatePolicies returns the policy that decides req, or nil when no policy
+// applies. Deny rules take precedence over allow rules.
+func (r *Router) evaluatePolicies(ctx context.Context, orgID, agentID, userID string, req protocol.PermissionRequest) *store.PermissionPolicy {
+	policies, err := r.store.ListPermissionPolicies(ctx, orgID)
+	if err != nil {
+		r.logger.Warn("list permission policies failed", "org_id", orgID, "error", err)
+		return nil
+	}
+	var allow *store.PermissionPolicy
+	for i := range policies {
+		p := &policies[i]
+		if !PolicyMatches(p, agentID, userID, req) {
+			continue
+		}
+		if p.Effect == PolicyDeny {
+			return p
+		}
+		if allow == nil {
+			allow = p
+		}
+	}
+	return allow
+}
+
+// applyPolicy answers a permission request on behalf of a policy without
+// involving any client.
+func (r *Router) applyPolicy(ctx context.Context, runtimeID string, p *store.PermissionPolicy, req protocol.PermissionRequest, agentID string) {
+	approved := p.Effect == PolicyAllow
+	action := "permission.auto_denied"
+	outcome := "auto_denied"
+	if approved {
+		action = "permission.auto_granted"
+		outcome = "auto_approved"
+	}
+	r.metrics.PermissionResponses.With(outcome).Inc()
+	if err := r.store.LogAuditEvent(ctx, &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: p.OrgID, Action: action,
+		SessionID: req.SessionID, AgentID: agentID,
+		Detail: json.RawMessage(fmt.Sprintf(`{"request_id":%q,"tool":%q,"resource":%q,"policy_id":%q}`,
+			req.RequestID, req.Tool, req.Resource, p.ID)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		r.logger.Warn("failed to log audit event", "action", action, "error", err)
+	}
+
+	r.sendToRuntime(runtimeID, protocol.TypePermissionResponse, req.SessionID, protocol.PermissionResponse{
+		SessionID: req.SessionID,
+		RequestID: req.RequestID,
+		Approved:  approved,
+	})
+}
+
+// persistAlwaysAllow stores an "always allow" answer as an allow policy scoped
+// to the session's org, agent and user, so later sessions skip the prompt. The
+// rule matches the approved resource exactly: a '*' the user approved as part
+// of a command must not widen it to commands they never saw.
+func (r *Router) persistAlwaysAllow(ctx context.Context, pp *pendingPermission, userID string) {
+	pattern := pp.resource
+	if pattern == "" {
+		pattern = pp.description
+	}
+	now := time.Now()
+	p := &store.PermissionPolicy{
+		ID:          uuid.New().String(),
+		OrgID:       pp.orgID,
+		AgentID:     pp.agentID,
+		UserID:      pp.userID,
+		Tool:        pp.tool,
+		Pattern:     pattern,
+		Exact:       true,
+		Effect:      PolicyAllow,
+		Description: "always allow",
+		CreatedBy:   userID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := r.store.CreatePermissionPolicy(ctx, p); err != nil {
+		r.logger.Warn("failed to persist always-allow policy", "request_id", pp.requestID, "error", err)
+		return
+	}
+	if err := r.store.LogAuditEvent(ctx, &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: pp.orgID, Action: "permission_policy.created",
+		UserID: userID, SessionID: pp.sessionID, AgentID: pp.agentID,
+		Detail:    json.RawMessage(fmt.Sprintf(`{"policy_id":%q,"tool":%q,"pattern":%q,"effect":"allow","via":"always_allow"}`, p.ID, p.Tool, p.Pattern)),
+		CreatedAt: now,
+	}); err != nil {
+		r.logger.Warn("failed to log audit event", "action", "permission_policy.created", "error", err)
+	}
+}
@@ -0,0 +1,181 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/amurg-ai/amurg/pkg/promptprofile"
+	"github.com/amurg-ai/amurg/pkg/protocol"
+	"github.com/google/uuid"
+)
+
+// ErrRuntimeOffline is returned when a prompt cannot be delivered because the
+// session's runtime is not connected.
+var ErrRuntimeOffline = errors.New("runtime is offline")
+
+// RuntimeOnline reports whether a runtime is currently connected to the hub.
+func (r *Router) RuntimeOnline(runtimeID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.runtimes[runtimeID]
+	return ok
+}
+
+// SendPrompt sends a user message to a session on behalf of authorID without a
+// client connection, the way a client's user.message would be handled. The
+// returned channel receives the session's next TurnCompleted; seq is the
+// stored message's sequence number.
+func (r *Router) SendPrompt(ctx context.Context, sess *store.Session, authorID, content string) (seq int64, done <-chan protocol.TurnCompleted, err error) {
+	if int64(len(content)) > r.maxContentBytes {
+		return 0, nil, fmt.Errorf("prompt exceeds maximum size of %d bytes", r.maxContentBytes)
+	}
+	if !r.RuntimeOnline(sess.RuntimeID) {
+		return 0, nil, ErrRuntimeOffline
+	}
+
+	msg := protocol.UserMessage{
+		SessionID:     sess.ID,
+		MessageID:     uuid.New().String(),
+		Content:       content,
+		AgentID:       sess.AgentID,
+		UserID:        sess.UserID,
+		PromptProfile: promptprofile.Normalize(sess.PromptProfile),
+		NativeHandle:  sess.NativeHandle,
+	}
+	stored := &store.Message{
+		ID:        msg.MessageID,
+		SessionID: sess.ID,
+		Direction: "user",
+		Channel:   "stdin",
+		Content:   content,
+		AuthorID:  authorID,
+		CreatedAt: time.Now(),
+	}
+	if stored.Seq, err = r.store.AppendMessage(ctx, stored); err != nil {
+		return 0, nil, fmt.Errorf("persist prompt: %w", err)
+	}
+	msg.Content = r.seedForkPrompt(ctx, sess, stored.Seq, content)
+	r.broadcastToSession(sess.ID, protocol.TypeHistoryResponse, protocol.HistoryResponse{
+		SessionID: sess.ID,
+		Messages:  []protocol.StoredMessage{toStoredMessage(stored)},
+	})
+	if err := r.store.LogAuditEvent(ctx, &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: sess.OrgID, Action: "message.sent", UserID: authorID,
+		SessionID: sess.ID, AgentID: sess.AgentID, CreatedAt: time.Now(),
+	}); err != nil {
+		r.logger.Warn("failed to log audit event", "action", "message.sent", "error", err)
+	}
+
+	ch := make(chan protocol.TurnCompleted, 1)
+	r.mu.Lock()
+	r.turnWaiters[sess.ID] = append(r.turnWaiters[sess.ID], ch)
+	r.mu.Unlock()
+
+	if !r.sendToRuntime(sess.RuntimeID, protocol.TypeUserMessage, sess.ID, msg) {
+		r.removeTurnWaiter(sess.ID, ch)
+		return stored.Seq, nil, ErrRuntimeOffline
+	}
+	return stored.Seq, ch, nil
+}
+
+// notifyTurnWaiters hands a completed turn to everyone waiting on the session.
+func (r *Router) notifyTurnWaiters(tc protocol.TurnCompleted) {
+	r.mu.Lock()
+	waiters := r.turnWaiters[tc.SessionID]
+	delete(r.turnWaiters, tc.SessionID)
+	r.mu.Unlock()
+
+	for _, ch := range waiters {
+		ch <- tc
+	}
+}
+
+// CancelTurnWait stops delivering the session's next TurnCompleted to done, a
+// channel returned by SendPrompt, for callers that gave up waiting.
+func (r *Router) CancelTurnWait(sessionID string, done <-chan protocol.TurnCompleted) {
+	r.removeTurnWaiter(sessionID, done)
+}
+
+func (r *Router) removeTurnWaiter(sessionID string, ch <-chan protocol.TurnCompleted) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	waiters := r.turnWaiters[sessionID]
+	for i, w := range waiters {
+		if w == ch {
+			r.turnWaiters[sessionID] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(r.turnWaiters[sessionID]) == 0 {
+		delete(r.turnWaiters, sessionID)
+	}
+}
+
+// WatchOutput returns a channel that is signaled whenever agent output for the
+// session is stored, and a function that stops watching. Signals coalesce, so a
+// watcher should read new messages from the store after each one.
+func (r *Router) WatchOutput(sessionID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	r.mu.Lock()
+	r.outputWatchers[sessionID] = append(r.outputWatchers[sessionID], ch)
+	r.mu.Unlock()
+
+	return ch, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		watchers := r.outputWatchers[sessionID]
+		for i, w := range watchers {
+			if w == ch {
+				r.outputWatchers[sessionID] = append(watchers[:i], watchers[i+1:]...)
+				break
+			}
+		}
+		if len(r.outputWatchers[sessionID]) == 0 {
+			delete(r.outputWatchers, sessionID)
+		}
+	}
+}
+
+func (r *Router) notifyOutputWatchers(sessionID string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, ch := range r.outputWatchers[sessionID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// CollectOutput joins the agent's stdout stored after afterSeq, truncated to
+// max bytes. It returns what it gathered before any store error.
+func CollectOutput(ctx context.Context, s store.Store, sessionID string, afterSeq int64, max int) (string, error) {
+	var b strings.Builder
+	for b.Len() < max {
+		msgs, err := s.GetMessages(ctx, sessionID, afterSeq, 500)
+		if err != nil {
+			return truncate(b.String(), max), err
+		}
+		for _, m := range msgs {
+			if m.Direction == "agent" && m.Channel == "stdout" {
+				b.WriteString(m.Content)
+			}
+			afterSeq = m.Seq
+		}
+		if len(msgs) < 500 {
+			break
+		}
+	}
+	return truncate(b.String(), max), nil
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
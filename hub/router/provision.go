@@ -0,0 +1,120 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/amurg-ai/amurg/pkg/protocol"
+)
+
+// provisionTimeout bounds how long ProvisionAgent waits for the runtime.
+const provisionTimeout = 30 * time.Second
+
+// ErrProvisionTimeout is returned when the runtime does not acknowledge an
+// agent provisioning in time. The change may still have been applied.
+var ErrProvisionTimeout = errors.New("runtime did not acknowledge the agent provisioning")
+
+// ProvisionError is a provisioning the runtime refused or failed to apply.
+type ProvisionError struct {
+	Reason string
+}
+
+func (e *ProvisionError) Error() string { return "provisioning failed: " + e.Reason }
+
+type pendingProvision struct {
+	runtimeID string
+	ch        chan protocol.AgentProvisionAck
+}
+
+// ProvisionAgent asks a runtime to create, update or delete an agent
+// definition and waits for the result. The runtime announces its new agent
+// list before acknowledging, so the agent store is current when this
+// returns. An updated agent gets its stored config override pushed again,
+// since the new definition replaced it on the runtime.
+func (r *Router) ProvisionAgent(ctx context.Context, runtimeID, action, agentID string, cfg json.RawMessage) error {
+	req := protocol.AgentProvision{
+		RequestID: uuid.New().String(),
+		Action:    action,
+		AgentID:   agentID,
+		Config:    cfg,
+	}
+
+	pending := &pendingProvision{runtimeID: runtimeID, ch: make(chan protocol.AgentProvisionAck, 1)}
+	r.mu.Lock()
+	r.pendingProvisions[req.RequestID] = pending
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.pendingProvisions, req.RequestID)
+		r.mu.Unlock()
+	}()
+
+	if !r.sendToRuntime(runtimeID, protocol.TypeAgentProvision, "", req) {
+		return ErrRuntimeOffline
+	}
+
+	timer := time.NewTimer(provisionTimeout)
+	defer timer.Stop()
+	select {
+	case ack := <-pending.ch:
+		if !ack.OK {
+			return &ProvisionError{Reason: ack.Error}
+		}
+	case <-timer.C:
+		return ErrProvisionTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if action == protocol.ProvisionUpdate {
+		if update := r.storedConfigOverride(ctx, agentID); update != nil {
+			r.sendToRuntime(runtimeID, protocol.TypeAgentConfigUpdate, "", *update)
+		}
+	}
+	return nil
+}
+
+// handleProvisionAck hands a runtime's provisioning result to the waiting
+// caller. Acks from runtimes the request was not sent to are dropped.
+func (r *Router) handleProvisionAck(runtimeID string, ack protocol.AgentProvisionAck) {
+	r.mu.RLock()
+	pending, ok := r.pendingProvisions[ack.RequestID]
+	r.mu.RUnlock()
+	if !ok {
+		return
+	}
+	if pending.runtimeID != runtimeID {
+		r.logger.Warn("dropping provision ack from foreign runtime", "agent_id", ack.AgentID, "runtime", runtimeID)
+		return
+	}
+	select {
+	case pending.ch <- ack:
+	default:
+	}
+}
+
+// handleAgentsAnnounce replaces a connected runtime's agents with the list
+// it announced after provisioning.
+func (r *Router) handleAgentsAnnounce(runtimeID string, agents []protocol.AgentRegistration) {
+	byID := make(map[string]protocol.AgentRegistration, len(agents))
+	for _, agent := range agents {
+		byID[agent.ID] = agent
+	}
+
+	r.mu.Lock()
+	rt, ok := r.runtimes[runtimeID]
+	if ok {
+		rt.agents = byID
+	}
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	r.registerAgents(context.Background(), rt.orgID, runtimeID, agents)
+	r.logger.Info("runtime agents updated", "runtime_id", runtimeID, "agents", len(agents))
+}
@@ -0,0 +1,64 @@
+package router
+
+import (
+	"context"
+
+	"github.com/amurg-ai/amurg/pkg/protocol"
+)
+
+// reconcileSessions brings the hub's view of a runtime's sessions in line
+// with the sessions the runtime announced after registering. Sessions the
+// hub closed, or never handed to this runtime, are closed on the runtime.
+// Turns lost to a runtime restart are completed so clients stop waiting.
+func (r *Router) reconcileSessions(runtimeID string, announced []protocol.AnnouncedSession) {
+	ctx := context.Background()
+	for _, a := range announced {
+		sess, err := r.store.GetSession(ctx, a.SessionID)
+		if err != nil {
+			r.logger.Warn("reconcile: get session failed", "session_id", a.SessionID, "error", err)
+			continue
+		}
+		if sess == nil || sess.RuntimeID != runtimeID || sess.State == "closed" {
+			r.logger.Info("reconcile: closing session unknown to the hub", "session_id", a.SessionID, "runtime_id", runtimeID)
+			r.sendToRuntime(runtimeID, protocol.TypeSessionClose, a.SessionID, protocol.SessionClose{
+				SessionID: a.SessionID,
+				Reason:    "session is closed",
+			})
+			continue
+		}
+
+		if a.NativeHandle != "" && a.NativeHandle != sess.NativeHandle {
+			if err := r.store.SetSessionNativeHandle(ctx, a.SessionID, a.NativeHandle); err != nil {
+				r.logger.Warn("reconcile: set native handle failed", "session_id", a.SessionID, "error", err)
+			}
+		}
+		if a.Branch != "" && a.Branch != sess.Branch {
+			if err := r.store.SetSessionBranch(ctx, a.SessionID, a.Branch); err != nil {
+				r.logger.Warn("reconcile: set branch failed", "session_id", a.SessionID, "error", err)
+			}
+		}
+
+		switch {
+		case sess.State == "responding" && !a.Responding:
+			if err := r.store.UpdateSessionState(ctx, a.SessionID, "active"); err != nil {
+				r.logger.Warn("reconcile: update session state failed", "session_id", a.SessionID, "error", err)
+			}
+			r.mu.Lock()
+			delete(r.turnStartTimes, a.SessionID)
+			r.mu.Unlock()
+			tc := protocol.TurnCompleted{SessionID: a.SessionID, NativeHandle: a.NativeHandle}
+			r.broadcastToSession(a.SessionID, protocol.TypeTurnCompleted, tc)
+			r.notifyTurnWaiters(tc)
+		case sess.State == "creating" || sess.State == "queued":
+			if err := r.store.UpdateSessionState(ctx, a.SessionID, "active"); err != nil {
+				r.logger.Warn("reconcile: update session state failed", "session_id", a.SessionID, "error", err)
+			}
+			r.broadcastToSession(a.SessionID, protocol.TypeSessionCreated, protocol.SessionCreated{
+				SessionID:    a.SessionID,
+				OK:           true,
+				NativeHandle: a.NativeHandle,
+				Branch:       a.Branch,
+			})
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/amurg-ai/amurg/pkg/protocol"
+)
+
+// rollbackTimeout bounds how long RollbackWorkspace waits for the runtime.
+const rollbackTimeout = time.Minute
+
+// ErrRollbackTimeout is returned when the runtime does not acknowledge a
+// rollback in time. The rollback may still have happened.
+var ErrRollbackTimeout = errors.New("runtime did not acknowledge the rollback")
+
+// RollbackError is a rollback the runtime refused or failed to apply.
+type RollbackError struct {
+	Reason string
+}
+
+func (e *RollbackError) Error() string { return "rollback failed: " + e.Reason }
+
+// RollbackWorkspace asks the session's runtime to restore the work dir to the
+// checkpoint taken before the turn started by msg, and waits for the result.
+func (r *Router) RollbackWorkspace(ctx context.Context, sess *store.Session, msg *store.Message) error {
+	req := protocol.WorkspaceRollback{
+		SessionID: sess.ID,
+		RequestID: uuid.New().String(),
+		MessageID: msg.ID,
+		Seq:       msg.Seq,
+	}
+
+	ch := make(chan protocol.WorkspaceRollbackAck, 1)
+	r.mu.Lock()
+	r.pendingRollbacks[req.RequestID] = ch
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.pendingRollbacks, req.RequestID)
+		r.mu.Unlock()
+	}()
+
+	if !r.sendToRuntime(sess.RuntimeID, protocol.TypeWorkspaceRollback, sess.ID, req) {
+		return ErrRuntimeOffline
+	}
+
+	timer := time.NewTimer(rollbackTimeout)
+	defer timer.Stop()
+	select {
+	case ack := <-ch:
+		if !ack.OK {
+			return &RollbackError{Reason: ack.Error}
+		}
+		return nil
+	case <-timer.C:
+		return ErrRollbackTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handleRollbackAck hands a runtime's rollback result to the waiting caller.
+// Acks for sessions on other runtimes are dropped.
+func (r *Router) handleRollbackAck(runtimeID string, ack protocol.WorkspaceRollbackAck) {
+	r.mu.RLock()
+	ch, ok := r.pendingRollbacks[ack.RequestID]
+	r.mu.RUnlock()
+	if !ok {
+		return
+	}
+	sess, err := r.store.GetSession(context.Background(), ack.SessionID)
+	if err != nil || sess == nil || sess.RuntimeID != runtimeID {
+		r.logger.Warn("dropping rollback ack for foreign session", "session_id", ack.SessionID, "runtime", runtimeID)
+		return
+	}
+	select {
+	case ch <- ack:
+	default:
+	}
+}
@@ -18,7 +18,10 @@ import (
 	"time"
 
 	"github.com/amurg-ai/amurg/hub/auth"
+	"github.com/amurg-ai/amurg/hub/metrics"
+	"github.com/amurg-ai/amurg/hub/permlink"
 	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/amurg-ai/amurg/hub/webhook"
 	"github.com/amurg-ai/amurg/pkg/promptprofile"
 	"github.com/amurg-ai/amurg/pkg/protocol"
 	"github.com/google/uuid"
@@ -55,6 +58,9 @@ type Router struct {
 	authProvider auth.Provider
 	runtimeAuth  auth.RuntimeAuthProvider
 	logger       *slog.Logger
+	metrics      *metrics.Hub
+	webhooks     *webhook.Dispatcher // nil disables outbound webhooks
+	permLinks    *permlink.Signer    // nil omits approve/deny links from webhook payloads
 	upgrader     websocket.Upgrader
 
 	turnBased  bool // enforce turn-based messaging
@@ -66,8 +72,10 @@ type Router struct {
 
 	permissionTimeout     time.Duration
 	pendingPerms          map[string]*pendingPermission
-	pendingNativeSessions map[string]*clientConn                  // request_id -> requesting client
-	pendingConfigAcks     map[string]chan protocol.AgentConfigAck // agent_id -> ack channel
+	pendingNativeSessions map[string]*clientConn                        // request_id -> requesting client
+	pendingConfigAcks     map[string]chan protocol.AgentConfigAck       // agent_id -> ack channel
+	pendingRollbacks      map[string]chan protocol.WorkspaceRollbackAck // request_id -> ack channel
+	pendingProvisions     map[string]*pendingProvision                  // request_id -> waiting ProvisionAgent call
 	fileStoragePath       string
 	maxFileBytes          int64
 
@@ -76,15 +84,24 @@ type Router struct {
 	clients               map[string]*clientConn            // conn_id -> conn
 	subscribers           map[string]map[string]*clientConn // session_id -> conn_id -> conn
 	turnStartTimes        map[string]time.Time              // session_id -> turn start time
+	turnWaiters           map[string][]chan protocol.TurnCompleted
+	outputWatchers        map[string][]chan struct{}
 	clientsByUser         map[string]int
 	maxClientConnsPerUser int
 }
 
 type pendingPermission struct {
-	sessionID string
-	requestID string
-	runtimeID string
-	timer     *time.Timer
+	sessionID   string
+	requestID   string
+	runtimeID   string
+	orgID       string
+	agentID     string
+	userID      string // session owner
+	tool        string
+	description string
+	resource    string
+	createdAt   time.Time
+	timer       *time.Timer
 }
 
 type runtimeConn struct {
@@ -118,6 +135,9 @@ type Options struct {
 	FileStoragePath       string // path to store files
 	MaxFileBytes          int64  // max file size in bytes
 	MaxClientConnsPerUser int
+	Metrics               *metrics.Hub        // nil creates a private metric set
+	Webhooks              *webhook.Dispatcher // nil disables outbound webhooks
+	PermissionLinks       *permlink.Signer    // nil omits approve/deny links from webhook payloads
 }
 
 // New creates a new Router.
@@ -147,11 +167,19 @@ func New(s store.Store, ap auth.Provider, ra auth.RuntimeAuthProvider, logger *s
 		maxConnsPerUser = 10
 	}
 
+	m := opts.Metrics
+	if m == nil {
+		m = metrics.NewHub()
+	}
+
 	return &Router{
 		store:                 s,
 		authProvider:          ap,
 		runtimeAuth:           ra,
 		logger:                logger.With("component", "router"),
+		metrics:               m,
+		webhooks:              opts.Webhooks,
+		permLinks:             opts.PermissionLinks,
 		upgrader:              makeUpgrader(opts.AllowedOrigins),
 		turnBased:             opts.TurnBased,
 		maxPerUser:            opts.MaxPerUser,
@@ -162,12 +190,16 @@ func New(s store.Store, ap auth.Provider, ra auth.RuntimeAuthProvider, logger *s
 		pendingPerms:          make(map[string]*pendingPermission),
 		pendingNativeSessions: make(map[string]*clientConn),
 		pendingConfigAcks:     make(map[string]chan protocol.AgentConfigAck),
+		pendingRollbacks:      make(map[string]chan protocol.WorkspaceRollbackAck),
+		pendingProvisions:     make(map[string]*pendingProvision),
 		fileStoragePath:       opts.FileStoragePath,
 		maxFileBytes:          opts.MaxFileBytes,
 		runtimes:              make(map[string]*runtimeConn),
 		clients:               make(map[string]*clientConn),
 		subscribers:           make(map[string]map[string]*clientConn),
 		turnStartTimes:        make(map[string]time.Time),
+		turnWaiters:           make(map[string][]chan protocol.TurnCompleted),
+		outputWatchers:        make(map[string][]chan struct{}),
 		clientsByUser:         make(map[string]int),
 		maxClientConnsPerUser: maxConnsPerUser,
 	}
@@ -267,6 +299,7 @@ func (r *Router) HandleRuntimeWS(w http.ResponseWriter, req *http.Request) {
 		_ = existing.conn.Close()
 	}
 	r.runtimes[hello.RuntimeID] = rtConn
+	r.metrics.RuntimesConnected.Set(float64(len(r.runtimes)))
 	r.mu.Unlock()
 
 	// Update store.
@@ -292,62 +325,15 @@ func (r *Router) HandleRuntimeWS(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Register agents in store.
-	if err := r.store.DeleteAgentsByRuntime(ctx, hello.RuntimeID); err != nil {
-		r.logger.Warn("failed to delete agents by runtime", "runtime_id", hello.RuntimeID, "error", err)
-	}
-	for _, agent := range hello.Agents {
-		capsJSON, _ := json.Marshal(agent.Caps)
-		tagsJSON, _ := json.Marshal(agent.Tags)
-		secJSON := "{}"
-		if agent.Security != nil {
-			if b, err := json.Marshal(agent.Security); err == nil {
-				secJSON = string(b)
-			}
-		}
-		if err := r.store.UpsertAgent(ctx, &store.Agent{
-			ID:        agent.ID,
-			OrgID:     orgID,
-			RuntimeID: hello.RuntimeID,
-			Profile:   agent.Profile,
-			Name:      agent.Name,
-			Tags:      string(tagsJSON),
-			Caps:      string(capsJSON),
-			Security:  secJSON,
-		}); err != nil {
-			r.logger.Warn("failed to upsert agent", "agent_id", agent.ID, "error", err)
-		}
-	}
+	r.registerAgents(ctx, orgID, hello.RuntimeID, hello.Agents)
 
 	// Send ack.
 	r.sendToConn(conn, protocol.TypeHelloAck, "", protocol.HelloAck{OK: true})
 
 	// Push any stored config overrides to the runtime on reconnect.
 	for _, agent := range hello.Agents {
-		override, err := r.store.GetAgentConfigOverride(ctx, agent.ID)
-		if err != nil {
-			r.logger.Warn("failed to load config override on reconnect", "agent_id", agent.ID, "error", err)
-			continue
-		}
-		if override != nil {
-			var sec *protocol.SecurityProfile
-			if override.Security != "" && override.Security != "{}" {
-				sec = &protocol.SecurityProfile{}
-				if err := json.Unmarshal([]byte(override.Security), sec); err != nil {
-					r.logger.Warn("failed to unmarshal security override", "agent_id", agent.ID, "error", err)
-				}
-			}
-			var lim *protocol.AgentLimits
-			if override.Limits != "" && override.Limits != "{}" {
-				lim = &protocol.AgentLimits{}
-				if err := json.Unmarshal([]byte(override.Limits), lim); err != nil {
-					r.logger.Warn("failed to unmarshal limits override", "agent_id", agent.ID, "error", err)
-				}
-			}
-			r.sendToConn(conn, protocol.TypeAgentConfigUpdate, "", protocol.AgentConfigUpdate{
-				AgentID:  agent.ID,
-				Security: sec,
-				Limits:   lim,
-			})
+		if update := r.storedConfigOverride(ctx, agent.ID); update != nil {
+			r.sendToConn(conn, protocol.TypeAgentConfigUpdate, "", *update)
 			r.logger.Info("pushed config override on reconnect", "agent_id", agent.ID, "runtime_id", hello.RuntimeID)
 		}
 	}
@@ -384,6 +370,7 @@ func (r *Router) HandleRuntimeWS(w http.ResponseWriter, req *http.Request) {
 		current, ok := r.runtimes[hello.RuntimeID]
 		if ok && current == rtConn {
 			delete(r.runtimes, hello.RuntimeID)
+			r.metrics.RuntimesConnected.Set(float64(len(r.runtimes)))
 		}
 		replaced := ok && current != rtConn
 		r.mu.Unlock()
@@ -399,6 +386,9 @@ func (r *Router) HandleRuntimeWS(w http.ResponseWriter, req *http.Request) {
 		}); err != nil {
 			r.logger.Warn("failed to log audit event", "action", "runtime.disconnect", "error", err)
 		}
+		r.webhooks.Publish(webhook.Event{
+			Type: webhook.EventRuntimeOffline, OrgID: orgID, RuntimeID: hello.RuntimeID,
+		})
 		r.logger.Info("runtime disconnected", "runtime_id", hello.RuntimeID)
 
 		// Deny any pending permissions belonging to this runtime since
@@ -443,6 +433,16 @@ func (r *Router) HandleClientWS(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
+	// The client socket drives sessions, so personal access tokens need the
+	// messages:send scope to open one.
+	if !identity.HasScope(auth.ScopeMessagesSend) {
+		http.Error(w, "token lacks the messages:send scope", http.StatusForbidden)
+		return
+	}
+	if _, ok := r.authProvider.(auth.MFAProvider); ok && auth.MFAEnrollmentRequired(req.Context(), r.store, identity) {
+		http.Error(w, "two-factor authentication is required for admins", http.StatusForbidden)
+		return
+	}
 
 	conn, err := r.upgrader.Upgrade(w, req, nil)
 	if err != nil {
@@ -471,6 +471,7 @@ func (r *Router) HandleClientWS(w http.ResponseWriter, req *http.Request) {
 	}
 	r.clientsByUser[identity.UserID]++
 	r.clients[connID] = cc
+	r.metrics.ClientsConnected.Set(float64(len(r.clients)))
 	r.mu.Unlock()
 
 	// Set read limit for client connections.
@@ -485,6 +486,7 @@ func (r *Router) HandleClientWS(w http.ResponseWriter, req *http.Request) {
 	defer func() {
 		r.mu.Lock()
 		delete(r.clients, connID)
+		r.metrics.ClientsConnected.Set(float64(len(r.clients)))
 		r.clientsByUser[cc.userID]--
 		if r.clientsByUser[cc.userID] <= 0 {
 			delete(r.clientsByUser, cc.userID)
@@ -510,6 +512,7 @@ func (r *Router) HandleClientWS(w http.ResponseWriter, req *http.Request) {
 		_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
 
 		if !cc.allowMessage() {
+			r.metrics.RateLimitRejections.With("ws_client").Inc()
 			r.logger.Debug("client message rate limited", "conn_id", connID)
 			continue
 		}
@@ -545,6 +548,11 @@ func (r *Router) handleRuntimeMessage(runtimeID string, env protocol.Envelope) {
 					r.logger.Warn("failed to set session native handle", "session_id", resp.SessionID, "error", err)
 				}
 			}
+			if resp.Branch != "" {
+				if err := r.store.SetSessionBranch(ctx, resp.SessionID, resp.Branch); err != nil {
+					r.logger.Warn("failed to set session branch", "session_id", resp.SessionID, "error", err)
+				}
+			}
 		} else {
 			if err := r.store.UpdateSessionState(ctx, resp.SessionID, "closed"); err != nil {
 				r.logger.Warn("failed to close rejected session", "session_id", resp.SessionID, "error", err)
@@ -554,6 +562,37 @@ func (r *Router) handleRuntimeMessage(runtimeID string, env protocol.Envelope) {
 
 		r.broadcastToSession(resp.SessionID, protocol.TypeSessionCreated, resp)
 
+	case protocol.TypeSessionQueued:
+		data, _ := json.Marshal(env.Payload)
+		var q protocol.SessionQueued
+		if err := json.Unmarshal(data, &q); err != nil {
+			r.logger.Warn("unmarshal session.queued failed", "error", err)
+			return
+		}
+
+		ctx := context.Background()
+		sess, err := r.store.GetSession(ctx, q.SessionID)
+		if err != nil || sess == nil || sess.RuntimeID != runtimeID {
+			r.logger.Warn("session.queued from wrong runtime", "session_id", q.SessionID, "runtime_id", runtimeID)
+			return
+		}
+		if sess.State == "closed" {
+			return
+		}
+		if err := r.store.UpdateSessionState(ctx, q.SessionID, "queued"); err != nil {
+			r.logger.Warn("failed to update session state to queued", "session_id", q.SessionID, "error", err)
+		}
+		r.broadcastToSession(q.SessionID, protocol.TypeSessionQueued, q)
+
+	case protocol.TypeSessionsAnnounce:
+		data, _ := json.Marshal(env.Payload)
+		var ann protocol.SessionsAnnounce
+		if err := json.Unmarshal(data, &ann); err != nil {
+			r.logger.Warn("unmarshal sessions.announce failed", "error", err)
+			return
+		}
+		r.reconcileSessions(runtimeID, ann.Sessions)
+
 	case protocol.TypeAgentOutput:
 		data, _ := json.Marshal(env.Payload)
 		var output protocol.AgentOutput
@@ -591,9 +630,12 @@ func (r *Router) handleRuntimeMessage(runtimeID string, env protocol.Envelope) {
 			return
 		}
 		output.Seq = seq
+		r.metrics.AgentOutputMessages.With(output.Channel).Inc()
+		r.metrics.AgentOutputBytes.With(output.Channel).Add(float64(len(output.Content)))
 
 		// Forward to subscribed clients.
 		r.broadcastToSession(output.SessionID, protocol.TypeAgentOutput, output)
+		r.notifyOutputWatchers(output.SessionID)
 
 	case protocol.TypeTurnStarted:
 		data, _ := json.Marshal(env.Payload)
@@ -621,6 +663,7 @@ func (r *Router) handleRuntimeMessage(runtimeID string, env protocol.Envelope) {
 			return
 		}
 		r.broadcastToSession(tc.SessionID, protocol.TypeTurnCompleted, tc)
+		defer r.notifyTurnWaiters(tc)
 
 		ctx := context.Background()
 		if err := r.store.UpdateSessionState(ctx, tc.SessionID, "active"); err != nil {
@@ -641,6 +684,11 @@ func (r *Router) handleRuntimeMessage(runtimeID string, env protocol.Envelope) {
 		}
 		r.mu.Unlock()
 
+		r.metrics.TurnsCompleted.Inc()
+		if hasTiming {
+			r.metrics.TurnDuration.Observe(time.Since(startTime).Seconds())
+		}
+
 		sess, _ := r.store.GetSession(ctx, tc.SessionID)
 		agentID := ""
 		orgID := ""
@@ -665,6 +713,9 @@ func (r *Router) handleRuntimeMessage(runtimeID string, env protocol.Envelope) {
 		}); err != nil {
 			r.logger.Warn("failed to log audit event", "action", "turn.completed", "error", err)
 		}
+		r.webhooks.Publish(webhook.Event{
+			Type: webhook.EventTurnCompleted, OrgID: orgID, SessionID: tc.SessionID, AgentID: agentID, Data: detailJSON,
+		})
 
 	case protocol.TypeStopAck:
 		data, _ := json.Marshal(env.Payload)
@@ -681,28 +732,48 @@ func (r *Router) handleRuntimeMessage(runtimeID string, env protocol.Envelope) {
 			r.logger.Warn("unmarshal permission request failed", "error", err)
 		}
 
+		ctx := context.Background()
+		sess, _ := r.store.GetSession(ctx, req.SessionID)
+		permOrgID := ""
+		permAgentID := ""
+		permUserID := ""
+		if sess != nil {
+			permOrgID = sess.OrgID
+			permAgentID = sess.AgentID
+			permUserID = sess.UserID
+		}
+
+		// Persistent policies answer matching requests without asking anyone.
+		if sess != nil {
+			if p := r.evaluatePolicies(ctx, permOrgID, permAgentID, permUserID, req); p != nil {
+				r.metrics.PermissionRequests.Inc()
+				r.applyPolicy(ctx, runtimeID, p, req, permAgentID)
+				return
+			}
+		}
+
 		// Track pending permission.
 		r.mu.Lock()
 		pp := &pendingPermission{
-			sessionID: req.SessionID,
-			requestID: req.RequestID,
-			runtimeID: runtimeID,
+			sessionID:   req.SessionID,
+			requestID:   req.RequestID,
+			runtimeID:   runtimeID,
+			orgID:       permOrgID,
+			agentID:     permAgentID,
+			userID:      permUserID,
+			tool:        req.Tool,
+			description: req.Description,
+			resource:    req.Resource,
+			createdAt:   time.Now(),
 		}
 		pp.timer = time.AfterFunc(r.permissionTimeout, func() {
 			r.handlePermissionTimeout(req.RequestID)
 		})
 		r.pendingPerms[req.RequestID] = pp
 		r.mu.Unlock()
+		r.metrics.PermissionRequests.Inc()
 
 		// Audit log.
-		ctx := context.Background()
-		sess, _ := r.store.GetSession(ctx, req.SessionID)
-		permOrgID := ""
-		permAgentID := ""
-		if sess != nil {
-			permOrgID = sess.OrgID
-			permAgentID = sess.AgentID
-		}
 		if err := r.store.LogAuditEvent(ctx, &store.AuditEvent{
 			ID: uuid.New().String(), OrgID: permOrgID, Action: "permission.requested",
 			SessionID: req.SessionID, AgentID: permAgentID,
@@ -711,6 +782,10 @@ func (r *Router) handleRuntimeMessage(runtimeID string, env protocol.Envelope) {
 		}); err != nil {
 			r.logger.Warn("failed to log audit event", "action", "permission.requested", "error", err)
 		}
+		r.webhooks.Publish(webhook.Event{
+			Type: webhook.EventPermissionRequest, OrgID: permOrgID, SessionID: req.SessionID, AgentID: permAgentID,
+			UserID: permUserID, Data: r.permissionEventData(pp),
+		})
 
 		// Relay to subscribed UI clients.
 		r.broadcastToSession(req.SessionID, protocol.TypePermissionRequest, req)
@@ -825,6 +900,69 @@ func (r *Router) handleRuntimeMessage(runtimeID string, env protocol.Envelope) {
 			}
 		}
 
+	case protocol.TypeAgentProvisionAck:
+		data, _ := json.Marshal(env.Payload)
+		var ack protocol.AgentProvisionAck
+		if err := json.Unmarshal(data, &ack); err != nil {
+			r.logger.Warn("unmarshal agent provision ack failed", "error", err)
+			return
+		}
+		r.handleProvisionAck(runtimeID, ack)
+
+	case protocol.TypeAgentsAnnounce:
+		data, _ := json.Marshal(env.Payload)
+		var announce protocol.AgentsAnnounce
+		if err := json.Unmarshal(data, &announce); err != nil {
+			r.logger.Warn("unmarshal agents announce failed", "error", err)
+			return
+		}
+		r.handleAgentsAnnounce(runtimeID, announce.Agents)
+
+	case protocol.TypeWorkspaceRollbackAck:
+		data, _ := json.Marshal(env.Payload)
+		var ack protocol.WorkspaceRollbackAck
+		if err := json.Unmarshal(data, &ack); err != nil {
+			r.logger.Warn("unmarshal workspace rollback ack failed", "error", err)
+			return
+		}
+		r.handleRollbackAck(runtimeID, ack)
+
+	case protocol.TypeSecurityViolation:
+		data, _ := json.Marshal(env.Payload)
+		var v protocol.SecurityViolation
+		if err := json.Unmarshal(data, &v); err != nil {
+			r.logger.Warn("unmarshal security violation failed", "error", err)
+			return
+		}
+
+		// Only accept reports about this runtime's own agents and sessions.
+		ctx := context.Background()
+		agent, err := r.store.GetAgent(ctx, v.AgentID)
+		if err != nil || agent == nil || agent.RuntimeID != runtimeID {
+			r.logger.Warn("security.violation for unknown agent", "agent_id", v.AgentID, "runtime_id", runtimeID)
+			return
+		}
+		var userID string
+		if v.SessionID != "" {
+			sess, err := r.store.GetSession(ctx, v.SessionID)
+			if err != nil || sess == nil || sess.AgentID != v.AgentID {
+				r.logger.Warn("security.violation for unknown session", "session_id", v.SessionID, "runtime_id", runtimeID)
+				return
+			}
+			userID = sess.UserID
+		}
+
+		r.logger.Warn("agent security policy violation", "agent_id", v.AgentID, "session_id", v.SessionID,
+			"kind", v.Kind, "path", v.Path, "reason", v.Reason)
+		if err := r.store.LogAuditEvent(ctx, &store.AuditEvent{
+			ID: uuid.New().String(), OrgID: agent.OrgID, Action: "security.violation",
+			UserID: userID, SessionID: v.SessionID, AgentID: v.AgentID,
+			Detail:    json.RawMessage(fmt.Sprintf(`{"kind":%q,"path":%q,"reason":%q}`, v.Kind, v.Path, v.Reason)),
+			CreatedAt: time.Now(),
+		}); err != nil {
+			r.logger.Warn("failed to log audit event", "action", "security.violation", "error", err)
+		}
+
 	case protocol.TypeNativeSessionsResponse:
 		// Forward native sessions response to the client that requested it.
 		data, _ := json.Marshal(env.Payload)
@@ -880,8 +1018,8 @@ func (r *Router) handleClientMessage(cc *clientConn, env protocol.Envelope) {
 			return
 		}
 
-		// Verify user owns the session.
-		if sess.UserID != cc.userID {
+		// Verify the user owns the session or collaborates on it.
+		if !r.CanCollaborate(ctx, sess, cc.userID) {
 			r.sendToClient(cc, protocol.TypeErrorResponse, "", protocol.ErrorResponse{
 				Code: "forbidden", Message: "not your session",
 			})
@@ -939,15 +1077,17 @@ func (r *Router) handleClientMessage(cc *clientConn, env protocol.Envelope) {
 		}
 
 		// Persist user message with atomic seq assignment.
-		_, err = r.store.AppendMessage(ctx, &store.Message{
+		stored := &store.Message{
 			ID:        msg.MessageID,
 			SessionID: msg.SessionID,
 			Seq:       0, // assigned atomically by store
 			Direction: "user",
 			Channel:   "stdin",
 			Content:   msg.Content,
+			AuthorID:  cc.userID,
 			CreatedAt: time.Now(),
-		})
+		}
+		stored.Seq, err = r.store.AppendMessage(ctx, stored)
 		if err != nil {
 			r.sendToClient(cc, protocol.TypeErrorResponse, msg.SessionID, protocol.ErrorResponse{
 				Code: "persist_failed", Message: "failed to persist message",
@@ -955,6 +1095,13 @@ func (r *Router) handleClientMessage(cc *clientConn, env protocol.Envelope) {
 			return
 		}
 
+		// Other people watching a shared session see the message as history;
+		// the sender already shows it locally.
+		r.broadcastToSessionExcept(msg.SessionID, cc.id, protocol.TypeHistoryResponse, protocol.HistoryResponse{
+			SessionID: msg.SessionID,
+			Messages:  []protocol.StoredMessage{toStoredMessage(stored)},
+		})
+
 		action := "message.sent"
 		runtimeType := protocol.TypeUserMessage
 		if interactive {
@@ -974,6 +1121,9 @@ func (r *Router) handleClientMessage(cc *clientConn, env protocol.Envelope) {
 		msg.UserID = sess.UserID
 		msg.PromptProfile = promptprofile.Normalize(sess.PromptProfile)
 		msg.NativeHandle = sess.NativeHandle
+		if !interactive {
+			msg.Content = r.seedForkPrompt(ctx, sess, stored.Seq, msg.Content)
+		}
 
 		// Forward to runtime. Notify the client if delivery fails so
 		// the UI can inform the user instead of silently dropping it.
@@ -999,7 +1149,7 @@ func (r *Router) handleClientMessage(cc *clientConn, env protocol.Envelope) {
 			})
 			return
 		}
-		if sess.UserID != cc.userID && cc.role != "admin" {
+		if !r.CanViewSession(ctx, sess, cc.userID, cc.role) {
 			r.sendToClient(cc, protocol.TypeErrorResponse, sub.SessionID, protocol.ErrorResponse{
 				Code: "forbidden", Message: "not your session",
 			})
@@ -1017,16 +1167,8 @@ func (r *Router) handleClientMessage(cc *clientConn, env protocol.Envelope) {
 		messages, _ := r.store.GetMessages(ctx, sub.SessionID, sub.AfterSeq, 1000)
 		if len(messages) > 0 {
 			stored := make([]protocol.StoredMessage, len(messages))
-			for i, m := range messages {
-				stored[i] = protocol.StoredMessage{
-					ID:        m.ID,
-					SessionID: m.SessionID,
-					Seq:       m.Seq,
-					Direction: m.Direction,
-					Channel:   m.Channel,
-					Content:   m.Content,
-					Timestamp: m.CreatedAt,
-				}
+			for i := range messages {
+				stored[i] = toStoredMessage(&messages[i])
 			}
 			r.sendToClient(cc, protocol.TypeHistoryResponse, sub.SessionID, protocol.HistoryResponse{
 				SessionID: sub.SessionID,
@@ -1059,7 +1201,7 @@ func (r *Router) handleClientMessage(cc *clientConn, env protocol.Envelope) {
 
 		ctx := context.Background()
 		sess, _ := r.store.GetSession(ctx, req.SessionID)
-		if sess != nil && sess.UserID == cc.userID {
+		if sess != nil && r.CanCollaborate(ctx, sess, cc.userID) {
 			r.sendToRuntime(sess.RuntimeID, protocol.TypeStopRequest, req.SessionID, req)
 			if err := r.store.LogAuditEvent(ctx, &store.AuditEvent{
 				ID: uuid.New().String(), OrgID: cc.orgID, Action: "session.stop", UserID: cc.userID,
@@ -1080,7 +1222,7 @@ func (r *Router) handleClientMessage(cc *clientConn, env protocol.Envelope) {
 
 		// Verify session ownership.
 		sess, _ := r.store.GetSession(ctx, resp.SessionID)
-		if sess == nil || (sess.UserID != cc.userID && cc.role != "admin") {
+		if sess == nil || (!r.CanCollaborate(ctx, sess, cc.userID) && cc.role != "admin") {
 			r.sendToClient(cc, protocol.TypeErrorResponse, resp.SessionID, protocol.ErrorResponse{
 				Code: "forbidden", Message: "not your session",
 			})
@@ -1088,34 +1230,16 @@ func (r *Router) handleClientMessage(cc *clientConn, env protocol.Envelope) {
 		}
 
 		// Look up and clean up pending permission.
-		r.mu.Lock()
-		pp, ok := r.pendingPerms[resp.RequestID]
-		if ok {
-			pp.timer.Stop()
-			delete(r.pendingPerms, resp.RequestID)
-		}
-		r.mu.Unlock()
-
-		if !ok {
+		pp := r.takePendingPermission(resp.RequestID, resp.SessionID)
+		if pp == nil {
 			return // already timed out or not found
 		}
-
-		// Audit log.
-		action := "permission.denied"
-		if resp.Approved {
-			action = "permission.granted"
-		}
-		if err := r.store.LogAuditEvent(ctx, &store.AuditEvent{
-			ID: uuid.New().String(), OrgID: cc.orgID, Action: action,
-			UserID: cc.userID, SessionID: resp.SessionID, AgentID: sess.AgentID,
-			Detail:    json.RawMessage(fmt.Sprintf(`{"request_id":%q,"approved":%t}`, resp.RequestID, resp.Approved)),
-			CreatedAt: time.Now(),
-		}); err != nil {
-			r.logger.Warn("failed to log audit event", "action", action, "error", err)
+		// An always-allow rule acts for the owner in later sessions, so only
+		// the owner or an admin may create one; collaborators approve once.
+		if sess.UserID != cc.userID && cc.role != "admin" {
+			resp.AlwaysAllow = false
 		}
-
-		// Relay to runtime.
-		r.sendToRuntime(pp.runtimeID, protocol.TypePermissionResponse, resp.SessionID, resp)
+		r.answerPermission(ctx, pp, resp, cc.orgID, cc.userID, "websocket")
 
 	case protocol.TypeNativeSessionsList:
 		data, _ := json.Marshal(env.Payload)
@@ -1185,6 +1309,11 @@ type CreateSessionOption struct {
 	ResumeSessionID    string
 	ResumeNativeHandle string
 	PromptProfile      string
+	// ForkSeq, when set, forks ResumeSessionID: its messages up to ForkSeq are
+	// copied into the new session and the runtime branches ResumeNativeHandle
+	// instead of continuing it. Without a ResumeNativeHandle the agent starts
+	// fresh and gets the copied transcript with the first prompt.
+	ForkSeq int64
 }
 
 // CreateSession creates a new session and sends the create request to the runtime.
@@ -1220,20 +1349,36 @@ func (r *Router) CreateSession(ctx context.Context, userID, agentID string, opts
 		PromptProfile: promptprofile.Normalize(opt.PromptProfile),
 		State:         "creating",
 		ResumedFrom:   opt.ResumeSessionID,
+		ForkSeq:       opt.ForkSeq,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
 
+	if opt.ForkSeq > 0 {
+		sess.ForkMode = ForkTranscript
+		if opt.ResumeNativeHandle != "" {
+			sess.ForkMode = ForkNative
+		}
+	}
+
 	if err := r.store.CreateSession(ctx, sess); err != nil {
 		return nil, err
 	}
 
+	if opt.ForkSeq > 0 {
+		if _, err := r.store.CopyMessages(ctx, opt.ResumeSessionID, sess.ID, opt.ForkSeq); err != nil {
+			_ = r.store.UpdateSessionState(ctx, sess.ID, "closed")
+			return nil, fmt.Errorf("copy messages: %w", err)
+		}
+	}
+
 	// Send create request to runtime.
 	r.sendToRuntime(agent.RuntimeID, protocol.TypeSessionCreate, sess.ID, protocol.SessionCreate{
 		SessionID:       sess.ID,
 		AgentID:         agentID,
 		UserID:          userID,
 		ResumeSessionID: opt.ResumeNativeHandle,
+		Fork:            opt.ForkSeq > 0,
 		PromptProfile:   sess.PromptProfile,
 	})
 
@@ -1243,6 +1388,10 @@ func (r *Router) CreateSession(ctx context.Context, userID, agentID string, opts
 	}); err != nil {
 		r.logger.Warn("failed to log audit event", "action", "session.create", "error", err)
 	}
+	r.webhooks.Publish(webhook.Event{
+		Type: webhook.EventSessionCreated, OrgID: agent.OrgID, SessionID: sess.ID,
+		AgentID: agentID, RuntimeID: agent.RuntimeID, UserID: userID,
+	})
 
 	return sess, nil
 }
@@ -1276,6 +1425,36 @@ func (r *Router) broadcastToSession(sessionID, msgType string, payload any) {
 	}
 }
 
+// broadcastToSessionExcept is broadcastToSession minus one client connection.
+func (r *Router) broadcastToSessionExcept(sessionID, exceptConnID, msgType string, payload any) {
+	r.mu.RLock()
+	subs := r.subscribers[sessionID]
+	clients := make([]*clientConn, 0, len(subs))
+	for id, cc := range subs {
+		if id != exceptConnID {
+			clients = append(clients, cc)
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, cc := range clients {
+		r.sendToClient(cc, msgType, sessionID, payload)
+	}
+}
+
+func toStoredMessage(m *store.Message) protocol.StoredMessage {
+	return protocol.StoredMessage{
+		ID:        m.ID,
+		SessionID: m.SessionID,
+		Seq:       m.Seq,
+		Direction: m.Direction,
+		Channel:   m.Channel,
+		Content:   m.Content,
+		AuthorID:  m.AuthorID,
+		Timestamp: m.CreatedAt,
+	}
+}
+
 // sendToRuntime sends a message to the specified runtime. Returns false if the
 // runtime is not connected or the write fails.
 func (r *Router) sendToRuntime(runtimeID, msgType, sessionID string, payload any) bool {
@@ -1371,6 +1550,10 @@ func (r *Router) StartIdleReaper(ctx context.Context, defaultTimeout time.Durati
 						}); err != nil {
 							r.logger.Warn("idle reaper: log audit event failed", "session_id", sess.ID, "error", err)
 						}
+						r.webhooks.Publish(webhook.Event{
+							Type: webhook.EventSessionIdleClose, OrgID: sess.OrgID, SessionID: sess.ID,
+							AgentID: sess.AgentID, UserID: sess.UserID,
+						})
 						r.broadcastToSession(sess.ID, protocol.TypeSessionClosed, map[string]string{
 							"session_id": sess.ID,
 						})
@@ -1440,6 +1623,7 @@ func (r *Router) denyPermissionsForRuntime(runtimeID string) {
 	}
 	r.logger.Info("denying pending permissions for disconnected runtime",
 		"runtime_id", runtimeID, "count", len(stale))
+	r.metrics.PermissionResponses.With("runtime_disconnect").Add(float64(len(stale)))
 
 	for _, pp := range stale {
 		denied := protocol.PermissionResponse{
@@ -1460,6 +1644,7 @@ func (r *Router) handlePermissionTimeout(requestID string) {
 	}
 	delete(r.pendingPerms, requestID)
 	r.mu.Unlock()
+	r.metrics.PermissionResponses.With("timeout").Inc()
 
 	ctx := context.Background()
 	sess, _ := r.store.GetSession(ctx, pp.sessionID)
@@ -1513,6 +1698,112 @@ func (r *Router) BroadcastSessionClosed(sessionID string) {
 	})
 }
 
+// CloseSession marks a session closed, tells its subscribers and has the
+// runtime stop the agent. Closing a closed session does nothing.
+func (r *Router) CloseSession(ctx context.Context, sessionID, reason string) error {
+	sess, err := r.store.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess == nil || sess.State == "closed" {
+		return nil
+	}
+	if err := r.store.UpdateSessionState(ctx, sessionID, "closed"); err != nil {
+		return err
+	}
+	r.BroadcastSessionClosed(sessionID)
+	r.sendToRuntime(sess.RuntimeID, protocol.TypeSessionClose, sessionID, protocol.SessionClose{
+		SessionID: sessionID,
+		Reason:    reason,
+	})
+	return nil
+}
+
+// DisconnectUser closes every client connection of a user, e.g. after the
+// user was disabled or deleted. Reconnects then fail token validation.
+func (r *Router) DisconnectUser(userID string) {
+	r.mu.RLock()
+	var conns []*clientConn
+	for _, cc := range r.clients {
+		if cc.userID == userID {
+			conns = append(conns, cc)
+		}
+	}
+	r.mu.RUnlock()
+	for _, cc := range conns {
+		cc.mu.Lock()
+		_ = cc.conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "account changed"))
+		cc.mu.Unlock()
+		_ = cc.conn.Close()
+	}
+}
+
+// registerAgents replaces the agents stored for a runtime with the ones it
+// registered.
+func (r *Router) registerAgents(ctx context.Context, orgID, runtimeID string, agents []protocol.AgentRegistration) {
+	if err := r.store.DeleteAgentsByRuntime(ctx, runtimeID); err != nil {
+		r.logger.Warn("failed to delete agents by runtime", "runtime_id", runtimeID, "error", err)
+	}
+	for _, agent := range agents {
+		capsJSON, _ := json.Marshal(agent.Caps)
+		tagsJSON, _ := json.Marshal(agent.Tags)
+		secJSON := "{}"
+		if agent.Security != nil {
+			if b, err := json.Marshal(agent.Security); err == nil {
+				secJSON = string(b)
+			}
+		}
+		var sandboxJSON string
+		if agent.Sandbox != nil {
+			if b, err := json.Marshal(agent.Sandbox); err == nil {
+				sandboxJSON = string(b)
+			}
+		}
+		if err := r.store.UpsertAgent(ctx, &store.Agent{
+			ID:        agent.ID,
+			OrgID:     orgID,
+			RuntimeID: runtimeID,
+			Profile:   agent.Profile,
+			Name:      agent.Name,
+			Tags:      string(tagsJSON),
+			Caps:      string(capsJSON),
+			Security:  secJSON,
+			Sandbox:   sandboxJSON,
+		}); err != nil {
+			r.logger.Warn("failed to upsert agent", "agent_id", agent.ID, "error", err)
+		}
+	}
+}
+
+// storedConfigOverride returns the config override stored for an agent as
+// an update to push to its runtime, or nil if it has none.
+func (r *Router) storedConfigOverride(ctx context.Context, agentID string) *protocol.AgentConfigUpdate {
+	override, err := r.store.GetAgentConfigOverride(ctx, agentID)
+	if err != nil {
+		r.logger.Warn("failed to load config override", "agent_id", agentID, "error", err)
+		return nil
+	}
+	if override == nil {
+		return nil
+	}
+	var sec *protocol.SecurityProfile
+	if override.Security != "" && override.Security != "{}" {
+		sec = &protocol.SecurityProfile{}
+		if err := json.Unmarshal([]byte(override.Security), sec); err != nil {
+			r.logger.Warn("failed to unmarshal security override", "agent_id", agentID, "error", err)
+		}
+	}
+	var lim *protocol.AgentLimits
+	if override.Limits != "" && override.Limits != "{}" {
+		lim = &protocol.AgentLimits{}
+		if err := json.Unmarshal([]byte(override.Limits), lim); err != nil {
+			r.logger.Warn("failed to unmarshal limits override", "agent_id", agentID, "error", err)
+		}
+	}
+	return &protocol.AgentConfigUpdate{AgentID: agentID, Security: sec, Limits: lim}
+}
+
 // ConfigUpdateResult contains the outcome of a config push to a runtime.
 type ConfigUpdateResult struct {
 	Pushed bool   // true if the message was sent to the runtime
@@ -0,0 +1,266 @@
+// Package scheduler runs stored prompts against agents on cron schedules.
+//
+// When a schedule is due the scheduler opens a session as the schedule's
+// owner, sends the prompt, waits for the turn to complete, records the agent's
+// output in the schedule's run history and closes the session. A schedule
+// whose agent's runtime is offline, or whose owner has lost access to the
+// agent, records a missed run instead; missed runs are not retried, the
+// schedule simply fires again at its next time.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/amurg-ai/amurg/hub/router"
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/amurg-ai/amurg/pkg/protocol"
+	"github.com/google/uuid"
+)
+
+// Run statuses.
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusMissed    = "missed"
+)
+
+const (
+	scanInterval = 30 * time.Second
+	runTimeout   = time.Hour
+	maxResult    = 64 << 10
+)
+
+// Sessions is the part of the router the scheduler drives.
+type Sessions interface {
+	CreateSession(ctx context.Context, userID, agentID string, opts ...router.CreateSessionOption) (*store.Session, error)
+	SendPrompt(ctx context.Context, sess *store.Session, authorID, content string) (int64, <-chan protocol.TurnCompleted, error)
+	CancelTurnWait(sessionID string, done <-chan protocol.TurnCompleted)
+	CloseSession(ctx context.Context, sessionID, reason string) error
+	RuntimeOnline(runtimeID string) bool
+}
+
+// Scheduler fires due schedules.
+type Scheduler struct {
+	store    store.Store
+	sessions Sessions
+	logger   *slog.Logger
+
+	// RunTimeout bounds how long a run waits for its turn to complete.
+	RunTimeout time.Duration
+	// DefaultAgentAccess mirrors auth.default_agent_access. With "none",
+	// owners need a grant for the agent each time the schedule fires.
+	DefaultAgentAccess string
+
+	wg sync.WaitGroup
+}
+
+// New creates a scheduler.
+func New(s store.Store, sessions Sessions, logger *slog.Logger) *Scheduler {
+	return &Scheduler{
+		store:      s,
+		sessions:   sessions,
+		logger:     logger.With("component", "scheduler"),
+		RunTimeout: runTimeout,
+	}
+}
+
+// NextRun returns the next time expr fires after t, in UTC.
+func NextRun(expr string, t time.Time) (time.Time, error) {
+	c, err := ParseCron(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := c.Next(t.UTC())
+	if next.IsZero() {
+		return time.Time{}, errors.New("cron expression never fires")
+	}
+	return next, nil
+}
+
+// Run checks for due schedules until ctx is canceled, then waits for runs in
+// progress to finish.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(scanInterval)
+	defer ticker.Stop()
+	for {
+		s.Tick(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick starts every schedule due at now. Runs proceed in the background; use
+// Wait to block until they finish.
+func (s *Scheduler) Tick(ctx context.Context, now time.Time) {
+	due, err := s.store.ListDueSchedules(ctx, now)
+	if err != nil {
+		s.logger.Warn("list due schedules failed", "error", err)
+		return
+	}
+	for i := range due {
+		sc := due[i]
+		scheduledFor := sc.NextRunAt
+
+		// Advance the schedule before running so a slow run is not started twice.
+		next, err := NextRun(sc.Cron, now)
+		if err != nil {
+			s.logger.Warn("invalid schedule, disabling", "schedule_id", sc.ID, "error", err)
+			sc.Enabled = false
+		}
+		sc.NextRunAt = next
+		sc.LastRunAt = &now
+		sc.UpdatedAt = now
+		if err := s.store.UpdateSchedule(ctx, &sc); err != nil {
+			s.logger.Warn("update schedule failed", "schedule_id", sc.ID, "error", err)
+			continue
+		}
+		if !sc.Enabled {
+			continue
+		}
+
+		run := &store.ScheduleRun{
+			ID:           uuid.New().String(),
+			ScheduleID:   sc.ID,
+			OrgID:        sc.OrgID,
+			Status:       StatusRunning,
+			ScheduledFor: scheduledFor,
+			StartedAt:    now,
+		}
+		if reason := s.unavailable(ctx, &sc); reason != "" {
+			run.Status = StatusMissed
+			run.Error = reason
+			run.FinishedAt = &now
+			if err := s.store.CreateScheduleRun(ctx, run); err != nil {
+				s.logger.Warn("record missed run failed", "schedule_id", sc.ID, "error", err)
+			}
+			s.logAudit(ctx, &sc, "schedule.run_missed", run)
+			continue
+		}
+		if err := s.store.CreateScheduleRun(ctx, run); err != nil {
+			s.logger.Warn("record run failed", "schedule_id", sc.ID, "error", err)
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.execute(context.WithoutCancel(ctx), &sc, run)
+		}()
+	}
+}
+
+// Wait blocks until every run started by Tick has finished.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// unavailable returns why a schedule cannot run now, or "" if it can.
+func (s *Scheduler) unavailable(ctx context.Context, sc *store.Schedule) string {
+	agent, err := s.store.GetAgent(ctx, sc.AgentID)
+	if err != nil || agent == nil {
+		return "agent not found"
+	}
+	if user, err := s.store.GetUserByID(ctx, sc.UserID); err == nil && user != nil && user.Disabled {
+		return "user disabled"
+	}
+	if s.DefaultAgentAccess == "none" {
+		if ok, err := s.store.HasAgentAccess(ctx, sc.UserID, sc.AgentID); err != nil || !ok {
+			return "no access"
+		}
+	}
+	if !s.sessions.RuntimeOnline(agent.RuntimeID) {
+		return "runtime offline"
+	}
+	return ""
+}
+
+func (s *Scheduler) execute(ctx context.Context, sc *store.Schedule, run *store.ScheduleRun) {
+	defer func() {
+		now := time.Now()
+		run.FinishedAt = &now
+		if err := s.store.UpdateScheduleRun(ctx, run); err != nil {
+			s.logger.Warn("update schedule run failed", "run_id", run.ID, "error", err)
+		}
+		s.logAudit(ctx, sc, "schedule.run_"+run.Status, run)
+	}()
+
+	fail := func(format string, args ...any) {
+		run.Status = StatusFailed
+		run.Error = fmt.Sprintf(format, args...)
+	}
+
+	sess, err := s.sessions.CreateSession(ctx, sc.UserID, sc.AgentID, router.CreateSessionOption{PromptProfile: sc.PromptProfile})
+	if err != nil {
+		fail("create session: %v", err)
+		return
+	}
+	if sess == nil {
+		fail("create session: agent not found")
+		return
+	}
+	// Each firing gets its own session; close it so runs do not pile up
+	// against the runtime's session limit.
+	defer func() {
+		if err := s.sessions.CloseSession(ctx, sess.ID, "scheduled run finished"); err != nil {
+			s.logger.Warn("close run session failed", "run_id", run.ID, "session_id", sess.ID, "error", err)
+		}
+	}()
+	run.SessionID = sess.ID
+	if err := s.store.UpdateScheduleRun(ctx, run); err != nil {
+		s.logger.Warn("update schedule run failed", "run_id", run.ID, "error", err)
+	}
+
+	seq, done, err := s.sessions.SendPrompt(ctx, sess, sc.UserID, sc.Prompt)
+	if errors.Is(err, router.ErrRuntimeOffline) {
+		run.Status = StatusMissed
+		run.Error = "runtime offline"
+		return
+	}
+	if err != nil {
+		fail("send prompt: %v", err)
+		return
+	}
+
+	timer := time.NewTimer(s.RunTimeout)
+	defer timer.Stop()
+	var tc protocol.TurnCompleted
+	select {
+	case tc = <-done:
+	case <-timer.C:
+		s.sessions.CancelTurnWait(sess.ID, done)
+		fail("timed out after %s waiting for the turn to complete", s.RunTimeout)
+		return
+	}
+
+	if run.Result, err = router.CollectOutput(ctx, s.store, sess.ID, seq, maxResult); err != nil {
+		s.logger.Warn("get run output failed", "session_id", sess.ID, "error", err)
+	}
+	if tc.ExitCode != nil && *tc.ExitCode != 0 {
+		fail("agent exited with code %d", *tc.ExitCode)
+		return
+	}
+	run.Status = StatusSucceeded
+}
+
+func (s *Scheduler) logAudit(ctx context.Context, sc *store.Schedule, action string, run *store.ScheduleRun) {
+	if err := s.store.LogAuditEvent(ctx, &store.AuditEvent{
+		ID: uuid.New().String(), OrgID: sc.OrgID, Action: action, UserID: sc.UserID,
+		SessionID: run.SessionID, AgentID: sc.AgentID,
+		Detail: json.RawMessage(fmt.Sprintf(`{"schedule_id":%q,"run_id":%q,"error":%q}`,
+			sc.ID, run.ID, run.Error)),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		s.logger.Warn("failed to log audit event", "action", action, "error", err)
+	}
+}
@@ -0,0 +1,250 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/amurg-ai/amurg/hub/router"
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/amurg-ai/amurg/pkg/protocol"
+	"github.com/google/uuid"
+)
+
+func TestParseCronNext(t *testing.T) {
+	base := time.Date(2026, 3, 4, 10, 7, 30, 0, time.UTC) // a Wednesday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 3, 4, 10, 15, 0, 0, time.UTC)},
+		{"0 9-17 * * *", time.Date(2026, 3, 4, 11, 0, 0, 0, time.UTC)},
+		{"30 8 * * 1-5", time.Date(2026, 3, 5, 8, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)}, // day-of-month OR day-of-week
+		{"@hourly", time.Date(2026, 3, 4, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 29 2 *", time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := NextRun(tt.expr, base)
+		if err != nil {
+			t.Errorf("NextRun(%q): %v", tt.expr, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("NextRun(%q) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "0 0 30 2 *"} {
+		if _, err := NextRun(expr, base); err == nil {
+			t.Errorf("NextRun(%q): expected error", expr)
+		}
+	}
+}
+
+// fakeSessions stands in for the router: it stores sessions and messages and
+// completes turns with a canned reply.
+type fakeSessions struct {
+	store   store.Store
+	online  bool
+	reply   string
+	noReply bool
+	prompts []string
+	closed  []string
+	waiting int // turn waiters not yet completed or cancelled
+}
+
+func (f *fakeSessions) CreateSession(ctx context.Context, userID, agentID string, opts ...router.CreateSessionOption) (*store.Session, error) {
+	sess := &store.Session{
+		ID: uuid.New().String(), OrgID: "default", UserID: userID, AgentID: agentID, RuntimeID: "rt-1",
+		Profile: "default", State: "active", CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}
+	if len(opts) > 0 {
+		sess.PromptProfile = opts[0].PromptProfile
+	}
+	return sess, f.store.CreateSession(ctx, sess)
+}
+
+func (f *fakeSessions) SendPrompt(ctx context.Context, sess *store.Session, authorID, content string) (int64, <-chan protocol.TurnCompleted, error) {
+	f.prompts = append(f.prompts, content)
+	seq, err := f.store.AppendMessage(ctx, &store.Message{
+		ID: uuid.New().String(), SessionID: sess.ID, Direction: "user", Channel: "stdin",
+		Content: content, AuthorID: authorID, CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	done := make(chan protocol.TurnCompleted, 1)
+	if f.noReply {
+		f.waiting++
+		return seq, done, nil
+	}
+	if _, err := f.store.AppendMessage(ctx, &store.Message{
+		ID: uuid.New().String(), SessionID: sess.ID, Direction: "agent", Channel: "stdout",
+		Content: f.reply, CreatedAt: time.Now(),
+	}); err != nil {
+		return 0, nil, err
+	}
+	done <- protocol.TurnCompleted{SessionID: sess.ID}
+	return seq, done, nil
+}
+
+func (f *fakeSessions) CancelTurnWait(string, <-chan protocol.TurnCompleted) { f.waiting-- }
+
+func (f *fakeSessions) CloseSession(ctx context.Context, sessionID, reason string) error {
+	f.closed = append(f.closed, sessionID)
+	return f.store.UpdateSessionState(ctx, sessionID, "closed")
+}
+
+func (f *fakeSessions) RuntimeOnline(string) bool { return f.online }
+
+func newTestScheduler(t *testing.T, fake *fakeSessions) (*Scheduler, store.Store) {
+	t.Helper()
+	s, err := store.NewSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	ctx := context.Background()
+	if err := s.CreateUser(ctx, &store.User{ID: "u-1", OrgID: "default", Username: "sched", Role: "user", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertRuntime(ctx, &store.Runtime{ID: "rt-1", OrgID: "default", Name: "rt", Online: true, LastSeen: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertAgent(ctx, &store.Agent{
+		ID: "ag-1", OrgID: "default", RuntimeID: "rt-1", Profile: "default", Name: "agent",
+		Tags: "{}", Caps: "{}", Security: "{}",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	fake.store = s
+	return New(s, fake, slog.Default()), s
+}
+
+func createSchedule(t *testing.T, s store.Store, nextRun time.Time) *store.Schedule {
+	t.Helper()
+	sc := &store.Schedule{
+		ID: uuid.New().String(), OrgID: "default", UserID: "u-1", AgentID: "ag-1", Name: "nightly",
+		Cron: "0 2 * * *", Prompt: "check the build", Enabled: true, NextRunAt: nextRun,
+		CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}
+	if err := s.CreateSchedule(context.Background(), sc); err != nil {
+		t.Fatal(err)
+	}
+	return sc
+}
+
+func singleRun(t *testing.T, s store.Store, scheduleID string) store.ScheduleRun {
+	t.Helper()
+	runs, err := s.ListScheduleRuns(context.Background(), scheduleID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("expected 1 run, got %d", len(runs))
+	}
+	return runs[0]
+}
+
+func TestTickRunsDueSchedule(t *testing.T) {
+	fake := &fakeSessions{online: true, reply: "build is green"}
+	sched, s := newTestScheduler(t, fake)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 4, 2, 0, 20, 0, time.UTC)
+	sc := createSchedule(t, s, now.Add(-20*time.Second))
+	createSchedule(t, s, now.Add(time.Hour)) // not due yet
+
+	sched.Tick(ctx, now)
+	sched.Wait()
+
+	if len(fake.prompts) != 1 || fake.prompts[0] != "check the build" {
+		t.Fatalf("prompts = %v", fake.prompts)
+	}
+	run := singleRun(t, s, sc.ID)
+	if run.Status != StatusSucceeded || run.Result != "build is green" || run.SessionID == "" || run.FinishedAt == nil {
+		t.Fatalf("unexpected run %+v", run)
+	}
+	if len(fake.closed) != 1 || fake.closed[0] != run.SessionID {
+		t.Fatalf("expected the run's session to be closed, closed %v", fake.closed)
+	}
+
+	got, err := s.GetSchedule(ctx, sc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 3, 5, 2, 0, 0, 0, time.UTC); !got.NextRunAt.Equal(want) {
+		t.Fatalf("next_run_at = %s, want %s", got.NextRunAt, want)
+	}
+	if got.LastRunAt == nil {
+		t.Fatal("expected last_run_at to be set")
+	}
+
+	// Already advanced, so a second tick at the same time does nothing.
+	sched.Tick(ctx, now)
+	sched.Wait()
+	if len(fake.prompts) != 1 {
+		t.Fatalf("schedule fired twice: %v", fake.prompts)
+	}
+}
+
+func TestTickRecordsMissedRunWhenOffline(t *testing.T) {
+	fake := &fakeSessions{online: false}
+	sched, s := newTestScheduler(t, fake)
+	now := time.Now()
+	sc := createSchedule(t, s, now.Add(-time.Minute))
+
+	sched.Tick(context.Background(), now)
+	sched.Wait()
+
+	if len(fake.prompts) != 0 {
+		t.Fatalf("prompt sent to offline runtime: %v", fake.prompts)
+	}
+	run := singleRun(t, s, sc.ID)
+	if run.Status != StatusMissed || run.Error != "runtime offline" {
+		t.Fatalf("unexpected run %+v", run)
+	}
+}
+
+func TestTickFailsRunOnTimeout(t *testing.T) {
+	fake := &fakeSessions{online: true, noReply: true}
+	sched, s := newTestScheduler(t, fake)
+	sched.RunTimeout = 10 * time.Millisecond
+	now := time.Now()
+	sc := createSchedule(t, s, now.Add(-time.Minute))
+
+	sched.Tick(context.Background(), now)
+	sched.Wait()
+
+	run := singleRun(t, s, sc.ID)
+	if run.Status != StatusFailed || run.Error == "" {
+		t.Fatalf("unexpected run %+v", run)
+	}
+	if fake.waiting != 0 || len(fake.closed) != 1 {
+		t.Fatalf("expected the turn wait cancelled and the session closed, waiting=%d closed=%v", fake.waiting, fake.closed)
+	}
+}
+
+func TestTickRecordsMissedRunWithoutAccess(t *testing.T) {
+	fake := &fakeSessions{online: true, reply: "done"}
+	sched, s := newTestScheduler(t, fake)
+	sched.DefaultAgentAccess = "none"
+	now := time.Now()
+	sc := createSchedule(t, s, now.Add(-time.Minute))
+
+	// The owner's grant was revoked after the schedule was created.
+	sched.Tick(context.Background(), now)
+	sched.Wait()
+
+	if len(fake.prompts) != 0 {
+		t.Fatalf("prompt sent without agent access: %v", fake.prompts)
+	}
+	run := singleRun(t, s, sc.ID)
+	if run.Status != StatusMissed || run.Error != "no access" {
+		t.Fatalf("unexpected run %+v", run)
+	}
+}
@@ -0,0 +1,607 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Observer receives the method name, latency and result of every store call.
+type Observer func(method string, d time.Duration, err error)
+
+// Instrument wraps s so that every call except Close is reported to observe.
+func Instrument(s Store, observe Observer) Store {
+	return &instrumentedStore{next: s, obs: observe}
+}
+
+type instrumentedStore struct {
+	next Store
+	obs  Observer
+}
+
+func (s *instrumentedStore) observe(method string, start time.Time, err *error) {
+	s.obs(method, time.Since(start), *err)
+}
+
+func (s *instrumentedStore) Close() error {
+	return s.next.Close()
+}
+
+func (s *instrumentedStore) CreateOrganization(ctx context.Context, org *Organization) (err error) {
+	defer s.observe("CreateOrganization", time.Now(), &err)
+	return s.next.CreateOrganization(ctx, org)
+}
+
+func (s *instrumentedStore) GetOrganization(ctx context.Context, id string) (_ *Organization, err error) {
+	defer s.observe("GetOrganization", time.Now(), &err)
+	return s.next.GetOrganization(ctx, id)
+}
+
+func (s *instrumentedStore) UpdateOrganization(ctx context.Context, org *Organization) (err error) {
+	defer s.observe("UpdateOrganization", time.Now(), &err)
+	return s.next.UpdateOrganization(ctx, org)
+}
+
+func (s *instrumentedStore) CreateUser(ctx context.Context, user *User) (err error) {
+	defer s.observe("CreateUser", time.Now(), &err)
+	return s.next.CreateUser(ctx, user)
+}
+
+func (s *instrumentedStore) GetUser(ctx context.Context, orgID string, username string) (_ *User, err error) {
+	defer s.observe("GetUser", time.Now(), &err)
+	return s.next.GetUser(ctx, orgID, username)
+}
+
+func (s *instrumentedStore) GetUserByID(ctx context.Context, id string) (_ *User, err error) {
+	defer s.observe("GetUserByID", time.Now(), &err)
+	return s.next.GetUserByID(ctx, id)
+}
+
+func (s *instrumentedStore) GetUserByExternalID(ctx context.Context, externalID string) (_ *User, err error) {
+	defer s.observe("GetUserByExternalID", time.Now(), &err)
+	return s.next.GetUserByExternalID(ctx, externalID)
+}
+
+func (s *instrumentedStore) ListUsers(ctx context.Context, orgID string) (_ []User, err error) {
+	defer s.observe("ListUsers", time.Now(), &err)
+	return s.next.ListUsers(ctx, orgID)
+}
+
+func (s *instrumentedStore) UpdateUser(ctx context.Context, user *User) (err error) {
+	defer s.observe("UpdateUser", time.Now(), &err)
+	return s.next.UpdateUser(ctx, user)
+}
+
+func (s *instrumentedStore) DeleteUser(ctx context.Context, id string) (err error) {
+	defer s.observe("DeleteUser", time.Now(), &err)
+	return s.next.DeleteUser(ctx, id)
+}
+
+func (s *instrumentedStore) CreatePasswordReset(ctx context.Context, r *PasswordReset) (err error) {
+	defer s.observe("CreatePasswordReset", time.Now(), &err)
+	return s.next.CreatePasswordReset(ctx, r)
+}
+
+func (s *instrumentedStore) GetPasswordResetByHash(ctx context.Context, tokenHash string) (_ *PasswordReset, err error) {
+	defer s.observe("GetPasswordResetByHash", time.Now(), &err)
+	return s.next.GetPasswordResetByHash(ctx, tokenHash)
+}
+
+func (s *instrumentedStore) DeletePasswordResets(ctx context.Context, userID string) (err error) {
+	defer s.observe("DeletePasswordResets", time.Now(), &err)
+	return s.next.DeletePasswordResets(ctx, userID)
+}
+
+func (s *instrumentedStore) UpsertRuntime(ctx context.Context, rt *Runtime) (err error) {
+	defer s.observe("UpsertRuntime", time.Now(), &err)
+	return s.next.UpsertRuntime(ctx, rt)
+}
+
+func (s *instrumentedStore) GetRuntime(ctx context.Context, id string) (_ *Runtime, err error) {
+	defer s.observe("GetRuntime", time.Now(), &err)
+	return s.next.GetRuntime(ctx, id)
+}
+
+func (s *instrumentedStore) ListRuntimes(ctx context.Context, orgID string) (_ []Runtime, err error) {
+	defer s.observe("ListRuntimes", time.Now(), &err)
+	return s.next.ListRuntimes(ctx, orgID)
+}
+
+func (s *instrumentedStore) SetRuntimeOnline(ctx context.Context, id string, online bool) (err error) {
+	defer s.observe("SetRuntimeOnline", time.Now(), &err)
+	return s.next.SetRuntimeOnline(ctx, id, online)
+}
+
+func (s *instrumentedStore) UpsertAgent(ctx context.Context, agent *Agent) (err error) {
+	defer s.observe("UpsertAgent", time.Now(), &err)
+	return s.next.UpsertAgent(ctx, agent)
+}
+
+func (s *instrumentedStore) GetAgent(ctx context.Context, id string) (_ *Agent, err error) {
+	defer s.observe("GetAgent", time.Now(), &err)
+	return s.next.GetAgent(ctx, id)
+}
+
+func (s *instrumentedStore) ListAgents(ctx context.Context, orgID string) (_ []Agent, err error) {
+	defer s.observe("ListAgents", time.Now(), &err)
+	return s.next.ListAgents(ctx, orgID)
+}
+
+func (s *instrumentedStore) ListAgentsByRuntime(ctx context.Context, runtimeID string) (_ []Agent, err error) {
+	defer s.observe("ListAgentsByRuntime", time.Now(), &err)
+	return s.next.ListAgentsByRuntime(ctx, runtimeID)
+}
+
+func (s *instrumentedStore) DeleteAgentsByRuntime(ctx context.Context, runtimeID string) (err error) {
+	defer s.observe("DeleteAgentsByRuntime", time.Now(), &err)
+	return s.next.DeleteAgentsByRuntime(ctx, runtimeID)
+}
+
+func (s *instrumentedStore) CreateSession(ctx context.Context, sess *Session) (err error) {
+	defer s.observe("CreateSession", time.Now(), &err)
+	return s.next.CreateSession(ctx, sess)
+}
+
+func (s *instrumentedStore) GetSession(ctx context.Context, id string) (_ *Session, err error) {
+	defer s.observe("GetSession", time.Now(), &err)
+	return s.next.GetSession(ctx, id)
+}
+
+func (s *instrumentedStore) ListSessionsByUser(ctx context.Context, userID string) (_ []Session, err error) {
+	defer s.observe("ListSessionsByUser", time.Now(), &err)
+	return s.next.ListSessionsByUser(ctx, userID)
+}
+
+func (s *instrumentedStore) UpdateSessionState(ctx context.Context, id string, state string) (err error) {
+	defer s.observe("UpdateSessionState", time.Now(), &err)
+	return s.next.UpdateSessionState(ctx, id, state)
+}
+
+func (s *instrumentedStore) SetSessionNativeHandle(ctx context.Context, id string, handle string) (err error) {
+	defer s.observe("SetSessionNativeHandle", time.Now(), &err)
+	return s.next.SetSessionNativeHandle(ctx, id, handle)
+}
+
+func (s *instrumentedStore) SetSessionBranch(ctx context.Context, id string, branch string) (err error) {
+	defer s.observe("SetSessionBranch", time.Now(), &err)
+	return s.next.SetSessionBranch(ctx, id, branch)
+}
+
+func (s *instrumentedStore) ListActiveSessions(ctx context.Context, orgID string) (_ []Session, err error) {
+	defer s.observe("ListActiveSessions", time.Now(), &err)
+	return s.next.ListActiveSessions(ctx, orgID)
+}
+
+func (s *instrumentedStore) CountActiveSessionsByUser(ctx context.Context, userID string) (_ int, err error) {
+	defer s.observe("CountActiveSessionsByUser", time.Now(), &err)
+	return s.next.CountActiveSessionsByUser(ctx, userID)
+}
+
+func (s *instrumentedStore) CountSessionsByState(ctx context.Context) (_ map[string]int, err error) {
+	defer s.observe("CountSessionsByState", time.Now(), &err)
+	return s.next.CountSessionsByState(ctx)
+}
+
+func (s *instrumentedStore) AppendMessage(ctx context.Context, msg *Message) (_ int64, err error) {
+	defer s.observe("AppendMessage", time.Now(), &err)
+	return s.next.AppendMessage(ctx, msg)
+}
+
+func (s *instrumentedStore) GetMessages(ctx context.Context, sessionID string, afterSeq int64, limit int) (_ []Message, err error) {
+	defer s.observe("GetMessages", time.Now(), &err)
+	return s.next.GetMessages(ctx, sessionID, afterSeq, limit)
+}
+
+func (s *instrumentedStore) MessageExists(ctx context.Context, sessionID string, messageID string) (_ bool, err error) {
+	defer s.observe("MessageExists", time.Now(), &err)
+	return s.next.MessageExists(ctx, sessionID, messageID)
+}
+
+func (s *instrumentedStore) SearchMessages(ctx context.Context, filter SearchFilter) (_ []SearchResult, err error) {
+	defer s.observe("SearchMessages", time.Now(), &err)
+	return s.next.SearchMessages(ctx, filter)
+}
+
+func (s *instrumentedStore) GrantAgentAccess(ctx context.Context, userID string, agentID string) (err error) {
+	defer s.observe("GrantAgentAccess", time.Now(), &err)
+	return s.next.GrantAgentAccess(ctx, userID, agentID)
+}
+
+func (s *instrumentedStore) RevokeAgentAccess(ctx context.Context, userID string, agentID string) (err error) {
+	defer s.observe("RevokeAgentAccess", time.Now(), &err)
+	return s.next.RevokeAgentAccess(ctx, userID, agentID)
+}
+
+func (s *instrumentedStore) ListUserAgents(ctx context.Context, userID string) (_ []string, err error) {
+	defer s.observe("ListUserAgents", time.Now(), &err)
+	return s.next.ListUserAgents(ctx, userID)
+}
+
+func (s *instrumentedStore) HasAgentAccess(ctx context.Context, userID string, agentID string) (_ bool, err error) {
+	defer s.observe("HasAgentAccess", time.Now(), &err)
+	return s.next.HasAgentAccess(ctx, userID, agentID)
+}
+
+func (s *instrumentedStore) CreateGroup(ctx context.Context, g *Group) (err error) {
+	defer s.observe("CreateGroup", time.Now(), &err)
+	return s.next.CreateGroup(ctx, g)
+}
+
+func (s *instrumentedStore) GetGroup(ctx context.Context, id string) (_ *Group, err error) {
+	defer s.observe("GetGroup", time.Now(), &err)
+	return s.next.GetGroup(ctx, id)
+}
+
+func (s *instrumentedStore) ListGroups(ctx context.Context, orgID string) (_ []Group, err error) {
+	defer s.observe("ListGroups", time.Now(), &err)
+	return s.next.ListGroups(ctx, orgID)
+}
+
+func (s *instrumentedStore) UpdateGroup(ctx context.Context, g *Group) (err error) {
+	defer s.observe("UpdateGroup", time.Now(), &err)
+	return s.next.UpdateGroup(ctx, g)
+}
+
+func (s *instrumentedStore) DeleteGroup(ctx context.Context, id string) (err error) {
+	defer s.observe("DeleteGroup", time.Now(), &err)
+	return s.next.DeleteGroup(ctx, id)
+}
+
+func (s *instrumentedStore) AddGroupMember(ctx context.Context, m *GroupMember) (err error) {
+	defer s.observe("AddGroupMember", time.Now(), &err)
+	return s.next.AddGroupMember(ctx, m)
+}
+
+func (s *instrumentedStore) RemoveGroupMember(ctx context.Context, groupID string, userID string) (err error) {
+	defer s.observe("RemoveGroupMember", time.Now(), &err)
+	return s.next.RemoveGroupMember(ctx, groupID, userID)
+}
+
+func (s *instrumentedStore) ListGroupMembers(ctx context.Context, groupID string) (_ []GroupMember, err error) {
+	defer s.observe("ListGroupMembers", time.Now(), &err)
+	return s.next.ListGroupMembers(ctx, groupID)
+}
+
+func (s *instrumentedStore) ListUserGroups(ctx context.Context, userID string) (_ []Group, err error) {
+	defer s.observe("ListUserGroups", time.Now(), &err)
+	return s.next.ListUserGroups(ctx, userID)
+}
+
+func (s *instrumentedStore) CreateGroupGrant(ctx context.Context, g *GroupGrant) (err error) {
+	defer s.observe("CreateGroupGrant", time.Now(), &err)
+	return s.next.CreateGroupGrant(ctx, g)
+}
+
+func (s *instrumentedStore) ListGroupGrants(ctx context.Context, groupID string) (_ []GroupGrant, err error) {
+	defer s.observe("ListGroupGrants", time.Now(), &err)
+	return s.next.ListGroupGrants(ctx, groupID)
+}
+
+func (s *instrumentedStore) DeleteGroupGrant(ctx context.Context, id string) (err error) {
+	defer s.observe("DeleteGroupGrant", time.Now(), &err)
+	return s.next.DeleteGroupGrant(ctx, id)
+}
+
+func (s *instrumentedStore) LogAuditEvent(ctx context.Context, event *AuditEvent) (err error) {
+	defer s.observe("LogAuditEvent", time.Now(), &err)
+	return s.next.LogAuditEvent(ctx, event)
+}
+
+func (s *instrumentedStore) ListAuditEvents(ctx context.Context, orgID string, limit int, offset int) (_ []AuditEvent, err error) {
+	defer s.observe("ListAuditEvents", time.Now(), &err)
+	return s.next.ListAuditEvents(ctx, orgID, limit, offset)
+}
+
+func (s *instrumentedStore) ListAuditEventsFiltered(ctx context.Context, orgID string, filter AuditFilter) (_ []AuditEvent, err error) {
+	defer s.observe("ListAuditEventsFiltered", time.Now(), &err)
+	return s.next.ListAuditEventsFiltered(ctx, orgID, filter)
+}
+
+func (s *instrumentedStore) PurgeOldMessages(ctx context.Context, before time.Time) (_ int64, err error) {
+	defer s.observe("PurgeOldMessages", time.Now(), &err)
+	return s.next.PurgeOldMessages(ctx, before)
+}
+
+func (s *instrumentedStore) PurgeOldAuditEvents(ctx context.Context, before time.Time) (_ int64, err error) {
+	defer s.observe("PurgeOldAuditEvents", time.Now(), &err)
+	return s.next.PurgeOldAuditEvents(ctx, before)
+}
+
+func (s *instrumentedStore) ListAllSessions(ctx context.Context, orgID string) (_ []Session, err error) {
+	defer s.observe("ListAllSessions", time.Now(), &err)
+	return s.next.ListAllSessions(ctx, orgID)
+}
+
+func (s *instrumentedStore) UpsertAgentConfigOverride(ctx context.Context, override *AgentConfigOverride) (err error) {
+	defer s.observe("UpsertAgentConfigOverride", time.Now(), &err)
+	return s.next.UpsertAgentConfigOverride(ctx, override)
+}
+
+func (s *instrumentedStore) GetAgentConfigOverride(ctx context.Context, agentID string) (_ *AgentConfigOverride, err error) {
+	defer s.observe("GetAgentConfigOverride", time.Now(), &err)
+	return s.next.GetAgentConfigOverride(ctx, agentID)
+}
+
+func (s *instrumentedStore) ListAgentConfigOverrides(ctx context.Context, orgID string) (_ []AgentConfigOverride, err error) {
+	defer s.observe("ListAgentConfigOverrides", time.Now(), &err)
+	return s.next.ListAgentConfigOverrides(ctx, orgID)
+}
+
+func (s *instrumentedStore) DeleteAgentConfigOverride(ctx context.Context, agentID string) (err error) {
+	defer s.observe("DeleteAgentConfigOverride", time.Now(), &err)
+	return s.next.DeleteAgentConfigOverride(ctx, agentID)
+}
+
+func (s *instrumentedStore) CreateDeviceCode(ctx context.Context, dc *DeviceCode) (err error) {
+	defer s.observe("CreateDeviceCode", time.Now(), &err)
+	return s.next.CreateDeviceCode(ctx, dc)
+}
+
+func (s *instrumentedStore) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (_ *DeviceCode, err error) {
+	defer s.observe("GetDeviceCodeByUserCode", time.Now(), &err)
+	return s.next.GetDeviceCodeByUserCode(ctx, userCode)
+}
+
+func (s *instrumentedStore) GetDeviceCodeByPollingToken(ctx context.Context, pollingToken string) (_ *DeviceCode, err error) {
+	defer s.observe("GetDeviceCodeByPollingToken", time.Now(), &err)
+	return s.next.GetDeviceCodeByPollingToken(ctx, pollingToken)
+}
+
+func (s *instrumentedStore) UpdateDeviceCodeStatus(ctx context.Context, id string, status string, runtimeID string, token string, approvedBy string) (err error) {
+	defer s.observe("UpdateDeviceCodeStatus", time.Now(), &err)
+	return s.next.UpdateDeviceCodeStatus(ctx, id, status, runtimeID, token, approvedBy)
+}
+
+func (s *instrumentedStore) PurgeExpiredDeviceCodes(ctx context.Context) (_ int64, err error) {
+	defer s.observe("PurgeExpiredDeviceCodes", time.Now(), &err)
+	return s.next.PurgeExpiredDeviceCodes(ctx)
+}
+
+func (s *instrumentedStore) CreateRuntimeToken(ctx context.Context, rt *RuntimeToken) (err error) {
+	defer s.observe("CreateRuntimeToken", time.Now(), &err)
+	return s.next.CreateRuntimeToken(ctx, rt)
+}
+
+func (s *instrumentedStore) GetRuntimeTokenByHash(ctx context.Context, tokenHash string) (_ *RuntimeToken, err error) {
+	defer s.observe("GetRuntimeTokenByHash", time.Now(), &err)
+	return s.next.GetRuntimeTokenByHash(ctx, tokenHash)
+}
+
+func (s *instrumentedStore) ListRuntimeTokens(ctx context.Context, orgID string) (_ []RuntimeToken, err error) {
+	defer s.observe("ListRuntimeTokens", time.Now(), &err)
+	return s.next.ListRuntimeTokens(ctx, orgID)
+}
+
+func (s *instrumentedStore) RevokeRuntimeToken(ctx context.Context, id string) (err error) {
+	defer s.observe("RevokeRuntimeToken", time.Now(), &err)
+	return s.next.RevokeRuntimeToken(ctx, id)
+}
+
+func (s *instrumentedStore) UpdateRuntimeTokenLastUsed(ctx context.Context, id string) (err error) {
+	defer s.observe("UpdateRuntimeTokenLastUsed", time.Now(), &err)
+	return s.next.UpdateRuntimeTokenLastUsed(ctx, id)
+}
+
+func (s *instrumentedStore) CreateUserToken(ctx context.Context, t *UserToken) (err error) {
+	defer s.observe("CreateUserToken", time.Now(), &err)
+	return s.next.CreateUserToken(ctx, t)
+}
+
+func (s *instrumentedStore) GetUserTokenByHash(ctx context.Context, tokenHash string) (_ *UserToken, err error) {
+	defer s.observe("GetUserTokenByHash", time.Now(), &err)
+	return s.next.GetUserTokenByHash(ctx, tokenHash)
+}
+
+func (s *instrumentedStore) ListUserTokens(ctx context.Context, userID string) (_ []UserToken, err error) {
+	defer s.observe("ListUserTokens", time.Now(), &err)
+	return s.next.ListUserTokens(ctx, userID)
+}
+
+func (s *instrumentedStore) DeleteUserToken(ctx context.Context, id string) (err error) {
+	defer s.observe("DeleteUserToken", time.Now(), &err)
+	return s.next.DeleteUserToken(ctx, id)
+}
+
+func (s *instrumentedStore) UpdateUserTokenLastUsed(ctx context.Context, id string) (err error) {
+	defer s.observe("UpdateUserTokenLastUsed", time.Now(), &err)
+	return s.next.UpdateUserTokenLastUsed(ctx, id)
+}
+
+func (s *instrumentedStore) GetUserMFA(ctx context.Context, userID string) (_ *UserMFA, err error) {
+	defer s.observe("GetUserMFA", time.Now(), &err)
+	return s.next.GetUserMFA(ctx, userID)
+}
+
+func (s *instrumentedStore) UpsertUserMFA(ctx context.Context, m *UserMFA) (err error) {
+	defer s.observe("UpsertUserMFA", time.Now(), &err)
+	return s.next.UpsertUserMFA(ctx, m)
+}
+
+func (s *instrumentedStore) DeleteUserMFA(ctx context.Context, userID string) (err error) {
+	defer s.observe("DeleteUserMFA", time.Now(), &err)
+	return s.next.DeleteUserMFA(ctx, userID)
+}
+
+func (s *instrumentedStore) GetSubscription(ctx context.Context, orgID string) (_ *Subscription, err error) {
+	defer s.observe("GetSubscription", time.Now(), &err)
+	return s.next.GetSubscription(ctx, orgID)
+}
+
+func (s *instrumentedStore) UpsertSubscription(ctx context.Context, sub *Subscription) (err error) {
+	defer s.observe("UpsertSubscription", time.Now(), &err)
+	return s.next.UpsertSubscription(ctx, sub)
+}
+
+func (s *instrumentedStore) GetSubscriptionByStripeCustomer(ctx context.Context, customerID string) (_ *Subscription, err error) {
+	defer s.observe("GetSubscriptionByStripeCustomer", time.Now(), &err)
+	return s.next.GetSubscriptionByStripeCustomer(ctx, customerID)
+}
+
+func (s *instrumentedStore) CreateWebhook(ctx context.Context, wh *Webhook) (err error) {
+	defer s.observe("CreateWebhook", time.Now(), &err)
+	return s.next.CreateWebhook(ctx, wh)
+}
+
+func (s *instrumentedStore) GetWebhook(ctx context.Context, id string) (_ *Webhook, err error) {
+	defer s.observe("GetWebhook", time.Now(), &err)
+	return s.next.GetWebhook(ctx, id)
+}
+
+func (s *instrumentedStore) ListWebhooks(ctx context.Context, orgID string) (_ []Webhook, err error) {
+	defer s.observe("ListWebhooks", time.Now(), &err)
+	return s.next.ListWebhooks(ctx, orgID)
+}
+
+func (s *instrumentedStore) UpdateWebhook(ctx context.Context, wh *Webhook) (err error) {
+	defer s.observe("UpdateWebhook", time.Now(), &err)
+	return s.next.UpdateWebhook(ctx, wh)
+}
+
+func (s *instrumentedStore) DeleteWebhook(ctx context.Context, id string) (err error) {
+	defer s.observe("DeleteWebhook", time.Now(), &err)
+	return s.next.DeleteWebhook(ctx, id)
+}
+
+func (s *instrumentedStore) CreateWebhookDelivery(ctx context.Context, d *WebhookDelivery) (err error) {
+	defer s.observe("CreateWebhookDelivery", time.Now(), &err)
+	return s.next.CreateWebhookDelivery(ctx, d)
+}
+
+func (s *instrumentedStore) UpdateWebhookDelivery(ctx context.Context, d *WebhookDelivery) (err error) {
+	defer s.observe("UpdateWebhookDelivery", time.Now(), &err)
+	return s.next.UpdateWebhookDelivery(ctx, d)
+}
+
+func (s *instrumentedStore) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) (_ []WebhookDelivery, err error) {
+	defer s.observe("ListWebhookDeliveries", time.Now(), &err)
+	return s.next.ListWebhookDeliveries(ctx, webhookID, limit)
+}
+
+func (s *instrumentedStore) ListDueWebhookDeliveries(ctx context.Context, before time.Time, limit int) (_ []WebhookDelivery, err error) {
+	defer s.observe("ListDueWebhookDeliveries", time.Now(), &err)
+	return s.next.ListDueWebhookDeliveries(ctx, before, limit)
+}
+
+func (s *instrumentedStore) PurgeOldWebhookDeliveries(ctx context.Context, before time.Time) (_ int64, err error) {
+	defer s.observe("PurgeOldWebhookDeliveries", time.Now(), &err)
+	return s.next.PurgeOldWebhookDeliveries(ctx, before)
+}
+
+func (s *instrumentedStore) AddSessionMember(ctx context.Context, m *SessionMember) (err error) {
+	defer s.observe("AddSessionMember", time.Now(), &err)
+	return s.next.AddSessionMember(ctx, m)
+}
+
+func (s *instrumentedStore) GetSessionMember(ctx context.Context, sessionID string, userID string) (_ *SessionMember, err error) {
+	defer s.observe("GetSessionMember", time.Now(), &err)
+	return s.next.GetSessionMember(ctx, sessionID, userID)
+}
+
+func (s *instrumentedStore) ListSessionMembers(ctx context.Context, sessionID string) (_ []SessionMember, err error) {
+	defer s.observe("ListSessionMembers", time.Now(), &err)
+	return s.next.ListSessionMembers(ctx, sessionID)
+}
+
+func (s *instrumentedStore) RemoveSessionMember(ctx context.Context, sessionID string, userID string) (err error) {
+	defer s.observe("RemoveSessionMember", time.Now(), &err)
+	return s.next.RemoveSessionMember(ctx, sessionID, userID)
+}
+
+func (s *instrumentedStore) ListSessionsSharedWithUser(ctx context.Context, userID string) (_ []Session, err error) {
+	defer s.observe("ListSessionsSharedWithUser", time.Now(), &err)
+	return s.next.ListSessionsSharedWithUser(ctx, userID)
+}
+
+func (s *instrumentedStore) CopyMessages(ctx context.Context, fromSessionID string, toSessionID string, uptoSeq int64) (_ int64, err error) {
+	defer s.observe("CopyMessages", time.Now(), &err)
+	return s.next.CopyMessages(ctx, fromSessionID, toSessionID, uptoSeq)
+}
+
+func (s *instrumentedStore) ListChildSessions(ctx context.Context, parentID string) (_ []Session, err error) {
+	defer s.observe("ListChildSessions", time.Now(), &err)
+	return s.next.ListChildSessions(ctx, parentID)
+}
+
+func (s *instrumentedStore) CreateSchedule(ctx context.Context, sc *Schedule) (err error) {
+	defer s.observe("CreateSchedule", time.Now(), &err)
+	return s.next.CreateSchedule(ctx, sc)
+}
+
+func (s *instrumentedStore) GetSchedule(ctx context.Context, id string) (_ *Schedule, err error) {
+	defer s.observe("GetSchedule", time.Now(), &err)
+	return s.next.GetSchedule(ctx, id)
+}
+
+func (s *instrumentedStore) ListSchedules(ctx context.Context, orgID string) (_ []Schedule, err error) {
+	defer s.observe("ListSchedules", time.Now(), &err)
+	return s.next.ListSchedules(ctx, orgID)
+}
+
+func (s *instrumentedStore) UpdateSchedule(ctx context.Context, sc *Schedule) (err error) {
+	defer s.observe("UpdateSchedule", time.Now(), &err)
+	return s.next.UpdateSchedule(ctx, sc)
+}
+
+func (s *instrumentedStore) DeleteSchedule(ctx context.Context, id string) (err error) {
+	defer s.observe("DeleteSchedule", time.Now(), &err)
+	return s.next.DeleteSchedule(ctx, id)
+}
+
+func (s *instrumentedStore) ListDueSchedules(ctx context.Context, before time.Time) (_ []Schedule, err error) {
+	defer s.observe("ListDueSchedules", time.Now(), &err)
+	return s.next.ListDueSchedules(ctx, before)
+}
+
+func (s *instrumentedStore) CreateScheduleRun(ctx context.Context, run *ScheduleRun) (err error) {
+	defer s.observe("CreateScheduleRun", time.Now(), &err)
+	return s.next.CreateScheduleRun(ctx, run)
+}
+
+func (s *instrumentedStore) UpdateScheduleRun(ctx context.Context, run *ScheduleRun) (err error) {
+	defer s.observe("UpdateScheduleRun", time.Now(), &err)
+	return s.next.UpdateScheduleRun(ctx, run)
+}
+
+func (s *instrumentedStore) ListScheduleRuns(ctx context.Context, scheduleID string, limit int) (_ []ScheduleRun, err error) {
+	defer s.observe("ListScheduleRuns", time.Now(), &err)
+	return s.next.ListScheduleRuns(ctx, scheduleID, limit)
+}
+
+func (s *instrumentedStore) CreatePermissionPolicy(ctx context.Context, p *PermissionPolicy) (err error) {
+	defer s.observe("CreatePermissionPolicy", time.Now(), &err)
+	return s.next.CreatePermissionPolicy(ctx, p)
+}
+
+func (s *instrumentedStore) GetPermissionPolicy(ctx context.Context, id string) (_ *PermissionPolicy, err error) {
+	defer s.observe("GetPermissionPolicy", time.Now(), &err)
+	return s.next.GetPermissionPolicy(ctx, id)
+}
+
+func (s *instrumentedStore) ListPermissionPolicies(ctx context.Context, orgID string) (_ []PermissionPolicy, err error) {
+	defer s.observe("ListPermissionPolicies", time.Now(), &err)
+	return s.next.ListPermissionPolicies(ctx, orgID)
+}
+
+func (s *instrumentedStore) UpdatePermissionPolicy(ctx context.Context, p *PermissionPolicy) (err error) {
+	defer s.observe("UpdatePermissionPolicy", time.Now(), &err)
+	return s.next.UpdatePermissionPolicy(ctx, p)
+}
+
+func (s *instrumentedStore) DeletePermissionPolicy(ctx context.Context, id string) (err error) {
+	defer s.observe("DeletePermissionPolicy", time.Now(), &err)
+	return s.next.DeletePermissionPolicy(ctx, id)
+}
+
+func (s *instrumentedStore) CountActiveSessionsByOrg(ctx context.Context, orgID string) (_ int, err error) {
+	defer s.observe("CountActiveSessionsByOrg", time.Now(), &err)
+	return s.next.CountActiveSessionsByOrg(ctx, orgID)
+}
+
+func (s *instrumentedStore) CountOnlineRuntimesByOrg(ctx context.Context, orgID string) (_ int, err error) {
+	defer s.observe("CountOnlineRuntimesByOrg", time.Now(), &err)
+	return s.next.CountOnlineRuntimesByOrg(ctx, orgID)
+}
+
+func (s *instrumentedStore) Ping(ctx context.Context) (err error) {
+	defer s.observe("Ping", time.Now(), &err)
+	return s.next.Ping(ctx)
+}
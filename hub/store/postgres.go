@@ -205,6 +205,50 @@ func (s *PostgresStore) migrate() error {
 			ALTER TABLE sessions ADD COLUMN prompt_profile TEXT NOT NULL DEFAULT 'standard';
 		EXCEPTION WHEN duplicate_column THEN NULL;
 		END $$`,
+		`DO $$ BEGIN
+			ALTER TABLE sessions ADD COLUMN fork_seq BIGINT NOT NULL DEFAULT 0;
+		EXCEPTION WHEN duplicate_column THEN NULL;
+		END $$`,
+		`DO $$ BEGIN
+			ALTER TABLE sessions ADD COLUMN fork_mode TEXT NOT NULL DEFAULT '';
+		EXCEPTION WHEN duplicate_column THEN NULL;
+		END $$`,
+		`DO $$ BEGIN
+			ALTER TABLE sessions ADD COLUMN branch TEXT NOT NULL DEFAULT '';
+		EXCEPTION WHEN duplicate_column THEN NULL;
+		END $$`,
+		// Full-text search over message content. The generated column is
+		// populated for existing rows when added and on every AppendMessage.
+		`DO $$ BEGIN
+			ALTER TABLE messages ADD COLUMN content_tsv tsvector
+				GENERATED ALWAYS AS (to_tsvector('english', content)) STORED;
+		EXCEPTION WHEN duplicate_column THEN NULL;
+		END $$`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_content_tsv ON messages USING GIN (content_tsv)`,
+		`DO $$ BEGIN
+			ALTER TABLE messages ADD COLUMN author_id TEXT NOT NULL DEFAULT '';
+		EXCEPTION WHEN duplicate_column THEN NULL;
+		END $$`,
+		`DO $$ BEGIN
+			ALTER TABLE agents ADD COLUMN sandbox TEXT NOT NULL DEFAULT '';
+		EXCEPTION WHEN duplicate_column THEN NULL;
+		END $$`,
+		`DO $$ BEGIN
+			ALTER TABLE organizations ADD COLUMN require_admin_mfa BOOLEAN NOT NULL DEFAULT FALSE;
+		EXCEPTION WHEN duplicate_column THEN NULL;
+		END $$`,
+		`DO $$ BEGIN
+			ALTER TABLE users ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
+		EXCEPTION WHEN duplicate_column THEN NULL;
+		END $$`,
+		`DO $$ BEGIN
+			ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMPTZ;
+		EXCEPTION WHEN duplicate_column THEN NULL;
+		END $$`,
+		`DO $$ BEGIN
+			ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
+		EXCEPTION WHEN duplicate_column THEN NULL;
+		END $$`,
 	}
 	for _, m := range subscriptionMigrations {
 		if _, err := s.db.Exec(m); err != nil {
@@ -212,6 +256,224 @@ func (s *PostgresStore) migrate() error {
 		}
 	}
 
+	// Outbound webhooks and their delivery log.
+	webhookMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS webhooks (
+			id TEXT PRIMARY KEY,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			events JSONB NOT NULL DEFAULT '[]',
+			description TEXT NOT NULL DEFAULT '',
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhooks_org_id ON webhooks(org_id)`,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id TEXT PRIMARY KEY,
+			webhook_id TEXT NOT NULL,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			event_id TEXT NOT NULL,
+			event_type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			response_code INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at)`,
+	}
+	for _, m := range webhookMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+
+	// Persistent permission policy rules.
+	policyMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS permission_policies (
+			id TEXT PRIMARY KEY,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			agent_id TEXT NOT NULL DEFAULT '',
+			user_id TEXT NOT NULL DEFAULT '',
+			tool TEXT NOT NULL DEFAULT '',
+			pattern TEXT NOT NULL DEFAULT '',
+			effect TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_permission_policies_org_id ON permission_policies(org_id)`,
+		`DO $$ BEGIN
+			ALTER TABLE permission_policies ADD COLUMN exact BOOLEAN NOT NULL DEFAULT FALSE;
+		EXCEPTION WHEN duplicate_column THEN NULL;
+		END $$`,
+	}
+	for _, m := range policyMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+
+	// Users a session is shared with, beyond its owner.
+	memberMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS session_members (
+			session_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			role TEXT NOT NULL,
+			added_by TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (session_id, user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_session_members_user_id ON session_members(user_id)`,
+	}
+	for _, m := range memberMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+
+	// Scheduled agent runs and their history.
+	scheduleMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS schedules (
+			id TEXT PRIMARY KEY,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			user_id TEXT NOT NULL,
+			agent_id TEXT NOT NULL,
+			name TEXT NOT NULL DEFAULT '',
+			cron TEXT NOT NULL,
+			prompt TEXT NOT NULL,
+			prompt_profile TEXT NOT NULL DEFAULT '',
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			next_run_at TIMESTAMPTZ NOT NULL,
+			last_run_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_schedules_org_id ON schedules(org_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_schedules_due ON schedules(enabled, next_run_at)`,
+		`CREATE TABLE IF NOT EXISTS schedule_runs (
+			id TEXT PRIMARY KEY,
+			schedule_id TEXT NOT NULL,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			session_id TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL,
+			result TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT '',
+			scheduled_for TIMESTAMPTZ NOT NULL,
+			started_at TIMESTAMPTZ NOT NULL,
+			finished_at TIMESTAMPTZ
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_schedule_runs_schedule ON schedule_runs(schedule_id, started_at)`,
+	}
+	for _, m := range scheduleMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+
+	// Personal access tokens.
+	userTokenMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS user_tokens (
+			id TEXT PRIMARY KEY,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			token_hash TEXT NOT NULL,
+			scopes TEXT NOT NULL DEFAULT '[]',
+			expires_at TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			last_used_at TIMESTAMPTZ
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_user_tokens_hash ON user_tokens(token_hash)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_tokens_user_id ON user_tokens(user_id)`,
+	}
+	for _, m := range userTokenMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+
+	// TOTP second factors.
+	mfaMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS user_mfa (
+			user_id TEXT PRIMARY KEY,
+			secret TEXT NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT FALSE,
+			recovery_codes TEXT NOT NULL DEFAULT '[]',
+			last_step BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			enabled_at TIMESTAMPTZ
+		)`,
+	}
+	for _, m := range mfaMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+
+	// Admin-issued password reset links.
+	passwordResetMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS password_resets (
+			id TEXT PRIMARY KEY,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			user_id TEXT NOT NULL,
+			token_hash TEXT NOT NULL,
+			created_by TEXT NOT NULL DEFAULT '',
+			expires_at TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_password_resets_hash ON password_resets(token_hash)`,
+		`CREATE INDEX IF NOT EXISTS idx_password_resets_user_id ON password_resets(user_id)`,
+	}
+	for _, m := range passwordResetMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+
+	groupMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS user_groups (
+			id TEXT PRIMARY KEY,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_user_groups_org_name ON user_groups(org_id, name)`,
+		`CREATE TABLE IF NOT EXISTS group_members (
+			group_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			added_by TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (group_id, user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_group_members_user_id ON group_members(user_id)`,
+		`CREATE TABLE IF NOT EXISTS group_grants (
+			id TEXT PRIMARY KEY,
+			group_id TEXT NOT NULL,
+			agent_id TEXT NOT NULL DEFAULT '',
+			tag_key TEXT NOT NULL DEFAULT '',
+			tag_value TEXT NOT NULL DEFAULT '',
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_group_grants_unique ON group_grants(group_id, agent_id, tag_key, tag_value)`,
+	}
+	for _, m := range groupMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+
 	// Phase: rename endpoint -> agent (migration for existing databases)
 	if pgTableExists(s.db, "endpoints") {
 		renameStmts := []string{
@@ -329,6 +591,9 @@ func (s *PostgresStore) normalizeExternalUserIDs() error {
 		if _, err := tx.Exec(`UPDATE runtime_tokens SET created_by = $1 WHERE created_by = $2`, m.externalID, m.oldID); err != nil {
 			return err
 		}
+		if _, err := tx.Exec(`UPDATE user_tokens SET user_id = $1 WHERE user_id = $2`, m.externalID, m.oldID); err != nil {
+			return err
+		}
 
 		var targetExists bool
 		if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, m.externalID).Scan(&targetExists); err != nil {
@@ -369,14 +634,22 @@ func (s *PostgresStore) CreateOrganization(ctx context.Context, org *Organizatio
 func (s *PostgresStore) GetOrganization(ctx context.Context, id string) (*Organization, error) {
 	var org Organization
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, name, created_at FROM organizations WHERE id = $1", id,
-	).Scan(&org.ID, &org.Name, &org.CreatedAt)
+		"SELECT id, name, require_admin_mfa, created_at FROM organizations WHERE id = $1", id,
+	).Scan(&org.ID, &org.Name, &org.RequireAdminMFA, &org.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	return &org, err
 }
 
+func (s *PostgresStore) UpdateOrganization(ctx context.Context, org *Organization) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE organizations SET name = $1, require_admin_mfa = $2 WHERE id = $3",
+		org.Name, org.RequireAdminMFA, org.ID,
+	)
+	return err
+}
+
 // --- Users ---
 
 func (s *PostgresStore) CreateUser(ctx context.Context, user *User) error {
@@ -390,9 +663,9 @@ func (s *PostgresStore) CreateUser(ctx context.Context, user *User) error {
 func (s *PostgresStore) GetUser(ctx context.Context, orgID, username string) (*User, error) {
 	var u User
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, org_id, external_id, username, password_hash, role, created_at FROM users WHERE org_id = $1 AND username = $2",
+		"SELECT id, org_id, external_id, username, password_hash, role, created_at, disabled, password_changed_at, deleted_at FROM users WHERE org_id = $1 AND username = $2",
 		orgID, username,
-	).Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt)
+	).Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.Disabled, &u.PasswordChangedAt, &u.DeletedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -402,8 +675,8 @@ func (s *PostgresStore) GetUser(ctx context.Context, orgID, username string) (*U
 func (s *PostgresStore) GetUserByID(ctx context.Context, id string) (*User, error) {
 	var u User
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, org_id, external_id, username, password_hash, role, created_at FROM users WHERE id = $1", id,
-	).Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt)
+		"SELECT id, org_id, external_id, username, password_hash, role, created_at, disabled, password_changed_at, deleted_at FROM users WHERE id = $1", id,
+	).Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.Disabled, &u.PasswordChangedAt, &u.DeletedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -413,9 +686,9 @@ func (s *PostgresStore) GetUserByID(ctx context.Context, id string) (*User, erro
 func (s *PostgresStore) GetUserByExternalID(ctx context.Context, externalID string) (*User, error) {
 	var u User
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, org_id, external_id, username, password_hash, role, created_at FROM users WHERE external_id = $1",
+		"SELECT id, org_id, external_id, username, password_hash, role, created_at, disabled, password_changed_at, deleted_at FROM users WHERE external_id = $1",
 		externalID,
-	).Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt)
+	).Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.Disabled, &u.PasswordChangedAt, &u.DeletedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -424,7 +697,7 @@ func (s *PostgresStore) GetUserByExternalID(ctx context.Context, externalID stri
 
 func (s *PostgresStore) ListUsers(ctx context.Context, orgID string) ([]User, error) {
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, org_id, external_id, username, password_hash, role, created_at FROM users WHERE org_id = $1 ORDER BY created_at",
+		"SELECT id, org_id, external_id, username, password_hash, role, created_at, disabled, password_changed_at, deleted_at FROM users WHERE org_id = $1 AND deleted_at IS NULL ORDER BY created_at",
 		orgID,
 	)
 	if err != nil {
@@ -435,7 +708,7 @@ func (s *PostgresStore) ListUsers(ctx context.Context, orgID string) ([]User, er
 	var users []User
 	for rows.Next() {
 		var u User
-		if err := rows.Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt); err != nil {
+		if err := rows.Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.Disabled, &u.PasswordChangedAt, &u.DeletedAt); err != nil {
 			return nil, err
 		}
 		users = append(users, u)
@@ -443,6 +716,49 @@ func (s *PostgresStore) ListUsers(ctx context.Context, orgID string) ([]User, er
 	return users, rows.Err()
 }
 
+// UpdateUser saves a user's role, password and disabled state.
+func (s *PostgresStore) UpdateUser(ctx context.Context, user *User) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE users SET role = $1, password_hash = $2, disabled = $3, password_changed_at = $4 WHERE id = $5",
+		user.Role, user.PasswordHash, user.Disabled, user.PasswordChangedAt, user.ID,
+	)
+	return err
+}
+
+// DeleteUser removes a user's credentials, agent grants, session memberships,
+// personal permission rules and pending password resets, and turns off their
+// schedules. The row itself stays, renamed and marked deleted, because
+// sessions and audit events keep referring to it.
+func (s *PostgresStore) DeleteUser(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE users SET username = 'deleted:' || id, password_hash = '', disabled = $1, deleted_at = $2 WHERE id = $3",
+		true, time.Now(), id,
+	); err != nil {
+		return err
+	}
+	for _, q := range []string{
+		"DELETE FROM user_tokens WHERE user_id = $1",
+		"DELETE FROM user_mfa WHERE user_id = $1",
+		"DELETE FROM password_resets WHERE user_id = $1",
+		"DELETE FROM agent_permissions WHERE user_id = $1",
+		"DELETE FROM group_members WHERE user_id = $1",
+		"DELETE FROM session_members WHERE user_id = $1",
+		"DELETE FROM permission_policies WHERE user_id = $1",
+		"UPDATE schedules SET enabled = FALSE WHERE user_id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 // --- Runtimes ---
 
 func (s *PostgresStore) UpsertRuntime(ctx context.Context, rt *Runtime) error {
@@ -498,9 +814,9 @@ func (s *PostgresStore) SetRuntimeOnline(ctx context.Context, id string, online
 
 func (s *PostgresStore) UpsertAgent(ctx context.Context, agent *Agent) error {
 	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO agents (id, org_id, runtime_id, profile, name, tags, caps, security) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		 ON CONFLICT(id) DO UPDATE SET org_id=EXCLUDED.org_id, runtime_id=EXCLUDED.runtime_id, profile=EXCLUDED.profile, name=EXCLUDED.name, tags=EXCLUDED.tags, caps=EXCLUDED.caps, security=EXCLUDED.security`,
-		agent.ID, agent.OrgID, agent.RuntimeID, agent.Profile, agent.Name, agent.Tags, agent.Caps, agent.Security,
+		`INSERT INTO agents (id, org_id, runtime_id, profile, name, tags, caps, security, sandbox) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 ON CONFLICT(id) DO UPDATE SET org_id=EXCLUDED.org_id, runtime_id=EXCLUDED.runtime_id, profile=EXCLUDED.profile, name=EXCLUDED.name, tags=EXCLUDED.tags, caps=EXCLUDED.caps, security=EXCLUDED.security, sandbox=EXCLUDED.sandbox`,
+		agent.ID, agent.OrgID, agent.RuntimeID, agent.Profile, agent.Name, agent.Tags, agent.Caps, agent.Security, agent.Sandbox,
 	)
 	return err
 }
@@ -508,8 +824,8 @@ func (s *PostgresStore) UpsertAgent(ctx context.Context, agent *Agent) error {
 func (s *PostgresStore) GetAgent(ctx context.Context, id string) (*Agent, error) {
 	var agent Agent
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, org_id, runtime_id, profile, name, tags, caps, security FROM agents WHERE id = $1", id,
-	).Scan(&agent.ID, &agent.OrgID, &agent.RuntimeID, &agent.Profile, &agent.Name, &agent.Tags, &agent.Caps, &agent.Security)
+		"SELECT id, org_id, runtime_id, profile, name, tags, caps, security, sandbox FROM agents WHERE id = $1", id,
+	).Scan(&agent.ID, &agent.OrgID, &agent.RuntimeID, &agent.Profile, &agent.Name, &agent.Tags, &agent.Caps, &agent.Security, &agent.Sandbox)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -518,7 +834,7 @@ func (s *PostgresStore) GetAgent(ctx context.Context, id string) (*Agent, error)
 
 func (s *PostgresStore) ListAgents(ctx context.Context, orgID string) ([]Agent, error) {
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, org_id, runtime_id, profile, name, tags, caps, security FROM agents WHERE org_id = $1 ORDER BY name",
+		"SELECT id, org_id, runtime_id, profile, name, tags, caps, security, sandbox FROM agents WHERE org_id = $1 ORDER BY name",
 		orgID,
 	)
 	if err != nil {
@@ -529,7 +845,7 @@ func (s *PostgresStore) ListAgents(ctx context.Context, orgID string) ([]Agent,
 	var agents []Agent
 	for rows.Next() {
 		var agent Agent
-		if err := rows.Scan(&agent.ID, &agent.OrgID, &agent.RuntimeID, &agent.Profile, &agent.Name, &agent.Tags, &agent.Caps, &agent.Security); err != nil {
+		if err := rows.Scan(&agent.ID, &agent.OrgID, &agent.RuntimeID, &agent.Profile, &agent.Name, &agent.Tags, &agent.Caps, &agent.Security, &agent.Sandbox); err != nil {
 			return nil, err
 		}
 		agents = append(agents, agent)
@@ -539,7 +855,7 @@ func (s *PostgresStore) ListAgents(ctx context.Context, orgID string) ([]Agent,
 
 func (s *PostgresStore) ListAgentsByRuntime(ctx context.Context, runtimeID string) ([]Agent, error) {
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, org_id, runtime_id, profile, name, tags, caps, security FROM agents WHERE runtime_id = $1 ORDER BY name",
+		"SELECT id, org_id, runtime_id, profile, name, tags, caps, security, sandbox FROM agents WHERE runtime_id = $1 ORDER BY name",
 		runtimeID,
 	)
 	if err != nil {
@@ -550,7 +866,7 @@ func (s *PostgresStore) ListAgentsByRuntime(ctx context.Context, runtimeID strin
 	var agents []Agent
 	for rows.Next() {
 		var agent Agent
-		if err := rows.Scan(&agent.ID, &agent.OrgID, &agent.RuntimeID, &agent.Profile, &agent.Name, &agent.Tags, &agent.Caps, &agent.Security); err != nil {
+		if err := rows.Scan(&agent.ID, &agent.OrgID, &agent.RuntimeID, &agent.Profile, &agent.Name, &agent.Tags, &agent.Caps, &agent.Security, &agent.Sandbox); err != nil {
 			return nil, err
 		}
 		agents = append(agents, agent)
@@ -567,10 +883,10 @@ func (s *PostgresStore) DeleteAgentsByRuntime(ctx context.Context, runtimeID str
 
 func (s *PostgresStore) CreateSession(ctx context.Context, sess *Session) error {
 	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO sessions (id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		`INSERT INTO sessions (id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, fork_seq, fork_mode, branch, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`,
 		sess.ID, sess.OrgID, sess.UserID, sess.AgentID, sess.RuntimeID, sess.Profile,
-		sess.PromptProfile, sess.State, sess.NativeHandle, sess.ResumedFrom, sess.CreatedAt, sess.UpdatedAt,
+		sess.PromptProfile, sess.State, sess.NativeHandle, sess.ResumedFrom, sess.ForkSeq, sess.ForkMode, sess.Branch, sess.CreatedAt, sess.UpdatedAt,
 	)
 	return err
 }
@@ -578,10 +894,10 @@ func (s *PostgresStore) CreateSession(ctx context.Context, sess *Session) error
 func (s *PostgresStore) GetSession(ctx context.Context, id string) (*Session, error) {
 	var sess Session
 	err := s.db.QueryRowContext(ctx,
-		`SELECT id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, created_at, updated_at
+		`SELECT id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, fork_seq, fork_mode, branch, created_at, updated_at
 		 FROM sessions WHERE id = $1`, id,
 	).Scan(&sess.ID, &sess.OrgID, &sess.UserID, &sess.AgentID, &sess.RuntimeID, &sess.Profile,
-		&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.CreatedAt, &sess.UpdatedAt)
+		&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.ForkSeq, &sess.ForkMode, &sess.Branch, &sess.CreatedAt, &sess.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -590,13 +906,13 @@ func (s *PostgresStore) GetSession(ctx context.Context, id string) (*Session, er
 
 func (s *PostgresStore) ListSessionsByUser(ctx context.Context, userID string) ([]Session, error) {
 	rows, err := s.db.QueryContext(ctx,
-		`SELECT s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from,
+		`SELECT s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from, s.fork_seq, s.fork_mode, s.branch,
 		        s.created_at, s.updated_at, COALESCE(a.name, '') as agent_name, COUNT(m.id) as message_count
 		 FROM sessions s
 		 LEFT JOIN agents a ON s.agent_id = a.id
 		 LEFT JOIN messages m ON m.session_id = s.id
 		 WHERE s.user_id = $1
-		 GROUP BY s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from,
+		 GROUP BY s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from, s.fork_seq, s.fork_mode, s.branch,
 		          s.created_at, s.updated_at, a.name
 		 ORDER BY s.updated_at DESC`, userID,
 	)
@@ -609,7 +925,7 @@ func (s *PostgresStore) ListSessionsByUser(ctx context.Context, userID string) (
 	for rows.Next() {
 		var sess Session
 		if err := rows.Scan(&sess.ID, &sess.OrgID, &sess.UserID, &sess.AgentID, &sess.RuntimeID, &sess.Profile,
-			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.CreatedAt, &sess.UpdatedAt, &sess.AgentName, &sess.MessageCount); err != nil {
+			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.ForkSeq, &sess.ForkMode, &sess.Branch, &sess.CreatedAt, &sess.UpdatedAt, &sess.AgentName, &sess.MessageCount); err != nil {
 			return nil, err
 		}
 		sessions = append(sessions, sess)
@@ -633,6 +949,14 @@ func (s *PostgresStore) SetSessionNativeHandle(ctx context.Context, id, handle s
 	return err
 }
 
+func (s *PostgresStore) SetSessionBranch(ctx context.Context, id, branch string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE sessions SET branch = $1, updated_at = $2 WHERE id = $3",
+		branch, time.Now(), id,
+	)
+	return err
+}
+
 // --- Messages ---
 
 func (s *PostgresStore) AppendMessage(ctx context.Context, msg *Message) (int64, error) {
@@ -644,10 +968,10 @@ func (s *PostgresStore) AppendMessage(ctx context.Context, msg *Message) (int64,
 	defer func() { _ = tx.Rollback() }()
 
 	if err = tx.QueryRowContext(ctx,
-		`INSERT INTO messages (id, session_id, seq, direction, channel, content, created_at)
-		 VALUES ($1, $2, (SELECT COALESCE(MAX(seq),0)+1 FROM messages WHERE session_id = $3), $4, $5, $6, $7)
+		`INSERT INTO messages (id, session_id, seq, direction, channel, content, author_id, created_at)
+		 VALUES ($1, $2, (SELECT COALESCE(MAX(seq),0)+1 FROM messages WHERE session_id = $3), $4, $5, $6, $7, $8)
 		 RETURNING seq`,
-		msg.ID, msg.SessionID, msg.SessionID, msg.Direction, msg.Channel, msg.Content, msg.CreatedAt,
+		msg.ID, msg.SessionID, msg.SessionID, msg.Direction, msg.Channel, msg.Content, msg.AuthorID, msg.CreatedAt,
 	).Scan(&seq); err != nil {
 		return 0, err
 	}
@@ -664,7 +988,7 @@ func (s *PostgresStore) AppendMessage(ctx context.Context, msg *Message) (int64,
 
 func (s *PostgresStore) GetMessages(ctx context.Context, sessionID string, afterSeq int64, limit int) ([]Message, error) {
 	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, session_id, seq, direction, channel, content, created_at
+		`SELECT id, session_id, seq, direction, channel, content, author_id, created_at
 		 FROM messages WHERE session_id = $1 AND seq > $2 ORDER BY seq LIMIT $3`,
 		sessionID, afterSeq, limit,
 	)
@@ -676,7 +1000,7 @@ func (s *PostgresStore) GetMessages(ctx context.Context, sessionID string, after
 	var messages []Message
 	for rows.Next() {
 		var m Message
-		if err := rows.Scan(&m.ID, &m.SessionID, &m.Seq, &m.Direction, &m.Channel, &m.Content, &m.CreatedAt); err != nil {
+		if err := rows.Scan(&m.ID, &m.SessionID, &m.Seq, &m.Direction, &m.Channel, &m.Content, &m.AuthorID, &m.CreatedAt); err != nil {
 			return nil, err
 		}
 		messages = append(messages, m)
@@ -692,6 +1016,57 @@ func (s *PostgresStore) MessageExists(ctx context.Context, sessionID, messageID
 	return count > 0, err
 }
 
+// --- Search ---
+
+func (s *PostgresStore) SearchMessages(ctx context.Context, filter SearchFilter) ([]SearchResult, error) {
+	if strings.TrimSpace(filter.Query) == "" {
+		return nil, nil
+	}
+
+	query := `SELECT m.session_id, m.seq, m.id, m.direction, m.channel,
+		        ts_headline('english', m.content, q, 'StartSel="**", StopSel="**", MaxWords=32, MinWords=8'),
+		        s.agent_id, COALESCE(a.name, ''), m.created_at
+		 FROM messages m
+		 CROSS JOIN websearch_to_tsquery('english', $1) AS q
+		 JOIN sessions s ON s.id = m.session_id
+		 LEFT JOIN agents a ON a.id = s.agent_id
+		 WHERE m.content_tsv @@ q AND s.org_id = $2`
+	args := []any{filter.Query, filter.OrgID}
+	argN := 3
+
+	if filter.UserID != "" {
+		query += fmt.Sprintf(" AND (s.user_id = $%d OR s.id IN (SELECT session_id FROM session_members WHERE user_id = $%d))", argN, argN)
+		args = append(args, filter.UserID)
+		argN++
+	}
+
+	query += " ORDER BY ts_rank(m.content_tsv, q) DESC, m.created_at DESC"
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argN, argN+1)
+	args = append(args, limit, filter.Offset)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.SessionID, &r.Seq, &r.MessageID, &r.Direction, &r.Channel,
+			&r.Snippet, &r.AgentID, &r.AgentName, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
 // --- Sessions (additional) ---
 
 func (s *PostgresStore) ListActiveSessions(ctx context.Context, orgID string) ([]Session, error) {
@@ -699,11 +1074,11 @@ func (s *PostgresStore) ListActiveSessions(ctx context.Context, orgID string) ([
 	var err error
 	if orgID == "" {
 		rows, err = s.db.QueryContext(ctx,
-			`SELECT id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, created_at, updated_at
+			`SELECT id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, fork_seq, fork_mode, branch, created_at, updated_at
 			 FROM sessions WHERE state NOT IN ('closed') ORDER BY updated_at DESC`)
 	} else {
 		rows, err = s.db.QueryContext(ctx,
-			`SELECT id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, created_at, updated_at
+			`SELECT id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, fork_seq, fork_mode, branch, created_at, updated_at
 			 FROM sessions WHERE org_id = $1 AND state NOT IN ('closed') ORDER BY updated_at DESC`,
 			orgID)
 	}
@@ -716,7 +1091,7 @@ func (s *PostgresStore) ListActiveSessions(ctx context.Context, orgID string) ([
 	for rows.Next() {
 		var sess Session
 		if err := rows.Scan(&sess.ID, &sess.OrgID, &sess.UserID, &sess.AgentID, &sess.RuntimeID, &sess.Profile,
-			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.CreatedAt, &sess.UpdatedAt); err != nil {
+			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.ForkSeq, &sess.ForkMode, &sess.Branch, &sess.CreatedAt, &sess.UpdatedAt); err != nil {
 			return nil, err
 		}
 		sessions = append(sessions, sess)
@@ -732,6 +1107,25 @@ func (s *PostgresStore) CountActiveSessionsByUser(ctx context.Context, userID st
 	return count, err
 }
 
+func (s *PostgresStore) CountSessionsByState(ctx context.Context) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT state, COUNT(*) FROM sessions GROUP BY state")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var state string
+		var n int
+		if err := rows.Scan(&state, &n); err != nil {
+			return nil, err
+		}
+		counts[state] = n
+	}
+	return counts, rows.Err()
+}
+
 // --- Agent Permissions ---
 
 func (s *PostgresStore) GrantAgentAccess(ctx context.Context, userID, agentID string) error {
@@ -751,10 +1145,24 @@ func (s *PostgresStore) RevokeAgentAccess(ctx context.Context, userID, agentID s
 	return err
 }
 
+// pgUserAgentsQuery selects the IDs of every agent user $1 can access:
+// direct grants, agent grants of their groups, and agents whose tags match a
+// tag grant of their groups.
+const pgUserAgentsQuery = `
+	SELECT agent_id FROM agent_permissions WHERE user_id = $1
+	UNION
+	SELECT gg.agent_id FROM group_grants gg
+	JOIN group_members gm ON gm.group_id = gg.group_id
+	WHERE gm.user_id = $1 AND gg.agent_id != ''
+	UNION
+	SELECT a.id FROM agents a
+	JOIN user_groups g ON g.org_id = a.org_id
+	JOIN group_members gm ON gm.group_id = g.id
+	JOIN group_grants gg ON gg.group_id = g.id
+	WHERE gm.user_id = $1 AND gg.tag_key != '' AND a.tags ->> gg.tag_key = gg.tag_value`
+
 func (s *PostgresStore) ListUserAgents(ctx context.Context, userID string) ([]string, error) {
-	rows, err := s.db.QueryContext(ctx,
-		"SELECT agent_id FROM agent_permissions WHERE user_id = $1", userID,
-	)
+	rows, err := s.db.QueryContext(ctx, pgUserAgentsQuery, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -774,51 +1182,206 @@ func (s *PostgresStore) ListUserAgents(ctx context.Context, userID string) ([]st
 func (s *PostgresStore) HasAgentAccess(ctx context.Context, userID, agentID string) (bool, error) {
 	var count int
 	err := s.db.QueryRowContext(ctx,
-		"SELECT COUNT(*) FROM agent_permissions WHERE user_id = $1 AND agent_id = $2",
+		"SELECT COUNT(*) FROM ("+pgUserAgentsQuery+") ua WHERE ua.agent_id = $2",
 		userID, agentID,
 	).Scan(&count)
 	return count > 0, err
 }
 
-// --- Audit ---
+// --- Groups ---
 
-func (s *PostgresStore) LogAuditEvent(ctx context.Context, event *AuditEvent) error {
-	detail := ""
-	if event.Detail != nil {
-		detail = string(event.Detail)
-	}
+func (s *PostgresStore) CreateGroup(ctx context.Context, g *Group) error {
 	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO audit_events (id, org_id, action, user_id, runtime_id, session_id, agent_id, detail, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
-		event.ID, event.OrgID, event.Action, event.UserID, event.RuntimeID, event.SessionID, event.AgentID, detail, event.CreatedAt,
+		`INSERT INTO user_groups (id, org_id, name, description, created_by, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		g.ID, g.OrgID, g.Name, g.Description, g.CreatedBy, g.CreatedAt,
 	)
 	return err
 }
 
-func (s *PostgresStore) ListAuditEvents(ctx context.Context, orgID string, limit, offset int) ([]AuditEvent, error) {
-	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, org_id, action, user_id, runtime_id, session_id, agent_id, detail, created_at
-		 FROM audit_events WHERE org_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`,
-		orgID, limit, offset,
-	)
-	if err != nil {
-		return nil, err
+func (s *PostgresStore) GetGroup(ctx context.Context, id string) (*Group, error) {
+	var g Group
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, org_id, name, description, created_by, created_at FROM user_groups WHERE id = $1", id,
+	).Scan(&g.ID, &g.OrgID, &g.Name, &g.Description, &g.CreatedBy, &g.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-	defer func() { _ = rows.Close() }()
+	return &g, err
+}
 
-	var events []AuditEvent
-	for rows.Next() {
-		var e AuditEvent
-		var detail string
-		if err := rows.Scan(&e.ID, &e.OrgID, &e.Action, &e.UserID, &e.RuntimeID, &e.SessionID, &e.AgentID, &detail, &e.CreatedAt); err != nil {
-			return nil, err
-		}
-		if detail != "" {
-			e.Detail = json.RawMessage(detail)
-		}
-		events = append(events, e)
-	}
-	return events, rows.Err()
+func (s *PostgresStore) ListGroups(ctx context.Context, orgID string) ([]Group, error) {
+	return s.queryGroups(ctx,
+		"SELECT id, org_id, name, description, created_by, created_at FROM user_groups WHERE org_id = $1 ORDER BY name", orgID,
+	)
+}
+
+func (s *PostgresStore) UpdateGroup(ctx context.Context, g *Group) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE user_groups SET name = $1, description = $2 WHERE id = $3",
+		g.Name, g.Description, g.ID,
+	)
+	return err
+}
+
+func (s *PostgresStore) DeleteGroup(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, q := range []string{
+		"DELETE FROM group_grants WHERE group_id = $1",
+		"DELETE FROM group_members WHERE group_id = $1",
+		"DELETE FROM user_groups WHERE id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) AddGroupMember(ctx context.Context, m *GroupMember) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO group_members (group_id, user_id, added_by, created_at) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (group_id, user_id) DO NOTHING`,
+		m.GroupID, m.UserID, m.AddedBy, m.CreatedAt,
+	)
+	return err
+}
+
+func (s *PostgresStore) RemoveGroupMember(ctx context.Context, groupID, userID string) error {
+	_, err := s.db.ExecContext(ctx,
+		"DELETE FROM group_members WHERE group_id = $1 AND user_id = $2", groupID, userID,
+	)
+	return err
+}
+
+func (s *PostgresStore) ListGroupMembers(ctx context.Context, groupID string) ([]GroupMember, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT gm.group_id, gm.user_id, COALESCE(u.username, ''), gm.added_by, gm.created_at
+		 FROM group_members gm
+		 LEFT JOIN users u ON u.id = gm.user_id
+		 WHERE gm.group_id = $1 ORDER BY gm.created_at`, groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var members []GroupMember
+	for rows.Next() {
+		var m GroupMember
+		if err := rows.Scan(&m.GroupID, &m.UserID, &m.Username, &m.AddedBy, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (s *PostgresStore) ListUserGroups(ctx context.Context, userID string) ([]Group, error) {
+	return s.queryGroups(ctx,
+		`SELECT g.id, g.org_id, g.name, g.description, g.created_by, g.created_at
+		 FROM user_groups g
+		 JOIN group_members gm ON gm.group_id = g.id
+		 WHERE gm.user_id = $1 ORDER BY g.name`, userID,
+	)
+}
+
+func (s *PostgresStore) queryGroups(ctx context.Context, query string, args ...any) ([]Group, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var groups []Group
+	for rows.Next() {
+		var g Group
+		if err := rows.Scan(&g.ID, &g.OrgID, &g.Name, &g.Description, &g.CreatedBy, &g.CreatedAt); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+func (s *PostgresStore) CreateGroupGrant(ctx context.Context, g *GroupGrant) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO group_grants (id, group_id, agent_id, tag_key, tag_value, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		g.ID, g.GroupID, g.AgentID, g.TagKey, g.TagValue, g.CreatedBy, g.CreatedAt,
+	)
+	return err
+}
+
+func (s *PostgresStore) ListGroupGrants(ctx context.Context, groupID string) ([]GroupGrant, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, group_id, agent_id, tag_key, tag_value, created_by, created_at
+		 FROM group_grants WHERE group_id = $1 ORDER BY created_at`, groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var grants []GroupGrant
+	for rows.Next() {
+		var g GroupGrant
+		if err := rows.Scan(&g.ID, &g.GroupID, &g.AgentID, &g.TagKey, &g.TagValue, &g.CreatedBy, &g.CreatedAt); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+func (s *PostgresStore) DeleteGroupGrant(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM group_grants WHERE id = $1", id)
+	return err
+}
+
+// --- Audit ---
+
+func (s *PostgresStore) LogAuditEvent(ctx context.Context, event *AuditEvent) error {
+	detail := ""
+	if event.Detail != nil {
+		detail = string(event.Detail)
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO audit_events (id, org_id, action, user_id, runtime_id, session_id, agent_id, detail, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		event.ID, event.OrgID, event.Action, event.UserID, event.RuntimeID, event.SessionID, event.AgentID, detail, event.CreatedAt,
+	)
+	return err
+}
+
+func (s *PostgresStore) ListAuditEvents(ctx context.Context, orgID string, limit, offset int) ([]AuditEvent, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, org_id, action, user_id, runtime_id, session_id, agent_id, detail, created_at
+		 FROM audit_events WHERE org_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`,
+		orgID, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var events []AuditEvent
+	for rows.Next() {
+		var e AuditEvent
+		var detail string
+		if err := rows.Scan(&e.ID, &e.OrgID, &e.Action, &e.UserID, &e.RuntimeID, &e.SessionID, &e.AgentID, &detail, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if detail != "" {
+			e.Detail = json.RawMessage(detail)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
 }
 
 func (s *PostgresStore) ListAuditEventsFiltered(ctx context.Context, orgID string, filter AuditFilter) ([]AuditEvent, error) {
@@ -890,13 +1453,13 @@ func (s *PostgresStore) ListAuditEventsFiltered(ctx context.Context, orgID strin
 
 func (s *PostgresStore) ListAllSessions(ctx context.Context, orgID string) ([]Session, error) {
 	rows, err := s.db.QueryContext(ctx,
-		`SELECT s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from,
+		`SELECT s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from, s.fork_seq, s.fork_mode, s.branch,
 		        s.created_at, s.updated_at, COALESCE(a.name, '') as agent_name, COUNT(m.id) as message_count
 		 FROM sessions s
 		 LEFT JOIN agents a ON s.agent_id = a.id
 		 LEFT JOIN messages m ON m.session_id = s.id
 		 WHERE s.org_id = $1
-		 GROUP BY s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from,
+		 GROUP BY s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from, s.fork_seq, s.fork_mode, s.branch,
 		          s.created_at, s.updated_at, a.name
 		 ORDER BY s.updated_at DESC`,
 		orgID,
@@ -910,7 +1473,7 @@ func (s *PostgresStore) ListAllSessions(ctx context.Context, orgID string) ([]Se
 	for rows.Next() {
 		var sess Session
 		if err := rows.Scan(&sess.ID, &sess.OrgID, &sess.UserID, &sess.AgentID, &sess.RuntimeID, &sess.Profile,
-			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.CreatedAt, &sess.UpdatedAt, &sess.AgentName, &sess.MessageCount); err != nil {
+			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.ForkSeq, &sess.ForkMode, &sess.Branch, &sess.CreatedAt, &sess.UpdatedAt, &sess.AgentName, &sess.MessageCount); err != nil {
 			return nil, err
 		}
 		sessions = append(sessions, sess)
@@ -1106,6 +1669,122 @@ func (s *PostgresStore) UpdateRuntimeTokenLastUsed(ctx context.Context, id strin
 	return err
 }
 
+// --- Personal access tokens ---
+
+func (s *PostgresStore) CreateUserToken(ctx context.Context, t *UserToken) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO user_tokens (id, org_id, user_id, name, token_hash, scopes, expires_at, created_at, last_used_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		t.ID, t.OrgID, t.UserID, t.Name, t.TokenHash, t.Scopes, t.ExpiresAt, t.CreatedAt, t.LastUsedAt,
+	)
+	return err
+}
+
+func (s *PostgresStore) GetUserTokenByHash(ctx context.Context, tokenHash string) (*UserToken, error) {
+	var t UserToken
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, org_id, user_id, name, token_hash, scopes, expires_at, created_at, last_used_at
+		 FROM user_tokens WHERE token_hash = $1`, tokenHash,
+	).Scan(&t.ID, &t.OrgID, &t.UserID, &t.Name, &t.TokenHash, &t.Scopes, &t.ExpiresAt, &t.CreatedAt, &t.LastUsedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &t, err
+}
+
+func (s *PostgresStore) ListUserTokens(ctx context.Context, userID string) ([]UserToken, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, org_id, user_id, name, token_hash, scopes, expires_at, created_at, last_used_at
+		 FROM user_tokens WHERE user_id = $1 ORDER BY created_at DESC`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var tokens []UserToken
+	for rows.Next() {
+		var t UserToken
+		if err := rows.Scan(&t.ID, &t.OrgID, &t.UserID, &t.Name, &t.TokenHash, &t.Scopes, &t.ExpiresAt, &t.CreatedAt, &t.LastUsedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *PostgresStore) DeleteUserToken(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM user_tokens WHERE id = $1", id)
+	return err
+}
+
+func (s *PostgresStore) UpdateUserTokenLastUsed(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE user_tokens SET last_used_at = $1 WHERE id = $2",
+		time.Now(), id,
+	)
+	return err
+}
+
+// --- Two-factor authentication ---
+
+func (s *PostgresStore) GetUserMFA(ctx context.Context, userID string) (*UserMFA, error) {
+	var m UserMFA
+	err := s.db.QueryRowContext(ctx,
+		`SELECT user_id, secret, enabled, recovery_codes, last_step, created_at, enabled_at
+		 FROM user_mfa WHERE user_id = $1`, userID,
+	).Scan(&m.UserID, &m.Secret, &m.Enabled, &m.RecoveryCodes, &m.LastStep, &m.CreatedAt, &m.EnabledAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &m, err
+}
+
+func (s *PostgresStore) UpsertUserMFA(ctx context.Context, m *UserMFA) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO user_mfa (user_id, secret, enabled, recovery_codes, last_step, created_at, enabled_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, enabled = excluded.enabled,
+		   recovery_codes = excluded.recovery_codes, last_step = excluded.last_step,
+		   created_at = excluded.created_at, enabled_at = excluded.enabled_at`,
+		m.UserID, m.Secret, m.Enabled, m.RecoveryCodes, m.LastStep, m.CreatedAt, m.EnabledAt,
+	)
+	return err
+}
+
+func (s *PostgresStore) DeleteUserMFA(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM user_mfa WHERE user_id = $1", userID)
+	return err
+}
+
+// --- Password resets ---
+
+func (s *PostgresStore) CreatePasswordReset(ctx context.Context, r *PasswordReset) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO password_resets (id, org_id, user_id, token_hash, created_by, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		r.ID, r.OrgID, r.UserID, r.TokenHash, r.CreatedBy, r.ExpiresAt, r.CreatedAt,
+	)
+	return err
+}
+
+func (s *PostgresStore) GetPasswordResetByHash(ctx context.Context, tokenHash string) (*PasswordReset, error) {
+	var r PasswordReset
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, org_id, user_id, token_hash, created_by, expires_at, created_at
+		 FROM password_resets WHERE token_hash = $1`, tokenHash,
+	).Scan(&r.ID, &r.OrgID, &r.UserID, &r.TokenHash, &r.CreatedBy, &r.ExpiresAt, &r.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &r, err
+}
+
+func (s *PostgresStore) DeletePasswordResets(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM password_resets WHERE user_id = $1", userID)
+	return err
+}
+
 // --- Subscriptions (billing) ---
 
 func (s *PostgresStore) GetSubscription(ctx context.Context, orgID string) (*Subscription, error) {
@@ -1165,3 +1844,436 @@ func (s *PostgresStore) CountOnlineRuntimesByOrg(ctx context.Context, orgID stri
 	).Scan(&count)
 	return count, err
 }
+
+// --- Webhooks ---
+
+func (s *PostgresStore) CreateWebhook(ctx context.Context, wh *Webhook) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO webhooks (id, org_id, url, secret, events, description, enabled, created_by, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		wh.ID, wh.OrgID, wh.URL, wh.Secret, wh.Events, wh.Description, wh.Enabled, wh.CreatedBy, wh.CreatedAt, wh.UpdatedAt,
+	)
+	return err
+}
+
+func (s *PostgresStore) GetWebhook(ctx context.Context, id string) (*Webhook, error) {
+	var wh Webhook
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, org_id, url, secret, events, description, enabled, created_by, created_at, updated_at
+		 FROM webhooks WHERE id = $1`, id,
+	).Scan(&wh.ID, &wh.OrgID, &wh.URL, &wh.Secret, &wh.Events, &wh.Description, &wh.Enabled, &wh.CreatedBy, &wh.CreatedAt, &wh.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &wh, err
+}
+
+func (s *PostgresStore) ListWebhooks(ctx context.Context, orgID string) ([]Webhook, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, org_id, url, secret, events, description, enabled, created_by, created_at, updated_at
+		 FROM webhooks WHERE org_id = $1 ORDER BY created_at`, orgID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var webhooks []Webhook
+	for rows.Next() {
+		var wh Webhook
+		if err := rows.Scan(&wh.ID, &wh.OrgID, &wh.URL, &wh.Secret, &wh.Events, &wh.Description, &wh.Enabled, &wh.CreatedBy, &wh.CreatedAt, &wh.UpdatedAt); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, wh)
+	}
+	return webhooks, rows.Err()
+}
+
+func (s *PostgresStore) UpdateWebhook(ctx context.Context, wh *Webhook) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE webhooks SET url = $1, secret = $2, events = $3, description = $4, enabled = $5, updated_at = $6
+		 WHERE id = $7`,
+		wh.URL, wh.Secret, wh.Events, wh.Description, wh.Enabled, wh.UpdatedAt, wh.ID,
+	)
+	return err
+}
+
+func (s *PostgresStore) DeleteWebhook(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE webhook_id = $1", id); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1", id)
+	return err
+}
+
+func (s *PostgresStore) CreateWebhookDelivery(ctx context.Context, d *WebhookDelivery) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (id, webhook_id, org_id, event_id, event_type, payload, status, attempts,
+		                                 response_code, last_error, next_attempt_at, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		d.ID, d.WebhookID, d.OrgID, d.EventID, d.EventType, d.Payload, d.Status, d.Attempts,
+		d.ResponseCode, d.LastError, d.NextAttemptAt, d.CreatedAt, d.UpdatedAt,
+	)
+	return err
+}
+
+func (s *PostgresStore) UpdateWebhookDelivery(ctx context.Context, d *WebhookDelivery) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET status = $1, attempts = $2, response_code = $3, last_error = $4,
+		        next_attempt_at = $5, updated_at = $6
+		 WHERE id = $7`,
+		d.Status, d.Attempts, d.ResponseCode, d.LastError, d.NextAttemptAt, d.UpdatedAt, d.ID,
+	)
+	return err
+}
+
+func (s *PostgresStore) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	return s.queryWebhookDeliveries(ctx,
+		`SELECT id, webhook_id, org_id, event_id, event_type, payload, status, attempts, response_code, last_error,
+		        next_attempt_at, created_at, updated_at
+		 FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC LIMIT $2`,
+		webhookID, limit,
+	)
+}
+
+func (s *PostgresStore) ListDueWebhookDeliveries(ctx context.Context, before time.Time, limit int) ([]WebhookDelivery, error) {
+	return s.queryWebhookDeliveries(ctx,
+		`SELECT id, webhook_id, org_id, event_id, event_type, payload, status, attempts, response_code, last_error,
+		        next_attempt_at, created_at, updated_at
+		 FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= $1 ORDER BY next_attempt_at LIMIT $2`,
+		before, limit,
+	)
+}
+
+func (s *PostgresStore) queryWebhookDeliveries(ctx context.Context, query string, args ...any) ([]WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.OrgID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+			&d.ResponseCode, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (s *PostgresStore) PurgeOldWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx,
+		"DELETE FROM webhook_deliveries WHERE created_at < $1 AND status <> 'pending'", before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// --- Permission policies ---
+
+func (s *PostgresStore) CreatePermissionPolicy(ctx context.Context, p *PermissionPolicy) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO permission_policies (id, org_id, agent_id, user_id, tool, pattern, exact, effect, description, created_by, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		p.ID, p.OrgID, p.AgentID, p.UserID, p.Tool, p.Pattern, p.Exact, p.Effect, p.Description, p.CreatedBy, p.CreatedAt, p.UpdatedAt,
+	)
+	return err
+}
+
+func (s *PostgresStore) GetPermissionPolicy(ctx context.Context, id string) (*PermissionPolicy, error) {
+	var p PermissionPolicy
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, org_id, agent_id, user_id, tool, pattern, exact, effect, description, created_by, created_at, updated_at
+		 FROM permission_policies WHERE id = $1`, id,
+	).Scan(&p.ID, &p.OrgID, &p.AgentID, &p.UserID, &p.Tool, &p.Pattern, &p.Exact, &p.Effect, &p.Description, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &p, err
+}
+
+func (s *PostgresStore) ListPermissionPolicies(ctx context.Context, orgID string) ([]PermissionPolicy, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, org_id, agent_id, user_id, tool, pattern, exact, effect, description, created_by, created_at, updated_at
+		 FROM permission_policies WHERE org_id = $1 ORDER BY created_at`, orgID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var policies []PermissionPolicy
+	for rows.Next() {
+		var p PermissionPolicy
+		if err := rows.Scan(&p.ID, &p.OrgID, &p.AgentID, &p.UserID, &p.Tool, &p.Pattern, &p.Exact, &p.Effect, &p.Description, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+func (s *PostgresStore) UpdatePermissionPolicy(ctx context.Context, p *PermissionPolicy) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE permission_policies SET agent_id = $1, user_id = $2, tool = $3, pattern = $4, exact = $5, effect = $6, description = $7, updated_at = $8
+		 WHERE id = $9`,
+		p.AgentID, p.UserID, p.Tool, p.Pattern, p.Exact, p.Effect, p.Description, p.UpdatedAt, p.ID,
+	)
+	return err
+}
+
+func (s *PostgresStore) DeletePermissionPolicy(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM permission_policies WHERE id = $1", id)
+	return err
+}
+
+// --- Session members ---
+
+func (s *PostgresStore) AddSessionMember(ctx context.Context, m *SessionMember) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO session_members (session_id, user_id, role, added_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (session_id, user_id) DO UPDATE SET role = excluded.role, added_by = excluded.added_by`,
+		m.SessionID, m.UserID, m.Role, m.AddedBy, m.CreatedAt,
+	)
+	return err
+}
+
+func (s *PostgresStore) GetSessionMember(ctx context.Context, sessionID, userID string) (*SessionMember, error) {
+	var m SessionMember
+	err := s.db.QueryRowContext(ctx,
+		`SELECT session_id, user_id, role, added_by, created_at
+		 FROM session_members WHERE session_id = $1 AND user_id = $2`, sessionID, userID,
+	).Scan(&m.SessionID, &m.UserID, &m.Role, &m.AddedBy, &m.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &m, err
+}
+
+func (s *PostgresStore) ListSessionMembers(ctx context.Context, sessionID string) ([]SessionMember, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT sm.session_id, sm.user_id, COALESCE(u.username, ''), sm.role, sm.added_by, sm.created_at
+		 FROM session_members sm
+		 LEFT JOIN users u ON u.id = sm.user_id
+		 WHERE sm.session_id = $1 ORDER BY sm.created_at`, sessionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var members []SessionMember
+	for rows.Next() {
+		var m SessionMember
+		if err := rows.Scan(&m.SessionID, &m.UserID, &m.Username, &m.Role, &m.AddedBy, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (s *PostgresStore) RemoveSessionMember(ctx context.Context, sessionID, userID string) error {
+	_, err := s.db.ExecContext(ctx,
+		"DELETE FROM session_members WHERE session_id = $1 AND user_id = $2", sessionID, userID,
+	)
+	return err
+}
+
+func (s *PostgresStore) ListSessionsSharedWithUser(ctx context.Context, userID string) ([]Session, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from, s.fork_seq, s.fork_mode, s.branch,
+		        s.created_at, s.updated_at, COALESCE(a.name, '') as agent_name, COUNT(m.id) as message_count, sm.role
+		 FROM session_members sm
+		 JOIN sessions s ON s.id = sm.session_id
+		 LEFT JOIN agents a ON s.agent_id = a.id
+		 LEFT JOIN messages m ON m.session_id = s.id
+		 WHERE sm.user_id = $1
+		 GROUP BY s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from, s.fork_seq, s.fork_mode, s.branch,
+		          s.created_at, s.updated_at, a.name, sm.role
+		 ORDER BY s.updated_at DESC`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var sessions []Session
+	for rows.Next() {
+		var sess Session
+		if err := rows.Scan(&sess.ID, &sess.OrgID, &sess.UserID, &sess.AgentID, &sess.RuntimeID, &sess.Profile,
+			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.ForkSeq, &sess.ForkMode, &sess.Branch, &sess.CreatedAt, &sess.UpdatedAt,
+			&sess.AgentName, &sess.MessageCount, &sess.MemberRole); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, rows.Err()
+}
+
+// --- Session forks ---
+
+// CopyMessages copies the messages of one session with seq <= uptoSeq into
+// another, keeping their seq and timestamps. Copies get derived IDs so they
+// stay unique.
+func (s *PostgresStore) CopyMessages(ctx context.Context, fromSessionID, toSessionID string, uptoSeq int64) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO messages (id, session_id, seq, direction, channel, content, author_id, created_at)
+		 SELECT $1::text || ':' || seq::text, $2::text, seq, direction, channel, content, author_id, created_at
+		 FROM messages WHERE session_id = $3 AND seq <= $4 ORDER BY seq`,
+		toSessionID, toSessionID, fromSessionID, uptoSeq,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *PostgresStore) ListChildSessions(ctx context.Context, parentID string) ([]Session, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, fork_seq, fork_mode, branch, created_at, updated_at
+		 FROM sessions WHERE resumed_from = $1 ORDER BY created_at`, parentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var sessions []Session
+	for rows.Next() {
+		var sess Session
+		if err := rows.Scan(&sess.ID, &sess.OrgID, &sess.UserID, &sess.AgentID, &sess.RuntimeID, &sess.Profile,
+			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.ForkSeq, &sess.ForkMode, &sess.Branch, &sess.CreatedAt, &sess.UpdatedAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, rows.Err()
+}
+
+// --- Schedules ---
+
+func (s *PostgresStore) CreateSchedule(ctx context.Context, sc *Schedule) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO schedules (id, org_id, user_id, agent_id, name, cron, prompt, prompt_profile, enabled, next_run_at, last_run_at, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		sc.ID, sc.OrgID, sc.UserID, sc.AgentID, sc.Name, sc.Cron, sc.Prompt, sc.PromptProfile, sc.Enabled,
+		sc.NextRunAt, sc.LastRunAt, sc.CreatedAt, sc.UpdatedAt,
+	)
+	return err
+}
+
+func (s *PostgresStore) GetSchedule(ctx context.Context, id string) (*Schedule, error) {
+	var sc Schedule
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, org_id, user_id, agent_id, name, cron, prompt, prompt_profile, enabled, next_run_at, last_run_at, created_at, updated_at
+		 FROM schedules WHERE id = $1`, id,
+	).Scan(&sc.ID, &sc.OrgID, &sc.UserID, &sc.AgentID, &sc.Name, &sc.Cron, &sc.Prompt, &sc.PromptProfile, &sc.Enabled, &sc.NextRunAt, &sc.LastRunAt, &sc.CreatedAt, &sc.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &sc, err
+}
+
+func (s *PostgresStore) ListSchedules(ctx context.Context, orgID string) ([]Schedule, error) {
+	return s.querySchedules(ctx,
+		`SELECT id, org_id, user_id, agent_id, name, cron, prompt, prompt_profile, enabled, next_run_at, last_run_at, created_at, updated_at
+		 FROM schedules WHERE org_id = $1 ORDER BY created_at`, orgID,
+	)
+}
+
+func (s *PostgresStore) ListDueSchedules(ctx context.Context, before time.Time) ([]Schedule, error) {
+	return s.querySchedules(ctx,
+		`SELECT id, org_id, user_id, agent_id, name, cron, prompt, prompt_profile, enabled, next_run_at, last_run_at, created_at, updated_at
+		 FROM schedules WHERE enabled = TRUE AND next_run_at <= $1 ORDER BY next_run_at`, before,
+	)
+}
+
+func (s *PostgresStore) querySchedules(ctx context.Context, query string, args ...any) ([]Schedule, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var schedules []Schedule
+	for rows.Next() {
+		var sc Schedule
+		if err := rows.Scan(&sc.ID, &sc.OrgID, &sc.UserID, &sc.AgentID, &sc.Name, &sc.Cron, &sc.Prompt, &sc.PromptProfile, &sc.Enabled, &sc.NextRunAt, &sc.LastRunAt, &sc.CreatedAt, &sc.UpdatedAt); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, sc)
+	}
+	return schedules, rows.Err()
+}
+
+func (s *PostgresStore) UpdateSchedule(ctx context.Context, sc *Schedule) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE schedules SET agent_id = $1, name = $2, cron = $3, prompt = $4, prompt_profile = $5, enabled = $6,
+		        next_run_at = $7, last_run_at = $8, updated_at = $9
+		 WHERE id = $10`,
+		sc.AgentID, sc.Name, sc.Cron, sc.Prompt, sc.PromptProfile, sc.Enabled, sc.NextRunAt, sc.LastRunAt, sc.UpdatedAt, sc.ID,
+	)
+	return err
+}
+
+func (s *PostgresStore) DeleteSchedule(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM schedule_runs WHERE schedule_id = $1", id); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, "DELETE FROM schedules WHERE id = $1", id)
+	return err
+}
+
+func (s *PostgresStore) CreateScheduleRun(ctx context.Context, run *ScheduleRun) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO schedule_runs (id, schedule_id, org_id, session_id, status, result, error, scheduled_for, started_at, finished_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		run.ID, run.ScheduleID, run.OrgID, run.SessionID, run.Status, run.Result, run.Error,
+		run.ScheduledFor, run.StartedAt, run.FinishedAt,
+	)
+	return err
+}
+
+func (s *PostgresStore) UpdateScheduleRun(ctx context.Context, run *ScheduleRun) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE schedule_runs SET session_id = $1, status = $2, result = $3, error = $4, finished_at = $5 WHERE id = $6`,
+		run.SessionID, run.Status, run.Result, run.Error, run.FinishedAt, run.ID,
+	)
+	return err
+}
+
+func (s *PostgresStore) ListScheduleRuns(ctx context.Context, scheduleID string, limit int) ([]ScheduleRun, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, schedule_id, org_id, session_id, status, result, error, scheduled_for, started_at, finished_at
+		 FROM schedule_runs WHERE schedule_id = $1 ORDER BY started_at DESC LIMIT $2`,
+		scheduleID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var runs []ScheduleRun
+	for rows.Next() {
+		var run ScheduleRun
+		if err := rows.Scan(&run.ID, &run.ScheduleID, &run.OrgID, &run.SessionID, &run.Status, &run.Result, &run.Error,
+			&run.ScheduledFor, &run.StartedAt, &run.FinishedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
@@ -126,7 +126,23 @@ func TestPostgresFullFlow(t *testing.T) {
 		t.Errorf("message_count = %d, want 2", sessions[0].MessageCount)
 	}
 
-	// 7. Agent config override (was failing with wrong column name)
+	// 7. Full-text search is scoped by org and user
+	results, err := s.SearchMessages(ctx, SearchFilter{Query: "hello", OrgID: orgID, UserID: userExternalID})
+	if err != nil {
+		t.Fatalf("SearchMessages: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("got %d search results, want 2", len(results))
+	}
+	results, err = s.SearchMessages(ctx, SearchFilter{Query: "hello", OrgID: orgID, UserID: "someone-else"})
+	if err != nil {
+		t.Fatalf("SearchMessages(other user): %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("got %d search results for other user, want 0", len(results))
+	}
+
+	// 8. Agent config override (was failing with wrong column name)
 	override, err := s.GetAgentConfigOverride(ctx, agentID)
 	if err != nil {
 		t.Fatalf("GetAgentConfigOverride: %v", err)
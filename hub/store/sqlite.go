@@ -185,6 +185,15 @@ func (s *SQLiteStore) migrate() error {
 		{"agents", "security", "TEXT NOT NULL DEFAULT '{}'"},
 		{"sessions", "resumed_from", "TEXT NOT NULL DEFAULT ''"},
 		{"sessions", "prompt_profile", "TEXT NOT NULL DEFAULT 'standard'"},
+		{"sessions", "fork_seq", "INTEGER NOT NULL DEFAULT 0"},
+		{"sessions", "fork_mode", "TEXT NOT NULL DEFAULT ''"},
+		{"messages", "author_id", "TEXT NOT NULL DEFAULT ''"},
+		{"agents", "sandbox", "TEXT NOT NULL DEFAULT ''"},
+		{"sessions", "branch", "TEXT NOT NULL DEFAULT ''"},
+		{"organizations", "require_admin_mfa", "INTEGER NOT NULL DEFAULT 0"},
+		{"users", "disabled", "INTEGER NOT NULL DEFAULT 0"},
+		{"users", "password_changed_at", "DATETIME"},
+		{"users", "deleted_at", "DATETIME"},
 	}
 	for _, cm := range columnMigrations {
 		if err := s.addColumnIfNotExists(cm.table, cm.column, cm.definition); err != nil {
@@ -282,6 +291,254 @@ func (s *SQLiteStore) migrate() error {
 		return fmt.Errorf("add column organizations.plan: %w", err)
 	}
 
+	// Full-text search over message content. messages_fts is an external-content
+	// FTS5 table kept in sync with messages by triggers, so AppendMessage and the
+	// retention purge index and unindex rows in the same transaction.
+	ftsExisted := tableExists(s.db, "messages_fts")
+	ftsMigrations := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+			content, content='messages', content_rowid='rowid', tokenize='porter unicode61'
+		)`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_ai AFTER INSERT ON messages BEGIN
+			INSERT INTO messages_fts(rowid, content) VALUES (new.rowid, new.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_ad AFTER DELETE ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_au AFTER UPDATE OF content ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+			INSERT INTO messages_fts(rowid, content) VALUES (new.rowid, new.content);
+		END`,
+	}
+	for _, m := range ftsMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+	// Index transcripts written before the FTS table existed.
+	if !ftsExisted {
+		if _, err := s.db.Exec(`INSERT INTO messages_fts(messages_fts) VALUES ('rebuild')`); err != nil {
+			return fmt.Errorf("rebuild messages_fts: %w", err)
+		}
+	}
+
+	// Outbound webhooks and their delivery log.
+	webhookMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS webhooks (
+			id TEXT PRIMARY KEY,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			events TEXT NOT NULL DEFAULT '[]',
+			description TEXT NOT NULL DEFAULT '',
+			enabled INTEGER NOT NULL DEFAULT 1,
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhooks_org_id ON webhooks(org_id)`,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id TEXT PRIMARY KEY,
+			webhook_id TEXT NOT NULL,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			event_id TEXT NOT NULL,
+			event_type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			response_code INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at)`,
+	}
+	for _, m := range webhookMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+
+	// Persistent permission policy rules.
+	policyMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS permission_policies (
+			id TEXT PRIMARY KEY,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			agent_id TEXT NOT NULL DEFAULT '',
+			user_id TEXT NOT NULL DEFAULT '',
+			tool TEXT NOT NULL DEFAULT '',
+			pattern TEXT NOT NULL DEFAULT '',
+			effect TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_permission_policies_org_id ON permission_policies(org_id)`,
+	}
+	for _, m := range policyMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+	if err := s.addColumnIfNotExists("permission_policies", "exact", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("add column permission_policies.exact: %w", err)
+	}
+
+	// Users a session is shared with, beyond its owner.
+	memberMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS session_members (
+			session_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			role TEXT NOT NULL,
+			added_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (session_id, user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_session_members_user_id ON session_members(user_id)`,
+	}
+	for _, m := range memberMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+
+	// Scheduled agent runs and their history.
+	scheduleMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS schedules (
+			id TEXT PRIMARY KEY,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			user_id TEXT NOT NULL,
+			agent_id TEXT NOT NULL,
+			name TEXT NOT NULL DEFAULT '',
+			cron TEXT NOT NULL,
+			prompt TEXT NOT NULL,
+			prompt_profile TEXT NOT NULL DEFAULT '',
+			enabled INTEGER NOT NULL DEFAULT 1,
+			next_run_at DATETIME NOT NULL,
+			last_run_at DATETIME,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_schedules_org_id ON schedules(org_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_schedules_due ON schedules(enabled, next_run_at)`,
+		`CREATE TABLE IF NOT EXISTS schedule_runs (
+			id TEXT PRIMARY KEY,
+			schedule_id TEXT NOT NULL,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			session_id TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL,
+			result TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT '',
+			scheduled_for DATETIME NOT NULL,
+			started_at DATETIME NOT NULL,
+			finished_at DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_schedule_runs_schedule ON schedule_runs(schedule_id, started_at)`,
+	}
+	for _, m := range scheduleMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+
+	// Personal access tokens.
+	userTokenMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS user_tokens (
+			id TEXT PRIMARY KEY,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			token_hash TEXT NOT NULL,
+			scopes TEXT NOT NULL DEFAULT '[]',
+			expires_at DATETIME NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_used_at DATETIME
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_user_tokens_hash ON user_tokens(token_hash)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_tokens_user_id ON user_tokens(user_id)`,
+	}
+	for _, m := range userTokenMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+
+	// TOTP second factors.
+	mfaMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS user_mfa (
+			user_id TEXT PRIMARY KEY,
+			secret TEXT NOT NULL,
+			enabled INTEGER NOT NULL DEFAULT 0,
+			recovery_codes TEXT NOT NULL DEFAULT '[]',
+			last_step INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			enabled_at DATETIME
+		)`,
+	}
+	for _, m := range mfaMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+
+	// Admin-issued password reset links.
+	passwordResetMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS password_resets (
+			id TEXT PRIMARY KEY,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			user_id TEXT NOT NULL,
+			token_hash TEXT NOT NULL,
+			created_by TEXT NOT NULL DEFAULT '',
+			expires_at DATETIME NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_password_resets_hash ON password_resets(token_hash)`,
+		`CREATE INDEX IF NOT EXISTS idx_password_resets_user_id ON password_resets(user_id)`,
+	}
+	for _, m := range passwordResetMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+
+	groupMigrations := []string{
+		`CREATE TABLE IF NOT EXISTS user_groups (
+			id TEXT PRIMARY KEY,
+			org_id TEXT NOT NULL DEFAULT 'default',
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_user_groups_org_name ON user_groups(org_id, name)`,
+		`CREATE TABLE IF NOT EXISTS group_members (
+			group_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			added_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (group_id, user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_group_members_user_id ON group_members(user_id)`,
+		`CREATE TABLE IF NOT EXISTS group_grants (
+			id TEXT PRIMARY KEY,
+			group_id TEXT NOT NULL,
+			agent_id TEXT NOT NULL DEFAULT '',
+			tag_key TEXT NOT NULL DEFAULT '',
+			tag_value TEXT NOT NULL DEFAULT '',
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_group_grants_unique ON group_grants(group_id, agent_id, tag_key, tag_value)`,
+	}
+	for _, m := range groupMigrations {
+		if _, err := s.db.Exec(m); err != nil {
+			return fmt.Errorf("migration failed: %w\n  SQL: %s", err, m)
+		}
+	}
+
 	// Phase: rename endpoint -> agent (migration for existing databases)
 	if tableExists(s.db, "endpoints") {
 		renameStmts := []string{
@@ -407,6 +664,9 @@ func (s *SQLiteStore) normalizeExternalUserIDs() error {
 		if _, err := tx.Exec(`UPDATE runtime_tokens SET created_by = ? WHERE created_by = ?`, m.externalID, m.oldID); err != nil {
 			return err
 		}
+		if _, err := tx.Exec(`UPDATE user_tokens SET user_id = ? WHERE user_id = ?`, m.externalID, m.oldID); err != nil {
+			return err
+		}
 
 		var targetExists int
 		if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE id = ?`, m.externalID).Scan(&targetExists); err != nil {
@@ -453,14 +713,22 @@ func (s *SQLiteStore) CreateOrganization(ctx context.Context, org *Organization)
 func (s *SQLiteStore) GetOrganization(ctx context.Context, id string) (*Organization, error) {
 	var org Organization
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, name, created_at FROM organizations WHERE id = ?", id,
-	).Scan(&org.ID, &org.Name, &org.CreatedAt)
+		"SELECT id, name, require_admin_mfa, created_at FROM organizations WHERE id = ?", id,
+	).Scan(&org.ID, &org.Name, &org.RequireAdminMFA, &org.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	return &org, err
 }
 
+func (s *SQLiteStore) UpdateOrganization(ctx context.Context, org *Organization) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE organizations SET name = ?, require_admin_mfa = ? WHERE id = ?",
+		org.Name, org.RequireAdminMFA, org.ID,
+	)
+	return err
+}
+
 // --- Users ---
 
 func (s *SQLiteStore) CreateUser(ctx context.Context, user *User) error {
@@ -474,9 +742,9 @@ func (s *SQLiteStore) CreateUser(ctx context.Context, user *User) error {
 func (s *SQLiteStore) GetUser(ctx context.Context, orgID, username string) (*User, error) {
 	var u User
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, org_id, external_id, username, password_hash, role, created_at FROM users WHERE org_id = ? AND username = ?",
+		"SELECT id, org_id, external_id, username, password_hash, role, created_at, disabled, password_changed_at, deleted_at FROM users WHERE org_id = ? AND username = ?",
 		orgID, username,
-	).Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt)
+	).Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.Disabled, &u.PasswordChangedAt, &u.DeletedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -486,8 +754,8 @@ func (s *SQLiteStore) GetUser(ctx context.Context, orgID, username string) (*Use
 func (s *SQLiteStore) GetUserByID(ctx context.Context, id string) (*User, error) {
 	var u User
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, org_id, external_id, username, password_hash, role, created_at FROM users WHERE id = ?", id,
-	).Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt)
+		"SELECT id, org_id, external_id, username, password_hash, role, created_at, disabled, password_changed_at, deleted_at FROM users WHERE id = ?", id,
+	).Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.Disabled, &u.PasswordChangedAt, &u.DeletedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -497,9 +765,9 @@ func (s *SQLiteStore) GetUserByID(ctx context.Context, id string) (*User, error)
 func (s *SQLiteStore) GetUserByExternalID(ctx context.Context, externalID string) (*User, error) {
 	var u User
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, org_id, external_id, username, password_hash, role, created_at FROM users WHERE external_id = ?",
+		"SELECT id, org_id, external_id, username, password_hash, role, created_at, disabled, password_changed_at, deleted_at FROM users WHERE external_id = ?",
 		externalID,
-	).Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt)
+	).Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.Disabled, &u.PasswordChangedAt, &u.DeletedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -508,7 +776,7 @@ func (s *SQLiteStore) GetUserByExternalID(ctx context.Context, externalID string
 
 func (s *SQLiteStore) ListUsers(ctx context.Context, orgID string) ([]User, error) {
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, org_id, external_id, username, role, created_at FROM users WHERE org_id = ? ORDER BY created_at",
+		"SELECT id, org_id, external_id, username, role, created_at, disabled, password_changed_at FROM users WHERE org_id = ? AND deleted_at IS NULL ORDER BY created_at",
 		orgID,
 	)
 	if err != nil {
@@ -519,7 +787,7 @@ func (s *SQLiteStore) ListUsers(ctx context.Context, orgID string) ([]User, erro
 	var users []User
 	for rows.Next() {
 		var u User
-		if err := rows.Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.Role, &u.CreatedAt); err != nil {
+		if err := rows.Scan(&u.ID, &u.OrgID, &u.ExternalID, &u.Username, &u.Role, &u.CreatedAt, &u.Disabled, &u.PasswordChangedAt); err != nil {
 			return nil, err
 		}
 		users = append(users, u)
@@ -527,6 +795,49 @@ func (s *SQLiteStore) ListUsers(ctx context.Context, orgID string) ([]User, erro
 	return users, rows.Err()
 }
 
+// UpdateUser saves a user's role, password and disabled state.
+func (s *SQLiteStore) UpdateUser(ctx context.Context, user *User) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE users SET role = ?, password_hash = ?, disabled = ?, password_changed_at = ? WHERE id = ?",
+		user.Role, user.PasswordHash, user.Disabled, user.PasswordChangedAt, user.ID,
+	)
+	return err
+}
+
+// DeleteUser removes a user's credentials, agent grants, session memberships,
+// personal permission rules and pending password resets, and turns off their
+// schedules. The row itself stays, renamed and marked deleted, because
+// sessions and audit events keep referring to it.
+func (s *SQLiteStore) DeleteUser(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE users SET username = 'deleted:' || id, password_hash = '', disabled = ?, deleted_at = ? WHERE id = ?",
+		true, time.Now(), id,
+	); err != nil {
+		return err
+	}
+	for _, q := range []string{
+		"DELETE FROM user_tokens WHERE user_id = ?",
+		"DELETE FROM user_mfa WHERE user_id = ?",
+		"DELETE FROM password_resets WHERE user_id = ?",
+		"DELETE FROM agent_permissions WHERE user_id = ?",
+		"DELETE FROM group_members WHERE user_id = ?",
+		"DELETE FROM session_members WHERE user_id = ?",
+		"DELETE FROM permission_policies WHERE user_id = ?",
+		"UPDATE schedules SET enabled = 0 WHERE user_id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 // --- Runtimes ---
 
 func (s *SQLiteStore) UpsertRuntime(ctx context.Context, rt *Runtime) error {
@@ -582,9 +893,9 @@ func (s *SQLiteStore) SetRuntimeOnline(ctx context.Context, id string, online bo
 
 func (s *SQLiteStore) UpsertAgent(ctx context.Context, agent *Agent) error {
 	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO agents (id, org_id, runtime_id, profile, name, tags, caps, security) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-		 ON CONFLICT(id) DO UPDATE SET org_id=excluded.org_id, runtime_id=excluded.runtime_id, profile=excluded.profile, name=excluded.name, tags=excluded.tags, caps=excluded.caps, security=excluded.security`,
-		agent.ID, agent.OrgID, agent.RuntimeID, agent.Profile, agent.Name, agent.Tags, agent.Caps, agent.Security,
+		`INSERT INTO agents (id, org_id, runtime_id, profile, name, tags, caps, security, sandbox) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(id) DO UPDATE SET org_id=excluded.org_id, runtime_id=excluded.runtime_id, profile=excluded.profile, name=excluded.name, tags=excluded.tags, caps=excluded.caps, security=excluded.security, sandbox=excluded.sandbox`,
+		agent.ID, agent.OrgID, agent.RuntimeID, agent.Profile, agent.Name, agent.Tags, agent.Caps, agent.Security, agent.Sandbox,
 	)
 	return err
 }
@@ -592,8 +903,8 @@ func (s *SQLiteStore) UpsertAgent(ctx context.Context, agent *Agent) error {
 func (s *SQLiteStore) GetAgent(ctx context.Context, id string) (*Agent, error) {
 	var agent Agent
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, org_id, runtime_id, profile, name, tags, caps, security FROM agents WHERE id = ?", id,
-	).Scan(&agent.ID, &agent.OrgID, &agent.RuntimeID, &agent.Profile, &agent.Name, &agent.Tags, &agent.Caps, &agent.Security)
+		"SELECT id, org_id, runtime_id, profile, name, tags, caps, security, sandbox FROM agents WHERE id = ?", id,
+	).Scan(&agent.ID, &agent.OrgID, &agent.RuntimeID, &agent.Profile, &agent.Name, &agent.Tags, &agent.Caps, &agent.Security, &agent.Sandbox)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -602,7 +913,7 @@ func (s *SQLiteStore) GetAgent(ctx context.Context, id string) (*Agent, error) {
 
 func (s *SQLiteStore) ListAgents(ctx context.Context, orgID string) ([]Agent, error) {
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, org_id, runtime_id, profile, name, tags, caps, security FROM agents WHERE org_id = ? ORDER BY name",
+		"SELECT id, org_id, runtime_id, profile, name, tags, caps, security, sandbox FROM agents WHERE org_id = ? ORDER BY name",
 		orgID,
 	)
 	if err != nil {
@@ -613,7 +924,7 @@ func (s *SQLiteStore) ListAgents(ctx context.Context, orgID string) ([]Agent, er
 	var agents []Agent
 	for rows.Next() {
 		var agent Agent
-		if err := rows.Scan(&agent.ID, &agent.OrgID, &agent.RuntimeID, &agent.Profile, &agent.Name, &agent.Tags, &agent.Caps, &agent.Security); err != nil {
+		if err := rows.Scan(&agent.ID, &agent.OrgID, &agent.RuntimeID, &agent.Profile, &agent.Name, &agent.Tags, &agent.Caps, &agent.Security, &agent.Sandbox); err != nil {
 			return nil, err
 		}
 		agents = append(agents, agent)
@@ -623,7 +934,7 @@ func (s *SQLiteStore) ListAgents(ctx context.Context, orgID string) ([]Agent, er
 
 func (s *SQLiteStore) ListAgentsByRuntime(ctx context.Context, runtimeID string) ([]Agent, error) {
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, org_id, runtime_id, profile, name, tags, caps, security FROM agents WHERE runtime_id = ? ORDER BY name",
+		"SELECT id, org_id, runtime_id, profile, name, tags, caps, security, sandbox FROM agents WHERE runtime_id = ? ORDER BY name",
 		runtimeID,
 	)
 	if err != nil {
@@ -634,7 +945,7 @@ func (s *SQLiteStore) ListAgentsByRuntime(ctx context.Context, runtimeID string)
 	var agents []Agent
 	for rows.Next() {
 		var agent Agent
-		if err := rows.Scan(&agent.ID, &agent.OrgID, &agent.RuntimeID, &agent.Profile, &agent.Name, &agent.Tags, &agent.Caps, &agent.Security); err != nil {
+		if err := rows.Scan(&agent.ID, &agent.OrgID, &agent.RuntimeID, &agent.Profile, &agent.Name, &agent.Tags, &agent.Caps, &agent.Security, &agent.Sandbox); err != nil {
 			return nil, err
 		}
 		agents = append(agents, agent)
@@ -652,10 +963,10 @@ func (s *SQLiteStore) DeleteAgentsByRuntime(ctx context.Context, runtimeID strin
 func (s *SQLiteStore) CreateSession(ctx context.Context, sess *Session) error {
 	return sqliteRetry(func() error {
 		_, err := s.db.ExecContext(ctx,
-			`INSERT INTO sessions (id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, created_at, updated_at)
-			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			`INSERT INTO sessions (id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, fork_seq, fork_mode, branch, created_at, updated_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 			sess.ID, sess.OrgID, sess.UserID, sess.AgentID, sess.RuntimeID, sess.Profile,
-			sess.PromptProfile, sess.State, sess.NativeHandle, sess.ResumedFrom, sess.CreatedAt, sess.UpdatedAt,
+			sess.PromptProfile, sess.State, sess.NativeHandle, sess.ResumedFrom, sess.ForkSeq, sess.ForkMode, sess.Branch, sess.CreatedAt, sess.UpdatedAt,
 		)
 		return err
 	})
@@ -664,10 +975,10 @@ func (s *SQLiteStore) CreateSession(ctx context.Context, sess *Session) error {
 func (s *SQLiteStore) GetSession(ctx context.Context, id string) (*Session, error) {
 	var sess Session
 	err := s.db.QueryRowContext(ctx,
-		`SELECT id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, created_at, updated_at
+		`SELECT id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, fork_seq, fork_mode, branch, created_at, updated_at
 		 FROM sessions WHERE id = ?`, id,
 	).Scan(&sess.ID, &sess.OrgID, &sess.UserID, &sess.AgentID, &sess.RuntimeID, &sess.Profile,
-		&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.CreatedAt, &sess.UpdatedAt)
+		&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.ForkSeq, &sess.ForkMode, &sess.Branch, &sess.CreatedAt, &sess.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -676,13 +987,13 @@ func (s *SQLiteStore) GetSession(ctx context.Context, id string) (*Session, erro
 
 func (s *SQLiteStore) ListSessionsByUser(ctx context.Context, userID string) ([]Session, error) {
 	rows, err := s.db.QueryContext(ctx,
-		`SELECT s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from,
+		`SELECT s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from, s.fork_seq, s.fork_mode, s.branch,
 		        s.created_at, s.updated_at, COALESCE(a.name, '') as agent_name, COUNT(m.id) as message_count
 		 FROM sessions s
 		 LEFT JOIN agents a ON s.agent_id = a.id
 		 LEFT JOIN messages m ON m.session_id = s.id
 		 WHERE s.user_id = ?
-		 GROUP BY s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from,
+		 GROUP BY s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from, s.fork_seq, s.fork_mode, s.branch,
 		          s.created_at, s.updated_at, a.name
 		 ORDER BY s.updated_at DESC`, userID,
 	)
@@ -695,7 +1006,7 @@ func (s *SQLiteStore) ListSessionsByUser(ctx context.Context, userID string) ([]
 	for rows.Next() {
 		var sess Session
 		if err := rows.Scan(&sess.ID, &sess.OrgID, &sess.UserID, &sess.AgentID, &sess.RuntimeID, &sess.Profile,
-			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.CreatedAt, &sess.UpdatedAt, &sess.AgentName, &sess.MessageCount); err != nil {
+			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.ForkSeq, &sess.ForkMode, &sess.Branch, &sess.CreatedAt, &sess.UpdatedAt, &sess.AgentName, &sess.MessageCount); err != nil {
 			return nil, err
 		}
 		sessions = append(sessions, sess)
@@ -721,6 +1032,14 @@ func (s *SQLiteStore) SetSessionNativeHandle(ctx context.Context, id, handle str
 	return err
 }
 
+func (s *SQLiteStore) SetSessionBranch(ctx context.Context, id, branch string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE sessions SET branch = ?, updated_at = ? WHERE id = ?",
+		branch, time.Now(), id,
+	)
+	return err
+}
+
 // --- Messages ---
 
 func (s *SQLiteStore) AppendMessage(ctx context.Context, msg *Message) (int64, error) {
@@ -733,10 +1052,10 @@ func (s *SQLiteStore) AppendMessage(ctx context.Context, msg *Message) (int64, e
 		defer func() { _ = tx.Rollback() }()
 
 		if txErr = tx.QueryRowContext(ctx,
-			`INSERT INTO messages (id, session_id, seq, direction, channel, content, created_at)
-			 VALUES (?, ?, (SELECT COALESCE(MAX(seq),0)+1 FROM messages WHERE session_id = ?), ?, ?, ?, ?)
+			`INSERT INTO messages (id, session_id, seq, direction, channel, content, author_id, created_at)
+			 VALUES (?, ?, (SELECT COALESCE(MAX(seq),0)+1 FROM messages WHERE session_id = ?), ?, ?, ?, ?, ?)
 			 RETURNING seq`,
-			msg.ID, msg.SessionID, msg.SessionID, msg.Direction, msg.Channel, msg.Content, msg.CreatedAt,
+			msg.ID, msg.SessionID, msg.SessionID, msg.Direction, msg.Channel, msg.Content, msg.AuthorID, msg.CreatedAt,
 		).Scan(&seq); txErr != nil {
 			return txErr
 		}
@@ -755,7 +1074,7 @@ func (s *SQLiteStore) AppendMessage(ctx context.Context, msg *Message) (int64, e
 
 func (s *SQLiteStore) GetMessages(ctx context.Context, sessionID string, afterSeq int64, limit int) ([]Message, error) {
 	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, session_id, seq, direction, channel, content, created_at
+		`SELECT id, session_id, seq, direction, channel, content, author_id, created_at
 		 FROM messages WHERE session_id = ? AND seq > ? ORDER BY seq LIMIT ?`,
 		sessionID, afterSeq, limit,
 	)
@@ -767,7 +1086,7 @@ func (s *SQLiteStore) GetMessages(ctx context.Context, sessionID string, afterSe
 	var messages []Message
 	for rows.Next() {
 		var m Message
-		if err := rows.Scan(&m.ID, &m.SessionID, &m.Seq, &m.Direction, &m.Channel, &m.Content, &m.CreatedAt); err != nil {
+		if err := rows.Scan(&m.ID, &m.SessionID, &m.Seq, &m.Direction, &m.Channel, &m.Content, &m.AuthorID, &m.CreatedAt); err != nil {
 			return nil, err
 		}
 		messages = append(messages, m)
@@ -783,6 +1102,64 @@ func (s *SQLiteStore) MessageExists(ctx context.Context, sessionID, messageID st
 	return count > 0, err
 }
 
+// --- Search ---
+
+// ftsQuery turns free-form input into an FTS5 query matching rows that contain
+// every term. Terms are quoted so FTS5 operators and punctuation in the input
+// are matched literally instead of causing syntax errors.
+func ftsQuery(q string) string {
+	terms := strings.Fields(q)
+	for i, t := range terms {
+		terms[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"`
+	}
+	return strings.Join(terms, " ")
+}
+
+func (s *SQLiteStore) SearchMessages(ctx context.Context, filter SearchFilter) ([]SearchResult, error) {
+	match := ftsQuery(filter.Query)
+	if match == "" {
+		return nil, nil
+	}
+
+	query := `SELECT m.session_id, m.seq, m.id, m.direction, m.channel,
+		        snippet(messages_fts, 0, '**', '**', '…', 16), s.agent_id, COALESCE(a.name, ''), m.created_at
+		 FROM messages_fts
+		 JOIN messages m ON m.rowid = messages_fts.rowid
+		 JOIN sessions s ON s.id = m.session_id
+		 LEFT JOIN agents a ON a.id = s.agent_id
+		 WHERE messages_fts MATCH ? AND s.org_id = ?`
+	args := []any{match, filter.OrgID}
+	if filter.UserID != "" {
+		query += " AND (s.user_id = ? OR s.id IN (SELECT session_id FROM session_members WHERE user_id = ?))"
+		args = append(args, filter.UserID, filter.UserID)
+	}
+	query += " ORDER BY bm25(messages_fts), m.created_at DESC"
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	query += " LIMIT ? OFFSET ?"
+	args = append(args, limit, filter.Offset)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.SessionID, &r.Seq, &r.MessageID, &r.Direction, &r.Channel,
+			&r.Snippet, &r.AgentID, &r.AgentName, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
 // --- Sessions (additional) ---
 
 func (s *SQLiteStore) ListActiveSessions(ctx context.Context, orgID string) ([]Session, error) {
@@ -790,11 +1167,11 @@ func (s *SQLiteStore) ListActiveSessions(ctx context.Context, orgID string) ([]S
 	var err error
 	if orgID == "" {
 		rows, err = s.db.QueryContext(ctx,
-			`SELECT id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, created_at, updated_at
+			`SELECT id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, fork_seq, fork_mode, branch, created_at, updated_at
 			 FROM sessions WHERE state NOT IN ('closed') ORDER BY updated_at DESC`)
 	} else {
 		rows, err = s.db.QueryContext(ctx,
-			`SELECT id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, created_at, updated_at
+			`SELECT id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, fork_seq, fork_mode, branch, created_at, updated_at
 			 FROM sessions WHERE org_id = ? AND state NOT IN ('closed') ORDER BY updated_at DESC`,
 			orgID)
 	}
@@ -807,7 +1184,7 @@ func (s *SQLiteStore) ListActiveSessions(ctx context.Context, orgID string) ([]S
 	for rows.Next() {
 		var sess Session
 		if err := rows.Scan(&sess.ID, &sess.OrgID, &sess.UserID, &sess.AgentID, &sess.RuntimeID, &sess.Profile,
-			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.CreatedAt, &sess.UpdatedAt); err != nil {
+			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.ForkSeq, &sess.ForkMode, &sess.Branch, &sess.CreatedAt, &sess.UpdatedAt); err != nil {
 			return nil, err
 		}
 		sessions = append(sessions, sess)
@@ -823,6 +1200,25 @@ func (s *SQLiteStore) CountActiveSessionsByUser(ctx context.Context, userID stri
 	return count, err
 }
 
+func (s *SQLiteStore) CountSessionsByState(ctx context.Context) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT state, COUNT(*) FROM sessions GROUP BY state")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var state string
+		var n int
+		if err := rows.Scan(&state, &n); err != nil {
+			return nil, err
+		}
+		counts[state] = n
+	}
+	return counts, rows.Err()
+}
+
 // --- Agent Permissions ---
 
 func (s *SQLiteStore) GrantAgentAccess(ctx context.Context, userID, agentID string) error {
@@ -842,10 +1238,25 @@ func (s *SQLiteStore) RevokeAgentAccess(ctx context.Context, userID, agentID str
 	return err
 }
 
+// sqliteUserAgentsQuery selects the IDs of every agent a user can access:
+// direct grants, agent grants of their groups, and agents whose tags match a
+// tag grant of their groups. It takes the user ID three times.
+const sqliteUserAgentsQuery = `
+	SELECT agent_id FROM agent_permissions WHERE user_id = ?
+	UNION
+	SELECT gg.agent_id FROM group_grants gg
+	JOIN group_members gm ON gm.group_id = gg.group_id
+	WHERE gm.user_id = ? AND gg.agent_id != ''
+	UNION
+	SELECT a.id FROM agents a
+	JOIN user_groups g ON g.org_id = a.org_id
+	JOIN group_members gm ON gm.group_id = g.id
+	JOIN group_grants gg ON gg.group_id = g.id
+	WHERE gm.user_id = ? AND gg.tag_key != ''
+	  AND EXISTS (SELECT 1 FROM json_each(a.tags) t WHERE t.key = gg.tag_key AND t.value = gg.tag_value)`
+
 func (s *SQLiteStore) ListUserAgents(ctx context.Context, userID string) ([]string, error) {
-	rows, err := s.db.QueryContext(ctx,
-		"SELECT agent_id FROM agent_permissions WHERE user_id = ?", userID,
-	)
+	rows, err := s.db.QueryContext(ctx, sqliteUserAgentsQuery, userID, userID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -865,59 +1276,214 @@ func (s *SQLiteStore) ListUserAgents(ctx context.Context, userID string) ([]stri
 func (s *SQLiteStore) HasAgentAccess(ctx context.Context, userID, agentID string) (bool, error) {
 	var count int
 	err := s.db.QueryRowContext(ctx,
-		"SELECT COUNT(*) FROM agent_permissions WHERE user_id = ? AND agent_id = ?",
-		userID, agentID,
+		"SELECT COUNT(*) FROM ("+sqliteUserAgentsQuery+") WHERE agent_id = ?",
+		userID, userID, userID, agentID,
 	).Scan(&count)
 	return count > 0, err
 }
 
-// --- Audit ---
+// --- Groups ---
 
-func (s *SQLiteStore) LogAuditEvent(ctx context.Context, event *AuditEvent) error {
-	detail := ""
-	if event.Detail != nil {
-		detail = string(event.Detail)
+func (s *SQLiteStore) CreateGroup(ctx context.Context, g *Group) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO user_groups (id, org_id, name, description, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		g.ID, g.OrgID, g.Name, g.Description, g.CreatedBy, g.CreatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetGroup(ctx context.Context, id string) (*Group, error) {
+	var g Group
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, org_id, name, description, created_by, created_at FROM user_groups WHERE id = ?", id,
+	).Scan(&g.ID, &g.OrgID, &g.Name, &g.Description, &g.CreatedBy, &g.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-	return sqliteRetry(func() error {
-		_, err := s.db.ExecContext(ctx,
-			`INSERT INTO audit_events (id, org_id, action, user_id, runtime_id, session_id, agent_id, detail, created_at)
-			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-			event.ID, event.OrgID, event.Action, event.UserID, event.RuntimeID, event.SessionID, event.AgentID, detail, event.CreatedAt,
-		)
+	return &g, err
+}
+
+func (s *SQLiteStore) ListGroups(ctx context.Context, orgID string) ([]Group, error) {
+	return s.queryGroups(ctx,
+		"SELECT id, org_id, name, description, created_by, created_at FROM user_groups WHERE org_id = ? ORDER BY name", orgID,
+	)
+}
+
+func (s *SQLiteStore) UpdateGroup(ctx context.Context, g *Group) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE user_groups SET name = ?, description = ? WHERE id = ?",
+		g.Name, g.Description, g.ID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) DeleteGroup(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
 		return err
-	})
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, q := range []string{
+		"DELETE FROM group_grants WHERE group_id = ?",
+		"DELETE FROM group_members WHERE group_id = ?",
+		"DELETE FROM user_groups WHERE id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-func (s *SQLiteStore) ListAuditEvents(ctx context.Context, orgID string, limit, offset int) ([]AuditEvent, error) {
+func (s *SQLiteStore) AddGroupMember(ctx context.Context, m *GroupMember) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO group_members (group_id, user_id, added_by, created_at) VALUES (?, ?, ?, ?)
+		 ON CONFLICT (group_id, user_id) DO NOTHING`,
+		m.GroupID, m.UserID, m.AddedBy, m.CreatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) RemoveGroupMember(ctx context.Context, groupID, userID string) error {
+	_, err := s.db.ExecContext(ctx,
+		"DELETE FROM group_members WHERE group_id = ? AND user_id = ?", groupID, userID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) ListGroupMembers(ctx context.Context, groupID string) ([]GroupMember, error) {
 	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, org_id, action, user_id, runtime_id, session_id, agent_id, detail, created_at
-		 FROM audit_events WHERE org_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?`,
-		orgID, limit, offset,
+		`SELECT gm.group_id, gm.user_id, COALESCE(u.username, ''), gm.added_by, gm.created_at
+		 FROM group_members gm
+		 LEFT JOIN users u ON u.id = gm.user_id
+		 WHERE gm.group_id = ? ORDER BY gm.created_at`, groupID,
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = rows.Close() }()
 
-	var events []AuditEvent
+	var members []GroupMember
 	for rows.Next() {
-		var e AuditEvent
-		var detail string
-		if err := rows.Scan(&e.ID, &e.OrgID, &e.Action, &e.UserID, &e.RuntimeID, &e.SessionID, &e.AgentID, &detail, &e.CreatedAt); err != nil {
+		var m GroupMember
+		if err := rows.Scan(&m.GroupID, &m.UserID, &m.Username, &m.AddedBy, &m.CreatedAt); err != nil {
 			return nil, err
 		}
-		if detail != "" {
-			e.Detail = json.RawMessage(detail)
-		}
-		events = append(events, e)
+		members = append(members, m)
 	}
-	return events, rows.Err()
+	return members, rows.Err()
 }
 
-func (s *SQLiteStore) ListAuditEventsFiltered(ctx context.Context, orgID string, filter AuditFilter) ([]AuditEvent, error) {
-	query := `SELECT id, org_id, action, user_id, runtime_id, session_id, agent_id, detail, created_at
-	          FROM audit_events WHERE org_id = ?`
-	args := []any{orgID}
+func (s *SQLiteStore) ListUserGroups(ctx context.Context, userID string) ([]Group, error) {
+	return s.queryGroups(ctx,
+		`SELECT g.id, g.org_id, g.name, g.description, g.created_by, g.created_at
+		 FROM user_groups g
+		 JOIN group_members gm ON gm.group_id = g.id
+		 WHERE gm.user_id = ? ORDER BY g.name`, userID,
+	)
+}
+
+func (s *SQLiteStore) queryGroups(ctx context.Context, query string, args ...any) ([]Group, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var groups []Group
+	for rows.Next() {
+		var g Group
+		if err := rows.Scan(&g.ID, &g.OrgID, &g.Name, &g.Description, &g.CreatedBy, &g.CreatedAt); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+func (s *SQLiteStore) CreateGroupGrant(ctx context.Context, g *GroupGrant) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO group_grants (id, group_id, agent_id, tag_key, tag_value, created_by, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		g.ID, g.GroupID, g.AgentID, g.TagKey, g.TagValue, g.CreatedBy, g.CreatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) ListGroupGrants(ctx context.Context, groupID string) ([]GroupGrant, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, group_id, agent_id, tag_key, tag_value, created_by, created_at
+		 FROM group_grants WHERE group_id = ? ORDER BY created_at`, groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var grants []GroupGrant
+	for rows.Next() {
+		var g GroupGrant
+		if err := rows.Scan(&g.ID, &g.GroupID, &g.AgentID, &g.TagKey, &g.TagValue, &g.CreatedBy, &g.CreatedAt); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteGroupGrant(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM group_grants WHERE id = ?", id)
+	return err
+}
+
+// --- Audit ---
+
+func (s *SQLiteStore) LogAuditEvent(ctx context.Context, event *AuditEvent) error {
+	detail := ""
+	if event.Detail != nil {
+		detail = string(event.Detail)
+	}
+	return sqliteRetry(func() error {
+		_, err := s.db.ExecContext(ctx,
+			`INSERT INTO audit_events (id, org_id, action, user_id, runtime_id, session_id, agent_id, detail, created_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			event.ID, event.OrgID, event.Action, event.UserID, event.RuntimeID, event.SessionID, event.AgentID, detail, event.CreatedAt,
+		)
+		return err
+	})
+}
+
+func (s *SQLiteStore) ListAuditEvents(ctx context.Context, orgID string, limit, offset int) ([]AuditEvent, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, org_id, action, user_id, runtime_id, session_id, agent_id, detail, created_at
+		 FROM audit_events WHERE org_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?`,
+		orgID, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var events []AuditEvent
+	for rows.Next() {
+		var e AuditEvent
+		var detail string
+		if err := rows.Scan(&e.ID, &e.OrgID, &e.Action, &e.UserID, &e.RuntimeID, &e.SessionID, &e.AgentID, &detail, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if detail != "" {
+			e.Detail = json.RawMessage(detail)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (s *SQLiteStore) ListAuditEventsFiltered(ctx context.Context, orgID string, filter AuditFilter) ([]AuditEvent, error) {
+	query := `SELECT id, org_id, action, user_id, runtime_id, session_id, agent_id, detail, created_at
+	          FROM audit_events WHERE org_id = ?`
+	args := []any{orgID}
 
 	if filter.Action != "" {
 		// Escape LIKE wildcards to prevent injection of % and _ characters.
@@ -977,13 +1543,13 @@ func (s *SQLiteStore) ListAuditEventsFiltered(ctx context.Context, orgID string,
 
 func (s *SQLiteStore) ListAllSessions(ctx context.Context, orgID string) ([]Session, error) {
 	rows, err := s.db.QueryContext(ctx,
-		`SELECT s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from,
+		`SELECT s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from, s.fork_seq, s.fork_mode, s.branch,
 		        s.created_at, s.updated_at, COALESCE(a.name, '') as agent_name, COUNT(m.id) as message_count
 		 FROM sessions s
 		 LEFT JOIN agents a ON s.agent_id = a.id
 		 LEFT JOIN messages m ON m.session_id = s.id
 		 WHERE s.org_id = ?
-		 GROUP BY s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from,
+		 GROUP BY s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from, s.fork_seq, s.fork_mode, s.branch,
 		          s.created_at, s.updated_at, a.name
 		 ORDER BY s.updated_at DESC`,
 		orgID,
@@ -997,7 +1563,7 @@ func (s *SQLiteStore) ListAllSessions(ctx context.Context, orgID string) ([]Sess
 	for rows.Next() {
 		var sess Session
 		if err := rows.Scan(&sess.ID, &sess.OrgID, &sess.UserID, &sess.AgentID, &sess.RuntimeID, &sess.Profile,
-			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.CreatedAt, &sess.UpdatedAt, &sess.AgentName, &sess.MessageCount); err != nil {
+			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.ForkSeq, &sess.ForkMode, &sess.Branch, &sess.CreatedAt, &sess.UpdatedAt, &sess.AgentName, &sess.MessageCount); err != nil {
 			return nil, err
 		}
 		sessions = append(sessions, sess)
@@ -1193,6 +1759,122 @@ func (s *SQLiteStore) UpdateRuntimeTokenLastUsed(ctx context.Context, id string)
 	return err
 }
 
+// --- Personal access tokens ---
+
+func (s *SQLiteStore) CreateUserToken(ctx context.Context, t *UserToken) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO user_tokens (id, org_id, user_id, name, token_hash, scopes, expires_at, created_at, last_used_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.ID, t.OrgID, t.UserID, t.Name, t.TokenHash, t.Scopes, t.ExpiresAt, t.CreatedAt, t.LastUsedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetUserTokenByHash(ctx context.Context, tokenHash string) (*UserToken, error) {
+	var t UserToken
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, org_id, user_id, name, token_hash, scopes, expires_at, created_at, last_used_at
+		 FROM user_tokens WHERE token_hash = ?`, tokenHash,
+	).Scan(&t.ID, &t.OrgID, &t.UserID, &t.Name, &t.TokenHash, &t.Scopes, &t.ExpiresAt, &t.CreatedAt, &t.LastUsedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &t, err
+}
+
+func (s *SQLiteStore) ListUserTokens(ctx context.Context, userID string) ([]UserToken, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, org_id, user_id, name, token_hash, scopes, expires_at, created_at, last_used_at
+		 FROM user_tokens WHERE user_id = ? ORDER BY created_at DESC`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var tokens []UserToken
+	for rows.Next() {
+		var t UserToken
+		if err := rows.Scan(&t.ID, &t.OrgID, &t.UserID, &t.Name, &t.TokenHash, &t.Scopes, &t.ExpiresAt, &t.CreatedAt, &t.LastUsedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteUserToken(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM user_tokens WHERE id = ?", id)
+	return err
+}
+
+func (s *SQLiteStore) UpdateUserTokenLastUsed(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE user_tokens SET last_used_at = ? WHERE id = ?",
+		time.Now(), id,
+	)
+	return err
+}
+
+// --- Two-factor authentication ---
+
+func (s *SQLiteStore) GetUserMFA(ctx context.Context, userID string) (*UserMFA, error) {
+	var m UserMFA
+	err := s.db.QueryRowContext(ctx,
+		`SELECT user_id, secret, enabled, recovery_codes, last_step, created_at, enabled_at
+		 FROM user_mfa WHERE user_id = ?`, userID,
+	).Scan(&m.UserID, &m.Secret, &m.Enabled, &m.RecoveryCodes, &m.LastStep, &m.CreatedAt, &m.EnabledAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &m, err
+}
+
+func (s *SQLiteStore) UpsertUserMFA(ctx context.Context, m *UserMFA) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO user_mfa (user_id, secret, enabled, recovery_codes, last_step, created_at, enabled_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, enabled = excluded.enabled,
+		   recovery_codes = excluded.recovery_codes, last_step = excluded.last_step,
+		   created_at = excluded.created_at, enabled_at = excluded.enabled_at`,
+		m.UserID, m.Secret, m.Enabled, m.RecoveryCodes, m.LastStep, m.CreatedAt, m.EnabledAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) DeleteUserMFA(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM user_mfa WHERE user_id = ?", userID)
+	return err
+}
+
+// --- Password resets ---
+
+func (s *SQLiteStore) CreatePasswordReset(ctx context.Context, r *PasswordReset) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO password_resets (id, org_id, user_id, token_hash, created_by, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		r.ID, r.OrgID, r.UserID, r.TokenHash, r.CreatedBy, r.ExpiresAt, r.CreatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetPasswordResetByHash(ctx context.Context, tokenHash string) (*PasswordReset, error) {
+	var r PasswordReset
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, org_id, user_id, token_hash, created_by, expires_at, created_at
+		 FROM password_resets WHERE token_hash = ?`, tokenHash,
+	).Scan(&r.ID, &r.OrgID, &r.UserID, &r.TokenHash, &r.CreatedBy, &r.ExpiresAt, &r.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &r, err
+}
+
+func (s *SQLiteStore) DeletePasswordResets(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM password_resets WHERE user_id = ?", userID)
+	return err
+}
+
 // --- Subscriptions (billing) ---
 
 func (s *SQLiteStore) GetSubscription(ctx context.Context, orgID string) (*Subscription, error) {
@@ -1252,3 +1934,436 @@ func (s *SQLiteStore) CountOnlineRuntimesByOrg(ctx context.Context, orgID string
 	).Scan(&count)
 	return count, err
 }
+
+// --- Webhooks ---
+
+func (s *SQLiteStore) CreateWebhook(ctx context.Context, wh *Webhook) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO webhooks (id, org_id, url, secret, events, description, enabled, created_by, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		wh.ID, wh.OrgID, wh.URL, wh.Secret, wh.Events, wh.Description, wh.Enabled, wh.CreatedBy, wh.CreatedAt, wh.UpdatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetWebhook(ctx context.Context, id string) (*Webhook, error) {
+	var wh Webhook
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, org_id, url, secret, events, description, enabled, created_by, created_at, updated_at
+		 FROM webhooks WHERE id = ?`, id,
+	).Scan(&wh.ID, &wh.OrgID, &wh.URL, &wh.Secret, &wh.Events, &wh.Description, &wh.Enabled, &wh.CreatedBy, &wh.CreatedAt, &wh.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &wh, err
+}
+
+func (s *SQLiteStore) ListWebhooks(ctx context.Context, orgID string) ([]Webhook, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, org_id, url, secret, events, description, enabled, created_by, created_at, updated_at
+		 FROM webhooks WHERE org_id = ? ORDER BY created_at`, orgID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var webhooks []Webhook
+	for rows.Next() {
+		var wh Webhook
+		if err := rows.Scan(&wh.ID, &wh.OrgID, &wh.URL, &wh.Secret, &wh.Events, &wh.Description, &wh.Enabled, &wh.CreatedBy, &wh.CreatedAt, &wh.UpdatedAt); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, wh)
+	}
+	return webhooks, rows.Err()
+}
+
+func (s *SQLiteStore) UpdateWebhook(ctx context.Context, wh *Webhook) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE webhooks SET url = ?, secret = ?, events = ?, description = ?, enabled = ?, updated_at = ?
+		 WHERE id = ?`,
+		wh.URL, wh.Secret, wh.Events, wh.Description, wh.Enabled, wh.UpdatedAt, wh.ID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) DeleteWebhook(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = ?", id)
+	return err
+}
+
+func (s *SQLiteStore) CreateWebhookDelivery(ctx context.Context, d *WebhookDelivery) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (id, webhook_id, org_id, event_id, event_type, payload, status, attempts,
+		                                 response_code, last_error, next_attempt_at, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.ID, d.WebhookID, d.OrgID, d.EventID, d.EventType, d.Payload, d.Status, d.Attempts,
+		d.ResponseCode, d.LastError, d.NextAttemptAt, d.CreatedAt, d.UpdatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) UpdateWebhookDelivery(ctx context.Context, d *WebhookDelivery) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET status = ?, attempts = ?, response_code = ?, last_error = ?,
+		        next_attempt_at = ?, updated_at = ?
+		 WHERE id = ?`,
+		d.Status, d.Attempts, d.ResponseCode, d.LastError, d.NextAttemptAt, d.UpdatedAt, d.ID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	return s.queryWebhookDeliveries(ctx,
+		`SELECT id, webhook_id, org_id, event_id, event_type, payload, status, attempts, response_code, last_error,
+		        next_attempt_at, created_at, updated_at
+		 FROM webhook_deliveries WHERE webhook_id = ? ORDER BY created_at DESC LIMIT ?`,
+		webhookID, limit,
+	)
+}
+
+func (s *SQLiteStore) ListDueWebhookDeliveries(ctx context.Context, before time.Time, limit int) ([]WebhookDelivery, error) {
+	return s.queryWebhookDeliveries(ctx,
+		`SELECT id, webhook_id, org_id, event_id, event_type, payload, status, attempts, response_code, last_error,
+		        next_attempt_at, created_at, updated_at
+		 FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?`,
+		before, limit,
+	)
+}
+
+func (s *SQLiteStore) queryWebhookDeliveries(ctx context.Context, query string, args ...any) ([]WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.OrgID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+			&d.ResponseCode, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (s *SQLiteStore) PurgeOldWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx,
+		"DELETE FROM webhook_deliveries WHERE created_at < ? AND status <> 'pending'", before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// --- Permission policies ---
+
+func (s *SQLiteStore) CreatePermissionPolicy(ctx context.Context, p *PermissionPolicy) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO permission_policies (id, org_id, agent_id, user_id, tool, pattern, exact, effect, description, created_by, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.ID, p.OrgID, p.AgentID, p.UserID, p.Tool, p.Pattern, p.Exact, p.Effect, p.Description, p.CreatedBy, p.CreatedAt, p.UpdatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetPermissionPolicy(ctx context.Context, id string) (*PermissionPolicy, error) {
+	var p PermissionPolicy
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, org_id, agent_id, user_id, tool, pattern, exact, effect, description, created_by, created_at, updated_at
+		 FROM permission_policies WHERE id = ?`, id,
+	).Scan(&p.ID, &p.OrgID, &p.AgentID, &p.UserID, &p.Tool, &p.Pattern, &p.Exact, &p.Effect, &p.Description, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &p, err
+}
+
+func (s *SQLiteStore) ListPermissionPolicies(ctx context.Context, orgID string) ([]PermissionPolicy, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, org_id, agent_id, user_id, tool, pattern, exact, effect, description, created_by, created_at, updated_at
+		 FROM permission_policies WHERE org_id = ? ORDER BY created_at`, orgID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var policies []PermissionPolicy
+	for rows.Next() {
+		var p PermissionPolicy
+		if err := rows.Scan(&p.ID, &p.OrgID, &p.AgentID, &p.UserID, &p.Tool, &p.Pattern, &p.Exact, &p.Effect, &p.Description, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+func (s *SQLiteStore) UpdatePermissionPolicy(ctx context.Context, p *PermissionPolicy) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE permission_policies SET agent_id = ?, user_id = ?, tool = ?, pattern = ?, exact = ?, effect = ?, description = ?, updated_at = ?
+		 WHERE id = ?`,
+		p.AgentID, p.UserID, p.Tool, p.Pattern, p.Exact, p.Effect, p.Description, p.UpdatedAt, p.ID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) DeletePermissionPolicy(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM permission_policies WHERE id = ?", id)
+	return err
+}
+
+// --- Session members ---
+
+func (s *SQLiteStore) AddSessionMember(ctx context.Context, m *SessionMember) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO session_members (session_id, user_id, role, added_by, created_at)
+		 VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT (session_id, user_id) DO UPDATE SET role = excluded.role, added_by = excluded.added_by`,
+		m.SessionID, m.UserID, m.Role, m.AddedBy, m.CreatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetSessionMember(ctx context.Context, sessionID, userID string) (*SessionMember, error) {
+	var m SessionMember
+	err := s.db.QueryRowContext(ctx,
+		`SELECT session_id, user_id, role, added_by, created_at
+		 FROM session_members WHERE session_id = ? AND user_id = ?`, sessionID, userID,
+	).Scan(&m.SessionID, &m.UserID, &m.Role, &m.AddedBy, &m.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &m, err
+}
+
+func (s *SQLiteStore) ListSessionMembers(ctx context.Context, sessionID string) ([]SessionMember, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT sm.session_id, sm.user_id, COALESCE(u.username, ''), sm.role, sm.added_by, sm.created_at
+		 FROM session_members sm
+		 LEFT JOIN users u ON u.id = sm.user_id
+		 WHERE sm.session_id = ? ORDER BY sm.created_at`, sessionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var members []SessionMember
+	for rows.Next() {
+		var m SessionMember
+		if err := rows.Scan(&m.SessionID, &m.UserID, &m.Username, &m.Role, &m.AddedBy, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (s *SQLiteStore) RemoveSessionMember(ctx context.Context, sessionID, userID string) error {
+	_, err := s.db.ExecContext(ctx,
+		"DELETE FROM session_members WHERE session_id = ? AND user_id = ?", sessionID, userID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) ListSessionsSharedWithUser(ctx context.Context, userID string) ([]Session, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from, s.fork_seq, s.fork_mode, s.branch,
+		        s.created_at, s.updated_at, COALESCE(a.name, '') as agent_name, COUNT(m.id) as message_count, sm.role
+		 FROM session_members sm
+		 JOIN sessions s ON s.id = sm.session_id
+		 LEFT JOIN agents a ON s.agent_id = a.id
+		 LEFT JOIN messages m ON m.session_id = s.id
+		 WHERE sm.user_id = ?
+		 GROUP BY s.id, s.org_id, s.user_id, s.agent_id, s.runtime_id, s.profile, s.prompt_profile, s.state, s.native_handle, s.resumed_from, s.fork_seq, s.fork_mode, s.branch,
+		          s.created_at, s.updated_at, a.name, sm.role
+		 ORDER BY s.updated_at DESC`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var sessions []Session
+	for rows.Next() {
+		var sess Session
+		if err := rows.Scan(&sess.ID, &sess.OrgID, &sess.UserID, &sess.AgentID, &sess.RuntimeID, &sess.Profile,
+			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.ForkSeq, &sess.ForkMode, &sess.Branch, &sess.CreatedAt, &sess.UpdatedAt,
+			&sess.AgentName, &sess.MessageCount, &sess.MemberRole); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, rows.Err()
+}
+
+// --- Session forks ---
+
+// CopyMessages copies the messages of one session with seq <= uptoSeq into
+// another, keeping their seq and timestamps. Copies get derived IDs so they
+// stay unique.
+func (s *SQLiteStore) CopyMessages(ctx context.Context, fromSessionID, toSessionID string, uptoSeq int64) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO messages (id, session_id, seq, direction, channel, content, author_id, created_at)
+		 SELECT ? || ':' || CAST(seq AS TEXT), ?, seq, direction, channel, content, author_id, created_at
+		 FROM messages WHERE session_id = ? AND seq <= ? ORDER BY seq`,
+		toSessionID, toSessionID, fromSessionID, uptoSeq,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *SQLiteStore) ListChildSessions(ctx context.Context, parentID string) ([]Session, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, org_id, user_id, agent_id, runtime_id, profile, prompt_profile, state, native_handle, resumed_from, fork_seq, fork_mode, branch, created_at, updated_at
+		 FROM sessions WHERE resumed_from = ? ORDER BY created_at`, parentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var sessions []Session
+	for rows.Next() {
+		var sess Session
+		if err := rows.Scan(&sess.ID, &sess.OrgID, &sess.UserID, &sess.AgentID, &sess.RuntimeID, &sess.Profile,
+			&sess.PromptProfile, &sess.State, &sess.NativeHandle, &sess.ResumedFrom, &sess.ForkSeq, &sess.ForkMode, &sess.Branch, &sess.CreatedAt, &sess.UpdatedAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, rows.Err()
+}
+
+// --- Schedules ---
+
+func (s *SQLiteStore) CreateSchedule(ctx context.Context, sc *Schedule) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO schedules (id, org_id, user_id, agent_id, name, cron, prompt, prompt_profile, enabled, next_run_at, last_run_at, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sc.ID, sc.OrgID, sc.UserID, sc.AgentID, sc.Name, sc.Cron, sc.Prompt, sc.PromptProfile, sc.Enabled,
+		sc.NextRunAt, sc.LastRunAt, sc.CreatedAt, sc.UpdatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetSchedule(ctx context.Context, id string) (*Schedule, error) {
+	var sc Schedule
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, org_id, user_id, agent_id, name, cron, prompt, prompt_profile, enabled, next_run_at, last_run_at, created_at, updated_at
+		 FROM schedules WHERE id = ?`, id,
+	).Scan(&sc.ID, &sc.OrgID, &sc.UserID, &sc.AgentID, &sc.Name, &sc.Cron, &sc.Prompt, &sc.PromptProfile, &sc.Enabled, &sc.NextRunAt, &sc.LastRunAt, &sc.CreatedAt, &sc.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &sc, err
+}
+
+func (s *SQLiteStore) ListSchedules(ctx context.Context, orgID string) ([]Schedule, error) {
+	return s.querySchedules(ctx,
+		`SELECT id, org_id, user_id, agent_id, name, cron, prompt, prompt_profile, enabled, next_run_at, last_run_at, created_at, updated_at
+		 FROM schedules WHERE org_id = ? ORDER BY created_at`, orgID,
+	)
+}
+
+func (s *SQLiteStore) ListDueSchedules(ctx context.Context, before time.Time) ([]Schedule, error) {
+	return s.querySchedules(ctx,
+		`SELECT id, org_id, user_id, agent_id, name, cron, prompt, prompt_profile, enabled, next_run_at, last_run_at, created_at, updated_at
+		 FROM schedules WHERE enabled = 1 AND next_run_at <= ? ORDER BY next_run_at`, before,
+	)
+}
+
+func (s *SQLiteStore) querySchedules(ctx context.Context, query string, args ...any) ([]Schedule, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var schedules []Schedule
+	for rows.Next() {
+		var sc Schedule
+		if err := rows.Scan(&sc.ID, &sc.OrgID, &sc.UserID, &sc.AgentID, &sc.Name, &sc.Cron, &sc.Prompt, &sc.PromptProfile, &sc.Enabled, &sc.NextRunAt, &sc.LastRunAt, &sc.CreatedAt, &sc.UpdatedAt); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, sc)
+	}
+	return schedules, rows.Err()
+}
+
+func (s *SQLiteStore) UpdateSchedule(ctx context.Context, sc *Schedule) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE schedules SET agent_id = ?, name = ?, cron = ?, prompt = ?, prompt_profile = ?, enabled = ?,
+		        next_run_at = ?, last_run_at = ?, updated_at = ?
+		 WHERE id = ?`,
+		sc.AgentID, sc.Name, sc.Cron, sc.Prompt, sc.PromptProfile, sc.Enabled, sc.NextRunAt, sc.LastRunAt, sc.UpdatedAt, sc.ID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) DeleteSchedule(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM schedule_runs WHERE schedule_id = ?", id); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, "DELETE FROM schedules WHERE id = ?", id)
+	return err
+}
+
+func (s *SQLiteStore) CreateScheduleRun(ctx context.Context, run *ScheduleRun) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO schedule_runs (id, schedule_id, org_id, session_id, status, result, error, scheduled_for, started_at, finished_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.ID, run.ScheduleID, run.OrgID, run.SessionID, run.Status, run.Result, run.Error,
+		run.ScheduledFor, run.StartedAt, run.FinishedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) UpdateScheduleRun(ctx context.Context, run *ScheduleRun) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE schedule_runs SET session_id = ?, status = ?, result = ?, error = ?, finished_at = ? WHERE id = ?`,
+		run.SessionID, run.Status, run.Result, run.Error, run.FinishedAt, run.ID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) ListScheduleRuns(ctx context.Context, scheduleID string, limit int) ([]ScheduleRun, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, schedule_id, org_id, session_id, status, result, error, scheduled_for, started_at, finished_at
+		 FROM schedule_runs WHERE schedule_id = ? ORDER BY started_at DESC LIMIT ?`,
+		scheduleID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var runs []ScheduleRun
+	for rows.Next() {
+		var run ScheduleRun
+		if err := rows.Scan(&run.ID, &run.ScheduleID, &run.OrgID, &run.SessionID, &run.Status, &run.Result, &run.Error,
+			&run.ScheduledFor, &run.StartedAt, &run.FinishedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
@@ -369,6 +369,7 @@ func TestUpsertAgent(t *testing.T) {
 		Tags:      `{"env":"prod"}`,
 		Caps:      `{"streaming":true}`,
 		Security:  "{}",
+		Sandbox:   `{"mode":"auto","backend":"bwrap"}`,
 	}
 
 	if err := s.UpsertAgent(ctx, agent); err != nil {
@@ -397,6 +398,9 @@ func TestUpsertAgent(t *testing.T) {
 	if got.Caps != `{"streaming":true}` {
 		t.Errorf("Caps: got %q, want %q", got.Caps, `{"streaming":true}`)
 	}
+	if got.Sandbox != agent.Sandbox {
+		t.Errorf("Sandbox: got %q, want %q", got.Sandbox, agent.Sandbox)
+	}
 }
 
 func TestListAgents(t *testing.T) {
@@ -940,3 +944,552 @@ func TestPing(t *testing.T) {
 		t.Fatalf("Ping: %v", err)
 	}
 }
+
+func TestSearchMessages(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice", "user")
+	bob := createTestUser(t, s, "bob", "user")
+	rt := createTestRuntime(t, s, "runtime-1")
+	agent := createTestAgent(t, s, rt.ID, "agent-1")
+	aliceSess := createTestSession(t, s, alice.ID, agent.ID, rt.ID, "active")
+	bobSess := createTestSession(t, s, bob.ID, agent.ID, rt.ID, "active")
+
+	for _, m := range []Message{
+		{SessionID: aliceSess.ID, Direction: "user", Channel: "stdin", Content: "please fix the failing migrations"},
+		{SessionID: aliceSess.ID, Direction: "agent", Channel: "stdout", Content: "Fixed the migration for the users table."},
+		{SessionID: bobSess.ID, Direction: "agent", Channel: "stdout", Content: "migration rolled back"},
+		{SessionID: bobSess.ID, Direction: "agent", Channel: "stdout", Content: "unrelated output"},
+	} {
+		m.ID = uuid.New().String()
+		m.CreatedAt = time.Now()
+		if _, err := s.AppendMessage(ctx, &m); err != nil {
+			t.Fatalf("AppendMessage: %v", err)
+		}
+	}
+
+	// Org-wide search matches stemmed terms across all sessions.
+	all, err := s.SearchMessages(ctx, SearchFilter{Query: "migration", OrgID: "default"})
+	if err != nil {
+		t.Fatalf("SearchMessages: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("org search: got %d results, want 3", len(all))
+	}
+
+	// Scoped to a user, only their sessions are searched; all terms must match.
+	mine, err := s.SearchMessages(ctx, SearchFilter{Query: "migration users", OrgID: "default", UserID: alice.ID})
+	if err != nil {
+		t.Fatalf("SearchMessages(user): %v", err)
+	}
+	if len(mine) != 1 {
+		t.Fatalf("user search: got %d results, want 1", len(mine))
+	}
+	if mine[0].SessionID != aliceSess.ID || mine[0].Seq != 2 || mine[0].AgentName != "agent-1" {
+		t.Errorf("unexpected result: %+v", mine[0])
+	}
+	if mine[0].Snippet != "Fixed the **migration** for the **users** table." {
+		t.Errorf("snippet = %q", mine[0].Snippet)
+	}
+
+	// Sessions shared with a user are searched too.
+	if err := s.AddSessionMember(ctx, &SessionMember{
+		SessionID: bobSess.ID, UserID: alice.ID, Role: "observer", AddedBy: bob.ID, CreatedAt: time.Now(),
+	}); err != nil {
+		t.Fatalf("AddSessionMember: %v", err)
+	}
+	shared, err := s.SearchMessages(ctx, SearchFilter{Query: "migration", OrgID: "default", UserID: alice.ID})
+	if err != nil {
+		t.Fatalf("SearchMessages(shared): %v", err)
+	}
+	if len(shared) != 3 {
+		t.Fatalf("shared search: got %d results, want 3", len(shared))
+	}
+
+	// Other orgs see nothing.
+	other, err := s.SearchMessages(ctx, SearchFilter{Query: "migration", OrgID: "other-org"})
+	if err != nil {
+		t.Fatalf("SearchMessages(other org): %v", err)
+	}
+	if len(other) != 0 {
+		t.Errorf("other org: got %d results, want 0", len(other))
+	}
+
+	// FTS5 syntax in user input is matched literally rather than failing.
+	if _, err := s.SearchMessages(ctx, SearchFilter{Query: `"unbalanced AND (`, OrgID: "default"}); err != nil {
+		t.Errorf("SearchMessages(special chars): %v", err)
+	}
+
+	// Purged messages drop out of the index.
+	if _, err := s.PurgeOldMessages(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("PurgeOldMessages: %v", err)
+	}
+	after, err := s.SearchMessages(ctx, SearchFilter{Query: "migration", OrgID: "default"})
+	if err != nil {
+		t.Fatalf("SearchMessages(after purge): %v", err)
+	}
+	if len(after) != 0 {
+		t.Errorf("after purge: got %d results, want 0", len(after))
+	}
+}
+
+func TestWebhooksAndDeliveries(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	wh := &Webhook{
+		ID: uuid.New().String(), OrgID: "default", URL: "https://example.com/hook", Secret: "s3cret",
+		Events: `["session.created"]`, Enabled: true, CreatedBy: "u1", CreatedAt: now, UpdatedAt: now,
+	}
+	if err := s.CreateWebhook(ctx, wh); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+
+	wh.Enabled = false
+	wh.Events = `["*"]`
+	if err := s.UpdateWebhook(ctx, wh); err != nil {
+		t.Fatalf("UpdateWebhook: %v", err)
+	}
+	got, err := s.GetWebhook(ctx, wh.ID)
+	if err != nil {
+		t.Fatalf("GetWebhook: %v", err)
+	}
+	if got == nil || got.Enabled || got.Events != `["*"]` || got.Secret != "s3cret" {
+		t.Fatalf("GetWebhook: unexpected %+v", got)
+	}
+	if list, err := s.ListWebhooks(ctx, "other-org"); err != nil || len(list) != 0 {
+		t.Fatalf("ListWebhooks(other-org): got %d, err %v", len(list), err)
+	}
+
+	due := &WebhookDelivery{
+		ID: uuid.New().String(), WebhookID: wh.ID, OrgID: "default", EventID: "e1", EventType: "session.created",
+		Payload: "{}", Status: "pending", NextAttemptAt: now.Add(-time.Minute), CreatedAt: now, UpdatedAt: now,
+	}
+	later := &WebhookDelivery{
+		ID: uuid.New().String(), WebhookID: wh.ID, OrgID: "default", EventID: "e2", EventType: "session.created",
+		Payload: "{}", Status: "pending", NextAttemptAt: now.Add(time.Hour), CreatedAt: now, UpdatedAt: now,
+	}
+	for _, d := range []*WebhookDelivery{due, later} {
+		if err := s.CreateWebhookDelivery(ctx, d); err != nil {
+			t.Fatalf("CreateWebhookDelivery: %v", err)
+		}
+	}
+
+	pending, err := s.ListDueWebhookDeliveries(ctx, now, 10)
+	if err != nil {
+		t.Fatalf("ListDueWebhookDeliveries: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != due.ID {
+		t.Fatalf("ListDueWebhookDeliveries: got %+v, want only %s", pending, due.ID)
+	}
+
+	due.Status = "succeeded"
+	due.Attempts = 1
+	due.ResponseCode = 200
+	if err := s.UpdateWebhookDelivery(ctx, due); err != nil {
+		t.Fatalf("UpdateWebhookDelivery: %v", err)
+	}
+	all, err := s.ListWebhookDeliveries(ctx, wh.ID, 10)
+	if err != nil {
+		t.Fatalf("ListWebhookDeliveries: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("ListWebhookDeliveries: got %d, want 2", len(all))
+	}
+
+	// Only finished deliveries are purged.
+	n, err := s.PurgeOldWebhookDeliveries(ctx, now.Add(time.Second))
+	if err != nil {
+		t.Fatalf("PurgeOldWebhookDeliveries: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("PurgeOldWebhookDeliveries: deleted %d, want 1", n)
+	}
+
+	if err := s.DeleteWebhook(ctx, wh.ID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	if got, _ := s.GetWebhook(ctx, wh.ID); got != nil {
+		t.Fatal("expected webhook to be deleted")
+	}
+	if all, _ := s.ListWebhookDeliveries(ctx, wh.ID, 10); len(all) != 0 {
+		t.Fatalf("expected deliveries to be deleted with webhook, got %d", len(all))
+	}
+}
+
+func TestUserTokens(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	tok := &UserToken{
+		ID: uuid.New().String(), OrgID: "default", UserID: "u1", Name: "ci", TokenHash: "hash-1",
+		Scopes: `["sessions:read"]`, ExpiresAt: now.Add(24 * time.Hour), CreatedAt: now,
+	}
+	if err := s.CreateUserToken(ctx, tok); err != nil {
+		t.Fatalf("CreateUserToken: %v", err)
+	}
+	dup := *tok
+	dup.ID = uuid.New().String()
+	if err := s.CreateUserToken(ctx, &dup); err == nil {
+		t.Fatal("expected duplicate token hash to be rejected")
+	}
+
+	got, err := s.GetUserTokenByHash(ctx, "hash-1")
+	if err != nil || got == nil {
+		t.Fatalf("GetUserTokenByHash: %v, %v", got, err)
+	}
+	if got.UserID != "u1" || got.Scopes != `["sessions:read"]` || !got.ExpiresAt.Equal(tok.ExpiresAt) || got.LastUsedAt != nil {
+		t.Fatalf("GetUserTokenByHash: unexpected %+v", got)
+	}
+	if missing, err := s.GetUserTokenByHash(ctx, "nope"); err != nil || missing != nil {
+		t.Fatalf("GetUserTokenByHash(missing): %v, %v", missing, err)
+	}
+
+	if err := s.UpdateUserTokenLastUsed(ctx, tok.ID); err != nil {
+		t.Fatalf("UpdateUserTokenLastUsed: %v", err)
+	}
+	list, err := s.ListUserTokens(ctx, "u1")
+	if err != nil || len(list) != 1 || list[0].LastUsedAt == nil {
+		t.Fatalf("ListUserTokens: %+v, %v", list, err)
+	}
+	if list, _ := s.ListUserTokens(ctx, "u2"); len(list) != 0 {
+		t.Fatalf("ListUserTokens(u2): got %d, want 0", len(list))
+	}
+
+	if err := s.DeleteUserToken(ctx, tok.ID); err != nil {
+		t.Fatalf("DeleteUserToken: %v", err)
+	}
+	if got, _ := s.GetUserTokenByHash(ctx, "hash-1"); got != nil {
+		t.Fatal("expected token to be deleted")
+	}
+}
+
+func TestUserMFAAndOrgSettings(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if m, err := s.GetUserMFA(ctx, "u1"); err != nil || m != nil {
+		t.Fatalf("GetUserMFA(missing): %v, %v", m, err)
+	}
+	m := &UserMFA{UserID: "u1", Secret: "JBSWY3DPEHPK3PXP", RecoveryCodes: "[]", CreatedAt: time.Now()}
+	if err := s.UpsertUserMFA(ctx, m); err != nil {
+		t.Fatalf("UpsertUserMFA: %v", err)
+	}
+	now := time.Now()
+	m.Enabled, m.EnabledAt, m.LastStep, m.RecoveryCodes = true, &now, 42, `["h1"]`
+	if err := s.UpsertUserMFA(ctx, m); err != nil {
+		t.Fatalf("UpsertUserMFA(update): %v", err)
+	}
+	got, err := s.GetUserMFA(ctx, "u1")
+	if err != nil || got == nil || !got.Enabled || got.LastStep != 42 || got.RecoveryCodes != `["h1"]` || got.EnabledAt == nil {
+		t.Fatalf("GetUserMFA: %+v, %v", got, err)
+	}
+	if err := s.DeleteUserMFA(ctx, "u1"); err != nil {
+		t.Fatalf("DeleteUserMFA: %v", err)
+	}
+	if got, _ := s.GetUserMFA(ctx, "u1"); got != nil {
+		t.Fatal("expected MFA to be deleted")
+	}
+
+	org, err := s.GetOrganization(ctx, "default")
+	if err != nil || org == nil || org.RequireAdminMFA {
+		t.Fatalf("GetOrganization: %+v, %v", org, err)
+	}
+	org.RequireAdminMFA = true
+	if err := s.UpdateOrganization(ctx, org); err != nil {
+		t.Fatalf("UpdateOrganization: %v", err)
+	}
+	if org, _ := s.GetOrganization(ctx, "default"); !org.RequireAdminMFA || org.Name != "Default" {
+		t.Fatalf("UpdateOrganization did not persist: %+v", org)
+	}
+}
+
+func TestUserLifecycle(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	u := &User{ID: "u1", OrgID: "default", Username: "alice", PasswordHash: "h1", Role: "user", CreatedAt: time.Now()}
+	if err := s.CreateUser(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	u.Role, u.PasswordHash, u.Disabled, u.PasswordChangedAt = "admin", "h2", true, &now
+	if err := s.UpdateUser(ctx, u); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	got, err := s.GetUserByID(ctx, "u1")
+	if err != nil || got.Role != "admin" || got.PasswordHash != "h2" || !got.Disabled || got.PasswordChangedAt == nil {
+		t.Fatalf("UpdateUser did not persist: %+v, %v", got, err)
+	}
+
+	reset := &PasswordReset{ID: "r1", OrgID: "default", UserID: "u1", TokenHash: "hash", CreatedBy: "admin", ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+	if err := s.CreatePasswordReset(ctx, reset); err != nil {
+		t.Fatalf("CreatePasswordReset: %v", err)
+	}
+	if r, err := s.GetPasswordResetByHash(ctx, "hash"); err != nil || r == nil || r.UserID != "u1" {
+		t.Fatalf("GetPasswordResetByHash: %+v, %v", r, err)
+	}
+
+	if err := s.GrantAgentAccess(ctx, "u1", "agent-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertUserMFA(ctx, &UserMFA{UserID: "u1", Secret: "s", RecoveryCodes: "[]", CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteUser(ctx, "u1"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	got, err = s.GetUserByID(ctx, "u1")
+	if err != nil || got == nil || got.DeletedAt == nil || !got.Disabled || got.PasswordHash != "" {
+		t.Fatalf("expected a deleted tombstone, got %+v, %v", got, err)
+	}
+	if byName, _ := s.GetUser(ctx, "default", "alice"); byName != nil {
+		t.Fatal("expected the username to be free after deletion")
+	}
+	if users, _ := s.ListUsers(ctx, "default"); len(users) != 0 {
+		t.Fatalf("expected deleted users to be hidden, got %+v", users)
+	}
+	if agents, _ := s.ListUserAgents(ctx, "u1"); len(agents) != 0 {
+		t.Fatalf("expected agent grants to be revoked, got %v", agents)
+	}
+	if m, _ := s.GetUserMFA(ctx, "u1"); m != nil {
+		t.Fatal("expected MFA to be removed")
+	}
+	if r, _ := s.GetPasswordResetByHash(ctx, "hash"); r != nil {
+		t.Fatal("expected password resets to be removed")
+	}
+}
+
+func TestPermissionPolicies(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	p := &PermissionPolicy{
+		ID: uuid.New().String(), OrgID: "default", Tool: "Bash", Pattern: "go test *", Effect: "allow",
+		CreatedBy: "u1", CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}
+	if err := s.CreatePermissionPolicy(ctx, p); err != nil {
+		t.Fatalf("CreatePermissionPolicy: %v", err)
+	}
+
+	p.Effect = "deny"
+	p.AgentID = "agent-1"
+	if err := s.UpdatePermissionPolicy(ctx, p); err != nil {
+		t.Fatalf("UpdatePermissionPolicy: %v", err)
+	}
+	got, err := s.GetPermissionPolicy(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("GetPermissionPolicy: %v", err)
+	}
+	if got == nil || got.Effect != "deny" || got.AgentID != "agent-1" || got.Pattern != "go test *" {
+		t.Fatalf("GetPermissionPolicy: unexpected %+v", got)
+	}
+
+	if list, err := s.ListPermissionPolicies(ctx, "default"); err != nil || len(list) != 1 {
+		t.Fatalf("ListPermissionPolicies: got %d, err %v", len(list), err)
+	}
+	if list, _ := s.ListPermissionPolicies(ctx, "other-org"); len(list) != 0 {
+		t.Fatalf("ListPermissionPolicies(other-org): got %d, want 0", len(list))
+	}
+
+	if err := s.DeletePermissionPolicy(ctx, p.ID); err != nil {
+		t.Fatalf("DeletePermissionPolicy: %v", err)
+	}
+	if got, _ := s.GetPermissionPolicy(ctx, p.ID); got != nil {
+		t.Fatal("expected policy to be deleted")
+	}
+}
+
+func TestSessionMembers(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	owner := createTestUser(t, s, "owner", "user")
+	guest := createTestUser(t, s, "guest", "user")
+	rt := createTestRuntime(t, s, "rt")
+	agent := createTestAgent(t, s, rt.ID, "agent")
+	sess := createTestSession(t, s, owner.ID, agent.ID, rt.ID, "active")
+
+	m := &SessionMember{SessionID: sess.ID, UserID: guest.ID, Role: MemberObserver, AddedBy: owner.ID, CreatedAt: time.Now()}
+	if err := s.AddSessionMember(ctx, m); err != nil {
+		t.Fatalf("AddSessionMember: %v", err)
+	}
+	// Adding again updates the role.
+	m.Role = MemberCollaborator
+	if err := s.AddSessionMember(ctx, m); err != nil {
+		t.Fatalf("AddSessionMember (upsert): %v", err)
+	}
+
+	got, err := s.GetSessionMember(ctx, sess.ID, guest.ID)
+	if err != nil || got == nil || got.Role != MemberCollaborator {
+		t.Fatalf("GetSessionMember: got %+v, err %v", got, err)
+	}
+	if got, _ := s.GetSessionMember(ctx, sess.ID, owner.ID); got != nil {
+		t.Fatalf("GetSessionMember(owner): expected nil, got %+v", got)
+	}
+
+	members, err := s.ListSessionMembers(ctx, sess.ID)
+	if err != nil || len(members) != 1 || members[0].Username != "guest" {
+		t.Fatalf("ListSessionMembers: got %+v, err %v", members, err)
+	}
+
+	shared, err := s.ListSessionsSharedWithUser(ctx, guest.ID)
+	if err != nil || len(shared) != 1 || shared[0].ID != sess.ID || shared[0].MemberRole != MemberCollaborator {
+		t.Fatalf("ListSessionsSharedWithUser: got %+v, err %v", shared, err)
+	}
+
+	if _, err := s.AppendMessage(ctx, &Message{
+		ID: uuid.New().String(), SessionID: sess.ID, Direction: "user", Channel: "stdin",
+		Content: "hi", AuthorID: guest.ID, CreatedAt: time.Now(),
+	}); err != nil {
+		t.Fatalf("AppendMessage: %v", err)
+	}
+	msgs, err := s.GetMessages(ctx, sess.ID, 0, 10)
+	if err != nil || len(msgs) != 1 || msgs[0].AuthorID != guest.ID {
+		t.Fatalf("GetMessages: got %+v, err %v", msgs, err)
+	}
+
+	if err := s.RemoveSessionMember(ctx, sess.ID, guest.ID); err != nil {
+		t.Fatalf("RemoveSessionMember: %v", err)
+	}
+	if shared, _ := s.ListSessionsSharedWithUser(ctx, guest.ID); len(shared) != 0 {
+		t.Fatalf("ListSessionsSharedWithUser after remove: got %d, want 0", len(shared))
+	}
+}
+
+func TestGroupAccess(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice", "user")
+	bob := createTestUser(t, s, "bob", "user")
+	rt := createTestRuntime(t, s, "rt")
+	tagged := createTestAgent(t, s, rt.ID, "tagged") // tags {"env":"test"}
+	other := createTestAgent(t, s, rt.ID, "other")
+	other.Tags = `{"env":"prod"}`
+	if err := s.UpsertAgent(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	direct := createTestAgent(t, s, rt.ID, "direct")
+
+	g := &Group{ID: uuid.New().String(), OrgID: "default", Name: "devs", CreatedAt: time.Now()}
+	if err := s.CreateGroup(ctx, g); err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	if err := s.CreateGroup(ctx, &Group{ID: uuid.New().String(), OrgID: "default", Name: "devs", CreatedAt: time.Now()}); err == nil {
+		t.Fatal("expected a duplicate group name to fail")
+	}
+	for _, gr := range []*GroupGrant{
+		{ID: uuid.New().String(), GroupID: g.ID, AgentID: direct.ID, CreatedAt: time.Now()},
+		{ID: uuid.New().String(), GroupID: g.ID, TagKey: "env", TagValue: "test", CreatedAt: time.Now()},
+	} {
+		if err := s.CreateGroupGrant(ctx, gr); err != nil {
+			t.Fatalf("CreateGroupGrant: %v", err)
+		}
+	}
+	if err := s.AddGroupMember(ctx, &GroupMember{GroupID: g.ID, UserID: alice.ID, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("AddGroupMember: %v", err)
+	}
+
+	agents, err := s.ListUserAgents(ctx, alice.ID)
+	if err != nil || len(agents) != 2 {
+		t.Fatalf("ListUserAgents: got %v, err %v", agents, err)
+	}
+	for _, tc := range []struct {
+		user, agent string
+		want        bool
+	}{
+		{alice.ID, tagged.ID, true},
+		{alice.ID, direct.ID, true},
+		{alice.ID, other.ID, false},
+		{bob.ID, tagged.ID, false},
+	} {
+		if got, err := s.HasAgentAccess(ctx, tc.user, tc.agent); err != nil || got != tc.want {
+			t.Errorf("HasAgentAccess(%s, %s) = %v, %v; want %v", tc.user, tc.agent, got, err, tc.want)
+		}
+	}
+
+	members, err := s.ListGroupMembers(ctx, g.ID)
+	if err != nil || len(members) != 1 || members[0].Username != "alice" {
+		t.Fatalf("ListGroupMembers: got %+v, err %v", members, err)
+	}
+	if groups, err := s.ListUserGroups(ctx, alice.ID); err != nil || len(groups) != 1 || groups[0].ID != g.ID {
+		t.Fatalf("ListUserGroups: got %+v, err %v", groups, err)
+	}
+
+	grants, _ := s.ListGroupGrants(ctx, g.ID)
+	if len(grants) != 2 {
+		t.Fatalf("ListGroupGrants: got %d, want 2", len(grants))
+	}
+	for _, gr := range grants {
+		if gr.TagKey == "" {
+			continue
+		}
+		if err := s.DeleteGroupGrant(ctx, gr.ID); err != nil {
+			t.Fatalf("DeleteGroupGrant: %v", err)
+		}
+	}
+	if ok, _ := s.HasAgentAccess(ctx, alice.ID, tagged.ID); ok {
+		t.Fatal("expected the tag grant to be gone")
+	}
+
+	if err := s.DeleteGroup(ctx, g.ID); err != nil {
+		t.Fatalf("DeleteGroup: %v", err)
+	}
+	if agents, _ := s.ListUserAgents(ctx, alice.ID); len(agents) != 0 {
+		t.Fatalf("ListUserAgents after DeleteGroup: got %v, want none", agents)
+	}
+	if members, _ := s.ListGroupMembers(ctx, g.ID); len(members) != 0 {
+		t.Fatalf("expected members to be removed with the group, got %d", len(members))
+	}
+}
+
+func TestCopyMessagesAndChildSessions(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	user := createTestUser(t, s, "forker", "user")
+	rt := createTestRuntime(t, s, "rt")
+	agent := createTestAgent(t, s, rt.ID, "agent")
+	parent := createTestSession(t, s, user.ID, agent.ID, rt.ID, "active")
+	for _, content := range []string{"one", "two", "three"} {
+		if _, err := s.AppendMessage(ctx, &Message{
+			ID: uuid.New().String(), SessionID: parent.ID, Direction: "user", Channel: "stdin", Content: content, CreatedAt: time.Now(),
+		}); err != nil {
+			t.Fatalf("AppendMessage: %v", err)
+		}
+	}
+
+	child := &Session{
+		ID: uuid.New().String(), OrgID: "default", UserID: user.ID, AgentID: agent.ID, RuntimeID: rt.ID,
+		State: "active", ResumedFrom: parent.ID, ForkSeq: 2, CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}
+	if err := s.CreateSession(ctx, child); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	n, err := s.CopyMessages(ctx, parent.ID, child.ID, 2)
+	if err != nil || n != 2 {
+		t.Fatalf("CopyMessages: copied %d, err %v", n, err)
+	}
+
+	// New messages continue after the copied ones.
+	seq, err := s.AppendMessage(ctx, &Message{
+		ID: uuid.New().String(), SessionID: child.ID, Direction: "user", Channel: "stdin", Content: "alt", CreatedAt: time.Now(),
+	})
+	if err != nil || seq != 3 {
+		t.Fatalf("AppendMessage after copy: seq %d, err %v", seq, err)
+	}
+	msgs, _ := s.GetMessages(ctx, child.ID, 0, 10)
+	if len(msgs) != 3 || msgs[0].Content != "one" || msgs[1].Content != "two" || msgs[2].Content != "alt" {
+		t.Fatalf("fork transcript: unexpected %+v", msgs)
+	}
+
+	children, err := s.ListChildSessions(ctx, parent.ID)
+	if err != nil || len(children) != 1 || children[0].ID != child.ID || children[0].ForkSeq != 2 {
+		t.Fatalf("ListChildSessions: got %+v, err %v", children, err)
+	}
+}
@@ -12,6 +12,7 @@ type Store interface {
 	// Organizations
 	CreateOrganization(ctx context.Context, org *Organization) error
 	GetOrganization(ctx context.Context, id string) (*Organization, error)
+	UpdateOrganization(ctx context.Context, org *Organization) error
 
 	// Users
 	CreateUser(ctx context.Context, user *User) error
@@ -19,6 +20,13 @@ type Store interface {
 	GetUserByID(ctx context.Context, id string) (*User, error)
 	GetUserByExternalID(ctx context.Context, externalID string) (*User, error)
 	ListUsers(ctx context.Context, orgID string) ([]User, error)
+	UpdateUser(ctx context.Context, user *User) error
+	DeleteUser(ctx context.Context, id string) error
+
+	// Password resets
+	CreatePasswordReset(ctx context.Context, r *PasswordReset) error
+	GetPasswordResetByHash(ctx context.Context, tokenHash string) (*PasswordReset, error)
+	DeletePasswordResets(ctx context.Context, userID string) error
 
 	// Runtimes
 	UpsertRuntime(ctx context.Context, rt *Runtime) error
@@ -39,22 +47,42 @@ type Store interface {
 	ListSessionsByUser(ctx context.Context, userID string) ([]Session, error)
 	UpdateSessionState(ctx context.Context, id string, state string) error
 	SetSessionNativeHandle(ctx context.Context, id, handle string) error
+	SetSessionBranch(ctx context.Context, id, branch string) error
 
 	// Sessions (additional)
 	ListActiveSessions(ctx context.Context, orgID string) ([]Session, error)
 	CountActiveSessionsByUser(ctx context.Context, userID string) (int, error)
+	CountSessionsByState(ctx context.Context) (map[string]int, error)
 
 	// Messages
 	AppendMessage(ctx context.Context, msg *Message) (int64, error)
 	GetMessages(ctx context.Context, sessionID string, afterSeq int64, limit int) ([]Message, error)
 	MessageExists(ctx context.Context, sessionID, messageID string) (bool, error)
 
-	// Agent Permissions
+	// Search
+	SearchMessages(ctx context.Context, filter SearchFilter) ([]SearchResult, error)
+
+	// Agent Permissions. ListUserAgents and HasAgentAccess resolve effective
+	// access: direct grants plus agent and tag grants of the user's groups.
 	GrantAgentAccess(ctx context.Context, userID, agentID string) error
 	RevokeAgentAccess(ctx context.Context, userID, agentID string) error
 	ListUserAgents(ctx context.Context, userID string) ([]string, error)
 	HasAgentAccess(ctx context.Context, userID, agentID string) (bool, error)
 
+	// Groups
+	CreateGroup(ctx context.Context, g *Group) error
+	GetGroup(ctx context.Context, id string) (*Group, error)
+	ListGroups(ctx context.Context, orgID string) ([]Group, error)
+	UpdateGroup(ctx context.Context, g *Group) error
+	DeleteGroup(ctx context.Context, id string) error
+	AddGroupMember(ctx context.Context, m *GroupMember) error
+	RemoveGroupMember(ctx context.Context, groupID, userID string) error
+	ListGroupMembers(ctx context.Context, groupID string) ([]GroupMember, error)
+	ListUserGroups(ctx context.Context, userID string) ([]Group, error)
+	CreateGroupGrant(ctx context.Context, g *GroupGrant) error
+	ListGroupGrants(ctx context.Context, groupID string) ([]GroupGrant, error)
+	DeleteGroupGrant(ctx context.Context, id string) error
+
 	// Audit
 	LogAuditEvent(ctx context.Context, event *AuditEvent) error
 	ListAuditEvents(ctx context.Context, orgID string, limit, offset int) ([]AuditEvent, error)
@@ -87,11 +115,64 @@ type Store interface {
 	RevokeRuntimeToken(ctx context.Context, id string) error
 	UpdateRuntimeTokenLastUsed(ctx context.Context, id string) error
 
+	// Personal access tokens
+	CreateUserToken(ctx context.Context, t *UserToken) error
+	GetUserTokenByHash(ctx context.Context, tokenHash string) (*UserToken, error)
+	ListUserTokens(ctx context.Context, userID string) ([]UserToken, error)
+	DeleteUserToken(ctx context.Context, id string) error
+	UpdateUserTokenLastUsed(ctx context.Context, id string) error
+
+	// Two-factor authentication
+	GetUserMFA(ctx context.Context, userID string) (*UserMFA, error)
+	UpsertUserMFA(ctx context.Context, m *UserMFA) error
+	DeleteUserMFA(ctx context.Context, userID string) error
+
 	// Subscriptions (billing)
 	GetSubscription(ctx context.Context, orgID string) (*Subscription, error)
 	UpsertSubscription(ctx context.Context, sub *Subscription) error
 	GetSubscriptionByStripeCustomer(ctx context.Context, customerID string) (*Subscription, error)
 
+	// Webhooks
+	CreateWebhook(ctx context.Context, wh *Webhook) error
+	GetWebhook(ctx context.Context, id string) (*Webhook, error)
+	ListWebhooks(ctx context.Context, orgID string) ([]Webhook, error)
+	UpdateWebhook(ctx context.Context, wh *Webhook) error
+	DeleteWebhook(ctx context.Context, id string) error
+	CreateWebhookDelivery(ctx context.Context, d *WebhookDelivery) error
+	UpdateWebhookDelivery(ctx context.Context, d *WebhookDelivery) error
+	ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error)
+	ListDueWebhookDeliveries(ctx context.Context, before time.Time, limit int) ([]WebhookDelivery, error)
+	PurgeOldWebhookDeliveries(ctx context.Context, before time.Time) (int64, error)
+
+	// Session members
+	AddSessionMember(ctx context.Context, m *SessionMember) error
+	GetSessionMember(ctx context.Context, sessionID, userID string) (*SessionMember, error)
+	ListSessionMembers(ctx context.Context, sessionID string) ([]SessionMember, error)
+	RemoveSessionMember(ctx context.Context, sessionID, userID string) error
+	ListSessionsSharedWithUser(ctx context.Context, userID string) ([]Session, error)
+
+	// Session forks
+	CopyMessages(ctx context.Context, fromSessionID, toSessionID string, uptoSeq int64) (int64, error)
+	ListChildSessions(ctx context.Context, parentID string) ([]Session, error)
+
+	// Schedules
+	CreateSchedule(ctx context.Context, sc *Schedule) error
+	GetSchedule(ctx context.Context, id string) (*Schedule, error)
+	ListSchedules(ctx context.Context, orgID string) ([]Schedule, error)
+	UpdateSchedule(ctx context.Context, sc *Schedule) error
+	DeleteSchedule(ctx context.Context, id string) error
+	ListDueSchedules(ctx context.Context, before time.Time) ([]Schedule, error)
+	CreateScheduleRun(ctx context.Context, run *ScheduleRun) error
+	UpdateScheduleRun(ctx context.Context, run *ScheduleRun) error
+	ListScheduleRuns(ctx context.Context, scheduleID string, limit int) ([]ScheduleRun, error)
+
+	// Permission policies
+	CreatePermissionPolicy(ctx context.Context, p *PermissionPolicy) error
+	GetPermissionPolicy(ctx context.Context, id string) (*PermissionPolicy, error)
+	ListPermissionPolicies(ctx context.Context, orgID string) ([]PermissionPolicy, error)
+	UpdatePermissionPolicy(ctx context.Context, p *PermissionPolicy) error
+	DeletePermissionPolicy(ctx context.Context, id string) error
+
 	// Billing counts
 	CountActiveSessionsByOrg(ctx context.Context, orgID string) (int, error)
 	CountOnlineRuntimesByOrg(ctx context.Context, orgID string) (int, error)
@@ -105,10 +186,11 @@ type Store interface {
 
 // Organization represents a tenant organization.
 type Organization struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Plan      string    `json:"plan"`
-	CreatedAt time.Time `json:"created_at"`
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	Plan            string    `json:"plan"`
+	RequireAdminMFA bool      `json:"require_admin_mfa"` // admins must sign in with a second factor
+	CreatedAt       time.Time `json:"created_at"`
 }
 
 // Subscription represents a billing subscription for an organization.
@@ -132,6 +214,10 @@ type User struct {
 	PasswordHash string    `json:"-"`
 	Role         string    `json:"role"` // "admin" or "user"
 	CreatedAt    time.Time `json:"created_at"`
+
+	Disabled          bool       `json:"disabled"`
+	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty"` // session tokens issued earlier are rejected
+	DeletedAt         *time.Time `json:"deleted_at,omitempty"`
 }
 
 // Runtime represents a registered runtime.
@@ -153,6 +239,7 @@ type Agent struct {
 	Tags      string `json:"tags"`     // JSON-encoded map
 	Caps      string `json:"caps"`     // JSON-encoded ProfileCaps
 	Security  string `json:"security"` // JSON-encoded SecurityProfile
+	Sandbox   string `json:"sandbox"`  // JSON-encoded SandboxStatus; empty when unsandboxed
 }
 
 // Session represents a conversation session.
@@ -166,11 +253,15 @@ type Session struct {
 	PromptProfile string    `json:"prompt_profile,omitempty"`
 	State         string    `json:"state"` // "active", "idle", "closed"
 	NativeHandle  string    `json:"native_handle,omitempty"`
-	ResumedFrom   string    `json:"resumed_from,omitempty"` // ID of the hub session this was resumed from, if known
+	ResumedFrom   string    `json:"resumed_from,omitempty"` // ID of the hub session this was resumed or forked from, if known
+	ForkSeq       int64     `json:"fork_seq,omitempty"`     // last message seq copied from ResumedFrom when forked
+	ForkMode      string    `json:"fork_mode,omitempty"`    // "native" or "transcript" when forked
+	Branch        string    `json:"branch,omitempty"`       // git branch of the session's worktree, if any
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 	AgentName     string    `json:"agent_name,omitempty"`
 	MessageCount  int       `json:"message_count"`
+	MemberRole    string    `json:"member_role,omitempty"` // caller's role when the session is shared with them
 }
 
 // Message represents a stored message in a transcript.
@@ -181,6 +272,76 @@ type Message struct {
 	Direction string    `json:"direction"` // "user" or "agent"
 	Channel   string    `json:"channel"`   // "stdin", "stdout", "stderr", "system"
 	Content   string    `json:"content"`
+	AuthorID  string    `json:"author_id,omitempty"` // user who sent a user-direction message
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Session member roles.
+const (
+	MemberObserver     = "observer"     // may watch the session and read its transcript
+	MemberCollaborator = "collaborator" // may also send messages and answer permission requests
+)
+
+// SessionMember grants a user other than the owner access to a session.
+type SessionMember struct {
+	SessionID string    `json:"session_id"`
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username,omitempty"`
+	Role      string    `json:"role"`
+	AddedBy   string    `json:"added_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Group is a named set of users that agent access can be granted to.
+type Group struct {
+	ID          string    `json:"id"`
+	OrgID       string    `json:"org_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// GroupMember is a user's membership in a group.
+type GroupMember struct {
+	GroupID   string    `json:"group_id"`
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username,omitempty"`
+	AddedBy   string    `json:"added_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GroupGrant gives a group's members access to one agent (AgentID) or to
+// every agent whose registration tags include TagKey=TagValue.
+type GroupGrant struct {
+	ID        string    `json:"id"`
+	GroupID   string    `json:"group_id"`
+	AgentID   string    `json:"agent_id,omitempty"`
+	TagKey    string    `json:"tag_key,omitempty"`
+	TagValue  string    `json:"tag_value,omitempty"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SearchFilter scopes a full-text search over message content.
+type SearchFilter struct {
+	Query  string // free-form search terms; all terms must match
+	OrgID  string
+	UserID string // restrict to sessions owned by or shared with this user; empty = all sessions in the org
+	Limit  int
+	Offset int
+}
+
+// SearchResult is a message that matched a full-text search.
+type SearchResult struct {
+	SessionID string    `json:"session_id"`
+	Seq       int64     `json:"seq"`
+	MessageID string    `json:"message_id"`
+	Direction string    `json:"direction"`
+	Channel   string    `json:"channel"`
+	Snippet   string    `json:"snippet"` // matched terms are wrapped in "**"
+	AgentID   string    `json:"agent_id"`
+	AgentName string    `json:"agent_name,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -233,6 +394,91 @@ type RuntimeToken struct {
 	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
 }
 
+// UserToken is a personal access token a user created for scripts and the CLI.
+type UserToken struct {
+	ID         string     `json:"id"`
+	OrgID      string     `json:"org_id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	Scopes     string     `json:"scopes"` // JSON-encoded []string
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// PasswordReset is a one-time password reset link issued by an admin. Only
+// the SHA-256 hash of the link's token is stored.
+type PasswordReset struct {
+	ID        string    `json:"id"`
+	OrgID     string    `json:"org_id"`
+	UserID    string    `json:"user_id"`
+	TokenHash string    `json:"-"`
+	CreatedBy string    `json:"created_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserMFA is a user's TOTP second factor. It is pending until the user
+// confirms enrollment with a valid code.
+type UserMFA struct {
+	UserID        string     `json:"user_id"`
+	Secret        string     `json:"-"` // base32 TOTP secret
+	Enabled       bool       `json:"enabled"`
+	RecoveryCodes string     `json:"-"` // JSON-encoded []string of SHA-256 hashes of unused codes
+	LastStep      int64      `json:"-"` // last accepted TOTP time step, to refuse replays
+	CreatedAt     time.Time  `json:"created_at"`
+	EnabledAt     *time.Time `json:"enabled_at,omitempty"`
+}
+
+// Webhook is an admin-registered URL that receives hub events for an org.
+type Webhook struct {
+	ID          string    `json:"id"`
+	OrgID       string    `json:"org_id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"-"`      // HMAC-SHA256 signing key
+	Events      string    `json:"events"` // JSON-encoded []string of event types; "*" matches all
+	Description string    `json:"description"`
+	Enabled     bool      `json:"enabled"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery records a single event sent (or to be sent) to a webhook.
+type WebhookDelivery struct {
+	ID            string    `json:"id"`
+	WebhookID     string    `json:"webhook_id"`
+	OrgID         string    `json:"org_id"`
+	EventID       string    `json:"event_id"`
+	EventType     string    `json:"event_type"`
+	Payload       string    `json:"payload"` // JSON request body
+	Status        string    `json:"status"`  // "pending", "succeeded", "failed"
+	Attempts      int       `json:"attempts"`
+	ResponseCode  int       `json:"response_code,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// PermissionPolicy is a persistent rule that answers permission requests
+// automatically. Empty AgentID/UserID match any agent/user in the org.
+type PermissionPolicy struct {
+	ID          string    `json:"id"`
+	OrgID       string    `json:"org_id"`
+	AgentID     string    `json:"agent_id"`
+	UserID      string    `json:"user_id"`
+	Tool        string    `json:"tool"`    // tool name; "*" or empty matches any tool
+	Pattern     string    `json:"pattern"` // wildcard matched against the request's resource or description
+	Exact       bool      `json:"exact"`   // Pattern is literal; '*' is not a wildcard
+	Effect      string    `json:"effect"`  // "allow" or "deny"
+	Description string    `json:"description"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
 // AuditFilter specifies criteria for filtering audit events.
 type AuditFilter struct {
 	Action    string
@@ -242,3 +488,34 @@ type AuditFilter struct {
 	Limit     int
 	Offset    int
 }
+
+// Schedule runs a stored prompt against an agent on a cron schedule.
+type Schedule struct {
+	ID            string     `json:"id"`
+	OrgID         string     `json:"org_id"`
+	UserID        string     `json:"user_id"` // owner; runs are created as this user
+	AgentID       string     `json:"agent_id"`
+	Name          string     `json:"name"`
+	Cron          string     `json:"cron"` // 5-field cron expression, evaluated in UTC
+	Prompt        string     `json:"prompt"`
+	PromptProfile string     `json:"prompt_profile,omitempty"`
+	Enabled       bool       `json:"enabled"`
+	NextRunAt     time.Time  `json:"next_run_at"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ScheduleRun records one firing of a schedule.
+type ScheduleRun struct {
+	ID           string     `json:"id"`
+	ScheduleID   string     `json:"schedule_id"`
+	OrgID        string     `json:"org_id"`
+	SessionID    string     `json:"session_id,omitempty"`
+	Status       string     `json:"status"`           // "running", "succeeded", "failed", "missed"
+	Result       string     `json:"result,omitempty"` // agent output of the turn, truncated
+	Error        string     `json:"error,omitempty"`
+	ScheduledFor time.Time  `json:"scheduled_for"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
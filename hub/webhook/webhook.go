@@ -0,0 +1,318 @@
+// Package webhook delivers hub events to admin-registered HTTP endpoints.
+//
+// Each event is POSTed as JSON to every enabled webhook in the event's org
+// whose filter matches. Requests carry an HMAC-SHA256 signature computed with
+// the webhook's secret. Failed deliveries are retried with backoff; every
+// attempt is recorded in the store so retries survive a hub restart.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/amurg-ai/amurg/hub/store"
+	"github.com/google/uuid"
+)
+
+// Event types that can be subscribed to.
+const (
+	EventSessionCreated    = "session.created"
+	EventTurnCompleted     = "turn.completed"
+	EventPermissionRequest = "permission.request"
+	EventSessionIdleClose  = "session.idle_close"
+	EventRuntimeOffline    = "runtime.offline"
+
+	// EventAll subscribes a webhook to every event type.
+	EventAll = "*"
+)
+
+// EventTypes lists every event type a webhook may subscribe to.
+var EventTypes = []string{
+	EventSessionCreated,
+	EventTurnCompleted,
+	EventPermissionRequest,
+	EventSessionIdleClose,
+	EventRuntimeOffline,
+}
+
+// Request headers set on every delivery.
+const (
+	HeaderEvent     = "X-Amurg-Event"
+	HeaderDelivery  = "X-Amurg-Delivery"
+	HeaderTimestamp = "X-Amurg-Timestamp"
+	HeaderSignature = "X-Amurg-Signature"
+)
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Event is a single hub event. It is also the JSON body of the delivery.
+type Event struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	OrgID     string          `json:"org_id"`
+	SessionID string          `json:"session_id,omitempty"`
+	AgentID   string          `json:"agent_id,omitempty"`
+	RuntimeID string          `json:"runtime_id,omitempty"`
+	UserID    string          `json:"user_id,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// ValidEventType reports whether t can be used in a webhook's event filter.
+func ValidEventType(t string) bool {
+	if t == EventAll {
+		return true
+	}
+	for _, e := range EventTypes {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether a webhook's JSON-encoded event filter includes eventType.
+func Matches(filter, eventType string) bool {
+	var events []string
+	if err := json.Unmarshal([]byte(filter), &events); err != nil {
+		return false
+	}
+	for _, e := range events {
+		if e == EventAll || e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the X-Amurg-Signature value for a delivery body:
+// "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>".
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher fans events out to matching webhooks. A nil *Dispatcher is valid
+// and discards every event, so callers need not check whether webhooks are enabled.
+type Dispatcher struct {
+	store  store.Store
+	client *http.Client
+	logger *slog.Logger
+	events chan Event
+
+	workers      int
+	backoff      []time.Duration // delay before retry N; len(backoff)+1 attempts in total
+	lease        time.Duration   // how long an in-flight delivery is hidden from the retry scanner
+	scanInterval time.Duration
+	now          func() time.Time
+}
+
+// NewDispatcher creates a dispatcher backed by the given store.
+func NewDispatcher(s store.Store, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		store:        s,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		logger:       logger.With("component", "webhook"),
+		events:       make(chan Event, 256),
+		workers:      4,
+		backoff:      []time.Duration{30 * time.Second, 2 * time.Minute, 10 * time.Minute, 30 * time.Minute, time.Hour},
+		lease:        time.Minute,
+		scanInterval: 15 * time.Second,
+		now:          time.Now,
+	}
+}
+
+// Publish queues an event for delivery. It never blocks; if the queue is full
+// the event is dropped and a warning is logged.
+func (d *Dispatcher) Publish(ev Event) {
+	if d == nil {
+		return
+	}
+	if ev.ID == "" {
+		ev.ID = uuid.New().String()
+	}
+	if ev.CreatedAt.IsZero() {
+		ev.CreatedAt = d.now()
+	}
+	select {
+	case d.events <- ev:
+	default:
+		d.logger.Warn("webhook queue full, dropping event", "event_type", ev.Type, "event_id", ev.ID)
+	}
+}
+
+// Run delivers queued events and retries failed deliveries until ctx is canceled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	if d == nil {
+		return
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < d.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case ev := <-d.events:
+					d.dispatch(ctx, ev)
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(d.scanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+			d.retryDue(ctx)
+		}
+	}
+}
+
+// dispatch records and attempts a delivery for every webhook matching ev.
+func (d *Dispatcher) dispatch(ctx context.Context, ev Event) {
+	webhooks, err := d.store.ListWebhooks(ctx, ev.OrgID)
+	if err != nil {
+		d.logger.Warn("list webhooks failed", "org_id", ev.OrgID, "error", err)
+		return
+	}
+	var payload []byte
+	for i := range webhooks {
+		wh := &webhooks[i]
+		if !wh.Enabled || !Matches(wh.Events, ev.Type) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(ev); err != nil {
+				d.logger.Warn("marshal webhook event failed", "event_type", ev.Type, "error", err)
+				return
+			}
+		}
+		now := d.now()
+		del := &store.WebhookDelivery{
+			ID:            uuid.New().String(),
+			WebhookID:     wh.ID,
+			OrgID:         wh.OrgID,
+			EventID:       ev.ID,
+			EventType:     ev.Type,
+			Payload:       string(payload),
+			Status:        StatusPending,
+			NextAttemptAt: now.Add(d.lease),
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := d.store.CreateWebhookDelivery(ctx, del); err != nil {
+			d.logger.Warn("record webhook delivery failed", "webhook_id", wh.ID, "error", err)
+			continue
+		}
+		d.attempt(ctx, wh, del)
+	}
+}
+
+// retryDue re-attempts pending deliveries whose backoff has elapsed.
+func (d *Dispatcher) retryDue(ctx context.Context) {
+	due, err := d.store.ListDueWebhookDeliveries(ctx, d.now(), 50)
+	if err != nil {
+		d.logger.Warn("list due webhook deliveries failed", "error", err)
+		return
+	}
+	for i := range due {
+		del := &due[i]
+		wh, err := d.store.GetWebhook(ctx, del.WebhookID)
+		if err != nil {
+			d.logger.Warn("get webhook failed", "webhook_id", del.WebhookID, "error", err)
+			continue
+		}
+		if wh == nil || !wh.Enabled {
+			del.Status = StatusFailed
+			del.LastError = "webhook deleted or disabled"
+			del.UpdatedAt = d.now()
+			if err := d.store.UpdateWebhookDelivery(ctx, del); err != nil {
+				d.logger.Warn("update webhook delivery failed", "delivery_id", del.ID, "error", err)
+			}
+			continue
+		}
+		d.attempt(ctx, wh, del)
+	}
+}
+
+// attempt sends one delivery and records the outcome, scheduling a retry on failure.
+func (d *Dispatcher) attempt(ctx context.Context, wh *store.Webhook, del *store.WebhookDelivery) {
+	code, err := d.send(ctx, wh, del)
+
+	del.Attempts++
+	del.ResponseCode = code
+	del.UpdatedAt = d.now()
+	switch {
+	case err == nil:
+		del.Status = StatusSucceeded
+		del.LastError = ""
+	case del.Attempts > len(d.backoff):
+		del.Status = StatusFailed
+		del.LastError = err.Error()
+	default:
+		del.Status = StatusPending
+		del.LastError = err.Error()
+		del.NextAttemptAt = del.UpdatedAt.Add(d.backoff[del.Attempts-1])
+	}
+	if err != nil {
+		d.logger.Warn("webhook delivery failed",
+			"webhook_id", wh.ID, "delivery_id", del.ID, "attempt", del.Attempts, "status", del.Status, "error", err)
+	}
+
+	if err := d.store.UpdateWebhookDelivery(ctx, del); err != nil {
+		d.logger.Warn("update webhook delivery failed", "delivery_id", del.ID, "error", err)
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, wh *store.Webhook, del *store.WebhookDelivery) (int, error) {
+	body := []byte(del.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	ts := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "amurg-hub-webhook")
+	req.Header.Set(HeaderEvent, del.EventType)
+	req.Header.Set(HeaderDelivery, del.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(wh.Secret, ts, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
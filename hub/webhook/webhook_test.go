@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amurg-ai/amurg/hub/store"
+)
+
+func newTestDispatcher(t *testing.T) (*Dispatcher, store.Store) {
+	t.Helper()
+	s, err := store.NewSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	d := NewDispatcher(s, slog.Default())
+	d.scanInterval = 10 * time.Millisecond
+	d.backoff = []time.Duration{0}
+	return d, s
+}
+
+func createWebhook(t *testing.T, s store.Store, url, events string) *store.Webhook {
+	t.Helper()
+	wh := &store.Webhook{
+		ID: "wh-" + strconv.FormatInt(time.Now().UnixNano(), 36), OrgID: "default", URL: url, Secret: "topsecret",
+		Events: events, Enabled: true, CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}
+	if err := s.CreateWebhook(context.Background(), wh); err != nil {
+		t.Fatal(err)
+	}
+	return wh
+}
+
+func waitForDeliveries(t *testing.T, s store.Store, webhookID, status string, n int) []store.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deliveries, err := s.ListWebhookDeliveries(context.Background(), webhookID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		matched := 0
+		for _, d := range deliveries {
+			if d.Status == status {
+				matched++
+			}
+		}
+		if matched == n {
+			return deliveries
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d %s deliveries, have %+v", n, status, deliveries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Reference value: printf '1700000000.{}' | openssl dgst -sha256 -hmac secret
+	got := Sign("secret", 1700000000, []byte("{}"))
+	want := "sha256=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+	if got != want {
+		t.Errorf("Sign = %q, want %q", got, want)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		filter, event string
+		want          bool
+	}{
+		{`["session.created"]`, EventSessionCreated, true},
+		{`["session.created"]`, EventTurnCompleted, false},
+		{`["*"]`, EventRuntimeOffline, true},
+		{`not json`, EventSessionCreated, false},
+	}
+	for _, tt := range tests {
+		if got := Matches(tt.filter, tt.event); got != tt.want {
+			t.Errorf("Matches(%s, %s) = %v, want %v", tt.filter, tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestDispatcherSignsAndRetries(t *testing.T) {
+	d, s := newTestDispatcher(t)
+
+	var mu sync.Mutex
+	calls := 0
+	var lastBody []byte
+	var lastHeader http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		lastBody, lastHeader = body, r.Header.Clone()
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	wh := createWebhook(t, s, srv.URL, `["session.created"]`)
+	skipped := createWebhook(t, s, srv.URL, `["turn.completed"]`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	d.Publish(Event{Type: EventSessionCreated, OrgID: "default", SessionID: "sess-1"})
+
+	deliveries := waitForDeliveries(t, s, wh.ID, StatusSucceeded, 1)
+	if deliveries[0].Attempts != 2 {
+		t.Errorf("attempts = %d, want 2", deliveries[0].Attempts)
+	}
+	if deliveries[0].ResponseCode != http.StatusNoContent {
+		t.Errorf("response code = %d, want 204", deliveries[0].ResponseCode)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if lastHeader.Get(HeaderEvent) != EventSessionCreated {
+		t.Errorf("event header = %q", lastHeader.Get(HeaderEvent))
+	}
+	if lastHeader.Get(HeaderDelivery) != deliveries[0].ID {
+		t.Errorf("delivery header = %q, want %q", lastHeader.Get(HeaderDelivery), deliveries[0].ID)
+	}
+	ts, err := strconv.ParseInt(lastHeader.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("bad timestamp header: %v", err)
+	}
+	if lastHeader.Get(HeaderSignature) != Sign("topsecret", ts, lastBody) {
+		t.Error("signature does not verify")
+	}
+	var ev Event
+	if err := json.Unmarshal(lastBody, &ev); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if ev.SessionID != "sess-1" || ev.ID == "" {
+		t.Errorf("unexpected event body %+v", ev)
+	}
+
+	if other, _ := s.ListWebhookDeliveries(context.Background(), skipped.ID, 10); len(other) != 0 {
+		t.Errorf("non-matching webhook got %d deliveries", len(other))
+	}
+}
+
+func TestDispatcherGivesUpAfterBackoff(t *testing.T) {
+	d, s := newTestDispatcher(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	wh := createWebhook(t, s, srv.URL, `["*"]`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	d.Publish(Event{Type: EventRuntimeOffline, OrgID: "default", RuntimeID: "rt-1"})
+
+	deliveries := waitForDeliveries(t, s, wh.ID, StatusFailed, 1)
+	if deliveries[0].Attempts != len(d.backoff)+1 {
+		t.Errorf("attempts = %d, want %d", deliveries[0].Attempts, len(d.backoff)+1)
+	}
+	if deliveries[0].LastError == "" {
+		t.Error("expected last_error to be recorded")
+	}
+}
+
+func TestNilDispatcherIsNoop(t *testing.T) {
+	var d *Dispatcher
+	d.Publish(Event{Type: EventSessionCreated})
+	d.Run(context.Background())
+}
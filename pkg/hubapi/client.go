@@ -1,6 +1,7 @@
 package hubapi
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -23,12 +24,71 @@ type Session struct {
 	State        string    `json:"state"`
 	NativeHandle string    `json:"native_handle,omitempty"`
 	ResumedFrom  string    `json:"resumed_from,omitempty"`
+	Branch       string    `json:"branch,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	AgentName    string    `json:"agent_name,omitempty"`
 	MessageCount int       `json:"message_count"`
 }
 
+// RunRequest is the body of POST /api/agents/{id}/run.
+type RunRequest struct {
+	Prompt         string `json:"prompt"`
+	PromptProfile  string `json:"prompt_profile,omitempty"`
+	Wait           *bool  `json:"wait,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+// Run mirrors the hub's headless agent run payload.
+type Run struct {
+	ID         string     `json:"id"`
+	SessionID  string     `json:"session_id"`
+	AgentID    string     `json:"agent_id"`
+	Status     string     `json:"status"` // "running", "succeeded" or "failed"
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	Output     string     `json:"output"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// OutputChunk is one agent.output event from a streamed run.
+type OutputChunk struct {
+	SessionID string `json:"session_id"`
+	Seq       int64  `json:"seq"`
+	Channel   string `json:"channel"` // "stdout", "stderr" or "system"
+	Content   string `json:"content"`
+}
+
+// Token mirrors a personal access token from /api/me/tokens. Token holds the
+// secret and is only set in the response that created it.
+type Token struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	Token      string     `json:"token,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// TokenRequest is the body of POST /api/me/tokens.
+type TokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty"`
+}
+
+// MFARequiredError is returned by Login for accounts with two-factor
+// authentication. Pass Token and a code to LoginMFA to finish signing in.
+type MFARequiredError struct {
+	Token string
+}
+
+func (e *MFARequiredError) Error() string {
+	return "account uses two-factor authentication: a one-time code is required"
+}
+
 // Client is a small HTTP client for the hub API.
 type Client struct {
 	baseURL    string
@@ -97,9 +157,12 @@ func (c *Client) SetToken(token string) {
 }
 
 // Login exchanges username/password for a JWT using the builtin auth endpoint.
+// Accounts with two-factor authentication get an *MFARequiredError.
 func (c *Client) Login(ctx context.Context, username, password string) (string, error) {
 	var resp struct {
-		Token string `json:"token"`
+		Token       string `json:"token"`
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
 	}
 	if err := c.do(ctx, http.MethodPost, "/api/auth/login", map[string]string{
 		"username": username,
@@ -107,6 +170,26 @@ func (c *Client) Login(ctx context.Context, username, password string) (string,
 	}, false, &resp); err != nil {
 		return "", err
 	}
+	if resp.MFARequired {
+		return "", &MFARequiredError{Token: resp.MFAToken}
+	}
+	if resp.Token == "" {
+		return "", fmt.Errorf("hub login returned an empty token")
+	}
+	return resp.Token, nil
+}
+
+// LoginMFA finishes a two-factor login with a TOTP or recovery code.
+func (c *Client) LoginMFA(ctx context.Context, mfaToken, code string) (string, error) {
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/auth/login/mfa", map[string]string{
+		"mfa_token": mfaToken,
+		"code":      code,
+	}, false, &resp); err != nil {
+		return "", err
+	}
 	if resp.Token == "" {
 		return "", fmt.Errorf("hub login returned an empty token")
 	}
@@ -125,51 +208,197 @@ func (c *Client) ListSessions(ctx context.Context) ([]Session, error) {
 	return sessions, nil
 }
 
+// ExportSession downloads the transcript of a session in the given format
+// ("md", "jsonl" or "html") and copies it to w.
+func (c *Client) ExportSession(ctx context.Context, sessionID, format string, w io.Writer) error {
+	path := "/api/sessions/" + url.PathEscape(sessionID) + "/export?format=" + url.QueryEscape(format)
+	resp, err := c.send(ctx, http.MethodGet, path, nil, true)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("read export: %w", err)
+	}
+	return nil
+}
+
+// ListTokens returns the current user's personal access tokens.
+func (c *Client) ListTokens(ctx context.Context) ([]Token, error) {
+	var tokens []Token
+	if err := c.do(ctx, http.MethodGet, "/api/me/tokens", nil, true, &tokens); err != nil {
+		return nil, err
+	}
+	if tokens == nil {
+		tokens = []Token{}
+	}
+	return tokens, nil
+}
+
+// CreateToken creates a personal access token. The hub only accepts this
+// from an interactive sign-in, not from another personal access token.
+func (c *Client) CreateToken(ctx context.Context, req TokenRequest) (*Token, error) {
+	var token Token
+	if err := c.do(ctx, http.MethodPost, "/api/me/tokens", req, true, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RevokeToken deletes one of the current user's personal access tokens.
+func (c *Client) RevokeToken(ctx context.Context, tokenID string) error {
+	return c.do(ctx, http.MethodDelete, "/api/me/tokens/"+url.PathEscape(tokenID), nil, true, nil)
+}
+
+// StartRun sends a prompt to an agent in a new session and returns the run
+// without waiting for it to finish; poll it with GetRun.
+func (c *Client) StartRun(ctx context.Context, agentID string, req RunRequest) (*Run, error) {
+	wait := false
+	req.Wait = &wait
+	var run Run
+	if err := c.do(ctx, http.MethodPost, "/api/agents/"+url.PathEscape(agentID)+"/run", req, true, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// GetRun returns the current state of a run started with StartRun or StreamRun.
+func (c *Client) GetRun(ctx context.Context, runID string) (*Run, error) {
+	var run Run
+	if err := c.do(ctx, http.MethodGet, "/api/runs/"+url.PathEscape(runID), nil, true, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// StreamRun sends a prompt to an agent in a new session and calls onOutput
+// for each output chunk as it arrives. It returns the finished run. The
+// stream is bounded by ctx rather than the client's request timeout.
+func (c *Client) StreamRun(ctx context.Context, agentID string, req RunRequest, onOutput func(OutputChunk)) (*Run, error) {
+	path := "/api/agents/" + url.PathEscape(agentID) + "/run"
+	httpReq, err := c.newRequest(ctx, http.MethodPost, path, req, true)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	streamClient := *c.httpClient
+	streamClient.Timeout = 0
+	resp, err := sendRequest(&streamClient, httpReq, path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var run *Run
+	var event string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if v, ok := strings.CutPrefix(line, "event: "); ok {
+			event = v
+			continue
+		}
+		payload, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		switch event {
+		case "run", "turn.completed":
+			var r Run
+			if err := json.Unmarshal([]byte(payload), &r); err != nil {
+				return nil, fmt.Errorf("decode run event: %w", err)
+			}
+			run = &r
+			if event == "turn.completed" {
+				return run, nil
+			}
+		case "agent.output":
+			var chunk OutputChunk
+			if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+				return nil, fmt.Errorf("decode output event: %w", err)
+			}
+			if onOutput != nil {
+				onOutput(chunk)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return run, fmt.Errorf("read run stream: %w", err)
+	}
+	return run, fmt.Errorf("run stream ended before the turn completed")
+}
+
 func (c *Client) do(ctx context.Context, method, path string, body any, auth bool, out any) error {
+	resp, err := c.send(ctx, method, path, body, auth)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// send performs a request and returns the response if it has a 2xx status.
+// The caller must close the response body.
+func (c *Client) send(ctx context.Context, method, path string, body any, auth bool) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, path, body, auth)
+	if err != nil {
+		return nil, err
+	}
+	return sendRequest(c.httpClient, req, path)
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body any, auth bool) (*http.Request, error) {
 	var reqBody io.Reader
 	if body != nil {
 		data, err := json.Marshal(body)
 		if err != nil {
-			return fmt.Errorf("encode request body: %w", err)
+			return nil, fmt.Errorf("encode request body: %w", err)
 		}
 		reqBody = bytes.NewReader(data)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
 	if err != nil {
-		return fmt.Errorf("build request: %w", err)
+		return nil, fmt.Errorf("build request: %w", err)
 	}
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
 	if auth {
 		if c.token == "" {
-			return fmt.Errorf("missing bearer token")
+			return nil, fmt.Errorf("missing bearer token")
 		}
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
+	return req, nil
+}
 
-	resp, err := c.httpClient.Do(req)
+// sendRequest performs req with httpClient; path is used in error messages.
+func sendRequest(httpClient *http.Client, req *http.Request, path string) (*http.Response, error) {
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("request %s %s: %w", method, path, err)
+		return nil, fmt.Errorf("request %s %s: %w", req.Method, path, err)
 	}
-	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
 		msg := readAPIError(resp.Body)
 		if msg == "" {
 			msg = resp.Status
 		}
-		return fmt.Errorf("hub API %s %s failed: %s (HTTP %d)", method, path, msg, resp.StatusCode)
-	}
-
-	if out == nil || resp.StatusCode == http.StatusNoContent {
-		return nil
-	}
-	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
-		return fmt.Errorf("decode response: %w", err)
+		return nil, fmt.Errorf("hub API %s %s failed: %s (HTTP %d)", req.Method, path, msg, resp.StatusCode)
 	}
-	return nil
+	return resp, nil
 }
 
 func readAPIError(r io.Reader) string {
@@ -3,8 +3,10 @@ package hubapi
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -100,3 +102,126 @@ func TestClientLoginAndListSessions(t *testing.T) {
 		t.Fatalf("message_count = %d, want 7", sessions[0].MessageCount)
 	}
 }
+
+func TestClientLoginMFA(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/api/auth/login":
+			_ = json.NewEncoder(w).Encode(map[string]any{"mfa_required": true, "mfa_token": "challenge"})
+		case "/api/auth/login/mfa":
+			if body["mfa_token"] != "challenge" || body["code"] != "123456" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid two-factor code"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"token": "jwt-token"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL, srv.Client())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	_, err = client.Login(context.Background(), "alice", "secret")
+	var mfaErr *MFARequiredError
+	if !errors.As(err, &mfaErr) || mfaErr.Token != "challenge" {
+		t.Fatalf("Login error = %v, want MFARequiredError", err)
+	}
+	if _, err := client.LoginMFA(context.Background(), mfaErr.Token, "000000"); err == nil {
+		t.Fatal("expected a wrong code to fail")
+	}
+	token, err := client.LoginMFA(context.Background(), mfaErr.Token, "123456")
+	if err != nil || token != "jwt-token" {
+		t.Fatalf("LoginMFA = %q, %v", token, err)
+	}
+}
+
+func TestClientExportSession(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/sessions/sess-1/export" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.URL.Query().Get("format"); got != "md" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "format must be md, jsonl, or html"})
+			return
+		}
+		_, _ = w.Write([]byte("# Session sess-1\n"))
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL, srv.Client())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	client.SetToken("jwt-token")
+
+	var out strings.Builder
+	if err := client.ExportSession(context.Background(), "sess-1", "md", &out); err != nil {
+		t.Fatalf("ExportSession: %v", err)
+	}
+	if out.String() != "# Session sess-1\n" {
+		t.Fatalf("export = %q", out.String())
+	}
+
+	err = client.ExportSession(context.Background(), "sess-1", "pdf", &out)
+	if err == nil || !strings.Contains(err.Error(), "format must be md, jsonl, or html") {
+		t.Fatalf("ExportSession(pdf) error = %v", err)
+	}
+}
+
+func TestClientStartAndGetRun(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/agents/ag-1/run":
+			var body RunRequest
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Fatalf("decode run body: %v", err)
+			}
+			if body.Wait == nil || *body.Wait || body.Prompt != "hello" {
+				t.Fatalf("unexpected run payload: %+v", body)
+			}
+			w.WriteHeader(http.StatusAccepted)
+			_ = json.NewEncoder(w).Encode(Run{ID: "run-1", SessionID: "sess-1", Status: "running"})
+		case "/api/runs/run-1":
+			_ = json.NewEncoder(w).Encode(Run{ID: "run-1", SessionID: "sess-1", Status: "succeeded", Output: "hi"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := New(srv.URL, srv.Client())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	client.SetToken("jwt-token")
+
+	run, err := client.StartRun(context.Background(), "ag-1", RunRequest{Prompt: "hello"})
+	if err != nil {
+		t.Fatalf("StartRun: %v", err)
+	}
+	if run.ID != "run-1" || run.Status != "running" {
+		t.Fatalf("StartRun = %+v", run)
+	}
+	run, err = client.GetRun(context.Background(), run.ID)
+	if err != nil {
+		t.Fatalf("GetRun: %v", err)
+	}
+	if run.Status != "succeeded" || run.Output != "hi" {
+		t.Fatalf("GetRun = %+v", run)
+	}
+}
@@ -5,7 +5,10 @@
 // that determines the payload structure.
 package protocol
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Envelope is the top-level wire format for all messages.
 type Envelope struct {
@@ -45,6 +48,15 @@ type AgentRegistration struct {
 	Tags     map[string]string `json:"tags,omitempty"`
 	Caps     ProfileCaps       `json:"caps"`
 	Security *SecurityProfile  `json:"security,omitempty"`
+	Sandbox  *SandboxStatus    `json:"sandbox,omitempty"`
+}
+
+// SandboxStatus reports how an agent's processes are confined on the runtime host.
+type SandboxStatus struct {
+	Mode        string `json:"mode"`              // configured mode: "auto", "bwrap" or "landlock"
+	Backend     string `json:"backend,omitempty"` // backend in use; empty when unsandboxed
+	DenyNetwork bool   `json:"deny_network,omitempty"`
+	Error       string `json:"error,omitempty"` // why the required backend is unavailable
 }
 
 // ProfileCaps declares capabilities for a profile (spec §5.2).
@@ -78,6 +90,7 @@ type SessionCreate struct {
 	AgentID         string `json:"agent_id"`
 	UserID          string `json:"user_id"`
 	ResumeSessionID string `json:"resume_session_id,omitempty"` // native session ID for resume
+	Fork            bool   `json:"fork,omitempty"`              // branch ResumeSessionID instead of continuing it
 	PromptProfile   string `json:"prompt_profile,omitempty"`
 }
 
@@ -87,6 +100,31 @@ type SessionCreated struct {
 	OK           bool   `json:"ok"`
 	Error        string `json:"error,omitempty"`
 	NativeHandle string `json:"native_handle,omitempty"`
+	Branch       string `json:"branch,omitempty"` // git branch of the session's worktree, if any
+}
+
+// SessionQueued is sent by the runtime while a session creation waits for a
+// free slot at max_sessions. It is re-sent whenever the position changes and
+// followed by SessionCreated once the session starts or the wait ends.
+type SessionQueued struct {
+	SessionID string `json:"session_id"`
+	Position  int    `json:"position"` // 1 = next to start
+}
+
+// SessionsAnnounce is sent by the runtime after every registration with the
+// sessions it holds, including those restored from its journal after a
+// restart. The hub closes those it no longer knows as open.
+type SessionsAnnounce struct {
+	Sessions []AnnouncedSession `json:"sessions"`
+}
+
+// AnnouncedSession is one session in a SessionsAnnounce.
+type AnnouncedSession struct {
+	SessionID    string `json:"session_id"`
+	AgentID      string `json:"agent_id"`
+	NativeHandle string `json:"native_handle,omitempty"`
+	Branch       string `json:"branch,omitempty"`
+	Responding   bool   `json:"responding,omitempty"` // a turn is in progress
 }
 
 // SessionClose is sent by either side to close a session.
@@ -125,7 +163,7 @@ type AgentOutput struct {
 	SessionID string `json:"session_id"`
 	MessageID string `json:"message_id,omitempty"` // hub-assigned
 	Seq       int64  `json:"seq"`                  // monotonic per session
-	Channel   string `json:"channel"`              // "stdout", "stderr", "system"
+	Channel   string `json:"channel"`              // "stdout", "stderr", "system", "diff"
 	Content   string `json:"content"`
 	Final     bool   `json:"final"` // true if this is the last chunk for this turn
 }
@@ -146,6 +184,43 @@ type TurnCompleted struct {
 	NativeHandle string `json:"native_handle,omitempty"` // agent's native session ID
 }
 
+// TurnDiff is the JSON content of a "diff" channel AgentOutput: the changes a
+// turn made to the files of a git-backed work dir.
+type TurnDiff struct {
+	Files     []FileChange `json:"files"`
+	Patch     string       `json:"patch,omitempty"`     // unified diff
+	Truncated bool         `json:"truncated,omitempty"` // Patch was cut at the size cap
+}
+
+// FileChange is one file listed in a TurnDiff.
+type FileChange struct {
+	Path      string `json:"path"`
+	OldPath   string `json:"old_path,omitempty"` // for renames
+	Status    string `json:"status"`             // "added", "modified", "deleted", "renamed"
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+// --- Workspace rollback ---
+
+// WorkspaceRollback asks the runtime to restore a session's work dir to the
+// checkpoint taken when a turn started.
+type WorkspaceRollback struct {
+	SessionID string `json:"session_id"`
+	RequestID string `json:"request_id"`
+	MessageID string `json:"message_id"` // user message that started the turn
+	Seq       int64  `json:"seq"`        // the message's seq, for the transcript
+}
+
+// WorkspaceRollbackAck reports the outcome of a WorkspaceRollback.
+type WorkspaceRollbackAck struct {
+	SessionID string `json:"session_id"`
+	RequestID string `json:"request_id"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+}
+
 // --- Stop / Cancel ---
 
 // StopRequest is sent by the hub to the runtime.
@@ -210,6 +285,24 @@ const (
 	TypeAgentConfigUpdate = "agent.config_update" // hub → runtime: apply config override
 	TypeAgentConfigAck    = "agent.config_ack"    // runtime → hub: acknowledge config update
 
+	// Agent provisioning (hub → runtime → hub)
+	TypeAgentProvision    = "agent.provision"     // hub → runtime: create, update or delete an agent definition
+	TypeAgentProvisionAck = "agent.provision_ack" // runtime → hub: outcome of the provisioning
+	TypeAgentsAnnounce    = "agents.announce"     // runtime → hub: agent list after it changed
+
+	// Security (runtime → hub)
+	TypeSecurityViolation = "security.violation" // runtime → hub: action refused by security config
+
+	// Session queue (runtime → hub → client)
+	TypeSessionQueued = "session.queued" // runtime → hub: creation waits for a free slot
+
+	// Session journal (runtime → hub)
+	TypeSessionsAnnounce = "sessions.announce" // runtime → hub: sessions held after registering
+
+	// Workspace checkpoints (hub → runtime → hub)
+	TypeWorkspaceRollback    = "workspace.rollback"     // hub → runtime: restore a turn's checkpoint
+	TypeWorkspaceRollbackAck = "workspace.rollback_ack" // runtime → hub: outcome of the rollback
+
 	// Native sessions (client → hub → runtime → hub → client)
 	TypeNativeSessionsList     = "native.sessions.list"     // client → hub → runtime
 	TypeNativeSessionsResponse = "native.sessions.response" // runtime → hub → client
@@ -242,6 +335,7 @@ type StoredMessage struct {
 	Direction string    `json:"direction"` // "user" or "agent"
 	Channel   string    `json:"channel"`
 	Content   string    `json:"content"`
+	AuthorID  string    `json:"author_id,omitempty"` // sender of a user message in a shared session
 	Timestamp time.Time `json:"ts"`
 }
 
@@ -311,6 +405,11 @@ type AgentLimits struct {
 	SessionTimeout string `json:"session_timeout,omitempty"` // duration string, e.g. "30m"
 	MaxOutputBytes int64  `json:"max_output_bytes,omitempty"`
 	IdleTimeout    string `json:"idle_timeout,omitempty"` // duration string, e.g. "5m"
+
+	// Per-session cgroup v2 limits. Zero leaves the current value unchanged.
+	CPUQuota       float64 `json:"cpu_quota,omitempty"` // CPUs, e.g. 1.5
+	MemoryMaxBytes int64   `json:"memory_max_bytes,omitempty"`
+	PidsMax        int64   `json:"pids_max,omitempty"`
 }
 
 // AgentConfigAck is the runtime's acknowledgment of a config update.
@@ -320,6 +419,49 @@ type AgentConfigAck struct {
 	Error   string `json:"error,omitempty"`
 }
 
+// Agent provisioning actions.
+const (
+	ProvisionCreate = "create"
+	ProvisionUpdate = "update"
+	ProvisionDelete = "delete"
+)
+
+// AgentProvision asks the runtime to create, replace or delete a full agent
+// definition. Config is the agent's runtime config block (as in
+// runtime-config.json) and is omitted for deletes.
+type AgentProvision struct {
+	RequestID string          `json:"request_id"`
+	Action    string          `json:"action"` // "create", "update", "delete"
+	AgentID   string          `json:"agent_id"`
+	Config    json.RawMessage `json:"config,omitempty"`
+}
+
+// AgentProvisionAck reports the outcome of an AgentProvision.
+type AgentProvisionAck struct {
+	RequestID string `json:"request_id"`
+	AgentID   string `json:"agent_id"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+}
+
+// AgentsAnnounce replaces the agent list a runtime registered in its hello,
+// after agents were provisioned or removed.
+type AgentsAnnounce struct {
+	Agents []AgentRegistration `json:"agents"`
+}
+
+// --- Security ---
+
+// SecurityViolation reports an action the runtime refused because of an
+// agent's security config, such as a work dir outside allowed_paths.
+type SecurityViolation struct {
+	SessionID string `json:"session_id,omitempty"` // empty for agent-level violations
+	AgentID   string `json:"agent_id"`
+	Kind      string `json:"kind"` // "work_dir" or "file"
+	Path      string `json:"path"`
+	Reason    string `json:"reason"`
+}
+
 // --- File transfer ---
 
 // FileMetadata describes a file being transferred.
@@ -69,6 +69,13 @@ type ResumeSeeder interface {
 	SetResumeSessionID(id string)
 }
 
+// ForkSeeder is an optional interface for agent sessions that can branch an
+// existing native session, leaving the original untouched. The new native
+// handle becomes available once the agent reports it.
+type ForkSeeder interface {
+	SetForkSessionID(id string)
+}
+
 // NativeHandleProvider is an optional interface for agent sessions that
 // expose their native session ID (e.g. Claude Code's session UUID,
 // Codex's thread ID). Used to report the handle back to the hub so
@@ -16,6 +16,7 @@ import (
 	"sync/atomic"
 
 	"github.com/amurg-ai/amurg/pkg/promptprofile"
+	"github.com/amurg-ai/amurg/runtime/internal/cgroup"
 	"github.com/amurg-ai/amurg/runtime/internal/config"
 )
 
@@ -39,13 +40,14 @@ func (a *ClaudeCodeAdapter) Start(ctx context.Context, cfg config.AgentConfig) (
 		ctx:      ctx,
 		cfg:      resolvedCfg,
 		security: cfg.Security,
+		cgroup:   cfg.Cgroup,
 		output:   make(chan Output, 64),
 	}
 	switch resolvedCfg.Transport {
 	case "", "stream-json":
 		return sess, nil
 	case "tmux":
-		return newClaudeTMuxSession(ctx, resolvedCfg, cfg.Security), nil
+		return newClaudeTMuxSession(ctx, resolvedCfg, cfg.Security, cfg.Cgroup), nil
 	default:
 		return nil, fmt.Errorf("unsupported claude transport %q", resolvedCfg.Transport)
 	}
@@ -58,8 +60,10 @@ type claudeCodeSession struct {
 	ctx            context.Context
 	cfg            config.ClaudeCodeConfig
 	security       *config.SecurityConfig
+	cgroup         string
 	sessionID      string // Claude Code's native session ID
 	resumeExplicit bool   // true only when SetResumeSessionID was called (explicit resume)
+	forkPending    bool   // resume sessionID with --fork-session until the fork's own ID is known
 	permHandler    func(tool, description, resource string) bool
 
 	cmd    *exec.Cmd
@@ -99,6 +103,7 @@ func (s *claudeCodeSession) startProcess() error {
 	if s.resumeExplicit {
 		sid = s.sessionID
 	}
+	fork := s.forkPending
 	s.mu.Unlock()
 
 	s.turnComplete.Store(false)
@@ -166,6 +171,9 @@ func (s *claudeCodeSession) startProcess() error {
 	// Resume with native session ID — only for explicit resumes.
 	if sid != "" {
 		args = append(args, "--resume", sid)
+		if fork {
+			args = append(args, "--fork-session")
+		}
 	}
 
 	cmd := exec.CommandContext(s.ctx, s.cfg.Command, args...)
@@ -173,20 +181,15 @@ func (s *claudeCodeSession) startProcess() error {
 	// Working directory — always resolved to a valid dir (home as fallback).
 	cmd.Dir = resolveWorkDir(s.cfg.WorkDir, s.security)
 
-	// Filter out env vars that trigger nested-session detection in Claude Code.
-	for _, e := range os.Environ() {
-		if strings.HasPrefix(e, "CLAUDECODE=") ||
-			strings.HasPrefix(e, "CLAUDE_CODE_ENTRYPOINT=") {
-			continue
-		}
-		cmd.Env = append(cmd.Env, e)
-	}
 	// Reinforce skip-permissions via env var in case the CLI flag alone isn't enough.
+	var extra []string
 	if skipPerms {
-		cmd.Env = append(cmd.Env, "CLAUDE_DANGEROUS_SKIP_PERMISSIONS=true")
+		extra = append(extra, "CLAUDE_DANGEROUS_SKIP_PERMISSIONS=true")
 	}
-	for k, v := range s.cfg.Env {
-		cmd.Env = append(cmd.Env, k+"="+v)
+	// Filter out env vars that trigger nested-session detection in Claude Code.
+	cmd.Env = agentEnv("claude-code", s.security, s.cfg.Env, extra, "CLAUDECODE", "CLAUDE_CODE_ENTRYPOINT")
+	if err := sandboxCmd(cmd, "claude-code", s.security, ""); err != nil {
+		return fmt.Errorf("sandbox: %w", err)
 	}
 
 	fmt.Fprintf(os.Stderr, "claude-code: spawning %s %s (dir=%s, resume=%v)\n",
@@ -205,7 +208,7 @@ func (s *claudeCodeSession) startProcess() error {
 		return fmt.Errorf("stderr pipe: %w", err)
 	}
 
-	if err := cmd.Start(); err != nil {
+	if err := cgroup.Start(cmd, s.cgroup); err != nil {
 		return fmt.Errorf("start claude process: %w", err)
 	}
 
@@ -346,6 +349,7 @@ func (s *claudeCodeSession) handleStreamEvent(line []byte) {
 			if event.SessionID != "" {
 				s.mu.Lock()
 				s.sessionID = event.SessionID
+				s.forkPending = false
 				s.mu.Unlock()
 			}
 			return // Don't emit init as output.
@@ -361,6 +365,7 @@ func (s *claudeCodeSession) handleStreamEvent(line []byte) {
 		if event.SessionID != "" {
 			s.mu.Lock()
 			s.sessionID = event.SessionID
+			s.forkPending = false
 			s.mu.Unlock()
 		}
 		// Signal turn completion — process stays alive for next message.
@@ -571,6 +576,10 @@ func (s *claudeCodeSession) UpdateSecurity(security *config.SecurityConfig) (res
 func (s *claudeCodeSession) NativeHandle() string {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if s.forkPending {
+		// sessionID is still the parent's; the fork gets its own ID on start.
+		return ""
+	}
 	return s.sessionID
 }
 
@@ -583,6 +592,16 @@ func (s *claudeCodeSession) SetResumeSessionID(id string) {
 	s.resumeExplicit = true
 }
 
+// SetForkSessionID makes the first Send() resume id with --fork-session, so
+// the conversation continues in a new Claude Code session.
+func (s *claudeCodeSession) SetForkSessionID(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessionID = id
+	s.resumeExplicit = true
+	s.forkPending = true
+}
+
 // LoadNativeHistory reads the native Claude Code session JSONL and emits
 // conversation history through the output channel. This pre-populates the
 // UI when resuming an existing session.
@@ -6,12 +6,12 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/amurg-ai/amurg/runtime/internal/cgroup"
 	"github.com/amurg-ai/amurg/runtime/internal/config"
 )
 
@@ -21,6 +21,7 @@ type claudeTMuxSession struct {
 	ctx            context.Context
 	cfg            config.ClaudeCodeConfig
 	security       *config.SecurityConfig
+	cgroup         string
 	sessionID      string
 	resumeExplicit bool
 
@@ -42,11 +43,12 @@ type claudeTMuxSession struct {
 	closeOnce   sync.Once
 }
 
-func newClaudeTMuxSession(ctx context.Context, cfg config.ClaudeCodeConfig, security *config.SecurityConfig) *claudeTMuxSession {
+func newClaudeTMuxSession(ctx context.Context, cfg config.ClaudeCodeConfig, security *config.SecurityConfig, cgroupDir string) *claudeTMuxSession {
 	return &claudeTMuxSession{
 		ctx:       ctx,
 		cfg:       cfg,
 		security:  security,
+		cgroup:    cgroupDir,
 		output:    make(chan Output, 256),
 		closeDone: make(chan struct{}),
 	}
@@ -91,8 +93,14 @@ func (s *claudeTMuxSession) ensureStarted() error {
 	}
 	s.mu.Unlock()
 
-	command := buildClaudeTMuxCommand(s.cfg, s.security, resumeID)
-	if err := tmuxCreateSession(s.sessionName, workDir, command); err != nil {
+	command, err := sandboxArgv("claude-code", s.security, workDir, buildClaudeTMuxCommand(s.cfg, s.security, resumeID))
+	if err != nil {
+		_ = os.RemoveAll(logDir)
+		return fmt.Errorf("sandbox: %w", err)
+	}
+	// The tmux server forks the pane, so the command joins the session's
+	// cgroup itself.
+	if err := tmuxCreateSession(s.sessionName, workDir, cgroup.Wrap(s.cgroup, command)); err != nil {
 		_ = os.RemoveAll(logDir)
 		return err
 	}
@@ -125,19 +133,13 @@ func (s *claudeTMuxSession) ensureStarted() error {
 
 func buildClaudeTMuxCommand(cfg config.ClaudeCodeConfig, security *config.SecurityConfig, resumeID string) []string {
 	args, skipPerms := buildClaudeTMuxArgs(cfg, security, resumeID)
-	command := []string{"env", "-u", "CLAUDECODE", "-u", "CLAUDE_CODE_ENTRYPOINT"}
+	// The pane inherits the tmux server's environment, so start from an empty
+	// one and pass exactly what the agent is allowed to see.
+	var extra []string
 	if skipPerms {
-		command = append(command, "CLAUDE_DANGEROUS_SKIP_PERMISSIONS=true")
-	}
-
-	keys := make([]string, 0, len(cfg.Env))
-	for key := range cfg.Env {
-		keys = append(keys, key)
-	}
-	sort.Strings(keys)
-	for _, key := range keys {
-		command = append(command, key+"="+cfg.Env[key])
+		extra = append(extra, "CLAUDE_DANGEROUS_SKIP_PERMISSIONS=true")
 	}
+	command := append([]string{"env", "-i"}, agentEnv("claude-code", security, cfg.Env, extra, "CLAUDECODE", "CLAUDE_CODE_ENTRYPOINT")...)
 
 	binary := cfg.Command
 	if binary == "" {
@@ -9,6 +9,7 @@ import (
 	"os/exec"
 	"sync"
 
+	"github.com/amurg-ai/amurg/runtime/internal/cgroup"
 	"github.com/amurg-ai/amurg/runtime/internal/config"
 )
 
@@ -26,9 +27,9 @@ func (a *CLIAdapter) Start(ctx context.Context, cfg config.AgentConfig) (AgentSe
 	if dir := resolveWorkDir(cliCfg.WorkDir, cfg.Security); dir != "" {
 		cmd.Dir = dir
 	}
-	cmd.Env = os.Environ()
-	for k, v := range cliCfg.Env {
-		cmd.Env = append(cmd.Env, k+"="+v)
+	cmd.Env = agentEnv("generic-cli", cfg.Security, cliCfg.Env, nil)
+	if err := sandboxCmd(cmd, "generic-cli", cfg.Security, ""); err != nil {
+		return nil, fmt.Errorf("sandbox: %w", err)
 	}
 
 	stdin, err := cmd.StdinPipe()
@@ -46,7 +47,7 @@ func (a *CLIAdapter) Start(ctx context.Context, cfg config.AgentConfig) (AgentSe
 		return nil, fmt.Errorf("stderr pipe: %w", err)
 	}
 
-	if err := cmd.Start(); err != nil {
+	if err := cgroup.Start(cmd, cfg.Cgroup); err != nil {
 		return nil, fmt.Errorf("start process: %w", err)
 	}
 
@@ -13,6 +13,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/amurg-ai/amurg/runtime/internal/cgroup"
 	"github.com/amurg-ai/amurg/runtime/internal/config"
 )
 
@@ -34,6 +35,7 @@ func (a *CodexAdapter) Start(ctx context.Context, cfg config.AgentConfig) (Agent
 		ctx:      ctx,
 		cfg:      *cxCfg,
 		security: cfg.Security,
+		cgroup:   cfg.Cgroup,
 		output:   make(chan Output, 64),
 	}
 	return sess, nil
@@ -160,6 +162,7 @@ type codexSession struct {
 	ctx      context.Context
 	cfg      config.CodexConfig
 	security *config.SecurityConfig
+	cgroup   string
 	threadID string // Codex thread ID for resume
 
 	output chan Output
@@ -232,8 +235,9 @@ func (s *codexSession) Send(ctx context.Context, input []byte) error {
 	}
 
 	// Working directory — validated with fallback.
-	if dir := resolveWorkDir(s.cfg.WorkDir, s.security); dir != "" {
-		args = append(args, "--cd", dir)
+	workDir := resolveWorkDir(s.cfg.WorkDir, s.security)
+	if workDir != "" {
+		args = append(args, "--cd", workDir)
 	}
 
 	// Additional writable directories.
@@ -245,9 +249,9 @@ func (s *codexSession) Send(ctx context.Context, input []byte) error {
 	args = append(args, string(input))
 
 	cmd := exec.CommandContext(ctx, s.cfg.Command, args...)
-	cmd.Env = os.Environ()
-	for k, v := range s.cfg.Env {
-		cmd.Env = append(cmd.Env, k+"="+v)
+	cmd.Env = agentEnv("codex", s.security, s.cfg.Env, nil)
+	if err := sandboxCmd(cmd, "codex", s.security, workDir, s.cfg.AdditionalDirs...); err != nil {
+		return fmt.Errorf("sandbox: %w", err)
 	}
 
 	stdout, err := cmd.StdoutPipe()
@@ -259,7 +263,7 @@ func (s *codexSession) Send(ctx context.Context, input []byte) error {
 		return fmt.Errorf("stderr pipe: %w", err)
 	}
 
-	if err := cmd.Start(); err != nil {
+	if err := cgroup.Start(cmd, s.cgroup); err != nil {
 		return fmt.Errorf("start codex process: %w", err)
 	}
 
@@ -13,6 +13,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/amurg-ai/amurg/runtime/internal/cgroup"
 	"github.com/amurg-ai/amurg/runtime/internal/config"
 )
 
@@ -34,6 +35,7 @@ func (a *GitHubCopilotAdapter) Start(ctx context.Context, cfg config.AgentConfig
 		ctx:      ctx,
 		cfg:      *copCfg,
 		security: cfg.Security,
+		cgroup:   cfg.Cgroup,
 		output:   make(chan Output, 64),
 	}
 	return sess, nil
@@ -113,6 +115,7 @@ type copilotSession struct {
 	ctx       context.Context
 	cfg       config.CopilotConfig
 	security  *config.SecurityConfig
+	cgroup    string
 	sessionID string // Copilot native session ID for --resume
 	resumeExplicit bool // true only when SetResumeSessionID was called
 
@@ -189,10 +192,7 @@ func (s *copilotSession) Send(ctx context.Context, input []byte) error {
 	// Working directory — always resolved to a valid dir (home as fallback).
 	cmd.Dir = resolveWorkDir(s.cfg.WorkDir, s.security)
 
-	cmd.Env = os.Environ()
-	for k, v := range s.cfg.Env {
-		cmd.Env = append(cmd.Env, k+"="+v)
-	}
+	cmd.Env = agentEnv("github-copilot", s.security, s.cfg.Env, nil)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -203,7 +203,7 @@ func (s *copilotSession) Send(ctx context.Context, input []byte) error {
 		return fmt.Errorf("stderr pipe: %w", err)
 	}
 
-	if err := cmd.Start(); err != nil {
+	if err := cgroup.Start(cmd, s.cgroup); err != nil {
 		return fmt.Errorf("start copilot process: %w", err)
 	}
 
@@ -12,6 +12,7 @@ import (
 	"sync"
 
 	"github.com/google/uuid"
+	"github.com/amurg-ai/amurg/runtime/internal/cgroup"
 	"github.com/amurg-ai/amurg/runtime/internal/config"
 )
 
@@ -49,9 +50,9 @@ func (a *ExternalAdapter) Start(ctx context.Context, cfg config.AgentConfig) (Ag
 	if dir := resolveWorkDir(extCfg.WorkDir, cfg.Security); dir != "" {
 		cmd.Dir = dir
 	}
-	cmd.Env = os.Environ()
-	for k, v := range extCfg.Env {
-		cmd.Env = append(cmd.Env, k+"="+v)
+	cmd.Env = agentEnv("external", cfg.Security, extCfg.Env, nil)
+	if err := sandboxCmd(cmd, "external", cfg.Security, ""); err != nil {
+		return nil, fmt.Errorf("sandbox: %w", err)
 	}
 
 	stdin, err := cmd.StdinPipe()
@@ -67,7 +68,7 @@ func (a *ExternalAdapter) Start(ctx context.Context, cfg config.AgentConfig) (Ag
 	// Redirect stderr to os.Stderr for adapter debugging.
 	cmd.Stderr = os.Stderr
 
-	if err := cmd.Start(); err != nil {
+	if err := cgroup.Start(cmd, cfg.Cgroup); err != nil {
 		return nil, fmt.Errorf("start external adapter: %w", err)
 	}
 
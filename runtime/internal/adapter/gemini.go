@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/amurg-ai/amurg/pkg/promptprofile"
+	"github.com/amurg-ai/amurg/runtime/internal/cgroup"
 	"github.com/amurg-ai/amurg/runtime/internal/config"
 )
 
@@ -41,6 +42,7 @@ func (a *GeminiCLIAdapter) Start(ctx context.Context, cfg config.AgentConfig) (A
 		ctx:      ctx,
 		cfg:      resolvedCfg,
 		security: cfg.Security,
+		cgroup:   cfg.Cgroup,
 		output:   make(chan Output, 64),
 		promptMD: combinedPrompt,
 	}
@@ -188,6 +190,7 @@ type geminiSession struct {
 	ctx       context.Context
 	cfg       config.GeminiCLIConfig
 	security  *config.SecurityConfig
+	cgroup    string
 	sessionID string // Gemini session UUID for --resume
 	promptMD  string
 
@@ -255,14 +258,14 @@ func (s *geminiSession) Send(ctx context.Context, input []byte) error {
 		cmd.Dir = dir
 	}
 
-	cmd.Env = os.Environ()
-	for k, v := range s.cfg.Env {
-		cmd.Env = append(cmd.Env, k+"="+v)
-	}
-
 	// System prompt file override.
+	var extra []string
 	if s.promptMD != "" {
-		cmd.Env = append(cmd.Env, "GEMINI_SYSTEM_MD="+s.promptMD)
+		extra = append(extra, "GEMINI_SYSTEM_MD="+s.promptMD)
+	}
+	cmd.Env = agentEnv("gemini-cli", s.security, s.cfg.Env, extra)
+	if err := sandboxCmd(cmd, "gemini-cli", s.security, "", s.cfg.IncludeDirs...); err != nil {
+		return fmt.Errorf("sandbox: %w", err)
 	}
 
 	stdout, err := cmd.StdoutPipe()
@@ -274,7 +277,7 @@ func (s *geminiSession) Send(ctx context.Context, input []byte) error {
 		return fmt.Errorf("stderr pipe: %w", err)
 	}
 
-	if err := cmd.Start(); err != nil {
+	if err := cgroup.Start(cmd, s.cgroup); err != nil {
 		return fmt.Errorf("start gemini process: %w", err)
 	}
 
@@ -4,11 +4,11 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
 	"os/exec"
 	"sync"
 	"time"
 
+	"github.com/amurg-ai/amurg/runtime/internal/cgroup"
 	"github.com/amurg-ai/amurg/runtime/internal/config"
 )
 
@@ -26,6 +26,7 @@ func (a *JobAdapter) Start(ctx context.Context, cfg config.AgentConfig) (AgentSe
 		cfg:      *jobCfg,
 		epID:     cfg.ID,
 		security: cfg.Security,
+		cgroup:   cfg.Cgroup,
 		output:   make(chan Output, 64),
 	}, nil
 }
@@ -34,6 +35,7 @@ type jobSession struct {
 	cfg      config.JobConfig
 	epID     string
 	security *config.SecurityConfig
+	cgroup   string
 	output   chan Output
 
 	mu       sync.Mutex
@@ -67,9 +69,10 @@ func (s *jobSession) Send(ctx context.Context, input []byte) error {
 	if dir := resolveWorkDir(s.cfg.WorkDir, s.security); dir != "" {
 		cmd.Dir = dir
 	}
-	cmd.Env = os.Environ()
-	for k, v := range s.cfg.Env {
-		cmd.Env = append(cmd.Env, k+"="+v)
+	cmd.Env = agentEnv("generic-job", s.security, s.cfg.Env, nil)
+	if err := sandboxCmd(cmd, "generic-job", s.security, ""); err != nil {
+		cancel()
+		return fmt.Errorf("sandbox: %w", err)
 	}
 
 	// Pass input via stdin.
@@ -91,7 +94,7 @@ func (s *jobSession) Send(ctx context.Context, input []byte) error {
 		return fmt.Errorf("stderr pipe: %w", err)
 	}
 
-	if err := cmd.Start(); err != nil {
+	if err := cgroup.Start(cmd, s.cgroup); err != nil {
 		cancel()
 		return fmt.Errorf("start job: %w", err)
 	}
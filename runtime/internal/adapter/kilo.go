@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/amurg-ai/amurg/pkg/promptprofile"
+	"github.com/amurg-ai/amurg/runtime/internal/cgroup"
 	"github.com/amurg-ai/amurg/runtime/internal/config"
 )
 
@@ -37,6 +38,7 @@ func (a *KiloAdapter) Start(ctx context.Context, cfg config.AgentConfig) (AgentS
 		ctx:      ctx,
 		cfg:      resolvedCfg,
 		security: cfg.Security,
+		cgroup:   cfg.Cgroup,
 		output:   make(chan Output, 64),
 	}
 	return sess, nil
@@ -166,6 +168,7 @@ type kiloSession struct {
 	ctx       context.Context
 	cfg       config.KiloConfig
 	security  *config.SecurityConfig
+	cgroup    string
 	sessionID string // Kilo session ID for --session resume
 
 	output chan Output
@@ -204,8 +207,9 @@ func (s *kiloSession) Send(ctx context.Context, input []byte) error {
 	}
 
 	// Working directory.
-	if dir := resolveWorkDir(s.cfg.WorkDir, s.security); dir != "" {
-		args = append(args, "--dir", dir)
+	workDir := resolveWorkDir(s.cfg.WorkDir, s.security)
+	if workDir != "" {
+		args = append(args, "--dir", workDir)
 	}
 
 	// Session continuity: use --session with session ID, fallback to --continue.
@@ -222,9 +226,9 @@ func (s *kiloSession) Send(ctx context.Context, input []byte) error {
 
 	cmd := exec.CommandContext(ctx, s.cfg.Command, args...)
 
-	cmd.Env = os.Environ()
-	for k, v := range s.cfg.Env {
-		cmd.Env = append(cmd.Env, k+"="+v)
+	cmd.Env = agentEnv("kilo-code", s.security, s.cfg.Env, nil)
+	if err := sandboxCmd(cmd, "kilo-code", s.security, workDir); err != nil {
+		return fmt.Errorf("sandbox: %w", err)
 	}
 
 	stdout, err := cmd.StdoutPipe()
@@ -236,7 +240,7 @@ func (s *kiloSession) Send(ctx context.Context, input []byte) error {
 		return fmt.Errorf("stderr pipe: %w", err)
 	}
 
-	if err := cmd.Start(); err != nil {
+	if err := cgroup.Start(cmd, s.cgroup); err != nil {
 		return fmt.Errorf("start kilo process: %w", err)
 	}
 
@@ -552,7 +556,7 @@ func (s *kiloSession) LoadNativeHistory() []Output {
 
 	// Use `kilo export <sessionID>` to get session data.
 	cmd := exec.Command("kilo", "export", sid)
-	cmd.Env = append(os.Environ(), "KILO_EPHEMERAL_MODE=true")
+	cmd.Env = agentEnv("kilo-code", s.security, s.cfg.Env, []string{"KILO_EPHEMERAL_MODE=true"})
 	out, err := cmd.Output()
 	if err != nil {
 		return nil
@@ -0,0 +1,100 @@
+package adapter
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/amurg-ai/amurg/runtime/internal/config"
+	"github.com/amurg-ai/amurg/runtime/internal/sandbox"
+)
+
+// profileStateDirs lists where each profile's CLI keeps its own sessions and
+// settings. They stay writable inside the sandbox so the agent can run.
+func profileStateDirs(profile string) []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	switch profile {
+	case "claude-code":
+		return []string{filepath.Join(home, ".claude"), filepath.Join(home, ".claude.json")}
+	case "codex":
+		return []string{codexHomeDir()}
+	case "gemini-cli":
+		if dir := os.Getenv("GEMINI_CLI_HOME"); dir != "" {
+			return []string{dir}
+		}
+		return []string{filepath.Join(home, ".gemini")}
+	case "kilo-code":
+		return []string{filepath.Join(home, ".config", "kilo"), filepath.Join(home, ".local", "share", "kilo")}
+	default:
+		return nil
+	}
+}
+
+// sandboxPolicy turns an agent's security config into a sandbox policy for a
+// process started in dir. Writes are allowed under allowed_paths (or dir when
+// none are set), extraRW, the profile's state dirs, the temp dir and the
+// sandbox's writable_paths; denied_paths are hidden.
+func sandboxPolicy(profile string, security *config.SecurityConfig, dir string, extraRW []string) sandbox.Policy {
+	var policy sandbox.Policy
+	if len(security.AllowedPaths) > 0 {
+		policy.ReadWrite = append(policy.ReadWrite, security.AllowedPaths...)
+	} else if dir != "" {
+		policy.ReadWrite = append(policy.ReadWrite, dir)
+	}
+	policy.ReadWrite = append(policy.ReadWrite, extraRW...)
+	policy.ReadWrite = append(policy.ReadWrite, profileStateDirs(profile)...)
+	policy.ReadWrite = append(policy.ReadWrite, os.TempDir())
+	if security.Sandbox != nil {
+		policy.ReadWrite = append(policy.ReadWrite, security.Sandbox.WritablePaths...)
+		policy.DenyNetwork = security.Sandbox.DenyNetwork
+	}
+	for i, p := range policy.ReadWrite {
+		policy.ReadWrite[i] = canonicalPath(p)
+	}
+	for _, p := range security.DeniedPaths {
+		policy.Deny = append(policy.Deny, canonicalPath(p))
+	}
+	return policy
+}
+
+// sandboxArgv wraps argv so it runs inside the sandbox configured for the
+// agent. argv is returned unchanged when no sandbox is configured.
+func sandboxArgv(profile string, security *config.SecurityConfig, dir string, argv []string, extraRW ...string) ([]string, error) {
+	if security == nil || security.Sandbox == nil {
+		return argv, nil
+	}
+	backend, err := sandbox.Resolve(security.Sandbox.Mode)
+	if err != nil || backend == "" {
+		return argv, err
+	}
+	return sandbox.Wrap(backend, sandboxPolicy(profile, security, dir, extraRW), dir, argv)
+}
+
+// sandboxCmd rewrites cmd to run inside the agent's sandbox. Call it after
+// cmd.Dir is set and before cmd.Start. dir is the agent's working directory
+// when it is passed as a flag rather than through cmd.Dir.
+func sandboxCmd(cmd *exec.Cmd, profile string, security *config.SecurityConfig, dir string, extraRW ...string) error {
+	if security == nil || security.Sandbox == nil {
+		return nil
+	}
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	if dir == "" {
+		dir = cmd.Dir
+	}
+	argv, err := sandboxArgv(profile, security, dir, append([]string{cmd.Path}, cmd.Args[1:]...), extraRW...)
+	if err != nil {
+		return err
+	}
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return err
+	}
+	cmd.Path = path
+	cmd.Args = argv
+	return nil
+}
@@ -0,0 +1,48 @@
+package adapter
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/amurg-ai/amurg/runtime/internal/config"
+)
+
+func TestSandboxPolicy(t *testing.T) {
+	dir := t.TempDir()
+	shared := t.TempDir()
+	security := &config.SecurityConfig{
+		DeniedPaths: []string{filepath.Join(dir, "secrets")},
+		Sandbox:     &config.SandboxConfig{Mode: "auto", DenyNetwork: true, WritablePaths: []string{shared}},
+	}
+
+	policy := sandboxPolicy("generic-cli", security, dir, nil)
+	for _, want := range []string{canonicalPath(dir), canonicalPath(shared), canonicalPath(os.TempDir())} {
+		if !slices.Contains(policy.ReadWrite, want) {
+			t.Errorf("read-write paths %v missing %q", policy.ReadWrite, want)
+		}
+	}
+	if !policy.DenyNetwork || len(policy.Deny) != 1 {
+		t.Errorf("unexpected policy %+v", policy)
+	}
+
+	// allowed_paths replace the work dir as the writable area.
+	security.AllowedPaths = []string{shared}
+	policy = sandboxPolicy("generic-cli", security, dir, nil)
+	if slices.Contains(policy.ReadWrite, canonicalPath(dir)) {
+		t.Errorf("work dir writable despite allowed_paths: %v", policy.ReadWrite)
+	}
+}
+
+func TestSandboxCmdWithoutSandbox(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "true")
+	args := slices.Clone(cmd.Args)
+	if err := sandboxCmd(cmd, "generic-cli", &config.SecurityConfig{}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cmd.Args, args) {
+		t.Fatalf("command rewritten without a sandbox: %v", cmd.Args)
+	}
+}
@@ -0,0 +1,182 @@
+package adapter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/amurg-ai/amurg/runtime/internal/config"
+)
+
+// Violation describes an action refused by an agent's security config.
+type Violation struct {
+	Kind   string // "work_dir" or "file"
+	Path   string
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%s %q %s", v.Kind, v.Path, v.Reason)
+}
+
+// CheckPath reports whether path is permitted by the security config. A path
+// under any denied path is refused; when allowed paths are configured, the
+// path must also be under one of them. Denied paths win over allowed paths.
+// Symlinks are resolved on both sides so a link cannot step outside the rules.
+func CheckPath(security *config.SecurityConfig, kind, path string) error {
+	if security == nil || (len(security.AllowedPaths) == 0 && len(security.DeniedPaths) == 0) {
+		return nil
+	}
+	p := canonicalPath(path)
+	for _, denied := range security.DeniedPaths {
+		if pathWithin(p, canonicalPath(denied)) {
+			return &Violation{Kind: kind, Path: path, Reason: "is under denied path " + denied}
+		}
+	}
+	if len(security.AllowedPaths) == 0 {
+		return nil
+	}
+	for _, allowed := range security.AllowedPaths {
+		if pathWithin(p, canonicalPath(allowed)) {
+			return nil
+		}
+	}
+	return &Violation{Kind: kind, Path: path, Reason: "is outside allowed_paths"}
+}
+
+// CheckUploadPath reports whether an uploaded file the runtime stored under
+// storeDir may be handed to an agent. The runtime's own file store is
+// implicitly allowed: allowed_paths say where the agent may work, not where
+// uploads land. Denied paths still apply.
+func CheckUploadPath(security *config.SecurityConfig, storeDir, path string) error {
+	if security != nil && storeDir != "" && pathWithin(canonicalPath(path), canonicalPath(storeDir)) {
+		security = &config.SecurityConfig{DeniedPaths: security.DeniedPaths}
+	}
+	return CheckPath(security, "file", path)
+}
+
+// CheckAgentPaths validates the directories an agent session would be given —
+// its effective working directory and any extra directories its profile grants
+// (codex additional_dirs, gemini include_directories) — against the path rules.
+func CheckAgentPaths(cfg config.AgentConfig) error {
+	dir, _ := effectiveWorkDir(cfg.WorkDir(), cfg.Security)
+	if dir != "" {
+		if err := CheckPath(cfg.Security, "work_dir", dir); err != nil {
+			return err
+		}
+	}
+	var extra []string
+	if cfg.Codex != nil {
+		extra = append(extra, cfg.Codex.AdditionalDirs...)
+	}
+	if cfg.Gemini != nil {
+		extra = append(extra, cfg.Gemini.IncludeDirs...)
+	}
+	for _, d := range extra {
+		if err := CheckPath(cfg.Security, "work_dir", d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func canonicalPath(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[1:])
+		}
+	}
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		return resolved
+	}
+	// The path may not exist yet; resolve its closest existing parent.
+	dir, base := filepath.Split(p)
+	if dir != "" && dir != p {
+		if resolved, err := filepath.EvalSymlinks(filepath.Clean(dir)); err == nil {
+			return filepath.Join(resolved, base)
+		}
+	}
+	return filepath.Clean(p)
+}
+
+func pathWithin(p, root string) bool {
+	if p == root {
+		return true
+	}
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// baseEnv lists the variables every agent process gets from the runtime's
+// environment so that ordinary tools keep working. Entries ending in "*" match
+// a prefix.
+var baseEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "COLORTERM",
+	"LANG", "LANGUAGE", "LC_*", "TZ", "TMPDIR", "TMP", "TEMP", "XDG_*",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy",
+	"SSL_CERT_FILE", "SSL_CERT_DIR",
+	// Windows
+	"SYSTEMROOT", "SYSTEMDRIVE", "COMSPEC", "PATHEXT", "WINDIR",
+	"USERPROFILE", "APPDATA", "LOCALAPPDATA", "PROGRAMDATA", "PROGRAMFILES",
+}
+
+// profileEnv lists the credentials and settings each profile's CLI reads from
+// the environment. They are passed through when no env_whitelist is set.
+var profileEnv = map[string][]string{
+	"claude-code":    {"ANTHROPIC_*", "CLAUDE_*"},
+	"codex":          {"OPENAI_*", "CODEX_*"},
+	"github-copilot": {"GH_TOKEN", "GITHUB_TOKEN", "COPILOT_*"},
+	"gemini-cli":     {"GEMINI_*", "GOOGLE_*"},
+	"kilo-code":      {"KILO_*"},
+}
+
+// agentEnv builds the environment for an agent process. It starts from the
+// runtime's own environment filtered to baseEnv plus either the agent's
+// env_whitelist or, if none is set, the profile's default credentials, then
+// adds the explicit env from the agent's config and any extra "KEY=VALUE"
+// entries. Variables named in exclude are dropped from the inherited part.
+func agentEnv(profile string, security *config.SecurityConfig, env map[string]string, extra []string, exclude ...string) []string {
+	allow := append([]string{}, baseEnv...)
+	if security != nil && len(security.EnvWhitelist) > 0 {
+		allow = append(allow, security.EnvWhitelist...)
+	} else {
+		allow = append(allow, profileEnv[profile]...)
+	}
+
+	var out []string
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		if envMatches(key, exclude) || !envMatches(key, allow) {
+			continue
+		}
+		out = append(out, kv)
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		out = append(out, k+"="+env[k])
+	}
+	return append(out, extra...)
+}
+
+func envMatches(key string, patterns []string) bool {
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == p {
+			return true
+		}
+	}
+	return false
+}
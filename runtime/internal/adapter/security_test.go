@@ -0,0 +1,114 @@
+package adapter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/amurg-ai/amurg/runtime/internal/config"
+)
+
+func TestCheckPath(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	secrets := filepath.Join(project, "secrets")
+	other := filepath.Join(root, "other")
+	for _, dir := range []string{secrets, other} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := filepath.Join(project, "escape")
+	if err := os.Symlink(other, link); err != nil {
+		t.Fatal(err)
+	}
+
+	security := &config.SecurityConfig{AllowedPaths: []string{project}, DeniedPaths: []string{secrets}}
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{project, true},
+		{filepath.Join(project, "src", "main.go"), true},
+		{secrets, false},
+		{filepath.Join(secrets, "key.pem"), false},
+		{other, false},
+		{project + "-sibling", false},
+		{filepath.Join(project, "..", "other"), false},
+		{link, false}, // symlink pointing outside allowed_paths
+	}
+	for _, tt := range tests {
+		err := CheckPath(security, "work_dir", tt.path)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckPath(%q) = %v, want ok=%v", tt.path, err, tt.ok)
+		}
+		var v *Violation
+		if err != nil && (!errors.As(err, &v) || v.Kind != "work_dir") {
+			t.Errorf("CheckPath(%q) returned %T, want *Violation", tt.path, err)
+		}
+	}
+
+	if err := CheckPath(nil, "file", other); err != nil {
+		t.Errorf("nil security should allow everything, got %v", err)
+	}
+	if err := CheckPath(&config.SecurityConfig{DeniedPaths: []string{secrets}}, "file", other); err != nil {
+		t.Errorf("denied_paths alone should allow other paths, got %v", err)
+	}
+}
+
+func TestCheckAgentPaths(t *testing.T) {
+	project := t.TempDir()
+	other := t.TempDir()
+	cfg := config.AgentConfig{
+		Profile:  "codex",
+		Codex:    &config.CodexConfig{WorkDir: project},
+		Security: &config.SecurityConfig{AllowedPaths: []string{project}},
+	}
+	if err := CheckAgentPaths(cfg); err != nil {
+		t.Fatalf("work dir inside allowed_paths: %v", err)
+	}
+
+	cfg.Codex.AdditionalDirs = []string{other}
+	if err := CheckAgentPaths(cfg); err == nil {
+		t.Fatal("expected additional_dirs outside allowed_paths to be refused")
+	}
+
+	cfg.Codex.AdditionalDirs = nil
+	cfg.Security.Cwd = other
+	if err := CheckAgentPaths(cfg); err == nil {
+		t.Fatal("expected security cwd outside allowed_paths to be refused")
+	}
+}
+
+func TestAgentEnv(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin")
+	t.Setenv("LC_ALL", "C")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "aws-secret")
+	t.Setenv("MY_TOKEN", "mine")
+	t.Setenv("CLAUDECODE", "1")
+
+	// Without a whitelist the profile's own credentials pass through.
+	env := agentEnv("claude-code", nil, map[string]string{"FOO": "bar"}, []string{"EXTRA=1"}, "CLAUDECODE")
+	for _, want := range []string{"PATH=/usr/bin", "LC_ALL=C", "ANTHROPIC_API_KEY=sk-ant", "FOO=bar", "EXTRA=1"} {
+		if !slices.Contains(env, want) {
+			t.Errorf("env missing %q", want)
+		}
+	}
+	for _, leaked := range []string{"AWS_SECRET_ACCESS_KEY=aws-secret", "MY_TOKEN=mine", "CLAUDECODE=1"} {
+		if slices.Contains(env, leaked) {
+			t.Errorf("env leaked %q", leaked)
+		}
+	}
+
+	// A whitelist replaces the profile defaults.
+	env = agentEnv("claude-code", &config.SecurityConfig{EnvWhitelist: []string{"MY_*"}}, nil, nil)
+	if !slices.Contains(env, "MY_TOKEN=mine") || !slices.Contains(env, "PATH=/usr/bin") {
+		t.Errorf("whitelisted variables missing: %v", env)
+	}
+	if slices.Contains(env, "ANTHROPIC_API_KEY=sk-ant") {
+		t.Error("profile default passed through despite env_whitelist")
+	}
+}
@@ -13,21 +13,30 @@ import (
 // or not set. Always returns a non-empty directory so the agent process
 // never inherits the runtime daemon's arbitrary CWD.
 func resolveWorkDir(profileWorkDir string, security *config.SecurityConfig) string {
-	workDir := profileWorkDir
+	dir, configured := effectiveWorkDir(profileWorkDir, security)
+	if configured != "" && dir != configured {
+		fmt.Fprintf(os.Stderr, "WARNING: work_dir %q does not exist, falling back to home directory\n", configured)
+	}
+	return dir
+}
+
+// effectiveWorkDir returns the directory resolveWorkDir would use, together
+// with the configured directory it was derived from (empty if none).
+func effectiveWorkDir(profileWorkDir string, security *config.SecurityConfig) (dir, configured string) {
+	configured = profileWorkDir
 	if security != nil && security.Cwd != "" {
-		workDir = security.Cwd
+		configured = security.Cwd
 	}
 
-	if workDir != "" {
-		if info, err := os.Stat(workDir); err == nil && info.IsDir() {
-			return workDir
+	if configured != "" {
+		if info, err := os.Stat(configured); err == nil && info.IsDir() {
+			return configured, configured
 		}
-		fmt.Fprintf(os.Stderr, "WARNING: work_dir %q does not exist, falling back to home directory\n", workDir)
 	}
 
 	// No work_dir configured or configured path doesn't exist — use home.
 	if home, err := os.UserHomeDir(); err == nil {
-		return home
+		return home, configured
 	}
-	return ""
+	return "", configured
 }
@@ -0,0 +1,77 @@
+// Package cgroup confines agent sessions to per-session cgroup v2 subtrees
+// with CPU, memory and process-count limits. The runtime creates the subtrees
+// under its own cgroup, which must be delegated to it (for example with
+// Delegate=yes in a systemd unit). Only Linux is supported.
+package cgroup
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// Limits are the resource limits for one session. Zero means unlimited.
+type Limits struct {
+	CPUQuota  float64 // CPUs, e.g. 1.5
+	MemoryMax int64   // bytes
+	PidsMax   int64
+}
+
+// IsZero reports whether no limit is set.
+func (l Limits) IsZero() bool {
+	return l.CPUQuota <= 0 && l.MemoryMax <= 0 && l.PidsMax <= 0
+}
+
+// Events counts how often the kernel enforced a session's limits.
+type Events struct {
+	OOMKills  uint64 // processes killed for exceeding memory.max
+	Throttled uint64 // CPU periods in which the session was throttled
+	PidsMax   uint64 // forks refused by pids.max
+}
+
+// Manager creates session cgroups. It prepares the runtime's cgroup the first
+// time a session needs limits, so runtimes that never set limits are left
+// untouched. A failed preparation is retried by the next session.
+type Manager struct {
+	logger *slog.Logger
+	setup  func() (string, error)
+
+	mu      sync.Mutex
+	base    string // directory session cgroups are created in; empty until set up
+	lastErr error
+}
+
+// NewManager creates a cgroup manager.
+func NewManager(logger *slog.Logger) *Manager {
+	return &Manager{logger: logger.With("component", "cgroup"), setup: setup}
+}
+
+// Create makes a cgroup named name for a session and applies limits.
+func (m *Manager) Create(name string, limits Limits) (*Group, error) {
+	m.mu.Lock()
+	if m.base == "" {
+		base, err := m.setup()
+		if err != nil {
+			if m.lastErr == nil || m.lastErr.Error() != err.Error() {
+				m.logger.Warn("cgroup limits unavailable", "error", err)
+			}
+			m.lastErr = err
+			m.mu.Unlock()
+			return nil, err
+		}
+		m.base, m.lastErr = base, nil
+		m.logger.Info("cgroup limits enabled", "base", base)
+	}
+	base := m.base
+	m.mu.Unlock()
+	return create(base, name, limits)
+}
+
+// Group is one session's cgroup.
+type Group struct {
+	path string
+}
+
+// Path returns the cgroup's directory in the cgroup filesystem.
+func (g *Group) Path() string {
+	return g.path
+}
@@ -0,0 +1,263 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	mountPoint  = "/sys/fs/cgroup"
+	runtimeLeaf = "runtime"  // leaf the runtime process moves into
+	sessionsDir = "sessions" // parent of the per-session cgroups
+	cpuPeriod   = 100000     // cpu.max period in microseconds
+)
+
+// controllers are the cgroup v2 controllers session limits need.
+var controllers = []string{"cpu", "memory", "pids"}
+
+// setup prepares the runtime's cgroup for session subtrees and returns the
+// directory session cgroups are created in.
+func setup() (string, error) {
+	if _, err := os.Stat(filepath.Join(mountPoint, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("cgroup v2 is not mounted at %s", mountPoint)
+	}
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", fmt.Errorf("read own cgroup: %w", err)
+	}
+	own := ""
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if rest, ok := strings.CutPrefix(line, "0::"); ok {
+			own = rest
+		}
+	}
+	if own == "" {
+		return "", errors.New("cgroup v2 unified hierarchy not found")
+	}
+	base := filepath.Join(mountPoint, own)
+	if filepath.Base(base) == runtimeLeaf {
+		// Already moved by an earlier setup in this cgroup.
+		base = filepath.Dir(base)
+	}
+
+	available, err := os.ReadFile(filepath.Join(base, "cgroup.controllers"))
+	if err != nil {
+		return "", fmt.Errorf("read available controllers: %w", err)
+	}
+	var enable []string
+	for _, c := range controllers {
+		if bytes.Contains(append(append([]byte(" "), bytes.TrimSpace(available)...), ' '), []byte(" "+c+" ")) {
+			enable = append(enable, c)
+		}
+	}
+	if len(enable) == 0 {
+		return "", fmt.Errorf("none of the %s controllers are delegated to %s", strings.Join(controllers, ", "), base)
+	}
+
+	// A cgroup that hands controllers to children may not hold processes
+	// itself, so the runtime, along with everything it started before the
+	// first limited session (unconfined sessions, a tmux server), moves into
+	// a leaf of its own.
+	leaf := filepath.Join(base, runtimeLeaf)
+	if err := os.Mkdir(leaf, 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+		return "", fmt.Errorf("create runtime cgroup: %w", err)
+	}
+	if err := moveProcs(base, leaf); err != nil {
+		return "", fmt.Errorf("move runtime into %s: %w", leaf, err)
+	}
+	if err := enableControllers(base, enable); err != nil {
+		return "", fmt.Errorf("%w (does %s hold other processes? run the runtime in its own delegated cgroup, e.g. systemd Delegate=yes)", err, base)
+	}
+
+	sessions := filepath.Join(base, sessionsDir)
+	if err := os.Mkdir(sessions, 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+		return "", fmt.Errorf("create sessions cgroup: %w", err)
+	}
+	if err := enableControllers(sessions, enable); err != nil {
+		return "", err
+	}
+	return sessions, nil
+}
+
+// moveProcs moves every process in from into to. Processes forked while it
+// runs land in from, so it repeats until from is empty.
+func moveProcs(from, to string) error {
+	for range 10 {
+		data, err := os.ReadFile(filepath.Join(from, "cgroup.procs"))
+		if err != nil {
+			return err
+		}
+		pids := strings.Fields(string(data))
+		if len(pids) == 0 {
+			return nil
+		}
+		for _, pid := range pids {
+			// A process that exited in the meantime needs no moving.
+			if err := writeFile(to, "cgroup.procs", pid); err != nil && !errors.Is(err, syscall.ESRCH) {
+				return fmt.Errorf("move pid %s: %w", pid, err)
+			}
+		}
+	}
+	return fmt.Errorf("processes keep appearing in %s", from)
+}
+
+func enableControllers(dir string, enable []string) error {
+	for _, c := range enable {
+		if err := writeFile(dir, "cgroup.subtree_control", "+"+c); err != nil {
+			return fmt.Errorf("enable %s controller in %s: %w", c, dir, err)
+		}
+	}
+	return nil
+}
+
+func create(base, name string, limits Limits) (*Group, error) {
+	dir := filepath.Join(base, filepath.Base(name))
+	if err := os.Mkdir(dir, 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("create session cgroup: %w", err)
+	}
+	g := &Group{path: dir}
+	if err := g.SetLimits(limits); err != nil {
+		_ = g.Remove()
+		return nil, err
+	}
+	return g, nil
+}
+
+// SetLimits writes limits to the cgroup. It can be called while processes run.
+func (g *Group) SetLimits(limits Limits) error {
+	cpu := "max " + strconv.Itoa(cpuPeriod)
+	if limits.CPUQuota > 0 {
+		cpu = fmt.Sprintf("%d %d", max(int64(limits.CPUQuota*cpuPeriod), 1000), cpuPeriod)
+	}
+	memory, swap := "max", "max"
+	if limits.MemoryMax > 0 {
+		// Without a swap limit the memory limit could be sidestepped by swapping.
+		memory, swap = strconv.FormatInt(limits.MemoryMax, 10), "0"
+	}
+	pids := "max"
+	if limits.PidsMax > 0 {
+		pids = strconv.FormatInt(limits.PidsMax, 10)
+	}
+
+	for _, w := range []struct {
+		file, value string
+		set         bool
+	}{
+		{"cpu.max", cpu, limits.CPUQuota > 0},
+		{"memory.max", memory, limits.MemoryMax > 0},
+		{"pids.max", pids, limits.PidsMax > 0},
+	} {
+		if err := writeFile(g.path, w.file, w.value); err != nil && (w.set || !errors.Is(err, fs.ErrNotExist)) {
+			return fmt.Errorf("set %s: %w", w.file, err)
+		}
+	}
+	// Swap accounting may be disabled; the memory limit still applies.
+	_ = writeFile(g.path, "memory.swap.max", swap)
+	return nil
+}
+
+// Events reads how often the kernel has enforced the cgroup's limits.
+func (g *Group) Events() (Events, error) {
+	var ev Events
+	var firstErr error
+	for _, r := range []struct {
+		file, key string
+		dst       *uint64
+	}{
+		{"memory.events", "oom_kill", &ev.OOMKills},
+		{"cpu.stat", "nr_throttled", &ev.Throttled},
+		{"pids.events", "max", &ev.PidsMax},
+	} {
+		v, err := readKey(filepath.Join(g.path, r.file), r.key)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) && firstErr == nil {
+			firstErr = err
+		}
+		*r.dst = v
+	}
+	return ev, firstErr
+}
+
+// Remove kills any processes left in the cgroup and deletes it.
+func (g *Group) Remove() error {
+	_ = writeFile(g.path, "cgroup.kill", "1")
+	var err error
+	for range 20 {
+		if err = os.Remove(g.path); err == nil || errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond) // killed processes take a moment to exit
+	}
+	return fmt.Errorf("remove cgroup %s: %w", g.path, err)
+}
+
+// Start starts cmd directly inside the cgroup at dir. An empty dir starts it
+// normally.
+func Start(cmd *exec.Cmd, dir string) error {
+	if dir == "" {
+		return cmd.Start()
+	}
+	fd, err := syscall.Open(dir, syscall.O_DIRECTORY|syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("open cgroup %s: %w", dir, err)
+	}
+	defer func() { _ = syscall.Close(fd) }()
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = fd
+	return cmd.Start()
+}
+
+// Wrap prefixes argv with a shell that joins the cgroup at dir before it execs
+// the command, for processes started by another program such as tmux. An
+// empty dir returns argv unchanged.
+func Wrap(dir string, argv []string) []string {
+	if dir == "" {
+		return argv
+	}
+	return append([]string{"/bin/sh", "-c", `echo $$ > "$1/cgroup.procs" && shift && exec "$@"`, "sh", dir}, argv...)
+}
+
+// writeFile writes a cgroup interface file. It never creates files: a missing
+// file means the controller is not enabled.
+func writeFile(dir, name, value string) error {
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(value)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// readKey returns the value of key in a flat-keyed cgroup file.
+func readKey(path, key string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), " ")
+		if ok && k == key {
+			return strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+		}
+	}
+	return 0, sc.Err()
+}
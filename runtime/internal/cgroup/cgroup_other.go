@@ -0,0 +1,29 @@
+//go:build !linux
+
+package cgroup
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errUnsupported = errors.New("cgroup limits are only supported on Linux")
+
+func setup() (string, error) { return "", errUnsupported }
+
+func create(string, string, Limits) (*Group, error) { return nil, errUnsupported }
+
+// SetLimits is unsupported on this platform.
+func (g *Group) SetLimits(Limits) error { return errUnsupported }
+
+// Events is unsupported on this platform.
+func (g *Group) Events() (Events, error) { return Events{}, errUnsupported }
+
+// Remove is a no-op on this platform.
+func (g *Group) Remove() error { return nil }
+
+// Start starts cmd; dir is ignored on this platform.
+func Start(cmd *exec.Cmd, dir string) error { return cmd.Start() }
+
+// Wrap returns argv unchanged on this platform.
+func Wrap(dir string, argv []string) []string { return argv }
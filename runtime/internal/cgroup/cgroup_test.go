@@ -0,0 +1,143 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeGroup returns a group backed by a plain directory holding the files a
+// cgroup with the cpu, memory and pids controllers exposes.
+func fakeGroup(t *testing.T) *Group {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"cpu.max", "memory.max", "memory.swap.max", "pids.max", "cgroup.procs"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Group{path: dir}
+}
+
+func readFile(t *testing.T, g *Group, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(g.path, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSetLimits(t *testing.T) {
+	g := fakeGroup(t)
+	if err := g.SetLimits(Limits{CPUQuota: 1.5, MemoryMax: 512 << 20, PidsMax: 64}); err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{
+		"cpu.max":         "150000 100000",
+		"memory.max":      "536870912",
+		"memory.swap.max": "0",
+		"pids.max":        "64",
+	} {
+		if got := readFile(t, g, file); got != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+
+	// Clearing limits writes "max" back.
+	if err := g.SetLimits(Limits{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, g, "cpu.max"); got != "max 100000" {
+		t.Errorf("cpu.max = %q after clearing", got)
+	}
+	if got := readFile(t, g, "pids.max"); got != "max" {
+		t.Errorf("pids.max = %q after clearing", got)
+	}
+}
+
+func TestSetLimits_MissingController(t *testing.T) {
+	g := &Group{path: t.TempDir()}
+	if err := g.SetLimits(Limits{}); err != nil {
+		t.Fatalf("clearing limits without controllers should succeed: %v", err)
+	}
+	if err := g.SetLimits(Limits{PidsMax: 10}); err == nil {
+		t.Fatal("expected error when pids controller is missing")
+	}
+}
+
+func TestEvents(t *testing.T) {
+	g := fakeGroup(t)
+	files := map[string]string{
+		"memory.events": "low 0\nhigh 0\nmax 12\noom 3\noom_kill 2\noom_group_kill 0\n",
+		"cpu.stat":      "usage_usec 1000\nnr_periods 50\nnr_throttled 7\nthrottled_usec 900\n",
+		"pids.events":   "max 4\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(g.path, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ev, err := g.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev != (Events{OOMKills: 2, Throttled: 7, PidsMax: 4}) {
+		t.Errorf("Events() = %+v", ev)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	if argv := Wrap("", []string{"claude"}); len(argv) != 1 {
+		t.Errorf("Wrap without cgroup changed argv: %v", argv)
+	}
+
+	g := fakeGroup(t)
+	argv := Wrap(g.path, []string{"echo", "hello"})
+	out, err := exec.Command(argv[0], argv[1:]...).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(out)) != "hello" {
+		t.Errorf("wrapped command output = %q", out)
+	}
+	if pid := strings.TrimSpace(readFile(t, g, "cgroup.procs")); pid == "" {
+		t.Error("wrapped command did not join the cgroup")
+	}
+}
+
+func TestManagerRetriesFailedSetup(t *testing.T) {
+	base := t.TempDir()
+	calls := 0
+	m := NewManager(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	m.setup = func() (string, error) {
+		calls++
+		if calls == 1 {
+			return "", errors.New("device or resource busy")
+		}
+		return base, nil
+	}
+
+	if _, err := m.Create("s1", Limits{}); err == nil {
+		t.Fatal("expected the first setup failure to be returned")
+	}
+	for _, name := range []string{"s2", "s3"} {
+		g, err := m.Create(name, Limits{})
+		if err != nil {
+			t.Fatalf("Create %s: %v", name, err)
+		}
+		if g.Path() != filepath.Join(base, name) {
+			t.Fatalf("unexpected path %q", g.Path())
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("expected setup to be retried once and then kept, got %d calls", calls)
+	}
+}
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"text/tabwriter"
@@ -9,6 +8,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/amurg-ai/amurg/pkg/cli"
+	"github.com/amurg-ai/amurg/runtime/internal/config"
 	"github.com/amurg-ai/amurg/runtime/internal/wizard"
 )
 
@@ -80,13 +80,8 @@ func runAgentsAdd(cmd *cobra.Command, args []string) error {
 	ep := w.ConfigureAgent(len(cfg.Agents))
 	cfg.Agents = append(cfg.Agents, ep)
 
-	data, err := json.MarshalIndent(cfg, "", "  ")
-	if err != nil {
-		return fmt.Errorf("marshal config: %w", err)
-	}
-
-	if err := os.WriteFile(configPath, append(data, '\n'), 0600); err != nil {
-		return fmt.Errorf("write config: %w", err)
+	if err := config.Save(configPath, cfg); err != nil {
+		return err
 	}
 
 	_, _ = fmt.Fprintf(os.Stdout, "Agent %q added to %s\n", ep.ID, configPath)
@@ -117,13 +112,8 @@ func runAgentsRemove(cmd *cobra.Command, args []string) error {
 
 	cfg.Agents = filtered
 
-	data, err := json.MarshalIndent(cfg, "", "  ")
-	if err != nil {
-		return fmt.Errorf("marshal config: %w", err)
-	}
-
-	if err := os.WriteFile(configPath, append(data, '\n'), 0600); err != nil {
-		return fmt.Errorf("write config: %w", err)
+	if err := config.Save(configPath, cfg); err != nil {
+		return err
 	}
 
 	_, _ = fmt.Fprintf(os.Stdout, "Agent %q removed from %s\n", targetID, configPath)
@@ -33,6 +33,7 @@ func NewRootCmd(v string) *cobra.Command {
 	root.AddCommand(newConfigCmd())
 	root.AddCommand(newAttachCmd())
 	root.AddCommand(newUpdateCmd())
+	root.AddCommand(newSandboxExecCmd())
 
 	root.PersistentFlags().StringP("config", "c", "", "path to config file")
 
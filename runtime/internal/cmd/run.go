@@ -55,6 +55,7 @@ func runRun(cmd *cobra.Command, args []string) error {
 
 	// Create and run the runtime.
 	rt := runtime.New(cfg, logger, bus)
+	rt.SetConfigPath(configPath)
 
 	// Start IPC server (non-fatal if it fails).
 	socketPath := daemon.SocketPath()
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/amurg-ai/amurg/runtime/internal/sandbox"
+)
+
+// newSandboxExecCmd is the internal entry point the runtime re-executes to
+// start an agent process under a Landlock policy.
+func newSandboxExecCmd() *cobra.Command {
+	var policyJSON string
+	cmd := &cobra.Command{
+		Use:    sandbox.ExecCommand + " --policy <json> -- <command> [args...]",
+		Short:  "Run a command under a Landlock sandbox policy (internal)",
+		Hidden: true,
+		Args:   cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var policy sandbox.Policy
+			if err := json.Unmarshal([]byte(policyJSON), &policy); err != nil {
+				return fmt.Errorf("parse sandbox policy: %w", err)
+			}
+			return sandbox.Exec(policy, args)
+		},
+	}
+	cmd.Flags().StringVar(&policyJSON, "policy", "{}", "sandbox policy as JSON")
+	cmd.Flags().SetInterspersed(false)
+	return cmd
+}
@@ -34,7 +34,11 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		_, _ = fmt.Fprintf(os.Stdout, "Runtime:  %s\n", status.RuntimeID)
 		_, _ = fmt.Fprintf(os.Stdout, "Hub:      %s (%s)\n", status.HubURL, connStatus)
 		_, _ = fmt.Fprintf(os.Stdout, "Uptime:   %s\n", status.Uptime)
-		_, _ = fmt.Fprintf(os.Stdout, "Sessions: %d/%d\n", status.Sessions, status.MaxSessions)
+		if status.Hibernated > 0 {
+			_, _ = fmt.Fprintf(os.Stdout, "Sessions: %d/%d (%d hibernated)\n", status.Sessions, status.MaxSessions, status.Hibernated)
+		} else {
+			_, _ = fmt.Fprintf(os.Stdout, "Sessions: %d/%d\n", status.Sessions, status.MaxSessions)
+		}
 		_, _ = fmt.Fprintf(os.Stdout, "Agents:   %d\n", len(status.Agents))
 		return nil
 	}
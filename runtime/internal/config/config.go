@@ -37,17 +37,42 @@ type RuntimeConfig struct {
 	FileStoragePath           string   `json:"file_storage_path,omitempty"` // path for file storage; default "./amurg-files"
 	MaxFileBytes              int64    `json:"max_file_bytes,omitempty"`    // max file size; default 10MB
 	AllowRemotePermissionSkip bool     `json:"allow_remote_permission_skip,omitempty"`
+	QueueSize                 int      `json:"queue_size,omitempty"`      // session creations that may wait at max_sessions; 0 rejects them
+	QueueTimeout              Duration `json:"queue_timeout,omitempty"`   // how long a creation waits in the queue; default 10m
+	HibernateAfter            Duration `json:"hibernate_after,omitempty"` // stop resumable agents idle this long; 0 never does
+	RemoteProfiles            []string `json:"remote_profiles,omitempty"` // profiles the hub may provision agents for; empty disables remote provisioning
+}
+
+// RemoteProfileAllowed reports whether the hub may provision agents with
+// the given profile.
+func (r RuntimeConfig) RemoteProfileAllowed(profile string) bool {
+	for _, p := range r.RemoteProfiles {
+		if p == profile {
+			return true
+		}
+	}
+	return false
 }
 
 // SecurityConfig defines security constraints for an agent.
 type SecurityConfig struct {
-	AllowedPaths    []string `json:"allowed_paths,omitempty"`
-	DeniedPaths     []string `json:"denied_paths,omitempty"`
-	AllowedTools    []string `json:"allowed_tools,omitempty"`
-	DisallowedTools []string `json:"disallowed_tools,omitempty"`
-	PermissionMode  string   `json:"permission_mode,omitempty"`
-	Cwd             string   `json:"cwd,omitempty"`
-	EnvWhitelist    []string `json:"env_whitelist,omitempty"`
+	AllowedPaths    []string       `json:"allowed_paths,omitempty"` // work dirs and delivered files must be under one of these
+	DeniedPaths     []string       `json:"denied_paths,omitempty"`  // refused even when under an allowed path
+	AllowedTools    []string       `json:"allowed_tools,omitempty"`
+	DisallowedTools []string       `json:"disallowed_tools,omitempty"`
+	PermissionMode  string         `json:"permission_mode,omitempty"`
+	Cwd             string         `json:"cwd,omitempty"`
+	EnvWhitelist    []string       `json:"env_whitelist,omitempty"` // inherited env vars passed to the agent ("PREFIX_*" allowed); unset keeps the profile's credentials
+	Sandbox         *SandboxConfig `json:"sandbox,omitempty"`
+}
+
+// SandboxConfig enables kernel-enforced confinement of the agent process
+// (Linux only). Writes are limited to the work dir or allowed_paths, the
+// profile's own state dir, the temp dir and WritablePaths.
+type SandboxConfig struct {
+	Mode          string   `json:"mode,omitempty"` // "off" (default), "auto", "bwrap", "landlock"
+	DenyNetwork   bool     `json:"deny_network,omitempty"`
+	WritablePaths []string `json:"writable_paths,omitempty"`
 }
 
 // AgentConfig defines a single agent configuration.
@@ -58,7 +83,9 @@ type AgentConfig struct {
 	Tags          map[string]string `json:"tags,omitempty"`
 	Limits        *AgentLimits      `json:"limits,omitempty"`
 	Security      *SecurityConfig   `json:"security,omitempty"`
+	Workspace     *WorkspaceConfig  `json:"workspace,omitempty"`
 	PromptProfile string            `json:"-"`
+	Cgroup        string            `json:"-"` // session cgroup dir, set by the session manager
 
 	// Profile-specific settings (parsed by the adapter)
 	CLI        *CLIConfig        `json:"cli,omitempty"`
@@ -96,12 +123,31 @@ func (a AgentConfig) WorkDir() string {
 	}
 }
 
+// WorkspaceConfig controls where an agent's sessions run. In "worktree" mode
+// each session gets its own git worktree and branch of the agent's work dir.
+// When the work dir is a git repository, the files each turn changed are
+// reported as a "diff" output unless no_turn_diff is set.
+type WorkspaceConfig struct {
+	Mode         string `json:"mode,omitempty"`           // "shared" (default) or "worktree"
+	Dir          string `json:"dir,omitempty"`            // parent dir for worktrees; default <git dir>/amurg-worktrees
+	BaseRef      string `json:"base_ref,omitempty"`       // what session branches start from; default HEAD
+	BranchPrefix string `json:"branch_prefix,omitempty"`  // default "amurg/"
+	Cleanup      string `json:"cleanup,omitempty"`        // on session close: "auto" (default), "remove" or "keep"
+	NoTurnDiff   bool   `json:"no_turn_diff,omitempty"`   // no per-turn diffs or checkpoints in a git work dir
+	DiffMaxBytes int    `json:"diff_max_bytes,omitempty"` // cap on a turn's unified diff; default 256 KiB
+}
+
 // AgentLimits are per-agent operational limits.
 type AgentLimits struct {
 	MaxSessions    int      `json:"max_sessions,omitempty"`
 	SessionTimeout Duration `json:"session_timeout,omitempty"`
 	MaxOutputBytes int64    `json:"max_output_bytes,omitempty"`
 	IdleTimeout    Duration `json:"idle_timeout,omitempty"`
+
+	// Per-session cgroup v2 limits (Linux only). Zero means unlimited.
+	CPUQuota       float64 `json:"cpu_quota,omitempty"`        // CPUs, e.g. 1.5
+	MemoryMaxBytes int64   `json:"memory_max_bytes,omitempty"` // bytes, swap disabled
+	PidsMax        int64   `json:"pids_max,omitempty"`
 }
 
 // CLIConfig is config for generic-cli and github-copilot profiles.
@@ -249,6 +295,18 @@ func Load(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+// Save writes cfg to path as indented JSON, readable only by its owner.
+func Save(path string, cfg *Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal config: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	return nil
+}
+
 func (c *Config) validate() error {
 	if c.Hub.URL == "" {
 		return fmt.Errorf("hub.url is required")
@@ -262,6 +320,12 @@ func (c *Config) validate() error {
 	if len(c.Agents) == 0 {
 		return fmt.Errorf("at least one agent is required")
 	}
+	if c.Runtime.QueueSize < 0 || c.Runtime.QueueTimeout.Duration < 0 {
+		return fmt.Errorf("runtime.queue_size and runtime.queue_timeout must not be negative")
+	}
+	if c.Runtime.HibernateAfter.Duration < 0 {
+		return fmt.Errorf("runtime.hibernate_after must not be negative")
+	}
 	seen := make(map[string]bool)
 	for i, agent := range c.Agents {
 		if agent.ID == "" {
@@ -271,34 +335,82 @@ func (c *Config) validate() error {
 			return fmt.Errorf("duplicate agent id: %s", agent.ID)
 		}
 		seen[agent.ID] = true
-		if agent.Profile == "" {
-			return fmt.Errorf("agents[%d].profile is required", i)
+		if err := validateAgent(fmt.Sprintf("agents[%d]", i), agent); err != nil {
+			return err
 		}
-		if agent.Security != nil && agent.Security.PermissionMode != "" {
-			switch agent.Security.PermissionMode {
-			case "skip", "strict", "auto", "acceptEdits", "bypassPermissions", "plan":
-				// valid
-			default:
-				return fmt.Errorf("agents[%d].security.permission_mode must be skip, strict, auto, acceptEdits, bypassPermissions, or plan", i)
-			}
+	}
+	return nil
+}
+
+// ValidateAgent checks a single agent definition with the same rules Load
+// applies to every configured agent.
+func ValidateAgent(agent AgentConfig) error {
+	if agent.ID == "" {
+		return fmt.Errorf("agent.id is required")
+	}
+	return validateAgent("agent", agent)
+}
+
+// validateAgent checks the fields of one agent; name prefixes error messages.
+func validateAgent(name string, agent AgentConfig) error {
+	if agent.Profile == "" {
+		return fmt.Errorf("%s.profile is required", name)
+	}
+	if agent.Security != nil && agent.Security.PermissionMode != "" {
+		switch agent.Security.PermissionMode {
+		case "skip", "strict", "auto", "acceptEdits", "bypassPermissions", "plan":
+			// valid
+		default:
+			return fmt.Errorf("%s.security.permission_mode must be skip, strict, auto, acceptEdits, bypassPermissions, or plan", name)
+		}
+	}
+	if agent.Security != nil && agent.Security.Sandbox != nil {
+		switch agent.Security.Sandbox.Mode {
+		case "", "off", "auto", "bwrap", "landlock":
+			// valid
+		default:
+			return fmt.Errorf("%s.security.sandbox.mode must be off, auto, bwrap, or landlock", name)
 		}
-		// Validate profile-specific permission modes.
-		if agent.ClaudeCode != nil && agent.ClaudeCode.PermissionMode != "" {
-			switch agent.ClaudeCode.PermissionMode {
-			case "dangerously-skip-permissions", "skip", "bypassPermissions",
-				"acceptEdits", "plan", "default", "auto", "strict":
-				// valid
-			default:
-				return fmt.Errorf("agents[%d].claude_code.permission_mode %q is not recognized; use skip, acceptEdits, plan, or strict", i, agent.ClaudeCode.PermissionMode)
-			}
+	}
+	if ws := agent.Workspace; ws != nil {
+		switch ws.Mode {
+		case "", "shared", "worktree":
+			// valid
+		default:
+			return fmt.Errorf("%s.workspace.mode must be shared or worktree", name)
+		}
+		switch ws.Cleanup {
+		case "", "auto", "remove", "keep":
+			// valid
+		default:
+			return fmt.Errorf("%s.workspace.cleanup must be auto, remove, or keep", name)
+		}
+		if ws.DiffMaxBytes < 0 {
+			return fmt.Errorf("%s.workspace.diff_max_bytes must not be negative", name)
 		}
-		if agent.ClaudeCode != nil && agent.ClaudeCode.Transport != "" {
-			switch agent.ClaudeCode.Transport {
-			case "stream-json", "tmux":
-				// valid
-			default:
-				return fmt.Errorf("agents[%d].claude_code.transport %q is not recognized; use stream-json or tmux", i, agent.ClaudeCode.Transport)
-			}
+		if ws.Mode == "worktree" && agent.WorkDir() == "" && (agent.Security == nil || agent.Security.Cwd == "") {
+			return fmt.Errorf("%s.workspace.mode worktree requires a work_dir", name)
+		}
+	}
+	if l := agent.Limits; l != nil && (l.CPUQuota < 0 || l.MemoryMaxBytes < 0 || l.PidsMax < 0) {
+		return fmt.Errorf("%s.limits: cpu_quota, memory_max_bytes and pids_max must not be negative", name)
+	}
+	// Validate profile-specific permission modes.
+	if agent.ClaudeCode != nil && agent.ClaudeCode.PermissionMode != "" {
+		switch agent.ClaudeCode.PermissionMode {
+		case "dangerously-skip-permissions", "skip", "bypassPermissions",
+			"acceptEdits", "plan", "default", "auto", "strict":
+			// valid
+		default:
+			return fmt.Errorf("%s.claude_code.permission_mode %q is not recognized; use skip, acceptEdits, plan, or strict", name, agent.ClaudeCode.PermissionMode)
+		}
+	}
+	if agent.ClaudeCode != nil && agent.ClaudeCode.Transport != "" {
+		switch agent.ClaudeCode.Transport {
+		case "stream-json", "tmux":
+			// valid
+		default:
+			return fmt.Errorf("%s.claude_code.transport %q is not recognized; use stream-json or tmux", name, agent.ClaudeCode.Transport)
 		}
 	}
 	return nil
@@ -317,6 +429,9 @@ func (c *Config) applyDefaults() {
 	if c.Runtime.IdleTimeout.Duration == 0 {
 		c.Runtime.IdleTimeout.Duration = 30 * time.Second
 	}
+	if c.Runtime.QueueTimeout.Duration == 0 {
+		c.Runtime.QueueTimeout.Duration = 10 * time.Minute
+	}
 	if c.Runtime.LogLevel == "" {
 		c.Runtime.LogLevel = "info"
 	}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -175,6 +176,9 @@ func TestLoad_AppliesDefaults(t *testing.T) {
 	if cfg.Runtime.IdleTimeout.Duration != 30*time.Second {
 		t.Errorf("expected default idle timeout 30s, got %v", cfg.Runtime.IdleTimeout.Duration)
 	}
+	if cfg.Runtime.QueueSize != 0 || cfg.Runtime.QueueTimeout.Duration != 10*time.Minute {
+		t.Errorf("expected queue disabled with 10m timeout, got %d/%v", cfg.Runtime.QueueSize, cfg.Runtime.QueueTimeout.Duration)
+	}
 	if cfg.Runtime.LogLevel != "info" {
 		t.Errorf("expected default log level info, got %s", cfg.Runtime.LogLevel)
 	}
@@ -389,3 +393,116 @@ func writeTemp(t *testing.T, content string) string {
 	}
 	return path
 }
+
+func TestLoad_InvalidSandboxMode(t *testing.T) {
+	cfgJSON := `{
+		"hub": {"url": "ws://localhost", "token": "t"},
+		"runtime": {"id": "r1"},
+		"agents": [{
+			"id": "e1", "name": "n", "profile": "generic-cli",
+			"security": {"sandbox": {"mode": "chroot"}}
+		}]
+	}`
+	path := writeTemp(t, cfgJSON)
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expected validation error for invalid security.sandbox.mode")
+	}
+}
+
+func TestLoad_CgroupLimits(t *testing.T) {
+	cfgJSON := `{
+		"hub": {"url": "ws://localhost", "token": "t"},
+		"runtime": {"id": "r1"},
+		"agents": [{
+			"id": "e1", "name": "n", "profile": "generic-cli",
+			"limits": {"cpu_quota": 1.5, "memory_max_bytes": 1073741824, "pids_max": 256}
+		}]
+	}`
+	cfg, err := Load(writeTemp(t, cfgJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := cfg.Agents[0].Limits
+	if l.CPUQuota != 1.5 || l.MemoryMaxBytes != 1<<30 || l.PidsMax != 256 {
+		t.Errorf("unexpected limits %+v", l)
+	}
+
+	negative := strings.Replace(cfgJSON, `"pids_max": 256`, `"pids_max": -1`, 1)
+	if _, err := Load(writeTemp(t, negative)); err == nil {
+		t.Fatal("expected validation error for negative pids_max")
+	}
+}
+
+func TestLoad_WorkspaceValidation(t *testing.T) {
+	base := `{
+		"hub": {"url": "ws://localhost", "token": "t"},
+		"runtime": {"id": "r1"},
+		"agents": [{
+			"id": "e1", "name": "n", "profile": "generic-cli",
+			"cli": {"command": "bash"WORKDIR},
+			"workspace": WORKSPACE
+		}]
+	}`
+	cases := []struct {
+		name, workDir, workspace string
+		wantErr                  bool
+	}{
+		{"worktree", `, "work_dir": "/srv/repo"`, `{"mode": "worktree", "cleanup": "keep"}`, false},
+		{"worktree without work_dir", ``, `{"mode": "worktree"}`, true},
+		{"unknown mode", `, "work_dir": "/srv/repo"`, `{"mode": "copy"}`, true},
+		{"unknown cleanup", `, "work_dir": "/srv/repo"`, `{"mode": "worktree", "cleanup": "never"}`, true},
+		{"turn diff options", `, "work_dir": "/srv/repo"`, `{"no_turn_diff": true, "diff_max_bytes": 4096}`, false},
+		{"negative diff cap", `, "work_dir": "/srv/repo"`, `{"diff_max_bytes": -1}`, true},
+	}
+	for _, tc := range cases {
+		cfgJSON := strings.NewReplacer("WORKDIR", tc.workDir, "WORKSPACE", tc.workspace).Replace(base)
+		_, err := Load(writeTemp(t, cfgJSON))
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestValidateAgent(t *testing.T) {
+	if err := ValidateAgent(AgentConfig{ID: "e1", Profile: "generic-cli"}); err != nil {
+		t.Fatalf("valid agent: %v", err)
+	}
+	if err := ValidateAgent(AgentConfig{Profile: "generic-cli"}); err == nil {
+		t.Fatal("expected an error for a missing id")
+	}
+	err := ValidateAgent(AgentConfig{ID: "e1", Profile: "generic-cli", Security: &SecurityConfig{PermissionMode: "yolo"}})
+	if err == nil || !strings.HasPrefix(err.Error(), "agent.security.permission_mode") {
+		t.Fatalf("expected a permission_mode error, got %v", err)
+	}
+}
+
+func TestSave_RoundTrip(t *testing.T) {
+	cfg, err := Load(writeTemp(t, `{
+		"hub": {"url": "ws://localhost", "token": "t"},
+		"runtime": {"id": "r1", "remote_profiles": ["generic-cli"]},
+		"agents": [{"id": "e1", "name": "n", "profile": "generic-cli"}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Agents = append(cfg.Agents, AgentConfig{ID: "e2", Name: "m", Profile: "generic-cli", CLI: &CLIConfig{Command: "make"}})
+
+	path := filepath.Join(t.TempDir(), "runtime-config.json")
+	if err := Save(path, cfg); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected a 0600 config file, got %v (err %v)", info, err)
+	}
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(reloaded.Agents) != 2 || reloaded.Agents[1].CLI == nil || reloaded.Agents[1].CLI.Command != "make" {
+		t.Fatalf("unexpected agents after reload: %+v", reloaded.Agents)
+	}
+	if !reloaded.Runtime.RemoteProfileAllowed("generic-cli") || reloaded.Runtime.RemoteProfileAllowed("claude-code") {
+		t.Errorf("unexpected remote profile allowlist %v", reloaded.Runtime.RemoteProfiles)
+	}
+}
@@ -33,6 +33,11 @@ func SocketPath() string {
 	return filepath.Join(DefaultDir(), "runtime.sock")
 }
 
+// JournalPath returns the path to the session journal of a runtime.
+func JournalPath(runtimeID string) string {
+	return filepath.Join(DefaultDir(), "journal", runtimeID+".json")
+}
+
 // WritePID writes the given PID to the PID file.
 func WritePID(pid int) error {
 	dir := DefaultDir()
@@ -46,7 +46,7 @@ type Client struct {
 	cfg     config.HubConfig
 	rtID    string
 	orgID   string // optional, defaults to "default" on hub side
-	agents  []protocol.AgentRegistration
+	agents  []protocol.AgentRegistration // guarded by mu
 	handler MessageHandler
 	logger  *slog.Logger
 
@@ -91,6 +91,14 @@ func (c *Client) SetStateChangeHandler(fn StateChangeFunc) {
 	c.mu.Unlock()
 }
 
+// SetAgents replaces the agents registered in the hello sent on every
+// (re)connect.
+func (c *Client) SetAgents(agents []protocol.AgentRegistration) {
+	c.mu.Lock()
+	c.agents = agents
+	c.mu.Unlock()
+}
+
 func (c *Client) notifyStateChange(connected, reconnecting bool) {
 	c.mu.Lock()
 	fn := c.onStateChange
@@ -200,13 +208,14 @@ func (c *Client) connectOnce(ctx context.Context) error {
 	// Send hello with latest token.
 	c.mu.Lock()
 	token := c.currentToken
+	agents := c.agents
 	c.mu.Unlock()
 
 	hello := protocol.RuntimeHello{
 		RuntimeID: c.rtID,
 		Token:     token,
 		OrgID:     c.orgID,
-		Agents:    c.agents,
+		Agents:    agents,
 	}
 
 	if err := c.sendMessage(protocol.TypeRuntimeHello, "", hello); err != nil {
@@ -28,6 +28,7 @@ type StatusResult struct {
 	Uptime       string      `json:"uptime"`
 	StartedAt    time.Time   `json:"started_at"`
 	Sessions     int         `json:"sessions"`
+	Hibernated   int         `json:"hibernated,omitempty"` // sessions whose agent process is stopped until the next message
 	MaxSessions  int         `json:"max_sessions"`
 	Agents       []AgentInfo `json:"agents"`
 	Version      string      `json:"version"`
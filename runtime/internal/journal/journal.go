@@ -0,0 +1,134 @@
+// Package journal keeps an on-disk record of a runtime's open sessions so
+// they can be restored after the runtime restarts. The journal is a single
+// JSON file, rewritten atomically on every change.
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Entry is one journaled session.
+type Entry struct {
+	SessionID     string    `json:"session_id"`
+	AgentID       string    `json:"agent_id"`
+	UserID        string    `json:"user_id"`
+	PromptProfile string    `json:"prompt_profile,omitempty"`
+	NativeHandle  string    `json:"native_handle,omitempty"`
+	WorkDir       string    `json:"work_dir,omitempty"` // the session's worktree, if it has one
+	Branch        string    `json:"branch,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Journal is the set of sessions recorded in a file.
+type Journal struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// Open loads the journal at path. A missing file is an empty journal.
+func Open(path string) (*Journal, error) {
+	j := &Journal{path: path, entries: make(map[string]Entry)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse journal %s: %w", path, err)
+	}
+	for _, e := range entries {
+		j.entries[e.SessionID] = e
+	}
+	return j, nil
+}
+
+// Entries returns the journaled sessions, oldest first.
+func (j *Journal) Entries() []Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.sortedLocked()
+}
+
+// Get returns the entry of a session.
+func (j *Journal) Get(sessionID string) (Entry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e, ok := j.entries[sessionID]
+	return e, ok
+}
+
+// Put records or replaces a session's entry.
+func (j *Journal) Put(e Entry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if old, ok := j.entries[e.SessionID]; ok && old == e {
+		return nil
+	}
+	j.entries[e.SessionID] = e
+	return j.saveLocked()
+}
+
+// Delete removes a session's entry.
+func (j *Journal) Delete(sessionID string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.entries[sessionID]; !ok {
+		return nil
+	}
+	delete(j.entries, sessionID)
+	return j.saveLocked()
+}
+
+func (j *Journal) sortedLocked() []Entry {
+	entries := make([]Entry, 0, len(j.entries))
+	for _, e := range j.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		if !entries[a].CreatedAt.Equal(entries[b].CreatedAt) {
+			return entries[a].CreatedAt.Before(entries[b].CreatedAt)
+		}
+		return entries[a].SessionID < entries[b].SessionID
+	})
+	return entries
+}
+
+// saveLocked writes the journal to a temporary file and renames it over the
+// old one, so a crash never leaves a truncated journal behind.
+func (j *Journal) saveLocked() error {
+	data, err := json.MarshalIndent(j.sortedLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(j.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("create journal dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(j.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write journal: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), j.path); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	return nil
+}
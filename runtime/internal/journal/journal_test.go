@@ -0,0 +1,74 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournal_PersistsAcrossOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal", "rt-1.json")
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatalf("open empty journal: %v", err)
+	}
+	if len(j.Entries()) != 0 {
+		t.Fatalf("expected no entries, got %v", j.Entries())
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	first := Entry{SessionID: "sess-1", AgentID: "ep-1", UserID: "u1", PromptProfile: "standard", CreatedAt: now}
+	second := Entry{SessionID: "sess-2", AgentID: "ep-2", UserID: "u1", WorkDir: "/tmp/wt", Branch: "amurg/sess-2", CreatedAt: now.Add(time.Minute)}
+	for _, e := range []Entry{second, first} {
+		if err := j.Put(e); err != nil {
+			t.Fatalf("put %s: %v", e.SessionID, err)
+		}
+	}
+	first.NativeHandle = "native-1"
+	if err := j.Put(first); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	entries := reopened.Entries()
+	if len(entries) != 2 || entries[0] != first || entries[1] != second {
+		t.Fatalf("unexpected entries after reopen: %+v", entries)
+	}
+
+	if err := reopened.Delete("sess-1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := reopened.Delete("sess-missing"); err != nil {
+		t.Fatalf("delete missing: %v", err)
+	}
+	reopened, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen after delete: %v", err)
+	}
+	if _, ok := reopened.Get("sess-1"); ok {
+		t.Error("expected sess-1 to be deleted")
+	}
+	if _, ok := reopened.Get("sess-2"); !ok {
+		t.Error("expected sess-2 to remain")
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp"))
+	if len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestOpen_CorruptJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rt-1.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Fatal("expected an error for a corrupt journal")
+	}
+}
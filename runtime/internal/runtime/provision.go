@@ -0,0 +1,177 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/amurg-ai/amurg/pkg/protocol"
+	"github.com/amurg-ai/amurg/runtime/internal/config"
+)
+
+// handleAgentProvision creates, replaces or deletes an agent definition on
+// behalf of the hub. Accepted changes are written to the config file and the
+// new agent list is announced before the ack, so the hub has registered it
+// by the time the provisioning call returns.
+func (r *Runtime) handleAgentProvision(env protocol.Envelope) error {
+	data, _ := json.Marshal(env.Payload)
+	var req protocol.AgentProvision
+	if err := json.Unmarshal(data, &req); err != nil {
+		return fmt.Errorf("unmarshal agent provision: %w", err)
+	}
+
+	err := r.provisionAgent(req)
+
+	ack := protocol.AgentProvisionAck{
+		RequestID: req.RequestID,
+		AgentID:   req.AgentID,
+		OK:        err == nil,
+	}
+	if err != nil {
+		ack.Error = err.Error()
+		r.logger.Warn("agent provisioning failed", "action", req.Action, "agent_id", req.AgentID, "error", err)
+	} else {
+		r.logger.Info("agent provisioned", "action", req.Action, "agent_id", req.AgentID)
+
+		r.mu.Lock()
+		agents := agentRegistrations(r.cfg.Agents)
+		r.mu.Unlock()
+		r.hubClient.SetAgents(agents)
+		r.sendToHub(protocol.TypeAgentsAnnounce, "", protocol.AgentsAnnounce{Agents: agents})
+	}
+
+	return r.hubClient.Send(protocol.TypeAgentProvisionAck, "", ack)
+}
+
+// provisionAgent applies one provisioning request to the session manager,
+// the config file and the in-memory config, in that order. If the file
+// cannot be written the manager change is undone.
+func (r *Runtime) provisionAgent(req protocol.AgentProvision) error {
+	if r.configPath == "" {
+		return fmt.Errorf("runtime has no config file to persist agents to")
+	}
+
+	r.mu.Lock()
+	current, exists := findAgent(r.cfg.Agents, req.AgentID)
+	count := len(r.cfg.Agents)
+	r.mu.Unlock()
+
+	if exists && !r.cfg.Runtime.RemoteProfileAllowed(current.Profile) {
+		return fmt.Errorf("agent %s uses profile %q, which may not be provisioned remotely", req.AgentID, current.Profile)
+	}
+
+	var agent *config.AgentConfig
+	switch req.Action {
+	case protocol.ProvisionCreate, protocol.ProvisionUpdate:
+		if req.Action == protocol.ProvisionCreate && exists {
+			return fmt.Errorf("agent %s already exists", req.AgentID)
+		}
+		if req.Action == protocol.ProvisionUpdate && !exists {
+			return fmt.Errorf("unknown agent: %s", req.AgentID)
+		}
+		a, err := decodeAgent(req)
+		if err != nil {
+			return err
+		}
+		if !r.cfg.Runtime.RemoteProfileAllowed(a.Profile) {
+			return fmt.Errorf("profile %q may not be provisioned remotely (add it to runtime.remote_profiles)", a.Profile)
+		}
+		if err := config.ValidateAgent(a); err != nil {
+			return err
+		}
+		if err := r.sessions.PutAgent(a); err != nil {
+			return err
+		}
+		agent = &a
+
+	case protocol.ProvisionDelete:
+		if !exists {
+			return fmt.Errorf("unknown agent: %s", req.AgentID)
+		}
+		if count == 1 {
+			return fmt.Errorf("cannot remove the runtime's last agent")
+		}
+		if err := r.sessions.RemoveAgent(req.AgentID); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unknown provisioning action %q", req.Action)
+	}
+
+	if err := r.persistAgent(req.AgentID, agent); err != nil {
+		if exists {
+			_ = r.sessions.PutAgent(current)
+		} else {
+			_ = r.sessions.RemoveAgent(req.AgentID)
+		}
+		return err
+	}
+
+	r.mu.Lock()
+	r.cfg.Agents = replaceAgent(r.cfg.Agents, req.AgentID, agent)
+	r.mu.Unlock()
+	return nil
+}
+
+// decodeAgent parses the agent definition of a create or update. Unknown
+// fields are rejected so a typo does not silently drop a setting.
+func decodeAgent(req protocol.AgentProvision) (config.AgentConfig, error) {
+	var agent config.AgentConfig
+	if len(req.Config) == 0 {
+		return agent, fmt.Errorf("agent config is required")
+	}
+	dec := json.NewDecoder(bytes.NewReader(req.Config))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&agent); err != nil {
+		return agent, fmt.Errorf("parse agent config: %w", err)
+	}
+	if agent.ID == "" {
+		agent.ID = req.AgentID
+	}
+	if agent.ID != req.AgentID {
+		return agent, fmt.Errorf("agent config id %q does not match agent %q", agent.ID, req.AgentID)
+	}
+	return agent, nil
+}
+
+// persistAgent writes one agent change to the config file, re-reading it
+// first so edits made on disk since startup are kept. A nil agent deletes.
+func (r *Runtime) persistAgent(agentID string, agent *config.AgentConfig) error {
+	cfg, err := config.Load(r.configPath)
+	if err != nil {
+		return err
+	}
+	cfg.Agents = replaceAgent(cfg.Agents, agentID, agent)
+	return config.Save(r.configPath, cfg)
+}
+
+func findAgent(agents []config.AgentConfig, id string) (config.AgentConfig, bool) {
+	for _, a := range agents {
+		if a.ID == id {
+			return a, true
+		}
+	}
+	return config.AgentConfig{}, false
+}
+
+// replaceAgent returns agents with the agent id replaced by agent, appended
+// if it was missing, or removed if agent is nil.
+func replaceAgent(agents []config.AgentConfig, id string, agent *config.AgentConfig) []config.AgentConfig {
+	out := make([]config.AgentConfig, 0, len(agents)+1)
+	found := false
+	for _, a := range agents {
+		if a.ID != id {
+			out = append(out, a)
+			continue
+		}
+		found = true
+		if agent != nil {
+			out = append(out, *agent)
+		}
+	}
+	if !found && agent != nil {
+		out = append(out, *agent)
+	}
+	return out
+}
@@ -16,16 +16,20 @@ import (
 	"github.com/amurg-ai/amurg/pkg/protocol"
 	"github.com/amurg-ai/amurg/runtime/internal/adapter"
 	"github.com/amurg-ai/amurg/runtime/internal/config"
+	"github.com/amurg-ai/amurg/runtime/internal/daemon"
 	"github.com/amurg-ai/amurg/runtime/internal/eventbus"
 	"github.com/amurg-ai/amurg/runtime/internal/hub"
 	"github.com/amurg-ai/amurg/runtime/internal/ipc"
+	"github.com/amurg-ai/amurg/runtime/internal/journal"
+	"github.com/amurg-ai/amurg/runtime/internal/sandbox"
 	"github.com/amurg-ai/amurg/runtime/internal/session"
 	"github.com/google/uuid"
 )
 
 // Runtime is the main runtime process.
 type Runtime struct {
-	cfg                *config.Config
+	cfg                *config.Config // cfg.Agents is guarded by mu
+	configPath         string         // file provisioned agents are persisted to
 	registry           *adapter.Registry
 	sessions           *session.Manager
 	hubClient          *hub.Client
@@ -34,6 +38,7 @@ type Runtime struct {
 	startedAt          time.Time
 	mu                 sync.Mutex
 	pendingPermissions map[string]chan bool
+	queued             map[string][]protocol.Envelope // hub messages held back for sessions waiting in the queue
 	hubConnected       bool
 	hubReconnecting    bool
 }
@@ -54,6 +59,7 @@ func New(cfg *config.Config, logger *slog.Logger, bus *eventbus.Bus) *Runtime {
 		bus:                bus,
 		startedAt:          time.Now(),
 		pendingPermissions: make(map[string]chan bool),
+		queued:             make(map[string][]protocol.Envelope),
 	}
 
 	// Create session manager with output handler that forwards to hub.
@@ -65,10 +71,44 @@ func New(cfg *config.Config, logger *slog.Logger, bus *eventbus.Bus) *Runtime {
 		rt.handlePermissionRequest,
 		logger,
 	)
+	rt.sessions.SetViolationHandler(rt.handleSecurityViolation)
+	rt.sessions.SetQueueHandler(rt.handleSessionQueued)
 
-	// Build agent registrations for hub.
-	agents := make([]protocol.AgentRegistration, 0, len(cfg.Agents))
-	for _, agent := range cfg.Agents {
+	// Without a journal the runtime still works; sessions just do not
+	// survive a restart.
+	if j, err := journal.Open(daemon.JournalPath(cfg.Runtime.ID)); err != nil {
+		rt.logger.Warn("session journal unavailable", "error", err)
+	} else {
+		rt.sessions.SetJournal(j)
+	}
+
+	rt.hubClient = hub.NewClient(cfg.Hub, cfg.Runtime.ID, cfg.Runtime.OrgID, agentRegistrations(cfg.Agents), rt.handleHubMessage, logger)
+
+	// Wire hub state change notifications to event bus.
+	rt.hubClient.SetStateChangeHandler(func(connected, reconnecting bool) {
+		rt.mu.Lock()
+		rt.hubConnected = connected
+		rt.hubReconnecting = reconnecting
+		rt.mu.Unlock()
+
+		if connected {
+			// Deny all stale pending permissions — hub has already timed them out.
+			rt.denyStalePermissions()
+			rt.bus.PublishType(eventbus.HubConnected, nil)
+		} else if reconnecting {
+			rt.bus.PublishType(eventbus.HubReconnecting, nil)
+		} else {
+			rt.bus.PublishType(eventbus.HubDisconnected, nil)
+		}
+	})
+
+	return rt
+}
+
+// agentRegistrations describes the configured agents to the hub.
+func agentRegistrations(cfgs []config.AgentConfig) []protocol.AgentRegistration {
+	agents := make([]protocol.AgentRegistration, 0, len(cfgs))
+	for _, agent := range cfgs {
 		caps, ok := protocol.KnownProfiles[agent.Profile]
 		if !ok {
 			caps = protocol.ProfileCaps{ExecModel: protocol.ExecInteractive}
@@ -86,6 +126,11 @@ func New(cfg *config.Config, logger *slog.Logger, bus *eventbus.Bus) *Runtime {
 			}
 		}
 
+		var sandboxStatus *protocol.SandboxStatus
+		if agent.Security != nil && agent.Security.Sandbox != nil {
+			sandboxStatus = sandbox.Status(agent.Security.Sandbox.Mode, agent.Security.Sandbox.DenyNetwork)
+		}
+
 		agents = append(agents, protocol.AgentRegistration{
 			ID:       agent.ID,
 			Profile:  agent.Profile,
@@ -93,30 +138,10 @@ func New(cfg *config.Config, logger *slog.Logger, bus *eventbus.Bus) *Runtime {
 			Tags:     agent.Tags,
 			Caps:     caps,
 			Security: sec,
+			Sandbox:  sandboxStatus,
 		})
 	}
-
-	rt.hubClient = hub.NewClient(cfg.Hub, cfg.Runtime.ID, cfg.Runtime.OrgID, agents, rt.handleHubMessage, logger)
-
-	// Wire hub state change notifications to event bus.
-	rt.hubClient.SetStateChangeHandler(func(connected, reconnecting bool) {
-		rt.mu.Lock()
-		rt.hubConnected = connected
-		rt.hubReconnecting = reconnecting
-		rt.mu.Unlock()
-
-		if connected {
-			// Deny all stale pending permissions — hub has already timed them out.
-			rt.denyStalePermissions()
-			rt.bus.PublishType(eventbus.HubConnected, nil)
-		} else if reconnecting {
-			rt.bus.PublishType(eventbus.HubReconnecting, nil)
-		} else {
-			rt.bus.PublishType(eventbus.HubDisconnected, nil)
-		}
-	})
-
-	return rt
+	return agents
 }
 
 // Bus returns the runtime's event bus.
@@ -124,17 +149,30 @@ func (r *Runtime) Bus() *eventbus.Bus {
 	return r.bus
 }
 
+// SetConfigPath sets the config file that agents provisioned by the hub are
+// written to. Without it the runtime refuses remote provisioning.
+func (r *Runtime) SetConfigPath(path string) {
+	r.configPath = path
+}
+
 // Status returns the current runtime status (implements ipc.StateProvider).
 func (r *Runtime) Status() ipc.StatusResult {
 	r.mu.Lock()
 	connected := r.hubConnected
 	reconnecting := r.hubReconnecting
-	r.mu.Unlock()
-
 	agents := make([]ipc.AgentInfo, len(r.cfg.Agents))
 	for i, a := range r.cfg.Agents {
 		agents[i] = ipc.AgentInfo{ID: a.ID, Name: a.Name, Profile: a.Profile, WorkDir: a.WorkDir()}
 	}
+	r.mu.Unlock()
+
+	sessions := r.sessions.List()
+	hibernated := 0
+	for _, s := range sessions {
+		if s.State == string(session.StateHibernated) {
+			hibernated++
+		}
+	}
 
 	return ipc.StatusResult{
 		RuntimeID:    r.cfg.Runtime.ID,
@@ -143,7 +181,8 @@ func (r *Runtime) Status() ipc.StatusResult {
 		Reconnecting: reconnecting,
 		StartedAt:    r.startedAt,
 		Uptime:       time.Since(r.startedAt).Truncate(time.Second).String(),
-		Sessions:     r.sessions.ActiveCount(),
+		Sessions:     len(sessions),
+		Hibernated:   hibernated,
 		MaxSessions:  r.cfg.Runtime.MaxSessions,
 		Agents:       agents,
 	}
@@ -180,6 +219,10 @@ func (r *Runtime) Run(ctx context.Context) error {
 		_ = r.hubClient.Close()
 	}()
 
+	if restored := r.sessions.Restore(ctx); len(restored) > 0 {
+		r.logger.Info("restored sessions from journal", "count", len(restored))
+	}
+
 	return r.hubClient.Connect(ctx)
 }
 
@@ -196,12 +239,16 @@ func (r *Runtime) handleHubMessage(env protocol.Envelope) error {
 		return r.handleUserMessage(env)
 	case protocol.TypeInteractiveInput:
 		return r.handleInteractiveInput(env)
+	case protocol.TypeWorkspaceRollback:
+		return r.handleWorkspaceRollback(env)
 	case protocol.TypeStopRequest:
 		return r.handleStop(env)
 	case protocol.TypeFileUpload:
 		return r.handleFileUpload(env)
 	case protocol.TypeAgentConfigUpdate:
 		return r.handleAgentConfigUpdate(env)
+	case protocol.TypeAgentProvision:
+		return r.handleAgentProvision(env)
 	case protocol.TypePermissionResponse:
 		return r.handlePermissionResponse(env)
 	case protocol.TypeNativeSessionsList:
@@ -226,9 +273,28 @@ func (r *Runtime) handleHelloAck(env protocol.Envelope) error {
 	}
 
 	r.logger.Info("registered with hub")
+	r.announceSessions()
 	return nil
 }
 
+// announceSessions tells the hub which sessions this runtime holds, so
+// sessions restored after a restart resume right away and those the hub
+// closed meanwhile are closed here too.
+func (r *Runtime) announceSessions() {
+	infos := r.sessions.List()
+	sessions := make([]protocol.AnnouncedSession, 0, len(infos))
+	for _, s := range infos {
+		sessions = append(sessions, protocol.AnnouncedSession{
+			SessionID:    s.ID,
+			AgentID:      s.AgentID,
+			NativeHandle: r.sessions.GetNativeHandle(s.ID),
+			Branch:       r.sessions.GetBranch(s.ID),
+			Responding:   s.State == string(session.StateResponding),
+		})
+	}
+	r.sendToHub(protocol.TypeSessionsAnnounce, "", protocol.SessionsAnnounce{Sessions: sessions})
+}
+
 func (r *Runtime) handleSessionCreate(env protocol.Envelope) error {
 	data, _ := json.Marshal(env.Payload)
 	var req protocol.SessionCreate
@@ -236,11 +302,38 @@ func (r *Runtime) handleSessionCreate(env protocol.Envelope) error {
 		return fmt.Errorf("unmarshal session create: %w", err)
 	}
 
+	// Hub messages are handled one at a time, so a creation that has to wait
+	// for a free slot must not block the others.
+	if r.sessions.MustQueue() {
+		r.mu.Lock()
+		r.queued[req.SessionID] = nil
+		r.mu.Unlock()
+		go func() {
+			resp := r.createSession(req)
+			if !resp.OK {
+				r.releaseQueued(req.SessionID, false)
+			}
+			r.sendToHub(protocol.TypeSessionCreated, req.SessionID, resp)
+			if resp.OK {
+				r.releaseQueued(req.SessionID, true)
+			}
+		}()
+		return nil
+	}
+
+	return r.hubClient.Send(protocol.TypeSessionCreated, req.SessionID, r.createSession(req))
+}
+
+// createSession starts the session requested by req.
+func (r *Runtime) createSession(req protocol.SessionCreate) protocol.SessionCreated {
 	ctx := context.Background()
 	var err error
-	if req.ResumeSessionID != "" {
+	switch {
+	case req.ResumeSessionID != "" && req.Fork:
+		err = r.sessions.CreateFork(ctx, req.SessionID, req.AgentID, req.UserID, req.ResumeSessionID, req.PromptProfile)
+	case req.ResumeSessionID != "":
 		err = r.sessions.CreateWithResume(ctx, req.SessionID, req.AgentID, req.UserID, req.ResumeSessionID, req.PromptProfile)
-	} else {
+	default:
 		err = r.sessions.Create(ctx, req.SessionID, req.AgentID, req.UserID, req.PromptProfile)
 	}
 
@@ -255,14 +348,74 @@ func (r *Runtime) handleSessionCreate(env protocol.Envelope) error {
 		// For resumed sessions the native handle is already known at creation
 		// time, which lets the hub surface handoff information immediately.
 		resp.NativeHandle = r.sessions.GetNativeHandle(req.SessionID)
+		resp.Branch = r.sessions.GetBranch(req.SessionID)
 		r.bus.PublishType(eventbus.SessionCreated, map[string]string{
 			"session_id": req.SessionID,
 			"agent_id":   req.AgentID,
 			"user_id":    req.UserID,
 		})
 	}
+	return resp
+}
+
+// handleSessionQueued reports a queued session's position to the hub.
+func (r *Runtime) handleSessionQueued(sessionID string, position int) {
+	r.sendToHub(protocol.TypeSessionQueued, sessionID, protocol.SessionQueued{
+		SessionID: sessionID,
+		Position:  position,
+	})
+}
+
+// holdForQueue keeps env until the queued session it is addressed to has
+// started. It reports whether the session is queued.
+func (r *Runtime) holdForQueue(sessionID string, env protocol.Envelope) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	held, ok := r.queued[sessionID]
+	if ok {
+		r.queued[sessionID] = append(held, env)
+	}
+	return ok
+}
 
-	return r.hubClient.Send(protocol.TypeSessionCreated, req.SessionID, resp)
+// releaseQueued hands the messages held for a queued session on once it has
+// started, or completes their turns if it never did.
+func (r *Runtime) releaseQueued(sessionID string, started bool) {
+	for {
+		r.mu.Lock()
+		held := r.queued[sessionID]
+		if len(held) == 0 || !started {
+			delete(r.queued, sessionID)
+		} else {
+			r.queued[sessionID] = nil
+		}
+		r.mu.Unlock()
+		if len(held) == 0 {
+			return
+		}
+
+		for _, env := range held {
+			if started {
+				if err := r.handleHubMessage(env); err != nil {
+					r.logger.Warn("queued hub message failed", "session_id", sessionID, "type", env.Type, "error", err)
+				}
+				continue
+			}
+			if env.Type == protocol.TypeUserMessage {
+				data, _ := json.Marshal(env.Payload)
+				var msg protocol.UserMessage
+				if err := json.Unmarshal(data, &msg); err == nil {
+					r.sendToHub(protocol.TypeTurnCompleted, sessionID, protocol.TurnCompleted{
+						SessionID:    sessionID,
+						InResponseTo: msg.MessageID,
+					})
+				}
+			}
+		}
+		if !started {
+			return
+		}
+	}
 }
 
 func (r *Runtime) handleSessionClose(env protocol.Envelope) error {
@@ -289,6 +442,9 @@ func (r *Runtime) handleUserMessage(env protocol.Envelope) error {
 	if err := json.Unmarshal(data, &msg); err != nil {
 		return fmt.Errorf("unmarshal user message: %w", err)
 	}
+	if r.holdForQueue(msg.SessionID, env) {
+		return nil
+	}
 
 	// Signal turn started.
 	r.sendToHub(protocol.TypeTurnStarted, msg.SessionID, protocol.TurnStarted{
@@ -297,7 +453,7 @@ func (r *Runtime) handleUserMessage(env protocol.Envelope) error {
 	})
 
 	ctx := context.Background()
-	err := r.sessions.Send(ctx, msg.SessionID, []byte(msg.Content))
+	err := r.sessions.Send(ctx, msg.SessionID, msg.MessageID, []byte(msg.Content))
 
 	// Lazy session recreation: if the session is gone (runtime restarted)
 	// but the hub forwarded enough metadata, recreate it with the native handle.
@@ -306,7 +462,7 @@ func (r *Runtime) handleUserMessage(env protocol.Envelope) error {
 			"session_id", msg.SessionID, "agent_id", msg.AgentID,
 			"native_handle", msg.NativeHandle)
 		if createErr := r.sessions.CreateWithResume(ctx, msg.SessionID, msg.AgentID, msg.UserID, msg.NativeHandle, msg.PromptProfile); createErr == nil {
-			err = r.sessions.Send(ctx, msg.SessionID, []byte(msg.Content))
+			err = r.sessions.Send(ctx, msg.SessionID, msg.MessageID, []byte(msg.Content))
 		} else {
 			r.logger.Warn("lazy session recreation failed", "session_id", msg.SessionID, "error", createErr)
 		}
@@ -331,16 +487,19 @@ func (r *Runtime) handleInteractiveInput(env protocol.Envelope) error {
 	if err := json.Unmarshal(data, &msg); err != nil {
 		return fmt.Errorf("unmarshal interactive input: %w", err)
 	}
+	if r.holdForQueue(msg.SessionID, env) {
+		return nil
+	}
 
 	ctx := context.Background()
-	err := r.sessions.SendInteractive(ctx, msg.SessionID, []byte(msg.Content))
+	err := r.sessions.SendInteractive(ctx, msg.SessionID, msg.MessageID, []byte(msg.Content))
 
 	if err != nil && msg.AgentID != "" {
 		r.logger.Info("attempting lazy interactive session recreation",
 			"session_id", msg.SessionID, "agent_id", msg.AgentID,
 			"native_handle", msg.NativeHandle)
 		if createErr := r.sessions.CreateWithResume(ctx, msg.SessionID, msg.AgentID, msg.UserID, msg.NativeHandle, msg.PromptProfile); createErr == nil {
-			err = r.sessions.SendInteractive(ctx, msg.SessionID, []byte(msg.Content))
+			err = r.sessions.SendInteractive(ctx, msg.SessionID, msg.MessageID, []byte(msg.Content))
 		} else {
 			r.logger.Warn("lazy interactive session recreation failed", "session_id", msg.SessionID, "error", createErr)
 		}
@@ -372,6 +531,33 @@ func (r *Runtime) handleStop(env protocol.Envelope) error {
 	return r.hubClient.Send(protocol.TypeStopAck, req.SessionID, ack)
 }
 
+// handleWorkspaceRollback restores a session's work dir to a turn's
+// checkpoint and records the rollback in the transcript.
+func (r *Runtime) handleWorkspaceRollback(env protocol.Envelope) error {
+	data, _ := json.Marshal(env.Payload)
+	var req protocol.WorkspaceRollback
+	if err := json.Unmarshal(data, &req); err != nil {
+		return fmt.Errorf("unmarshal workspace rollback: %w", err)
+	}
+
+	err := r.sessions.Rollback(req.SessionID, req.MessageID)
+	ack := protocol.WorkspaceRollbackAck{
+		SessionID: req.SessionID,
+		RequestID: req.RequestID,
+		OK:        err == nil,
+	}
+	if err != nil {
+		ack.Error = err.Error()
+	} else {
+		r.handleAgentOutput(req.SessionID, adapter.Output{
+			Channel: "system",
+			Data:    []byte(fmt.Sprintf("Workspace rolled back to its state before message #%d.", req.Seq)),
+		}, false)
+	}
+
+	return r.hubClient.Send(protocol.TypeWorkspaceRollbackAck, req.SessionID, ack)
+}
+
 // handleFileUpload handles file.upload from hub (user uploaded a file).
 func (r *Runtime) handleFileUpload(env protocol.Envelope) error {
 	data, _ := json.Marshal(env.Payload)
@@ -386,12 +572,22 @@ func (r *Runtime) handleFileUpload(env protocol.Envelope) error {
 		return fmt.Errorf("decode file data: %w", err)
 	}
 
+	// Sanitize path components to prevent path traversal.
+	safeSessionID := filepath.Base(upload.SessionID)
+	safeFileID := filepath.Base(upload.Metadata.FileID)
+	safeName := filepath.Base(upload.Metadata.Name)
+	for _, part := range []string{safeSessionID, safeFileID, safeName} {
+		if part == "." || part == ".." || part == string(filepath.Separator) {
+			return fmt.Errorf("invalid file upload path component %q", part)
+		}
+	}
+
 	// Save to runtime disk: {files_dir}/{session_id}/{file_id}/{filename}
-	dir := filepath.Join(r.cfg.Runtime.FileStoragePath, upload.SessionID, upload.Metadata.FileID)
+	dir := filepath.Join(r.cfg.Runtime.FileStoragePath, safeSessionID, safeFileID)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("create file dir: %w", err)
 	}
-	filePath := filepath.Join(dir, upload.Metadata.Name)
+	filePath := filepath.Join(dir, safeName)
 	if err := os.WriteFile(filePath, fileData, 0o644); err != nil {
 		return fmt.Errorf("write file: %w", err)
 	}
@@ -479,6 +675,17 @@ func (r *Runtime) handleAgentOutput(sessionID string, output adapter.Output, fin
 	})
 }
 
+// handleSecurityViolation reports an action refused by an agent's security config to the hub for auditing.
+func (r *Runtime) handleSecurityViolation(sessionID, agentID string, v *adapter.Violation) {
+	r.sendToHub(protocol.TypeSecurityViolation, sessionID, protocol.SecurityViolation{
+		SessionID: sessionID,
+		AgentID:   agentID,
+		Kind:      v.Kind,
+		Path:      v.Path,
+		Reason:    v.Reason,
+	})
+}
+
 // handlePermissionRequest is called by the session manager when an adapter needs user permission.
 func (r *Runtime) handlePermissionRequest(sessionID, tool, description, resource string) bool {
 	requestID := uuid.New().String()
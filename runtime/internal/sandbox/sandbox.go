@@ -0,0 +1,138 @@
+// Package sandbox confines agent processes to the paths and network access an
+// agent's security config allows. On Linux it uses bubblewrap (user and mount
+// namespaces) when the bwrap binary is installed, or Landlock otherwise; other
+// platforms have no sandbox backend.
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/amurg-ai/amurg/pkg/protocol"
+)
+
+// Sandbox modes accepted in security.sandbox.mode.
+const (
+	ModeOff      = "off"      // no sandbox (default)
+	ModeAuto     = "auto"     // best available backend, unsandboxed if none
+	ModeBwrap    = "bwrap"    // require bubblewrap
+	ModeLandlock = "landlock" // require Landlock
+)
+
+// ExecCommand is the hidden runtime subcommand that applies a Landlock policy
+// to itself and then execs the agent command.
+const ExecCommand = "sandbox-exec"
+
+// Policy is what a sandboxed process may touch. The whole filesystem stays
+// readable (agents need their toolchains); writes are limited to ReadWrite.
+type Policy struct {
+	ReadWrite   []string `json:"rw,omitempty"`
+	Deny        []string `json:"deny,omitempty"` // hidden entirely; enforced by bwrap only
+	DenyNetwork bool     `json:"deny_network,omitempty"`
+}
+
+// Resolve picks the backend for mode. It returns "" when the process should
+// run unsandboxed, and an error when a required backend is unavailable.
+func Resolve(mode string) (string, error) {
+	switch mode {
+	case "", ModeOff:
+		return "", nil
+	case ModeBwrap:
+		if !bwrapAvailable() {
+			return "", fmt.Errorf("sandbox mode %q: bwrap not found in PATH", mode)
+		}
+		return ModeBwrap, nil
+	case ModeLandlock:
+		if abi := landlockABI(); abi < 1 {
+			return "", fmt.Errorf("sandbox mode %q: Landlock is not supported by this kernel", mode)
+		}
+		return ModeLandlock, nil
+	case ModeAuto:
+		if bwrapAvailable() {
+			return ModeBwrap, nil
+		}
+		if landlockABI() >= 1 {
+			return ModeLandlock, nil
+		}
+		return "", nil
+	default:
+		return "", fmt.Errorf("unknown sandbox mode %q", mode)
+	}
+}
+
+// Status reports the sandbox an agent configured with mode gets on this host,
+// or nil when sandboxing is off.
+func Status(mode string, denyNetwork bool) *protocol.SandboxStatus {
+	if mode == "" || mode == ModeOff {
+		return nil
+	}
+	status := &protocol.SandboxStatus{Mode: mode, DenyNetwork: denyNetwork}
+	backend, err := Resolve(mode)
+	if err != nil {
+		status.Error = err.Error()
+	}
+	status.Backend = backend
+	return status
+}
+
+// Wrap returns the argv that runs argv inside the given backend with policy
+// applied. dir is the working directory.
+func Wrap(backend string, policy Policy, dir string, argv []string) ([]string, error) {
+	switch backend {
+	case "":
+		return argv, nil
+	case ModeBwrap:
+		return append(bwrapArgs(policy, dir), argv...), nil
+	case ModeLandlock:
+		if policy.DenyNetwork && landlockABI() < 4 {
+			return nil, fmt.Errorf("deny_network needs Landlock ABI 4 (Linux 6.7) or bwrap")
+		}
+		self, err := os.Executable()
+		if err != nil {
+			return nil, fmt.Errorf("locate runtime executable: %w", err)
+		}
+		data, err := json.Marshal(policy)
+		if err != nil {
+			return nil, err
+		}
+		return append([]string{self, ExecCommand, "--policy", string(data), "--"}, argv...), nil
+	default:
+		return nil, fmt.Errorf("unknown sandbox backend %q", backend)
+	}
+}
+
+// bwrapArgs builds the bubblewrap command line: a read-only view of the host
+// with the policy's read-write paths bound back in and denied paths masked.
+func bwrapArgs(policy Policy, dir string) []string {
+	args := []string{
+		"bwrap",
+		"--die-with-parent",
+		"--unshare-user-try", "--unshare-pid", "--unshare-ipc", "--unshare-uts",
+		"--ro-bind", "/", "/",
+		"--dev", "/dev",
+		"--proc", "/proc",
+	}
+	if policy.DenyNetwork {
+		args = append(args, "--unshare-net")
+	}
+	for _, p := range policy.ReadWrite {
+		args = append(args, "--bind-try", p, p)
+	}
+	// Denied paths come last so they win over read-write binds above them.
+	for _, p := range policy.Deny {
+		info, err := os.Stat(p)
+		switch {
+		case err != nil:
+			continue
+		case info.IsDir():
+			args = append(args, "--tmpfs", p)
+		default:
+			args = append(args, "--ro-bind", os.DevNull, p)
+		}
+	}
+	if dir != "" {
+		args = append(args, "--chdir", dir)
+	}
+	return append(args, "--")
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package sandbox
+
+import "errors"
+
+func bwrapAvailable() bool { return false }
+
+func landlockABI() int { return 0 }
+
+// Exec is only supported on Linux.
+func Exec(Policy, []string) error {
+	return errors.New("sandboxing is only supported on Linux")
+}
@@ -0,0 +1,94 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	for _, mode := range []string{"", ModeOff} {
+		backend, err := Resolve(mode)
+		if err != nil || backend != "" {
+			t.Errorf("Resolve(%q) = %q, %v; want no sandbox", mode, backend, err)
+		}
+	}
+	if _, err := Resolve("chroot"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+	// auto never fails: it falls back to running unsandboxed.
+	if _, err := Resolve(ModeAuto); err != nil {
+		t.Errorf("Resolve(auto): %v", err)
+	}
+	if Status(ModeOff, false) != nil {
+		t.Error("expected no status when sandboxing is off")
+	}
+	if st := Status(ModeAuto, true); st == nil || st.Mode != ModeAuto || !st.DenyNetwork {
+		t.Errorf("unexpected status %+v", st)
+	}
+}
+
+func TestBwrapArgs(t *testing.T) {
+	dir := t.TempDir()
+	deniedDir := filepath.Join(dir, "secrets")
+	deniedFile := filepath.Join(dir, ".env")
+	if err := os.Mkdir(deniedDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(deniedFile, []byte("TOKEN=x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	argv, err := Wrap(ModeBwrap, Policy{
+		ReadWrite:   []string{dir},
+		Deny:        []string{deniedDir, deniedFile, filepath.Join(dir, "missing")},
+		DenyNetwork: true,
+	}, dir, []string{"/usr/bin/agent", "--flag"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(argv, " ")
+	for _, want := range []string{
+		"--ro-bind / /",
+		"--unshare-net",
+		"--bind-try " + dir + " " + dir,
+		"--tmpfs " + deniedDir,
+		"--ro-bind " + os.DevNull + " " + deniedFile,
+		"--chdir " + dir,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("bwrap args missing %q: %s", want, got)
+		}
+	}
+	if strings.Contains(got, "missing") {
+		t.Errorf("non-existent denied path should be skipped: %s", got)
+	}
+	// Denied paths must be mounted after the read-write binds they override.
+	if strings.Index(got, "--tmpfs") < strings.Index(got, "--bind-try") {
+		t.Errorf("denied paths mounted before read-write binds: %s", got)
+	}
+	if !slices.Equal(argv[len(argv)-3:], []string{"--", "/usr/bin/agent", "--flag"}) {
+		t.Errorf("command not appended after --: %v", argv)
+	}
+}
+
+func TestWrapLandlock(t *testing.T) {
+	policy := Policy{ReadWrite: []string{"/work"}}
+	argv, err := Wrap(ModeLandlock, policy, "/work", []string{"/usr/bin/agent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(argv) != 6 || argv[1] != ExecCommand || argv[2] != "--policy" || argv[4] != "--" || argv[5] != "/usr/bin/agent" {
+		t.Fatalf("unexpected argv %v", argv)
+	}
+	var decoded Policy
+	if err := json.Unmarshal([]byte(argv[3]), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(decoded.ReadWrite, policy.ReadWrite) {
+		t.Fatalf("policy round trip = %+v", decoded)
+	}
+}
@@ -0,0 +1,96 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amurg-ai/amurg/runtime/internal/adapter"
+)
+
+// reviver starts a fresh agent for a hibernated session that resumes the
+// native session handle.
+type reviver func(ctx context.Context, handle string) (adapter.AgentSession, error)
+
+// canHibernate reports whether an agent can be stopped and later resumed
+// from its native session handle.
+func canHibernate(agent adapter.AgentSession) bool {
+	_, nh := agent.(adapter.NativeHandleProvider)
+	_, rs := agent.(adapter.ResumeSeeder)
+	return nh && rs
+}
+
+// enableHibernation lets the session stop its agent after idle without a
+// turn; revive brings it back on the next message.
+func (s *Session) enableHibernation(idle time.Duration, revive reviver) {
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+	s.hibernateAfter = idle
+	s.revive = revive
+	s.armHibernateLocked()
+}
+
+// armHibernate (re)starts the idle countdown after a turn ends.
+func (s *Session) armHibernate() {
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+	s.armHibernateLocked()
+}
+
+func (s *Session) armHibernateLocked() {
+	if s.revive == nil || s.State() != StateActive {
+		return
+	}
+	s.stopHibernateTimer()
+	s.hibernateTimer = time.AfterFunc(s.hibernateAfter, s.hibernate)
+}
+
+// stopHibernateTimer cancels a pending hibernation. Callers hold lifeMu.
+func (s *Session) stopHibernateTimer() {
+	if s.hibernateTimer != nil {
+		s.hibernateTimer.Stop()
+		s.hibernateTimer = nil
+	}
+}
+
+// hibernate stops an idle agent's process, keeping its native handle so
+// the next message can resume it. Sessions that became busy in the
+// meantime, or have no handle yet, keep running.
+func (s *Session) hibernate() {
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+	s.hibernateTimer = nil
+	if s.State() != StateActive {
+		return
+	}
+	handle := s.NativeHandle()
+	if handle == "" {
+		return
+	}
+
+	if err := s.agentSession().Close(); err != nil {
+		s.logger.Warn("close agent for hibernation failed", "error", err)
+	}
+	s.hibernatedHandle = handle
+	s.state.Store(StateHibernated)
+	s.logger.Info("session hibernated", "native_handle", handle, "idle", s.hibernateAfter)
+}
+
+// wake revives a hibernated agent. Callers hold lifeMu.
+func (s *Session) wake(ctx context.Context) error {
+	s.stopHibernateTimer()
+	if s.State() != StateHibernated {
+		return nil
+	}
+
+	agent, err := s.revive(ctx, s.hibernatedHandle)
+	if err != nil {
+		return fmt.Errorf("revive hibernated agent: %w", err)
+	}
+	s.mu.Lock()
+	s.agent = agent
+	s.mu.Unlock()
+	s.state.Store(StateActive)
+	s.logger.Info("session revived", "native_handle", s.hibernatedHandle)
+	return nil
+}
@@ -0,0 +1,97 @@
+package session
+
+import (
+	"context"
+
+	"github.com/amurg-ai/amurg/runtime/internal/adapter"
+	"github.com/amurg-ai/amurg/runtime/internal/journal"
+)
+
+// SetJournal records sessions in j from now on. Sessions close explicitly
+// to leave it; those still open when the runtime shuts down are restored by
+// Restore on the next start.
+func (m *Manager) SetJournal(j *journal.Journal) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.journal = j
+}
+
+// Restore recreates the journaled sessions, continuing their native
+// sessions where the adapter supports it, and returns the IDs of those
+// restored. Sessions that cannot be restored leave the journal.
+func (m *Manager) Restore(ctx context.Context) []string {
+	m.mu.RLock()
+	j := m.journal
+	m.mu.RUnlock()
+	if j == nil {
+		return nil
+	}
+
+	var restored []string
+	for _, e := range j.Entries() {
+		err := m.create(ctx, e.SessionID, e.AgentID, e.UserID, e.NativeHandle, createRestore, e.PromptProfile)
+		if err != nil {
+			m.logger.Warn("restore journaled session failed", "session_id", e.SessionID, "agent_id", e.AgentID, "error", err)
+			if derr := j.Delete(e.SessionID); derr != nil {
+				m.logger.Warn("update session journal failed", "session_id", e.SessionID, "error", derr)
+			}
+			continue
+		}
+		if sess, ok := m.Get(e.SessionID); ok && e.WorkDir != "" && sess.worktree != nil && sess.worktree.Path != e.WorkDir {
+			m.logger.Warn("restored session moved to a different worktree", "session_id", e.SessionID,
+				"journaled", e.WorkDir, "path", sess.worktree.Path)
+		}
+		restored = append(restored, e.SessionID)
+	}
+	return restored
+}
+
+// sessionOutput forwards a session's output to the output handler, keeping
+// the journal's native handle current as turns end.
+func (m *Manager) sessionOutput(sessionID string, out adapter.Output, final bool) {
+	if final {
+		if sess, ok := m.Get(sessionID); ok {
+			m.journalSession(sess)
+		}
+	}
+	m.onOutput(sessionID, out, final)
+}
+
+// journalSession records sess in the journal. It does not take m.mu, so
+// create can call it while holding the lock.
+func (m *Manager) journalSession(sess *Session) {
+	if m.journal == nil {
+		return
+	}
+	e := journal.Entry{
+		SessionID:     sess.ID,
+		AgentID:       sess.AgentID,
+		UserID:        sess.UserID,
+		PromptProfile: sess.promptProfile,
+		NativeHandle:  sess.NativeHandle(),
+		Branch:        sess.Branch(),
+		CreatedAt:     sess.CreatedAt,
+	}
+	if sess.worktree != nil {
+		e.WorkDir = sess.worktree.Path
+	}
+	if old, ok := m.journal.Get(sess.ID); ok {
+		e.CreatedAt = old.CreatedAt
+		if e.NativeHandle == "" {
+			e.NativeHandle = old.NativeHandle
+		}
+	}
+	if err := m.journal.Put(e); err != nil {
+		m.logger.Warn("update session journal failed", "session_id", sess.ID, "error", err)
+	}
+}
+
+// unjournalSession removes a closed session from the journal.
+func (m *Manager) unjournalSession(sessionID string) {
+	if m.journal == nil {
+		return
+	}
+	if err := m.journal.Delete(sessionID); err != nil {
+		m.logger.Warn("update session journal failed", "session_id", sessionID, "error", err)
+	}
+}
@@ -0,0 +1,76 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amurg-ai/amurg/runtime/internal/adapter"
+	"github.com/amurg-ai/amurg/runtime/internal/cgroup"
+	"github.com/amurg-ai/amurg/runtime/internal/config"
+)
+
+const (
+	// limitPollInterval is how often a session's cgroup events are checked.
+	limitPollInterval = 2 * time.Second
+	// throttleNoticeInterval rate-limits CPU throttling notices, which would
+	// otherwise repeat for every busy poll.
+	throttleNoticeInterval = time.Minute
+)
+
+// cgroupLimits extracts the cgroup limits from an agent's limits.
+func cgroupLimits(l *config.AgentLimits) cgroup.Limits {
+	if l == nil {
+		return cgroup.Limits{}
+	}
+	return cgroup.Limits{CPUQuota: l.CPUQuota, MemoryMax: l.MemoryMaxBytes, PidsMax: l.PidsMax}
+}
+
+// attachCgroup ties g to the session: limit enforcement is reported as system
+// output until the session closes, and Close removes the cgroup.
+func (s *Session) attachCgroup(g *cgroup.Group) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cgroup = g
+	s.stopMonitor = cancel
+	go s.monitorLimits(ctx, g)
+}
+
+// monitorLimits polls the session's cgroup and surfaces OOM kills, refused
+// forks and CPU throttling.
+func (s *Session) monitorLimits(ctx context.Context, g *cgroup.Group) {
+	ticker := time.NewTicker(limitPollInterval)
+	defer ticker.Stop()
+
+	last, _ := g.Events()
+	var lastThrottleNotice time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ev, err := g.Events()
+		if err != nil {
+			s.logger.Debug("read cgroup events failed", "error", err)
+			continue
+		}
+		if ev.OOMKills > last.OOMKills {
+			s.emitSystem(fmt.Sprintf("Memory limit reached: %d process(es) killed by the out-of-memory killer.", ev.OOMKills-last.OOMKills))
+		}
+		if ev.PidsMax > last.PidsMax {
+			s.emitSystem("Process limit reached: new processes are being refused.")
+		}
+		if ev.Throttled > last.Throttled && time.Since(lastThrottleNotice) >= throttleNoticeInterval {
+			s.emitSystem("CPU limit reached: the agent is being throttled.")
+			lastThrottleNotice = time.Now()
+		}
+		last = ev
+	}
+}
+
+func (s *Session) emitSystem(msg string) {
+	s.logger.Warn("resource limit enforced", "message", msg)
+	if s.onOutput != nil {
+		s.onOutput(s.ID, adapter.Output{Channel: "system", Data: []byte(msg)}, false)
+	}
+}
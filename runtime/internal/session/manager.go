@@ -2,6 +2,7 @@ package session
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
@@ -10,24 +11,42 @@ import (
 	"github.com/amurg-ai/amurg/pkg/promptprofile"
 	"github.com/amurg-ai/amurg/pkg/protocol"
 	"github.com/amurg-ai/amurg/runtime/internal/adapter"
+	"github.com/amurg-ai/amurg/runtime/internal/cgroup"
 	"github.com/amurg-ai/amurg/runtime/internal/config"
+	"github.com/amurg-ai/amurg/runtime/internal/journal"
+	"github.com/amurg-ai/amurg/runtime/internal/workspace"
 )
 
 // PermissionRequestFunc is called when an adapter needs user permission.
 type PermissionRequestFunc func(sessionID, tool, description, resource string) bool
 
+// ViolationHandler is called when an agent's security config refuses a
+// session, a file delivery or a config update. sessionID is empty for
+// agent-level violations.
+type ViolationHandler func(sessionID, agentID string, v *adapter.Violation)
+
 // Manager tracks all active sessions and enforces runtime limits.
 type Manager struct {
 	cfg      config.RuntimeConfig
 	registry *adapter.Registry
+	cgroups  *cgroup.Manager
 	logger   *slog.Logger
 
 	mu        sync.RWMutex
 	sessions  map[string]*Session
 	agentCfgs map[string]config.AgentConfig
 
+	// Session creations waiting for a free slot, oldest first, and the
+	// number of slots handed to queued creations that have yet to start.
+	queue    []*pendingCreate
+	reserved int
+
+	journal *journal.Journal // nil when sessions are not journaled
+
 	onOutput            OutputHandler
 	onPermissionRequest PermissionRequestFunc
+	onViolation         ViolationHandler
+	onQueue             QueueHandler
 }
 
 // NewManager creates a session manager.
@@ -47,6 +66,7 @@ func NewManager(
 	return &Manager{
 		cfg:                 cfg,
 		registry:            registry,
+		cgroups:             cgroup.NewManager(logger),
 		logger:              logger,
 		sessions:            make(map[string]*Session),
 		agentCfgs:           agentCfgs,
@@ -55,6 +75,32 @@ func NewManager(
 	}
 }
 
+// SetViolationHandler registers a callback for security policy violations.
+func (m *Manager) SetViolationHandler(h ViolationHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onViolation = h
+}
+
+// reportViolation surfaces a security violation as system output in the
+// session (if any) and to the violation handler. Callers may hold m.mu.
+func (m *Manager) reportViolation(sessionID, agentID string, err error) {
+	var v *adapter.Violation
+	if !errors.As(err, &v) {
+		return
+	}
+	m.logger.Warn("security policy violation", "session_id", sessionID, "agent_id", agentID, "error", err)
+	if sessionID != "" && m.onOutput != nil {
+		m.onOutput(sessionID, adapter.Output{
+			Channel: "system",
+			Data:    []byte("Blocked by security policy: " + v.Error()),
+		}, false)
+	}
+	if m.onViolation != nil {
+		m.onViolation(sessionID, agentID, v)
+	}
+}
+
 // Create creates a new session for the given agent.
 func (m *Manager) Create(ctx context.Context, sessionID, agentID, userID, profileID string) error {
 	return m.CreateWithResume(ctx, sessionID, agentID, userID, "", profileID)
@@ -62,12 +108,41 @@ func (m *Manager) Create(ctx context.Context, sessionID, agentID, userID, profil
 
 // CreateWithResume creates a new session, optionally resuming a native session.
 func (m *Manager) CreateWithResume(ctx context.Context, sessionID, agentID, userID, resumeSessionID, profileID string) error {
+	return m.create(ctx, sessionID, agentID, userID, resumeSessionID, createResume, profileID)
+}
+
+// CreateFork creates a new session that branches the native session
+// forkSessionID. Adapters that cannot fork natively start a fresh session
+// rather than continuing the original. The hub already holds the transcript,
+// so native history is not replayed.
+func (m *Manager) CreateFork(ctx context.Context, sessionID, agentID, userID, forkSessionID, profileID string) error {
+	return m.create(ctx, sessionID, agentID, userID, forkSessionID, createFork, profileID)
+}
+
+// createMode says how a new session relates to the native session it is
+// given, if any.
+type createMode int
+
+const (
+	createResume  createMode = iota // continue it and replay its history
+	createFork                      // branch it
+	createRestore                   // continue it after a runtime restart; the hub has the history
+)
+
+func (m *Manager) create(ctx context.Context, sessionID, agentID, userID, resumeSessionID string, mode createMode, profileID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if len(m.sessions) >= m.cfg.MaxSessions {
+	// Restored sessions never wait in the queue: the runtime is not
+	// connected to the hub yet.
+	if mode == createRestore && !m.hasFreeSlot() {
 		return fmt.Errorf("max sessions reached (%d)", m.cfg.MaxSessions)
 	}
+	if err := m.waitForSlot(ctx, sessionID); err != nil {
+		return err
+	}
+	// A failed creation hands its slot on to the next queued one.
+	defer m.promoteQueued()
 
 	if _, exists := m.sessions[sessionID]; exists {
 		return fmt.Errorf("session %s already exists", sessionID)
@@ -79,38 +154,90 @@ func (m *Manager) CreateWithResume(ctx context.Context, sessionID, agentID, user
 	}
 	agentCfg.PromptProfile = promptprofile.Normalize(profileID)
 
+	if err := adapter.CheckAgentPaths(agentCfg); err != nil {
+		m.reportViolation(sessionID, agentID, err)
+		return fmt.Errorf("security policy: %w", err)
+	}
+
 	adp, err := m.registry.Get(agentCfg.Profile)
 	if err != nil {
 		return err
 	}
 
+	// In worktree mode the session runs in its own checkout of the work dir.
+	var wt *workspace.Worktree
+	if usesWorktree(agentCfg) {
+		wt, err = createWorktree(ctx, sessionID, agentCfg)
+		if err != nil {
+			return fmt.Errorf("create worktree: %w", err)
+		}
+		agentCfg.Security = worktreeSecurity(agentCfg.Security, wt)
+		if err := adapter.CheckAgentPaths(agentCfg); err != nil {
+			discardWorktree(wt)
+			m.reportViolation(sessionID, agentID, err)
+			return fmt.Errorf("security policy: %w", err)
+		}
+	}
+
+	// Resource limits fail open: a host without cgroup v2 delegation still
+	// runs the session, with a warning in its output.
+	var group *cgroup.Group
+	if limits := cgroupLimits(agentCfg.Limits); !limits.IsZero() {
+		group, err = m.cgroups.Create(sessionID, limits)
+		if err != nil {
+			m.logger.Warn("session cgroup unavailable", "session_id", sessionID, "error", err)
+			if m.onOutput != nil {
+				m.onOutput(sessionID, adapter.Output{
+					Channel: "system",
+					Data:    []byte("Resource limits not applied: " + err.Error()),
+				}, false)
+			}
+		} else {
+			agentCfg.Cgroup = group.Path()
+		}
+	}
+
 	agentSess, err := adp.Start(ctx, agentCfg)
 	if err != nil {
+		if group != nil {
+			_ = group.Remove()
+		}
+		if wt != nil {
+			discardWorktree(wt)
+		}
 		return fmt.Errorf("start agent: %w", err)
 	}
 
-	// Pre-seed native session ID for resume if provided.
+	// Pre-seed native session ID for resume or fork if provided.
 	if resumeSessionID != "" {
-		if rs, ok := agentSess.(adapter.ResumeSeeder); ok {
+		if mode == createFork {
+			if fs, ok := agentSess.(adapter.ForkSeeder); ok {
+				fs.SetForkSessionID(resumeSessionID)
+			}
+		} else if rs, ok := agentSess.(adapter.ResumeSeeder); ok {
 			rs.SetResumeSessionID(resumeSessionID)
 		}
 	}
 
-	// Wire up permission handler if supported.
-	if pr, ok := agentSess.(adapter.PermissionRequester); ok && m.onPermissionRequest != nil {
-		sid := sessionID
-		pr.SetPermissionHandler(func(tool, description, resource string) bool {
-			return m.onPermissionRequest(sid, tool, description, resource)
-		})
-	}
+	m.wirePermissions(sessionID, agentSess)
 
-	sess := NewSession(sessionID, agentID, userID, agentSess, m.onOutput, m.logger)
+	sess := NewSession(sessionID, agentID, userID, agentSess, m.sessionOutput, m.logger)
+	sess.promptProfile = agentCfg.PromptProfile
+	if group != nil {
+		sess.attachCgroup(group)
+	}
+	sess.worktree = wt
+	sess.diffDir, sess.diffMaxBytes = turnDiffConfig(ctx, agentCfg)
+	if m.cfg.HibernateAfter.Duration > 0 && canHibernate(agentSess) {
+		sess.enableHibernation(m.cfg.HibernateAfter.Duration, m.reviveAgent(sess, adp))
+	}
 	m.sessions[sessionID] = sess
+	m.journalSession(sess)
 
 	// Load native history if this is a resumed session.
 	// History is loaded and emitted directly via onOutput (bypassing the
 	// adapter output channel) to avoid drain timing issues.
-	if resumeSessionID != "" {
+	if resumeSessionID != "" && mode == createResume {
 		if hl, ok := agentSess.(adapter.HistoryLoader); ok {
 			onOut := m.onOutput
 			go func() {
@@ -129,10 +256,52 @@ func (m *Manager) CreateWithResume(ctx context.Context, sessionID, agentID, user
 	}
 
 	m.logger.Info("session created", "session_id", sessionID, "agent_id", agentID, "user_id", userID,
-		"resume_session_id", resumeSessionID, "prompt_profile", agentCfg.PromptProfile)
+		"resume_session_id", resumeSessionID, "prompt_profile", agentCfg.PromptProfile, "branch", sess.Branch())
 	return nil
 }
 
+// wirePermissions routes the agent's permission requests, if it makes any,
+// to the permission handler.
+func (m *Manager) wirePermissions(sessionID string, agentSess adapter.AgentSession) {
+	if pr, ok := agentSess.(adapter.PermissionRequester); ok && m.onPermissionRequest != nil {
+		pr.SetPermissionHandler(func(tool, description, resource string) bool {
+			return m.onPermissionRequest(sessionID, tool, description, resource)
+		})
+	}
+}
+
+// reviveAgent returns the reviver of a hibernated session. The agent is
+// restarted with the agent's current config inside the session's worktree
+// and cgroup, resuming the native session it left off.
+func (m *Manager) reviveAgent(sess *Session, adp adapter.Adapter) reviver {
+	return func(ctx context.Context, handle string) (adapter.AgentSession, error) {
+		m.mu.RLock()
+		agentCfg := m.agentCfgs[sess.AgentID]
+		m.mu.RUnlock()
+		agentCfg.PromptProfile = sess.promptProfile
+		if sess.worktree != nil {
+			agentCfg.Security = worktreeSecurity(agentCfg.Security, sess.worktree)
+		}
+		if sess.cgroup != nil {
+			agentCfg.Cgroup = sess.cgroup.Path()
+		}
+		if err := adapter.CheckAgentPaths(agentCfg); err != nil {
+			m.reportViolation(sess.ID, sess.AgentID, err)
+			return nil, fmt.Errorf("security policy: %w", err)
+		}
+
+		agentSess, err := adp.Start(ctx, agentCfg)
+		if err != nil {
+			return nil, fmt.Errorf("start agent: %w", err)
+		}
+		if rs, ok := agentSess.(adapter.ResumeSeeder); ok {
+			rs.SetResumeSessionID(handle)
+		}
+		m.wirePermissions(sess.ID, agentSess)
+		return agentSess, nil
+	}
+}
+
 // GetAgentProfile returns the profile for an agent.
 func (m *Manager) GetAgentProfile(agentID string) string {
 	m.mu.RLock()
@@ -143,8 +312,9 @@ func (m *Manager) GetAgentProfile(agentID string) string {
 	return ""
 }
 
-// Send delivers a user message to a session's agent.
-func (m *Manager) Send(ctx context.Context, sessionID string, input []byte) error {
+// Send delivers a user message to a session's agent. messageID identifies
+// the turn's workspace checkpoint.
+func (m *Manager) Send(ctx context.Context, sessionID, messageID string, input []byte) error {
 	m.mu.RLock()
 	sess, ok := m.sessions[sessionID]
 	m.mu.RUnlock()
@@ -159,11 +329,11 @@ func (m *Manager) Send(ctx context.Context, sessionID string, input []byte) erro
 		idleTimeout = agentCfg.Limits.IdleTimeout.Duration
 	}
 
-	return sess.Send(ctx, input, idleTimeout)
+	return sess.Send(ctx, messageID, input, idleTimeout)
 }
 
 // SendInteractive delivers follow-up input to an already running interactive session.
-func (m *Manager) SendInteractive(ctx context.Context, sessionID string, input []byte) error {
+func (m *Manager) SendInteractive(ctx context.Context, sessionID, messageID string, input []byte) error {
 	m.mu.RLock()
 	sess, ok := m.sessions[sessionID]
 	m.mu.RUnlock()
@@ -177,7 +347,7 @@ func (m *Manager) SendInteractive(ctx context.Context, sessionID string, input [
 		idleTimeout = agentCfg.Limits.IdleTimeout.Duration
 	}
 
-	return sess.SendInteractive(ctx, input, idleTimeout)
+	return sess.SendInteractive(ctx, messageID, input, idleTimeout)
 }
 
 // Stop requests stop for a session.
@@ -197,16 +367,59 @@ func (m *Manager) Stop(sessionID string) error {
 func (m *Manager) Close(sessionID string) error {
 	m.mu.Lock()
 	sess, ok := m.sessions[sessionID]
+	var agentCfg config.AgentConfig
 	if ok {
 		delete(m.sessions, sessionID)
+		agentCfg = m.agentCfgs[sess.AgentID]
 	}
+	queued := !ok && m.cancelQueued(sessionID, ErrQueueCanceled)
 	m.mu.Unlock()
 
+	if queued {
+		m.logger.Info("queued session creation canceled", "session_id", sessionID)
+		return nil
+	}
 	if !ok {
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
+	m.unjournalSession(sessionID)
 
-	return sess.Close()
+	err := sess.Close()
+	m.mu.Lock()
+	m.promoteQueued()
+	m.mu.Unlock()
+	// Worktrees and checkpoints outlive runtime shutdown (CloseAll) so a
+	// recreated session can pick up where it stopped; only an explicit close
+	// cleans them up.
+	sess.deleteCheckpoints()
+	if sess.worktree != nil {
+		m.closeWorktree(sess, agentCfg)
+	}
+	return err
+}
+
+// Rollback restores a session's work dir to the checkpoint taken when the
+// turn started by messageID began.
+func (m *Manager) Rollback(sessionID, messageID string) error {
+	m.mu.RLock()
+	sess, ok := m.sessions[sessionID]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	return sess.Rollback(messageID)
+}
+
+// GetBranch returns the git branch of a session's worktree, or "" if the
+// session has none.
+func (m *Manager) GetBranch(sessionID string) string {
+	m.mu.RLock()
+	sess, ok := m.sessions[sessionID]
+	m.mu.RUnlock()
+	if !ok {
+		return ""
+	}
+	return sess.Branch()
 }
 
 // GetNativeHandle returns the native handle for a session, or "" if unknown.
@@ -236,6 +449,9 @@ func (m *Manager) CloseAll() {
 		sessions = append(sessions, s)
 	}
 	m.sessions = make(map[string]*Session)
+	for len(m.queue) > 0 {
+		m.cancelQueued(m.queue[0].sessionID, ErrQueueCanceled)
+	}
 	m.mu.Unlock()
 
 	for _, s := range sessions {
@@ -251,6 +467,11 @@ func (m *Manager) CloseAll() {
 func (m *Manager) DeliverFile(sessionID, filePath string, meta protocol.FileMetadata) {
 	m.mu.RLock()
 	sess, ok := m.sessions[sessionID]
+	var agentCfg config.AgentConfig
+	isKnown := false
+	if ok {
+		agentCfg, isKnown = m.agentCfgs[sess.AgentID]
+	}
 	m.mu.RUnlock()
 
 	if !ok {
@@ -258,13 +479,19 @@ func (m *Manager) DeliverFile(sessionID, filePath string, meta protocol.FileMeta
 		return
 	}
 
+	if err := adapter.CheckUploadPath(agentCfg.Security, m.cfg.FileStoragePath, filePath); err != nil {
+		m.reportViolation(sessionID, sess.AgentID, err)
+		m.reportFileNotDelivered(sessionID, meta.Name)
+		return
+	}
+
 	// Check if the adapter is an external adapter by looking at the agent profile.
-	agentCfg, isKnown := m.agentCfgs[sess.AgentID]
 	if isKnown && agentCfg.Profile == "external" {
 		// External adapters get native file protocol via DeliverFileToExternal.
-		if fd, ok := sess.agent.(adapter.FileDeliverer); ok {
+		if fd, ok := sess.agentSession().(adapter.FileDeliverer); ok {
 			if err := fd.DeliverFile(filePath, meta.Name, meta.MimeType); err != nil {
 				m.logger.Warn("deliver file to external adapter failed", "session_id", sessionID, "error", err)
+				m.reportFileNotDelivered(sessionID, meta.Name)
 			}
 			return
 		}
@@ -279,8 +506,19 @@ func (m *Manager) DeliverFile(sessionID, filePath string, meta protocol.FileMeta
 		idleTimeout = ac.Limits.IdleTimeout.Duration
 	}
 
-	if err := sess.Send(ctx, []byte(msg), idleTimeout); err != nil {
+	if err := sess.Send(ctx, "", []byte(msg), idleTimeout); err != nil {
 		m.logger.Warn("deliver file path message failed", "session_id", sessionID, "error", err)
+		m.reportFileNotDelivered(sessionID, meta.Name)
+	}
+}
+
+// reportFileNotDelivered tells the user an upload never reached the agent.
+func (m *Manager) reportFileNotDelivered(sessionID, name string) {
+	if m.onOutput != nil {
+		m.onOutput(sessionID, adapter.Output{
+			Channel: "system",
+			Data:    []byte(fmt.Sprintf("File %q was not delivered to the agent.", name)),
+		}, false)
 	}
 }
 
@@ -353,22 +591,54 @@ func (m *Manager) UpdateAgentConfig(agentID string, security *protocol.SecurityP
 				agentCfg.Limits.IdleTimeout = config.Duration{Duration: d}
 			}
 		}
+		if limits.CPUQuota > 0 {
+			agentCfg.Limits.CPUQuota = limits.CPUQuota
+		}
+		if limits.MemoryMaxBytes > 0 {
+			agentCfg.Limits.MemoryMaxBytes = limits.MemoryMaxBytes
+		}
+		if limits.PidsMax > 0 {
+			agentCfg.Limits.PidsMax = limits.PidsMax
+		}
 	}
 
+	if err := adapter.CheckAgentPaths(agentCfg); err != nil {
+		m.reportViolation("", agentID, err)
+		return fmt.Errorf("security policy: %w", err)
+	}
 	m.agentCfgs[agentID] = agentCfg
 
+	// Apply new resource limits to running sessions' cgroups. Sessions started
+	// without limits pick them up when they are next created.
+	if limits != nil {
+		for _, sess := range m.sessions {
+			if sess.AgentID != agentID || sess.cgroup == nil {
+				continue
+			}
+			if err := sess.cgroup.SetLimits(cgroupLimits(agentCfg.Limits)); err != nil {
+				m.logger.Warn("update session cgroup limits failed", "session_id", sess.ID, "error", err)
+			}
+		}
+	}
+
 	// Propagate security config to running sessions for this agent.
 	if security != nil && agentCfg.Security != nil {
 		resumeOnRestart := security.PermissionMode != ""
 		for _, sess := range m.sessions {
-			if sess.AgentID != agentID {
+			// Hibernated sessions pick up the new config when revived.
+			if sess.AgentID != agentID || sess.State() == StateHibernated {
 				continue
 			}
-			if su, ok := sess.agent.(adapter.SecurityUpdater); ok {
-				restart := su.UpdateSecurity(agentCfg.Security)
+			agent := sess.agentSession()
+			if su, ok := agent.(adapter.SecurityUpdater); ok {
+				security := agentCfg.Security
+				if sess.worktree != nil {
+					security = worktreeSecurity(security, sess.worktree)
+				}
+				restart := su.UpdateSecurity(security)
 				if restart {
 					if resumeOnRestart {
-						if rs, ok := sess.agent.(adapter.ResumeSeeder); ok {
+						if rs, ok := agent.(adapter.ResumeSeeder); ok {
 							if nativeHandle := sess.NativeHandle(); nativeHandle != "" {
 								rs.SetResumeSessionID(nativeHandle)
 							}
@@ -377,7 +647,7 @@ func (m *Manager) UpdateAgentConfig(agentID string, security *protocol.SecurityP
 					// Stop the process — it auto-restarts with new flags on next Send().
 					m.logger.Info("stopping session for security config restart",
 						"session_id", sess.ID, "agent_id", agentID)
-					_ = sess.agent.Stop()
+					_ = agent.Stop()
 				}
 			}
 		}
@@ -386,6 +656,46 @@ func (m *Manager) UpdateAgentConfig(agentID string, security *protocol.SecurityP
 	return nil
 }
 
+// PutAgent adds an agent definition or replaces an existing one. Open
+// sessions keep the agent they were started with; new sessions use the new
+// definition.
+func (m *Manager) PutAgent(agentCfg config.AgentConfig) error {
+	if _, err := m.registry.Get(agentCfg.Profile); err != nil {
+		return err
+	}
+	if err := adapter.CheckAgentPaths(agentCfg); err != nil {
+		m.reportViolation("", agentCfg.ID, err)
+		return fmt.Errorf("security policy: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.agentCfgs[agentCfg.ID] = agentCfg
+	return nil
+}
+
+// RemoveAgent deletes an agent definition. It refuses while the agent still
+// has open sessions.
+func (m *Manager) RemoveAgent(agentID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.agentCfgs[agentID]; !ok {
+		return fmt.Errorf("unknown agent: %s", agentID)
+	}
+	open := 0
+	for _, sess := range m.sessions {
+		if sess.AgentID == agentID {
+			open++
+		}
+	}
+	if open > 0 {
+		return fmt.Errorf("agent %s has %d open sessions", agentID, open)
+	}
+	delete(m.agentCfgs, agentID)
+	return nil
+}
+
 // ActiveCount returns the number of active sessions.
 func (m *Manager) ActiveCount() int {
 	m.mu.RLock()
@@ -2,15 +2,22 @@ package session
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/amurg-ai/amurg/pkg/protocol"
 	"github.com/amurg-ai/amurg/runtime/internal/adapter"
+	"github.com/amurg-ai/amurg/runtime/internal/cgroup"
 	"github.com/amurg-ai/amurg/runtime/internal/config"
+	"github.com/amurg-ai/amurg/runtime/internal/journal"
 )
 
 // mockAdapter implements adapter.Adapter for testing.
@@ -184,6 +191,106 @@ func TestManager_Create_MaxSessionsEnforced(t *testing.T) {
 	}
 }
 
+func newQueueTestManager(t *testing.T, size int, timeout time.Duration, onQueue QueueHandler) *Manager {
+	t.Helper()
+	m := newTestManager(t)
+	m.cfg.MaxSessions = 1
+	m.cfg.QueueSize = size
+	m.cfg.QueueTimeout = config.Duration{Duration: timeout}
+	if onQueue != nil {
+		m.SetQueueHandler(onQueue)
+	}
+	if err := m.Create(context.Background(), "sess-1", "ep-1", "user-1", "standard"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	return m
+}
+
+func TestManager_Create_QueuesAtMaxSessions(t *testing.T) {
+	type update struct {
+		id  string
+		pos int
+	}
+	updates := make(chan update, 10)
+	m := newQueueTestManager(t, 2, time.Minute, func(id string, pos int) {
+		updates <- update{id, pos}
+	})
+	next := func() update {
+		t.Helper()
+		select {
+		case u := <-updates:
+			return u
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for queue update")
+			return update{}
+		}
+	}
+
+	if !m.MustQueue() {
+		t.Fatal("expected MustQueue at max sessions")
+	}
+	results := make(chan error, 2)
+	for i, id := range []string{"sess-2", "sess-3"} {
+		go func() { results <- m.Create(context.Background(), id, "ep-1", "user-1", "standard") }()
+		if u := next(); u != (update{id, i + 1}) {
+			t.Fatalf("expected %s at position %d, got %+v", id, i+1, u)
+		}
+	}
+
+	// A full queue rejects further creations.
+	if err := m.Create(context.Background(), "sess-4", "ep-1", "user-1", "standard"); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	// Closing a session starts the oldest queued one and moves the rest up.
+	if err := m.Close("sess-1"); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if u := next(); u != (update{"sess-3", 1}) {
+		t.Fatalf("expected sess-3 to move up, got %+v", u)
+	}
+	if err := <-results; err != nil {
+		t.Fatalf("queued create: %v", err)
+	}
+	if _, ok := m.Get("sess-2"); !ok {
+		t.Error("expected sess-2 to start first")
+	}
+
+	// Closing a queued session cancels its creation.
+	if err := m.Close("sess-3"); err != nil {
+		t.Fatalf("close queued: %v", err)
+	}
+	if err := <-results; !errors.Is(err, ErrQueueCanceled) {
+		t.Fatalf("expected ErrQueueCanceled, got %v", err)
+	}
+	if m.ActiveCount() != 1 {
+		t.Errorf("expected 1 active session, got %d", m.ActiveCount())
+	}
+}
+
+func TestManager_Create_QueueTimeout(t *testing.T) {
+	m := newQueueTestManager(t, 1, 20*time.Millisecond, nil)
+
+	err := m.Create(context.Background(), "sess-2", "ep-1", "user-1", "standard")
+	if !errors.Is(err, ErrQueueTimeout) {
+		t.Fatalf("expected ErrQueueTimeout, got %v", err)
+	}
+	if !m.MustQueue() {
+		t.Error("expected the session slot to stay taken")
+	}
+
+	// The timed-out creation left the queue, so a closed slot is free again.
+	if err := m.Close("sess-1"); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if m.MustQueue() {
+		t.Error("expected a free slot after close")
+	}
+	if err := m.Create(context.Background(), "sess-3", "ep-1", "user-1", "standard"); err != nil {
+		t.Fatalf("create after timeout: %v", err)
+	}
+}
+
 func TestManager_Get(t *testing.T) {
 	m := newTestManager(t)
 
@@ -268,7 +375,7 @@ func TestManager_SendInteractive(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if err := m.SendInteractive(context.Background(), "sess-1", []byte("reply")); err != nil {
+	if err := m.SendInteractive(context.Background(), "sess-1", "msg-1", []byte("reply")); err != nil {
 		t.Fatalf("unexpected error sending interactive input: %v", err)
 	}
 
@@ -333,7 +440,7 @@ func TestManager_Send(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	err = m.Send(context.Background(), "sess-1", []byte("hello"))
+	err = m.Send(context.Background(), "sess-1", "msg-1", []byte("hello"))
 	if err != nil {
 		t.Fatalf("unexpected error sending: %v", err)
 	}
@@ -356,7 +463,7 @@ func TestManager_Send(t *testing.T) {
 func TestManager_Send_NotFound(t *testing.T) {
 	m := newTestManager(t)
 
-	err := m.Send(context.Background(), "nonexistent", []byte("hello"))
+	err := m.Send(context.Background(), "nonexistent", "msg-1", []byte("hello"))
 	if err == nil {
 		t.Fatal("expected error sending to nonexistent session, got nil")
 	}
@@ -424,6 +531,116 @@ func TestManager_Create_PassesPromptProfileToAdapter(t *testing.T) {
 	}
 }
 
+func TestManager_HibernatesIdleAgentAndRevivesOnSend(t *testing.T) {
+	registry := adapter.NewRegistry()
+	adp := &restartOnSecurityAdapter{}
+	registry.Register("restart-profile", adp)
+	registry.Register("test-profile", &mockAdapter{})
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	m := NewManager(config.RuntimeConfig{
+		ID:             "test-runtime",
+		MaxSessions:    3,
+		IdleTimeout:    config.Duration{Duration: time.Second},
+		HibernateAfter: config.Duration{Duration: 20 * time.Millisecond},
+	}, []config.AgentConfig{
+		{ID: "restart-1", Name: "Restart Agent", Profile: "restart-profile"},
+		{ID: "plain-1", Name: "Plain Agent", Profile: "test-profile"},
+	}, registry, func(string, adapter.Output, bool) {}, nil, logger)
+
+	if err := m.Create(context.Background(), "sess-1", "restart-1", "user-1", "standard"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := m.Create(context.Background(), "sess-2", "plain-1", "user-1", "standard"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	first := adp.session
+	sess, _ := m.Get("sess-1")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for sess.State() != StateHibernated {
+		if time.Now().After(deadline) {
+			t.Fatalf("session not hibernated, state %s", sess.State())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !first.isClosed() {
+		t.Error("expected the hibernated agent to be closed")
+	}
+	if plain, _ := m.Get("sess-2"); plain.State() != StateActive {
+		t.Errorf("agent without a native handle hibernated: state %s", plain.State())
+	}
+	if sess.NativeHandle() != "native-123" {
+		t.Errorf("hibernated session lost its native handle: %q", sess.NativeHandle())
+	}
+
+	if err := m.Send(context.Background(), "sess-1", "msg-1", []byte("hello")); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if adp.session == first {
+		t.Fatal("expected the agent to be restarted")
+	}
+	if adp.session.resumeID != "native-123" {
+		t.Errorf("revived agent resume ID = %q, want native-123", adp.session.resumeID)
+	}
+	if sess.State() != StateResponding {
+		t.Errorf("state = %s, want responding", sess.State())
+	}
+	close(adp.session.outCh)
+	m.CloseAll()
+}
+
+func TestManager_RestoresJournaledSessions(t *testing.T) {
+	j, err := journal.Open(filepath.Join(t.TempDir(), "rt.json"))
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	agents := []config.AgentConfig{{ID: "restart-1", Name: "Restart Agent", Profile: "restart-profile"}}
+	newManager := func(adp adapter.Adapter) *Manager {
+		registry := adapter.NewRegistry()
+		registry.Register("restart-profile", adp)
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+		m := NewManager(config.RuntimeConfig{ID: "test-runtime", MaxSessions: 3}, agents, registry,
+			func(string, adapter.Output, bool) {}, nil, logger)
+		m.SetJournal(j)
+		return m
+	}
+
+	m := newManager(&restartOnSecurityAdapter{})
+	for _, id := range []string{"sess-1", "sess-2"} {
+		if err := m.Create(context.Background(), id, "restart-1", "user-1", "fast"); err != nil {
+			t.Fatalf("Create %s: %v", id, err)
+		}
+	}
+	// An explicit close leaves the journal; a shutdown does not.
+	if err := m.Close("sess-2"); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	m.CloseAll()
+
+	entries := j.Entries()
+	if len(entries) != 1 || entries[0].SessionID != "sess-1" || entries[0].NativeHandle != "native-123" {
+		t.Fatalf("unexpected journal entries: %+v", entries)
+	}
+
+	adp := &restartOnSecurityAdapter{}
+	restarted := newManager(adp)
+	restored := restarted.Restore(context.Background())
+	if len(restored) != 1 || restored[0] != "sess-1" {
+		t.Fatalf("restored = %v, want [sess-1]", restored)
+	}
+	sess, ok := restarted.Get("sess-1")
+	if !ok {
+		t.Fatal("expected sess-1 to be restored")
+	}
+	if sess.UserID != "user-1" || sess.promptProfile != "fast" {
+		t.Errorf("restored session lost its user or prompt profile: %q %q", sess.UserID, sess.promptProfile)
+	}
+	if adp.session.resumeID != "native-123" {
+		t.Errorf("restored agent resume ID = %q, want native-123", adp.session.resumeID)
+	}
+}
+
 func TestManager_UpdateAgentConfig_PermissionChangeSeedsResumeSessionID(t *testing.T) {
 	registry := adapter.NewRegistry()
 	adp := &restartOnSecurityAdapter{}
@@ -463,3 +680,428 @@ func TestManager_UpdateAgentConfig_PermissionChangeSeedsResumeSessionID(t *testi
 		t.Fatalf("expected 1 stop call, got %d", adp.session.stopCalls)
 	}
 }
+
+func TestManager_CreateFork_SkipsHistoryAndResume(t *testing.T) {
+	registry := adapter.NewRegistry()
+	adp := &forkAdapter{}
+	registry.Register("fork-profile", adp)
+	registry.Register("history-profile", &historyAdapter{})
+
+	var (
+		mu      sync.Mutex
+		outputs []adapter.Output
+	)
+	handler := func(_ string, out adapter.Output, _ bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		outputs = append(outputs, out)
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	m := NewManager(config.RuntimeConfig{ID: "test-runtime", MaxSessions: 3}, []config.AgentConfig{
+		{ID: "fork-1", Name: "Fork Agent", Profile: "fork-profile"},
+		{ID: "hist-1", Name: "History Agent", Profile: "history-profile"},
+	}, registry, handler, nil, logger)
+
+	if err := m.CreateFork(context.Background(), "sess-1", "fork-1", "user-1", "native-1", ""); err != nil {
+		t.Fatalf("CreateFork: %v", err)
+	}
+	if adp.session.forkID != "native-1" || adp.session.resumeID != "" {
+		t.Fatalf("expected fork of native-1, got fork %q resume %q", adp.session.forkID, adp.session.resumeID)
+	}
+
+	// Adapters without native fork start fresh instead of continuing the original.
+	if err := m.CreateFork(context.Background(), "sess-2", "hist-1", "user-1", "native-1", ""); err != nil {
+		t.Fatalf("CreateFork: %v", err)
+	}
+	sess, _ := m.Get("sess-2")
+	if hs := sess.agent.(*historyAgentSession); hs.resumeID != "" {
+		t.Fatalf("expected no resume for non-forking adapter, got %q", hs.resumeID)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(outputs) != 0 {
+		t.Fatalf("expected no history replay for forks, got %d outputs", len(outputs))
+	}
+}
+
+type forkAdapter struct {
+	session *forkAgentSession
+}
+
+func (a *forkAdapter) Start(_ context.Context, _ config.AgentConfig) (adapter.AgentSession, error) {
+	a.session = &forkAgentSession{historyAgentSession: &historyAgentSession{mockAgentSession: newMockAgent()}}
+	return a.session, nil
+}
+
+type forkAgentSession struct {
+	*historyAgentSession
+	forkID string
+}
+
+func (s *forkAgentSession) SetForkSessionID(id string) {
+	s.forkID = id
+}
+
+func TestManager_Create_RefusesWorkDirOutsideAllowedPaths(t *testing.T) {
+	registry := adapter.NewRegistry()
+	registry.Register("test-profile", &mockAdapter{})
+
+	var (
+		mu         sync.Mutex
+		outputs    []adapter.Output
+		violations []*adapter.Violation
+	)
+	handler := func(_ string, out adapter.Output, _ bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		outputs = append(outputs, out)
+	}
+
+	allowed := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	m := NewManager(testManagerConfig(), []config.AgentConfig{{
+		ID:       "ep-1",
+		Name:     "Locked CLI",
+		Profile:  "test-profile",
+		CLI:      &config.CLIConfig{WorkDir: t.TempDir()},
+		Security: &config.SecurityConfig{AllowedPaths: []string{allowed}},
+	}}, registry, handler, nil, logger)
+	m.SetViolationHandler(func(sessionID, agentID string, v *adapter.Violation) {
+		mu.Lock()
+		defer mu.Unlock()
+		violations = append(violations, v)
+	})
+
+	if err := m.Create(context.Background(), "sess-1", "ep-1", "user-1", "standard"); err == nil {
+		t.Fatal("expected work dir outside allowed_paths to be refused")
+	}
+	if m.ActiveCount() != 0 {
+		t.Fatalf("expected no active sessions, got %d", m.ActiveCount())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(violations) != 1 || violations[0].Kind != "work_dir" {
+		t.Fatalf("unexpected violations %+v", violations)
+	}
+	if len(outputs) != 1 || outputs[0].Channel != "system" {
+		t.Fatalf("expected one system output, got %+v", outputs)
+	}
+}
+
+func TestManager_DeliverFile_AllowsFileStoreUnderAllowedPaths(t *testing.T) {
+	registry := adapter.NewRegistry()
+	registry.Register("test-profile", &mockAdapter{})
+
+	var (
+		mu         sync.Mutex
+		outputs    []adapter.Output
+		violations []*adapter.Violation
+	)
+	handler := func(_ string, out adapter.Output, _ bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		outputs = append(outputs, out)
+	}
+
+	workDir := t.TempDir()
+	files := t.TempDir()
+	cfg := testManagerConfig()
+	cfg.FileStoragePath = files
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	m := NewManager(cfg, []config.AgentConfig{{
+		ID:       "ep-1",
+		Name:     "Locked CLI",
+		Profile:  "test-profile",
+		CLI:      &config.CLIConfig{WorkDir: workDir},
+		Security: &config.SecurityConfig{AllowedPaths: []string{workDir}},
+	}}, registry, handler, nil, logger)
+	m.SetViolationHandler(func(sessionID, agentID string, v *adapter.Violation) {
+		mu.Lock()
+		defer mu.Unlock()
+		violations = append(violations, v)
+	})
+	if err := m.Create(context.Background(), "sess-1", "ep-1", "user-1", "standard"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Uploads land in the runtime's file store, outside the agent's
+	// allowed_paths, and still reach the agent.
+	meta := protocol.FileMetadata{Name: "report.pdf", MimeType: "application/pdf", Size: 3}
+	m.DeliverFile("sess-1", filepath.Join(files, "sess-1", "f-1", "report.pdf"), meta)
+	mu.Lock()
+	if len(violations) != 0 || len(outputs) != 0 {
+		t.Fatalf("expected the upload to be delivered, got violations %+v, outputs %+v", violations, outputs)
+	}
+	mu.Unlock()
+
+	// Denied paths still apply, and the user is told the file was refused.
+	if err := m.UpdateAgentConfig("ep-1", &protocol.SecurityProfile{AllowedPaths: []string{workDir}, DeniedPaths: []string{files}}, nil); err != nil {
+		t.Fatalf("UpdateAgentConfig: %v", err)
+	}
+	m.DeliverFile("sess-1", filepath.Join(files, "sess-1", "f-2", "report.pdf"), meta)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(violations) != 1 || violations[0].Kind != "file" {
+		t.Fatalf("unexpected violations %+v", violations)
+	}
+	if len(outputs) != 2 || !strings.Contains(string(outputs[1].Data), `"report.pdf" was not delivered`) {
+		t.Fatalf("expected the user to be told the file was refused, got %+v", outputs)
+	}
+}
+
+func TestManager_UpdateAgentConfig_RejectsViolatingSecurity(t *testing.T) {
+	m := newTestManager(t)
+
+	err := m.UpdateAgentConfig("ep-1", &protocol.SecurityProfile{DeniedPaths: []string{"/"}}, nil)
+	if err == nil {
+		t.Fatal("expected update denying the work dir to be rejected")
+	}
+	if sec := m.agentCfgs["ep-1"].Security; sec != nil && len(sec.DeniedPaths) > 0 {
+		t.Fatalf("rejected security update was stored: %+v", sec)
+	}
+}
+
+func TestManager_UpdateAgentConfig_MergesCgroupLimits(t *testing.T) {
+	m := newTestManager(t)
+
+	if err := m.UpdateAgentConfig("ep-1", nil, &protocol.AgentLimits{CPUQuota: 0.5, PidsMax: 128}); err != nil {
+		t.Fatalf("UpdateAgentConfig: %v", err)
+	}
+	if err := m.UpdateAgentConfig("ep-1", nil, &protocol.AgentLimits{MemoryMaxBytes: 1 << 30}); err != nil {
+		t.Fatalf("UpdateAgentConfig: %v", err)
+	}
+
+	got := cgroupLimits(m.agentCfgs["ep-1"].Limits)
+	want := cgroup.Limits{CPUQuota: 0.5, MemoryMax: 1 << 30, PidsMax: 128}
+	if got != want {
+		t.Fatalf("limits = %+v, want %+v", got, want)
+	}
+}
+
+func TestManager_Create_WorktreeWorkspace(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "initial"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	registry := adapter.NewRegistry()
+	capture := &capturePromptAdapter{}
+	registry.Register("capture-profile", capture)
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	m := NewManager(testManagerConfig(), []config.AgentConfig{{
+		ID: "ep-1", Name: "Worktree Agent", Profile: "capture-profile",
+		CLI:       &config.CLIConfig{Command: "bash", WorkDir: repo},
+		Workspace: &config.WorkspaceConfig{Mode: "worktree"},
+	}}, registry, func(string, adapter.Output, bool) {}, nil, logger)
+
+	if err := m.Create(context.Background(), "sess-1", "ep-1", "user-1", "standard"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if got := m.GetBranch("sess-1"); got != "amurg/sess-1" {
+		t.Fatalf("branch = %q, want amurg/sess-1", got)
+	}
+	sec := capture.lastConfig.Security
+	if sec == nil || sec.Cwd == "" || sec.Cwd == repo {
+		t.Fatalf("adapter not started in a worktree: %+v", sec)
+	}
+	if m.agentCfgs["ep-1"].Security != nil {
+		t.Fatal("worktree path leaked into the shared agent config")
+	}
+
+	// A clean worktree is removed on close under the default policy.
+	if err := m.Close("sess-1"); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(sec.Cwd); !os.IsNotExist(err) {
+		t.Fatalf("worktree %s not removed on close: %v", sec.Cwd, err)
+	}
+}
+
+func TestManager_Send_ReportsTurnDiff(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	if out, err := exec.Command("git", "-C", repo, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+
+	var (
+		mu      sync.Mutex
+		diffs   []string
+		finalCh = make(chan struct{}, 1)
+	)
+	onOutput := func(_ string, out adapter.Output, final bool) {
+		if final {
+			finalCh <- struct{}{}
+			return
+		}
+		if out.Channel == "diff" {
+			mu.Lock()
+			diffs = append(diffs, string(out.Data))
+			mu.Unlock()
+		}
+	}
+	registry := adapter.NewRegistry()
+	registry.Register("mock-profile", &mockAdapter{})
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	m := NewManager(testManagerConfig(), []config.AgentConfig{{
+		ID: "ep-1", Name: "Repo Agent", Profile: "mock-profile",
+		CLI: &config.CLIConfig{Command: "bash", WorkDir: repo},
+	}}, registry, onOutput, nil, logger)
+
+	if err := m.Create(context.Background(), "sess-1", "ep-1", "user-1", "standard"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := m.Send(context.Background(), "sess-1", "msg-1", []byte("write a file")); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := os.WriteFile(repo+"/hello.txt", []byte("hi\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sess, _ := m.Get("sess-1")
+	close(sess.agent.(*mockAgentSession).outCh)
+
+	select {
+	case <-finalCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("turn did not complete")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(diffs) != 1 {
+		t.Fatalf("got %d diff outputs, want 1", len(diffs))
+	}
+	var d protocol.TurnDiff
+	if err := json.Unmarshal([]byte(diffs[0]), &d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Files) != 1 || d.Files[0].Path != "hello.txt" || d.Files[0].Status != "added" {
+		t.Fatalf("unexpected diff files: %+v", d.Files)
+	}
+	if !strings.Contains(d.Patch, "+hi") {
+		t.Fatalf("patch missing added line:\n%s", d.Patch)
+	}
+}
+
+func TestManager_Rollback(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	if out, err := exec.Command("git", "-C", repo, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+
+	finalCh := make(chan struct{}, 1)
+	registry := adapter.NewRegistry()
+	registry.Register("mock-profile", &mockAdapter{})
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	m := NewManager(testManagerConfig(), []config.AgentConfig{{
+		ID: "ep-1", Name: "Repo Agent", Profile: "mock-profile",
+		CLI: &config.CLIConfig{Command: "bash", WorkDir: repo},
+	}}, registry, func(_ string, _ adapter.Output, final bool) {
+		if final {
+			finalCh <- struct{}{}
+		}
+	}, nil, logger)
+
+	if err := m.Create(context.Background(), "sess-1", "ep-1", "user-1", "standard"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := m.Send(context.Background(), "sess-1", "msg-1", []byte("make a mess")); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := m.Rollback("sess-1", "msg-1"); err == nil {
+		t.Fatal("rollback during a turn succeeded")
+	}
+	if err := os.WriteFile(repo+"/mess.txt", []byte("oops\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sess, _ := m.Get("sess-1")
+	close(sess.agent.(*mockAgentSession).outCh)
+	select {
+	case <-finalCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("turn did not complete")
+	}
+
+	if err := m.Rollback("sess-1", "msg-unknown"); err == nil {
+		t.Fatal("rollback to an unknown turn succeeded")
+	}
+	if err := m.Rollback("sess-1", "msg-1"); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if _, err := os.Stat(repo + "/mess.txt"); !os.IsNotExist(err) {
+		t.Fatalf("file written during the turn survived the rollback: %v", err)
+	}
+
+	// A rollback racing a Send waits for it and then sees the turn running.
+	sess.lifeMu.Lock()
+	rolledBack := make(chan error, 1)
+	go func() { rolledBack <- m.Rollback("sess-1", "msg-1") }()
+	select {
+	case err := <-rolledBack:
+		sess.lifeMu.Unlock()
+		t.Fatalf("rollback did not wait for the send in progress: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	sess.state.Store(StateResponding)
+	sess.lifeMu.Unlock()
+	if err := <-rolledBack; err == nil || !strings.Contains(err.Error(), "turn is in progress") {
+		t.Fatalf("expected the rollback to be refused once the turn started, got %v", err)
+	}
+	sess.state.Store(StateActive)
+
+	// Closing the session drops its checkpoints.
+	if err := m.Close("sess-1"); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := exec.Command("git", "-C", repo, "for-each-ref", "refs/amurg/").Output(); len(out) != 0 {
+		t.Fatalf("checkpoints left after close: %s", out)
+	}
+}
+
+func TestManager_PutAndRemoveAgent(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	if err := m.PutAgent(config.AgentConfig{ID: "ep-new", Profile: "missing-profile"}); err == nil {
+		t.Fatal("expected an error for a profile without an adapter")
+	}
+	if err := m.PutAgent(config.AgentConfig{ID: "ep-new", Name: "Provisioned", Profile: "test-profile"}); err != nil {
+		t.Fatalf("PutAgent: %v", err)
+	}
+	if err := m.Create(ctx, "sess-1", "ep-new", "user-1", "standard"); err != nil {
+		t.Fatalf("create session on provisioned agent: %v", err)
+	}
+
+	if err := m.RemoveAgent("ep-new"); err == nil || !strings.Contains(err.Error(), "open sessions") {
+		t.Fatalf("expected removal to be refused while a session is open, got %v", err)
+	}
+	if err := m.Close("sess-1"); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := m.RemoveAgent("ep-new"); err != nil {
+		t.Fatalf("RemoveAgent: %v", err)
+	}
+	if err := m.Create(ctx, "sess-2", "ep-new", "user-1", "standard"); err == nil {
+		t.Fatal("expected creating a session on a removed agent to fail")
+	}
+	if err := m.RemoveAgent("ep-new"); err == nil {
+		t.Fatal("expected removing an unknown agent to fail")
+	}
+}
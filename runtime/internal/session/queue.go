@@ -0,0 +1,155 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors returned for session creations that could not be queued or left
+// the queue without a free slot.
+var (
+	ErrQueueFull     = errors.New("session queue is full")
+	ErrQueueTimeout  = errors.New("timed out waiting for a free session slot")
+	ErrQueueCanceled = errors.New("queued session creation canceled")
+)
+
+// QueueHandler is called with a queued session's 1-based position whenever
+// it changes.
+type QueueHandler func(sessionID string, position int)
+
+// pendingCreate is a session creation waiting in the queue. done receives
+// nil when a slot is reserved for it, or the reason it was dropped.
+type pendingCreate struct {
+	sessionID string
+	done      chan error
+}
+
+// SetQueueHandler registers a callback for queue position updates.
+func (m *Manager) SetQueueHandler(h QueueHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onQueue = h
+}
+
+// MustQueue reports whether a new session creation would have to wait in
+// the queue instead of starting right away.
+func (m *Manager) MustQueue() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cfg.QueueSize > 0 && !m.hasFreeSlot()
+}
+
+// hasFreeSlot reports whether a session can start without queueing. Slots
+// reserved for queued creations are taken, and nobody jumps the queue.
+// Callers hold m.mu.
+func (m *Manager) hasFreeSlot() bool {
+	return len(m.queue) == 0 && len(m.sessions)+m.reserved < m.cfg.MaxSessions
+}
+
+// waitForSlot returns once sessionID may be created. At max_sessions the
+// creation joins the queue, if enabled, until a session closes, the queue
+// timeout passes, ctx is done or the creation is canceled. It is called and
+// returns with m.mu held, releasing it while waiting.
+func (m *Manager) waitForSlot(ctx context.Context, sessionID string) error {
+	if m.hasFreeSlot() {
+		return nil
+	}
+	if m.cfg.QueueSize <= 0 {
+		return fmt.Errorf("max sessions reached (%d)", m.cfg.MaxSessions)
+	}
+	if len(m.queue) >= m.cfg.QueueSize {
+		return fmt.Errorf("max sessions reached (%d): %w", m.cfg.MaxSessions, ErrQueueFull)
+	}
+	for _, p := range m.queue {
+		if p.sessionID == sessionID {
+			return fmt.Errorf("session %s is already queued", sessionID)
+		}
+	}
+
+	p := &pendingCreate{sessionID: sessionID, done: make(chan error, 1)}
+	m.queue = append(m.queue, p)
+	m.logger.Info("session creation queued", "session_id", sessionID, "position", len(m.queue))
+	m.notifyQueue(len(m.queue) - 1)
+
+	m.mu.Unlock()
+	timer := time.NewTimer(m.cfg.QueueTimeout.Duration)
+	var err error
+	select {
+	case err = <-p.done:
+	case <-timer.C:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	timer.Stop()
+	m.mu.Lock()
+
+	if i := m.queueIndex(p); i >= 0 {
+		m.queue = append(m.queue[:i], m.queue[i+1:]...)
+		m.notifyQueue(i)
+		m.logger.Info("queued session creation dropped", "session_id", sessionID, "error", err)
+		return err
+	}
+	// Left the queue: either a slot was reserved, possibly just as the
+	// timeout fired, or the creation was canceled.
+	select {
+	case err = <-p.done:
+	default:
+	}
+	if err != nil {
+		return err
+	}
+	m.reserved--
+	return nil
+}
+
+// promoteQueued reserves free slots for the oldest queued creations.
+// Callers hold m.mu.
+func (m *Manager) promoteQueued() {
+	promoted := 0
+	for promoted < len(m.queue) && len(m.sessions)+m.reserved < m.cfg.MaxSessions {
+		m.reserved++
+		m.queue[promoted].done <- nil
+		promoted++
+	}
+	if promoted > 0 {
+		m.queue = append(m.queue[:0:0], m.queue[promoted:]...)
+		m.notifyQueue(0)
+	}
+}
+
+// cancelQueued drops a queued creation. It reports whether sessionID was
+// queued. Callers hold m.mu.
+func (m *Manager) cancelQueued(sessionID string, reason error) bool {
+	for i, p := range m.queue {
+		if p.sessionID == sessionID {
+			p.done <- reason
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			m.notifyQueue(i)
+			return true
+		}
+	}
+	return false
+}
+
+// notifyQueue reports the positions of queued creations from index from on.
+// Callers hold m.mu.
+func (m *Manager) notifyQueue(from int) {
+	if m.onQueue == nil {
+		return
+	}
+	for i := from; i < len(m.queue); i++ {
+		m.onQueue(m.queue[i].sessionID, i+1)
+	}
+}
+
+func (m *Manager) queueIndex(p *pendingCreate) int {
+	for i, q := range m.queue {
+		if q == p {
+			return i
+		}
+	}
+	return -1
+}
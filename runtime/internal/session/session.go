@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/amurg-ai/amurg/runtime/internal/adapter"
+	"github.com/amurg-ai/amurg/runtime/internal/cgroup"
+	"github.com/amurg-ai/amurg/runtime/internal/workspace"
 )
 
 // State represents a session's lifecycle state.
@@ -19,6 +21,7 @@ const (
 	StateActive     State = "active"
 	StateResponding State = "responding"
 	StateIdle       State = "idle"
+	StateHibernated State = "hibernated" // agent process stopped, revived on the next message
 	StateClosed     State = "closed"
 )
 
@@ -32,13 +35,36 @@ type Session struct {
 	UserID    string
 	CreatedAt time.Time
 
-	agent    adapter.AgentSession
-	state    atomic.Value // State
+	agent    adapter.AgentSession // guarded by mu; replaced when a hibernated session is revived
+	state    atomic.Value         // State
 	logger   *slog.Logger
 	onOutput OutputHandler
 
-	mu  sync.Mutex
-	seq int64
+	promptProfile string
+
+	cgroup      *cgroup.Group // nil when the agent has no cgroup limits
+	stopMonitor context.CancelFunc
+	worktree    *workspace.Worktree // nil unless the agent uses worktree mode
+
+	// diffDir is the git work tree whose changes are reported after every
+	// turn; "" disables turn diffs.
+	diffDir      string
+	diffMaxBytes int
+
+	// Hibernation stops the agent process after hibernateAfter without a
+	// turn. revive restarts it, resuming the native handle saved in
+	// hibernatedHandle; nil when the session cannot hibernate.
+	hibernateAfter   time.Duration
+	revive           reviver
+	hibernateTimer   *time.Timer
+	hibernatedHandle string
+
+	// lifeMu serializes sending to the agent with hibernating and reviving it.
+	lifeMu sync.Mutex
+
+	mu       sync.Mutex
+	seq      int64
+	turnBase string // snapshot of diffDir taken when the current turn started
 }
 
 // NewSession creates a new session wrapping an agent session.
@@ -56,6 +82,15 @@ func NewSession(id, agentID, userID string, agent adapter.AgentSession, onOutput
 	return s
 }
 
+// Branch returns the git branch of the session's worktree, or "" when the
+// session runs in the agent's shared work dir.
+func (s *Session) Branch() string {
+	if s.worktree == nil {
+		return ""
+	}
+	return s.worktree.Branch
+}
+
 // State returns the current session state.
 func (s *Session) State() State {
 	return s.state.Load().(State)
@@ -63,24 +98,41 @@ func (s *Session) State() State {
 
 // NativeHandle returns the agent's native session ID if the adapter supports it.
 func (s *Session) NativeHandle() string {
-	if nh, ok := s.agent.(adapter.NativeHandleProvider); ok {
+	if nh, ok := s.agentSession().(adapter.NativeHandleProvider); ok {
 		return nh.NativeHandle()
 	}
 	return ""
 }
 
+// agentSession returns the session's current agent.
+func (s *Session) agentSession() adapter.AgentSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.agent
+}
+
 // Send delivers a user message to the agent and starts reading output.
-func (s *Session) Send(ctx context.Context, input []byte, idleTimeout time.Duration) error {
+// turnID names the workspace checkpoint of the new turn; "" skips it.
+func (s *Session) Send(ctx context.Context, turnID string, input []byte, idleTimeout time.Duration) error {
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+	if err := s.wake(ctx); err != nil {
+		return err
+	}
+
 	s.state.Store(StateResponding)
 	s.logger.Info("sending user input", "bytes", len(input))
+	s.snapshotTurn(turnID)
 
-	if err := s.agent.Send(ctx, input); err != nil {
+	agent := s.agentSession()
+	if err := agent.Send(ctx, input); err != nil {
 		s.state.Store(StateActive)
+		s.armHibernateLocked()
 		return err
 	}
 
 	// Start draining output in background.
-	go s.drainOutput(idleTimeout)
+	go s.drainOutput(agent, idleTimeout)
 
 	return nil
 }
@@ -88,45 +140,72 @@ func (s *Session) Send(ctx context.Context, input []byte, idleTimeout time.Durat
 // SendInteractive delivers follow-up input to a running interactive session.
 // If the runtime recreated the local wrapper while the native process is still
 // alive, this also restarts output draining for the current turn.
-func (s *Session) SendInteractive(ctx context.Context, input []byte, idleTimeout time.Duration) error {
-	state := s.State()
-	if state == StateClosed {
+func (s *Session) SendInteractive(ctx context.Context, turnID string, input []byte, idleTimeout time.Duration) error {
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+	if s.State() == StateClosed {
 		return fmt.Errorf("session closed")
 	}
+	if err := s.wake(ctx); err != nil {
+		return err
+	}
 
+	state := s.State()
+	agent := s.agentSession()
 	s.logger.Info("sending interactive input", "bytes", len(input), "state", state)
 	if state == StateActive || state == StateIdle {
 		s.state.Store(StateResponding)
-		if err := s.agent.Send(ctx, input); err != nil {
+		s.snapshotTurn(turnID)
+		if err := agent.Send(ctx, input); err != nil {
 			s.state.Store(state)
+			s.armHibernateLocked()
 			return err
 		}
-		go s.drainOutput(idleTimeout)
+		go s.drainOutput(agent, idleTimeout)
 		return nil
 	}
 
-	return s.agent.Send(ctx, input)
+	return agent.Send(ctx, input)
 }
 
 // Stop requests the agent to stop.
 func (s *Session) Stop() error {
 	s.logger.Info("stopping session")
-	return s.agent.Stop()
+	if s.State() == StateHibernated {
+		return nil
+	}
+	return s.agentSession().Stop()
 }
 
 // Close terminates the session and releases resources.
 func (s *Session) Close() error {
+	s.lifeMu.Lock()
+	hibernated := s.State() == StateHibernated
 	s.state.Store(StateClosed)
+	s.stopHibernateTimer()
+	s.lifeMu.Unlock()
+
 	s.logger.Info("closing session")
-	return s.agent.Close()
+	var err error
+	if !hibernated {
+		err = s.agentSession().Close()
+	}
+	if s.cgroup != nil {
+		s.stopMonitor()
+		if rerr := s.cgroup.Remove(); rerr != nil {
+			s.logger.Warn("remove session cgroup failed", "error", rerr)
+		}
+	}
+	return err
 }
 
 // drainOutput reads from the agent output channel and forwards to the handler.
 // It uses idle timeout to detect turn completion for interactive profiles.
-func (s *Session) drainOutput(idleTimeout time.Duration) {
-	outCh := s.agent.Output()
+func (s *Session) drainOutput(agent adapter.AgentSession, idleTimeout time.Duration) {
+	outCh := agent.Output()
 	timer := time.NewTimer(idleTimeout)
 	defer timer.Stop()
+	defer s.armHibernate()
 
 	for {
 		select {
@@ -138,9 +217,10 @@ func (s *Session) drainOutput(idleTimeout time.Duration) {
 				s.mu.Unlock()
 				s.state.Store(StateActive)
 				finalOut := adapter.Output{Channel: "system", Data: nil}
-				if ec, ok := s.agent.(adapter.ExitCoder); ok {
+				if ec, ok := agent.(adapter.ExitCoder); ok {
 					finalOut.ExitCode = ec.ExitCode()
 				}
+				s.reportTurnDiff()
 				s.onOutput(s.ID, finalOut, true)
 				return
 			}
@@ -154,6 +234,7 @@ func (s *Session) drainOutput(idleTimeout time.Duration) {
 			// immediately instead of waiting for idle timeout.
 			if out.ExitCode != nil {
 				s.state.Store(StateActive)
+				s.reportTurnDiff()
 				s.onOutput(s.ID, out, true)
 				return
 			}
@@ -173,6 +254,7 @@ func (s *Session) drainOutput(idleTimeout time.Duration) {
 			// Idle timeout = infer turn completion.
 			s.logger.Debug("idle timeout, inferring turn complete")
 			s.state.Store(StateActive)
+			s.reportTurnDiff()
 			s.onOutput(s.ID, adapter.Output{Channel: "system", Data: nil}, true)
 			return
 		}
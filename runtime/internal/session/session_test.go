@@ -96,7 +96,7 @@ func TestSession_Send_SetsResponding(t *testing.T) {
 	handler := func(string, adapter.Output, bool) {}
 	sess := NewSession("s1", "e1", "u1", agent, handler, testLogger())
 
-	err := sess.Send(context.Background(), []byte("hello"), 5*time.Second)
+	err := sess.Send(context.Background(), "", []byte("hello"), 5*time.Second)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -117,7 +117,7 @@ func TestSession_Send_ErrorRestoresActive(t *testing.T) {
 	handler := func(string, adapter.Output, bool) {}
 	sess := NewSession("s1", "e1", "u1", agent, handler, testLogger())
 
-	err := sess.Send(context.Background(), []byte("hello"), 5*time.Second)
+	err := sess.Send(context.Background(), "", []byte("hello"), 5*time.Second)
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -132,11 +132,11 @@ func TestSession_SendInteractive_WhileRespondingUsesExistingDrain(t *testing.T)
 	handler := func(string, adapter.Output, bool) {}
 	sess := NewSession("s1", "e1", "u1", agent, handler, testLogger())
 
-	if err := sess.Send(context.Background(), []byte("hello"), 5*time.Second); err != nil {
+	if err := sess.Send(context.Background(), "", []byte("hello"), 5*time.Second); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if err := sess.SendInteractive(context.Background(), []byte("y"), 5*time.Second); err != nil {
+	if err := sess.SendInteractive(context.Background(), "", []byte("y"), 5*time.Second); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
@@ -153,7 +153,7 @@ func TestSession_SendInteractive_FromActiveStartsResponding(t *testing.T) {
 	handler := func(string, adapter.Output, bool) {}
 	sess := NewSession("s1", "e1", "u1", agent, handler, testLogger())
 
-	if err := sess.SendInteractive(context.Background(), []byte("reply"), 5*time.Second); err != nil {
+	if err := sess.SendInteractive(context.Background(), "", []byte("reply"), 5*time.Second); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
@@ -181,7 +181,7 @@ func TestSession_DrainOutput_ForwardsMessages(t *testing.T) {
 	sess := NewSession("s1", "e1", "u1", agent, handler, testLogger())
 
 	// Send a message to start draining.
-	err := sess.Send(context.Background(), []byte("go"), 5*time.Second)
+	err := sess.Send(context.Background(), "", []byte("go"), 5*time.Second)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -246,7 +246,7 @@ func TestSession_DrainOutput_IdleTimeout(t *testing.T) {
 	sess := NewSession("s1", "e1", "u1", agent, handler, testLogger())
 
 	// Use a very short idle timeout.
-	err := sess.Send(context.Background(), []byte("go"), 50*time.Millisecond)
+	err := sess.Send(context.Background(), "", []byte("go"), 50*time.Millisecond)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -296,7 +296,7 @@ func TestSession_DrainOutput_ExitCode(t *testing.T) {
 
 	sess := NewSession("s1", "e1", "u1", agent, handler, testLogger())
 
-	err := sess.Send(context.Background(), []byte("go"), 5*time.Second)
+	err := sess.Send(context.Background(), "", []byte("go"), 5*time.Second)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -0,0 +1,201 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/amurg-ai/amurg/runtime/internal/adapter"
+	"github.com/amurg-ai/amurg/runtime/internal/config"
+	"github.com/amurg-ai/amurg/runtime/internal/workspace"
+)
+
+// worktreeTimeout bounds the git commands that create or clean up a worktree.
+const worktreeTimeout = 30 * time.Second
+
+// turnDiffTimeout bounds each snapshot or diff of a session's work dir.
+const turnDiffTimeout = 15 * time.Second
+
+// usesWorktree reports whether sessions of the agent get their own worktree.
+func usesWorktree(cfg config.AgentConfig) bool {
+	return cfg.Workspace != nil && cfg.Workspace.Mode == workspace.ModeWorktree
+}
+
+// createWorktree checks out the session's worktree of the agent's work dir.
+func createWorktree(ctx context.Context, sessionID string, cfg config.AgentConfig) (*workspace.Worktree, error) {
+	repo := cfg.WorkDir()
+	if cfg.Security != nil && cfg.Security.Cwd != "" {
+		repo = cfg.Security.Cwd
+	}
+	if repo == "" {
+		return nil, fmt.Errorf("workspace mode %q needs a work_dir", workspace.ModeWorktree)
+	}
+	ctx, cancel := context.WithTimeout(ctx, worktreeTimeout)
+	defer cancel()
+	return workspace.Create(ctx, repo, sessionID, workspace.Options{
+		Dir:          cfg.Workspace.Dir,
+		BaseRef:      cfg.Workspace.BaseRef,
+		BranchPrefix: cfg.Workspace.BranchPrefix,
+	})
+}
+
+// worktreeSecurity returns a copy of security that starts the agent inside
+// wt. Security.Cwd takes precedence over every profile's work_dir. When
+// sandboxed, the repository's git dir stays writable so the agent can commit.
+func worktreeSecurity(security *config.SecurityConfig, wt *workspace.Worktree) *config.SecurityConfig {
+	var sec config.SecurityConfig
+	if security != nil {
+		sec = *security
+	}
+	sec.Cwd = wt.Path
+	if sec.Sandbox != nil {
+		sb := *sec.Sandbox
+		sb.WritablePaths = append(slices.Clone(sb.WritablePaths), wt.GitDir)
+		sec.Sandbox = &sb
+	}
+	return &sec
+}
+
+// closeWorktree applies the agent's cleanup policy to a closed session's
+// worktree.
+func (m *Manager) closeWorktree(sess *Session, cfg config.AgentConfig) {
+	policy := ""
+	if cfg.Workspace != nil {
+		policy = cfg.Workspace.Cleanup
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), worktreeTimeout)
+	defer cancel()
+	kept, err := sess.worktree.Close(ctx, policy)
+	if err != nil {
+		m.logger.Warn("worktree cleanup failed", "session_id", sess.ID, "path", sess.worktree.Path, "error", err)
+		return
+	}
+	m.logger.Info("session worktree closed", "session_id", sess.ID, "branch", sess.worktree.Branch, "kept", kept)
+}
+
+// discardWorktree cleans up the worktree of a session that failed to start.
+// Worktrees holding work from an earlier run are kept.
+func discardWorktree(wt *workspace.Worktree) {
+	ctx, cancel := context.WithTimeout(context.Background(), worktreeTimeout)
+	defer cancel()
+	_, _ = wt.Close(ctx, workspace.CleanupAuto)
+}
+
+// turnDiffConfig returns the git work tree whose changes are reported after
+// each turn and the size cap of the reported diff. The dir is "" when the
+// agent does not run in a git repository or turn diffs are disabled.
+func turnDiffConfig(ctx context.Context, cfg config.AgentConfig) (dir string, maxBytes int) {
+	if ws := cfg.Workspace; ws != nil {
+		if ws.NoTurnDiff {
+			return "", 0
+		}
+		maxBytes = ws.DiffMaxBytes
+	}
+	dir = cfg.WorkDir()
+	if cfg.Security != nil && cfg.Security.Cwd != "" {
+		dir = cfg.Security.Cwd
+	}
+	if dir == "" {
+		return "", 0
+	}
+	ctx, cancel := context.WithTimeout(ctx, turnDiffTimeout)
+	defer cancel()
+	if !workspace.IsRepo(ctx, dir) {
+		return "", 0
+	}
+	return dir, maxBytes
+}
+
+// snapshotTurn records the state of the work dir as a turn starts and keeps
+// it as the turn's checkpoint unless turnID is "".
+func (s *Session) snapshotTurn(turnID string) {
+	if s.diffDir == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), turnDiffTimeout)
+	defer cancel()
+	tree, err := workspace.Snapshot(ctx, s.diffDir)
+	if err != nil {
+		s.logger.Warn("workspace snapshot failed", "dir", s.diffDir, "error", err)
+	} else if turnID != "" {
+		if err := workspace.Checkpoint(ctx, s.diffDir, workspace.CheckpointRef(s.ID, turnID), tree); err != nil {
+			s.logger.Warn("workspace checkpoint failed", "dir", s.diffDir, "error", err)
+		}
+	}
+	s.mu.Lock()
+	s.turnBase = tree
+	s.mu.Unlock()
+}
+
+// reportTurnDiff emits what the turn changed in the work dir as a "diff"
+// output. Turns that changed nothing emit nothing.
+func (s *Session) reportTurnDiff() {
+	s.mu.Lock()
+	base := s.turnBase
+	s.turnBase = ""
+	s.mu.Unlock()
+	if base == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), turnDiffTimeout)
+	defer cancel()
+	tree, err := workspace.Snapshot(ctx, s.diffDir)
+	if err != nil {
+		s.logger.Warn("workspace snapshot failed", "dir", s.diffDir, "error", err)
+		return
+	}
+	diff, err := workspace.Diff(ctx, s.diffDir, base, tree, s.diffMaxBytes)
+	if err != nil {
+		s.logger.Warn("workspace diff failed", "dir", s.diffDir, "error", err)
+		return
+	}
+	if len(diff.Files) == 0 {
+		return
+	}
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.seq++
+	s.mu.Unlock()
+	s.onOutput(s.ID, adapter.Output{Channel: "diff", Data: data}, false)
+}
+
+// Rollback restores the work dir to the checkpoint of the turn turnID. It
+// holds lifeMu so no turn can start while the files are being restored.
+func (s *Session) Rollback(turnID string) error {
+	if s.diffDir == "" {
+		return fmt.Errorf("session has no git work dir to roll back")
+	}
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+	switch s.State() {
+	case StateResponding:
+		return fmt.Errorf("a turn is in progress")
+	case StateClosed:
+		return fmt.Errorf("session closed")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), turnDiffTimeout)
+	defer cancel()
+	if err := workspace.Restore(ctx, s.diffDir, workspace.CheckpointRef(s.ID, turnID)); err != nil {
+		return err
+	}
+	s.logger.Info("workspace rolled back", "dir", s.diffDir, "turn", turnID)
+	return nil
+}
+
+// deleteCheckpoints removes the session's checkpoints from the repository.
+func (s *Session) deleteCheckpoints() {
+	if s.diffDir == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), turnDiffTimeout)
+	defer cancel()
+	if err := workspace.DeleteCheckpoints(ctx, s.diffDir, s.ID); err != nil {
+		s.logger.Warn("delete workspace checkpoints failed", "dir", s.diffDir, "error", err)
+	}
+}
@@ -33,8 +33,12 @@ func (h headerModel) View(width int) string {
 	right := fmt.Sprintf("%s  %s %s", hubURL, dot, statusLabel)
 
 	uptime := h.formatUptime()
-	info := fmt.Sprintf("  Runtime: %s   Sessions: %d/%d   Uptime: %s",
-		h.status.RuntimeID, h.status.Sessions, h.status.MaxSessions, uptime)
+	sessions := fmt.Sprintf("%d/%d", h.status.Sessions, h.status.MaxSessions)
+	if h.status.Hibernated > 0 {
+		sessions += fmt.Sprintf(" (%d hibernated)", h.status.Hibernated)
+	}
+	info := fmt.Sprintf("  Runtime: %s   Sessions: %s   Uptime: %s",
+		h.status.RuntimeID, sessions, uptime)
 
 	nameStyle := lipgloss.NewStyle().Foreground(tui.ColorText).Bold(true)
 	metaStyle := lipgloss.NewStyle().Foreground(tui.ColorMuted)
@@ -118,6 +118,8 @@ func stateColor(state string) lipgloss.Style {
 		return lipgloss.NewStyle().Foreground(tui.ColorAccent)
 	case "idle":
 		return lipgloss.NewStyle().Foreground(tui.ColorMuted)
+	case "hibernated":
+		return lipgloss.NewStyle().Foreground(tui.ColorSubtle).Italic(true)
 	default:
 		return lipgloss.NewStyle().Foreground(tui.ColorText)
 	}
@@ -0,0 +1,142 @@
+package usercmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/amurg-ai/amurg/pkg/hubapi"
+	"github.com/amurg-ai/amurg/runtime/internal/wizard"
+)
+
+// hubOptions holds the flags shared by every command that talks to the hub API.
+type hubOptions struct {
+	hubURL            string
+	token             string
+	username          string
+	password          string
+	otp               string
+	runtimeConfigPath string
+}
+
+func (o *hubOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.hubURL, "hub-url", "", "hub base URL or WebSocket URL (env: AMURG_HUB_URL)")
+	cmd.Flags().StringVar(&o.token, "token", "", "hub bearer token or personal access token (env: AMURG_TOKEN)")
+	cmd.Flags().StringVar(&o.username, "username", "", "hub username for builtin auth (env: AMURG_USERNAME)")
+	cmd.Flags().StringVar(&o.password, "password", "", "hub password for builtin auth (env: AMURG_PASSWORD)")
+	cmd.Flags().StringVar(&o.otp, "otp", "", "two-factor code or recovery code for builtin auth (env: AMURG_OTP)")
+	cmd.Flags().StringVar(&o.runtimeConfigPath, "config", "", "runtime config path used only to infer hub URL (default: ~/.amurg/config.json)")
+}
+
+// connect resolves the hub URL and credentials and returns an authenticated client.
+func (o *hubOptions) connect(ctx context.Context) (*hubapi.Client, error) {
+	baseURL, err := resolveHubBaseURL(o.hubURL, o.runtimeConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := hubapi.New(baseURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := resolveBearerToken(ctx, client, o)
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken(token)
+	return client, nil
+}
+
+func resolveHubBaseURL(flagValue, configPath string) (string, error) {
+	if env := strings.TrimSpace(os.Getenv("AMURG_HUB_URL")); flagValue == "" && env != "" {
+		flagValue = env
+	}
+	if flagValue != "" {
+		return hubapi.NormalizeBaseURL(flagValue)
+	}
+
+	path := configPath
+	explicitConfig := path != ""
+	if path == "" {
+		path = wizard.DefaultConfigPath()
+	}
+
+	rawURL, err := readHubURLFromRuntimeConfig(path)
+	if err == nil && rawURL != "" {
+		return hubapi.NormalizeBaseURL(rawURL)
+	}
+	if explicitConfig && err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("hub URL required: set --hub-url, AMURG_HUB_URL, or point --config at a runtime config")
+}
+
+func readHubURLFromRuntimeConfig(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read runtime config %q: %w", path, err)
+	}
+
+	var cfg struct {
+		Hub struct {
+			URL string `json:"url"`
+		} `json:"hub"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return "", fmt.Errorf("parse runtime config %q: %w", path, err)
+	}
+	if cfg.Hub.URL == "" {
+		return "", fmt.Errorf("runtime config %q does not contain hub.url", path)
+	}
+	return cfg.Hub.URL, nil
+}
+
+func resolveBearerToken(ctx context.Context, client *hubapi.Client, opts *hubOptions) (string, error) {
+	token := strings.TrimSpace(opts.token)
+	if token == "" {
+		token = strings.TrimSpace(os.Getenv("AMURG_TOKEN"))
+	}
+	if token != "" {
+		return token, nil
+	}
+
+	username := strings.TrimSpace(opts.username)
+	if username == "" {
+		username = strings.TrimSpace(os.Getenv("AMURG_USERNAME"))
+	}
+	password := opts.password
+	if password == "" {
+		password = os.Getenv("AMURG_PASSWORD")
+	}
+
+	switch {
+	case username == "" && password == "":
+		return "", fmt.Errorf("authentication required: provide --token / AMURG_TOKEN or --username with --password")
+	case username == "" || password == "":
+		return "", fmt.Errorf("username and password must be provided together")
+	}
+
+	token, err := client.Login(ctx, username, password)
+	var mfaErr *hubapi.MFARequiredError
+	if errors.As(err, &mfaErr) {
+		otp := strings.TrimSpace(opts.otp)
+		if otp == "" {
+			otp = strings.TrimSpace(os.Getenv("AMURG_OTP"))
+		}
+		if otp == "" {
+			return "", fmt.Errorf("%w: provide --otp / AMURG_OTP, or use a personal access token", err)
+		}
+		return client.LoginMFA(ctx, mfaErr.Token, otp)
+	}
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
@@ -7,12 +7,14 @@ func NewRootCmd(v string) *cobra.Command {
 	root := &cobra.Command{
 		Use:           "amurg",
 		Short:         "Amurg user CLI",
-		Long:          "Amurg queries the hub API for user-facing operations such as listing resumable sessions.",
+		Long:          "Amurg queries the hub API for user-facing operations such as listing resumable sessions and running prompts on agents.",
 		SilenceUsage:  true,
 		SilenceErrors: true,
 	}
 
+	root.AddCommand(newRunCmd())
 	root.AddCommand(newSessionsCmd())
+	root.AddCommand(newTokensCmd())
 	root.AddCommand(newVersionCmd(v))
 
 	return root
@@ -0,0 +1,105 @@
+package usercmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/amurg-ai/amurg/pkg/hubapi"
+)
+
+type runOptions struct {
+	hubOptions
+	agentID       string
+	promptProfile string
+	jsonOutput    bool
+	timeout       time.Duration
+}
+
+func newRunCmd() *cobra.Command {
+	opts := &runOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "run --agent <id> <prompt>",
+		Short: "Send a prompt to an agent and print its answer",
+		Long: "Open a new session on an agent, send a prompt and stream the agent's output until the turn completes.\n\n" +
+			"Pass - as the prompt to read it from stdin. The command exits non-zero if the run fails, " +
+			"so it can gate CI jobs. With --json, nothing is streamed and the finished run is printed as JSON.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRun(cmd, args[0], opts)
+		},
+	}
+
+	opts.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.agentID, "agent", "", "agent ID to run the prompt on (required)")
+	cmd.Flags().StringVar(&opts.promptProfile, "prompt-profile", "", "prompt profile for the session")
+	cmd.Flags().BoolVar(&opts.jsonOutput, "json", false, "print the finished run as JSON instead of streaming output")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", time.Hour, "give up waiting for the run after this long")
+	_ = cmd.MarkFlagRequired("agent")
+
+	return cmd
+}
+
+func runRun(cmd *cobra.Command, prompt string, opts *runOptions) error {
+	if prompt == "-" {
+		data, err := io.ReadAll(cmd.InOrStdin())
+		if err != nil {
+			return fmt.Errorf("read prompt from stdin: %w", err)
+		}
+		prompt = string(data)
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return fmt.Errorf("prompt is empty")
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), opts.timeout)
+	defer cancel()
+
+	client, err := opts.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	var onOutput func(hubapi.OutputChunk)
+	if !opts.jsonOutput {
+		onOutput = func(chunk hubapi.OutputChunk) {
+			out := cmd.OutOrStdout()
+			if chunk.Channel != "stdout" {
+				out = cmd.ErrOrStderr()
+			}
+			_, _ = io.WriteString(out, chunk.Content)
+		}
+	}
+
+	run, err := client.StreamRun(ctx, opts.agentID, hubapi.RunRequest{
+		Prompt:        prompt,
+		PromptProfile: opts.promptProfile,
+	}, onOutput)
+	if err != nil {
+		if run != nil {
+			return fmt.Errorf("%w (run %s, session %s)", err, run.ID, run.SessionID)
+		}
+		return err
+	}
+
+	if opts.jsonOutput {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(run); err != nil {
+			return err
+		}
+	} else if !strings.HasSuffix(run.Output, "\n") && run.Output != "" {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout())
+	}
+
+	if run.Status != "succeeded" {
+		return fmt.Errorf("run %s %s: %s", run.ID, run.Status, fallback(run.Error, "no details"))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package usercmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newRunTestServer(t *testing.T, finalStatus string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/agents/ag-1/run" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("Accept"); got != "text/event-stream" {
+			t.Errorf("Accept header = %q, want text/event-stream", got)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode run body: %v", err)
+		}
+		if body["prompt"] != "summarize the failures\n" {
+			t.Errorf("prompt = %q", body["prompt"])
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: run\ndata: {\"id\":\"run-1\",\"session_id\":\"sess-1\",\"status\":\"running\"}\n\n")
+		fmt.Fprint(w, "event: agent.output\ndata: {\"session_id\":\"sess-1\",\"seq\":2,\"channel\":\"stdout\",\"content\":\"two tests \"}\n\n")
+		fmt.Fprint(w, "event: agent.output\ndata: {\"session_id\":\"sess-1\",\"seq\":3,\"channel\":\"stderr\",\"content\":\"warning\"}\n\n")
+		fmt.Fprint(w, "event: agent.output\ndata: {\"session_id\":\"sess-1\",\"seq\":4,\"channel\":\"stdout\",\"content\":\"failed\"}\n\n")
+		fmt.Fprintf(w, "event: turn.completed\ndata: {\"id\":\"run-1\",\"session_id\":\"sess-1\",\"status\":%q,\"output\":\"two tests failed\",\"error\":\"agent exited with code 1\"}\n\n", finalStatus)
+	}))
+}
+
+func TestRunStreamsOutput(t *testing.T) {
+	t.Parallel()
+
+	srv := newRunTestServer(t, "succeeded")
+	defer srv.Close()
+
+	root := NewRootCmd("test")
+	var stdout, stderr bytes.Buffer
+	root.SetOut(&stdout)
+	root.SetErr(&stderr)
+	root.SetIn(strings.NewReader("summarize the failures\n"))
+	root.SetArgs([]string{"run", "--agent", "ag-1", "--hub-url", srv.URL, "--token", "jwt-token", "-"})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute: %v; stderr=%s", err, stderr.String())
+	}
+	if stdout.String() != "two tests failed\n" {
+		t.Fatalf("stdout = %q", stdout.String())
+	}
+	if stderr.String() != "warning" {
+		t.Fatalf("stderr = %q", stderr.String())
+	}
+}
+
+func TestRunFailsWhenRunFails(t *testing.T) {
+	t.Parallel()
+
+	srv := newRunTestServer(t, "failed")
+	defer srv.Close()
+
+	root := NewRootCmd("test")
+	var stdout bytes.Buffer
+	root.SetOut(&stdout)
+	root.SetErr(&bytes.Buffer{})
+	root.SetArgs([]string{"run", "--agent", "ag-1", "--hub-url", srv.URL, "--token", "jwt-token", "--json", "summarize the failures\n"})
+
+	err := root.Execute()
+	if err == nil || !strings.Contains(err.Error(), "agent exited with code 1") {
+		t.Fatalf("Execute error = %v, want run failure", err)
+	}
+	var run map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &run); err != nil {
+		t.Fatalf("decode JSON output: %v\noutput=%s", err, stdout.String())
+	}
+	if run["id"] != "run-1" || run["status"] != "failed" {
+		t.Fatalf("unexpected run output %v", run)
+	}
+}
@@ -5,24 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/amurg-ai/amurg/pkg/hubapi"
-	"github.com/amurg-ai/amurg/runtime/internal/wizard"
 )
 
 const defaultSessionListLimit = 20
 
 type sessionsListOptions struct {
-	hubURL               string
-	token                string
-	username             string
-	password             string
-	runtimeConfigPath    string
+	hubOptions
 	profile              string
 	jsonOutput           bool
 	includeMissingHandle bool
@@ -32,9 +26,10 @@ type sessionsListOptions struct {
 func newSessionsCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "sessions",
-		Short: "List hub sessions",
+		Short: "List and export hub sessions",
 	}
 	cmd.AddCommand(newSessionsListCmd())
+	cmd.AddCommand(newSessionsExportCmd())
 	return cmd
 }
 
@@ -53,11 +48,7 @@ func newSessionsListCmd() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVar(&opts.hubURL, "hub-url", "", "hub base URL or WebSocket URL (env: AMURG_HUB_URL)")
-	cmd.Flags().StringVar(&opts.token, "token", "", "hub bearer token (env: AMURG_TOKEN)")
-	cmd.Flags().StringVar(&opts.username, "username", "", "hub username for builtin auth (env: AMURG_USERNAME)")
-	cmd.Flags().StringVar(&opts.password, "password", "", "hub password for builtin auth (env: AMURG_PASSWORD)")
-	cmd.Flags().StringVar(&opts.runtimeConfigPath, "config", "", "runtime config path used only to infer hub URL (default: ~/.amurg/config.json)")
+	opts.addFlags(cmd)
 	cmd.Flags().StringVar(&opts.profile, "profile", "claude-code", "limit results to a specific agent profile; empty means all profiles")
 	cmd.Flags().BoolVar(&opts.includeMissingHandle, "include-missing-handle", false, "include sessions that do not have a native_handle yet")
 	cmd.Flags().BoolVar(&opts.jsonOutput, "json", false, "emit JSON")
@@ -67,24 +58,13 @@ func newSessionsListCmd() *cobra.Command {
 }
 
 func runSessionsList(cmd *cobra.Command, opts *sessionsListOptions) error {
-	baseURL, err := resolveHubBaseURL(opts.hubURL, opts.runtimeConfigPath)
-	if err != nil {
-		return err
-	}
-
-	client, err := hubapi.New(baseURL, nil)
-	if err != nil {
-		return err
-	}
-
 	ctx, cancel := context.WithTimeout(cmd.Context(), 15*time.Second)
 	defer cancel()
 
-	token, err := resolveBearerToken(ctx, client, opts)
+	client, err := opts.connect(ctx)
 	if err != nil {
 		return err
 	}
-	client.SetToken(token)
 
 	sessions, err := client.ListSessions(ctx)
 	if err != nil {
@@ -102,81 +82,67 @@ func runSessionsList(cmd *cobra.Command, opts *sessionsListOptions) error {
 	return writeHumanSessions(cmd, filtered, opts)
 }
 
-func resolveHubBaseURL(flagValue, configPath string) (string, error) {
-	if env := strings.TrimSpace(os.Getenv("AMURG_HUB_URL")); flagValue == "" && env != "" {
-		flagValue = env
-	}
-	if flagValue != "" {
-		return hubapi.NormalizeBaseURL(flagValue)
-	}
+type sessionsExportOptions struct {
+	hubOptions
+	format string
+	output string
+}
 
-	path := configPath
-	explicitConfig := path != ""
-	if path == "" {
-		path = wizard.DefaultConfigPath()
-	}
+func newSessionsExportCmd() *cobra.Command {
+	opts := &sessionsExportOptions{}
 
-	rawURL, err := readHubURLFromRuntimeConfig(path)
-	if err == nil && rawURL != "" {
-		return hubapi.NormalizeBaseURL(rawURL)
-	}
-	if explicitConfig && err != nil {
-		return "", err
+	cmd := &cobra.Command{
+		Use:   "export <session-id>",
+		Short: "Export a session transcript",
+		Long: "Download the full transcript of a hub session, including tool calls and their results, " +
+			"permission decisions and links to attached files.\n\n" +
+			"Supported formats are md (Markdown, default), jsonl and html.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSessionsExport(cmd, args[0], opts)
+		},
 	}
 
-	return "", fmt.Errorf("hub URL required: set --hub-url, AMURG_HUB_URL, or point --config at a runtime config")
-}
+	opts.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.format, "format", "md", "output format: md, jsonl, or html")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "", "write the transcript to this file instead of stdout")
 
-func readHubURLFromRuntimeConfig(path string) (string, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return "", fmt.Errorf("read runtime config %q: %w", path, err)
-	}
-
-	var cfg struct {
-		Hub struct {
-			URL string `json:"url"`
-		} `json:"hub"`
-	}
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return "", fmt.Errorf("parse runtime config %q: %w", path, err)
-	}
-	if cfg.Hub.URL == "" {
-		return "", fmt.Errorf("runtime config %q does not contain hub.url", path)
-	}
-	return cfg.Hub.URL, nil
+	return cmd
 }
 
-func resolveBearerToken(ctx context.Context, client *hubapi.Client, opts *sessionsListOptions) (string, error) {
-	token := strings.TrimSpace(opts.token)
-	if token == "" {
-		token = strings.TrimSpace(os.Getenv("AMURG_TOKEN"))
-	}
-	if token != "" {
-		return token, nil
+func runSessionsExport(cmd *cobra.Command, sessionID string, opts *sessionsExportOptions) error {
+	switch opts.format {
+	case "md", "jsonl", "html":
+	default:
+		return fmt.Errorf("unsupported format %q: use md, jsonl, or html", opts.format)
 	}
 
-	username := strings.TrimSpace(opts.username)
-	if username == "" {
-		username = strings.TrimSpace(os.Getenv("AMURG_USERNAME"))
-	}
-	password := opts.password
-	if password == "" {
-		password = os.Getenv("AMURG_PASSWORD")
+	ctx, cancel := context.WithTimeout(cmd.Context(), time.Minute)
+	defer cancel()
+
+	client, err := opts.connect(ctx)
+	if err != nil {
+		return err
 	}
 
-	switch {
-	case username == "" && password == "":
-		return "", fmt.Errorf("authentication required: provide --token / AMURG_TOKEN or --username with --password")
-	case username == "" || password == "":
-		return "", fmt.Errorf("username and password must be provided together")
+	if opts.output == "" {
+		return client.ExportSession(ctx, sessionID, opts.format, cmd.OutOrStdout())
 	}
 
-	token, err := client.Login(ctx, username, password)
+	f, err := os.Create(opts.output)
 	if err != nil {
-		return "", err
+		return fmt.Errorf("create output file: %w", err)
+	}
+	if err := client.ExportSession(ctx, sessionID, opts.format, f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(opts.output)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close output file: %w", err)
 	}
-	return token, nil
+	_, err = fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %s\n", opts.output)
+	return err
 }
 
 func filterSessions(sessions []hubapi.Session, opts *sessionsListOptions) []hubapi.Session {
@@ -207,15 +173,16 @@ func writeHumanSessions(cmd *cobra.Command, sessions []hubapi.Session, opts *ses
 	}
 
 	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
-	if _, err := fmt.Fprintln(tw, "NATIVE_HANDLE\tPROFILE\tCREATED_AT\tMESSAGE_COUNT"); err != nil {
+	if _, err := fmt.Fprintln(tw, "NATIVE_HANDLE\tPROFILE\tBRANCH\tCREATED_AT\tMESSAGE_COUNT"); err != nil {
 		return err
 	}
 	for _, sess := range sessions {
 		if _, err := fmt.Fprintf(
 			tw,
-			"%s\t%s\t%s\t%d\n",
+			"%s\t%s\t%s\t%s\t%d\n",
 			fallback(sess.NativeHandle, "-"),
 			sess.Profile,
+			fallback(sess.Branch, "-"),
 			sess.CreatedAt.Format(time.RFC3339),
 			sess.MessageCount,
 		); err != nil {
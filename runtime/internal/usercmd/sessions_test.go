@@ -77,7 +77,7 @@ func TestSessionsListHumanOutputUsesBuiltinLogin(t *testing.T) {
 				t.Fatalf("Authorization header = %q, want %q", got, "Bearer jwt-token")
 			}
 			_ = json.NewEncoder(w).Encode([]hubapi.Session{
-				{ID: "sess-1", Profile: "claude-code", NativeHandle: "claude-1", MessageCount: 4},
+				{ID: "sess-1", Profile: "claude-code", NativeHandle: "claude-1", Branch: "amurg/sess-1", MessageCount: 4},
 			})
 		default:
 			http.NotFound(w, r)
@@ -111,6 +111,9 @@ func TestSessionsListHumanOutputUsesBuiltinLogin(t *testing.T) {
 	if !strings.Contains(out, "claude-1") {
 		t.Fatalf("expected native handle in output, got %q", out)
 	}
+	if !strings.Contains(out, "amurg/sess-1") {
+		t.Fatalf("expected worktree branch in output, got %q", out)
+	}
 	if !strings.Contains(out, "4") {
 		t.Fatalf("expected message count in output, got %q", out)
 	}
@@ -133,3 +136,62 @@ func TestResolveHubBaseURLFromRuntimeConfig(t *testing.T) {
 		t.Fatalf("resolveHubBaseURL = %q, want %q", got, "https://hub.example.com")
 	}
 }
+
+func TestSessionsExportWritesFile(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/sessions/sess-1/export" || r.URL.Query().Get("format") != "html" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer jwt-token" {
+			t.Fatalf("Authorization header = %q, want %q", got, "Bearer jwt-token")
+		}
+		_, _ = w.Write([]byte("<html>transcript</html>"))
+	}))
+	defer srv.Close()
+
+	outPath := filepath.Join(t.TempDir(), "session.html")
+	root := NewRootCmd("test")
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	root.SetOut(&stdout)
+	root.SetErr(&stderr)
+	root.SetArgs([]string{
+		"sessions", "export", "sess-1",
+		"--hub-url", srv.URL,
+		"--token", "jwt-token",
+		"--format", "html",
+		"--output", outPath,
+	})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute: %v; stderr=%s", err, stderr.String())
+	}
+
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != "<html>transcript</html>" {
+		t.Fatalf("exported file = %q", data)
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected no stdout output, got %q", stdout.String())
+	}
+}
+
+func TestSessionsExportRejectsUnknownFormat(t *testing.T) {
+	t.Parallel()
+
+	root := NewRootCmd("test")
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	root.SetArgs([]string{"sessions", "export", "sess-1", "--hub-url", "http://localhost", "--token", "t", "--format", "pdf"})
+
+	err := root.Execute()
+	if err == nil || !strings.Contains(err.Error(), "unsupported format") {
+		t.Fatalf("Execute error = %v, want unsupported format", err)
+	}
+}
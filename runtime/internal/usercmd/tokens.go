@@ -0,0 +1,181 @@
+package usercmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/amurg-ai/amurg/pkg/hubapi"
+)
+
+type tokensCreateOptions struct {
+	hubOptions
+	scopes        []string
+	expiresInDays int
+	jsonOutput    bool
+}
+
+type tokensListOptions struct {
+	hubOptions
+	jsonOutput bool
+}
+
+func newTokensCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tokens",
+		Short: "Manage personal access tokens",
+		Long: "Manage personal access tokens for scripts and automation.\n\n" +
+			"A personal access token works anywhere --token / AMURG_TOKEN is accepted. " +
+			"Creating or revoking tokens needs an interactive sign-in, so pass --username and --password " +
+			"(or a session JWT as --token).",
+	}
+	cmd.AddCommand(newTokensCreateCmd())
+	cmd.AddCommand(newTokensListCmd())
+	cmd.AddCommand(newTokensRevokeCmd())
+	return cmd
+}
+
+func newTokensCreateCmd() *cobra.Command {
+	opts := &tokensCreateOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a personal access token",
+		Long: "Create a personal access token and print it. The token is shown only once.\n\n" +
+			"Scopes: sessions:read (read sessions and transcripts), messages:send (create sessions, " +
+			"send messages and run agents) and admin (admin API, admins only).",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTokensCreate(cmd, args[0], opts)
+		},
+	}
+
+	opts.addFlags(cmd)
+	cmd.Flags().StringSliceVar(&opts.scopes, "scope", []string{"sessions:read"}, "token scope; repeat or comma-separate for several")
+	cmd.Flags().IntVar(&opts.expiresInDays, "expires-in-days", 0, "days until the token expires (default: hub default, 90)")
+	cmd.Flags().BoolVar(&opts.jsonOutput, "json", false, "emit JSON")
+
+	return cmd
+}
+
+func runTokensCreate(cmd *cobra.Command, name string, opts *tokensCreateOptions) error {
+	ctx, cancel := context.WithTimeout(cmd.Context(), 15*time.Second)
+	defer cancel()
+
+	client, err := opts.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	token, err := client.CreateToken(ctx, hubapi.TokenRequest{
+		Name:          name,
+		Scopes:        opts.scopes,
+		ExpiresInDays: opts.expiresInDays,
+	})
+	if err != nil {
+		return err
+	}
+
+	if opts.jsonOutput {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(token)
+	}
+	if _, err := fmt.Fprintln(cmd.OutOrStdout(), token.Token); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(cmd.ErrOrStderr(), "Created token %s (%s), expires %s. Store it now; it will not be shown again.\n",
+		token.Name, token.ID, token.ExpiresAt.Format(time.RFC3339))
+	return err
+}
+
+func newTokensListCmd() *cobra.Command {
+	opts := &tokensListOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List your personal access tokens",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTokensList(cmd, opts)
+		},
+	}
+
+	opts.addFlags(cmd)
+	cmd.Flags().BoolVar(&opts.jsonOutput, "json", false, "emit JSON")
+
+	return cmd
+}
+
+func runTokensList(cmd *cobra.Command, opts *tokensListOptions) error {
+	ctx, cancel := context.WithTimeout(cmd.Context(), 15*time.Second)
+	defer cancel()
+
+	client, err := opts.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := client.ListTokens(ctx)
+	if err != nil {
+		return err
+	}
+
+	if opts.jsonOutput {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(tokens)
+	}
+	if len(tokens) == 0 {
+		_, err := fmt.Fprintln(cmd.OutOrStdout(), "No personal access tokens.")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "ID\tNAME\tSCOPES\tEXPIRES_AT\tLAST_USED_AT"); err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		lastUsed := "-"
+		if t.LastUsedAt != nil {
+			lastUsed = t.LastUsedAt.Format(time.RFC3339)
+		}
+		scopes, _ := json.Marshal(t.Scopes)
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			t.ID, t.Name, scopes, t.ExpiresAt.Format(time.RFC3339), lastUsed); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func newTokensRevokeCmd() *cobra.Command {
+	opts := &hubOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "revoke <token-id>",
+		Short: "Revoke a personal access token",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(cmd.Context(), 15*time.Second)
+			defer cancel()
+
+			client, err := opts.connect(ctx)
+			if err != nil {
+				return err
+			}
+			if err := client.RevokeToken(ctx, args[0]); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.ErrOrStderr(), "Revoked token %s\n", args[0])
+			return err
+		},
+	}
+
+	opts.addFlags(cmd)
+	return cmd
+}
@@ -0,0 +1,69 @@
+package usercmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amurg-ai/amurg/pkg/hubapi"
+)
+
+func TestTokensCreatePrintsTokenOnce(t *testing.T) {
+	t.Parallel()
+
+	var got hubapi.TokenRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/auth/login":
+			_ = json.NewEncoder(w).Encode(map[string]string{"token": "jwt-token"})
+		case "/api/me/tokens":
+			if r.Method != http.MethodPost {
+				t.Errorf("unexpected method %s", r.Method)
+			}
+			if auth := r.Header.Get("Authorization"); auth != "Bearer jwt-token" {
+				t.Errorf("Authorization header = %q, want %q", auth, "Bearer jwt-token")
+			}
+			_ = json.NewDecoder(r.Body).Decode(&got)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(hubapi.Token{
+				ID: "tok-1", Name: got.Name, Scopes: got.Scopes, Token: "amurg_pat_abc",
+				ExpiresAt: time.Now().Add(30 * 24 * time.Hour),
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	root := NewRootCmd("test")
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	root.SetOut(&stdout)
+	root.SetErr(&stderr)
+	root.SetArgs([]string{
+		"tokens", "create", "ci",
+		"--hub-url", srv.URL,
+		"--username", "alice",
+		"--password", "secret",
+		"--scope", "sessions:read,messages:send",
+		"--expires-in-days", "30",
+	})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute: %v; stderr=%s", err, stderr.String())
+	}
+
+	if got.Name != "ci" || strings.Join(got.Scopes, ",") != "sessions:read,messages:send" || got.ExpiresInDays != 30 {
+		t.Fatalf("unexpected token request %+v", got)
+	}
+	if strings.TrimSpace(stdout.String()) != "amurg_pat_abc" {
+		t.Fatalf("stdout = %q, want only the token", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "will not be shown again") {
+		t.Fatalf("expected a one-time warning on stderr, got %q", stderr.String())
+	}
+}
@@ -437,6 +437,8 @@ Type=simple
 ExecStart=/usr/local/bin/amurg-runtime run %s
 Restart=always
 RestartSec=5
+# Lets the runtime apply per-session cgroup limits.
+Delegate=yes
 
 [Install]
 WantedBy=multi-user.target
@@ -0,0 +1,61 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// checkpointRefPrefix is where checkpoints are kept, one ref per session
+// and turn. Refs outside refs/heads and refs/tags are invisible to git log
+// and push, but keep their commits from being garbage collected.
+const checkpointRefPrefix = "refs/amurg/checkpoints/"
+
+// checkpointIdent is the author and committer of checkpoint commits.
+var checkpointIdent = []string{
+	"GIT_AUTHOR_NAME=amurg", "GIT_AUTHOR_EMAIL=amurg@localhost",
+	"GIT_COMMITTER_NAME=amurg", "GIT_COMMITTER_EMAIL=amurg@localhost",
+}
+
+// CheckpointRef returns the ref of a session's checkpoint for a turn.
+func CheckpointRef(session, turn string) string {
+	return checkpointRefPrefix + session + "/" + turn
+}
+
+// Checkpoint stores a tree returned by Snapshot under ref.
+func Checkpoint(ctx context.Context, dir, ref, tree string) error {
+	commit, err := gitEnv(ctx, dir, checkpointIdent, "commit-tree", "-m", "amurg checkpoint "+ref, tree)
+	if err != nil {
+		return err
+	}
+	_, err = git(ctx, dir, "update-ref", ref, commit)
+	return err
+}
+
+// Restore makes dir's work tree match the checkpoint at ref. Files created
+// since the checkpoint are deleted; ignored files, HEAD and the user's index
+// are left alone.
+func Restore(ctx context.Context, dir, ref string) error {
+	tree, err := git(ctx, dir, "rev-parse", "--verify", "--quiet", ref+"^{tree}")
+	if err != nil {
+		return fmt.Errorf("no checkpoint %s", ref)
+	}
+	return withWorkTreeIndex(ctx, dir, func(env []string) error {
+		_, err := gitEnv(ctx, dir, env, "read-tree", "--reset", "-u", tree)
+		return err
+	})
+}
+
+// DeleteCheckpoints removes every checkpoint of a session.
+func DeleteCheckpoints(ctx context.Context, dir, session string) error {
+	refs, err := git(ctx, dir, "for-each-ref", "--format=%(refname)", checkpointRefPrefix+session+"/")
+	if err != nil || refs == "" {
+		return err
+	}
+	for _, ref := range strings.Split(refs, "\n") {
+		if _, err := git(ctx, dir, "update-ref", "-d", ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
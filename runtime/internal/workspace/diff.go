@@ -0,0 +1,162 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/amurg-ai/amurg/pkg/protocol"
+)
+
+// DefaultMaxDiffBytes caps the unified diff of a TurnDiff.
+const DefaultMaxDiffBytes = 256 << 10
+
+// IsRepo reports whether dir is inside a git work tree.
+func IsRepo(ctx context.Context, dir string) bool {
+	out, err := git(ctx, dir, "rev-parse", "--is-inside-work-tree")
+	return err == nil && out == "true"
+}
+
+// Snapshot records the current state of dir's work tree, including
+// uncommitted and untracked files, and returns the ID of the resulting tree.
+// It stages into a temporary index, so the user's index is left untouched.
+func Snapshot(ctx context.Context, dir string) (tree string, err error) {
+	err = withWorkTreeIndex(ctx, dir, func(env []string) error {
+		tree, err = gitEnv(ctx, dir, env, "write-tree")
+		return err
+	})
+	return tree, err
+}
+
+// withWorkTreeIndex calls fn with the environment of a git command that uses
+// a temporary index matching dir's work tree.
+func withWorkTreeIndex(ctx context.Context, dir string, fn func(env []string) error) error {
+	index, err := git(ctx, dir, "rev-parse", "--git-path", "index")
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(index) {
+		index = filepath.Join(dir, index)
+	}
+
+	tmp, err := os.CreateTemp("", "amurg-index-*")
+	if err != nil {
+		return fmt.Errorf("create temp index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	// Starting from a copy of the real index keeps git's stat cache, so only
+	// files that changed are hashed again.
+	data, err := os.ReadFile(index)
+	if err == nil {
+		_, err = tmp.Write(data)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// No usable index (e.g. a repository without commits): start empty.
+		if rerr := os.Remove(tmp.Name()); rerr != nil {
+			return fmt.Errorf("reset temp index: %w", rerr)
+		}
+	}
+
+	env := []string{"GIT_INDEX_FILE=" + tmp.Name()}
+	if _, err := gitEnv(ctx, dir, env, "add", "--all", "--", "."); err != nil {
+		return err
+	}
+	return fn(env)
+}
+
+// Diff compares two snapshots of dir. The unified diff is cut at maxBytes
+// (DefaultMaxDiffBytes when <= 0); the file list is always complete.
+func Diff(ctx context.Context, dir, from, to string, maxBytes int) (*protocol.TurnDiff, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxDiffBytes
+	}
+	d := &protocol.TurnDiff{Files: []protocol.FileChange{}}
+	if from == to {
+		return d, nil
+	}
+
+	nameStatus, err := git(ctx, dir, "diff", "--no-ext-diff", "-M", "-z", "--name-status", from, to)
+	if err != nil {
+		return nil, err
+	}
+	numstat, err := git(ctx, dir, "diff", "--no-ext-diff", "-M", "-z", "--numstat", from, to)
+	if err != nil {
+		return nil, err
+	}
+	d.Files = parseNameStatus(nameStatus)
+	applyNumstat(d.Files, numstat)
+	if len(d.Files) == 0 {
+		return d, nil
+	}
+
+	patch, err := git(ctx, dir, "diff", "--no-ext-diff", "--no-color", "-M", from, to)
+	if err != nil {
+		return nil, err
+	}
+	if len(patch) > maxBytes {
+		patch = patch[:maxBytes]
+		if i := strings.LastIndexByte(patch, '\n'); i > 0 {
+			patch = patch[:i]
+		}
+		d.Truncated = true
+	}
+	d.Patch = patch
+	return d, nil
+}
+
+// parseNameStatus parses the output of git diff --name-status -z.
+func parseNameStatus(out string) []protocol.FileChange {
+	fields := strings.Split(strings.TrimRight(out, "\x00"), "\x00")
+	files := []protocol.FileChange{}
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == "" {
+			break
+		}
+		var fc protocol.FileChange
+		switch fields[i][0] {
+		case 'A':
+			fc.Status = "added"
+		case 'D':
+			fc.Status = "deleted"
+		case 'R':
+			if i+2 >= len(fields) {
+				return files
+			}
+			fc.Status = "renamed"
+			fc.OldPath = fields[i+1]
+			i++
+		default:
+			fc.Status = "modified"
+		}
+		fc.Path = fields[i+1]
+		files = append(files, fc)
+	}
+	return files
+}
+
+// applyNumstat fills in line counts from git diff --numstat -z, which lists
+// files in the same order as --name-status.
+func applyNumstat(files []protocol.FileChange, out string) {
+	fields := strings.Split(strings.TrimRight(out, "\x00"), "\x00")
+	for i, n := 0, 0; i < len(fields) && n < len(files); i, n = i+1, n+1 {
+		parts := strings.SplitN(fields[i], "\t", 3)
+		if len(parts) != 3 {
+			return
+		}
+		if parts[2] == "" {
+			i += 2 // a rename: old and new path follow
+		}
+		if parts[0] == "-" {
+			files[n].Binary = true
+			continue
+		}
+		files[n].Additions, _ = strconv.Atoi(parts[0])
+		files[n].Deletions, _ = strconv.Atoi(parts[1])
+	}
+}
@@ -0,0 +1,183 @@
+// Package workspace isolates agent sessions that share a repository. In
+// worktree mode each session gets its own git worktree and branch, so
+// concurrent sessions do not overwrite each other's uncommitted changes.
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Workspace modes accepted in workspace.mode.
+const (
+	ModeShared   = "shared"   // all sessions run in the work dir (default)
+	ModeWorktree = "worktree" // one git worktree per session
+)
+
+// Cleanup policies accepted in workspace.cleanup.
+const (
+	CleanupAuto   = "auto"   // remove unless there are uncommitted changes or new commits (default)
+	CleanupRemove = "remove" // always remove the worktree and its branch
+	CleanupKeep   = "keep"   // always keep both
+)
+
+// DefaultBranchPrefix is prepended to the session ID to name a worktree's branch.
+const DefaultBranchPrefix = "amurg/"
+
+// Worktree is a session's checkout of an agent's repository.
+type Worktree struct {
+	Repo   string // the repository's main checkout
+	GitDir string // the repository's shared git dir, written to by commits
+	Path   string // the worktree's directory
+	Branch string
+	Base   string // commit the branch started from
+}
+
+// Options configure Create.
+type Options struct {
+	Dir          string // parent dir for worktrees; default <git dir>/amurg-worktrees
+	BaseRef      string // what new branches start from; default HEAD
+	BranchPrefix string // default DefaultBranchPrefix
+}
+
+// Create checks out a worktree named name of the repository containing repo.
+// A worktree left behind for the same name is reused, so a session that is
+// recreated after a runtime restart continues where it stopped.
+func Create(ctx context.Context, repo, name string, opts Options) (*Worktree, error) {
+	top, err := git(ctx, repo, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a git repository: %w", repo, err)
+	}
+	gitDir, err := git(ctx, top, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(top, gitDir)
+	}
+
+	dir := opts.Dir
+	if dir == "" {
+		dir = filepath.Join(gitDir, "amurg-worktrees")
+	}
+	prefix := opts.BranchPrefix
+	if prefix == "" {
+		prefix = DefaultBranchPrefix
+	}
+	baseRef := opts.BaseRef
+	if baseRef == "" {
+		baseRef = "HEAD"
+	}
+	base, err := git(ctx, top, "rev-parse", "--verify", baseRef+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("resolve base_ref %q: %w", baseRef, err)
+	}
+
+	name = filepath.Base(name)
+	wt := &Worktree{
+		Repo:   top,
+		GitDir: gitDir,
+		Path:   filepath.Join(dir, name),
+		Branch: prefix + name,
+		Base:   base,
+	}
+
+	if _, err := os.Stat(wt.Path); err == nil {
+		branch, err := git(ctx, wt.Path, "rev-parse", "--abbrev-ref", "HEAD")
+		if err != nil {
+			return nil, fmt.Errorf("%s exists but is not a worktree: %w", wt.Path, err)
+		}
+		wt.Branch = branch
+		if mb, err := git(ctx, top, "merge-base", base, branch); err == nil {
+			wt.Base = mb
+		}
+		return wt, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create worktree dir: %w", err)
+	}
+	if _, err := git(ctx, top, "rev-parse", "--verify", "--quiet", "refs/heads/"+wt.Branch); err == nil {
+		if _, err := git(ctx, top, "worktree", "add", wt.Path, wt.Branch); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := git(ctx, top, "worktree", "add", "-b", wt.Branch, wt.Path, base); err != nil {
+			return nil, err
+		}
+	}
+	return wt, nil
+}
+
+// Close applies the cleanup policy and reports whether the worktree was kept.
+func (w *Worktree) Close(ctx context.Context, policy string) (kept bool, err error) {
+	switch policy {
+	case CleanupKeep:
+		return true, nil
+	case CleanupRemove:
+	case "", CleanupAuto:
+		changed, err := w.HasChanges(ctx)
+		if err != nil || changed {
+			return true, err
+		}
+	default:
+		return true, fmt.Errorf("unknown cleanup policy %q", policy)
+	}
+
+	if _, err := git(ctx, w.Repo, "worktree", "remove", "--force", w.Path); err != nil {
+		return true, err
+	}
+	if _, err := git(ctx, w.Repo, "branch", "-D", w.Branch); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// HasChanges reports whether the worktree has uncommitted changes or commits
+// that are not on its base.
+func (w *Worktree) HasChanges(ctx context.Context) (bool, error) {
+	status, err := git(ctx, w.Path, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	if status != "" {
+		return true, nil
+	}
+	ahead, err := git(ctx, w.Path, "rev-list", "--count", w.Base+"..HEAD")
+	if err != nil {
+		return false, err
+	}
+	n, err := strconv.Atoi(ahead)
+	return n > 0, err
+}
+
+// git runs a git command in dir and returns its trimmed stdout.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	return gitEnv(ctx, dir, nil, args...)
+}
+
+// gitEnv is git with extra environment variables.
+func gitEnv(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", errors.New("git " + args[0] + ": " + msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
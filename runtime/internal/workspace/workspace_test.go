@@ -0,0 +1,274 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initRepo creates a git repository with one commit.
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	run(t, repo, "init", "-q", "-b", "main")
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, repo, "add", "README.md")
+	run(t, repo, "commit", "-q", "-m", "initial")
+	return repo
+}
+
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestCreate_IsolatesSessions(t *testing.T) {
+	repo := initRepo(t)
+	ctx := context.Background()
+
+	a, err := Create(ctx, repo, "sess-a", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Create(ctx, repo, "sess-b", Options{BranchPrefix: "agent/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Branch != "amurg/sess-a" || b.Branch != "agent/sess-b" {
+		t.Fatalf("branches = %q, %q", a.Branch, b.Branch)
+	}
+	if a.Path == b.Path || !strings.HasPrefix(a.Path, a.GitDir) {
+		t.Fatalf("unexpected worktree paths %q, %q (git dir %q)", a.Path, b.Path, a.GitDir)
+	}
+
+	if err := os.WriteFile(filepath.Join(a.Path, "README.md"), []byte("changed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(b.Path, "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello\n" {
+		t.Fatalf("change in one worktree leaked into another: %q", data)
+	}
+	if status := run(t, repo, "status", "--porcelain"); status != "" {
+		t.Fatalf("main checkout is dirty: %q", status)
+	}
+}
+
+func TestCreate_ReusesExistingWorktree(t *testing.T) {
+	repo := initRepo(t)
+	ctx := context.Background()
+
+	first, err := Create(ctx, repo, "sess-1", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Create(ctx, repo, "sess-1", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Path != first.Path || second.Branch != first.Branch {
+		t.Fatalf("reuse returned %+v, want %+v", second, first)
+	}
+}
+
+func TestClose_Policies(t *testing.T) {
+	repo := initRepo(t)
+	ctx := context.Background()
+
+	clean, err := Create(ctx, repo, "clean", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept, err := clean.Close(ctx, CleanupAuto); err != nil || kept {
+		t.Fatalf("auto cleanup of clean worktree: kept=%v err=%v", kept, err)
+	}
+	if _, err := os.Stat(clean.Path); !os.IsNotExist(err) {
+		t.Fatalf("worktree not removed: %v", err)
+	}
+	if branches := run(t, repo, "branch", "--list", clean.Branch); branches != "" {
+		t.Fatalf("branch not deleted: %q", branches)
+	}
+
+	committed, err := Create(ctx, repo, "committed", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(committed.Path, "new.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, committed.Path, "add", "new.txt")
+	run(t, committed.Path, "commit", "-q", "-m", "work")
+	if kept, err := committed.Close(ctx, CleanupAuto); err != nil || !kept {
+		t.Fatalf("auto cleanup must keep a worktree with commits: kept=%v err=%v", kept, err)
+	}
+
+	if kept, err := committed.Close(ctx, CleanupRemove); err != nil || kept {
+		t.Fatalf("remove cleanup: kept=%v err=%v", kept, err)
+	}
+	if _, err := os.Stat(committed.Path); !os.IsNotExist(err) {
+		t.Fatalf("worktree not removed: %v", err)
+	}
+}
+
+func TestCreate_NotARepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	if _, err := Create(context.Background(), t.TempDir(), "sess", Options{}); err == nil {
+		t.Fatal("expected error outside a git repository")
+	}
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	repo := initRepo(t)
+	ctx := context.Background()
+	for name, content := range map[string]string{"keep.txt": "a\nb\n", "old.txt": "gone\n", "moved.txt": "same content\nacross a rename\n"} {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run(t, repo, "add", ".")
+	run(t, repo, "commit", "-q", "-m", "files")
+
+	before, err := Snapshot(ctx, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "keep.txt"), []byte("a\nc\nd\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "new.txt"), []byte("untracked\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(repo, "old.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(repo, "moved.txt"), filepath.Join(repo, "renamed.txt")); err != nil {
+		t.Fatal(err)
+	}
+	after, err := Snapshot(ctx, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := Diff(ctx, repo, before, after, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, f := range d.Files {
+		got[f.Path] = f.Status
+		if f.Path == "keep.txt" && (f.Additions != 2 || f.Deletions != 1) {
+			t.Errorf("keep.txt: +%d -%d, want +2 -1", f.Additions, f.Deletions)
+		}
+		if f.Path == "renamed.txt" && f.OldPath != "moved.txt" {
+			t.Errorf("renamed.txt old path = %q", f.OldPath)
+		}
+	}
+	want := map[string]string{"keep.txt": "modified", "new.txt": "added", "old.txt": "deleted", "renamed.txt": "renamed"}
+	if len(got) != len(want) {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+	for path, status := range want {
+		if got[path] != status {
+			t.Errorf("%s: status %q, want %q", path, got[path], status)
+		}
+	}
+	if !strings.Contains(d.Patch, "+untracked") || d.Truncated {
+		t.Errorf("unexpected patch (truncated=%v):\n%s", d.Truncated, d.Patch)
+	}
+
+	// The user's index is not touched by snapshots.
+	if staged := run(t, repo, "diff", "--cached", "--name-only"); staged != "" {
+		t.Errorf("snapshot staged files: %q", staged)
+	}
+
+	capped, err := Diff(ctx, repo, before, after, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !capped.Truncated || len(capped.Patch) > 40 || len(capped.Files) != 4 {
+		t.Errorf("capped diff: truncated=%v, %d patch bytes, %d files", capped.Truncated, len(capped.Patch), len(capped.Files))
+	}
+
+	if same, err := Diff(ctx, repo, after, after, 0); err != nil || len(same.Files) != 0 {
+		t.Errorf("diff of identical snapshots = %+v, %v", same, err)
+	}
+}
+
+func TestCheckpointRestore(t *testing.T) {
+	repo := initRepo(t)
+	ctx := context.Background()
+	if err := os.WriteFile(filepath.Join(repo, "notes.txt"), []byte("draft\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, ".gitignore"), []byte("*.log\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := Snapshot(ctx, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := CheckpointRef("sess-1", "msg-1")
+	if err := Checkpoint(ctx, repo, ref, tree); err != nil {
+		t.Fatal(err)
+	}
+
+	// The turn edits a tracked file, deletes an untracked one and creates
+	// new files, one of them ignored.
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("broken\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(repo, "notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(repo, "gen"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "gen", "out.go"), []byte("package gen\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "build.log"), []byte("log\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Restore(ctx, repo, ref); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"README.md": "hello\n", "notes.txt": "draft\n", "build.log": "log\n"} {
+		data, err := os.ReadFile(filepath.Join(repo, name))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v; want %q", name, data, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(repo, "gen", "out.go")); !os.IsNotExist(err) {
+		t.Errorf("file created after the checkpoint was not removed: %v", err)
+	}
+	if staged := run(t, repo, "diff", "--cached", "--name-only"); staged != "" {
+		t.Errorf("restore staged files: %q", staged)
+	}
+
+	if err := DeleteCheckpoints(ctx, repo, "sess-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(ctx, repo, ref); err == nil {
+		t.Error("restore of a deleted checkpoint succeeded")
+	}
+}
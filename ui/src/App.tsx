@@ -2,6 +2,7 @@ import { useEffect, lazy, Suspense } from "react";
 import { Routes, Route, Navigate, useLocation } from "react-router-dom";
 import { useSessionStore } from "@/stores/sessionStore";
 import { Login } from "@/components/Login";
+import { ResetPassword } from "@/components/ResetPassword";
 import { Chat } from "@/components/Chat";
 
 const ConnectRuntime = lazy(() =>
@@ -19,6 +20,7 @@ export function App() {
   return (
     <Routes>
       <Route path="/login" element={<Login />} />
+      <Route path="/reset-password" element={<ResetPassword />} />
       {isAuthenticated ? (
         <>
           <Route
@@ -1,5 +1,5 @@
 import { describe, it, expect, vi, beforeEach, afterEach } from "vitest";
-import { api } from "./client";
+import { api, MFARequiredError } from "./client";
 
 // Save originals so we can restore them.
 const originalFetch = globalThis.fetch;
@@ -46,6 +46,18 @@ describe("api client", () => {
       });
     });
 
+    it("throws MFARequiredError without storing a token", async () => {
+      globalThis.fetch = vi.fn().mockResolvedValue({
+        ok: true,
+        json: () => Promise.resolve({ mfa_required: true, mfa_token: "challenge" }),
+      });
+
+      await expect(api.login("myuser", "mypass")).rejects.toBeInstanceOf(
+        MFARequiredError,
+      );
+      expect(localStorage.getItem("amurg_token")).toBeNull();
+    });
+
     it("throws on invalid credentials", async () => {
       globalThis.fetch = vi.fn().mockResolvedValue({
         ok: false,
@@ -73,6 +85,41 @@ describe("api client", () => {
     });
   });
 
+  // --- resetPassword ---
+  describe("resetPassword", () => {
+    it("posts the token without a session", async () => {
+      localStorage.setItem("amurg_token", "stale");
+      globalThis.fetch = vi.fn().mockResolvedValue({
+        ok: true,
+        json: () => Promise.resolve({ status: "ok", username: "alice" }),
+      });
+
+      const result = await api.resetPassword("reset-tok", "newpassword1");
+
+      expect(result.username).toBe("alice");
+      const [url, options] = (globalThis.fetch as ReturnType<typeof vi.fn>).mock
+        .calls[0];
+      expect(url).toBe("/api/auth/password-reset");
+      expect(options.headers.Authorization).toBeUndefined();
+      expect(JSON.parse(options.body)).toEqual({
+        token: "reset-tok",
+        new_password: "newpassword1",
+      });
+    });
+
+    it("surfaces the hub's error", async () => {
+      globalThis.fetch = vi.fn().mockResolvedValue({
+        ok: false,
+        status: 400,
+        json: () => Promise.resolve({ error: "reset link is invalid or has expired" }),
+      });
+
+      await expect(api.resetPassword("used", "newpassword1")).rejects.toThrow(
+        "reset link is invalid or has expired",
+      );
+    });
+  });
+
   // --- isAuthenticated ---
   describe("isAuthenticated", () => {
     it("returns false when no token", () => {
@@ -12,6 +12,17 @@ export function setTokenGetter(getter: TokenGetter) {
 
 export { tokenGetter };
 
+// Thrown by api.login when the account has two-factor authentication; pass
+// mfaToken and a code to api.loginMFA to finish signing in.
+export class MFARequiredError extends Error {
+  mfaToken: string;
+
+  constructor(mfaToken: string) {
+    super("Two-factor code required");
+    this.mfaToken = mfaToken;
+  }
+}
+
 async function request<T>(path: string, options?: RequestInit): Promise<T> {
   const token = await tokenGetter();
   const res = await fetch(`${BASE}${path}`, {
@@ -58,6 +69,9 @@ export const api = {
     }
 
     const data = await res.json();
+    if (data.mfa_required) {
+      throw new MFARequiredError(data.mfa_token);
+    }
     // Security: storing JWT in localStorage exposes it to XSS. This is an accepted
     // trade-off because (1) httpOnly cookies don't work with WebSocket auth, and
     // (2) the hub's Content-Security-Policy restricts script sources to mitigate XSS.
@@ -65,6 +79,53 @@ export const api = {
     return data;
   },
 
+  // Second login step for accounts with two-factor authentication. The
+  // returned token is stored by the caller via setToken.
+  loginMFA: async (mfaToken: string, code: string): Promise<{ token: string }> => {
+    const res = await fetch(`${BASE}/api/auth/login/mfa`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ mfa_token: mfaToken, code }),
+    });
+
+    if (!res.ok) {
+      const body = await res.json().catch(() => ({ error: "Invalid code" }));
+      throw new Error(body.error || "Invalid code");
+    }
+    return res.json();
+  },
+
+  // Redeems an admin-issued reset link. Needs no session; the user signs in
+  // with the new password afterwards.
+  resetPassword: async (token: string, newPassword: string): Promise<{ username: string }> => {
+    const res = await fetch(`${BASE}/api/auth/password-reset`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ token, new_password: newPassword }),
+    });
+
+    if (!res.ok) {
+      const body = await res.json().catch(() => ({ error: "Password reset failed" }));
+      throw new Error(body.error || "Password reset failed");
+    }
+    return res.json();
+  },
+
+  // Changes the signed-in user's password. Other sessions are signed out; the
+  // returned token replaces the current one.
+  changePassword: async (currentPassword: string, newPassword: string): Promise<void> => {
+    const { token } = await request<{ token: string }>("/api/me/password", {
+      method: "POST",
+      body: JSON.stringify({ current_password: currentPassword, new_password: newPassword }),
+    });
+    localStorage.setItem("amurg_token", token);
+  },
+
+  // Stores a token the hub handed over after a redirect sign-in (OIDC).
+  setToken: (token: string) => {
+    localStorage.setItem("amurg_token", token);
+  },
+
   logout: () => {
     localStorage.removeItem("amurg_token");
     window.location.href = "/login";
@@ -85,6 +85,9 @@ function AgentConfigEditor({
   const [maxSessions, setMaxSessions] = useState(initLim.max_sessions?.toString() || "");
   const [sessionTimeout, setSessionTimeout] = useState(initLim.session_timeout || "");
   const [idleTimeout, setIdleTimeout] = useState(initLim.idle_timeout || "");
+  const [cpuQuota, setCpuQuota] = useState(initLim.cpu_quota?.toString() || "");
+  const [memoryMaxMiB, setMemoryMaxMiB] = useState(initLim.memory_max_bytes ? (initLim.memory_max_bytes / (1 << 20)).toString() : "");
+  const [pidsMax, setPidsMax] = useState(initLim.pids_max?.toString() || "");
   const [saving, setSaving] = useState(false);
   const [toast, setToast] = useState<{ msg: string; ok: boolean } | null>(null);
 
@@ -119,6 +122,9 @@ function AgentConfigEditor({
       if (maxSessions) limits.max_sessions = parseInt(maxSessions, 10);
       if (sessionTimeout) limits.session_timeout = sessionTimeout;
       if (idleTimeout) limits.idle_timeout = idleTimeout;
+      if (cpuQuota) limits.cpu_quota = parseFloat(cpuQuota);
+      if (memoryMaxMiB) limits.memory_max_bytes = Math.round(parseFloat(memoryMaxMiB) * (1 << 20));
+      if (pidsMax) limits.pids_max = parseInt(pidsMax, 10);
 
       const result = await api.updateAgentConfig(agent.id, { security, limits });
       const pushed = result.pushed_to_runtime ? "pushed to runtime" : "runtime offline, will apply on reconnect";
@@ -260,7 +266,26 @@ function AgentConfigEditor({
               placeholder="5m"
               className="w-full bg-slate-700 text-slate-200 text-sm rounded px-3 py-2 border border-slate-600 focus:border-teal-500 focus:outline-none" />
           </label>
+          <label className="space-y-1">
+            <span className="text-xs text-slate-400">CPU Quota (CPUs)</span>
+            <input type="number" inputMode="decimal" step="0.1" min="0" value={cpuQuota} onChange={e => setCpuQuota(e.target.value)}
+              placeholder="unlimited"
+              className="w-full bg-slate-700 text-slate-200 text-sm rounded px-3 py-2 border border-slate-600 focus:border-teal-500 focus:outline-none" />
+          </label>
+          <label className="space-y-1">
+            <span className="text-xs text-slate-400">Memory Max (MiB)</span>
+            <input type="number" inputMode="numeric" min="0" value={memoryMaxMiB} onChange={e => setMemoryMaxMiB(e.target.value)}
+              placeholder="unlimited"
+              className="w-full bg-slate-700 text-slate-200 text-sm rounded px-3 py-2 border border-slate-600 focus:border-teal-500 focus:outline-none" />
+          </label>
+          <label className="space-y-1">
+            <span className="text-xs text-slate-400">Max Processes</span>
+            <input type="number" inputMode="numeric" pattern="[0-9]*" min="0" value={pidsMax} onChange={e => setPidsMax(e.target.value)}
+              placeholder="unlimited"
+              className="w-full bg-slate-700 text-slate-200 text-sm rounded px-3 py-2 border border-slate-600 focus:border-teal-500 focus:outline-none" />
+          </label>
         </div>
+        <p className="text-xs text-slate-500">CPU, memory and process limits apply per session through cgroup v2 on Linux runtimes.</p>
       </div>
 
       <div className="flex justify-end gap-3 pt-2">
@@ -359,6 +384,14 @@ function AgentsTab() {
                         </td>
                         <td className="px-4 py-2 text-slate-400 text-xs">
                           {sec.permission_mode || "default"}
+                          {ep.sandbox && (
+                            <span
+                              className={`ml-2 ${ep.sandbox.backend ? "text-teal-400" : "text-amber-400"}`}
+                              title={ep.sandbox.error || `Sandbox mode: ${ep.sandbox.mode}${ep.sandbox.deny_network ? ", network denied" : ""}`}
+                            >
+                              {ep.sandbox.backend ? `sandbox: ${ep.sandbox.backend}` : "unsandboxed"}
+                            </span>
+                          )}
                         </td>
                         <td className="px-4 py-2">
                           {ep.config_override ? (
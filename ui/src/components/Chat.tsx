@@ -84,7 +84,22 @@ function formatDuration(ms: number): string {
   return `${m}:${String(s).padStart(2, "0")}`;
 }
 
-function StateIndicator({ state, isResponding }: { state: string; isResponding: boolean }) {
+function ordinal(n: number): string {
+  const tens = n % 100;
+  if (tens >= 11 && tens <= 13) return `${n}th`;
+  switch (n % 10) {
+    case 1:
+      return `${n}st`;
+    case 2:
+      return `${n}nd`;
+    case 3:
+      return `${n}rd`;
+    default:
+      return `${n}th`;
+  }
+}
+
+function StateIndicator({ state, isResponding, queuePosition }: { state: string; isResponding: boolean; queuePosition?: number }) {
   if (isResponding || state === "responding") {
     return (
       <span className="flex items-center gap-1.5 text-xs text-green-400">
@@ -122,6 +137,13 @@ function StateIndicator({ state, isResponding }: { state: string; isResponding:
           Creating
         </span>
       );
+    case "queued":
+      return (
+        <span className="flex items-center gap-1.5 text-xs text-amber-400" title="The runtime is at its session limit">
+          <span className="w-2 h-2 bg-amber-500 rounded-full animate-pulse" />
+          {queuePosition ? `Waiting (${ordinal(queuePosition)} in line)` : "Waiting for a free slot"}
+        </span>
+      );
     default:
       return (
         <span className="flex items-center gap-1.5 text-xs text-slate-400">
@@ -336,7 +358,22 @@ export function Chat() {
                 <span className="hidden sm:inline-flex items-center rounded-full bg-slate-700 px-2 py-0.5 text-[11px] font-medium text-slate-300">
                   {PROMPT_PROFILE_DISPLAY[activeSession.prompt_profile || "standard"]?.label || "Standard"}
                 </span>
-                <StateIndicator state={activeSession.state} isResponding={isResponding} />
+                {activeSession.branch && (
+                  <span className="hidden sm:inline-flex items-center rounded-full bg-slate-700 px-2 py-0.5 text-[11px] font-mono text-slate-300 truncate max-w-[12rem]" title={`Worktree branch ${activeSession.branch}`}>
+                    {activeSession.branch}
+                  </span>
+                )}
+                {activeSession.fork_mode && (
+                  <span
+                    className="hidden sm:inline-flex items-center rounded-full bg-slate-700 px-2 py-0.5 text-[11px] font-medium text-slate-300"
+                    title={activeSession.fork_mode === "native"
+                      ? "Forked with the agent's own context"
+                      : "Forked from the transcript: the agent gets it with the first message, not its original context"}
+                  >
+                    {activeSession.fork_mode === "native" ? "Fork" : "Fork (transcript)"}
+                  </span>
+                )}
+                <StateIndicator state={activeSession.state} isResponding={isResponding} queuePosition={activeSession.queue_position} />
                 {pendingCount > 0 && (
                   <span className="inline-flex items-center justify-center w-5 h-5 text-xs font-bold bg-amber-600 text-white rounded-full">
                     {pendingCount}
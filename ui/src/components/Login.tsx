@@ -1,17 +1,47 @@
-import { useState } from "react";
+import { useEffect, useState } from "react";
 import { useNavigate, useLocation } from "react-router-dom";
 import { useSessionStore } from "@/stores/sessionStore";
+import { api, MFARequiredError } from "@/api/client";
 
 export function Login() {
   const [username, setUsername] = useState("");
   const [password, setPassword] = useState("");
   const [error, setError] = useState("");
   const [loading, setLoading] = useState(false);
+  const [provider, setProvider] = useState<string | null>(null);
+  const [mfaToken, setMfaToken] = useState("");
+  const [code, setCode] = useState("");
   const login = useSessionStore((s) => s.login);
+  const loginWithToken = useSessionStore((s) => s.loginWithToken);
   const navigate = useNavigate();
   const location = useLocation();
   const returnTo = (location.state as { returnTo?: string })?.returnTo || "/";
 
+  useEffect(() => {
+    api.getAuthConfig()
+      .then((cfg) => setProvider(cfg.provider))
+      .catch(() => setProvider("builtin"));
+  }, []);
+
+  // The hub sends the browser back here after an OIDC sign-in with the
+  // token (or an error) in the URL fragment.
+  useEffect(() => {
+    const params = new URLSearchParams(location.hash.replace(/^#/, ""));
+    const token = params.get("token");
+    const failure = params.get("error");
+    if (!token && !failure) return;
+    window.history.replaceState(null, "", location.pathname);
+    if (failure) {
+      setError(failure);
+      return;
+    }
+    setLoading(true);
+    loginWithToken(token!)
+      .then(() => navigate(returnTo, { replace: true }))
+      .catch(() => setError("Sign-in failed"))
+      .finally(() => setLoading(false));
+  }, [location.hash, location.pathname, loginWithToken, navigate, returnTo]);
+
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
     setError("");
@@ -25,13 +55,37 @@ export function Login() {
     try {
       await login(username, password);
       navigate(returnTo, { replace: true });
-    } catch {
+    } catch (err) {
+      if (err instanceof MFARequiredError) {
+        setMfaToken(err.mfaToken);
+        return;
+      }
       setError("Invalid credentials");
     } finally {
       setLoading(false);
     }
   };
 
+  const handleCodeSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError("");
+
+    setLoading(true);
+    try {
+      const { token } = await api.loginMFA(mfaToken, code.trim());
+      await loginWithToken(token);
+      navigate(returnTo, { replace: true });
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Invalid code");
+      if (err instanceof Error && err.message.startsWith("login expired")) {
+        setMfaToken("");
+        setCode("");
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
   return (
     <div className="min-h-screen flex items-center justify-center bg-slate-900 px-4 relative overflow-hidden">
       {/* Decorative background */}
@@ -45,65 +99,125 @@ export function Login() {
             <p className="text-slate-400 mt-2">Agent Control Plane</p>
           </div>
 
-          <form onSubmit={handleSubmit} className="space-y-4">
-            {error && (
-              <div className="bg-red-900/50 text-red-300 px-4 py-2 rounded-lg text-sm">
-                {error}
-              </div>
-            )}
-
-            <div>
-              <label
-                htmlFor="username"
-                className="block text-sm text-slate-400 mb-1"
+          {provider === "oidc" ? (
+            <div className="space-y-4">
+              {error && (
+                <div className="bg-red-900/50 text-red-300 px-4 py-2 rounded-lg text-sm">
+                  {error}
+                </div>
+              )}
+              <a
+                href="/api/auth/oidc/login"
+                className="block w-full py-3 bg-teal-600 hover:bg-teal-700 text-center
+                           text-white rounded-lg font-medium transition-colors"
               >
-                Username
-              </label>
-              <input
-                id="username"
-                type="text"
-                value={username}
-                onChange={(e) => setUsername(e.target.value)}
-                autoComplete="username"
-                className="w-full px-3 py-2.5 bg-slate-800 border border-slate-700 rounded-lg
-                           text-slate-100 placeholder-slate-500
-                           focus:outline-none focus:ring-2 focus:ring-teal-500 focus:border-transparent"
-                placeholder="admin"
-                autoFocus
-                required
-              />
+                {loading ? "Signing in..." : "Sign in with SSO"}
+              </a>
             </div>
+          ) : mfaToken ? (
+            <form onSubmit={handleCodeSubmit} className="space-y-4">
+              {error && (
+                <div className="bg-red-900/50 text-red-300 px-4 py-2 rounded-lg text-sm">
+                  {error}
+                </div>
+              )}
+
+              <div>
+                <label
+                  htmlFor="code"
+                  className="block text-sm text-slate-400 mb-1"
+                >
+                  Authentication code
+                </label>
+                <input
+                  id="code"
+                  type="text"
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                  autoComplete="one-time-code"
+                  inputMode="numeric"
+                  className="w-full px-3 py-2.5 bg-slate-800 border border-slate-700 rounded-lg
+                             text-slate-100 placeholder-slate-500
+                             focus:outline-none focus:ring-2 focus:ring-teal-500 focus:border-transparent"
+                  placeholder="123456"
+                  autoFocus
+                  required
+                />
+                <p className="text-xs text-slate-500 mt-2">
+                  Enter the code from your authenticator app, or a recovery code.
+                </p>
+              </div>
 
-            <div>
-              <label
-                htmlFor="password"
-                className="block text-sm text-slate-400 mb-1"
+              <button
+                type="submit"
+                disabled={loading}
+                className="w-full py-3 bg-teal-600 hover:bg-teal-700 disabled:bg-teal-800
+                           text-white rounded-lg font-medium transition-colors"
               >
-                Password
-              </label>
-              <input
-                id="password"
-                type="password"
-                value={password}
-                onChange={(e) => setPassword(e.target.value)}
-                autoComplete="current-password"
-                className="w-full px-3 py-2.5 bg-slate-800 border border-slate-700 rounded-lg
-                           text-slate-100 placeholder-slate-500
-                           focus:outline-none focus:ring-2 focus:ring-teal-500 focus:border-transparent"
-                placeholder="password"
-                required
-              />
-            </div>
+                {loading ? "Verifying..." : "Verify"}
+              </button>
+            </form>
+          ) : (
+            <form onSubmit={handleSubmit} className="space-y-4">
+              {error && (
+                <div className="bg-red-900/50 text-red-300 px-4 py-2 rounded-lg text-sm">
+                  {error}
+                </div>
+              )}
+
+              <div>
+                <label
+                  htmlFor="username"
+                  className="block text-sm text-slate-400 mb-1"
+                >
+                  Username
+                </label>
+                <input
+                  id="username"
+                  type="text"
+                  value={username}
+                  onChange={(e) => setUsername(e.target.value)}
+                  autoComplete="username"
+                  className="w-full px-3 py-2.5 bg-slate-800 border border-slate-700 rounded-lg
+                             text-slate-100 placeholder-slate-500
+                             focus:outline-none focus:ring-2 focus:ring-teal-500 focus:border-transparent"
+                  placeholder="admin"
+                  autoFocus
+                  required
+                />
+              </div>
 
-            <button
-              type="submit"
-              disabled={loading}
-              className="w-full py-3 bg-teal-600 hover:bg-teal-700 disabled:bg-teal-800
-                         text-white rounded-lg font-medium transition-colors"
-            >
-              {loading ? "Signing in..." : "Sign in"}
-            </button>
-          </form>
+              <div>
+                <label
+                  htmlFor="password"
+                  className="block text-sm text-slate-400 mb-1"
+                >
+                  Password
+                </label>
+                <input
+                  id="password"
+                  type="password"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                  autoComplete="current-password"
+                  className="w-full px-3 py-2.5 bg-slate-800 border border-slate-700 rounded-lg
+                             text-slate-100 placeholder-slate-500
+                             focus:outline-none focus:ring-2 focus:ring-teal-500 focus:border-transparent"
+                  placeholder="password"
+                  required
+                />
+              </div>
+
+              <button
+                type="submit"
+                disabled={loading}
+                className="w-full py-3 bg-teal-600 hover:bg-teal-700 disabled:bg-teal-800
+                           text-white rounded-lg font-medium transition-colors"
+              >
+                {loading ? "Signing in..." : "Sign in"}
+              </button>
+            </form>
+          )}
         </div>
       </div>
     </div>
@@ -11,6 +11,7 @@ import {
   FileRenderer,
   ToolCallRenderer,
   QuestionRenderer,
+  ChangesRenderer,
 } from "./renderers";
 
 function formatElapsed(ms: number): string {
@@ -372,6 +373,9 @@ function MessageContent({
     case "tool":
       return <ToolCallRenderer content={content} />;
 
+    case "changes":
+      return <ChangesRenderer content={content} />;
+
     case "ansi":
       return (
         <CollapsibleOutput content={content}>
@@ -0,0 +1,139 @@
+import { useEffect, useState } from "react";
+import { Link, useLocation } from "react-router-dom";
+import { api } from "@/api/client";
+
+// ResetPassword redeems an admin-issued reset link. The token arrives in the
+// URL fragment so it never reaches the hub's access logs.
+export function ResetPassword() {
+  const location = useLocation();
+  const [token, setToken] = useState("");
+  const [password, setPassword] = useState("");
+  const [confirm, setConfirm] = useState("");
+  const [error, setError] = useState("");
+  const [loading, setLoading] = useState(false);
+  const [username, setUsername] = useState<string | null>(null);
+
+  useEffect(() => {
+    const params = new URLSearchParams(location.hash.replace(/^#/, ""));
+    const t = params.get("token");
+    if (!t) return;
+    setToken(t);
+    window.history.replaceState(null, "", location.pathname);
+  }, [location.hash, location.pathname]);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError("");
+
+    if (password.length < 8) {
+      setError("Password must be at least 8 characters");
+      return;
+    }
+    if (password !== confirm) {
+      setError("Passwords do not match");
+      return;
+    }
+
+    setLoading(true);
+    try {
+      const result = await api.resetPassword(token, password);
+      setUsername(result.username);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Password reset failed");
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-slate-900 px-4 relative overflow-hidden">
+      <div className="absolute inset-0 bg-gradient-to-br from-teal-950/20 via-transparent to-slate-900 pointer-events-none" />
+
+      <div className="w-full max-w-sm relative z-10">
+        <div className="bg-slate-800/50 border border-slate-700/50 rounded-2xl p-8">
+          <div className="text-center mb-8">
+            <h1 className="text-3xl font-bold amurg-logo">Amurg</h1>
+            <p className="text-slate-400 mt-2">Choose a new password</p>
+          </div>
+
+          {username ? (
+            <div className="space-y-4 text-center">
+              <p className="text-slate-300 text-sm">
+                The password for <span className="font-medium">{username}</span> has been changed.
+              </p>
+              <Link
+                to="/login"
+                className="block w-full py-3 bg-teal-600 hover:bg-teal-700 text-center
+                           text-white rounded-lg font-medium transition-colors"
+              >
+                Sign in
+              </Link>
+            </div>
+          ) : !token ? (
+            <p className="text-slate-400 text-sm text-center">
+              This reset link is incomplete. Ask an administrator for a new one.
+            </p>
+          ) : (
+            <form onSubmit={handleSubmit} className="space-y-4">
+              {error && (
+                <div className="bg-red-900/50 text-red-300 px-4 py-2 rounded-lg text-sm">
+                  {error}
+                </div>
+              )}
+
+              <div>
+                <label
+                  htmlFor="new-password"
+                  className="block text-sm text-slate-400 mb-1"
+                >
+                  New password
+                </label>
+                <input
+                  id="new-password"
+                  type="password"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                  autoComplete="new-password"
+                  className="w-full px-3 py-2.5 bg-slate-800 border border-slate-700 rounded-lg
+                             text-slate-100 placeholder-slate-500
+                             focus:outline-none focus:ring-2 focus:ring-teal-500 focus:border-transparent"
+                  autoFocus
+                  required
+                />
+              </div>
+
+              <div>
+                <label
+                  htmlFor="confirm-password"
+                  className="block text-sm text-slate-400 mb-1"
+                >
+                  Confirm password
+                </label>
+                <input
+                  id="confirm-password"
+                  type="password"
+                  value={confirm}
+                  onChange={(e) => setConfirm(e.target.value)}
+                  autoComplete="new-password"
+                  className="w-full px-3 py-2.5 bg-slate-800 border border-slate-700 rounded-lg
+                             text-slate-100 placeholder-slate-500
+                             focus:outline-none focus:ring-2 focus:ring-teal-500 focus:border-transparent"
+                  required
+                />
+              </div>
+
+              <button
+                type="submit"
+                disabled={loading}
+                className="w-full py-3 bg-teal-600 hover:bg-teal-700 disabled:bg-teal-800
+                           text-white rounded-lg font-medium transition-colors"
+              >
+                {loading ? "Saving..." : "Set password"}
+              </button>
+            </form>
+          )}
+        </div>
+      </div>
+    </div>
+  );
+}
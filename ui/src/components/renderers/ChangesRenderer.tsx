@@ -0,0 +1,96 @@
+import { useState } from "react";
+import { DiffRenderer } from "./DiffRenderer";
+
+interface FileChange {
+  path: string;
+  old_path?: string;
+  status: "added" | "modified" | "deleted" | "renamed";
+  additions: number;
+  deletions: number;
+  binary?: boolean;
+}
+
+interface TurnDiff {
+  files: FileChange[];
+  patch?: string;
+  truncated?: boolean;
+}
+
+const STATUS_LABEL: Record<FileChange["status"], { letter: string; className: string }> = {
+  added: { letter: "A", className: "text-green-400" },
+  modified: { letter: "M", className: "text-amber-400" },
+  deleted: { letter: "D", className: "text-red-400" },
+  renamed: { letter: "R", className: "text-blue-400" },
+};
+
+/**
+ * Renders the files an agent turn changed in its work dir, with the
+ * unified diff behind a toggle.
+ */
+export function ChangesRenderer({ content }: { content: string }) {
+  const [expanded, setExpanded] = useState(false);
+
+  let diff: TurnDiff;
+  try {
+    diff = JSON.parse(content);
+  } catch {
+    return <pre className="text-xs text-slate-400">{content}</pre>;
+  }
+
+  const files = diff.files ?? [];
+  const additions = files.reduce((n, f) => n + (f.additions || 0), 0);
+  const deletions = files.reduce((n, f) => n + (f.deletions || 0), 0);
+
+  return (
+    <div className="my-1 rounded border border-slate-700/50 bg-slate-800/30 text-xs">
+      <button
+        onClick={() => setExpanded(!expanded)}
+        disabled={!diff.patch}
+        className="w-full flex items-center gap-2 px-3 py-1.5 text-left hover:bg-slate-800/50 transition-colors"
+      >
+        <span className="flex-shrink-0 font-medium text-slate-300">
+          {files.length} file{files.length === 1 ? "" : "s"} changed
+        </span>
+        <span className="text-green-400">+{additions}</span>
+        <span className="text-red-400">-{deletions}</span>
+        <span className="flex-1" />
+        {diff.patch && (
+          <span className="flex-shrink-0 text-slate-600">
+            {expanded ? "\u25BC" : "\u25B6"}
+          </span>
+        )}
+      </button>
+
+      <ul className="px-3 py-1.5 border-t border-slate-700/30 font-mono space-y-0.5">
+        {files.map((f) => {
+          const status = STATUS_LABEL[f.status] ?? STATUS_LABEL.modified;
+          return (
+            <li key={f.path} className="flex items-center gap-2">
+              <span className={`w-3 flex-shrink-0 ${status.className}`}>{status.letter}</span>
+              <span className="flex-1 truncate text-slate-300" title={f.path}>
+                {f.old_path ? `${f.old_path} \u2192 ${f.path}` : f.path}
+              </span>
+              {f.binary ? (
+                <span className="text-slate-500">binary</span>
+              ) : (
+                <span className="flex-shrink-0">
+                  <span className="text-green-400">+{f.additions}</span>{" "}
+                  <span className="text-red-400">-{f.deletions}</span>
+                </span>
+              )}
+            </li>
+          );
+        })}
+      </ul>
+
+      {expanded && diff.patch && (
+        <div className="px-3 border-t border-slate-700/30">
+          <DiffRenderer content={diff.patch} />
+          {diff.truncated && (
+            <p className="pb-2 text-slate-500 italic">Diff truncated.</p>
+          )}
+        </div>
+      )}
+    </div>
+  );
+}
@@ -15,6 +15,12 @@ describe("detectContentType", () => {
     expect(detectContentType('{"json": true}', "agent", "system")).toBe("plain");
   });
 
+  // --- Diff channel renders as a change set ---
+  it("returns changes for the diff channel", () => {
+    const diff = '{"files":[{"path":"a.go","status":"modified","additions":1,"deletions":0}],"patch":"--- a/a.go"}';
+    expect(detectContentType(diff, "agent", "diff")).toBe("changes");
+  });
+
   // --- ANSI detection ---
   it("detects ANSI escape sequences", () => {
     expect(detectContentType("\x1b[31mred text\x1b[0m", "agent", "stdout")).toBe("ansi");
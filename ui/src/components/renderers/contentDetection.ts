@@ -1,4 +1,4 @@
-export type ContentType = "ansi" | "diff" | "json" | "markdown" | "plain" | "file" | "tool" | "question";
+export type ContentType = "ansi" | "diff" | "json" | "markdown" | "plain" | "file" | "tool" | "question" | "changes";
 
 const ANSI_RE = /\x1b\[[\d;]*m/;
 
@@ -21,6 +21,9 @@ export function detectContentType(
   // Tool channel renders as collapsible tool call card.
   if (channel === "tool") return "tool";
 
+  // Diff channel carries the files a turn changed in the work dir.
+  if (channel === "diff") return "changes";
+
   // System channel is always plain.
   if (channel === "system") return "plain";
 
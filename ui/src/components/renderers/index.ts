@@ -9,3 +9,4 @@ export { CollapsibleOutput } from "./CollapsibleOutput";
 export { FileRenderer } from "./FileRenderer";
 export { ToolCallRenderer } from "./ToolCallRenderer";
 export { QuestionRenderer } from "./QuestionRenderer";
+export { ChangesRenderer } from "./ChangesRenderer";
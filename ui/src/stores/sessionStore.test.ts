@@ -659,12 +659,14 @@ describe("sessionStore", () => {
           session_id: "sess-1",
           ok: true,
           native_handle: "claude-session-123",
+          branch: "amurg/sess-1",
         },
       });
 
       const session = useSessionStore.getState().sessions[0];
       expect(session.state).toBe("active");
       expect(session.native_handle).toBe("claude-session-123");
+      expect(session.branch).toBe("amurg/sess-1");
     });
 
     it("closes a rejected session and surfaces the runtime error", () => {
@@ -698,6 +700,39 @@ describe("sessionStore", () => {
       expect(state.toasts[0]?.message).toBe("resume failed");
     });
 
+    it("tracks the queue position of a queued session", () => {
+      const onSessionQueued = getSocketHandler("session.queued");
+      const onSessionCreated = getSocketHandler("session.created");
+
+      useSessionStore.setState({
+        sessions: [
+          {
+            id: "sess-1",
+            user_id: "u1",
+            agent_id: "ag-1",
+            runtime_id: "rt-1",
+            profile: "claude-code",
+            state: "creating",
+            created_at: "2024-01-01T00:00:00Z",
+            updated_at: "2024-01-01T00:00:00Z",
+          },
+        ],
+      });
+
+      onSessionQueued({ payload: { session_id: "sess-1", position: 3 } });
+      let session = useSessionStore.getState().sessions[0];
+      expect(session.state).toBe("queued");
+      expect(session.queue_position).toBe(3);
+
+      onSessionQueued({ payload: { session_id: "sess-1", position: 1 } });
+      expect(useSessionStore.getState().sessions[0].queue_position).toBe(1);
+
+      onSessionCreated({ payload: { session_id: "sess-1", ok: true } });
+      session = useSessionStore.getState().sessions[0];
+      expect(session.state).toBe("active");
+      expect(session.queue_position).toBeUndefined();
+    });
+
     it("updates the session native_handle from turn.completed", () => {
       const onTurnCompleted = getSocketHandler("turn.completed");
 
@@ -137,6 +137,7 @@ interface SessionState {
   // Actions
   init: () => Promise<void>;
   login: (username: string, password: string) => Promise<void>;
+  loginWithToken: (token: string) => Promise<void>;
   logout: () => void;
   loadAgents: () => Promise<void>;
   loadSessions: () => Promise<void>;
@@ -166,11 +167,12 @@ export const useSessionStore = create<SessionState>((set, get) => {
         ok: boolean;
         error?: string;
         native_handle?: string;
+        branch?: string;
       };
       const { sessions } = get();
 
       if (!payload.ok) {
-        set({ sessions: patchSession(sessions, payload.session_id, { state: "closed" }) });
+        set({ sessions: patchSession(sessions, payload.session_id, { state: "closed", queue_position: undefined }) });
         if (payload.error) {
           get().addToast(payload.error, "error");
         }
@@ -180,7 +182,19 @@ export const useSessionStore = create<SessionState>((set, get) => {
       set({
         sessions: patchSession(sessions, payload.session_id, {
           state: "active",
+          queue_position: undefined,
           ...(payload.native_handle ? { native_handle: payload.native_handle } : {}),
+          ...(payload.branch ? { branch: payload.branch } : {}),
+        }),
+      });
+    });
+
+    socket.on("session.queued", (env: Envelope) => {
+      const payload = env.payload as { session_id: string; position: number };
+      set({
+        sessions: patchSession(get().sessions, payload.session_id, {
+          state: "queued",
+          queue_position: payload.position,
         }),
       });
     });
@@ -267,6 +281,7 @@ export const useSessionStore = create<SessionState>((set, get) => {
         sessions: patchSession(sessions, payload.session_id, {
           state: "active",
           ...(payload.native_handle ? { native_handle: payload.native_handle } : {}),
+          ...(payload.branch ? { branch: payload.branch } : {}),
         }),
       });
     });
@@ -455,6 +470,20 @@ export const useSessionStore = create<SessionState>((set, get) => {
       Promise.all([get().loadAgents(), get().loadSessions()]).catch(() => {});
     },
 
+    loginWithToken: async (token: string) => {
+      api.setToken(token);
+      const user = await api.getMe();
+      set({ user, isAuthenticated: true });
+      socket.setStateCallback((state) => {
+        set({ connectionState: state });
+        if (state === "connected") {
+          set({ responding: new Set() });
+        }
+      });
+      await socket.connect();
+      Promise.all([get().loadAgents(), get().loadSessions()]).catch(() => {});
+    },
+
     logout: () => {
       socket.disconnect();
       api.logout();
@@ -17,6 +17,13 @@ export interface SecurityProfile {
   env_whitelist?: string[];
 }
 
+export interface SandboxStatus {
+  mode: string; // "auto" | "bwrap" | "landlock"
+  backend?: string; // backend in use; empty when unsandboxed
+  deny_network?: boolean;
+  error?: string;
+}
+
 export interface AgentInfo {
   id: string;
   runtime_id: string;
@@ -26,6 +33,7 @@ export interface AgentInfo {
   online: boolean;
   caps: string; // JSON-encoded caps from store
   security?: string | SecurityProfile; // JSON string from hub or parsed object
+  sandbox?: SandboxStatus;
 }
 
 export type ConnectionState = "connected" | "disconnected" | "reconnecting";
@@ -40,6 +48,11 @@ export interface SessionInfo {
   state: string;
   native_handle?: string;
   resumed_from?: string;
+  fork_seq?: number;
+  fork_mode?: "native" | "transcript"; // transcript forks start a fresh agent seeded with the copied transcript
+  branch?: string; // git branch of the session's worktree
+  queue_position?: number; // place in the runtime's session queue while state is "queued"
+  member_role?: "observer" | "collaborator";
   created_at: string;
   updated_at: string;
   agent_name?: string;
@@ -60,6 +73,7 @@ export interface StoredMessage {
   direction: "user" | "agent";
   channel: string;
   content: string;
+  author_id?: string;
   created_at: string;
 }
 
@@ -152,6 +166,7 @@ export interface AdminAgentInfo {
   tags: Record<string, string>;
   caps: Record<string, unknown>;
   security: SecurityProfile;
+  sandbox?: SandboxStatus;
   config_override?: AgentConfigOverride;
 }
 
@@ -169,6 +184,9 @@ export interface AgentLimitsWire {
   session_timeout?: string;
   max_output_bytes?: number;
   idle_timeout?: string;
+  cpu_quota?: number; // CPUs, e.g. 1.5
+  memory_max_bytes?: number;
+  pids_max?: number;
 }
 
 // Native session from an agent's local storage